@@ -0,0 +1,479 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/ibrahmsql/gocat/internal/security"
+	"github.com/ibrahmsql/gocat/internal/signals"
+	"github.com/spf13/cobra"
+)
+
+// errUnknownSession is returned for actions on sessions that are not open
+var errUnknownSession = errors.New("unknown session")
+
+// policySource is what the connection policy is built from
+type policySource struct {
+	Allow     []string `json:"allow"`
+	Deny      []string `json:"deny"`
+	AllowFile string   `json:"allow_file"`
+	DenyFile  string   `json:"deny_file"`
+	RateLimit string   `json:"rate_limit"`
+}
+
+// policyInfo is the result of the policy.get and policy.reload methods
+type policyInfo struct {
+	policySource
+	Rejected int64 `json:"rejected"`
+}
+
+// connPolicy is the access control and per-connection rate limit applied to
+// connections accepted by guarded listeners. It can be reloaded at runtime.
+type connPolicy struct {
+	mu     sync.RWMutex
+	source policySource
+	acl    *security.AccessControl // nil when there are no rules
+	gen    uint64                  // bumped on every reload
+
+	rejected int64
+}
+
+// connectionPolicy is the policy of the running command
+var connectionPolicy = &connPolicy{}
+
+// load builds the policy from src, reading the allow and deny files again.
+// The current policy is kept when src is invalid.
+func (p *connPolicy) load(src policySource) error {
+	if _, err := network.NewRateLimiter(src.RateLimit); err != nil {
+		return err
+	}
+
+	var acl *security.AccessControl
+	if len(src.Allow) > 0 || len(src.Deny) > 0 || src.AllowFile != "" || src.DenyFile != "" {
+		acl = security.NewAccessControl()
+		for _, host := range src.Allow {
+			if err := acl.AddAllowedHost(host); err != nil {
+				return err
+			}
+		}
+		for _, host := range src.Deny {
+			if err := acl.AddDeniedHost(host); err != nil {
+				return err
+			}
+		}
+		if src.AllowFile != "" {
+			if err := acl.LoadAllowFile(src.AllowFile); err != nil {
+				return err
+			}
+		}
+		if src.DenyFile != "" {
+			if err := acl.LoadDenyFile(src.DenyFile); err != nil {
+				return err
+			}
+		}
+	}
+
+	p.mu.Lock()
+	p.source = src
+	p.acl = acl
+	p.gen++
+	p.mu.Unlock()
+	return nil
+}
+
+// allows reports whether a peer may connect, counting rejections
+func (p *connPolicy) allows(addr net.Addr) bool {
+	p.mu.RLock()
+	acl := p.acl
+	p.mu.RUnlock()
+	if acl == nil || acl.IsAllowed(addr) {
+		return true
+	}
+	atomic.AddInt64(&p.rejected, 1)
+	return false
+}
+
+// rateLimit returns the rate limit and the generation it belongs to
+func (p *connPolicy) rateLimit() (string, uint64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.source.RateLimit, p.gen
+}
+
+func (p *connPolicy) info() policyInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return policyInfo{policySource: p.source, Rejected: atomic.LoadInt64(&p.rejected)}
+}
+
+// loadConnectionPolicy builds the connection policy from the global --allow,
+// --deny, --allowfile, --denyfile and --rate-limit flags, along with the
+// PROXY protocol settings
+func loadConnectionPolicy(cmd *cobra.Command) {
+	loadProxyProtocol(cmd)
+	flags := cmd.Root().PersistentFlags()
+	var src policySource
+	src.Allow, _ = flags.GetStringSlice("allow")
+	src.Deny, _ = flags.GetStringSlice("deny")
+	src.AllowFile, _ = flags.GetString("allowfile")
+	src.DenyFile, _ = flags.GetString("denyfile")
+	src.RateLimit, _ = flags.GetString("rate-limit")
+	if err := connectionPolicy.load(src); err != nil {
+		logger.Fatal("Invalid access policy: %v", err)
+	}
+}
+
+// sessionInfo describes an open session for sessions.list
+type sessionInfo struct {
+	ID       string    `json:"id"`
+	Listener string    `json:"listener"`
+	Remote   string    `json:"remote"`
+	Started  time.Time `json:"started"`
+	BytesIn  int64     `json:"bytes_in"`
+	BytesOut int64     `json:"bytes_out"`
+}
+
+// sessionRegistry tracks the connections accepted by guarded listeners
+type sessionRegistry struct {
+	mu     sync.Mutex
+	nextID int64
+	conns  map[string]*trackedConn
+}
+
+// adminSessions holds the open sessions of the running command
+var adminSessions = &sessionRegistry{conns: make(map[string]*trackedConn)}
+
+func (r *sessionRegistry) add(conn net.Conn, listener string) *trackedConn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	tc := &trackedConn{
+		Conn:     conn,
+		registry: r,
+		id:       "s" + strconv.FormatInt(r.nextID, 10),
+		listener: listener,
+		started:  time.Now(),
+	}
+	r.conns[tc.id] = tc
+	return tc
+}
+
+// list returns the open sessions, oldest first
+func (r *sessionRegistry) list() []sessionInfo {
+	r.mu.Lock()
+	list := make([]sessionInfo, 0, len(r.conns))
+	for _, tc := range r.conns {
+		list = append(list, tc.info())
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(list[i].ID, "s"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(list[j].ID, "s"))
+		return a < b
+	})
+	return list
+}
+
+// kill closes the session with the given ID
+func (r *sessionRegistry) kill(id string) error {
+	r.mu.Lock()
+	tc, ok := r.conns[id]
+	r.mu.Unlock()
+	if !ok {
+		return errUnknownSession
+	}
+	logger.Info("Session %s (%s) killed via admin API", id, tc.RemoteAddr())
+	return tc.Close()
+}
+
+// trackedConn is a session: it counts traffic, applies the policy's rate
+// limit and leaves the registry when closed
+type trackedConn struct {
+	net.Conn
+	registry *sessionRegistry
+	id       string
+	listener string
+	started  time.Time
+
+	bytesIn  int64
+	bytesOut int64
+
+	limitMu      sync.Mutex
+	limitGen     uint64
+	readLimiter  *network.RateLimiter
+	writeLimiter *network.RateLimiter
+
+	closeOnce sync.Once
+}
+
+// limiters returns the connection's rate limiters, rebuilding them when the
+// policy was reloaded
+func (c *trackedConn) limiters() (*network.RateLimiter, *network.RateLimiter) {
+	rate, gen := connectionPolicy.rateLimit()
+	c.limitMu.Lock()
+	defer c.limitMu.Unlock()
+	if gen != c.limitGen {
+		c.readLimiter, _ = network.NewRateLimiter(rate)
+		c.writeLimiter, _ = network.NewRateLimiter(rate)
+		c.limitGen = gen
+	}
+	return c.readLimiter, c.writeLimiter
+}
+
+func (c *trackedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		atomic.AddInt64(&c.bytesIn, int64(n))
+		if limiter, _ := c.limiters(); limiter != nil {
+			if waitErr := limiter.Wait(context.Background(), n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	if _, limiter := c.limiters(); limiter != nil {
+		if err := limiter.Wait(context.Background(), len(p)); err != nil {
+			return 0, err
+		}
+	}
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.bytesOut, int64(n))
+	return n, err
+}
+
+// Unwrap lets relays splice the raw connection while no rate limit applies
+func (c *trackedConn) Unwrap() net.Conn {
+	if readLimiter, writeLimiter := c.limiters(); readLimiter != nil || writeLimiter != nil {
+		return nil
+	}
+	return c.Conn
+}
+
+// CountRead records bytes a relay read from the raw connection
+func (c *trackedConn) CountRead(n int64) {
+	atomic.AddInt64(&c.bytesIn, n)
+}
+
+// CountWritten records bytes a relay wrote to the raw connection
+func (c *trackedConn) CountWritten(n int64) {
+	atomic.AddInt64(&c.bytesOut, n)
+}
+
+// CloseWrite half-closes the raw connection so relays can pass EOF on
+func (c *trackedConn) CloseWrite() error {
+	if hc, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return hc.CloseWrite()
+	}
+	return relay.ErrNoHalfClose
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.registry.mu.Lock()
+		delete(c.registry.conns, c.id)
+		c.registry.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+func (c *trackedConn) info() sessionInfo {
+	return sessionInfo{
+		ID:       c.id,
+		Listener: c.listener,
+		Remote:   c.RemoteAddr().String(),
+		Started:  c.started,
+		BytesIn:  atomic.LoadInt64(&c.bytesIn),
+		BytesOut: atomic.LoadInt64(&c.bytesOut),
+	}
+}
+
+// guardedListener drops peers the connection policy denies and registers
+// the others as sessions
+type guardedListener struct {
+	net.Listener
+	label string
+}
+
+// guardListener applies the connection policy to ln. PROXY protocol headers
+// are read first, so the policy and the sessions see the client's address.
+func guardListener(ln net.Listener) net.Listener {
+	return &guardedListener{Listener: acceptProxyProtocol(ln), label: ln.Addr().String()}
+}
+
+// listenGuarded opens a TCP listener that applies the connection policy
+func listenGuarded(address string) (net.Listener, error) {
+	ln, err := listenTCP("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return guardListener(ln), nil
+}
+
+func (l *guardedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !connectionPolicy.allows(conn.RemoteAddr()) {
+			logger.Warn("Connection from %s denied by access policy", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		return adminSessions.add(conn, l.label), nil
+	}
+}
+
+// drainBeforeExit is set by commands that let their sessions finish on
+// SIGINT or SIGTERM; the admin socket stays up until it returns
+var drainBeforeExit func()
+
+// startAdminSocket serves the admin API on the --admin-socket path, if set.
+// Every command gets status, log level, policy and session methods; register
+// adds the command's own methods and may replace the defaults. It returns
+// the server, or nil without --admin-socket.
+func startAdminSocket(cmd *cobra.Command, register func(*admin.Server)) *admin.Server {
+	path, _ := cmd.Root().PersistentFlags().GetString("admin-socket")
+	if path == "" {
+		return nil
+	}
+
+	srv := admin.NewServer(cmd.Name())
+	registerAdminDefaults(srv)
+	if register != nil {
+		register(srv)
+	}
+	if err := srv.Listen(path); err != nil {
+		logger.Fatal("Failed to start admin socket: %v", err)
+	}
+
+	// Remove the socket on Ctrl+C instead of leaving it behind
+	signals.SetupSignalHandler(func() {
+		if drainBeforeExit != nil {
+			drainBeforeExit()
+		}
+		srv.Close()
+		os.Exit(0)
+	})
+
+	go func() {
+		if err := srv.Serve(); err != nil {
+			logger.Error("Admin socket error: %v", err)
+		}
+	}()
+	return srv
+}
+
+// decodeID reads a required "id" param. Numeric IDs are accepted too, as
+// gocat ctl sends id=1234 as a number.
+func decodeID(params json.RawMessage) (string, error) {
+	var p struct {
+		ID interface{} `json:"id"`
+	}
+	if err := admin.DecodeParams(params, &p); err != nil {
+		return "", err
+	}
+	switch id := p.ID.(type) {
+	case string:
+		if id != "" {
+			return id, nil
+		}
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64), nil
+	}
+	return "", admin.InvalidParams("missing id")
+}
+
+// registerAdminDefaults adds the methods every command supports
+func registerAdminDefaults(srv *admin.Server) {
+	srv.Handle("log.level", "Get the log level, or set it with {\"level\": \"debug|info|warn|error\"}", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Level string `json:"level"`
+		}
+		if err := admin.DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Level != "" {
+			level, err := logger.ParseLevel(p.Level)
+			if err != nil {
+				return nil, admin.InvalidParams("%v", err)
+			}
+			logger.SetLevel(level)
+		}
+		return map[string]string{"level": strings.ToLower(logger.GetLevel().String())}, nil
+	})
+
+	srv.Handle("policy.get", "Show the access control lists and rate limit", func(json.RawMessage) (interface{}, error) {
+		return connectionPolicy.info(), nil
+	})
+
+	srv.Handle("policy.reload", "Reload the ACL files; allow, deny, allow_file, deny_file and rate_limit params replace the current values", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Allow     *[]string `json:"allow"`
+			Deny      *[]string `json:"deny"`
+			AllowFile *string   `json:"allow_file"`
+			DenyFile  *string   `json:"deny_file"`
+			RateLimit *string   `json:"rate_limit"`
+		}
+		if err := admin.DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		src := connectionPolicy.info().policySource
+		if p.Allow != nil {
+			src.Allow = *p.Allow
+		}
+		if p.Deny != nil {
+			src.Deny = *p.Deny
+		}
+		if p.AllowFile != nil {
+			src.AllowFile = *p.AllowFile
+		}
+		if p.DenyFile != nil {
+			src.DenyFile = *p.DenyFile
+		}
+		if p.RateLimit != nil {
+			src.RateLimit = *p.RateLimit
+		}
+		if err := connectionPolicy.load(src); err != nil {
+			return nil, admin.InvalidParams("%v", err)
+		}
+		logger.Info("Access policy reloaded via admin API")
+		return connectionPolicy.info(), nil
+	})
+
+	srv.Handle("sessions.list", "List open sessions", func(json.RawMessage) (interface{}, error) {
+		return adminSessions.list(), nil
+	})
+
+	srv.Handle("sessions.kill", "Close a session: {\"id\": \"s1\"}", func(params json.RawMessage) (interface{}, error) {
+		id, err := decodeID(params)
+		if err != nil {
+			return nil, err
+		}
+		if err := adminSessions.kill(id); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
+		}
+		return map[string]string{"killed": id}, nil
+	})
+
+	srv.Handle("pools", "Show connection worker, buffer and backend connection pool usage", func(json.RawMessage) (interface{}, error) {
+		return currentPoolStats(), nil
+	})
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/proxyproto"
+)
+
+// echoGuarded serves an echo handler behind a guarded listener
+func echoGuarded(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := listenGuarded("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listenGuarded failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestAdminSessionsAndPolicy(t *testing.T) {
+	t.Cleanup(func() { connectionPolicy.load(policySource{}) })
+	if err := connectionPolicy.load(policySource{}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := admin.NewServer("test")
+	registerAdminDefaults(srv)
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	if err := srv.Listen(path); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	t.Cleanup(func() { srv.Close() })
+	client, err := admin.Dial(path, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ln := echoGuarded(t)
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("ping"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, make([]byte, 4)); err != nil {
+		t.Fatalf("echo failed: %v", err)
+	}
+
+	var sessions []sessionInfo
+	if err := client.Call("sessions.list", nil, &sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].BytesIn != 4 || sessions[0].BytesOut != 4 {
+		t.Fatalf("sessions.list = %+v", sessions)
+	}
+
+	if err := client.Call("sessions.kill", map[string]string{"id": sessions[0].ID}, nil); err != nil {
+		t.Fatalf("sessions.kill failed: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("killed session is still open")
+	}
+	if err := client.Call("sessions.kill", map[string]string{"id": sessions[0].ID}, nil); err == nil {
+		t.Error("killing a closed session should fail")
+	}
+
+	// Deny loopback: new peers are dropped and counted
+	var policy policyInfo
+	if err := client.Call("policy.reload", map[string]interface{}{"deny": []string{"127.0.0.1"}}, &policy); err != nil {
+		t.Fatalf("policy.reload failed: %v", err)
+	}
+	if !reflect.DeepEqual(policy.Deny, []string{"127.0.0.1"}) {
+		t.Errorf("policy.reload = %+v", policy)
+	}
+	denied, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer denied.Close()
+	denied.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := denied.Read(make([]byte, 1)); err == nil {
+		t.Error("denied peer was served")
+	}
+	if err := client.Call("policy.get", nil, &policy); err != nil || policy.Rejected != 1 {
+		t.Errorf("policy.get = %+v, %v; want 1 rejection", policy, err)
+	}
+
+	if err := client.Call("policy.reload", map[string]string{"rate_limit": "fast"}, nil); err == nil {
+		t.Error("invalid rate limit was accepted")
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	srv := admin.NewServer("test")
+	registerAdminDefaults(srv)
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	if err := srv.Listen(path); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	t.Cleanup(func() { srv.Close() })
+	client, err := admin.Dial(path, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var result map[string]string
+	if err := client.Call("log.level", map[string]string{"level": "warn"}, &result); err != nil || result["level"] != "warn" {
+		t.Errorf("log.level = %v, %v", result, err)
+	}
+	client.Call("log.level", map[string]string{"level": "info"}, nil)
+	if err := client.Call("log.level", map[string]string{"level": "loud"}, nil); err == nil {
+		t.Error("invalid level was accepted")
+	}
+}
+
+func TestParseCtlParams(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "null"},
+		{[]string{`{"id":"s1"}`}, `{"id":"s1"}`},
+		{[]string{"id=s1", "muted=false"}, `{"id":"s1","muted":false}`},
+		{[]string{"id=1234", `allow=["10.0.0.0/8"]`}, `{"allow":["10.0.0.0/8"],"id":1234}`},
+	}
+	for _, tt := range tests {
+		params, err := parseCtlParams(tt.args)
+		if err != nil {
+			t.Errorf("parseCtlParams(%v) failed: %v", tt.args, err)
+			continue
+		}
+		got, _ := json.Marshal(params)
+		if string(got) != tt.want {
+			t.Errorf("parseCtlParams(%v) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	if _, err := parseCtlParams([]string{"novalue"}); err == nil {
+		t.Error("expected an error for a param without '='")
+	}
+}
+
+func TestTrackedConnRelayCounts(t *testing.T) {
+	ln, err := listenGuarded("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	relayed := make(chan *trackedConn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(relayed)
+			return
+		}
+		defer conn.Close()
+		relayCopy(conn, conn)
+		relayed <- conn.(*trackedConn)
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	data := make([]byte, 3<<20)
+	go func() {
+		client.Write(data)
+		client.(*net.TCPConn).CloseWrite()
+	}()
+	n, err := io.Copy(io.Discard, client)
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("echoed %d bytes, %v; want %d", n, err, len(data))
+	}
+
+	tc := <-relayed
+	if tc == nil {
+		t.Fatal("accept failed")
+	}
+	if info := tc.info(); info.BytesIn != n || info.BytesOut != n {
+		t.Errorf("session counted %d in, %d out; want %d", info.BytesIn, info.BytesOut, n)
+	}
+}
+
+func TestGuardedListenerProxyProtocol(t *testing.T) {
+	var untrusted atomic.Bool
+	acceptProxyHeaders = &proxyproto.ListenerConfig{
+		Trusted: func(net.Addr) bool { return !untrusted.Load() },
+	}
+	t.Cleanup(func() {
+		acceptProxyHeaders = nil
+		connectionPolicy.load(policySource{})
+	})
+	if err := connectionPolicy.load(policySource{Deny: []string{"203.0.113.0/24"}}); err != nil {
+		t.Fatal(err)
+	}
+	ln := echoGuarded(t)
+
+	dial := func(header string) net.Conn {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.Write([]byte(header + "ping"))
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		return conn
+	}
+
+	// The policy applies to the client the header names, not the balancer
+	denied := dial("PROXY TCP4 203.0.113.7 192.0.2.1 4000 80\r\n")
+	if _, err := denied.Read(make([]byte, 1)); err == nil {
+		t.Error("client denied by the policy was served")
+	}
+
+	allowed := dial("PROXY TCP4 198.51.100.7 192.0.2.1 4000 80\r\n")
+	if _, err := io.ReadFull(allowed, make([]byte, 4)); err != nil {
+		t.Fatalf("echo failed: %v", err)
+	}
+	var found bool
+	for _, s := range adminSessions.list() {
+		found = found || s.Remote == "198.51.100.7:4000"
+	}
+	if !found {
+		t.Errorf("sessions = %+v, want one from 198.51.100.7:4000", adminSessions.list())
+	}
+
+	// An untrusted peer cannot claim an allowed address: its header is
+	// data and the policy sees the peer itself
+	untrusted.Store(true)
+	if err := connectionPolicy.load(policySource{Allow: []string{"198.51.100.0/24"}}); err != nil {
+		t.Fatal(err)
+	}
+	forged := dial("PROXY TCP4 198.51.100.7 192.0.2.1 4000 80\r\n")
+	if _, err := forged.Read(make([]byte, 1)); err == nil {
+		t.Error("untrusted peer with a forged header was served")
+	}
+}
@@ -1,19 +1,18 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
-	"net"
-	"sync"
-	"time"
 
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/broker"
 	"github.com/ibrahmsql/gocat/internal/logger"
 	"github.com/spf13/cobra"
 )
 
 var (
 	brokerPort     string
-	brokerClients  map[string]net.Conn
-	brokerMutex    sync.RWMutex
+	brokerServer   *broker.Server
 	brokerMaxConns int
 )
 
@@ -28,8 +27,7 @@ and relay data between them. Useful for creating a central hub for communication
 
 func init() {
 	rootCmd.AddCommand(brokerCmd)
-	brokerCmd.Flags().IntVarP(&brokerMaxConns, "max-conns", "m", 10, "Maximum number of concurrent connections")
-	brokerClients = make(map[string]net.Conn)
+	brokerCmd.Flags().IntVarP(&brokerMaxConns, "max-conns", "m", broker.DefaultMaxConns, "Maximum number of concurrent connections")
 }
 
 func runBroker(cmd *cobra.Command, args []string) {
@@ -42,77 +40,97 @@ func runBroker(cmd *cobra.Command, args []string) {
 
 	logger.Info("Starting broker mode on port %s (max connections: %d)", brokerPort, brokerMaxConns)
 
-	if err := startBroker(brokerPort); err != nil {
+	loadConnectionPolicy(cmd)
+	if err := startBroker(cmd, brokerPort); err != nil {
 		logger.Fatal("Broker error: %v", err)
 	}
 }
 
-func startBroker(port string) error {
-	listener, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return fmt.Errorf("failed to start broker listener: %w", err)
+func startBroker(cmd *cobra.Command, port string) error {
+	brokerServer = broker.New(broker.Options{
+		MaxConns: brokerMaxConns,
+		OnEvent:  logBrokerEvent,
+		// Apply --allow/--deny and --rate-limit to every client
+		WrapListener: guardListener,
+	})
+	if err := brokerServer.Listen(":" + port); err != nil {
+		return err
 	}
-	defer listener.Close()
+	defer brokerServer.Close()
+	startAdminSocket(cmd, registerBrokerAdmin)
+	startMetricsExporter(cmd)
 
 	logger.Info("Broker listening on :%s", port)
+	return brokerServer.Serve()
+}
 
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			logger.Error("Failed to accept connection: %v", err)
-			continue
-		}
-
-		brokerMutex.Lock()
-		if len(brokerClients) >= brokerMaxConns {
-			logger.Warn("Maximum connections reached, rejecting %s", conn.RemoteAddr())
-			if err := conn.Close(); err != nil {
-				logger.Error("Failed to close connection: %v", err)
-			}
-			brokerMutex.Unlock()
-			continue
-		}
-
-		clientID := fmt.Sprintf("%s-%d", conn.RemoteAddr().String(), time.Now().Unix())
-		brokerClients[clientID] = conn
-		brokerMutex.Unlock()
+// logBrokerEvent writes broker events to the log
+func logBrokerEvent(e broker.Event) {
+	switch e.Kind {
+	case broker.EventJoin:
+		logger.Info("Client connected: %s (ID: %s)", e.Addr, e.Client)
+	case broker.EventLeave:
+		logger.Info("Client disconnected: %s", e.Client)
+	case broker.EventReject:
+		logger.Warn("Maximum connections reached, rejecting %s", e.Addr)
+	case broker.EventAcceptError:
+		logger.Error("Accept error: %s", e.Error)
+	case broker.EventData:
+		logger.Debug("Received %d bytes from %s, relayed to %d client(s)", e.Bytes, e.Client, e.Receivers)
+	case broker.EventKick:
+		logger.Info("Client kicked: %s", e.Client)
+	case broker.EventMute:
+		logger.Info("Client muted: %s", e.Client)
+	case broker.EventUnmute:
+		logger.Info("Client unmuted: %s", e.Client)
+	}
+}
 
-		logger.Info("Client connected: %s (ID: %s)", conn.RemoteAddr(), clientID)
-		go handleBrokerClient(clientID, conn)
+// decodeMute reads the params of sessions.mute; muted defaults to true
+func decodeMute(params json.RawMessage) (string, bool, error) {
+	id, err := decodeID(params)
+	if err != nil {
+		return "", false, err
+	}
+	var p struct {
+		Muted *bool `json:"muted"`
+	}
+	if err := admin.DecodeParams(params, &p); err != nil {
+		return "", false, err
 	}
+	return id, p.Muted == nil || *p.Muted, nil
 }
 
-func handleBrokerClient(clientID string, conn net.Conn) {
-	defer func() {
-		brokerMutex.Lock()
-		delete(brokerClients, clientID)
-		brokerMutex.Unlock()
-		if err := conn.Close(); err != nil {
-			logger.Error("Failed to close connection: %v", err)
+// registerBrokerAdmin exposes the broker's clients, events and totals on
+// the admin API, replacing the generic session methods
+func registerBrokerAdmin(srv *admin.Server) {
+	srv.Handle("stats", "Show broker totals", func(json.RawMessage) (interface{}, error) {
+		return brokerServer.Stats(), nil
+	})
+	srv.Handle("events", "Show recent broker events", func(json.RawMessage) (interface{}, error) {
+		return brokerServer.Events(), nil
+	})
+	srv.Handle("sessions.list", "List connected clients", func(json.RawMessage) (interface{}, error) {
+		return brokerServer.Clients(), nil
+	})
+	srv.Handle("sessions.kill", "Disconnect a client: {\"id\": \"c1\"}", func(params json.RawMessage) (interface{}, error) {
+		id, err := decodeID(params)
+		if err != nil {
+			return nil, err
 		}
-		logger.Info("Client disconnected: %s", clientID)
-	}()
-
-	buffer := make([]byte, 4096)
-	for {
-		n, err := conn.Read(buffer)
+		if err := brokerServer.Kick(id); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
+		}
+		return map[string]string{"killed": id}, nil
+	})
+	srv.Handle("sessions.mute", "Stop relaying a client's data: {\"id\": \"c1\", \"muted\": true}", func(params json.RawMessage) (interface{}, error) {
+		id, muted, err := decodeMute(params)
 		if err != nil {
-			logger.Debug("Client %s read error: %v", clientID, err)
-			return
+			return nil, err
 		}
-
-		data := buffer[:n]
-		logger.Debug("Received %d bytes from %s", n, clientID)
-
-		// Broadcast to all other clients
-		brokerMutex.RLock()
-		for otherID, otherConn := range brokerClients {
-			if otherID != clientID {
-				if _, err := otherConn.Write(data); err != nil {
-					logger.Error("Failed to write to client %s: %v", otherID, err)
-				}
-			}
+		if err := brokerServer.SetMuted(id, muted); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
 		}
-		brokerMutex.RUnlock()
-	}
+		return map[string]interface{}{"id": id, "muted": muted}, nil
+	})
 }
@@ -1,45 +1,38 @@
 package cmd
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
-	"net"
-	"strings"
-	"sync"
-	"time"
 
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/chat"
 	"github.com/ibrahmsql/gocat/internal/logger"
 	"github.com/spf13/cobra"
 )
 
 var (
 	chatPort     string
-	chatClients  map[string]*ChatClient
-	chatMutex    sync.RWMutex
+	chatServer   *chat.Server
 	chatMaxConns int
 	chatRoomName string
 )
 
-type ChatClient struct {
-	Conn     net.Conn
-	Nickname string
-	JoinTime time.Time
-}
-
 var chatCmd = &cobra.Command{
 	Use:   "chat [port]",
 	Short: "Start a chat server mode",
 	Long: `Start GoCat in chat server mode. This creates a simple chat room where
-multiple clients can connect and exchange messages with nicknames.`,
+multiple clients can connect and exchange messages with nicknames.
+
+Clients can change their nickname with /nick and move between rooms with
+/join; /rooms lists the rooms in use.`,
 	Args: cobra.ExactArgs(1),
 	Run:  runChat,
 }
 
 func init() {
 	rootCmd.AddCommand(chatCmd)
-	chatCmd.Flags().IntVarP(&chatMaxConns, "max-conns", "m", 20, "Maximum number of concurrent chat connections")
-	chatCmd.Flags().StringVarP(&chatRoomName, "room", "r", "GoCat-Room", "Chat room name")
-	chatClients = make(map[string]*ChatClient)
+	chatCmd.Flags().IntVarP(&chatMaxConns, "max-conns", "m", chat.DefaultMaxConns, "Maximum number of concurrent chat connections")
+	chatCmd.Flags().StringVarP(&chatRoomName, "room", "r", chat.DefaultRoom, "Chat room name")
 }
 
 func runChat(cmd *cobra.Command, args []string) {
@@ -52,213 +45,87 @@ func runChat(cmd *cobra.Command, args []string) {
 
 	logger.Info("Starting chat server '%s' on port %s (max connections: %d)", chatRoomName, chatPort, chatMaxConns)
 
-	if err := startChatServer(chatPort); err != nil {
+	loadConnectionPolicy(cmd)
+	if err := startChatServer(cmd, chatPort); err != nil {
 		logger.Fatal("Chat server error: %v", err)
 	}
 }
 
-func startChatServer(port string) error {
-	listener, err := net.Listen("tcp", ":"+port)
-	if err != nil {
-		return fmt.Errorf("failed to start chat server: %w", err)
+func startChatServer(cmd *cobra.Command, port string) error {
+	chatServer = chat.New(chat.Options{
+		Room:     chatRoomName,
+		MaxConns: chatMaxConns,
+		OnEvent:  logChatEvent,
+		// Apply --allow/--deny and --rate-limit to every client
+		WrapListener: guardListener,
+	})
+	if err := chatServer.Listen(":" + port); err != nil {
+		return err
 	}
-	defer listener.Close()
+	defer chatServer.Close()
+	startAdminSocket(cmd, registerChatAdmin)
+	startMetricsExporter(cmd)
 
 	logger.Info("Chat server '%s' listening on :%s", chatRoomName, port)
-
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			logger.Error("Failed to accept chat connection: %v", err)
-			continue
-		}
-
-		chatMutex.Lock()
-		if len(chatClients) >= chatMaxConns {
-			logger.Warn("Maximum chat connections reached, rejecting %s", conn.RemoteAddr())
-			if _, err := conn.Write([]byte("Chat room is full. Please try again later.\n")); err != nil {
-				logger.Error("Failed to write to connection: %v", err)
-			}
-			if err := conn.Close(); err != nil {
-				logger.Error("Failed to close connection: %v", err)
-			}
-			chatMutex.Unlock()
-			continue
-		}
-		chatMutex.Unlock()
-
-		logger.Info("New chat connection from: %s", conn.RemoteAddr())
-		go handleChatClient(conn)
-	}
+	return chatServer.Serve()
 }
 
-func handleChatClient(conn net.Conn) {
-	defer func() {
-		if r := recover(); r != nil {
-			logger.Error("Panic in handleChatClient: %v", r)
-		}
-		if err := conn.Close(); err != nil {
-			logger.Error("Error closing chat connection: %v", err)
-		}
-	}()
-
-	// Welcome message and nickname prompt
-	if _, err := conn.Write([]byte(fmt.Sprintf("Welcome to %s!\nPlease enter your nickname: ", chatRoomName))); err != nil {
-		logger.Error("Failed to send welcome message: %v", err)
-		return
-	}
-
-	// Read nickname
-	reader := bufio.NewReader(conn)
-	nickname, err := reader.ReadString('\n')
-	if err != nil {
-		logger.Debug("Failed to read nickname from %s: %v", conn.RemoteAddr(), err)
-		return
-	}
-	nickname = strings.TrimSpace(nickname)
-	if nickname == "" {
-		nickname = fmt.Sprintf("Guest-%d", time.Now().Unix()%10000)
-	}
-
-	// Check if nickname is already taken and register client atomically
-	chatMutex.Lock()
-	originalNick := nickname
-	counter := 1
-	for {
-		taken := false
-		for _, client := range chatClients {
-			if client.Nickname == nickname {
-				taken = true
-				break
-			}
-		}
-		if !taken {
-			break
-		}
-		counter++
-		nickname = fmt.Sprintf("%s%d", originalNick, counter)
-	}
-
-	clientID := fmt.Sprintf("%s-%d", conn.RemoteAddr().String(), time.Now().Unix())
-	client := &ChatClient{
-		Conn:     conn,
-		Nickname: nickname,
-		JoinTime: time.Now(),
-	}
-	chatClients[clientID] = client
-	chatMutex.Unlock()
-
-	// Notify about successful join
-	if _, err := conn.Write([]byte(fmt.Sprintf("You joined as '%s'. Type /help for commands.\n", nickname))); err != nil {
-		logger.Error("Failed to send join confirmation: %v", err)
-		// Remove client from map since we couldn't confirm join
-		chatMutex.Lock()
-		delete(chatClients, clientID)
-		chatMutex.Unlock()
-		return
+// logChatEvent writes chat events to the log
+func logChatEvent(e chat.Event) {
+	switch e.Kind {
+	case chat.EventJoin:
+		logger.Info("Chat user '%s' joined from %s", e.Nick, e.Text)
+	case chat.EventLeave:
+		logger.Info("Chat user '%s' left", e.Nick)
+	case chat.EventReject:
+		logger.Warn("Maximum chat connections reached, rejecting %s", e.Text)
+	case chat.EventAcceptError:
+		logger.Error("Accept error: %s", e.Text)
+	case chat.EventMessage:
+		logger.Debug("Chat message from %s in %s: %s", e.Nick, e.Room, e.Text)
+	case chat.EventNick:
+		logger.Info("Chat user '%s' is now known as '%s'", e.Text, e.Nick)
+	case chat.EventRoom:
+		logger.Info("Chat user '%s' moved from %s to %s", e.Nick, e.Text, e.Room)
+	case chat.EventKick:
+		logger.Info("Chat user '%s' was kicked", e.Nick)
+	case chat.EventMute:
+		logger.Info("Chat user '%s' was muted", e.Nick)
+	case chat.EventUnmute:
+		logger.Info("Chat user '%s' was unmuted", e.Nick)
 	}
-	broadcastMessage(fmt.Sprintf("*** %s joined the chat ***", nickname), "")
-
-	logger.Info("Chat user '%s' joined from %s", nickname, conn.RemoteAddr())
-
-	defer func() {
-		chatMutex.Lock()
-		delete(chatClients, clientID)
-		chatMutex.Unlock()
-		broadcastMessage(fmt.Sprintf("*** %s left the chat ***", nickname), "")
-		logger.Info("Chat user '%s' left", nickname)
-	}()
+}
 
-	// Handle messages
-	for {
-		message, err := reader.ReadString('\n')
+// registerChatAdmin exposes the chat server's clients, events and totals on
+// the admin API, replacing the generic session methods
+func registerChatAdmin(srv *admin.Server) {
+	srv.Handle("stats", "Show chat totals and room occupancy", func(json.RawMessage) (interface{}, error) {
+		return chatServer.Stats(), nil
+	})
+	srv.Handle("events", "Show recent chat events", func(json.RawMessage) (interface{}, error) {
+		return chatServer.Events(), nil
+	})
+	srv.Handle("sessions.list", "List connected users", func(json.RawMessage) (interface{}, error) {
+		return chatServer.Clients(), nil
+	})
+	srv.Handle("sessions.kill", "Kick a user: {\"id\": \"c1\"}", func(params json.RawMessage) (interface{}, error) {
+		id, err := decodeID(params)
 		if err != nil {
-			logger.Debug("Chat client %s disconnected: %v", nickname, err)
-			return
-		}
-
-		message = strings.TrimSpace(message)
-		if message == "" {
-			continue
-		}
-
-		// Handle commands
-		if strings.HasPrefix(message, "/") {
-			handleChatCommand(client, message)
-			continue
+			return nil, err
 		}
-
-		// Broadcast regular message
-		formattedMsg := fmt.Sprintf("[%s] %s: %s", time.Now().Format("15:04"), nickname, message)
-		broadcastMessage(formattedMsg, clientID)
-		logger.Debug("Chat message from %s: %s", nickname, message)
-	}
-}
-
-func handleChatCommand(client *ChatClient, command string) {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return
-	}
-
-	cmd := strings.ToLower(parts[0])
-	switch cmd {
-	case "/help":
-		help := `Available commands:
-/help - Show this help
-/list - List online users
-/time - Show current time
-/quit - Leave the chat
-`
-		client.Conn.Write([]byte(help))
-
-	case "/list":
-		chatMutex.RLock()
-		userList := fmt.Sprintf("Online users (%d):\n", len(chatClients))
-		for _, c := range chatClients {
-			duration := time.Since(c.JoinTime).Truncate(time.Second)
-			userList += fmt.Sprintf("  %s (online for %s)\n", c.Nickname, duration)
+		if err := chatServer.Kick(id); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
 		}
-		chatMutex.RUnlock()
-		client.Conn.Write([]byte(userList))
-
-	case "/time":
-		timeStr := fmt.Sprintf("Current time: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-		client.Conn.Write([]byte(timeStr))
-
-	case "/quit":
-		client.Conn.Write([]byte("Goodbye!\n"))
-		client.Conn.Close()
-
-	default:
-		client.Conn.Write([]byte(fmt.Sprintf("Unknown command: %s. Type /help for available commands.\n", cmd)))
-	}
-}
-
-func broadcastMessage(message string, excludeClientID string) {
-	chatMutex.RLock()
-	// Create a copy of clients to avoid holding lock during network operations
-	clients := make(map[string]*ChatClient)
-	for id, client := range chatClients {
-		if id != excludeClientID {
-			clients[id] = client
+		return map[string]string{"killed": id}, nil
+	})
+	srv.Handle("sessions.mute", "Silence a user: {\"id\": \"c1\", \"muted\": true}", func(params json.RawMessage) (interface{}, error) {
+		id, muted, err := decodeMute(params)
+		if err != nil {
+			return nil, err
 		}
-	}
-	chatMutex.RUnlock()
-
-	// Send messages without holding the lock
-	for clientID, client := range clients {
-		if _, err := client.Conn.Write([]byte(message + "\n")); err != nil {
-			logger.Warn("Failed to send message to client %s: %v", clientID, err)
-			// Remove failed client from the map
-			chatMutex.Lock()
-			if _, exists := chatClients[clientID]; exists {
-				delete(chatClients, clientID)
-				if closeErr := client.Conn.Close(); closeErr != nil {
-					logger.Error("Error closing failed client connection: %v", closeErr)
-				}
-			}
-			chatMutex.Unlock()
+		if err := chatServer.SetMuted(id, muted); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
 		}
-	}
+		return map[string]interface{}{"id": id, "muted": muted}, nil
+	})
 }
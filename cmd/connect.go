@@ -1,21 +1,26 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/url"
 	"os"
-	"os/exec"
 	"runtime"
 	"strings"
 	"time"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
 	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/process"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/ibrahmsql/gocat/internal/resolver"
+	"github.com/ibrahmsql/gocat/internal/scripting"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/proxy"
 )
@@ -37,7 +42,6 @@ var (
 	sendOnly     bool
 	recvOnly     bool
 	outputFile   string
-	hexDumpFile  string
 	appendOutput bool
 	noShutdown   bool
 	// Protocol flags
@@ -47,6 +51,8 @@ var (
 	// Source flags
 	sourceAddress string
 	sourcePort    int
+	// Lua connection handler
+	connectLuaExec string
 )
 
 var connectCmd = &cobra.Command{
@@ -112,6 +118,9 @@ func runConnect(cmd *cobra.Command, args []string) {
 	if globalSCTP, _ := cmd.Root().PersistentFlags().GetBool("sctp"); globalSCTP {
 		useSCTP = true
 	}
+	if useSCTP {
+		loadSCTPConfig(cmd)
+	}
 	if globalWait, _ := cmd.Root().PersistentFlags().GetDuration("wait"); globalWait > 0 {
 		timeout = globalWait
 	}
@@ -147,9 +156,6 @@ func runConnect(cmd *cobra.Command, args []string) {
 	if globalOutput, _ := cmd.Root().PersistentFlags().GetString("output"); globalOutput != "" {
 		outputFile = globalOutput
 	}
-	if globalHexDump, _ := cmd.Root().PersistentFlags().GetString("hex-dump"); globalHexDump != "" {
-		hexDumpFile = globalHexDump
-	}
 	if globalAppend, _ := cmd.Root().PersistentFlags().GetBool("append-output"); globalAppend {
 		appendOutput = true
 	}
@@ -170,6 +176,9 @@ func runConnect(cmd *cobra.Command, args []string) {
 	if globalSourcePort, _ := cmd.Root().PersistentFlags().GetInt("source-port"); globalSourcePort > 0 {
 		sourcePort = globalSourcePort
 	}
+	if globalLuaExec, _ := cmd.Root().PersistentFlags().GetString("lua-exec"); globalLuaExec != "" {
+		connectLuaExec = globalLuaExec
+	}
 
 	if err := connect(host, port, shellPath); err != nil {
 		logger.Fatal("Error: %v", err)
@@ -203,27 +212,30 @@ func connect(host, port, shell string) error {
 	var conn net.Conn
 	var err error
 
-	// Retry logic with exponential backoff
-	for attempt := 0; attempt <= retryCount; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(attempt*attempt) * time.Second
-			logger.Info("Retrying connection (attempt %d/%d) in %v", attempt, retryCount+1, backoff)
-			time.Sleep(backoff)
+	// Compile the Lua handler before dialing so script errors don't waste a connection
+	var luaConnHandler *scripting.ConnHandler
+	if connectLuaExec != "" {
+		config, configErr := luaEngineConfig(connectLuaExec)
+		if configErr != nil {
+			return fmt.Errorf("failed to load Lua handler: %w", configErr)
 		}
-
-		conn, err = dialWithOptions(network, address)
-		if err == nil {
-			break
+		luaConnHandler, err = scripting.NewConnHandler(connectLuaExec, config)
+		if err != nil {
+			return fmt.Errorf("failed to load Lua handler: %w", err)
 		}
+	}
 
-		if attempt == retryCount {
-			return fmt.Errorf("failed to connect to %s after %d attempts: %v", address, retryCount+1, err)
-		}
-		logger.Warn("Connection attempt %d failed: %v", attempt+1, err)
+	// Each attempt carries the proxy and TLS options, and is retried with
+	// jittered backoff behind the circuit breaker
+	dialer := outboundDialer(retryCount, timeout)
+	dialer.SetDialFunc(dialAttempt)
+	conn, err = dialer.DialContext(context.Background(), network, address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", address, dialCause(err))
 	}
 
 	defer func() {
-		if err := conn.Close(); err != nil {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			logger.Error("Error closing connection: %v", err)
 		}
 	}()
@@ -246,6 +258,10 @@ func connect(host, port, shell string) error {
 		log.Printf("Error printing success message: %v", err)
 	}
 
+	if luaConnHandler != nil {
+		return luaConnHandler.Handle(conn)
+	}
+
 	if runtime.GOOS == "windows" {
 		return connectWindows(conn, shell)
 	} else {
@@ -259,40 +275,50 @@ func connect(host, port, shell string) error {
 // proxy if set, and performs TLS handshake when SSL is enabled.
 // It returns the established net.Conn on success or an error on failure.
 func dialWithOptions(network, address string) (net.Conn, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return dialAttempt(ctx, network, address)
+}
+
+// dialAttempt makes a single connection attempt for connect. TCP and UDP
+// race the addresses of the host with Happy Eyeballs, underneath any proxy
+// or TLS.
+func dialAttempt(ctx context.Context, network, address string) (net.Conn, error) {
 	// Handle SCTP separately
 	if strings.Contains(network, "sctp") {
 		return dialSCTP(network, address)
 	}
 
-	var dialer net.Dialer
-	dialer.Timeout = timeout
-
 	// Set source address if specified
+	var localAddr net.Addr
 	if sourceAddress != "" {
-		var localAddr net.Addr
-		var err error
-		if strings.Contains(network, "tcp") {
-			localAddr, err = net.ResolveTCPAddr(network, net.JoinHostPort(sourceAddress, fmt.Sprintf("%d", sourcePort)))
-		} else if strings.Contains(network, "udp") {
-			localAddr, err = net.ResolveUDPAddr(network, net.JoinHostPort(sourceAddress, fmt.Sprintf("%d", sourcePort)))
+		source, err := resolver.Default().ResolveAddr(ctx, network, net.JoinHostPort(sourceAddress, fmt.Sprintf("%d", sourcePort)))
+		if err == nil && strings.Contains(network, "tcp") {
+			localAddr, err = net.ResolveTCPAddr(network, source)
+		} else if err == nil && strings.Contains(network, "udp") {
+			localAddr, err = net.ResolveUDPAddr(network, source)
 		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve local address: %v", err)
 		}
-		dialer.LocalAddr = localAddr
 	}
+	dial := happyEyeballs(timeout, localAddr)
 
 	// Handle proxy
 	if proxyURL != "" {
-		return dialWithProxy(network, address, &dialer)
+		return dialWithProxy(ctx, network, address, dial)
 	}
 
 	// Handle SSL/TLS
 	if useSSL {
-		return dialWithTLS(network, address, &dialer)
+		return dialWithTLS(ctx, network, address, dial)
 	}
 
-	return dialer.Dial(network, address)
+	return dial(ctx, network, address)
 }
 
 // dialSCTP establishes an SCTP connection to the given network and address
@@ -319,7 +345,7 @@ func dialSCTP(netType, address string) (net.Conn, error) {
 	}
 
 	// Dial with timeout
-	conn, err := network.DialSCTPTimeout(netType, laddr, raddr, timeout, nil)
+	conn, err := network.DialSCTPTimeout(netType, laddr, raddr, timeout, sctpConfig)
 	if err != nil {
 		return nil, fmt.Errorf("SCTP dial failed: %w", err)
 	}
@@ -327,7 +353,7 @@ func dialSCTP(netType, address string) (net.Conn, error) {
 	return conn, nil
 }
 
-func dialWithProxy(network, address string, dialer *net.Dialer) (net.Conn, error) {
+func dialWithProxy(ctx context.Context, network, address string, dial network.DialFunc) (net.Conn, error) {
 	proxyParsed, err := url.Parse(proxyURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid proxy URL: %v", err)
@@ -335,22 +361,22 @@ func dialWithProxy(network, address string, dialer *net.Dialer) (net.Conn, error
 
 	switch proxyParsed.Scheme {
 	case "socks5":
-		proxySocks5, err := proxy.SOCKS5("tcp", proxyParsed.Host, nil, dialer)
+		proxySocks5, err := proxy.SOCKS5("tcp", proxyParsed.Host, nil, forwardDialer(dial))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create SOCKS5 proxy: %v", err)
 		}
-		return proxySocks5.Dial(network, address)
+		return proxySocks5.(proxy.ContextDialer).DialContext(ctx, network, address)
 	case "http", "https":
 		// For HTTP proxy, we need to use HTTP CONNECT method
-		return dialWithHTTPProxy(network, address, proxyParsed, dialer)
+		return dialWithHTTPProxy(ctx, network, address, proxyParsed, dial)
 	default:
 		return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyParsed.Scheme)
 	}
 }
 
-func dialWithHTTPProxy(network, address string, proxyURL *url.URL, dialer *net.Dialer) (net.Conn, error) {
+func dialWithHTTPProxy(ctx context.Context, network, address string, proxyURL *url.URL, dial network.DialFunc) (net.Conn, error) {
 	// Connect to proxy
-	proxyConn, err := dialer.Dial(network, proxyURL.Host)
+	proxyConn, err := dial(ctx, network, proxyURL.Host)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to proxy: %v", err)
 	}
@@ -386,12 +412,12 @@ func dialWithHTTPProxy(network, address string, proxyURL *url.URL, dialer *net.D
 	return proxyConn, nil
 }
 
-// dialWithTLS establishes a TLS connection to the given network and address using the provided dialer.
+// dialWithTLS establishes a TLS connection to the given network and address over a connection from dial.
 // It configures TLS with a minimum version of TLS 1.2 and sets InsecureSkipVerify according to verifyCert,
 // optionally loads a CA bundle from caCertFile, and applies persistent flags for server name, cipher suites,
 // and ALPN protocols.
 // It returns a TLS-wrapped net.Conn on success or an error if configuration or handshake fails.
-func dialWithTLS(network, address string, dialer *net.Dialer) (net.Conn, error) {
+func dialWithTLS(ctx context.Context, network, address string, dial network.DialFunc) (net.Conn, error) {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: !verifyCert,
 		MinVersion:         tls.VersionTLS12, // Secure minimum TLS version
@@ -432,7 +458,24 @@ func dialWithTLS(network, address string, dialer *net.Dialer) (net.Conn, error)
 		tlsConfig.NextProtos = protocols
 	}
 
-	return tls.DialWithDialer(dialer, network, address, tlsConfig)
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = host
+	}
+
+	rawConn, err := dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(rawConn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
 // parseCipherSuites converts cipher suite names to IDs
@@ -466,7 +509,6 @@ func parseCipherSuites(ciphers string) []uint16 {
 // handleDataFlowControl implements send-only and recv-only modes
 func handleDataFlowControl(conn net.Conn) error {
 	var outputWriter io.Writer = os.Stdout
-	var inputReader io.Reader = os.Stdin
 
 	// Setup output file if specified
 	if outputFile != "" {
@@ -478,34 +520,21 @@ func handleDataFlowControl(conn net.Conn) error {
 		outputWriter = file
 	}
 
-	// Setup hex dump file if specified
-	if hexDumpFile != "" {
-		hexFile, err := openOutputFile(hexDumpFile, appendOutput)
-		if err != nil {
-			return fmt.Errorf("failed to open hex dump file: %v", err)
-		}
-		defer hexFile.Close()
-		outputWriter = &hexDumper{writer: hexFile, original: outputWriter}
-	}
-
+	engine := newRelay()
+	mode := "send-only"
 	if sendOnly {
 		logger.Debug("Send-only mode: copying stdin to connection")
-		_, err := io.Copy(conn, inputReader)
-		if err != nil && !noShutdown {
-			return fmt.Errorf("send-only copy error: %v", err)
-		}
-		return nil
-	}
-
-	if recvOnly {
+		engine.Mode = relay.ModeForward
+	} else {
 		logger.Debug("Recv-only mode: copying connection to stdout")
-		_, err := io.Copy(outputWriter, conn)
-		if err != nil {
-			return fmt.Errorf("recv-only copy error: %v", err)
-		}
-		return nil
+		engine.Mode = relay.ModeReverse
+		mode = "recv-only"
 	}
 
+	_, err := runRelayContext(context.Background(), engine, relay.Stdio(os.Stdin, outputWriter), relay.Conn(conn))
+	if err != nil && !(sendOnly && noShutdown) {
+		return fmt.Errorf("%s copy error: %v", mode, err)
+	}
 	return nil
 }
 
@@ -520,122 +549,28 @@ func openOutputFile(filename string, append bool) (*os.File, error) {
 	return os.OpenFile(filename, flags, 0644)
 }
 
-// hexDumper implements hex dump output
-type hexDumper struct {
-	writer   io.Writer
-	original io.Writer
-	offset   int64
-}
-
-func (h *hexDumper) Write(p []byte) (n int, err error) {
-	// Write to original output if exists
-	if h.original != nil {
-		if _, err := h.original.Write(p); err != nil {
-			return 0, fmt.Errorf("failed to write to original output: %v", err)
-		}
-	}
-
-	// Write hex dump
-	for i := 0; i < len(p); i += 16 {
-		end := i + 16
-		if end > len(p) {
-			end = len(p)
-		}
-
-		// Write offset
-		fmt.Fprintf(h.writer, "%08x  ", h.offset+int64(i))
-
-		// Write hex bytes
-		for j := i; j < end; j++ {
-			fmt.Fprintf(h.writer, "%02x ", p[j])
-		}
-
-		// Pad if necessary
-		for j := end; j < i+16; j++ {
-			fmt.Fprintf(h.writer, "   ")
-		}
-
-		// Write ASCII representation
-		fmt.Fprintf(h.writer, " |")
-		for j := i; j < end; j++ {
-			if p[j] >= 32 && p[j] <= 126 {
-				fmt.Fprintf(h.writer, "%c", p[j])
-			} else {
-				fmt.Fprintf(h.writer, ".")
-			}
-		}
-		fmt.Fprintf(h.writer, "|\n")
-	}
-
-	h.offset += int64(len(p))
-	return len(p), nil
-}
-
 func connectWindows(conn net.Conn, shell string) error {
 	// Handle data flow control modes
 	if sendOnly || recvOnly {
 		return handleDataFlowControl(conn)
 	}
 
-	// Create shell command
-	cmd := exec.Command(shell)
-
-	// Get pipes for stdin, stdout, stderr
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %v", err)
-	}
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %v", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %v", err)
-	}
-
 	// Start the shell
-	if err := cmd.Start(); err != nil {
+	proc, err := process.Start(execConfig([]string{shell}))
+	if err != nil {
 		return fmt.Errorf("failed to start shell: %v", err)
 	}
 
-	// Copy data between connection and shell pipes with output handling
-	var outputWriter io.Writer = conn
-	if outputFile != "" || hexDumpFile != "" {
-		outputWriter = createOutputWriter(conn)
+	// Shell output goes to the output file instead of the connection if one
+	// is given
+	var peer relay.Endpoint = relay.Conn(conn)
+	if outputFile != "" {
+		peer = relay.Stdio(conn, createOutputWriter(conn))
 	}
-
-	go func() {
-		if !recvOnly {
-			if _, err := io.Copy(stdin, conn); err != nil {
-				logger.Error("conn to stdin copy error: %v", err)
-			}
-		}
-		if err := stdin.Close(); err != nil {
-			logger.Error("Error closing stdin: %v", err)
-		}
-	}()
-
-	go func() {
-		if !sendOnly {
-			if _, err := io.Copy(outputWriter, stdout); err != nil {
-				logger.Error("stdout to conn copy error: %v", err)
-			}
-		}
-	}()
-
-	go func() {
-		if !sendOnly {
-			if _, err := io.Copy(outputWriter, stderr); err != nil {
-				logger.Error("stderr to conn copy error: %v", err)
-			}
-		}
-	}()
+	relayEndpoints(peer, proc)
 
 	// Wait for the shell to exit
-	if err := cmd.Wait(); err != nil {
+	if err := proc.Wait(); err != nil {
 		logger.Warn("Shell exited with error: %v", err)
 	} else {
 		logger.Warn("Shell exited")
@@ -657,14 +592,5 @@ func createOutputWriter(defaultWriter io.Writer) io.Writer {
 		writer = file
 	}
 
-	if hexDumpFile != "" {
-		hexFile, err := openOutputFile(hexDumpFile, appendOutput)
-		if err != nil {
-			logger.Error("Failed to open hex dump file: %v", err)
-			return writer
-		}
-		writer = &hexDumper{writer: hexFile, original: writer}
-	}
-
 	return writer
 }
\ No newline at end of file
@@ -28,6 +28,11 @@ func connectUnix(conn net.Conn, shell string) error {
 		return handleDataFlowControl(conn)
 	}
 
+	// The exec flags need the shell to run as a managed process
+	if cfg := execConfig(nil); cfg.PTY || cfg.ReportExit || cfg.ClearEnv || cfg.Dir != "" || len(cfg.Env) > 0 {
+		return connectUnixPipes(conn, shell)
+	}
+
 	// Get the file descriptor from the connection
 	var fd int
 	switch c := conn.(type) {
@@ -90,32 +95,18 @@ func connectUnix(conn net.Conn, shell string) error {
 	return nil
 }
 
-// Fallback method using pipes for connection types that don't support File()
+// Fallback method relaying the shell through the exec subsystem, for
+// connection types that don't support File() and for the exec flags
 func connectUnixPipes(conn net.Conn, shell string) error {
 	// Handle data flow control modes
 	if sendOnly || recvOnly {
 		return handleDataFlowControl(conn)
 	}
 
-	// Create shell command
-	cmd := exec.Command(shell, "-i")
-
-	// Redirect stdin, stdout, stderr to the connection
-	cmd.Stdin = conn
-	cmd.Stdout = conn
-	cmd.Stderr = conn
-
-	// Start the shell
-	if err := cmd.Start(); err != nil {
+	if _, err := runExec(conn, execConfig([]string{shell, "-i"})); err != nil {
 		return fmt.Errorf("failed to start shell: %v", err)
 	}
-
-	// Wait for the shell to exit
-	if err := cmd.Wait(); err != nil {
-		logger.Warn("Shell exited with error: %v", err)
-	} else {
-		logger.Info("Shell exited normally")
-	}
+	logger.Info("Shell exited")
 
 	return nil
 }
\ No newline at end of file
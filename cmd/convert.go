@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"io"
 	"net"
 	"net/http"
@@ -12,7 +13,9 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/ibrahmsql/gocat/internal/buffer"
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/relay"
 	wsconv "github.com/ibrahmsql/gocat/internal/websocket"
 	"github.com/spf13/cobra"
 )
@@ -42,6 +45,9 @@ Examples:
 
   # WebSocket to TCP
   gocat convert --from ws:8080 --to tcp:backend:9000
+
+  # TCP to TCP with 4 connections to the backend kept open ahead of clients
+  gocat convert --from tcp:8080 --to tcp:backend:9000 --pool-warm 4
 `,
 	Run: runConvert,
 }
@@ -57,6 +63,7 @@ func init() {
 	convertCmd.Flags().StringVar(&convertFrom, "from", "", "Source protocol and address (e.g., tcp:8080, udp:8080, http:8080)")
 	convertCmd.Flags().StringVar(&convertTo, "to", "", "Target protocol and address (e.g., tcp:host:9000, udp:host:9000)")
 	convertCmd.Flags().IntVar(&convertBuffer, "buffer", 8192, "Buffer size for data transfer")
+	addUpstreamFlags(convertCmd)
 
 	convertCmd.MarkFlagRequired("from")
 	convertCmd.MarkFlagRequired("to")
@@ -70,6 +77,12 @@ func runConvert(cmd *cobra.Command, args []string) {
 
 	logger.Info("Starting protocol converter: %s:%s -> %s:%s", fromProto, fromAddr, toProto, toAddr)
 
+	// TCP, HTTP and WebSocket sources apply the access policy and are listed
+	// as sessions on the admin socket
+	loadConnectionPolicy(cmd)
+	startAdminSocket(cmd, nil)
+	startMetricsExporter(cmd)
+
 	switch fromProto {
 	case "tcp":
 		switch toProto {
@@ -134,7 +147,7 @@ func parseProtocolAddress(addr string) (string, string) {
 // connection and the UDP address udpAddr via handleTCPToUDP.
 // It logs a fatal error and exits if it cannot start listening on tcpAddr.
 func tcpToUDP(tcpAddr, udpAddr string) {
-	listener, err := net.Listen("tcp", tcpAddr)
+	listener, err := listenGuarded(tcpAddr)
 	if err != nil {
 		logger.Fatal("Failed to listen on TCP %s: %v", tcpAddr, err)
 	}
@@ -149,21 +162,21 @@ func tcpToUDP(tcpAddr, udpAddr string) {
 			continue
 		}
 
-		go handleTCPToUDP(conn, udpAddr)
+		serveConn(conn, func(c net.Conn) { handleTCPToUDP(c, udpAddr) })
 	}
 }
 
 // handleTCPToUDP bridges data between a TCP connection and a remote UDP address.
 //
 // It forwards bytes read from the TCP connection to the UDP address and forwards
-// packets read from the UDP connection back to the TCP connection until either
-// side closes or an I/O error occurs. The TCP connection is closed when the
+// packets read from the UDP connection back to the TCP connection until the TCP
+// peer is done or an I/O error occurs. The TCP connection is closed when the
 // function returns; the UDP connection is created for the duration of the
 // function. Errors encountered while reading or writing are logged.
 func handleTCPToUDP(tcpConn net.Conn, udpAddr string) {
 	defer tcpConn.Close()
 
-	udpConn, err := net.Dial("udp", udpAddr)
+	udpConn, err := dialOut("udp", udpAddr)
 	if err != nil {
 		logger.Error("Failed to connect to UDP %s: %v", udpAddr, err)
 		return
@@ -172,42 +185,15 @@ func handleTCPToUDP(tcpConn net.Conn, udpAddr string) {
 
 	logger.Debug("TCP->UDP: %s -> %s", tcpConn.RemoteAddr(), udpAddr)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// TCP to UDP
-	go func() {
-		defer wg.Done()
-		buf := make([]byte, convertBuffer)
-		for {
-			n, err := tcpConn.Read(buf)
-			if err != nil {
-				return
-			}
-			if _, err := udpConn.Write(buf[:n]); err != nil {
-				logger.Error("UDP write error: %v", err)
-				return
-			}
-		}
-	}()
-
-	// UDP to TCP
-	go func() {
-		defer wg.Done()
-		buf := make([]byte, convertBuffer)
-		for {
-			n, err := udpConn.Read(buf)
-			if err != nil {
-				return
-			}
-			if _, err := tcpConn.Write(buf[:n]); err != nil {
-				logger.Error("TCP write error: %v", err)
-				return
-			}
-		}
-	}()
+	convertRelay(relay.Conn(tcpConn), relay.Conn(udpConn))
+}
 
-	wg.Wait()
+// convertRelay relays a and b with reads of at most --buffer bytes, which
+// also bounds the datagrams sent to UDP targets
+func convertRelay(a, b relay.Endpoint) relay.Stats {
+	engine := newRelay()
+	engine.BufferSize = convertBuffer
+	return runRelay(engine, a, b)
 }
 
 // udpToTCP listens for UDP packets on udpAddr and forwards each UDP client's datagrams
@@ -230,9 +216,10 @@ func udpToTCP(udpAddr, tcpAddr string) {
 	clients := make(map[string]net.Conn)
 	var mu sync.Mutex
 
-	buf := make([]byte, convertBuffer)
+	buf := buffer.Shared().Get(convertBuffer)
+	defer buf.Release()
 	for {
-		n, clientAddr, err := udpConn.ReadFrom(buf)
+		n, clientAddr, err := udpConn.ReadFrom(buf.Data)
 		if err != nil {
 			logger.Error("UDP read error: %v", err)
 			continue
@@ -243,7 +230,7 @@ func udpToTCP(udpAddr, tcpAddr string) {
 		mu.Lock()
 		tcpConn, exists := clients[clientKey]
 		if !exists {
-			tcpConn, err = net.Dial("tcp", tcpAddr)
+			tcpConn, err = dialOut("tcp", tcpAddr)
 			if err != nil {
 				logger.Error("Failed to connect to TCP %s: %v", tcpAddr, err)
 				mu.Unlock()
@@ -260,35 +247,38 @@ func udpToTCP(udpAddr, tcpAddr string) {
 					mu.Unlock()
 				}()
 
-				respBuf := make([]byte, convertBuffer)
+				respBuf := buffer.Shared().Get(convertBuffer)
+				defer respBuf.Release()
 				for {
-					n, err := conn.Read(respBuf)
+					n, err := conn.Read(respBuf.Data)
 					if err != nil {
 						return
 					}
-					udpConn.WriteTo(respBuf[:n], addr)
+					udpConn.WriteTo(respBuf.Data[:n], addr)
 				}
 			}(tcpConn, clientAddr)
 		}
 		mu.Unlock()
 
-		if _, err := tcpConn.Write(buf[:n]); err != nil {
+		if _, err := tcpConn.Write(buf.Data[:n]); err != nil {
 			logger.Error("TCP write error: %v", err)
 		}
 	}
 }
 
 // tcpToTCP starts a TCP proxy that listens on listenAddr and forwards each incoming connection to targetAddr.
-// For each accepted client it dials the target and copies data bidirectionally between the client and target until either side closes.
+// For each accepted client it takes a connection to the target from the upstream pool, warm when --pool-warm
+// keeps some open, and relays data between the client and target until both sides are done.
 // It logs the listening state, calls logger.Fatal if the initial listen fails, and logs accept/connect/runtime errors.
 func tcpToTCP(listenAddr, targetAddr string) {
-	listener, err := net.Listen("tcp", listenAddr)
+	listener, err := listenGuarded(listenAddr)
 	if err != nil {
 		logger.Fatal("Failed to listen on TCP %s: %v", listenAddr, err)
 	}
 	defer listener.Close()
 
 	logger.Info("TCP->TCP proxy listening on %s, forwarding to %s", listenAddr, targetAddr)
+	upstreams().Warm(targetAddr)
 
 	for {
 		conn, err := listener.Accept()
@@ -297,31 +287,24 @@ func tcpToTCP(listenAddr, targetAddr string) {
 			continue
 		}
 
-		go func(c net.Conn) {
+		serveConn(conn, func(c net.Conn) {
 			defer c.Close()
 
-			target, err := net.Dial("tcp", targetAddr)
+			// The target connection carried a whole session, so it is
+			// closed rather than reused
+			target, err := upstreams().Get(context.Background(), targetAddr)
 			if err != nil {
-				logger.Error("Failed to connect to %s: %v", targetAddr, err)
+				logger.Error("Failed to connect to %s: %v", targetAddr, dialCause(err))
 				return
 			}
 			defer target.Close()
+			if err := sendProxyHeader(target, c.RemoteAddr(), c.LocalAddr()); err != nil {
+				logger.Error("Failed to send PROXY header to %s: %v", targetAddr, err)
+				return
+			}
 
-			var wg sync.WaitGroup
-			wg.Add(2)
-
-			go func() {
-				defer wg.Done()
-				io.Copy(target, c)
-			}()
-
-			go func() {
-				defer wg.Done()
-				io.Copy(c, target)
-			}()
-
-			wg.Wait()
-		}(conn)
+			relayConns(c, target)
+		})
 	}
 }
 
@@ -330,12 +313,6 @@ func tcpToTCP(listenAddr, targetAddr string) {
 func udpToUDP(listenAddr, targetAddr string) {
 	logger.Info("UDP->UDP proxy listening on %s, forwarding to %s", listenAddr, targetAddr)
 
-	// Resolve target address
-	targetUDPAddr, err := net.ResolveUDPAddr("udp", targetAddr)
-	if err != nil {
-		logger.Fatal("Failed to resolve target UDP address %s: %v", targetAddr, err)
-	}
-
 	// Listen on UDP
 	listenUDPAddr, err := net.ResolveUDPAddr("udp", listenAddr)
 	if err != nil {
@@ -353,7 +330,7 @@ func udpToUDP(listenAddr, targetAddr string) {
 	// Map to track client connections
 	type clientInfo struct {
 		addr       *net.UDPAddr
-		targetConn *net.UDPConn
+		targetConn net.Conn
 		lastSeen   time.Time
 	}
 	
@@ -379,9 +356,10 @@ func udpToUDP(listenAddr, targetAddr string) {
 		}
 	}()
 
-	buffer := make([]byte, 65535)
+	buf := buffer.Shared().Get(65535)
+	defer buf.Release()
 	for {
-		n, clientAddr, err := udpConn.ReadFromUDP(buffer)
+		n, clientAddr, err := udpConn.ReadFromUDP(buf.Data)
 		if err != nil {
 			logger.Error("UDP read error: %v", err)
 			continue
@@ -393,7 +371,7 @@ func udpToUDP(listenAddr, targetAddr string) {
 		client, exists := clients[clientKey]
 		if !exists {
 			// Create new connection to target for this client
-			targetConn, err := net.DialUDP("udp", nil, targetUDPAddr)
+			targetConn, err := dialOut("udp", targetAddr)
 			if err != nil {
 				logger.Error("Failed to dial target UDP: %v", err)
 				clientsMu.Unlock()
@@ -409,9 +387,10 @@ func udpToUDP(listenAddr, targetAddr string) {
 
 			// Start goroutine to read responses from target
 			go func(c *clientInfo) {
-				respBuffer := make([]byte, 65535)
+				respBuf := buffer.Shared().Get(65535)
+				defer respBuf.Release()
 				for {
-					n, err := c.targetConn.Read(respBuffer)
+					n, err := c.targetConn.Read(respBuf.Data)
 					if err != nil {
 						if !isClosedError(err) {
 							logger.Error("Target UDP read error: %v", err)
@@ -420,7 +399,7 @@ func udpToUDP(listenAddr, targetAddr string) {
 					}
 
 					// Send response back to original client
-					if _, err := udpConn.WriteToUDP(respBuffer[:n], c.addr); err != nil {
+					if _, err := udpConn.WriteToUDP(respBuf.Data[:n], c.addr); err != nil {
 						logger.Error("Failed to write response to client: %v", err)
 						return
 					}
@@ -438,7 +417,7 @@ func udpToUDP(listenAddr, targetAddr string) {
 		clientsMu.Unlock()
 
 		// Forward packet to target
-		if _, err := client.targetConn.Write(buffer[:n]); err != nil {
+		if _, err := client.targetConn.Write(buf.Data[:n]); err != nil {
 			logger.Error("Failed to forward UDP packet: %v", err)
 			continue
 		}
@@ -458,7 +437,7 @@ func isClosedError(err error) bool {
 // wsURL is the target WebSocket URL (for example "ws://host:port/path").
 // For each incoming TCP connection the function delegates forwarding to handleTCPToWebSocket and continues accepting new connections.
 func tcpToWebSocket(tcpAddr, wsURL string) {
-	listener, err := net.Listen("tcp", tcpAddr)
+	listener, err := listenGuarded(tcpAddr)
 	if err != nil {
 		logger.Fatal("Failed to listen on TCP %s: %v", tcpAddr, err)
 	}
@@ -473,7 +452,7 @@ func tcpToWebSocket(tcpAddr, wsURL string) {
 			continue
 		}
 
-		go handleTCPToWebSocket(conn, wsURL)
+		serveConn(conn, func(c net.Conn) { handleTCPToWebSocket(c, wsURL) })
 	}
 }
 
@@ -484,7 +463,7 @@ func tcpToWebSocket(tcpAddr, wsURL string) {
 func handleTCPToWebSocket(tcpConn net.Conn, wsURL string) {
 	defer tcpConn.Close()
 
-	wsConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	wsConn, _, err := websocketDialer().Dial(wsURL, nil)
 	if err != nil {
 		logger.Error("Failed to connect to WebSocket %s: %v", wsURL, err)
 		return
@@ -493,41 +472,7 @@ func handleTCPToWebSocket(tcpConn net.Conn, wsURL string) {
 
 	logger.Debug("TCP->WebSocket: %s -> %s", tcpConn.RemoteAddr(), wsURL)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// TCP to WebSocket
-	go func() {
-		defer wg.Done()
-		buf := make([]byte, convertBuffer)
-		for {
-			n, err := tcpConn.Read(buf)
-			if err != nil {
-				return
-			}
-			if err := wsConn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
-				logger.Error("WebSocket write error: %v", err)
-				return
-			}
-		}
-	}()
-
-	// WebSocket to TCP
-	go func() {
-		defer wg.Done()
-		for {
-			_, message, err := wsConn.ReadMessage()
-			if err != nil {
-				return
-			}
-			if _, err := tcpConn.Write(message); err != nil {
-				logger.Error("TCP write error: %v", err)
-				return
-			}
-		}
-	}()
-
-	wg.Wait()
+	convertRelay(relay.Conn(tcpConn), relay.WebSocket(wsConn))
 }
 
 // The function blocks serving requests and logs fatal on server errors. It returns after ListenAndServe fails.
@@ -544,49 +489,23 @@ func httpToWebSocket(httpAddr, wsURL string) {
 		}
 		defer clientWS.Close()
 
-		backendWS, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		backendWS, _, err := websocketDialer().Dial(wsURL, nil)
 		if err != nil {
 			logger.Error("Failed to connect to backend WebSocket: %v", err)
 			return
 		}
 		defer backendWS.Close()
 
-		var wg sync.WaitGroup
-		wg.Add(2)
-
-		// Client to backend
-		go func() {
-			defer wg.Done()
-			for {
-				msgType, message, err := clientWS.ReadMessage()
-				if err != nil {
-					return
-				}
-				if err := backendWS.WriteMessage(msgType, message); err != nil {
-					return
-				}
-			}
-		}()
-
-		// Backend to client
-		go func() {
-			defer wg.Done()
-			for {
-				msgType, message, err := backendWS.ReadMessage()
-				if err != nil {
-					return
-				}
-				if err := clientWS.WriteMessage(msgType, message); err != nil {
-					return
-				}
-			}
-		}()
-
-		wg.Wait()
+		client, backend := relay.WebSocketBridge(clientWS, backendWS)
+		convertRelay(client, backend)
 	})
 
 	logger.Info("HTTP->WebSocket converter listening on %s", httpAddr)
-	if err := http.ListenAndServe(httpAddr, nil); err != nil {
+	listener, err := listenGuarded(httpAddr)
+	if err != nil {
+		logger.Fatal("Failed to listen on %s: %v", httpAddr, err)
+	}
+	if err := http.Serve(listener, nil); err != nil {
 		logger.Fatal("HTTP server error: %v", err)
 	}
 }
@@ -607,50 +526,27 @@ func webSocketToTCP(wsAddr, tcpAddr string) {
 		}
 		defer wsConn.Close()
 
-		tcpConn, err := net.Dial("tcp", tcpAddr)
+		tcpConn, err := dialOut("tcp", tcpAddr)
 		if err != nil {
 			logger.Error("Failed to connect to TCP %s: %v", tcpAddr, err)
 			return
 		}
 		defer tcpConn.Close()
+		source, destination := requestAddrs(r)
+		if err := sendProxyHeader(tcpConn, source, destination); err != nil {
+			logger.Error("Failed to send PROXY header to %s: %v", tcpAddr, err)
+			return
+		}
 
-		var wg sync.WaitGroup
-		wg.Add(2)
-
-		// WebSocket to TCP
-		go func() {
-			defer wg.Done()
-			for {
-				_, message, err := wsConn.ReadMessage()
-				if err != nil {
-					return
-				}
-				if _, err := tcpConn.Write(message); err != nil {
-					return
-				}
-			}
-		}()
-
-		// TCP to WebSocket
-		go func() {
-			defer wg.Done()
-			buf := make([]byte, convertBuffer)
-			for {
-				n, err := tcpConn.Read(buf)
-				if err != nil {
-					return
-				}
-				if err := wsConn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
-					return
-				}
-			}
-		}()
-
-		wg.Wait()
+		convertRelay(relay.WebSocket(wsConn), relay.Conn(tcpConn))
 	})
 
 	logger.Info("WebSocket->TCP converter listening on %s", wsAddr)
-	if err := http.ListenAndServe(wsAddr, nil); err != nil {
+	listener, err := listenGuarded(wsAddr)
+	if err != nil {
+		logger.Fatal("Failed to listen on %s: %v", wsAddr, err)
+	}
+	if err := http.Serve(listener, nil); err != nil {
 		logger.Fatal("HTTP server error: %v", err)
 	}
 }
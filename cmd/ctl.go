@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ctlSocket  string
+	ctlTimeout time.Duration
+	ctlRaw     bool
+)
+
+// ctlCmd calls the admin API of a running gocat
+var ctlCmd = &cobra.Command{
+	Use:   "ctl [method] [params]",
+	Short: "Control a running gocat server over its admin socket",
+	Long: `Call a method on the admin API of a gocat command started with --admin-socket
+and print the JSON result. Without a method the available methods are listed.
+
+Params are either one JSON object or key=value pairs. Values that parse as
+JSON (numbers, booleans, arrays) are sent as such, anything else as a string.
+
+Every server supports status, log.level, policy.get, policy.reload,
+sessions.list, sessions.kill and pools. proxy adds backends.*, tunnel adds
+forwards.*, and broker and chat add sessions.mute and events.
+
+Examples:
+  # Start a proxy with an admin socket
+  gocat proxy --target http://app:80 --admin-socket /tmp/gocat.sock
+
+  # List methods, sessions and backends
+  gocat ctl --socket /tmp/gocat.sock
+  gocat ctl --socket /tmp/gocat.sock sessions.list
+  gocat ctl --socket /tmp/gocat.sock backends.add url=http://app2:80
+
+  # Kill a connection, switch to debug logging, tighten the ACL
+  gocat ctl --socket /tmp/gocat.sock sessions.kill id=s3
+  gocat ctl --socket /tmp/gocat.sock log.level level=debug
+  gocat ctl --socket /tmp/gocat.sock policy.reload '{"allow": ["10.0.0.0/8"], "rate_limit": "1MB/s"}'`,
+	Args: cobra.MaximumNArgs(64),
+	RunE: runCtl,
+}
+
+func init() {
+	rootCmd.AddCommand(ctlCmd)
+
+	ctlCmd.Flags().StringVarP(&ctlSocket, "socket", "S", "", "Admin socket path (default: --admin-socket or $GOCAT_ADMIN_SOCKET)")
+	ctlCmd.Flags().DurationVar(&ctlTimeout, "timeout", 5*time.Second, "Connect and call timeout")
+	ctlCmd.Flags().BoolVar(&ctlRaw, "raw", false, "Print compact JSON")
+}
+
+func runCtl(cmd *cobra.Command, args []string) error {
+	socket := ctlSocket
+	if socket == "" {
+		socket, _ = cmd.Root().PersistentFlags().GetString("admin-socket")
+	}
+	if socket == "" {
+		socket = os.Getenv("GOCAT_ADMIN_SOCKET")
+	}
+	if socket == "" {
+		return fmt.Errorf("no admin socket given (use --socket)")
+	}
+
+	client, err := admin.Dial(socket, ctlTimeout)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if len(args) == 0 {
+		var methods []admin.MethodInfo
+		if err := client.Call("rpc.methods", nil, &methods); err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, m := range methods {
+			fmt.Fprintf(w, "%s\t%s\n", m.Name, m.Help)
+		}
+		return w.Flush()
+	}
+
+	params, err := parseCtlParams(args[1:])
+	if err != nil {
+		return err
+	}
+
+	var result json.RawMessage
+	if err := client.Call(args[0], params, &result); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if ctlRaw {
+		err = json.Compact(&out, result)
+	} else {
+		err = json.Indent(&out, result, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
+
+// parseCtlParams turns the ctl arguments after the method into params: a
+// single JSON argument is sent as is, key=value pairs become an object
+func parseCtlParams(args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if len(args) == 1 && json.Valid([]byte(args[0])) && strings.ContainsAny(args[0][:1], "{[") {
+		return json.RawMessage(args[0]), nil
+	}
+
+	params := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid param %q (expected key=value or a JSON object)", arg)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+			params[key] = decoded
+		} else {
+			params[key] = value
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+)
+
+// outboundDialer returns the stack outbound connections go through: each
+// attempt dials with Happy Eyeballs, failed attempts are retried with
+// jittered exponential backoff, and a circuit breaker per address stops
+// hammering targets that keep failing. Commands replace the attempt with
+// SetDialFunc when they layer proxies or TLS on top.
+func outboundDialer(retries int, dialTimeout time.Duration) *network.Dialer {
+	config := network.DefaultDialerConfig()
+	config.MaxRetries = retries
+	if dialTimeout > 0 {
+		config.ConnectionTimeout = dialTimeout
+	}
+	config.OnRetry = func(attempt int, delay time.Duration, err error) {
+		logger.Warn("Connection attempt %d failed: %v", attempt, dialCause(err))
+		logger.Info("Retrying connection (attempt %d/%d) in %v", attempt+1, retries+1, delay.Round(time.Millisecond))
+	}
+	config.OnCircuitChange = func(address string, from, to network.CircuitBreakerState) {
+		switch to {
+		case network.CircuitBreakerOpen:
+			logger.Warn("Circuit breaker for %s is open, failing connections fast for %v", address, config.CircuitBreakerTimeout)
+		case network.CircuitBreakerClosed:
+			logger.Info("Circuit breaker for %s is closed again", address)
+		}
+	}
+	return network.NewDialer(config)
+}
+
+// relayDialer connects the commands relaying traffic to their targets. It
+// does not retry, as a client is waiting, but shares the circuit breakers
+// so targets that keep refusing are spared.
+var relayDialer = outboundDialer(0, 0)
+
+// dialOut connects to the target of relayed traffic through relayDialer
+func dialOut(network, address string) (net.Conn, error) {
+	conn, err := relayDialer.DialContext(context.Background(), network, address)
+	if err != nil {
+		return nil, dialCause(err)
+	}
+	return conn, nil
+}
+
+// websocketDialer returns a WebSocket dialer connecting through relayDialer
+func websocketDialer() *websocket.Dialer {
+	d := *websocket.DefaultDialer
+	d.NetDialContext = relayDialer.DialContext
+	return &d
+}
+
+// happyEyeballs returns a single attempt racing the addresses of a host,
+// bound to local when it is set
+func happyEyeballs(dialTimeout time.Duration, local net.Addr) network.DialFunc {
+	d := network.NewDualStackDialer(nil)
+	d.SetBaseDialer(&net.Dialer{Timeout: dialTimeout, LocalAddr: local})
+	return d.DialContext
+}
+
+// dialCause returns the error beneath the dialer's wrapping, which names
+// the address but not what went wrong
+func dialCause(err error) error {
+	for {
+		c, ok := err.(interface{ Cause() error })
+		if !ok || c.Cause() == nil {
+			return err
+		}
+		err = c.Cause()
+	}
+}
+
+// forwardDialer lets a dial attempt carry connections through a proxy
+type forwardDialer network.DialFunc
+
+// Dial implements proxy.Dialer
+func (f forwardDialer) Dial(network, address string) (net.Conn, error) {
+	return f(context.Background(), network, address)
+}
+
+// DialContext implements proxy.ContextDialer
+func (f forwardDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// listenTCP listens on a TCP address. Wildcard and empty hosts on plain
+// "tcp" bind IPv4 and IPv6 on sockets of their own, so neither family
+// depends on the kernel mapping it onto the other.
+func listenTCP(netType, address string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || netType != "tcp" || (host != "" && !net.ParseIP(host).IsUnspecified()) {
+		return net.Listen(netType, address)
+	}
+
+	ln := network.NewDualStackListener(nil)
+	if err := ln.Listen(address); err != nil {
+		return nil, err
+	}
+	logger.Debug("Listening dual-stack on %v", ln.GetAddresses())
+	return ln, nil
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/dns"
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var (
+	dnsQueryTCP       bool
+	dnsQueryNoEDNS    bool
+	dnsQueryBufSize   uint16
+	dnsQueryNoRecurse bool
+	dnsQueryShort     bool
+	dnsQueryTimeout   time.Duration
+
+	dnsServeZone     string
+	dnsServeListen   string
+	dnsServeDelay    time.Duration
+	dnsServeFail     string
+	dnsServeFailRate float64
+	dnsServeDropRate float64
+	dnsServeTruncate bool
+)
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Query DNS servers and run a throwaway authoritative server",
+}
+
+var dnsQueryCmd = &cobra.Command{
+	Use:   "query <name> [type] [@server]",
+	Short: "Query a DNS server, like dig",
+	Long: `Send a query to a DNS server and print the response the way dig does.
+The type defaults to A, or PTR when the name is an IP address, which is then
+queried by its reverse name. Without @server the first nameserver of
+/etc/resolv.conf is asked.
+
+Queries go over UDP with an EDNS0 payload size of 1232 and are retried over
+TCP when the answer comes back truncated.
+
+Supported types: A, AAAA, CNAME, MX, NS, PTR, SOA, SRV, TXT, ANY and TYPEn.
+
+Examples:
+  gocat dns query example.com
+  gocat dns query example.com MX @1.1.1.1
+  gocat dns query _sip._udp.example.com SRV @127.0.0.1:5300 --tcp
+  gocat dns query 192.0.2.10 @127.0.0.1:5300 --short`,
+	Args: cobra.RangeArgs(1, 3),
+	RunE: runDNSQuery,
+}
+
+var dnsServeCmd = &cobra.Command{
+	Use:   "serve --zone <file>",
+	Short: "Answer queries authoritatively from a zone file",
+	Long: `Serve the records of a zone file over UDP and TCP. Files ending in .yaml or
+.yml are read as YAML, anything else as a standard zone file with $ORIGIN,
+$TTL and relative names. Wildcard owners such as *.dyn answer for any name
+below them that has no records of its own.
+
+Faults can be injected per name in YAML zones, and for every query with the
+flags below, to test how clients cope with slow, failing or silent servers.
+
+YAML zone:
+  origin: example.test.
+  ttl: 300
+  records:
+    - {name: www, type: A, data: 192.0.2.10}
+    - {name: "*.dyn", type: A, data: 192.0.2.99}
+    - {name: "@", type: MX, data: 10 mail}
+  faults:
+    - {name: slow, delay: 2s}
+    - {name: flaky, rcode: SERVFAIL, rate: 0.5}
+    - {name: gone, drop: true}
+
+Examples:
+  gocat dns serve --zone example.test.zone --listen 127.0.0.1:5300
+  gocat dns serve --zone zone.yaml --listen :5300 --delay 100ms --fail SERVFAIL --fail-rate 0.1`,
+	Args: cobra.NoArgs,
+	RunE: runDNSServe,
+}
+
+func init() {
+	rootCmd.AddCommand(dnsCmd)
+	dnsCmd.AddCommand(dnsQueryCmd)
+	dnsCmd.AddCommand(dnsServeCmd)
+
+	dnsQueryCmd.Flags().BoolVar(&dnsQueryTCP, "tcp", false, "Query over TCP instead of UDP")
+	dnsQueryCmd.Flags().BoolVar(&dnsQueryNoEDNS, "no-edns", false, "Send the query without an EDNS0 OPT record")
+	dnsQueryCmd.Flags().Uint16Var(&dnsQueryBufSize, "bufsize", dns.DefaultUDPSize, "EDNS0 UDP payload size to advertise")
+	dnsQueryCmd.Flags().BoolVar(&dnsQueryNoRecurse, "norecurse", false, "Clear the recursion desired flag")
+	dnsQueryCmd.Flags().BoolVar(&dnsQueryShort, "short", false, "Print only the data of the answers")
+	dnsQueryCmd.Flags().DurationVar(&dnsQueryTimeout, "timeout", dns.DefaultTimeout, "How long to wait for the response")
+
+	dnsServeCmd.Flags().StringVar(&dnsServeZone, "zone", "", "Zone file to serve (zone file format, or YAML)")
+	dnsServeCmd.Flags().StringVar(&dnsServeListen, "listen", ":53", "Address to answer on over UDP and TCP")
+	dnsServeCmd.Flags().DurationVar(&dnsServeDelay, "delay", 0, "Delay every response")
+	dnsServeCmd.Flags().StringVar(&dnsServeFail, "fail", "", "Answer with this response code (SERVFAIL, REFUSED, NXDOMAIN...)")
+	dnsServeCmd.Flags().Float64Var(&dnsServeFailRate, "fail-rate", 0, "Fraction of queries --fail applies to (default: all)")
+	dnsServeCmd.Flags().Float64Var(&dnsServeDropRate, "drop-rate", 0, "Fraction of queries to leave unanswered")
+	dnsServeCmd.Flags().BoolVar(&dnsServeTruncate, "truncate", false, "Truncate every UDP answer, sending clients to TCP")
+
+	dnsServeCmd.MarkFlagRequired("zone")
+}
+
+// parseDNSQueryArgs sorts the arguments of dns query, in any order like
+// dig takes them, into the name, the type and the server
+func parseDNSQueryArgs(args []string) (string, dnsmessage.Type, string, error) {
+	var name, server string
+	var qtype dnsmessage.Type
+	for _, arg := range args {
+		if s, ok := strings.CutPrefix(arg, "@"); ok {
+			server = s
+			continue
+		}
+		if t, err := dns.ParseType(arg); err == nil && qtype == 0 && name != "" {
+			qtype = t
+			continue
+		}
+		if name != "" {
+			return "", 0, "", fmt.Errorf("unexpected argument %q", arg)
+		}
+		name = arg
+	}
+	if name == "" {
+		return "", 0, "", fmt.Errorf("no name to query")
+	}
+
+	if ip := net.ParseIP(name); ip != nil {
+		name = dns.ReverseName(ip)
+		if qtype == 0 {
+			qtype = dnsmessage.TypePTR
+		}
+	}
+	if qtype == 0 {
+		qtype = dnsmessage.TypeA
+	}
+	if server == "" {
+		server = dns.SystemServer()
+	}
+	return name, qtype, server, nil
+}
+
+func runDNSQuery(cmd *cobra.Command, args []string) error {
+	name, qtype, server, err := parseDNSQueryArgs(args)
+	if err != nil {
+		return err
+	}
+
+	opts := dns.QueryOptions{UDPSize: dnsQueryBufSize, NoRecurse: dnsQueryNoRecurse}
+	if dnsQueryNoEDNS {
+		opts.UDPSize = 0
+	}
+	query, err := dns.NewQuery(name, qtype, opts)
+	if err != nil {
+		return err
+	}
+
+	client := &dns.Client{
+		Timeout: dnsQueryTimeout,
+		TCP:     dnsQueryTCP,
+		Dial:    happyEyeballs(dnsQueryTimeout, nil),
+	}
+	resp, err := client.Exchange(context.Background(), query, server)
+	if err != nil {
+		return fmt.Errorf("query to %s failed: %v", server, dialCause(err))
+	}
+	if resp.Fallback {
+		logger.Debug("Answer truncated over UDP, retried over TCP")
+	}
+
+	if dnsQueryShort {
+		for _, r := range dns.Records(resp.Answers) {
+			fmt.Println(r.Data)
+		}
+		return nil
+	}
+	printDNSResponse(resp)
+	return nil
+}
+
+// printDNSResponse prints a response in the layout of dig
+func printDNSResponse(resp *dns.Response) {
+	h := resp.Header
+	fmt.Printf(";; ->>HEADER<<- opcode: %d, status: %s, id: %d\n", h.OpCode, dns.RCodeString(h.RCode), h.ID)
+
+	var flags []string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{h.Response, "qr"}, {h.Authoritative, "aa"}, {h.Truncated, "tc"},
+		{h.RecursionDesired, "rd"}, {h.RecursionAvailable, "ra"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	fmt.Printf(";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n",
+		strings.Join(flags, " "), len(resp.Questions), len(resp.Answers), len(resp.Authorities), len(resp.Additionals))
+
+	if size, ok := dns.EDNS(resp.Message); ok {
+		fmt.Printf("\n;; OPT PSEUDOSECTION:\n; EDNS: version: 0, udp: %d\n", size)
+	}
+
+	fmt.Println("\n;; QUESTION SECTION:")
+	for _, q := range resp.Questions {
+		fmt.Printf(";%s\t\tIN\t%s\n", q.Name, dns.TypeString(q.Type))
+	}
+	for _, section := range []struct {
+		title     string
+		resources []dnsmessage.Resource
+	}{
+		{"ANSWER", resp.Answers},
+		{"AUTHORITY", resp.Authorities},
+		{"ADDITIONAL", resp.Additionals},
+	} {
+		records := dns.Records(section.resources)
+		if len(records) == 0 {
+			continue
+		}
+		fmt.Printf("\n;; %s SECTION:\n", section.title)
+		for _, r := range records {
+			fmt.Println(r)
+		}
+	}
+
+	fmt.Printf("\n;; Query time: %d msec\n", resp.RTT.Milliseconds())
+	fmt.Printf(";; SERVER: %s (%s)\n", resp.Server, strings.ToUpper(resp.Network))
+	fmt.Printf(";; MSG SIZE  rcvd: %d\n", resp.Size)
+}
+
+func runDNSServe(cmd *cobra.Command, args []string) error {
+	zone, err := dns.LoadZone(dnsServeZone)
+	if err != nil {
+		return fmt.Errorf("failed to load zone %s: %v", dnsServeZone, err)
+	}
+
+	// Faults from flags apply to every query, after those of the zone
+	if dnsServeDropRate > 0 {
+		zone.Faults = append(zone.Faults, dns.Fault{Drop: true, Rate: dnsServeDropRate})
+	}
+	if dnsServeFail != "" {
+		rcode, err := dns.ParseRCode(dnsServeFail)
+		if err != nil {
+			return err
+		}
+		zone.Faults = append(zone.Faults, dns.Fault{RCode: rcode, Rate: dnsServeFailRate})
+	}
+	if dnsServeDelay > 0 || dnsServeTruncate {
+		zone.Faults = append(zone.Faults, dns.Fault{Delay: dnsServeDelay, Truncate: dnsServeTruncate})
+	}
+
+	conn, err := net.ListenPacket("udp", dnsServeListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s/udp: %v", dnsServeListen, err)
+	}
+	defer conn.Close()
+
+	// TCP takes the port UDP got, which matters when it was 0
+	host, _, _ := net.SplitHostPort(dnsServeListen)
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	ln, err := listenTCP("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s/tcp: %v", dnsServeListen, err)
+	}
+	defer ln.Close()
+
+	srv := dns.NewServer(zone)
+	srv.OnQuery = func(peer net.Addr, q dnsmessage.Question, network string, rcode, answers int) {
+		if rcode < 0 {
+			logger.Info("%s %s from %s over %s: dropped", q.Name, dns.TypeString(q.Type), peer, network)
+			return
+		}
+		logger.Info("%s %s from %s over %s: %s, %d answers", q.Name, dns.TypeString(q.Type), peer, network, dns.RCodeString(dnsmessage.RCode(rcode)), answers)
+	}
+
+	origin := zone.Origin
+	if origin == "" {
+		origin = "every name"
+	}
+	logger.Info("Serving %d records for %s on %s (udp and tcp)", zone.Len(), origin, conn.LocalAddr())
+
+	errc := make(chan error, 2)
+	go func() { errc <- srv.ServeUDP(conn) }()
+	go func() { errc <- srv.ServeTCP(ln) }()
+	return <-errc
+}
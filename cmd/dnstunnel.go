@@ -1,17 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/dns"
 	"github.com/ibrahmsql/gocat/internal/logger"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 var (
@@ -41,7 +47,7 @@ var dnsSessions = struct {
 
 var dnsTunnelCmd = &cobra.Command{
 	Use:     "dns-tunnel",
-	Aliases: []string{"dnstun", "dns"},
+	Aliases: []string{"dnstun"},
 	Short:   "DNS tunneling for data exfiltration and firewall bypass",
 	Long: `Create a covert channel using DNS queries and responses.
 Useful for bypassing firewalls that only allow DNS traffic.
@@ -86,12 +92,17 @@ func runDNSTunnel(cmd *cobra.Command, args []string) {
 		logger.Fatal("Cannot run as both server and client")
 	}
 
+	loadConnectionPolicy(cmd)
+	startMetricsExporter(cmd)
+
 	if dnsTunnelServer {
 		if dnsTunnelTarget == "" {
 			logger.Fatal("--target required for server mode")
 		}
+		startAdminSocket(cmd, registerDNSTunnelAdmin)
 		runDNSTunnelServer()
 	} else {
+		startAdminSocket(cmd, nil)
 		runDNSTunnelClient()
 	}
 }
@@ -140,24 +151,19 @@ func runDNSTunnelServer() {
 // bytes to the DNS client in a TXT response. On protocol or I/O failures it sends an appropriate DNS error response.
 func handleDNSQuery(conn *net.UDPConn, clientAddr *net.UDPAddr, query []byte) {
 	// Parse DNS query
-	if len(query) < 12 {
-		logger.Debug("Invalid DNS query (too short)")
-		return
-	}
-
-	// Extract domain name from query
-	domain, _ := parseDNSQuery(query)
-	if domain == "" {
-		logger.Debug("Failed to parse DNS query")
+	msg, err := dns.Unpack(query)
+	if err != nil || msg.Response {
+		logger.Debug("Invalid DNS query: %v", err)
 		return
 	}
+	domain := strings.TrimSuffix(msg.Questions[0].Name.String(), ".")
 
 	logger.Debug("DNS query: %s from %s", domain, clientAddr)
 
 	// Check if this is a tunnel query
 	if !strings.HasSuffix(domain, dnsTunnelDomain) {
 		logger.Debug("Not a tunnel query: %s", domain)
-		sendDNSError(conn, clientAddr, query)
+		sendDNSError(conn, clientAddr, msg)
 		return
 	}
 
@@ -175,11 +181,11 @@ func handleDNSQuery(conn *net.UDPConn, clientAddr *net.UDPAddr, query []byte) {
 			session.mu.Lock()
 			if session.conn == nil {
 				// Create connection to target
-				targetConn, err := net.Dial("tcp", dnsTunnelTarget)
+				targetConn, err := dialOut("tcp", dnsTunnelTarget)
 				if err != nil {
 					logger.Error("Failed to connect to target: %v", err)
 					session.mu.Unlock()
-					sendDNSError(conn, clientAddr, query)
+					sendDNSError(conn, clientAddr, msg)
 					return
 				}
 				session.conn = targetConn
@@ -192,7 +198,7 @@ func handleDNSQuery(conn *net.UDPConn, clientAddr *net.UDPAddr, query []byte) {
 			if _, err := session.conn.Write(decoded); err != nil {
 				logger.Error("Failed to write to target: %v", err)
 				session.mu.Unlock()
-				sendDNSError(conn, clientAddr, query)
+				sendDNSError(conn, clientAddr, msg)
 				return
 			}
 			session.mu.Unlock()
@@ -205,47 +211,7 @@ func handleDNSQuery(conn *net.UDPConn, clientAddr *net.UDPAddr, query []byte) {
 	session.buffer = nil
 	session.mu.Unlock()
 
-	sendDNSResponse(conn, clientAddr, query, responseData)
-}
-
-// parseDNSQuery extracts the queried domain name from a DNS query packet and
-// returns the domain and the remaining bytes following the domain label sequence.
-// The function expects a standard DNS header (12 bytes) followed by label-encoded
-// QNAME; on malformed or too-short input it returns an empty domain and nil
-// remainder.
-func parseDNSQuery(query []byte) (string, []byte) {
-	if len(query) < 12 {
-		return "", nil
-	}
-
-	// Skip DNS header (12 bytes)
-	pos := 12
-	var domain strings.Builder
-
-	for pos < len(query) {
-		length := int(query[pos])
-		if length == 0 {
-			break
-		}
-		pos++
-
-		if pos+length > len(query) {
-			return "", nil
-		}
-
-		if domain.Len() > 0 {
-			domain.WriteByte('.')
-		}
-		domain.Write(query[pos : pos+length])
-		pos += length
-	}
-
-	remainder := []byte{}
-	if pos < len(query) {
-		remainder = query[pos:]
-	}
-
-	return domain.String(), remainder
+	sendDNSResponse(conn, clientAddr, msg, responseData)
 }
 
 // extractTunnelData extracts a session identifier and the encoded payload portion from a full DNS query domain,
@@ -364,97 +330,43 @@ func readTargetResponses(session *dnsSession, _ *net.UDPConn, _ *net.UDPAddr) {
 	logger.Debug("Target connection closed for session %s", session.id)
 }
 
-// sendDNSResponse constructs a DNS response containing a single TXT answer and sends it to the client address.
-// 
-// The response reuses the request header, marks the packet as a standard response with recursion available,
-// and includes one TXT record whose payload is the tunnel-encoded `data`. The TXT payload is truncated to
-// at most 255 bytes if necessary and the record TTL is set to 60 seconds. The finalized DNS packet is written
-// to `clientAddr` using `conn`.
-func sendDNSResponse(conn *net.UDPConn, clientAddr *net.UDPAddr, query []byte, data []byte) {
-	// Build DNS response
-	response := make([]byte, 512)
-	
-	// Copy query header
-	copy(response, query[:12])
-	
-	// Set response flags
-	response[2] = 0x81 // Response, recursion available
-	response[3] = 0x80
-	
-	// Answer count
-	response[6] = 0x00
-	response[7] = 0x01
-
-	// Copy question section
-	pos := 12
-	for i := 12; i < len(query) && query[i] != 0; i++ {
-		response[pos] = query[i]
-		pos++
-	}
-	response[pos] = 0 // End of domain
-	pos++
-	
-	// Copy QTYPE and QCLASS
-	if pos+4 <= len(query) {
-		copy(response[pos:pos+4], query[pos:pos+4])
-		pos += 4
-	}
-
-	// Add answer section
-	// Pointer to domain name
-	response[pos] = 0xc0
-	response[pos+1] = 0x0c
-	pos += 2
-
-	// TYPE (TXT record)
-	response[pos] = 0x00
-	response[pos+1] = 0x10
-	pos += 2
-
-	// CLASS (IN)
-	response[pos] = 0x00
-	response[pos+1] = 0x01
-	pos += 2
-
-	// TTL (60 seconds)
-	response[pos] = 0x00
-	response[pos+1] = 0x00
-	response[pos+2] = 0x00
-	response[pos+3] = 0x3c
-	pos += 4
-
-	// Encode data
+// sendDNSResponse answers a query with a single TXT record holding the
+// tunnel-encoded data, truncated to the 255 bytes a TXT string can hold,
+// with a TTL of 60 seconds.
+func sendDNSResponse(conn *net.UDPConn, clientAddr *net.UDPAddr, query dnsmessage.Message, data []byte) {
 	encoded := encodeTunnelData(data)
 	if len(encoded) > 255 {
 		encoded = encoded[:255]
 	}
 
-	// RDLENGTH
-	rdlen := len(encoded) + 1
-	response[pos] = byte(rdlen >> 8)
-	response[pos+1] = byte(rdlen)
-	pos += 2
-
-	// TXT data length
-	response[pos] = byte(len(encoded))
-	pos++
+	resp := dns.NewReply(query)
+	resp.RecursionAvailable = true
+	resp.Answers = []dnsmessage.Resource{{
+		Header: dnsmessage.ResourceHeader{
+			Name:  query.Questions[0].Name,
+			Type:  dnsmessage.TypeTXT,
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		},
+		Body: &dnsmessage.TXTResource{TXT: []string{encoded}},
+	}}
 
-	// TXT data
-	copy(response[pos:], encoded)
-	pos += len(encoded)
-
-	// Send response
-	conn.WriteToUDP(response[:pos], clientAddr)
+	packed, err := resp.Pack()
+	if err != nil {
+		logger.Error("Failed to pack DNS response: %v", err)
+		return
+	}
+	conn.WriteToUDP(packed, clientAddr)
 }
 
-// sendDNSError sends a DNS response with RCODE Name Error (3) for the provided query to the client address.
-// It copies the original query, sets the DNS header flags to indicate a name error, and writes the response to the UDP connection.
-func sendDNSError(conn *net.UDPConn, clientAddr *net.UDPAddr, query []byte) {
-	response := make([]byte, len(query))
-	copy(response, query)
-	response[2] = 0x81
-	response[3] = 0x83 // Name error
-	conn.WriteToUDP(response, clientAddr)
+// sendDNSError answers a query with RCODE Name Error (3)
+func sendDNSError(conn *net.UDPConn, clientAddr *net.UDPAddr, query dnsmessage.Message) {
+	resp := dns.NewReply(query)
+	resp.RecursionAvailable = true
+	resp.RCode = dnsmessage.RCodeNameError
+	if packed, err := resp.Pack(); err == nil {
+		conn.WriteToUDP(packed, clientAddr)
+	}
 }
 
 // cleanupDNSSessions periodically removes idle DNS tunneling sessions.
@@ -493,7 +405,7 @@ func runDNSTunnelClient() {
 	logger.Info("DNS Server: %s:%d", "8.8.8.8", dnsTunnelDNSPort)
 
 	// Listen for local connections
-	listener, err := net.Listen("tcp", dnsTunnelListen)
+	listener, err := listenGuarded(dnsTunnelListen)
 	if err != nil {
 		logger.Fatal("Failed to listen: %v", err)
 	}
@@ -551,139 +463,88 @@ func handleDNSTunnelClient(conn net.Conn) {
 // and returns the decoded TXT record payload from the response or nil on error.
 //
 // The function contacts 8.8.8.8 using the package-level dnsTunnelDNSPort, waits up to 5 seconds
-// for a reply, and parses the TXT data using parseDNSResponse. It returns nil if sending,
-// receiving, or parsing fails.
+// for a reply, retrying over TCP when it comes back truncated, and parses the TXT data using
+// parseDNSResponse. It returns nil if sending, receiving, or parsing fails.
 func sendDNSQueryAndWait(domain string) []byte {
-	// Build DNS query
-	query := buildDNSQuery(domain)
-	
-	// Send to DNS server
-	dnsServer := fmt.Sprintf("8.8.8.8:%d", dnsTunnelDNSPort)
-	conn, err := net.Dial("udp", dnsServer)
+	query, err := dns.NewQuery(domain, dnsmessage.TypeTXT, dns.QueryOptions{})
 	if err != nil {
-		logger.Error("Failed to connect to DNS server: %v", err)
+		logger.Error("Failed to build DNS query: %v", err)
 		return nil
 	}
-	defer conn.Close()
 
-	conn.SetDeadline(time.Now().Add(5 * time.Second))
-	
-	if _, err := conn.Write(query); err != nil {
-		logger.Error("Failed to send DNS query: %v", err)
-		return nil
-	}
-
-	// Read response
-	buf := make([]byte, 512)
-	n, err := conn.Read(buf)
+	dnsServer := fmt.Sprintf("8.8.8.8:%d", dnsTunnelDNSPort)
+	client := &dns.Client{Timeout: 5 * time.Second}
+	resp, err := client.Exchange(context.Background(), query, dnsServer)
 	if err != nil {
-		logger.Error("Failed to read DNS response: %v", err)
+		logger.Error("DNS query to %s failed: %v", dnsServer, err)
 		return nil
 	}
 
 	// Parse TXT record data
-	return parseDNSResponse(buf[:n])
+	return parseDNSResponse(resp.Message)
 }
 
-// buildDNSQuery builds a DNS query packet for the given domain that requests a TXT record.
-// The packet uses a fixed transaction ID (0x1234), standard query flags, and a single question;
-// the domain is encoded in DNS label format and QTYPE is set to TXT (16) with QCLASS IN (1).
-// The function returns the raw DNS query bytes ready to be sent over UDP.
-func buildDNSQuery(domain string) []byte {
-	query := make([]byte, 512)
-	
-	// Transaction ID
-	query[0] = 0x12
-	query[1] = 0x34
-	
-	// Flags (standard query)
-	query[2] = 0x01
-	query[3] = 0x00
-	
-	// Questions: 1
-	query[4] = 0x00
-	query[5] = 0x01
-	
-	// Encode domain name
-	pos := 12
-	labels := strings.Split(domain, ".")
-	for _, label := range labels {
-		query[pos] = byte(len(label))
-		pos++
-		copy(query[pos:], label)
-		pos += len(label)
+// parseDNSResponse returns the decoded payload from the first TXT answer of a response
+// using the tunnel's configured encoding. It returns nil if the response has no TXT data.
+func parseDNSResponse(response dnsmessage.Message) []byte {
+	for _, answer := range response.Answers {
+		if txt, ok := answer.Body.(*dnsmessage.TXTResource); ok {
+			return decodeTunnelData(strings.Join(txt.TXT, ""))
+		}
 	}
-	query[pos] = 0 // End of domain
-	pos++
-	
-	// QTYPE (TXT = 16)
-	query[pos] = 0x00
-	query[pos+1] = 0x10
-	pos += 2
-	
-	// QCLASS (IN = 1)
-	query[pos] = 0x00
-	query[pos+1] = 0x01
-	pos += 2
-	
-	return query[:pos]
+	return nil
 }
 
-// parseDNSResponse parses a DNS response and returns the decoded payload from the first TXT answer
-// using the tunnel's configured encoding. It returns nil if the response is malformed or contains no TXT data.
-func parseDNSResponse(response []byte) []byte {
-	// Simple TXT record parser
-	// This is a simplified version
-	if len(response) < 12 {
-		return nil
-	}
+// dnsSessionInfo describes a DNS tunnel session for sessions.list
+type dnsSessionInfo struct {
+	ID         string    `json:"id"`
+	Target     string    `json:"target,omitempty"`
+	LastActive time.Time `json:"last_active"`
+	Buffered   int       `json:"buffered"`
+}
 
-	// Skip to answer section
-	pos := 12
-	
-	// Skip question section
-	for pos < len(response) && response[pos] != 0 {
-		if response[pos] >= 0xc0 {
-			pos += 2
-			break
+// registerDNSTunnelAdmin lists and kills the server's tunnel sessions over
+// the admin API
+func registerDNSTunnelAdmin(srv *admin.Server) {
+	srv.Handle("sessions.list", "List DNS tunnel sessions", func(json.RawMessage) (interface{}, error) {
+		dnsSessions.mu.RLock()
+		list := make([]dnsSessionInfo, 0, len(dnsSessions.sessions))
+		for _, session := range dnsSessions.sessions {
+			session.mu.Lock()
+			info := dnsSessionInfo{
+				ID:         session.id,
+				LastActive: session.lastActive,
+				Buffered:   len(session.buffer),
+			}
+			if session.conn != nil {
+				info.Target = session.conn.RemoteAddr().String()
+			}
+			session.mu.Unlock()
+			list = append(list, info)
 		}
-		pos += int(response[pos]) + 1
-	}
-	if pos < len(response) && response[pos] == 0 {
-		pos++
-	}
-	pos += 4 // Skip QTYPE and QCLASS
-	
-	// Parse answer
-	if pos+12 > len(response) {
-		return nil
-	}
-	
-	// Skip name, type, class, TTL
-	if response[pos] >= 0xc0 {
-		pos += 2
-	}
-	pos += 10
-	
-	// Read RDLENGTH
-	if pos+2 > len(response) {
-		return nil
-	}
-	rdlen := int(response[pos])<<8 | int(response[pos+1])
-	pos += 2
-	
-	// Read TXT data
-	if pos+rdlen > len(response) {
-		return nil
-	}
-	
-	txtLen := int(response[pos])
-	pos++
-	
-	if pos+txtLen > len(response) {
-		return nil
-	}
-	
-	encoded := string(response[pos : pos+txtLen])
-	return decodeTunnelData(encoded)
-}
\ No newline at end of file
+		dnsSessions.mu.RUnlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		return list, nil
+	})
+
+	srv.Handle("sessions.kill", "Close a DNS tunnel session and its target connection: {\"id\": \"1234\"}", func(params json.RawMessage) (interface{}, error) {
+		id, err := decodeID(params)
+		if err != nil {
+			return nil, err
+		}
+		dnsSessions.mu.Lock()
+		session, ok := dnsSessions.sessions[id]
+		delete(dnsSessions.sessions, id)
+		dnsSessions.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errUnknownSession, id)
+		}
+		session.mu.Lock()
+		if session.conn != nil {
+			session.conn.Close()
+		}
+		session.mu.Unlock()
+		logger.Info("DNS tunnel session %s killed via admin API", id)
+		return map[string]string{"killed": id}, nil
+	})
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/process"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/spf13/cobra"
+)
+
+// execArgs returns the command line run for each connection: --sh-exec
+// runs its command through the system shell, --exec and command are split
+// on whitespace like Ncat does. It is empty when no command is configured.
+func execArgs(cmd *cobra.Command, command string) []string {
+	flags := cmd.Root().PersistentFlags()
+	if shExec, _ := flags.GetString("sh-exec"); shExec != "" {
+		return shellCommand(shExec)
+	}
+	if globalExec, _ := flags.GetString("exec"); globalExec != "" {
+		command = globalExec
+	}
+	return strings.Fields(command)
+}
+
+// shellCommand returns the command line running command through the
+// system shell
+func shellCommand(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd.exe", "/C", command}
+	}
+	return []string{"/bin/sh", "-c", command}
+}
+
+// interactiveShell returns the command line of the user's interactive shell
+func interactiveShell() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd.exe"}
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return []string{shell, "-i"}
+	}
+	return []string{"/bin/sh", "-i"}
+}
+
+// execConfig returns the configuration for running args from the global
+// --pty, --exec-env, --exec-clear-env, --exec-dir and --exit-status flags
+func execConfig(args []string) process.Config {
+	flags := rootCmd.PersistentFlags()
+	cfg := process.Config{Args: args}
+	cfg.PTY, _ = flags.GetBool("pty")
+	cfg.Env, _ = flags.GetStringArray("exec-env")
+	cfg.ClearEnv, _ = flags.GetBool("exec-clear-env")
+	cfg.Dir, _ = flags.GetString("exec-dir")
+	cfg.ReportExit, _ = flags.GetBool("exit-status")
+	return cfg
+}
+
+// runExec runs the command of cfg for conn, relaying between them until
+// both are done. Under a PTY the peer resizes the terminal in-band, or with
+// --telnet through NAWS. The command's process group is killed afterwards.
+func runExec(conn net.Conn, cfg process.Config) (relay.Stats, error) {
+	proc, err := process.Start(cfg)
+	if err != nil {
+		return relay.Stats{}, err
+	}
+	defer proc.Close()
+	logger.Debug("Started %s (pid %d) for %s", cfg.Args[0], proc.Pid(), conn.RemoteAddr())
+
+	engine := newRelay()
+	if cfg.PTY {
+		useTelnet, _ := rootCmd.PersistentFlags().GetBool("telnet")
+		if useTelnet {
+			if err := process.RequestWindowSize(conn); err != nil {
+				logger.Debug("Failed to request the window size: %v", err)
+			}
+		}
+		engine.Use(proc.ResizeControl(relay.Forward, useTelnet))
+	}
+	stats := runRelay(engine, relay.Conn(conn), proc)
+
+	if err := proc.Wait(); err != nil {
+		logger.Debug("%s exited: %v", cfg.Args[0], err)
+	} else {
+		logger.Debug("%s exited", cfg.Args[0])
+	}
+	return stats, nil
+}
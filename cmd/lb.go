@@ -0,0 +1,444 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/balancer"
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/proxyproto"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/ibrahmsql/gocat/internal/signals"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lbListen         string
+	lbBackends       []string
+	lbAlgorithm      string
+	lbHealthInterval time.Duration
+	lbHealthTimeout  time.Duration
+	lbHealthSend     string
+	lbHealthExpect   string
+	lbHealthRise     int
+	lbHealthFall     int
+	lbConnectTimeout time.Duration
+	lbDrainTimeout   time.Duration
+	lbProxyProtocol  string
+	lbUDPIdle        time.Duration
+)
+
+// lbState is the running balancer
+var lbState struct {
+	balancer *balancer.Balancer
+	network  string
+	header   proxyproto.Version // 0 sends no PROXY header
+
+	active  atomic.Int64
+	total   atomic.Int64
+	closing atomic.Bool
+	drain   sync.Once
+	drained chan struct{} // closed once the drain is over
+}
+
+var lbCmd = &cobra.Command{
+	Use:   "lb --listen <addr> --backends <host:port,...>",
+	Short: "TCP/UDP load balancer with health checks and session affinity",
+	Long: `Balance raw TCP connections, or UDP sessions with -u, over backends.
+
+Backends are picked round-robin, by least connections, or by consistent
+hashing on the client IP (source-hash), which keeps every connection of a
+client on the same backend and moves only the clients of a backend that
+goes away. When a backend refuses a connection the next one is tried, and
+its circuit breaker keeps it out for a while once it refused several times
+in a row.
+
+Health checks connect to every backend each --health-interval. With
+--health-send they write a probe, with --health-expect they wait for a
+response containing the expected text (Go escapes such as \r\n and \x00
+are understood). UDP backends are only checked with a probe.
+
+On SIGINT or SIGTERM the listener closes and open sessions get
+--drain-timeout to finish. Single backends are drained through the admin
+API, which takes them out of rotation while their sessions carry on.
+
+--proxy-protocol, or the global --send-proxy-protocol, prefixes each
+backend connection with a PROXY protocol header carrying the client's
+address; over UDP it takes v2 and prefixes every datagram.
+
+Examples:
+  gocat lb --listen :5432 --backends db1:5432,db2:5432
+  gocat lb --listen :6379 --backends r1:6379,r2:6379 --algorithm source-hash \
+      --health-send 'PING\r\n' --health-expect PONG
+  gocat lb -u --listen :514 --backends log1:514,log2:514 --proxy-protocol v2
+  gocat lb --listen :8443 --backends a:443,b:443 --admin-socket /tmp/lb.sock
+  gocat ctl -S /tmp/lb.sock backends.drain address=a:443`,
+	Args: cobra.NoArgs,
+	RunE: runLB,
+}
+
+func init() {
+	rootCmd.AddCommand(lbCmd)
+
+	lbCmd.Flags().StringVarP(&lbListen, "listen", "l", "", "Address to listen on")
+	lbCmd.Flags().StringSliceVar(&lbBackends, "backends", nil, "Backend addresses (host:port)")
+	lbCmd.Flags().StringVar(&lbAlgorithm, "algorithm", "round-robin", "Balancing algorithm (round-robin, least-connections, source-hash)")
+	lbCmd.Flags().DurationVar(&lbHealthInterval, "health-interval", 5*time.Second, "Time between health checks (0 = no checks)")
+	lbCmd.Flags().DurationVar(&lbHealthTimeout, "health-timeout", 2*time.Second, "Time a health check may take")
+	lbCmd.Flags().StringVar(&lbHealthSend, "health-send", "", "Probe to send in health checks")
+	lbCmd.Flags().StringVar(&lbHealthExpect, "health-expect", "", "Text the response to a health check must contain")
+	lbCmd.Flags().IntVar(&lbHealthRise, "health-rise", 2, "Passed checks in a row that bring a backend back")
+	lbCmd.Flags().IntVar(&lbHealthFall, "health-fall", 2, "Failed checks in a row that take a backend out")
+	lbCmd.Flags().DurationVar(&lbConnectTimeout, "connect-timeout", 5*time.Second, "Time to connect to a backend before trying the next")
+	lbCmd.Flags().DurationVar(&lbDrainTimeout, "drain-timeout", 30*time.Second, "Time open sessions get to finish on shutdown")
+	lbCmd.Flags().StringVar(&lbProxyProtocol, "proxy-protocol", "", "Send a PROXY protocol header to backends (v1, v2)")
+	lbCmd.Flags().DurationVar(&lbUDPIdle, "udp-idle-timeout", 2*time.Minute, "End UDP sessions idle this long")
+
+	lbCmd.MarkFlagRequired("listen")
+	lbCmd.MarkFlagRequired("backends")
+}
+
+func runLB(cmd *cobra.Command, args []string) error {
+	algorithm, err := balancer.ParseAlgorithm(lbAlgorithm)
+	if err != nil {
+		return err
+	}
+	lbState.network = "tcp"
+	if udp, _ := cmd.Root().PersistentFlags().GetBool("udp"); udp {
+		lbState.network = "udp"
+	}
+	if lbProxyProtocol == "" {
+		lbProxyProtocol, _ = cmd.Root().PersistentFlags().GetString("send-proxy-protocol")
+	}
+	if lbProxyProtocol != "" {
+		lbState.header, err = proxyproto.ParseVersion(lbProxyProtocol)
+		if err != nil {
+			return err
+		}
+		if lbState.network == "udp" && lbState.header != proxyproto.V2 {
+			return fmt.Errorf("PROXY protocol %s cannot carry UDP, use v2", lbState.header)
+		}
+	}
+	for _, backend := range lbBackends {
+		if _, _, err := net.SplitHostPort(backend); err != nil {
+			return fmt.Errorf("invalid backend %q: %v", backend, err)
+		}
+	}
+	loadConnectionPolicy(cmd)
+
+	lbState.balancer = balancer.New(lbBackends, &balancer.Config{
+		Algorithm: algorithm,
+		Available: relayDialer.Available,
+	})
+	if err := startLBHealthChecks(); err != nil {
+		return err
+	}
+
+	var ln net.Listener
+	if lbState.network == "udp" {
+		config := network.DefaultUDPListenerConfig()
+		config.Allow = allowUDPPeer
+		config.IdleTimeout = lbUDPIdle
+		ln, err = network.ListenUDP("udp", lbListen, config)
+	} else {
+		ln, err = listenGuarded(lbListen)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", lbListen, err)
+	}
+	defer ln.Close()
+
+	lbState.drained = make(chan struct{})
+	drainBeforeExit = func() { drainLB(ln) }
+	if path, _ := cmd.Root().PersistentFlags().GetString("admin-socket"); path == "" {
+		signals.SetupSignalHandler(func() {
+			drainLB(ln)
+			os.Exit(0)
+		})
+	}
+	if srv := startAdminSocket(cmd, registerLBAdmin); srv != nil {
+		defer srv.Close()
+	}
+	startMetricsExporter(cmd)
+
+	logger.Info("Balancing %s on %s over %v (%s)", lbState.network, ln.Addr(), lbBackends, algorithm)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			// Draining closed the listener; stop once the sessions are done
+			if lbState.closing.Load() {
+				<-lbState.drained
+				return nil
+			}
+			if lbState.network == "udp" {
+				return err
+			}
+			logger.Error("Accept error: %v", err)
+			continue
+		}
+
+		lbState.active.Add(1)
+		lbState.total.Add(1)
+		if serveConn(conn, serveLBSession) != nil {
+			lbState.active.Add(-1)
+		}
+	}
+}
+
+// startLBHealthChecks checks the backends in the background, if enabled
+func startLBHealthChecks() error {
+	if lbHealthInterval <= 0 {
+		return nil
+	}
+	if lbState.network == "udp" && lbHealthSend == "" {
+		logger.Info("UDP backends are not health checked without --health-send")
+		return nil
+	}
+	send, err := unescapeProbe(lbHealthSend)
+	if err != nil {
+		return fmt.Errorf("invalid --health-send: %v", err)
+	}
+	expect, err := unescapeProbe(lbHealthExpect)
+	if err != nil {
+		return fmt.Errorf("invalid --health-expect: %v", err)
+	}
+
+	go lbState.balancer.RunHealthChecks(context.Background(), balancer.HealthCheck{
+		Network:  lbState.network,
+		Interval: lbHealthInterval,
+		Timeout:  lbHealthTimeout,
+		Send:     send,
+		Expect:   expect,
+		Rise:     lbHealthRise,
+		Fall:     lbHealthFall,
+		Dial:     happyEyeballs(lbHealthTimeout, nil),
+		OnChange: func(be *balancer.Backend, healthy bool, err error) {
+			if !healthy {
+				logger.Warn("Backend %s is down: %v", be.Address, dialCause(err))
+				return
+			}
+			// A backend that answers checks again may take connections
+			// before its breaker would have let a probe through
+			relayDialer.ResetCircuitBreaker(be.Address)
+			logger.Info("Backend %s is up", be.Address)
+		},
+	})
+	return nil
+}
+
+// unescapeProbe reads the Go escapes of a health check probe
+func unescapeProbe(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(s, `"`, `\"`) + `"`)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(unquoted), nil
+}
+
+// serveLBSession relays a client to a backend
+func serveLBSession(c net.Conn) {
+	defer lbState.active.Add(-1)
+	defer c.Close()
+
+	be, conn, err := dialLBBackend(c)
+	if err != nil {
+		logger.Warn("Dropping %s: %v", c.RemoteAddr(), err)
+		return
+	}
+	defer conn.Close()
+	release := be.Acquire()
+	defer release()
+
+	logger.Debug("Session from %s to %s", c.RemoteAddr(), be.Address)
+	if lbState.network == "udp" {
+		engine := newRelay()
+		engine.BufferSize = network.MaxDatagramSize
+		runRelay(engine, relay.Conn(c), relay.Conn(conn))
+		return
+	}
+	relayConns(c, conn)
+}
+
+// dialLBBackend connects a client to the backend the balancer picks for
+// it, moving on to the next one while backends refuse
+func dialLBBackend(c net.Conn) (*balancer.Backend, net.Conn, error) {
+	var tried []*balancer.Backend
+	for {
+		be := lbState.balancer.Next(c.RemoteAddr(), tried)
+		if be == nil {
+			if len(tried) == 0 {
+				return nil, nil, fmt.Errorf("no backend available")
+			}
+			return nil, nil, fmt.Errorf("all %d available backends failed", len(tried))
+		}
+
+		conn, err := connectLBBackend(be.Address, c)
+		if err == nil {
+			return be, conn, nil
+		}
+		be.Failed()
+		logger.Warn("Backend %s failed for %s: %v", be.Address, c.RemoteAddr(), dialCause(err))
+		tried = append(tried, be)
+	}
+}
+
+// connectLBBackend dials a backend through relayDialer, whose circuit
+// breakers the balancer consults, and sends the PROXY header if enabled
+func connectLBBackend(address string, client net.Conn) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lbConnectTimeout)
+	defer cancel()
+	conn, err := relayDialer.DialContext(ctx, lbState.network, address)
+	if err != nil {
+		return nil, err
+	}
+	if lbState.header == 0 {
+		return conn, nil
+	}
+
+	header := proxyproto.NewHeader(lbState.header, client.RemoteAddr(), client.LocalAddr())
+	if lbState.network == "udp" {
+		wrapped, err := proxyproto.DatagramConn(conn, header)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return wrapped, nil
+	}
+	if _, err := header.WriteTo(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// drainLB stops taking sessions and waits up to --drain-timeout for the
+// open ones to end. A second signal cuts the wait short.
+func drainLB(ln net.Listener) {
+	lbState.drain.Do(func() {
+		defer close(lbState.drained)
+		lbState.closing.Store(true)
+		ln.Close()
+
+		force := make(chan os.Signal, 1)
+		signal.Notify(force, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(force)
+
+		deadline := time.After(lbDrainTimeout)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		if n := lbState.active.Load(); n > 0 {
+			logger.Info("Draining %d sessions for up to %v", n, lbDrainTimeout)
+		}
+		for lbState.active.Load() > 0 {
+			select {
+			case <-ticker.C:
+			case <-deadline:
+				logger.Warn("Drain timed out with %d sessions open", lbState.active.Load())
+				return
+			case <-force:
+				logger.Warn("Drain interrupted with %d sessions open", lbState.active.Load())
+				return
+			}
+		}
+		logger.Info("All sessions ended")
+	})
+}
+
+// decodeBackendAddress reads the "address" param of the backends methods
+func decodeBackendAddress(params json.RawMessage) (string, error) {
+	var p struct {
+		Address string `json:"address"`
+	}
+	if err := admin.DecodeParams(params, &p); err != nil {
+		return "", err
+	}
+	if _, _, err := net.SplitHostPort(p.Address); err != nil {
+		return "", admin.InvalidParams("invalid backend address: %q", p.Address)
+	}
+	return p.Address, nil
+}
+
+// registerLBAdmin adds the balancer's stats and backend methods to the
+// admin API
+func registerLBAdmin(srv *admin.Server) {
+	lb := lbState.balancer
+
+	srv.Handle("stats", "Show session totals and backends", func(json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{
+			"network":         lbState.network,
+			"algorithm":       lb.Algorithm(),
+			"active_sessions": lbState.active.Load(),
+			"total_sessions":  lbState.total.Load(),
+			"draining":        lbState.closing.Load(),
+			"backends":        lb.Stats(),
+		}, nil
+	})
+
+	srv.Handle("backends.list", "List backends, their health and sessions", func(json.RawMessage) (interface{}, error) {
+		return lb.Stats(), nil
+	})
+
+	srv.Handle("backends.add", "Add a backend: {\"address\": \"host:port\"}", func(params json.RawMessage) (interface{}, error) {
+		address, err := decodeBackendAddress(params)
+		if err != nil {
+			return nil, err
+		}
+		if !lb.Add(address) {
+			return nil, fmt.Errorf("backend already exists: %s", address)
+		}
+		logger.Info("Backend added via admin API: %s", address)
+		return lb.Stats(), nil
+	})
+
+	srv.Handle("backends.remove", "Remove a backend, leaving its sessions open: {\"address\": \"host:port\"}", func(params json.RawMessage) (interface{}, error) {
+		address, err := decodeBackendAddress(params)
+		if err != nil {
+			return nil, err
+		}
+		be, ok := lb.Remove(address)
+		if !ok {
+			return nil, fmt.Errorf("unknown backend: %s", address)
+		}
+		logger.Info("Backend removed via admin API: %s (%d sessions open)", address, be.Active())
+		return lb.Stats(), nil
+	})
+
+	srv.Handle("backends.drain", "Take a backend out of rotation while its sessions finish: {\"address\": \"host:port\"}", func(params json.RawMessage) (interface{}, error) {
+		address, err := decodeBackendAddress(params)
+		if err != nil {
+			return nil, err
+		}
+		if !lb.SetDraining(address, true) {
+			return nil, fmt.Errorf("unknown backend: %s", address)
+		}
+		logger.Info("Draining backend %s via admin API", address)
+		return lb.Stats(), nil
+	})
+
+	srv.Handle("backends.enable", "Put a drained backend back in rotation: {\"address\": \"host:port\"}", func(params json.RawMessage) (interface{}, error) {
+		address, err := decodeBackendAddress(params)
+		if err != nil {
+			return nil, err
+		}
+		if !lb.SetDraining(address, false) {
+			return nil, fmt.Errorf("unknown backend: %s", address)
+		}
+		logger.Info("Backend %s back in rotation via admin API", address)
+		return lb.Stats(), nil
+	})
+}
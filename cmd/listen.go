@@ -1,23 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
-	"os/exec"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/creack/pty"
 	"github.com/ibrahmsql/gocat/internal/logger"
 	"github.com/ibrahmsql/gocat/internal/network"
 	"github.com/ibrahmsql/gocat/internal/readline"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/ibrahmsql/gocat/internal/scripting"
 	"github.com/ibrahmsql/gocat/internal/signals"
-	"github.com/ibrahmsql/gocat/internal/terminal"
 	"github.com/spf13/cobra"
 )
 
@@ -26,11 +26,15 @@ var (
 	blockSignals    bool
 	localOnly       bool
 	execCommand     string
+	listenExecArgs  []string
 	bindAddress     string
 	listenKeepAlive bool
 	maxConnections  int
 	listenTimeout   time.Duration
 	listenUseUDP    bool
+	listenUDPEcho   bool
+	listenUDPIdle   time.Duration
+	listenKeepOpen  bool
 	listenUseSCTP   bool
 	listenForceIPv6 bool
 	listenForceIPv4 bool
@@ -44,6 +48,8 @@ var (
 	listenHexDumpFile  string
 	listenAppendOutput bool
 	listenNoShutdown   bool
+	listenOutputOnce   sync.Once
+	listenOutputWriter io.Writer
 	// Access control flags
 	allowList []string
 	denyList  []string
@@ -53,6 +59,9 @@ var (
 	listenTelnetMode bool
 	listenCRLFMode   bool
 	listenZeroIOMode bool
+	// Lua connection handler
+	listenLuaExec string
+	luaHandler    *scripting.ConnHandler
 )
 
 var listenCmd = &cobra.Command{
@@ -76,6 +85,8 @@ func init() {
 	listenCmd.Flags().IntVar(&maxConnections, "listen-max-conn", 10, "Maximum concurrent connections")
 	listenCmd.Flags().DurationVar(&listenTimeout, "listen-timeout", 0, "Connection timeout (0 = no timeout)")
 	listenCmd.Flags().BoolVar(&listenUseUDP, "listen-udp", false, "Use UDP instead of TCP")
+	listenCmd.Flags().BoolVar(&listenUDPEcho, "udp-echo", false, "Send every UDP datagram back to its sender")
+	listenCmd.Flags().DurationVar(&listenUDPIdle, "udp-idle-timeout", 2*time.Minute, "End UDP peer sessions idle this long with -k (0 = never)")
 	listenCmd.Flags().BoolVar(&listenForceIPv6, "listen-ipv6", false, "Force IPv6")
 	listenCmd.Flags().BoolVar(&listenForceIPv4, "listen-ipv4", false, "Force IPv4")
 	listenCmd.Flags().BoolVar(&listenUseSSL, "listen-ssl", false, "Use SSL/TLS")
@@ -105,6 +116,9 @@ func runListen(cmd *cobra.Command, args []string) {
 	if globalUDP, _ := cmd.Root().PersistentFlags().GetBool("udp"); globalUDP {
 		listenUseUDP = true
 	}
+	if globalKeepOpen, _ := cmd.Root().PersistentFlags().GetBool("keep-open"); globalKeepOpen {
+		listenKeepOpen = true
+	}
 	if globalIPv4, _ := cmd.Root().PersistentFlags().GetBool("ipv4"); globalIPv4 {
 		listenForceIPv4 = true
 	}
@@ -114,6 +128,9 @@ func runListen(cmd *cobra.Command, args []string) {
 	if globalSCTP, _ := cmd.Root().PersistentFlags().GetBool("sctp"); globalSCTP {
 		listenUseSCTP = true
 	}
+	if listenUseSCTP {
+		loadSCTPConfig(cmd)
+	}
 	if globalMaxConns, _ := cmd.Root().PersistentFlags().GetInt("max-conns"); globalMaxConns > 0 {
 		maxConnections = globalMaxConns
 	}
@@ -144,12 +161,7 @@ func runListen(cmd *cobra.Command, args []string) {
 		listenAppendOutput = true
 	}
 	// Execution flags for listen
-	if globalExec, _ := cmd.Root().PersistentFlags().GetString("exec"); globalExec != "" {
-		execCommand = globalExec
-	}
-	if globalShExec, _ := cmd.Root().PersistentFlags().GetString("sh-exec"); globalShExec != "" {
-		execCommand = "/bin/sh -c \"" + globalShExec + "\""
-	}
+	listenExecArgs = execArgs(cmd, execCommand)
 	// Access control flags
 	if globalAllow, _ := cmd.Root().PersistentFlags().GetStringSlice("allow"); len(globalAllow) > 0 {
 		allowList = globalAllow
@@ -163,6 +175,9 @@ func runListen(cmd *cobra.Command, args []string) {
 	if globalDenyFile, _ := cmd.Root().PersistentFlags().GetString("denyfile"); globalDenyFile != "" {
 		denyFile = globalDenyFile
 	}
+	// TCP peers are filtered as they are accepted, UDP peers as their
+	// first datagram arrives
+	loadConnectionPolicy(cmd)
 	// Protocol flags for listen
 	if globalTelnet, _ := cmd.Root().PersistentFlags().GetBool("telnet"); globalTelnet {
 		listenTelnetMode = true
@@ -174,6 +189,10 @@ func runListen(cmd *cobra.Command, args []string) {
 		listenZeroIOMode = true
 	}
 
+	if globalLuaExec, _ := cmd.Root().PersistentFlags().GetString("lua-exec"); globalLuaExec != "" {
+		listenLuaExec = globalLuaExec
+	}
+
 	if err := listen(host, port); err != nil {
 		logger.Fatal("Error: %v", err)
 	}
@@ -196,6 +215,9 @@ func listen(host, port string) error {
 	}
 
 	logger.Debug("Listening on %s using %s protocol", address, network)
+	if listenUDPEcho && !listenUseUDP {
+		return errors.New("--udp-echo requires UDP (-u)")
+	}
 	if listenUseSSL {
 		logger.Debug("SSL/TLS enabled for listening")
 	}
@@ -204,6 +226,19 @@ func listen(host, port string) error {
 	var listener net.Listener
 	var err error
 
+	// Compile the Lua connection handler once, each connection gets its own state
+	if listenLuaExec != "" {
+		config, configErr := luaEngineConfig(listenLuaExec)
+		if configErr != nil {
+			return fmt.Errorf("failed to load Lua handler: %w", configErr)
+		}
+		luaHandler, err = scripting.NewConnHandler(listenLuaExec, config)
+		if err != nil {
+			return fmt.Errorf("failed to load Lua handler: %w", err)
+		}
+		logger.Debug("Using Lua connection handler %s", listenLuaExec)
+	}
+
 	// Handle SCTP separately
 	if listenUseSCTP {
 		return handleSCTPListener(network, address)
@@ -215,7 +250,10 @@ func listen(host, port string) error {
 	} else if listenUseUDP {
 		return handleUDPListener(network, address)
 	} else {
-		listener, err = net.Listen(network, address)
+		listener, err = listenTCP(network, address)
+		if err == nil {
+			listener = guardListener(listener)
+		}
 	}
 
 	if err != nil {
@@ -249,7 +287,7 @@ func listen(host, port string) error {
 
 		go func(c net.Conn) {
 			defer func() {
-				if err := c.Close(); err != nil {
+				if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 					logger.Error("Error closing connection: %v", err)
 				}
 				<-connSemaphore // Release semaphore slot
@@ -276,48 +314,11 @@ func createTLSListener(network, address string) (net.Listener, error) {
 		MinVersion:   tls.VersionTLS12, // Secure minimum TLS version
 	}
 
-	return tls.Listen(network, address, tlsConfig)
-}
-
-func handleUDPListener(network, address string) error {
-	udpAddr, err := net.ResolveUDPAddr(network, address)
-	if err != nil {
-		return fmt.Errorf("failed to resolve UDP address: %v", err)
-	}
-
-	udpConn, err := net.ListenUDP(network, udpAddr)
+	ln, err := listenTCP(network, address)
 	if err != nil {
-		return fmt.Errorf("failed to bind UDP: %v", err)
-	}
-	defer func() {
-		if err := udpConn.Close(); err != nil {
-			logger.Error("Error closing UDP connection: %v", err)
-		}
-	}()
-
-	theme := logger.GetCurrentTheme()
-	if _, err := theme.Success.Printf("Listening on %s (UDP)\n", address); err != nil {
-		logger.Error("Error printing success message: %v", err)
-	}
-
-	buffer := make([]byte, 4096)
-	for {
-		n, clientAddr, err := udpConn.ReadFromUDP(buffer)
-		if err != nil {
-			logger.Error("UDP read error: %v", err)
-			continue
-		}
-
-		theme := logger.GetCurrentTheme()
-		if _, err := theme.Highlight.Printf("UDP packet from %s: %s\n", clientAddr, string(buffer[:n])); err != nil {
-			logger.Error("Error printing highlight message: %v", err)
-		}
-
-		// Echo back for UDP
-		if _, err := udpConn.WriteToUDP(buffer[:n], clientAddr); err != nil {
-			logger.Error("UDP write error: %v", err)
-		}
+		return nil, err
 	}
+	return tls.NewListener(guardListener(ln), tlsConfig), nil
 }
 
 func handleSCTPListener(netType, address string) error {
@@ -333,7 +334,7 @@ func handleSCTPListener(netType, address string) error {
 	}
 
 	// Create SCTP listener
-	listener, err := network.ListenSCTP(netType, sctpAddr, nil)
+	listener, err := network.ListenSCTP(netType, sctpAddr, sctpConfig)
 	if err != nil {
 		return fmt.Errorf("failed to bind SCTP: %w", err)
 	}
@@ -354,6 +355,9 @@ func handleSCTPListener(netType, address string) error {
 
 	for {
 		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return err
+		}
 		if err != nil {
 			logger.Error("Failed to accept SCTP connection: %v", err)
 			continue
@@ -404,7 +408,13 @@ func handleConnection(conn net.Conn) {
 	}
 
 	var err error
-	if interactive {
+	if luaHandler != nil {
+		err = luaHandler.Handle(conn)
+	} else if len(listenExecArgs) > 0 {
+		_, err = runExec(conn, execConfig(listenExecArgs))
+	} else if listenUDPEcho {
+		err = handleEcho(conn)
+	} else if interactive {
 		err = handleInteractive(conn)
 	} else if localOnly {
 		err = handleLocalInteractive(conn)
@@ -422,25 +432,27 @@ func handleNormal(conn net.Conn) error {
 		signals.BlockExitSignals()
 	}
 
-	if execCommand != "" {
-		if _, err := conn.Write([]byte(execCommand + "\n")); err != nil {
-			return fmt.Errorf("failed to send exec command: %v", err)
-		}
-	}
+	// EOF on stdin half-closes the connection; the session ends when the
+	// peer is done sending
+	relayEndpoints(relay.Stdio(os.Stdin, listenOutput()), relay.Conn(conn))
+	return nil
+}
 
-	// Start goroutines for bidirectional communication
-	go func() {
-		if _, err := io.Copy(conn, os.Stdin); err != nil {
-			logger.Error("stdin copy error: %v", err)
+// listenOutput returns where received data goes: the -o file, or stdout
+func listenOutput() io.Writer {
+	listenOutputOnce.Do(func() {
+		listenOutputWriter = os.Stdout
+		if listenOutputFile == "" {
+			return
 		}
-		os.Exit(0)
-	}()
-
-	if _, err := io.Copy(os.Stdout, conn); err != nil {
-		return fmt.Errorf("stdout copy error: %v", err)
-	}
-
-	return nil
+		file, err := openOutputFile(listenOutputFile, listenAppendOutput)
+		if err != nil {
+			logger.Error("Failed to open output file: %v", err)
+			return
+		}
+		listenOutputWriter = file
+	})
+	return listenOutputWriter
 }
 
 func handleInteractive(conn net.Conn) error {
@@ -448,65 +460,31 @@ func handleInteractive(conn net.Conn) error {
 		signals.BlockExitSignals()
 	}
 
-	// Setup terminal for interactive mode on Unix systems
-	if runtime.GOOS != "windows" {
-		if termState, err := terminal.SetupTerminal(); err == nil {
-			defer func() {
-				if err := termState.Restore(); err != nil {
-					logger.Error("Error restoring terminal state: %v", err)
-				}
-			}()
-		}
-	}
-
-	// Create a PTY for better shell interaction
-	shell := "/bin/sh"
-	if runtime.GOOS == "windows" {
-		shell = "cmd.exe"
-	} else if os.Getenv("SHELL") != "" {
-		shell = os.Getenv("SHELL")
-	}
-
-	cmd := exec.Command(shell, "-i")
-	ptmx, err := pty.Start(cmd)
-	if err != nil {
-		return fmt.Errorf("failed to start pty: %v", err)
-	}
-	defer func() {
-		if err := ptmx.Close(); err != nil {
-			logger.Error("Error closing pty: %v", err)
-		}
-	}()
-
-	// Handle PTY size changes
-	go func() {
-		for {
-			if err := pty.InheritSize(os.Stdin, ptmx); err != nil {
-				logger.Error("error resizing pty: %v", err)
-			}
-		}
-	}()
-
-	// Copy data between connection and PTY
-	go func() {
-		if _, err := io.Copy(ptmx, conn); err != nil {
-			logger.Error("conn to pty copy error: %v", err)
-		}
-	}()
-
-	if _, err := io.Copy(conn, ptmx); err != nil {
-		return fmt.Errorf("pty to conn copy error: %v", err)
-	}
-
-	return nil
+	// Run the shell under a PTY whose size follows the peer's terminal
+	cfg := execConfig(interactiveShell())
+	cfg.PTY = true
+	_, err := runExec(conn, cfg)
+	return err
 }
 
 func handleLocalInteractive(conn net.Conn) error {
-	// Start goroutine to read from connection and write to stdout
+	// Typed lines reach the connection through the relay engine, so the
+	// session is recorded like any other
+	lines, typed := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	relayed := make(chan struct{})
 	go func() {
-		if _, err := io.Copy(os.Stdout, conn); err != nil {
+		defer close(relayed)
+		if _, err := runRelayContext(ctx, newRelay(), relay.Stdio(lines, os.Stdout), relay.Conn(conn)); err != nil && !errors.Is(err, context.Canceled) {
 			logger.Error("connection read error: %v", err)
 		}
+		// The next command fails to send once the peer is gone
+		lines.CloseWithError(net.ErrClosed)
+	}()
+	defer func() {
+		cancel()
+		typed.Close()
+		<-relayed
 	}()
 
 	theme := logger.GetCurrentTheme()
@@ -532,7 +510,7 @@ func handleLocalInteractive(conn net.Conn) error {
 			break
 		}
 
-		if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		if _, err := typed.Write([]byte(command + "\n")); err != nil {
 			return fmt.Errorf("failed to send command: %v", err)
 		}
 	}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/relay"
+)
+
+// handleUDPListener serves the peers of a UDP socket as pseudo-sessions.
+// Without -k it locks onto the first peer like a connected socket; with -k
+// every peer gets a session that ends after --udp-idle-timeout of silence.
+func handleUDPListener(netType, address string) error {
+	config := network.DefaultUDPListenerConfig()
+	config.Allow = allowUDPPeer
+	if listenKeepOpen {
+		config.IdleTimeout = listenUDPIdle
+	}
+	ln, err := network.ListenUDP(netType, address, config)
+	if err != nil {
+		return fmt.Errorf("failed to bind UDP: %v", err)
+	}
+	defer ln.Close()
+
+	theme := logger.GetCurrentTheme()
+	if _, err := theme.Success.Printf("Listening on %s (UDP)\n", address); err != nil {
+		logger.Error("Error printing success message: %v", err)
+	}
+
+	if !listenKeepOpen {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		// Datagrams of other peers are dropped from now on
+		ln.Close()
+		defer conn.Close()
+		handleConnection(conn)
+		return nil
+	}
+
+	if luaHandler == nil && len(listenExecArgs) == 0 && !listenUDPEcho && !interactive && !localOnly {
+		return serveUDPPeers(ln)
+	}
+
+	connSemaphore := make(chan struct{}, maxConnections)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		connSemaphore <- struct{}{}
+		go func(c net.Conn) {
+			defer func() {
+				c.Close()
+				<-connSemaphore
+			}()
+			handleConnection(c)
+		}(conn)
+	}
+}
+
+// allowUDPPeer applies the connection policy to the first datagram of a peer
+func allowUDPPeer(addr net.Addr) bool {
+	if connectionPolicy.allows(addr) {
+		return true
+	}
+	logger.Debug("Datagram from %s denied by access policy", addr)
+	return false
+}
+
+// handleEcho sends what the peer sends back to it
+func handleEcho(conn net.Conn) error {
+	engine := newRelay()
+	engine.BufferSize = network.MaxDatagramSize
+	engine.Mode = relay.ModeForward
+	runRelay(engine, relay.Conn(conn), relay.Conn(conn))
+	return nil
+}
+
+// udpPeers tracks the sessions of serveUDPPeers. The active peer is the one
+// heard from last; stdin goes to it.
+type udpPeers struct {
+	mu     sync.Mutex
+	active *udpPeer
+	output sync.Mutex // keeps the datagrams of concurrent peers whole
+}
+
+// udpPeer is a session of serveUDPPeers fed from stdin through a pipe
+type udpPeer struct {
+	conn  net.Conn
+	input *io.PipeWriter
+}
+
+// serveUDPPeers bridges stdin and the output with every peer of ln. What
+// the peers send is written as it arrives; each stdin line goes to the peer
+// heard from last.
+func serveUDPPeers(ln *network.UDPListener) error {
+	peers := &udpPeers{}
+	go peers.dispatch(os.Stdin)
+
+	theme := logger.GetCurrentTheme()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		if _, err := theme.Highlight.Printf("UDP session from %s\n", conn.RemoteAddr()); err != nil {
+			logger.Error("Error printing highlight message: %v", err)
+		}
+		go peers.serve(conn)
+	}
+}
+
+// serve relays one peer until its session expires
+func (p *udpPeers) serve(conn net.Conn) {
+	defer conn.Close()
+
+	input, inputWriter := io.Pipe()
+	peer := &udpPeer{conn: conn, input: inputWriter}
+	p.setActive(peer)
+
+	engine := newRelay()
+	engine.BufferSize = network.MaxDatagramSize
+	runRelay(engine, relay.Stdio(input, &udpPeerOutput{peers: p, peer: peer}), relay.Conn(conn))
+
+	// Unblock the stdin pump, which cannot be interrupted
+	input.Close()
+	p.mu.Lock()
+	if p.active == peer {
+		p.active = nil
+	}
+	p.mu.Unlock()
+	logger.Debug("UDP session with %s ended", conn.RemoteAddr())
+}
+
+func (p *udpPeers) setActive(peer *udpPeer) {
+	p.mu.Lock()
+	p.active = peer
+	p.mu.Unlock()
+}
+
+// dispatch sends each line of r to the active peer
+func (p *udpPeers) dispatch(r io.Reader) {
+	reader := bufio.NewReaderSize(r, network.MaxDatagramSize)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if len(line) > 0 {
+			p.mu.Lock()
+			peer := p.active
+			p.mu.Unlock()
+			if peer == nil {
+				logger.Warn("No UDP peer to send to yet, input dropped")
+			} else if _, err := peer.input.Write(line); err != nil {
+				logger.Warn("UDP session with %s ended, input dropped", peer.conn.RemoteAddr())
+			}
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			// Peers are still heard after stdin ends
+			return
+		}
+	}
+}
+
+// udpPeerOutput writes what a peer sends to the listen output and makes the
+// peer the active one
+type udpPeerOutput struct {
+	peers *udpPeers
+	peer  *udpPeer
+}
+
+func (w *udpPeerOutput) Write(b []byte) (int, error) {
+	w.peers.setActive(w.peer)
+	w.peers.output.Lock()
+	defer w.peers.output.Unlock()
+	return listenOutput().Write(b)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
@@ -36,6 +37,11 @@ Available metrics include:
   - Error counts
   - Request durations
   - System metrics (CPU, memory, goroutines)
+  - Connection worker and buffer pool usage
+
+Long-running commands (proxy, lb, multi-listen, convert, broker, chat,
+tunnel, dns-tunnel) export the same metrics for their own process with the global
+--metrics-addr flag.
 
 Examples:
   # Start metrics server on default port 9090
@@ -108,17 +114,52 @@ func runMetrics(cmd *cobra.Command, args []string) error {
 	}
 }
 
+// startMetricsExporter serves Prometheus metrics, including the usage of
+// the connection and buffer pools, on --metrics-addr if it is set
+func startMetricsExporter(cmd *cobra.Command) {
+	addr, _ := cmd.Root().PersistentFlags().GetString("metrics-addr")
+	if addr == "" {
+		return
+	}
+
+	listener, err := listenTCP("tcp", addr)
+	if err != nil {
+		logger.Fatal("Failed to start metrics exporter on %s: %v", addr, err)
+	}
+
+	pm := metrics.NewPrometheusMetrics(metricsNamespace, metricsSubsystem)
+	pm.RecordGauge("build_info", 1, map[string]string{
+		"version":    version,
+		"go_version": runtime.Version(),
+		"command":    cmd.Name(),
+	})
+	pm.RecordGauge("start_time_seconds", float64(time.Now().Unix()), nil)
+	go collectSystemMetrics(context.Background(), pm, metricsInterval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", pm)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logger.Error("Metrics exporter error: %v", err)
+		}
+	}()
+	logger.Info("Metrics exporter listening on http://%s/metrics", listener.Addr())
+}
+
 func collectSystemMetrics(ctx context.Context, pm *metrics.PrometheusMetrics, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	var memStats runtime.MemStats
+	recordPoolMetrics(pm)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			recordPoolMetrics(pm)
+
 			// Collect memory statistics
 			runtime.ReadMemStats(&memStats)
 
@@ -1,45 +1,81 @@
 package cmd
 
 import (
-	"io"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
-	"os/exec"
-	"runtime"
-	"strconv"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/ibrahmsql/gocat/internal/admin"
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/ibrahmsql/gocat/internal/scripting"
+	"github.com/ibrahmsql/gocat/internal/services"
 	"github.com/spf13/cobra"
 )
 
 var (
-	multiPorts       []string
-	multiPortRange   string
-	multiExec        string
-	multiMaxConns    int
-	multiTimeout     time.Duration
-	multiShowStats   bool
-	multiBindAddress string
+	multiPorts         []string
+	multiPortRange     string
+	multiExec          string
+	multiMaxConns      int
+	multiTimeout       time.Duration
+	multiShowStats     bool
+	multiStatsInterval time.Duration
+	multiStatsJSON     bool
+	multiBindAddress   string
+	multiConfigPath    string
 )
 
 type multiListenStats struct {
-	portStats map[int]*portStats
+	portStats map[string]*portStats // by endpoint key
 	mu        sync.RWMutex
 }
 
 type portStats struct {
-	Port            int
-	TotalConns      int64
-	ActiveConns     int64
-	BytesReceived   int64
-	BytesSent       int64
-	LastConnection  time.Time
+	Port           int       `json:"port"`
+	Protocol       string    `json:"protocol"`
+	Address        string    `json:"address"`
+	Service        string    `json:"service"`
+	Handler        string    `json:"handler"`
+	TotalConns     int64     `json:"total_connections"`
+	ActiveConns    int64     `json:"active_connections"`
+	Rejected       int64     `json:"rejected_connections"`
+	BytesReceived  int64     `json:"bytes_received"`
+	BytesSent      int64     `json:"bytes_sent"`
+	LastConnection time.Time `json:"last_connection"`
 }
 
 var mlStats = &multiListenStats{
-	portStats: make(map[int]*portStats),
+	portStats: make(map[string]*portStats),
+}
+
+// snapshot returns the stats of every port, ordered by port and protocol
+func (s *multiListenStats) snapshot() []portStats {
+	s.mu.RLock()
+	ports := make([]portStats, 0, len(s.portStats))
+	for _, stats := range s.portStats {
+		ports = append(ports, *stats)
+	}
+	s.mu.RUnlock()
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+	return ports
 }
 
 var multiListenCmd = &cobra.Command{
@@ -49,6 +85,42 @@ var multiListenCmd = &cobra.Command{
 	Long: `Listen on multiple ports at the same time and handle connections.
 Supports port ranges, individual ports, and different handlers per port.
 
+Without --config every port echoes, or runs the --exec command. With
+--config a YAML file maps ports and ranges to services, each with its own
+handler, protocol (tcp, udp or tls), access rules and limits:
+
+  bind: 0.0.0.0
+  services:
+    - name: ssh
+      ports: 22
+      handler: banner
+      banner: "SSH-2.0-OpenSSH_9.6\r\n"
+    - name: dns
+      ports: 53
+      protocol: udp
+      handler: forward
+      forward_to: 10.0.0.53:53
+    - name: web
+      ports: 8443
+      protocol: tls
+      cert: server.pem
+      key: server.key
+      handler: http
+      root: ./public
+      allow: [10.0.0.0/8]
+      max_connections: 100
+      timeout: 30s
+
+Handlers are echo, discard, chargen, daytime, banner (banner), exec (exec
+or sh_exec), forward (forward_to), lua (script) and http (root). UDP
+sessions end after idle_timeout, 2m by default.
+
+On SIGHUP, or the reload admin method, the file is read again: ports that
+stay keep their connections and take the new settings for new ones, ports
+that go are closed and new ports are opened. Per-port statistics are
+printed every --stats-interval, as JSON lines with --stats-json, and
+returned by the stats admin method.
+
 Examples:
   # Listen on multiple ports
   gocat multi-listen --ports 8080,8081,8082
@@ -61,6 +133,10 @@ Examples:
 
   # With connection limits
   gocat multi-listen --range 8000-8010 --max-connections 1000
+
+  # Fake services from a file, reloaded on SIGHUP
+  gocat multi-listen --config services.yaml --stats-json --admin-socket /tmp/ml.sock
+  gocat ctl -S /tmp/ml.sock reload
 `,
 	Run: runMultiListen,
 }
@@ -75,287 +151,600 @@ func init() {
 	multiListenCmd.Flags().IntVar(&multiMaxConns, "max-connections", 1000, "Maximum concurrent connections per port")
 	multiListenCmd.Flags().DurationVar(&multiTimeout, "timeout", 0, "Connection timeout (0 = no timeout)")
 	multiListenCmd.Flags().BoolVar(&multiShowStats, "stats", true, "Show statistics")
+	multiListenCmd.Flags().DurationVar(&multiStatsInterval, "stats-interval", 30*time.Second, "Time between statistics reports")
+	multiListenCmd.Flags().BoolVar(&multiStatsJSON, "stats-json", false, "Report statistics as JSON lines")
 	multiListenCmd.Flags().StringVar(&multiBindAddress, "bind", "0.0.0.0", "Bind address")
+	multiListenCmd.Flags().StringVar(&multiConfigPath, "config", "", "YAML file of services per port (reloaded on SIGHUP)")
 }
 
-// runMultiListen parses configured ports and port ranges, starts a listener on each port, and waits for all listeners to finish.
+// runMultiListen reads the services from --config, or builds one from the port flags, opens a listener
+// for each of their ports and serves them until SIGINT or SIGTERM.
 //
-// It reads port configuration from package-level flags (individual ports and a range), exits with a fatal log on invalid or missing port configuration, optionally starts the periodic statistics reporter, launches one listener goroutine per port, and blocks until those listeners exit (typically via interruption).
+// It exits with a fatal log on an invalid configuration or when no port could be opened, optionally starts
+// the periodic statistics reporter and, with --config, reloads the services on SIGHUP.
 func runMultiListen(cmd *cobra.Command, args []string) {
-	// Parse ports
-	var ports []int
-	
-	// Add individual ports
-	for _, portStr := range multiPorts {
-		port, err := strconv.Atoi(strings.TrimSpace(portStr))
-		if err != nil {
-			logger.Fatal("Invalid port: %s", portStr)
+	var config *services.Config
+	var err error
+	if multiConfigPath != "" {
+		if len(multiPorts) > 0 || multiPortRange != "" {
+			logger.Fatal("--config cannot be combined with --ports or --range")
 		}
-		ports = append(ports, port)
+		config, err = services.Load(multiConfigPath)
+	} else {
+		config, err = flagServices(cmd)
+	}
+	if err != nil {
+		logger.Fatal("Invalid services: %v", err)
 	}
+	endpoints, _ := config.Endpoints()
+
+	logger.Info("Starting multi-port listener on %d ports", len(endpoints))
+
+	loadConnectionPolicy(cmd)
+	startAdminSocket(cmd, registerMultiListenAdmin)
+	startMetricsExporter(cmd)
+
+	if _, err := multiHost.apply(config); err != nil {
+		logger.Fatal("Invalid services: %v", err)
+	}
+	if multiHost.size() == 0 {
+		logger.Fatal("No port could be opened")
+	}
+	if multiConfigPath != "" {
+		go reloadOnHangup()
+	}
+
+	// Start stats reporter if enabled
+	if multiShowStats {
+		go reportMultiListenStats()
+	}
+
+	logger.Info("All listeners started. Press Ctrl+C to stop.")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	multiHost.closeAll()
+}
 
-	// Add port range
+// flagServices builds the service of the --ports, --range, --exec,
+// --max-connections, --timeout and --bind flags: echo, or the command, on
+// every port, over UDP with -u
+func flagServices(cmd *cobra.Command) (*services.Config, error) {
+	ports := strings.Join(multiPorts, ",")
 	if multiPortRange != "" {
-		rangePorts, err := parsePortRange(multiPortRange)
+		ports = strings.TrimPrefix(ports+","+multiPortRange, ",")
+	}
+	if ports == "" {
+		return nil, errors.New("no ports specified, use --ports, --range or --config")
+	}
+
+	service := &services.Service{
+		Ports:          ports,
+		Handler:        services.Echo,
+		MaxConnections: multiMaxConns,
+		Timeout:        multiTimeout,
+	}
+	flags := cmd.Root().PersistentFlags()
+	if shExec, _ := flags.GetString("sh-exec"); shExec != "" {
+		service.Handler, service.ShExec = services.Exec, shExec
+	} else if multiExec != "" {
+		service.Handler, service.Exec = services.Exec, multiExec
+	}
+	if udp, _ := flags.GetBool("udp"); udp {
+		service.Protocol = services.UDP
+	}
+
+	config := &services.Config{Bind: multiBindAddress, Services: []*services.Service{service}}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// serviceHost runs the listeners of multi-listen. Applying a configuration
+// hands the ports that stay their new service, closes the ports that go
+// and opens the new ones.
+type serviceHost struct {
+	mu        sync.Mutex
+	listeners map[string]*portListener // by endpoint key
+}
+
+var multiHost = &serviceHost{listeners: make(map[string]*portListener)}
+
+// hostedService is a service with its handler ready to run
+type hostedService struct {
+	*services.Service
+	handle func(net.Conn) error
+	cert   *tls.Certificate // of TLS services
+}
+
+// reloadResult lists the endpoints a configuration started, stopped and
+// kept, and those that could not be opened
+type reloadResult struct {
+	Started []string `json:"started"`
+	Stopped []string `json:"stopped"`
+	Kept    []string `json:"kept"`
+	Failed  []string `json:"failed,omitempty"`
+}
+
+// apply switches the host to config. Every handler is prepared first, so
+// an invalid configuration leaves the running services untouched; ports
+// that fail to open are reported in the result.
+func (h *serviceHost) apply(config *services.Config) (*reloadResult, error) {
+	endpoints, err := config.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	hosted := make(map[*services.Service]*hostedService)
+	for _, service := range config.Services {
+		hs, err := newHostedService(service)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", service.Name, err)
+		}
+		hosted[service] = hs
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := &reloadResult{}
+	wanted := make(map[string]services.Endpoint, len(endpoints))
+	for _, e := range endpoints {
+		wanted[e.Key()] = e
+	}
+	for key, pl := range h.listeners {
+		e, ok := wanted[key]
+		if ok && sameSocket(pl.service.Load().Service, e.Service) {
+			continue
+		}
+		pl.close()
+		delete(h.listeners, key)
+		if !ok {
+			result.Stopped = append(result.Stopped, key)
+		}
+	}
+
+	for _, e := range endpoints {
+		hs := hosted[e.Service]
+		if pl, ok := h.listeners[e.Key()]; ok {
+			pl.setService(hs)
+			result.Kept = append(result.Kept, e.Key())
+			continue
+		}
+		pl, err := openPortListener(e, hs)
 		if err != nil {
-			logger.Fatal("Invalid port range: %v", err)
+			logger.Error("Failed to listen on %s: %v", e.Key(), err)
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", e.Key(), err))
+			continue
 		}
-		ports = append(ports, rangePorts...)
+		h.listeners[e.Key()] = pl
+		result.Started = append(result.Started, e.Key())
+		go pl.serve()
 	}
+	sort.Strings(result.Stopped)
+	return result, nil
+}
 
-	if len(ports) == 0 {
-		logger.Fatal("No ports specified. Use --ports or --range")
+// sameSocket reports whether a port can pass from service a to b without
+// being opened again
+func sameSocket(a, b *services.Service) bool {
+	if a.Protocol != b.Protocol {
+		return false
 	}
+	return a.Protocol != services.UDP || a.IdleTimeout == b.IdleTimeout
+}
 
-	logger.Info("Starting multi-port listener on %d ports", len(ports))
+func (h *serviceHost) size() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.listeners)
+}
 
-	// Start stats reporter if enabled
-	if multiShowStats {
-		go reportMultiListenStats()
+// closeAll closes every port and its connections
+func (h *serviceHost) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, pl := range h.listeners {
+		pl.close()
+		delete(h.listeners, key)
+	}
+}
+
+// newHostedService prepares the handler of a service: compiles its Lua
+// script, loads its certificate and so on
+func newHostedService(s *services.Service) (*hostedService, error) {
+	hs := &hostedService{Service: s}
+	datagram := s.Protocol == services.UDP
+
+	switch s.Handler {
+	case services.Echo:
+		hs.handle = func(c net.Conn) error { return services.ServeEcho(c, datagram) }
+	case services.Discard:
+		hs.handle = services.ServeDiscard
+	case services.Chargen:
+		hs.handle = func(c net.Conn) error { return services.ServeChargen(c, datagram) }
+	case services.Daytime:
+		hs.handle = func(c net.Conn) error { return services.ServeDaytime(c, datagram) }
+	case services.Banner:
+		hs.handle = func(c net.Conn) error { return services.ServeBanner(c, s.Banner, datagram) }
+	case services.Exec:
+		args := strings.Fields(s.Exec)
+		if s.ShExec != "" {
+			args = shellCommand(s.ShExec)
+		}
+		hs.handle = func(c net.Conn) error {
+			if _, err := runExec(c, execConfig(args)); err != nil {
+				logger.Error("Failed to run %s: %v", args[0], err)
+			}
+			return nil
+		}
+	case services.Forward:
+		network, target := s.Protocol.Network(), s.ForwardTo
+		hs.handle = func(c net.Conn) error {
+			forwardConn(c, network, target)
+			return nil
+		}
+	case services.Lua:
+		config, err := luaEngineConfig(s.Script)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Lua handler: %w", err)
+		}
+		handler, err := scripting.NewConnHandler(s.Script, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Lua handler: %w", err)
+		}
+		hs.handle = handler.Handle
+	case services.HTTP:
+		if info, err := os.Stat(s.Root); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", s.Root)
+		}
+		hs.handle = services.FileServer(s.Root)
+	default:
+		return nil, fmt.Errorf("unknown handler %q", s.Handler)
 	}
 
-	// Start listeners
-	var wg sync.WaitGroup
-	for _, port := range ports {
-		wg.Add(1)
-		go func(p int) {
-			defer wg.Done()
-			startPortListener(p)
-		}(port)
+	if s.Protocol == services.TLS {
+		cert, err := tls.LoadX509KeyPair(s.Cert, s.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		hs.cert = &cert
 	}
+	return hs, nil
+}
 
-	logger.Info("All listeners started. Press Ctrl+C to stop.")
-	wg.Wait()
-}
-
-// startPortListener starts a TCP listener bound to the provided port and runs an accept loop.
-// 
-// It initializes per-port statistics, enforces a concurrency limit using a semaphore sized by
-// multiMaxConns, and spawns a goroutine for each accepted connection that delegates handling to
-// handleMultiListenConnection. If the listener cannot be created the function logs the error and returns.
-func startPortListener(port int) {
-	address := net.JoinHostPort(multiBindAddress, strconv.Itoa(port))
-	
-	listener, err := net.Listen("tcp", address)
+// forwardConn relays conn to target, passing the client's address on in a
+// PROXY header when --send-proxy-protocol is set
+func forwardConn(conn net.Conn, network, target string) {
+	upstream, err := dialOut(network, target)
 	if err != nil {
-		logger.Error("Failed to listen on port %d: %v", port, err)
+		logger.Error("Failed to connect to %s: %v", target, dialCause(err))
 		return
 	}
-	defer listener.Close()
+	defer upstream.Close()
+	if network == "tcp" {
+		if err := sendProxyHeader(upstream, conn.RemoteAddr(), conn.LocalAddr()); err != nil {
+			logger.Error("Failed to send PROXY header to %s: %v", target, err)
+			return
+		}
+	}
+	relayConns(conn, upstream)
+}
 
-	// Initialize stats
-	mlStats.mu.Lock()
-	mlStats.portStats[port] = &portStats{
-		Port: port,
+// portListener serves the service of one endpoint
+type portListener struct {
+	key     string
+	ln      net.Listener
+	service atomic.Pointer[hostedService]
+	stats   *portStats
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// openPortListener opens the socket of an endpoint. TCP and TLS ports go
+// through the global connection policy, UDP peers are filtered by it as
+// their first datagram arrives.
+func openPortListener(e services.Endpoint, hs *hostedService) (*portListener, error) {
+	pl := &portListener{
+		key:   e.Key(),
+		conns: make(map[net.Conn]struct{}),
+		stats: &portStats{Port: e.Port, Protocol: string(hs.Protocol), Address: e.Address()},
 	}
+	pl.service.Store(hs)
+
+	var ln net.Listener
+	var err error
+	if hs.Protocol == services.UDP {
+		config := network.DefaultUDPListenerConfig()
+		config.Allow = allowUDPPeer
+		config.IdleTimeout = hs.IdleTimeout
+		ln, err = network.ListenUDP("udp", e.Address(), config)
+	} else {
+		ln, err = listenGuarded(e.Address())
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Traffic is counted on the wire, below TLS
+	ln = &countedListener{Listener: ln, stats: pl.stats}
+	if hs.Protocol == services.TLS {
+		ln = tls.NewListener(ln, &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: pl.certificate,
+		})
+	}
+	pl.ln = ln
+
+	pl.setService(hs)
+	mlStats.mu.Lock()
+	mlStats.portStats[pl.key] = pl.stats
 	mlStats.mu.Unlock()
 
-	logger.Info("Listening on %s", address)
+	logger.Info("Listening on %s (%s, %s)", e.Address(), hs.Protocol, hs.Name)
+	return pl, nil
+}
+
+// setService hands new connections to hs
+func (pl *portListener) setService(hs *hostedService) {
+	pl.service.Store(hs)
+	mlStats.mu.Lock()
+	pl.stats.Service = hs.Name
+	pl.stats.Handler = string(hs.Handler)
+	mlStats.mu.Unlock()
+}
 
-	// Connection semaphore
-	connSemaphore := make(chan struct{}, multiMaxConns)
+// certificate returns the certificate of the current service, so reloads
+// pick up renewed certificates
+func (pl *portListener) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := pl.service.Load().cert; cert != nil {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("%s is not a TLS service", pl.key)
+}
 
+// serve accepts connections until the port is closed
+func (pl *portListener) serve() {
 	for {
-		conn, err := listener.Accept()
+		conn, err := pl.ln.Accept()
 		if err != nil {
-			logger.Error("Accept error on port %d: %v", port, err)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logger.Error("Accept error on %s: %v", pl.key, err)
 			continue
 		}
 
-		// Acquire semaphore
-		connSemaphore <- struct{}{}
+		hs := pl.service.Load()
+		if !pl.admit(conn, hs) {
+			conn.Close()
+			continue
+		}
+		err = serveConn(conn, func(c net.Conn) {
+			defer c.Close()
+			handleMultiListenConnection(c, pl, hs)
+		})
+		if err != nil {
+			pl.release(conn)
+		}
+	}
+}
 
-		go func(c net.Conn, p int) {
-			defer func() {
-				c.Close()
-				<-connSemaphore
-			}()
-			handleMultiListenConnection(c, p)
-		}(conn, port)
+// admit applies the access rules and connection limit of the service to a
+// new connection and counts it
+func (pl *portListener) admit(conn net.Conn, hs *hostedService) bool {
+	if acl := hs.AccessControl(); acl != nil && !acl.IsAllowed(conn.RemoteAddr()) {
+		logger.Warn("Connection from %s to %s denied by service %s", conn.RemoteAddr(), pl.key, hs.Name)
+		pl.reject()
+		return false
 	}
+
+	mlStats.mu.Lock()
+	if hs.MaxConnections > 0 && pl.stats.ActiveConns >= int64(hs.MaxConnections) {
+		pl.stats.Rejected++
+		mlStats.mu.Unlock()
+		logger.Warn("Connection from %s to %s rejected: %d connections open", conn.RemoteAddr(), pl.key, hs.MaxConnections)
+		return false
+	}
+	pl.stats.TotalConns++
+	pl.stats.ActiveConns++
+	pl.stats.LastConnection = time.Now()
+	mlStats.mu.Unlock()
+
+	pl.mu.Lock()
+	pl.conns[conn] = struct{}{}
+	pl.mu.Unlock()
+	return true
 }
 
-// handleMultiListenConnection updates per-port statistics, applies an optional
-// connection timeout, and dispatches the connection to the configured handler.
-//
-// It increments TotalConns and ActiveConns and sets LastConnection for the
-// given port, and decrements ActiveConns when the connection handling finishes.
-// If multiTimeout is greater than zero, it sets a deadline on the connection.
-// If multiExec is non-empty the connection is handled by the exec handler;
-// otherwise it is handled in echo mode.
-//
-// conn is the accepted network connection. port is the listening port associated
-// with this connection.
-func handleMultiListenConnection(conn net.Conn, port int) {
-	// Update stats
+func (pl *portListener) reject() {
 	mlStats.mu.Lock()
-	if stats, ok := mlStats.portStats[port]; ok {
-		stats.TotalConns++
-		stats.ActiveConns++
-		stats.LastConnection = time.Now()
+	pl.stats.Rejected++
+	mlStats.mu.Unlock()
+}
+
+// release ends the count of an admitted connection
+func (pl *portListener) release(conn net.Conn) {
+	pl.mu.Lock()
+	delete(pl.conns, conn)
+	pl.mu.Unlock()
+
+	mlStats.mu.Lock()
+	pl.stats.ActiveConns--
+	mlStats.mu.Unlock()
+}
+
+// close closes the port and its connections and forgets its stats
+func (pl *portListener) close() {
+	pl.ln.Close()
+	pl.mu.Lock()
+	for conn := range pl.conns {
+		conn.Close()
 	}
+	pl.mu.Unlock()
+
+	mlStats.mu.Lock()
+	delete(mlStats.portStats, pl.key)
 	mlStats.mu.Unlock()
+	logger.Info("Closed %s", pl.key)
+}
 
-	defer func() {
-		mlStats.mu.Lock()
-		if stats, ok := mlStats.portStats[port]; ok {
-			stats.ActiveConns--
-		}
-		mlStats.mu.Unlock()
-	}()
+// handleMultiListenConnection applies the service's connection timeout and
+// runs its handler for conn, releasing the connection's slot afterwards.
+// Handler errors, mostly peers going away, are logged at debug level.
+func handleMultiListenConnection(conn net.Conn, pl *portListener, hs *hostedService) {
+	defer pl.release(conn)
 
 	// Set timeout if specified
-	if multiTimeout > 0 {
-		conn.SetDeadline(time.Now().Add(multiTimeout))
+	if hs.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(hs.Timeout))
 	}
 
-	logger.Debug("Connection on port %d from %s", port, conn.RemoteAddr())
+	logger.Debug("Connection on %s from %s (%s)", pl.key, conn.RemoteAddr(), hs.Name)
 
-	if multiExec != "" {
-		// Execute command
-		handleExecConnection(conn, port)
-	} else {
-		// Echo mode
-		handleEchoConnection(conn, port)
+	if err := hs.handle(conn); err != nil && !isClosedError(err) {
+		logger.Debug("%s on %s: %v", hs.Name, pl.key, err)
 	}
 }
 
-// handleExecConnection runs a configured command (or the platform default shell) for the given connection
-// and wires the network stream to the process stdio.
-//
-// It sends data from the connection into the process stdin and forwards both stdout and stderr back to
-// the connection. Bytes forwarded from stdout and stderr are added to mlStats.portStats[port].BytesSent.
-// The function waits for all I/O copying to complete and for the process to exit. Pipe creation or
-// command start failures are logged and cause the function to return.
-func handleExecConnection(conn net.Conn, port int) {
-	shell := multiExec
-	if shell == "" {
-		if runtime.GOOS == "windows" {
-			shell = "cmd.exe"
-		} else {
-			shell = "/bin/sh"
-		}
-	}
+// countedListener adds the traffic of its connections to the stats of a port
+type countedListener struct {
+	net.Listener
+	stats *portStats
+}
 
-	cmd := exec.Command(shell)
-	
-	stdin, err := cmd.StdinPipe()
+func (l *countedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
 	if err != nil {
-		logger.Error("Failed to create stdin pipe: %v", err)
-		return
+		return nil, err
 	}
+	return &countedConn{Conn: conn, stats: l.stats}, nil
+}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		logger.Error("Failed to create stdout pipe: %v", err)
-		return
-	}
+// countedConn adds its traffic to the stats of its port
+type countedConn struct {
+	net.Conn
+	stats *portStats
+}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		logger.Error("Failed to create stderr pipe: %v", err)
-		return
-	}
+func (c *countedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.CountRead(int64(n))
+	return n, err
+}
 
-	if err := cmd.Start(); err != nil {
-		logger.Error("Failed to start command: %v", err)
-		return
-	}
+func (c *countedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.CountWritten(int64(n))
+	return n, err
+}
 
-	// Copy data
-	var wg sync.WaitGroup
-	wg.Add(3)
+// CloseWrite half-closes the connection below when it can
+func (c *countedConn) CloseWrite() error {
+	if hc, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return hc.CloseWrite()
+	}
+	return relay.ErrNoHalfClose
+}
 
-	go func() {
-		defer wg.Done()
-		io.Copy(stdin, conn)
-		stdin.Close()
-	}()
+// Unwrap lets relays splice the connection below
+func (c *countedConn) Unwrap() net.Conn { return c.Conn }
 
-	go func() {
-		defer wg.Done()
-		n, _ := io.Copy(conn, stdout)
+func (c *countedConn) CountRead(n int64) {
+	if n > 0 {
 		mlStats.mu.Lock()
-		if stats, ok := mlStats.portStats[port]; ok {
-			stats.BytesSent += n
-		}
+		c.stats.BytesReceived += n
 		mlStats.mu.Unlock()
-	}()
+	}
+}
 
-	go func() {
-		defer wg.Done()
-		n, _ := io.Copy(conn, stderr)
+func (c *countedConn) CountWritten(n int64) {
+	if n > 0 {
 		mlStats.mu.Lock()
-		if stats, ok := mlStats.portStats[port]; ok {
-			stats.BytesSent += n
-		}
+		c.stats.BytesSent += n
 		mlStats.mu.Unlock()
-	}()
-
-	wg.Wait()
-	cmd.Wait()
+	}
 }
 
-// The loop stops on EOF or on read/write errors (which are logged at debug level).
-func handleEchoConnection(conn net.Conn, port int) {
-	buf := make([]byte, 4096)
-	for {
-		n, err := conn.Read(buf)
-		if err != nil {
-			if err != io.EOF {
-				logger.Debug("Read error on port %d: %v", port, err)
-			}
-			break
-		}
-
-		mlStats.mu.Lock()
-		if stats, ok := mlStats.portStats[port]; ok {
-			stats.BytesReceived += int64(n)
-		}
-		mlStats.mu.Unlock()
+// reloadServices reads --config again and applies it
+func reloadServices() (*reloadResult, error) {
+	if multiConfigPath == "" {
+		return nil, errors.New("services come from flags, start with --config to reload them")
+	}
+	config, err := services.Load(multiConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	return multiHost.apply(config)
+}
 
-		// Echo back
-		written, err := conn.Write(buf[:n])
+// reloadOnHangup reloads the services on SIGHUP
+func reloadOnHangup() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		result, err := reloadServices()
 		if err != nil {
-			logger.Debug("Write error on port %d: %v", port, err)
-			break
-		}
-
-		mlStats.mu.Lock()
-		if stats, ok := mlStats.portStats[port]; ok {
-			stats.BytesSent += int64(written)
+			logger.Error("Reload failed, the running services are kept: %v", err)
+			continue
 		}
-		mlStats.mu.Unlock()
+		logger.Info("Services reloaded: %d started, %d stopped, %d kept, %d failed",
+			len(result.Started), len(result.Stopped), len(result.Kept), len(result.Failed))
 	}
 }
 
-// reportMultiListenStats periodically prints per-port listener statistics.
-// It emits, every 30 seconds, a summary for each tracked port including total
-// connections, active connections, bytes received, bytes sent, and the time of
-// the last connection.
+// reportMultiListenStats prints the per-port statistics every
+// --stats-interval: total and active connections, rejections, bytes
+// received and sent and the time of the last connection, or with
+// --stats-json the stats method's JSON on one line.
 func reportMultiListenStats() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(multiStatsInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		mlStats.mu.RLock()
+		ports := mlStats.snapshot()
+		if multiStatsJSON {
+			line, err := json.Marshal(ports)
+			if err != nil {
+				logger.Error("Failed to encode statistics: %v", err)
+				continue
+			}
+			fmt.Println(string(line))
+			continue
+		}
+
 		theme := logger.GetCurrentTheme()
 		theme.Info.Println("\n╔═══════════════════════════════════════════════════════════════╗")
 		theme.Info.Println("║          Multi-Port Listener Statistics                    ║")
 		theme.Info.Println("╠═══════════════════════════════════════════════════════════════╣")
-		
-		for port, stats := range mlStats.portStats {
-			theme.Success.Printf("║ Port %-5d │ ", port)
+
+		for _, stats := range ports {
+			theme.Success.Printf("║ %s %-5d │ %-10s │ ", stats.Protocol, stats.Port, stats.Service)
 			theme.Highlight.Printf("Total: %-6d │ ", stats.TotalConns)
-			theme.Info.Printf("Active: %-4d │ ", stats.ActiveConns)
+			theme.Info.Printf("Active: %-4d │ Rejected: %-4d │ ", stats.ActiveConns, stats.Rejected)
 			theme.Debug.Printf("RX: %-8d │ TX: %-8d ║\n", stats.BytesReceived, stats.BytesSent)
 			if !stats.LastConnection.IsZero() {
 				theme.Debug.Printf("║           └─ Last connection: %s                          ║\n",
 					stats.LastConnection.Format("2006-01-02 15:04:05"))
 			}
 		}
-		
+
 		theme.Info.Println("╚═══════════════════════════════════════════════════════════════╝")
-		mlStats.mu.RUnlock()
 	}
-}
\ No newline at end of file
+}
+
+// registerMultiListenAdmin adds the per-port statistics and reloading to
+// the admin API
+func registerMultiListenAdmin(srv *admin.Server) {
+	srv.Handle("stats", "Show per-port service, connection and traffic totals", func(json.RawMessage) (interface{}, error) {
+		return mlStats.snapshot(), nil
+	})
+	srv.Handle("reload", "Read the --config file again and apply it", func(json.RawMessage) (interface{}, error) {
+		result, err := reloadServices()
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Services reloaded via admin API")
+		return result, nil
+	})
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/buffer"
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/metrics"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/worker"
+)
+
+const (
+	// maxPooledConns bounds the connection handlers running at once
+	maxPooledConns = 10000
+
+	// relayBufferSize matches the buffer io.Copy would allocate
+	relayBufferSize = 32 * 1024
+)
+
+var (
+	connPool     atomic.Pointer[worker.WorkerPool]
+	connPoolOnce sync.Once
+)
+
+// poolStats is the pool usage reported by the admin API
+type poolStats struct {
+	Connections *worker.PoolStats       `json:"connections,omitempty"`
+	Buffers     buffer.PoolStats        `json:"buffers"`
+	Upstreams   *network.UpstreamsStats `json:"upstreams,omitempty"`
+}
+
+// connWorkers returns the worker pool that runs the connection handlers of
+// the long-running commands, starting it on first use
+func connWorkers() *worker.WorkerPool {
+	if pool := connPool.Load(); pool != nil {
+		return pool
+	}
+	connPoolOnce.Do(func() {
+		connPool.Store(worker.NewWorkerPool(&worker.PoolConfig{
+			MinWorkers:    runtime.NumCPU(),
+			MaxWorkers:    maxPooledConns,
+			QueueSize:     1024,
+			IdleTimeout:   30 * time.Second,
+			ScaleInterval: 5 * time.Second,
+		}))
+	})
+	return connPool.Load()
+}
+
+// serveConn runs handler for conn on the connection pool. It blocks while
+// the queue is full, so a saturated pool pushes back on the accept loop; if
+// the pool is shut down the connection is closed and the error returned.
+func serveConn(conn net.Conn, handler func(net.Conn)) error {
+	err := connWorkers().SubmitWait(context.Background(), &worker.TaskFunc{
+		ID: conn.RemoteAddr().String(),
+		Fn: func(context.Context) error {
+			handler(conn)
+			return nil
+		},
+	})
+	if err != nil {
+		logger.Warn("Rejecting %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+	}
+	return err
+}
+
+// relayCopy copies src to dst through a buffer from the shared pool
+func relayCopy(dst io.Writer, src io.Reader) (int64, error) {
+	return buffer.Shared().Copy(dst, src, relayBufferSize)
+}
+
+// currentPoolStats returns the usage of the shared pools
+func currentPoolStats() poolStats {
+	stats := poolStats{Buffers: buffer.Shared().GetStats()}
+	if pool := connPool.Load(); pool != nil {
+		workers := pool.GetStats()
+		stats.Connections = &workers
+	}
+	if u := upstreamLayer.Load(); u != nil {
+		backends := u.Stats()
+		stats.Upstreams = &backends
+	}
+	return stats
+}
+
+// recordPoolMetrics exports the usage of the shared pools, and the
+// backends of lb when it is running
+func recordPoolMetrics(pm *metrics.PrometheusMetrics) {
+	stats := currentPoolStats()
+	pm.RecordBufferPoolStats("shared", stats.Buffers)
+	if stats.Connections != nil {
+		pm.RecordWorkerPoolStats("connections", *stats.Connections)
+	}
+	if stats.Upstreams != nil {
+		pm.RecordUpstreamStats(*stats.Upstreams)
+	}
+	if lb := lbState.balancer; lb != nil {
+		pm.RecordBalancerStats(lb.Stats())
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/services"
+)
+
+// benchConns is the number of concurrent connections per benchmark round
+const benchConns = 10000
+
+var benchPayload = bytes.Repeat([]byte("gocat"), 200)
+
+func TestServeConnRelay(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	done := make(chan struct{})
+	if err := serveConn(server, func(c net.Conn) {
+		defer close(done)
+		defer c.Close()
+		relayCopy(c, c)
+	}); err != nil {
+		t.Fatalf("serveConn failed: %v", err)
+	}
+
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+	go client.Write(benchPayload)
+	reply := make([]byte, len(benchPayload))
+	if _, err := io.ReadFull(client, reply); err != nil || !bytes.Equal(reply, benchPayload) {
+		t.Fatalf("relay echoed %q, %v", reply, err)
+	}
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after the peer closed")
+	}
+	if stats := currentPoolStats(); stats.Connections == nil || stats.Connections.TotalTasks == 0 {
+		t.Errorf("pool stats = %+v", stats)
+	}
+}
+
+// benchmarkConns opens benchConns connections at once per round, hands the
+// server side to serve and waits until every client got its payload echoed
+func benchmarkConns(b *testing.B, serve func(net.Conn)) {
+	b.ReportAllocs()
+	b.SetBytes(int64(benchConns * len(benchPayload)))
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(benchConns)
+		for c := 0; c < benchConns; c++ {
+			client, server := net.Pipe()
+			serve(server)
+			go func() {
+				defer wg.Done()
+				defer client.Close()
+				reply := make([]byte, len(benchPayload))
+				go client.Write(benchPayload)
+				if _, err := io.ReadFull(client, reply); err != nil {
+					b.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkEcho10kConns compares the multi-listen echo handler on the pools
+// with a goroutine and a fresh buffer per connection
+func BenchmarkEcho10kConns(b *testing.B) {
+	b.Run("goroutine", func(b *testing.B) {
+		benchmarkConns(b, func(conn net.Conn) {
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 4096)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					if _, err := conn.Write(buf[:n]); err != nil {
+						return
+					}
+				}
+			}()
+		})
+	})
+	b.Run("pooled", func(b *testing.B) {
+		benchmarkConns(b, func(conn net.Conn) {
+			serveConn(conn, func(c net.Conn) {
+				defer c.Close()
+				services.ServeEcho(c, false)
+			})
+		})
+	})
+}
+
+// BenchmarkRelay10kConns compares the pooled relay copy with io.Copy, which
+// allocates a 32KB buffer per call
+func BenchmarkRelay10kConns(b *testing.B) {
+	b.Run("goroutine", func(b *testing.B) {
+		benchmarkConns(b, func(conn net.Conn) {
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		})
+	})
+	b.Run("pooled", func(b *testing.B) {
+		benchmarkConns(b, func(conn net.Conn) {
+			serveConn(conn, func(c net.Conn) {
+				defer c.Close()
+				relayCopy(c, c)
+			})
+		})
+	})
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -11,7 +14,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/ibrahmsql/gocat/internal/admin"
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
 	"github.com/spf13/cobra"
 )
 
@@ -56,6 +61,17 @@ var proxyStats = &ProxyStats{
 	BackendStats: make(map[string]*BackendStats),
 }
 
+// proxyBalancer picks backends for the running proxy
+var proxyBalancer *loadBalancer
+
+// backendStats returns the statistics of a backend
+func (s *ProxyStats) backendStats(backend string) (*BackendStats, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats, ok := s.BackendStats[backend]
+	return stats, ok
+}
+
 var proxyCmd = &cobra.Command{
 	Use:     "proxy",
 	Aliases: []string{"p", "reverse-proxy"},
@@ -98,6 +114,7 @@ func init() {
 	proxyCmd.Flags().BoolVar(&proxySSL, "ssl", false, "Enable SSL/TLS")
 	proxyCmd.Flags().StringVar(&proxySSLCert, "cert", "", "SSL certificate file")
 	proxyCmd.Flags().StringVar(&proxySSLKey, "key", "", "SSL key file")
+	addUpstreamFlags(proxyCmd)
 }
 
 // runProxy starts the reverse proxy server according to CLI configuration.
@@ -128,34 +145,55 @@ func runProxy(cmd *cobra.Command, args []string) {
 			logger.Fatal("Invalid backend URL %s: %v", backendURL, err)
 		}
 		backends = append(backends, target)
-		proxyStats.BackendStats[backendURL] = &BackendStats{
+	}
+	for _, backend := range backends {
+		proxyStats.BackendStats[backend.String()] = &BackendStats{
 			IsHealthy:   true,
 			LastHealthy: time.Now(),
 		}
 	}
+	loadConnectionPolicy(cmd)
 
 	logger.Info("Starting reverse proxy on %s", proxyListen)
 	logger.Info("Backends: %v", backends)
 
+	// Create load balancer
+	proxyBalancer = newLoadBalancer(backends, proxyLoadBalance)
+	for _, backend := range backends {
+		upstreams().Warm(backendAddress(backend))
+	}
+
 	// Start health checks if enabled
 	if proxyHealthCheck != "" {
-		startHealthChecks(backends)
+		startHealthChecks(proxyBalancer)
 	}
 
-	// Create load balancer
-	lb := newLoadBalancer(backends, proxyLoadBalance)
-
-	// Create reverse proxy handler
+	// Create reverse proxy handler; backend connections come warm from the
+	// upstream pool and fail fast while a backend's circuit is open
 	handler := &proxyHandler{
-		loadBalancer: lb,
+		loadBalancer: proxyBalancer,
 		transport: &http.Transport{
 			MaxIdleConns:        100,
 			IdleConnTimeout:     90 * time.Second,
 			TLSHandshakeTimeout: 10 * time.Second,
-			DialContext: (&net.Dialer{
-				Timeout:   proxyTimeout,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
+			// A connection carrying a PROXY header speaks for one client
+			// only, so it is not kept for the next request
+			DisableKeepAlives: sendProxyVersion != 0,
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialCtx, cancel := context.WithTimeout(ctx, proxyTimeout)
+				defer cancel()
+				conn, err := upstreams().DialContext(dialCtx, network, address)
+				if err != nil {
+					return nil, err
+				}
+				if client, ok := ctx.Value(proxyClientKey{}).(proxyClient); ok {
+					if err := sendProxyHeader(conn, client.source, client.destination); err != nil {
+						conn.Close()
+						return nil, err
+					}
+				}
+				return conn, nil
+			},
 		},
 	}
 
@@ -171,17 +209,23 @@ func runProxy(cmd *cobra.Command, args []string) {
 	// Start stats reporter
 	go reportStats()
 
+	if proxySSL && (proxySSLCert == "" || proxySSLKey == "") {
+		logger.Fatal("SSL certificate and key are required for SSL mode")
+	}
+	listener, err := listenGuarded(proxyListen)
+	if err != nil {
+		logger.Fatal("Failed to listen on %s: %v", proxyListen, err)
+	}
+	startAdminSocket(cmd, registerProxyAdmin)
+	startMetricsExporter(cmd)
+
 	// Start server
-	var err error
 	if proxySSL {
-		if proxySSLCert == "" || proxySSLKey == "" {
-			logger.Fatal("SSL certificate and key are required for SSL mode")
-		}
 		logger.Info("Starting HTTPS proxy...")
-		err = server.ListenAndServeTLS(proxySSLCert, proxySSLKey)
+		err = server.ServeTLS(listener, proxySSLCert, proxySSLKey)
 	} else {
 		logger.Info("Starting HTTP proxy...")
-		err = server.ListenAndServe()
+		err = server.Serve(listener)
 	}
 
 	if err != nil && err != http.ErrServerClosed {
@@ -194,6 +238,14 @@ type proxyHandler struct {
 	transport    *http.Transport
 }
 
+// proxyClientKey holds the proxyClient of a backend request in its context
+type proxyClientKey struct{}
+
+// proxyClient is the client a backend connection sends the PROXY header of
+type proxyClient struct {
+	source, destination net.Addr
+}
+
 func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	atomic.AddInt64(&proxyStats.TotalRequests, 1)
@@ -214,6 +266,12 @@ func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sendProxyVersion != 0 {
+		source, destination := requestAddrs(r)
+		client := proxyClient{source: source, destination: destination}
+		r = r.WithContext(context.WithValue(r.Context(), proxyClientKey{}, client))
+	}
+
 	// Create reverse proxy
 	proxy := httputil.NewSingleHostReverseProxy(backend)
 	proxy.Transport = h.transport
@@ -235,7 +293,7 @@ func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		atomic.AddInt64(&proxyStats.FailedRequests, 1)
 		
 		// Mark backend as unhealthy
-		if stats, ok := proxyStats.BackendStats[backend.String()]; ok {
+		if stats, ok := proxyStats.backendStats(backend.String()); ok {
 			stats.IsHealthy = false
 			atomic.AddInt64(&stats.Failures, 1)
 		}
@@ -250,7 +308,7 @@ func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	latency := time.Since(startTime)
 	atomic.AddInt64(&proxyStats.totalLatency, int64(latency))
 	
-	if stats, ok := proxyStats.BackendStats[backend.String()]; ok {
+	if stats, ok := proxyStats.backendStats(backend.String()); ok {
 		atomic.AddInt64(&stats.Requests, 1)
 	}
 }
@@ -279,10 +337,13 @@ func (lb *loadBalancer) NextBackend(r *http.Request) *url.URL {
 		return nil
 	}
 
-	// Filter healthy backends
+	// Filter healthy backends, skipping those whose circuit is open
 	var healthy []*url.URL
 	for _, backend := range lb.backends {
-		if stats, ok := proxyStats.BackendStats[backend.String()]; ok && stats.IsHealthy {
+		if !upstreams().Available(backendAddress(backend)) {
+			continue
+		}
+		if stats, ok := proxyStats.backendStats(backend.String()); ok && stats.IsHealthy {
 			healthy = append(healthy, backend)
 		} else if !ok {
 			// No stats yet, assume healthy
@@ -305,7 +366,7 @@ func (lb *loadBalancer) NextBackend(r *http.Request) *url.URL {
 		var minBackend *url.URL
 		var minConns int64 = -1
 		for _, backend := range healthy {
-			if stats, ok := proxyStats.BackendStats[backend.String()]; ok {
+			if stats, ok := proxyStats.backendStats(backend.String()); ok {
 				if minConns == -1 || stats.Requests < minConns {
 					minConns = stats.Requests
 					minBackend = backend
@@ -329,6 +390,51 @@ func (lb *loadBalancer) NextBackend(r *http.Request) *url.URL {
 	}
 }
 
+// list returns a copy of the backends
+func (lb *loadBalancer) list() []*url.URL {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return append([]*url.URL(nil), lb.backends...)
+}
+
+// add appends a backend unless it is already present
+func (lb *loadBalancer) add(backend *url.URL) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	for _, b := range lb.backends {
+		if b.String() == backend.String() {
+			return false
+		}
+	}
+	lb.backends = append(lb.backends, backend)
+	return true
+}
+
+// remove drops the backend with the given URL
+func (lb *loadBalancer) remove(backend string) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	for i, b := range lb.backends {
+		if b.String() == backend {
+			lb.backends = append(lb.backends[:i:i], lb.backends[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// backendAddress returns the host:port the proxy connects to for a backend
+func backendAddress(backend *url.URL) string {
+	if backend.Port() != "" {
+		return backend.Host
+	}
+	port := "80"
+	if backend.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(backend.Hostname(), port)
+}
+
 // hashing and distribution.
 func hashString(s string) uint64 {
 	var hash uint64
@@ -339,15 +445,16 @@ func hashString(s string) uint64 {
 }
 
 // startHealthChecks starts a background routine that periodically checks the health
-// of each provided backend URL using the configured health-check path.
-// It runs checks on a 10-second interval and invokes checkBackendHealth concurrently for each backend.
-func startHealthChecks(backends []*url.URL) {
+// of each backend of lb using the configured health-check path.
+// It runs checks on a 10-second interval and invokes checkBackendHealth concurrently for each backend,
+// so backends added at runtime are checked too.
+func startHealthChecks(lb *loadBalancer) {
 	logger.Info("Starting health checks on %s", proxyHealthCheck)
 	
 	ticker := time.NewTicker(10 * time.Second)
 	go func() {
 		for range ticker.C {
-			for _, backend := range backends {
+			for _, backend := range lb.list() {
 				go checkBackendHealth(backend)
 			}
 		}
@@ -371,7 +478,7 @@ func checkBackendHealth(backend *url.URL) {
 	resp, err := client.Get(healthURL)
 	if err != nil {
 		logger.Debug("Health check failed for %s: %v", backend.String(), err)
-		if stats, ok := proxyStats.BackendStats[backend.String()]; ok {
+		if stats, ok := proxyStats.backendStats(backend.String()); ok {
 			stats.IsHealthy = false
 		}
 		return
@@ -379,14 +486,14 @@ func checkBackendHealth(backend *url.URL) {
 	defer resp.Body.Close()
 	
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		if stats, ok := proxyStats.BackendStats[backend.String()]; ok {
+		if stats, ok := proxyStats.backendStats(backend.String()); ok {
 			stats.IsHealthy = true
 			stats.LastHealthy = time.Now()
 		}
 		logger.Debug("Health check OK for %s", backend.String())
 	} else {
 		logger.Debug("Health check failed for %s: status %d", backend.String(), resp.StatusCode)
-		if stats, ok := proxyStats.BackendStats[backend.String()]; ok {
+		if stats, ok := proxyStats.backendStats(backend.String()); ok {
 			stats.IsHealthy = false
 		}
 	}
@@ -413,6 +520,7 @@ func reportStats() {
 			total, active, failed, avgLatency)
 		
 		// Backend stats
+		proxyStats.mu.RLock()
 		for backendURL, stats := range proxyStats.BackendStats {
 			status := "healthy"
 			if !stats.IsHealthy {
@@ -421,5 +529,108 @@ func reportStats() {
 			logger.Info("  Backend %s: %s, Requests: %d, Failures: %d", 
 				backendURL, status, stats.Requests, stats.Failures)
 		}
+		proxyStats.mu.RUnlock()
+	}
+}
+// proxyBackendInfo describes a backend for the backends.list method
+type proxyBackendInfo struct {
+	URL         string    `json:"url"`
+	Healthy     bool      `json:"healthy"`
+	LastHealthy time.Time `json:"last_healthy"`
+	Requests    int64     `json:"requests"`
+	Failures    int64     `json:"failures"`
+	// Circuit is the state of the circuit breaker of the backend
+	Circuit network.CircuitBreakerState `json:"circuit"`
+}
+
+// proxyBackends lists the backends with their statistics
+func proxyBackends() []proxyBackendInfo {
+	var list []proxyBackendInfo
+	circuits := relayDialer.GetCircuitBreakerStats()
+	for _, backend := range proxyBalancer.list() {
+		info := proxyBackendInfo{URL: backend.String(), Healthy: true}
+		info.Circuit = circuits[backendAddress(backend)].State
+		if stats, ok := proxyStats.backendStats(info.URL); ok {
+			info.Healthy = stats.IsHealthy
+			info.LastHealthy = stats.LastHealthy
+			info.Requests = atomic.LoadInt64(&stats.Requests)
+			info.Failures = atomic.LoadInt64(&stats.Failures)
+		}
+		list = append(list, info)
 	}
-}
\ No newline at end of file
+	return list
+}
+
+// registerProxyAdmin adds the proxy's stats and backend methods to the
+// admin API
+func registerProxyAdmin(srv *admin.Server) {
+	srv.Handle("stats", "Show request totals", func(json.RawMessage) (interface{}, error) {
+		total := atomic.LoadInt64(&proxyStats.TotalRequests)
+		var avgLatency time.Duration
+		if total > 0 {
+			avgLatency = time.Duration(atomic.LoadInt64(&proxyStats.totalLatency) / total)
+		}
+		return map[string]interface{}{
+			"total_requests":  total,
+			"active_requests": atomic.LoadInt64(&proxyStats.ActiveRequests),
+			"failed_requests": atomic.LoadInt64(&proxyStats.FailedRequests),
+			"average_latency": avgLatency.String(),
+			"backends":        proxyBackends(),
+		}, nil
+	})
+
+	srv.Handle("backends.list", "List backends and their health", func(json.RawMessage) (interface{}, error) {
+		return proxyBackends(), nil
+	})
+
+	srv.Handle("backends.add", "Add a backend: {\"url\": \"http://host:port\"}", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			URL string `json:"url"`
+		}
+		if err := admin.DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		backend, err := url.Parse(p.URL)
+		if err != nil || backend.Scheme == "" || backend.Host == "" {
+			return nil, admin.InvalidParams("invalid backend URL: %q", p.URL)
+		}
+		proxyStats.mu.Lock()
+		if _, ok := proxyStats.BackendStats[backend.String()]; !ok {
+			proxyStats.BackendStats[backend.String()] = &BackendStats{
+				IsHealthy:   true,
+				LastHealthy: time.Now(),
+			}
+		}
+		proxyStats.mu.Unlock()
+		if !proxyBalancer.add(backend) {
+			return nil, fmt.Errorf("backend already exists: %s", backend)
+		}
+		upstreams().Warm(backendAddress(backend))
+		logger.Info("Backend added via admin API: %s", backend)
+		return proxyBackends(), nil
+	})
+
+	srv.Handle("backends.remove", "Remove a backend: {\"url\": \"http://host:port\"}", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			URL string `json:"url"`
+		}
+		if err := admin.DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		backend := p.URL
+		if parsed, err := url.Parse(p.URL); err == nil {
+			backend = parsed.String()
+		}
+		if !proxyBalancer.remove(backend) {
+			return nil, fmt.Errorf("unknown backend: %s", p.URL)
+		}
+		if parsed, err := url.Parse(backend); err == nil {
+			upstreams().Forget(backendAddress(parsed))
+		}
+		proxyStats.mu.Lock()
+		delete(proxyStats.BackendStats, backend)
+		proxyStats.mu.Unlock()
+		logger.Info("Backend removed via admin API: %s", backend)
+		return proxyBackends(), nil
+	})
+}
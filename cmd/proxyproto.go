@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/proxyproto"
+	"github.com/ibrahmsql/gocat/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// acceptProxyHeaders configures reading PROXY protocol headers on guarded
+// listeners; nil when --accept-proxy-protocol is off
+var acceptProxyHeaders *proxyproto.ListenerConfig
+
+// sendProxyVersion is the PROXY protocol version outbound connections
+// start with; 0 sends no header
+var sendProxyVersion proxyproto.Version
+
+// loadProxyProtocol reads the global --accept-proxy-protocol,
+// --proxy-protocol-trusted and --send-proxy-protocol flags
+func loadProxyProtocol(cmd *cobra.Command) {
+	flags := cmd.Root().PersistentFlags()
+
+	if version, _ := flags.GetString("send-proxy-protocol"); version != "" {
+		v, err := proxyproto.ParseVersion(version)
+		if err != nil {
+			logger.Fatal("Invalid --send-proxy-protocol: %v", err)
+		}
+		sendProxyVersion = v
+	}
+
+	if accept, _ := flags.GetBool("accept-proxy-protocol"); !accept {
+		return
+	}
+	config := &proxyproto.ListenerConfig{
+		OnError: func(peer net.Addr, err error) {
+			logger.Warn("Dropped connection from %s: %v", peer, err)
+		},
+	}
+	// Any client could claim any address if every peer were trusted, getting
+	// past --allow and --deny
+	trusted, _ := flags.GetStringSlice("proxy-protocol-trusted")
+	if len(trusted) == 0 {
+		logger.Fatal("--accept-proxy-protocol needs --proxy-protocol-trusted with the addresses of the proxies that send headers")
+	}
+	acl := security.NewAccessControl()
+	for _, host := range trusted {
+		if err := acl.AddAllowedHost(host); err != nil {
+			logger.Fatal("Invalid --proxy-protocol-trusted: %v", err)
+		}
+	}
+	config.Trusted = acl.IsAllowed
+	acceptProxyHeaders = config
+}
+
+// acceptProxyProtocol makes ln read the PROXY protocol header of trusted
+// peers, so their connections carry the client's address
+func acceptProxyProtocol(ln net.Listener) net.Listener {
+	if acceptProxyHeaders == nil {
+		return ln
+	}
+	return proxyproto.NewListener(ln, acceptProxyHeaders)
+}
+
+// sendProxyHeader writes the --send-proxy-protocol header for a connection
+// from source to destination at the start of conn
+func sendProxyHeader(conn net.Conn, source, destination net.Addr) error {
+	if sendProxyVersion == 0 {
+		return nil
+	}
+	_, err := proxyproto.NewHeader(sendProxyVersion, source, destination).WriteTo(conn)
+	return err
+}
+
+// requestAddrs returns the client and local addresses of an HTTP request
+func requestAddrs(r *http.Request) (net.Addr, net.Addr) {
+	var source net.Addr
+	if ap, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		source = net.TCPAddrFromAddrPort(ap)
+	}
+	destination, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return source, destination
+}
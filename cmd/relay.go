@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/process"
+	"github.com/ibrahmsql/gocat/internal/record"
+	"github.com/ibrahmsql/gocat/internal/relay"
+)
+
+var (
+	relayMiddlewares     []relay.Middleware
+	relayMiddlewaresOnce sync.Once
+
+	sessionRecorder     *record.Recorder
+	sessionRecorderOnce sync.Once
+)
+
+// loadRelayMiddlewares builds the middlewares every relaying command
+// applies from the global flags: --hex-dump captures both directions of
+// each relay. The dump file is opened on first use so commands that relay
+// nothing leave it alone.
+func loadRelayMiddlewares() []relay.Middleware {
+	relayMiddlewaresOnce.Do(func() {
+		flags := rootCmd.PersistentFlags()
+		if path, _ := flags.GetString("hex-dump"); path != "" {
+			appendOut, _ := flags.GetBool("append-output")
+			file, err := openOutputFile(path, appendOut)
+			if err != nil {
+				logger.Error("Failed to open hex dump file: %v", err)
+			} else {
+				relayMiddlewares = append(relayMiddlewares, relay.HexCapture(file))
+			}
+		}
+	})
+	return relayMiddlewares
+}
+
+// newRelay returns a relay engine configured by the global flags:
+// -i/--idle-timeout ends idle relays, the middlewares of
+// loadRelayMiddlewares are applied
+func newRelay() *relay.Engine {
+	engine := relay.NewEngine()
+	engine.BufferSize = relayBufferSize
+	engine.IdleTimeout, _ = rootCmd.PersistentFlags().GetDuration("idle-timeout")
+	return engine.Use(loadRelayMiddlewares()...)
+}
+
+// relayEndpoints relays a and b until both sides are done and returns the
+// per-direction stats. Forward is a to b.
+func relayEndpoints(a, b relay.Endpoint) relay.Stats {
+	return runRelay(newRelay(), a, b)
+}
+
+// runRelay relays a and b on engine, logging how it ended
+func runRelay(engine *relay.Engine, a, b relay.Endpoint) relay.Stats {
+	stats, err := runRelayContext(context.Background(), engine, a, b)
+	if err != nil {
+		logger.Debug("Relay ended: %v", err)
+	}
+	return stats
+}
+
+// runRelayContext relays a and b on engine until both sides are done or
+// ctx is, recording the session with --record
+func runRelayContext(ctx context.Context, engine *relay.Engine, a, b relay.Endpoint) (relay.Stats, error) {
+	var session *record.Session
+	if rec := loadSessionRecorder(); rec != nil {
+		session = rec.Start(record.SessionInfo{
+			Command: strings.Join(os.Args[1:], " "),
+			A:       describeEndpoint(a),
+			B:       describeEndpoint(b),
+		})
+		engine.Use(session.Middleware())
+	}
+
+	stats, err := engine.Run(ctx, a, b)
+	if session != nil {
+		session.End(err)
+	}
+	logger.Debug("Relayed %d bytes forward, %d bytes reverse", stats.Forward.Bytes, stats.Reverse.Bytes)
+	return stats, err
+}
+
+// loadSessionRecorder opens the --record file on first use
+func loadSessionRecorder() *record.Recorder {
+	sessionRecorderOnce.Do(func() {
+		path, _ := rootCmd.PersistentFlags().GetString("record")
+		if path == "" {
+			return
+		}
+		rec, err := record.Create(path)
+		if err != nil {
+			logger.Error("Failed to create recording: %v", err)
+			return
+		}
+		sessionRecorder = rec
+		logger.Debug("Recording sessions to %s", path)
+	})
+	return sessionRecorder
+}
+
+// describeEndpoint returns the recording metadata of an endpoint
+func describeEndpoint(ep relay.Endpoint) record.Endpoint {
+	switch e := ep.(type) {
+	case net.Conn:
+		return record.Endpoint{
+			Kind:    "conn",
+			Network: e.LocalAddr().Network(),
+			Local:   e.LocalAddr().String(),
+			Remote:  e.RemoteAddr().String(),
+		}
+	case *relay.WebSocketEndpoint:
+		return record.Endpoint{
+			Kind:    "websocket",
+			Network: e.LocalAddr().Network(),
+			Local:   e.LocalAddr().String(),
+			Remote:  e.RemoteAddr().String(),
+		}
+	case *process.Process:
+		return record.Endpoint{Kind: "exec", Remote: e.String()}
+	default:
+		return record.Endpoint{Kind: "stdio"}
+	}
+}
+
+// relayConns relays two connections, see relayEndpoints
+func relayConns(a, b net.Conn) relay.Stats {
+	return relayEndpoints(relay.Conn(a), relay.Conn(b))
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/record"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayList    bool
+	replaySession int
+	replayConnect string
+	replayListen  string
+	replayNetwork string
+	replayPlay    string
+	replaySpeed   float64
+	replayNoDelay bool
+	replayAssert  bool
+	replayTimeout time.Duration
+)
+
+// replayCmd reproduces a session recorded with --record
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Replay a recorded session against a server or client",
+	Long: `Replay one side of a session recorded with --record. gocat sends what that
+side sent, with the recorded timing, and waits for the other side's data
+before going on. With --assert the replay fails when the peer's data differs
+from the recording, which turns recordings into regression fixtures.
+
+A recorded session relays between endpoint a and endpoint b. By default gocat
+plays the endpoint that is not the network connection, such as the stdin of
+listen or the command of --exec. When both are connections, as with convert
+and tunnel, a client plays a and a server plays b. --play picks the side
+explicitly.
+
+Examples:
+  # Record the sessions of a client with a device through a relay
+  gocat convert --from tcp::4001 --to tcp:10.0.0.5:4001 --record device.gcr
+
+  # List the recorded sessions
+  gocat replay --list device.gcr
+
+  # Replay the client side against the device, twice as fast, checking the answers
+  gocat replay device.gcr --connect 10.0.0.5:4001 --speed 2 --assert
+
+  # Impersonate the device for a client under test
+  gocat replay device.gcr --listen :4001 --play b`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().BoolVar(&replayList, "list", false, "List the sessions of the recording")
+	replayCmd.Flags().IntVar(&replaySession, "session", 1, "Session to replay")
+	replayCmd.Flags().StringVar(&replayConnect, "connect", "", "Replay as a client connecting to this address")
+	replayCmd.Flags().StringVar(&replayListen, "listen", "", "Replay as a server for the first client on this address")
+	replayCmd.Flags().StringVar(&replayNetwork, "network", "", "Network to use (default: the recorded one, or tcp)")
+	replayCmd.Flags().StringVar(&replayPlay, "play", "", "Recorded endpoint to play: a or b")
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Timing scale: 2 replays twice as fast")
+	replayCmd.Flags().BoolVar(&replayNoDelay, "no-delay", false, "Send without the recorded delays")
+	replayCmd.Flags().BoolVar(&replayAssert, "assert", false, "Fail when the peer's data differs from the recording")
+	replayCmd.Flags().DurationVar(&replayTimeout, "timeout", 5*time.Second, "How long to wait for each expected response")
+
+	replayCmd.MarkFlagsMutuallyExclusive("connect", "listen")
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	sessions, err := record.LoadFile(args[0])
+	if err != nil {
+		return err
+	}
+	if replayList {
+		printRecordedSessions(sessions)
+		return nil
+	}
+
+	if replaySession < 1 || replaySession > len(sessions) {
+		return fmt.Errorf("session %d not found, the recording has %d", replaySession, len(sessions))
+	}
+	session := sessions[replaySession-1]
+
+	if replayConnect == "" && replayListen == "" {
+		return errors.New("nothing to replay against (use --connect or --listen)")
+	}
+	if replaySpeed <= 0 {
+		return errors.New("--speed must be positive")
+	}
+
+	play, err := replayDirection(session, replayListen != "")
+	if err != nil {
+		return err
+	}
+	replayer := &record.Replayer{
+		Session: session,
+		Play:    play,
+		Speed:   replaySpeed,
+		Assert:  replayAssert,
+		Timeout: replayTimeout,
+	}
+	if replayNoDelay {
+		replayer.Speed = 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	netType := replayNetwork
+	if netType == "" {
+		netType = recordedNetwork(session)
+	}
+	conn, err := replayConn(ctx, netType)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	logger.Info("Replaying session %d (%d events) with %s", session.ID, len(session.Events), conn.RemoteAddr())
+	result, err := replayer.Run(ctx, conn)
+	logger.Info("Sent %d bytes, received %d of %d expected bytes", result.Sent, result.Received, result.Expected)
+	if err != nil {
+		return fmt.Errorf("replay failed: %w", err)
+	}
+	if replayAssert {
+		logger.Info("Responses match the recording")
+	}
+	return nil
+}
+
+// replayDirection returns the direction carrying the data of the played
+// endpoint. Unless --play says otherwise that is the endpoint that is not a
+// network connection; when both are, a client plays a and a server b.
+func replayDirection(session *record.SessionLog, listening bool) (relay.Direction, error) {
+	switch strings.ToLower(replayPlay) {
+	case "a":
+		return relay.Forward, nil
+	case "b":
+		return relay.Reverse, nil
+	case "":
+	default:
+		return relay.Forward, fmt.Errorf("invalid --play %q, want a or b", replayPlay)
+	}
+
+	aIsPeer := session.A.Kind == "conn" || session.A.Kind == "websocket"
+	bIsPeer := session.B.Kind == "conn" || session.B.Kind == "websocket"
+	switch {
+	case bIsPeer && !aIsPeer:
+		return relay.Forward, nil
+	case aIsPeer && !bIsPeer:
+		return relay.Reverse, nil
+	case listening:
+		return relay.Reverse, nil
+	default:
+		return relay.Forward, nil
+	}
+}
+
+// recordedNetwork returns the network of the recorded connection
+func recordedNetwork(session *record.SessionLog) string {
+	for _, ep := range []record.Endpoint{session.B, session.A} {
+		switch ep.Network {
+		case "udp", "udp4", "udp6", "unix", "unixgram":
+			return ep.Network
+		case "tcp", "tcp4", "tcp6":
+			return "tcp"
+		}
+	}
+	return "tcp"
+}
+
+// replayConn connects to --connect or waits for the first client on --listen
+func replayConn(ctx context.Context, netType string) (net.Conn, error) {
+	if replayConnect != "" {
+		conn, err := outboundDialer(0, 10*time.Second).DialContext(ctx, netType, replayConnect)
+		if err != nil {
+			return nil, dialCause(err)
+		}
+		return conn, nil
+	}
+
+	if strings.HasPrefix(netType, "udp") {
+		return acceptUDPPeer(ctx, netType)
+	}
+
+	ln, err := listenTCP(netType, replayListen)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	logger.Info("Waiting for a client on %s", ln.Addr())
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	conn, err := ln.Accept()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return conn, err
+}
+
+// acceptUDPPeer waits for the first datagram on --listen and returns the
+// session of its sender
+func acceptUDPPeer(ctx context.Context, netType string) (net.Conn, error) {
+	ln, err := network.ListenUDP(netType, replayListen, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Closing locks the socket onto the accepted peer
+	defer ln.Close()
+	logger.Info("Waiting for a client on %s", ln.Addr())
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	conn, err := ln.Accept()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return conn, err
+}
+
+// printRecordedSessions lists the sessions of a recording
+func printRecordedSessions(sessions []*record.SessionLog) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tSTARTED\tDURATION\tA\tB\tA->B\tB->A\tEVENTS")
+	for i, s := range sessions {
+		duration := "incomplete"
+		if s.Complete {
+			duration = s.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\n", i+1, s.Start.Format(time.RFC3339), duration,
+			describeRecorded(s.A), describeRecorded(s.B), s.Bytes(relay.Forward), s.Bytes(relay.Reverse), len(s.Events))
+	}
+	w.Flush()
+}
+
+// describeRecorded formats a recorded endpoint for the session list
+func describeRecorded(ep record.Endpoint) string {
+	switch {
+	case ep.Remote != "" && ep.Network != "":
+		return ep.Kind + " " + ep.Network + " " + ep.Remote
+	case ep.Remote != "":
+		return ep.Kind + " " + ep.Remote
+	default:
+		return ep.Kind
+	}
+}
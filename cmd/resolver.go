@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"net"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/resolver"
+)
+
+// loadResolver sets up the resolver every dial path looks names up with
+// from --resolver, --resolve and -n. Without them names go to the system
+// resolver.
+func loadResolver() {
+	flags := rootCmd.PersistentFlags()
+	upstream, _ := flags.GetString("resolver")
+	specs, _ := flags.GetStringArray("resolve")
+	noDNS, _ := flags.GetBool("nodns")
+	if upstream == "" && len(specs) == 0 && !noDNS {
+		return
+	}
+
+	overrides := make(map[string][]net.IP)
+	for _, spec := range specs {
+		host, ips, err := resolver.ParseOverride(spec)
+		if err != nil {
+			logger.Fatal("Invalid --resolve %q: %v", spec, err)
+		}
+		overrides[host] = append(overrides[host], ips...)
+	}
+
+	r, err := resolver.New(resolver.Config{
+		Upstream:  upstream,
+		Overrides: overrides,
+		HostsFile: resolver.DefaultHostsFile,
+		NoDNS:     noDNS,
+	})
+	if err != nil {
+		logger.Fatal("Invalid --resolver: %v", err)
+	}
+	resolver.SetDefault(r)
+
+	if noDNS {
+		logger.Debug("Hostname resolution disabled, %d names overridden", len(overrides))
+	} else {
+		logger.Debug("Resolving names with %s", r.Upstream())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/scripting"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -66,6 +67,7 @@ func SetBuildInfo(v, bt, gc, gb, bb string) {
 	gitCommit = gc
 	gitBranch = gb
 	builtBy = bb
+	scripting.SetVersion(v)
 }
 
 // showVersion displays version and build information
@@ -133,17 +135,25 @@ func init() {
 	rootCmd.PersistentFlags().BoolP("unixsock", "U", false, "Use Unix domain sockets only")
 	rootCmd.PersistentFlags().BoolP("udp", "u", false, "Use UDP instead of default TCP")
 	rootCmd.PersistentFlags().Bool("sctp", false, "Use SCTP instead of default TCP")
+	rootCmd.PersistentFlags().Int("sctp-streams", 10, "Number of SCTP streams to negotiate")
+	rootCmd.PersistentFlags().Uint16("sctp-stream", 0, "SCTP stream to send on")
+	rootCmd.PersistentFlags().Uint32("sctp-ppid", 0, "SCTP payload protocol identifier to send with")
 
 	// Hide advanced network flags
 	rootCmd.PersistentFlags().MarkHidden("ipv4")
 	rootCmd.PersistentFlags().MarkHidden("ipv6")
 	rootCmd.PersistentFlags().MarkHidden("unixsock")
 	rootCmd.PersistentFlags().MarkHidden("sctp")
+	rootCmd.PersistentFlags().MarkHidden("sctp-streams")
+	rootCmd.PersistentFlags().MarkHidden("sctp-stream")
+	rootCmd.PersistentFlags().MarkHidden("sctp-ppid")
 
 	// Connection and Behavior flags
 	rootCmd.PersistentFlags().BoolP("listen", "l", false, "Bind and listen for incoming connections")
 	rootCmd.PersistentFlags().BoolP("keep-open", "k", false, "Accept multiple connections in listen mode")
 	rootCmd.PersistentFlags().BoolP("nodns", "n", false, "Do not resolve hostnames via DNS")
+	rootCmd.PersistentFlags().String("resolver", "", "DNS server to resolve names with (udp://, tcp://, tls:// or https:// URL)")
+	rootCmd.PersistentFlags().StringArray("resolve", nil, "Resolve host to the given addresses (host:ip[,ip]), repeatable")
 	rootCmd.PersistentFlags().BoolP("telnet", "t", false, "Answer Telnet negotiations")
 	rootCmd.PersistentFlags().Bool("zero-io", false, "Zero-I/O mode, report connection status only")
 	rootCmd.PersistentFlags().BoolP("crlf", "C", false, "Use CRLF for EOL sequence")
@@ -157,7 +167,6 @@ func init() {
 	rootCmd.PersistentFlags().Bool("randomize-ports", false, "Randomize target port order")
 
 	// Hide advanced connection flags
-	rootCmd.PersistentFlags().MarkHidden("nodns")
 	rootCmd.PersistentFlags().MarkHidden("telnet")
 	rootCmd.PersistentFlags().MarkHidden("zero-io")
 	rootCmd.PersistentFlags().MarkHidden("crlf")
@@ -212,12 +221,19 @@ func init() {
 	// Execution and Command flags
 	rootCmd.PersistentFlags().StringP("sh-exec", "c", "", "Executes the given command via /bin/sh")
 	rootCmd.PersistentFlags().StringP("exec", "e", "", "Executes the given command")
-	rootCmd.PersistentFlags().String("lua-exec", "", "Executes the given Lua script")
+	rootCmd.PersistentFlags().String("lua-exec", "", "Handles each connection with the given Lua script")
+	rootCmd.PersistentFlags().StringSlice("allow-cap", []string{}, "Approve Lua script capabilities (net, fs, exec, all or a declared capability)")
+	rootCmd.PersistentFlags().Bool("pty", false, "Run executed commands under a pseudo-terminal")
+	rootCmd.PersistentFlags().StringArray("exec-env", nil, "Set KEY=VALUE in the environment of executed commands (repeatable)")
+	rootCmd.PersistentFlags().Bool("exec-clear-env", false, "Start executed commands with an empty environment")
+	rootCmd.PersistentFlags().String("exec-dir", "", "Working directory of executed commands")
+	rootCmd.PersistentFlags().Bool("exit-status", false, "Report the exit status of executed commands to the peer")
 
 	// Hide execution flags
 	rootCmd.PersistentFlags().MarkHidden("sh-exec")
 	rootCmd.PersistentFlags().MarkHidden("exec")
 	rootCmd.PersistentFlags().MarkHidden("lua-exec")
+	rootCmd.PersistentFlags().MarkHidden("allow-cap")
 
 	// Connection Limits and Management
 	rootCmd.PersistentFlags().IntP("max-conns", "m", 0, "Maximum simultaneous connections")
@@ -229,6 +245,7 @@ func init() {
 	rootCmd.PersistentFlags().StringP("output", "o", "", "Dump session data to a file")
 	rootCmd.PersistentFlags().StringP("hex-dump", "x", "", "Dump session data as hex to a file")
 	rootCmd.PersistentFlags().Bool("append-output", false, "Append rather than clobber specified output files")
+	rootCmd.PersistentFlags().String("record", "", "Record relayed sessions with timing to a file for gocat replay")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Set verbosity level (can be used several times)")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Suppress output")
 
@@ -260,6 +277,15 @@ func init() {
 	rootCmd.PersistentFlags().MarkHidden("deny")
 	rootCmd.PersistentFlags().MarkHidden("denyfile")
 
+	// PROXY protocol
+	rootCmd.PersistentFlags().Bool("accept-proxy-protocol", false, "Read PROXY protocol v1/v2 headers on listeners for the real client address")
+	rootCmd.PersistentFlags().StringSlice("proxy-protocol-trusted", nil, "Hosts or CIDRs that may send PROXY protocol headers (required with --accept-proxy-protocol)")
+	rootCmd.PersistentFlags().String("send-proxy-protocol", "", "Start outbound connections with a PROXY protocol header (v1, v2)")
+
+	// Remote control
+	rootCmd.PersistentFlags().String("admin-socket", "", "Serve the JSON-RPC admin API of long-running commands on this Unix socket")
+	rootCmd.PersistentFlags().String("metrics-addr", "", "Serve Prometheus metrics of long-running commands on this address (e.g. :9090)")
+
 	// Special Modes
 	rootCmd.PersistentFlags().Bool("broker", false, "Enable Ncat's connection brokering mode")
 	rootCmd.PersistentFlags().Bool("chat", false, "Start a simple Ncat chat server")
@@ -339,23 +365,17 @@ func initConfig() {
 		logger.SetStructured(true)
 	}
 
-	// Set log level from flag
-	if logLevel, _ := rootCmd.PersistentFlags().GetString("log-level"); logLevel != "" {
-		switch logLevel {
-		case "debug":
-			logger.SetLevel(logger.LevelDebug)
-		case "info":
-			logger.SetLevel(logger.LevelInfo)
-		case "warn":
-			logger.SetLevel(logger.LevelWarn)
-		case "error":
-			logger.SetLevel(logger.LevelError)
-		default:
+	// Set log level from flag, when given, so its default does not undo -v
+	if logLevel, _ := rootCmd.PersistentFlags().GetString("log-level"); rootCmd.PersistentFlags().Changed("log-level") {
+		level, err := logger.ParseLevel(logLevel)
+		if err != nil {
 			logger.Warn("Invalid log level '%s', using 'info'", logLevel)
-			logger.SetLevel(logger.LevelInfo)
 		}
+		logger.SetLevel(level)
 	}
 
+	loadResolver()
+
 	// Load theme if not disabled
 	if noColor, _ := rootCmd.PersistentFlags().GetBool("no-color"); !noColor {
 		initTheme()
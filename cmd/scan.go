@@ -1,20 +1,20 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"net"
-	"strconv"
+	"os"
+	"os/signal"
 	"strings"
-	"sync"
-	"time"
+	"syscall"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/scanner"
 	"github.com/spf13/cobra"
 )
 
 var (
-	scanTimeout   = 3 * time.Second // Default timeout
+	scanTimeout   = scanner.DefaultTimeout
 	concurrency   int
 	portRange     string
 	verboseOutput bool
@@ -26,11 +26,15 @@ var (
 
 // scanCmd represents the scan command
 var scanCmd = &cobra.Command{
-	Use:   "scan [host] [ports]",
+	Use:   "scan [targets] [ports]",
 	Short: "Port scanner for network reconnaissance",
 	Long: `A fast and efficient port scanner that can scan single ports, port ranges,
 or common ports on target hosts. Supports both TCP and UDP scanning with
-configurable concurrency and timeout settings.`,
+configurable concurrency and timeout settings.
+
+Targets may be a host, a CIDR network (192.168.1.0/24), a last-octet range
+(192.168.1.10-20) or a comma separated list of those. UDP ports that do not
+answer are reported as open|filtered.`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		host := args[0]
@@ -62,111 +66,65 @@ configurable concurrency and timeout settings.`,
 
 		logger.Info("Starting port scan on %s for ports %s", host, ports)
 
-		portList, err := parsePortRange(ports)
+		hosts, err := scanner.ExpandTargets(host)
 		if err != nil {
-			logger.Error("Invalid port range: %v", err)
+			logger.Error("Invalid target: %v", err)
 			return
 		}
-
-		scanPorts(host, portList)
-	},
-}
-
-func parsePortRange(portStr string) ([]int, error) {
-	var ports []int
-
-	if strings.Contains(portStr, ",") {
-		// Handle comma-separated ports: 22,80,443
-		portStrs := strings.Split(portStr, ",")
-		for _, p := range portStrs {
-			port, err := strconv.Atoi(strings.TrimSpace(p))
-			if err != nil {
-				return nil, fmt.Errorf("invalid port: %s", p)
-			}
-			ports = append(ports, port)
-		}
-	} else if strings.Contains(portStr, "-") {
-		// Handle port range: 1-1000
-		parts := strings.Split(portStr, "-")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid port range format")
-		}
-		start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
-		if err != nil {
-			return nil, fmt.Errorf("invalid start port: %s", parts[0])
-		}
-		end, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		portList, err := scanner.ParsePorts(ports)
 		if err != nil {
-			return nil, fmt.Errorf("invalid end port: %s", parts[1])
-		}
-		for i := start; i <= end; i++ {
-			ports = append(ports, i)
-		}
-	} else {
-		// Single port
-		port, err := strconv.Atoi(strings.TrimSpace(portStr))
-		if err != nil {
-			return nil, fmt.Errorf("invalid port: %s", portStr)
+			logger.Error("Invalid port range: %v", err)
+			return
 		}
-		ports = append(ports, port)
-	}
-
-	return ports, nil
-}
 
-func scanPorts(host string, ports []int) {
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, concurrency)
-
-	for _, port := range ports {
-		wg.Add(1)
-		go func(p int) {
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			isOpen := scanPort(host, p)
-			if isOpen || !onlyOpen {
-				printResult(host, p, isOpen)
-			}
-		}(port)
-	}
-
-	wg.Wait()
+		scanPorts(hosts, portList)
+	},
 }
 
-func scanPort(host string, port int) bool {
+func scanPorts(hosts []string, ports []int) {
 	network := "tcp"
 	if useUDPScan {
 		network = "udp"
 	}
-
 	if forceIPv6Scan {
 		network += "6"
 	} else if forceIPv4Scan {
 		network += "4"
 	}
 
-	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-	conn, err := net.DialTimeout(network, address, scanTimeout)
-	if err != nil {
-		return false
-	}
-	if err := conn.Close(); err != nil {
-		// Log close error but don't fail the scan
-		logger.Warn("Failed to close connection: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := scanner.New(scanner.Options{
+		Network:     network,
+		Timeout:     scanTimeout,
+		Concurrency: concurrency,
+		Dial:        happyEyeballs(scanTimeout, nil),
+	})
+	for result := range s.Scan(ctx, hosts, ports) {
+		isOpen := result.Status == scanner.StatusOpen || result.Status == scanner.StatusOpenFiltered
+		if isOpen || !onlyOpen || verboseOutput {
+			printResult(result)
+		}
 	}
-	return true
 }
 
-func printResult(host string, port int, isOpen bool) {
+func printResult(result scanner.Result) {
 	theme := logger.GetCurrentTheme()
-	if isOpen {
-		if _, err := theme.Success.Printf("[+] %s:%d - OPEN\n", host, port); err != nil {
+	switch result.Status {
+	case scanner.StatusOpen:
+		if _, err := theme.Success.Printf("[+] %s:%d - OPEN\n", result.Host, result.Port); err != nil {
 			log.Printf("Error printing success message: %v", err)
 		}
-	} else if verboseOutput {
-		if _, err := theme.Error.Printf("[-] %s:%d - CLOSED\n", host, port); err != nil {
+	case scanner.StatusOpenFiltered:
+		if _, err := theme.Warning.Printf("[?] %s:%d - OPEN|FILTERED\n", result.Host, result.Port); err != nil {
+			log.Printf("Error printing warning message: %v", err)
+		}
+	default:
+		if !verboseOutput {
+			return
+		}
+		if _, err := theme.Error.Printf("[-] %s:%d - %s\n", result.Host, result.Port, strings.ToUpper(string(result.Status))); err != nil {
 			log.Printf("Error printing error message: %v", err)
 		}
 	}
@@ -176,7 +134,7 @@ func init() {
 	rootCmd.AddCommand(scanCmd)
 
 	// Scan-specific flags
-	scanCmd.Flags().IntVar(&concurrency, "concurrency", 100, "Number of concurrent scans")
+	scanCmd.Flags().IntVar(&concurrency, "concurrency", scanner.DefaultConcurrency, "Number of concurrent scans")
 	scanCmd.Flags().StringVar(&portRange, "ports", "", "Port range to scan (e.g., 1-1000, 22,80,443)")
 	scanCmd.Flags().BoolVar(&verboseOutput, "verbose-scan", false, "Show closed ports as well")
 	scanCmd.Flags().BoolVar(&onlyOpen, "open", true, "Show only open ports")
@@ -1,20 +1,25 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
 	"github.com/ibrahmsql/gocat/internal/scripting"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // Constants for script command
 const (
-	scriptsDirectory = "./scripts"
-	luaExtension     = ".lua"
+	scriptsDirectory = scripting.ProjectScriptDir
 	maxScriptSize    = 10 * 1024 * 1024 // 10MB limit for script files
 )
 
@@ -27,45 +32,77 @@ GoCat provides a powerful Lua scripting engine that allows you to automate
 various network tasks such as port scanning, banner grabbing, HTTP requests,
 and more.
 
+Scripts are looked up by name in ./scripts, the directories listed in
+GOCAT_SCRIPT_PATH and $XDG_DATA_HOME/gocat/scripts (~/.local/share/gocat/scripts).
+A script is either a single .lua file or a package directory with a
+script.yaml manifest declaring its version, capabilities, dependencies and
+typed arguments.
+
 Available operations:
   run      Execute a Lua script
   list     List available scripts
   info     Show script information
   validate Validate script syntax
+  test     Run *_test.lua script tests
+  install  Install a script package
 
 Examples:
-  gocat script run port_scanner.lua
-  gocat script list
-  gocat script info banner_grabber.lua
-  gocat script validate ./custom_script.lua`,
+  gocat script run port_scanner --target 10.0.0.5 --ports 1-1024 --allow-cap net
+  gocat script list --json
+  gocat script info banner_grabber
+  gocat script validate ./custom_script.lua
+  gocat script test ./scripts
+  gocat script install ./smtp-probe-1.2.0.tar.gz`,
 }
 
 var scriptRunCmd = &cobra.Command{
-	Use:   "run [script]",
+	Use:   "run <script> [--arg value...]",
 	Short: "Execute a Lua script",
 	Long: `Execute a Lua script with GoCat's scripting engine.
 
 The script can be specified as:
-- A filename (searches in ./scripts/ directory)
+- A script or package name, looked up in the script search paths
 - A relative path from current directory
 - An absolute path
 
+Arguments declared by the script are passed as flags after the script name,
+validated against their declared types and exposed to the script as the
+global args table. Use "gocat script run <script> --help" to list them.
+
+Arguments are declared in script.yaml or in the script header:
+
+  -- Arg: target:host! Host to probe
+  -- Arg: ports:ports=25,587 Ports to check
+
+Supported types: string, int, number, bool, port, ports, host, duration, list.
+A trailing ! marks a required argument, =value sets the default.
+
+Scripts run in a sandbox. Network, filesystem and command execution access
+must be declared in the script header and approved with --allow-cap:
+
+  -- Capability: net:*.example.com:80,443
+  -- Capability: fs:/tmp/gocat
+  -- Capability: exec
+
 Examples:
-  gocat script run port_scanner.lua
-  gocat script run ./my_scripts/custom.lua
-  gocat script run /path/to/script.lua`,
-	Args: cobra.ExactArgs(1),
-	Run:  runScript,
+  gocat script run port_scanner --target 10.0.0.5 --ports 1-100 --allow-cap net
+  gocat script run ./my_scripts/custom.lua --allow-cap net:example.com:80
+  gocat script run /path/to/script.lua --timeout 60 --allow-cap all`,
+	// Flags are parsed in runScript once the script's arguments are known
+	DisableFlagParsing: true,
+	Run:                runScript,
 }
 
 var scriptListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available scripts",
-	Long: `List all available Lua scripts in the scripts directory.
+	Long: `List the Lua scripts available in the script search paths.
 
-This command scans the ./scripts/ directory and shows information about
-each available script including its purpose and key features.`,
-	Run: listScripts,
+When a script name appears in several paths, the first one wins: ./scripts,
+then GOCAT_SCRIPT_PATH, then $XDG_DATA_HOME/gocat/scripts. Use --json for
+the full manifests.`,
+	Args: cobra.NoArgs,
+	Run:  listScripts,
 }
 
 var scriptInfoCmd = &cobra.Command{
@@ -88,26 +125,140 @@ before execution.`,
 	Run:  validateScript,
 }
 
-func runScript(cmd *cobra.Command, args []string) {
-	scriptPath := args[0]
+var scriptTestCmd = &cobra.Command{
+	Use:   "test [paths...]",
+	Short: "Run Lua script tests",
+	Long: `Run the *_test.lua files found in the given files or directories
+(default: ./scripts/). Each file runs in its own sandbox and declares test
+cases with test(name, fn):
+
+  test("grabs the SSH banner", function()
+      local srv = mock.tcp{banner = "SSH-2.0-OpenSSH_9.6\r\n"}
+      local conn = assert(connect(srv.host, srv.port, "tcp"))
+      expect(conn:readline()):to_match("^SSH-2\\.0")
+  end)
+
+Test files may use assert.equal/same/matches/contains/fails, expect(value),
+skip(reason), include(path) to load the script under test, and mock.tcp{} /
+mock.udp{} to start fake servers on loopback with scripted responses.
+Loopback network access is always granted; other capabilities must be
+approved with --allow-cap. Results are reported as TAP or JUnit XML.
+
+Examples:
+  gocat script test
+  gocat script test ./probes --run banner
+  gocat script test ./probes --format junit --output report.xml`,
+	Run: runScriptTests,
+}
+
+var scriptInstallCmd = &cobra.Command{
+	Use:   "install <dir|package.tar.gz|script.lua>",
+	Short: "Install a script package",
+	Long: `Install a script package into the user script directory
+($XDG_DATA_HOME/gocat/scripts, default ~/.local/share/gocat/scripts).
+
+The source may be a package directory containing script.yaml, a .tar.gz
+archive of one, or a single .lua file. Installing over the same or a newer
+version requires --force.
+
+Example script.yaml:
+
+  name: smtp-probe
+  version: 1.2.0
+  description: Checks SMTP servers for STARTTLS
+  author: Jane Doe
+  main: probe.lua
+  capabilities: [net]
+  requires:
+    - gocat-utils >=1.0
+  args:
+    - {name: target, type: host, required: true}
+    - {name: ports, type: ports, default: "25,587"}
+
+Examples:
+  gocat script install ./smtp-probe
+  gocat script install smtp-probe-1.2.0.tar.gz --force
+  gocat script install probe.lua --dest ./scripts`,
+	Args: cobra.ExactArgs(1),
+	Run:  installScript,
+}
+
+func runScript(cmd *cobra.Command, rawArgs []string) {
+	name, err := scriptNameArg(cmd, rawArgs)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	if name == "" {
+		cmd.Help()
+		return
+	}
 
-	// Resolve script path
-	resolvedPath, err := resolveScriptPath(scriptPath)
+	script, err := scripting.FindScript(name, scripting.SearchPaths())
 	if err != nil {
 		logger.Error("Script resolution failed: %v", err)
 		os.Exit(1)
 	}
 
+	// Parse the command line again with the script's own arguments
+	flags := scriptRunFlags(cmd, script.Manifest)
+	if err := flags.Parse(append(rawArgs, deprecatedScriptArgs(rawArgs)...)); err != nil {
+		if err == pflag.ErrHelp {
+			printScriptUsage(cmd, script)
+			return
+		}
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	if help, _ := flags.GetBool("help"); help {
+		printScriptUsage(cmd, script)
+		return
+	}
+	if flags.NArg() > 1 {
+		logger.Error("Unexpected arguments: %s", strings.Join(flags.Args()[1:], " "))
+		os.Exit(1)
+	}
+	initConfig()
+
+	values := make(map[string]string)
+	flags.Visit(func(f *pflag.Flag) {
+		if f.Annotations[scriptArgAnnotation] != nil {
+			values[f.Name] = f.Value.String()
+		}
+	})
+	scriptArgs, err := script.Manifest.ResolveArgs(values)
+	if err != nil {
+		logger.Error("%v (see gocat script run %s --help)", err, name)
+		os.Exit(1)
+	}
+
 	// Validate script file size
-	if err := validateScriptFile(resolvedPath); err != nil {
+	if err := validateScriptFile(script.Path); err != nil {
 		logger.Error("Script validation failed: %v", err)
 		os.Exit(1)
 	}
 
-	logger.Info("Executing script: %s", resolvedPath)
+	config, err := scriptEngineConfig(script)
+	if err != nil {
+		logger.Error("Script sandbox setup failed: %v", err)
+		os.Exit(1)
+	}
+	config.Args = scriptArgs
+	if timeoutSeconds, _ := cmd.Flags().GetInt("timeout"); timeoutSeconds > 0 {
+		config.MaxExecutionTime = time.Duration(timeoutSeconds) * time.Second
+	}
+	if libs, _ := cmd.Flags().GetStringSlice("libs"); len(libs) > 0 {
+		config.Libraries = libs
+	}
+
+	logger.Info("Executing script: %s %s", script.Manifest.Name, script.Manifest.Version)
+	logger.Debug("Script path: %s", script.Path)
+	for _, capability := range config.Capabilities.List() {
+		logger.Debug("Granted capability: %s", capability)
+	}
 
 	// Create Lua engine
-	engine := scripting.NewLuaEngine(nil)
+	engine := scripting.NewLuaEngine(config)
 	if engine == nil {
 		logger.Error("Failed to create Lua engine")
 		os.Exit(1)
@@ -118,13 +269,17 @@ func runScript(cmd *cobra.Command, args []string) {
 		}
 	}()
 
+	// Cancel the script on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Load and execute script
-	if err := engine.LoadScript(resolvedPath); err != nil {
+	if err := engine.LoadScriptWithContext(ctx, script.Path); err != nil {
 		logger.Error("Failed to load script: %v", err)
 		os.Exit(1)
 	}
 
-	if err := engine.ExecuteScript(filepath.Base(scriptPath)); err != nil {
+	if err := engine.ExecuteScript(script.Manifest.Name); err != nil {
 		logger.Error("Script execution failed: %v", err)
 		os.Exit(1)
 	}
@@ -132,67 +287,171 @@ func runScript(cmd *cobra.Command, args []string) {
 	logger.Info("Script execution completed successfully")
 }
 
-func listScripts(cmd *cobra.Command, args []string) {
-	scriptsDir := scriptsDirectory
+// scriptArgAnnotation marks the flags generated from a script's args schema
+const scriptArgAnnotation = "gocat_script_arg"
 
-	// Check if scripts directory exists
-	if _, err := os.Stat(scriptsDir); os.IsNotExist(err) {
-		logger.Warn("Scripts directory not found: %s", scriptsDir)
-		logger.Info("Create a 'scripts' directory and add .lua files to get started")
-		return
+// scriptNameArg finds the script name among the raw run arguments, skipping
+// the flags of the run command and its parents. Unknown flags belong to the
+// script, so the name must come before them.
+func scriptNameArg(cmd *cobra.Command, rawArgs []string) (string, error) {
+	flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.Usage = func() {}
+	flags.AddFlagSet(cmd.LocalFlags())
+	flags.AddFlagSet(cmd.InheritedFlags())
+
+	if err := flags.Parse(rawArgs); err != nil && err != pflag.ErrHelp {
+		return "", err
 	}
+	return flags.Arg(0), nil
+}
 
-	// Read scripts directory
-	entries, err := os.ReadDir(scriptsDir)
-	if err != nil {
-		logger.Error("Failed to read scripts directory: %v", err)
-		return
+// scriptRunFlags builds the flag set for running a script: one flag per
+// declared argument plus the run command's own flags. Script arguments
+// shadow inherited global flags of the same name.
+func scriptRunFlags(cmd *cobra.Command, manifest *scripting.Manifest) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("gocat script run "+manifest.Name, pflag.ContinueOnError)
+	flags.Usage = func() {}
+
+	for _, arg := range manifest.Args {
+		usage := arg.Description
+		if len(arg.Choices) > 0 {
+			usage = strings.TrimSpace(fmt.Sprintf("%s (one of: %s)", usage, strings.Join(arg.Choices, ", ")))
+		}
+		flags.String(arg.Name, arg.Default, usage)
+		flag := flags.Lookup(arg.Name)
+		if arg.Type == scripting.ArgBool {
+			flag.NoOptDefVal = "true"
+		}
+		flag.Annotations = map[string][]string{scriptArgAnnotation: {arg.Type}}
 	}
 
-	// Filter Lua files
-	var luaScripts []string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), luaExtension) {
-			luaScripts = append(luaScripts, entry.Name())
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) { flags.AddFlag(f) })
+	cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		if flags.Lookup(f.Name) != nil {
+			return
+		}
+		if f.Shorthand != "" && flags.ShorthandLookup(f.Shorthand) != nil {
+			return
 		}
+		flags.AddFlag(f)
+	})
+	return flags
+}
+
+// deprecatedScriptArgs splits the value of the old --args flag so
+// "--args '--target x'" keeps working
+func deprecatedScriptArgs(rawArgs []string) []string {
+	for i, arg := range rawArgs {
+		switch {
+		case (arg == "--args" || arg == "-a") && i+1 < len(rawArgs):
+			return strings.Fields(rawArgs[i+1])
+		case strings.HasPrefix(arg, "--args="):
+			return strings.Fields(strings.TrimPrefix(arg, "--args="))
+		}
+	}
+	return nil
+}
+
+// printScriptUsage prints a script's arguments
+func printScriptUsage(cmd *cobra.Command, script *scripting.Script) {
+	m := script.Manifest
+	fmt.Printf("Usage: gocat script run %s [arguments]\n\n", m.Name)
+	if m.Description != "" {
+		fmt.Printf("%s\n\n", m.Description)
+	}
+
+	fmt.Println("Script arguments:")
+	if len(m.Args) == 0 {
+		fmt.Println("  none")
 	}
+	for _, arg := range m.Args {
+		fmt.Printf("  %s\n", formatArgSpec(arg))
+	}
+
+	fmt.Println("\nRun flags:")
+	fmt.Print(cmd.LocalFlags().FlagUsages())
+}
 
-	if len(luaScripts) == 0 {
-		logger.Info("No Lua scripts found in %s", scriptsDir)
+// formatArgSpec formats an argument as "--name type description (default)"
+func formatArgSpec(arg scripting.ArgSpec) string {
+	parts := []string{fmt.Sprintf("--%-20s %-9s", arg.Name, arg.Type)}
+	if arg.Description != "" {
+		parts = append(parts, arg.Description)
+	}
+	switch {
+	case arg.Required:
+		parts = append(parts, "(required)")
+	case arg.Default != "":
+		parts = append(parts, fmt.Sprintf("(default %s)", arg.Default))
+	}
+	if len(arg.Choices) > 0 {
+		parts = append(parts, fmt.Sprintf("[%s]", strings.Join(arg.Choices, "|")))
+	}
+	return strings.Join(parts, " ")
+}
+
+func listScripts(cmd *cobra.Command, args []string) {
+	paths := scripting.SearchPaths()
+	scripts, errs := scripting.ListScripts(paths)
+	for _, err := range errs {
+		logger.Warn("Skipping invalid script: %v", err)
+	}
+
+	if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+		if scripts == nil {
+			scripts = []*scripting.Script{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(scripts); err != nil {
+			logger.Error("Failed to encode script list: %v", err)
+			os.Exit(1)
+		}
 		return
 	}
 
+	if len(scripts) == 0 {
+		logger.Info("No Lua scripts found in %s", strings.Join(paths, ", "))
+		logger.Info("Create a 'scripts' directory and add .lua files, or use 'gocat script install'")
+		return
+	}
+
+	detailed, _ := cmd.Flags().GetBool("detailed")
+
 	// Display scripts with descriptions
 	fmt.Println("Available Lua Scripts:")
 	fmt.Println(strings.Repeat("=", 50))
 
-	for _, script := range luaScripts {
-		scriptPath := filepath.Join(scriptsDir, script)
-		description := getScriptDescription(scriptPath)
-		
-		fmt.Printf("📜 %s\n", script)
-		if description != "" {
-			fmt.Printf("   %s\n", description)
+	for _, script := range scripts {
+		m := script.Manifest
+		fmt.Printf("📜 %s %s\n", m.Name, m.Version)
+		if m.Description != "" {
+			fmt.Printf("   %s\n", m.Description)
+		}
+		if detailed {
+			fmt.Printf("   Path: %s\n", script.Path)
+			for _, arg := range m.Args {
+				fmt.Printf("   %s\n", formatArgSpec(arg))
+			}
 		}
 		fmt.Println()
 	}
 
-	fmt.Printf("Total scripts: %d\n", len(luaScripts))
-	fmt.Println("\nUsage: gocat script run <script_name>")
+	fmt.Printf("Total scripts: %d\n", len(scripts))
+	fmt.Println("\nUsage: gocat script run <script_name> [--arg value...]")
 }
 
 func showScriptInfo(cmd *cobra.Command, args []string) {
-	scriptPath := args[0]
-
-	// Resolve script path
-	resolvedPath, err := resolveScriptPath(scriptPath)
+	script, err := scripting.FindScript(args[0], scripting.SearchPaths())
 	if err != nil {
 		logger.Error("Script resolution failed: %v", err)
 		os.Exit(1)
 	}
+	m := script.Manifest
 
 	// Read script content
-	content, err := os.ReadFile(resolvedPath)
+	content, err := os.ReadFile(script.Path)
 	if err != nil {
 		logger.Error("Failed to read script: %v", err)
 		os.Exit(1)
@@ -200,14 +459,20 @@ func showScriptInfo(cmd *cobra.Command, args []string) {
 
 	// Parse script information
 	info := parseScriptInfo(string(content))
-	
-	fmt.Printf("Script Information: %s\n", filepath.Base(resolvedPath))
+
+	fmt.Printf("Script Information: %s\n", m.Name)
 	fmt.Println(strings.Repeat("=", 50))
-	
-	if info.Purpose != "" {
+	fmt.Printf("Version: %s\n", m.Version)
+	if m.Author != "" {
+		fmt.Printf("Author: %s\n", m.Author)
+	}
+	if m.Description != "" {
+		fmt.Printf("Description: %s\n", m.Description)
+	}
+	if info.Purpose != "" && info.Purpose != m.Description {
 		fmt.Printf("Purpose: %s\n", info.Purpose)
 	}
-	
+
 	if len(info.Features) > 0 {
 		fmt.Println("\nFeatures:")
 		for _, feature := range info.Features {
@@ -215,6 +480,26 @@ func showScriptInfo(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if len(m.Args) > 0 {
+		fmt.Println("\nArguments:")
+		for _, arg := range m.Args {
+			fmt.Printf("  %s\n", formatArgSpec(arg))
+		}
+	}
+
+	if len(m.Requires) > 0 {
+		fmt.Println("\nRequires:")
+		for _, dep := range m.Requires {
+			status := "installed"
+			if found, err := scripting.FindScript(dep.Name, scripting.SearchPaths()); err != nil {
+				status = "missing"
+			} else if ok, _ := scripting.VersionSatisfies(found.Manifest.Version, dep.Version); !ok {
+				status = "found " + found.Manifest.Version
+			}
+			fmt.Printf("  • %-30s %s\n", dep, status)
+		}
+	}
+
 	if len(info.Functions) > 0 {
 		fmt.Println("\nAvailable Functions:")
 		for _, function := range info.Functions {
@@ -226,22 +511,34 @@ func showScriptInfo(cmd *cobra.Command, args []string) {
 		fmt.Printf("\nUsage Example:\n%s\n", info.Usage)
 	}
 
+	capabilities, err := m.DeclaredCapabilities()
+	if err != nil {
+		logger.Warn("Invalid capability declaration: %v", err)
+	}
+	fmt.Println("\nCapabilities:")
+	if len(capabilities) == 0 {
+		fmt.Println("  none (sandboxed, no network, filesystem or exec access)")
+	}
+	for _, capability := range capabilities {
+		fmt.Printf("  • %-30s %s\n", capability, capability.Description())
+	}
+
 	// Show file stats
-	fileInfo, _ := os.Stat(resolvedPath)
+	fileInfo, _ := os.Stat(script.Path)
 	fmt.Printf("\nFile Information:\n")
+	fmt.Printf("  Path: %s\n", script.Path)
 	fmt.Printf("  Size: %d bytes\n", fileInfo.Size())
 	fmt.Printf("  Modified: %s\n", fileInfo.ModTime().Format("2006-01-02 15:04:05"))
 }
 
 func validateScript(cmd *cobra.Command, args []string) {
-	scriptPath := args[0]
-
-	// Resolve script path
-	resolvedPath, err := resolveScriptPath(scriptPath)
+	// Resolving the script also validates its manifest
+	script, err := scripting.FindScript(args[0], scripting.SearchPaths())
 	if err != nil {
-		logger.Error("Script resolution failed: %v", err)
+		logger.Error("Script validation failed: %v", err)
 		os.Exit(1)
 	}
+	resolvedPath := script.Path
 
 	logger.Info("Validating script: %s", resolvedPath)
 
@@ -272,59 +569,146 @@ func validateScript(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// Check that required packages are installed
+	if _, err := scripting.ResolveDependencies(script, scripting.SearchPaths()); err != nil {
+		logger.Error("Script validation failed: %v", err)
+		os.Exit(1)
+	}
+
 	logger.Info("✅ Script validation passed - syntax is correct")
 }
 
-// resolveScriptPath resolves script path with fallback logic
-func resolveScriptPath(scriptPath string) (string, error) {
-	// If absolute path, use as-is
-	if filepath.IsAbs(scriptPath) {
-		if _, err := os.Stat(scriptPath); err != nil {
-			return "", fmt.Errorf("script not found: %s", scriptPath)
+func runScriptTests(cmd *cobra.Command, args []string) {
+	paths := args
+	if len(paths) == 0 {
+		paths = []string{scriptsDirectory}
+	}
+
+	files, err := scripting.DiscoverTestScripts(paths)
+	if err != nil {
+		logger.Error("Test discovery failed: %v", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		logger.Error("No *%s files found in %s", scripting.TestFileSuffix, strings.Join(paths, ", "))
+		os.Exit(1)
+	}
+
+	runner := &scripting.TestRunner{}
+	runner.Approved, _ = rootCmd.PersistentFlags().GetStringSlice("allow-cap")
+	if timeoutSeconds, _ := cmd.Flags().GetInt("timeout"); timeoutSeconds > 0 {
+		runner.Timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	if libs, _ := cmd.Flags().GetStringSlice("libs"); len(libs) > 0 {
+		runner.Libraries = libs
+	}
+	if pattern, _ := cmd.Flags().GetString("run"); pattern != "" {
+		filter, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Error("Invalid --run pattern: %v", err)
+			os.Exit(1)
 		}
-		return scriptPath, nil
+		runner.Filter = filter
 	}
 
-	// If relative path with directory, use as-is
-	if strings.Contains(scriptPath, "/") || strings.Contains(scriptPath, "\\") {
-		if _, err := os.Stat(scriptPath); err != nil {
-			return "", fmt.Errorf("script not found: %s", scriptPath)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var suites []scripting.TestSuite
+	passed, failed, skipped := 0, 0, 0
+	for _, file := range files {
+		suite := runner.RunFile(ctx, file)
+		suites = append(suites, suite)
+		passed += suite.Count(scripting.TestPassed)
+		failed += suite.Count(scripting.TestFailed)
+		skipped += suite.Count(scripting.TestSkipped)
+		if ctx.Err() != nil {
+			break
 		}
-		return scriptPath, nil
 	}
 
-	// Try scripts directory first
-	scriptsPath := filepath.Join("scripts", scriptPath)
-	if _, err := os.Stat(scriptsPath); err == nil {
-		return scriptsPath, nil
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	out := os.Stdout
+	if output != "" && output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			logger.Error("Cannot create report file: %v", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := scripting.WriteTestReport(out, format, suites); err != nil {
+		logger.Error("Failed to write test report: %v", err)
+		os.Exit(1)
 	}
 
-	// Try current directory
-	if _, err := os.Stat(scriptPath); err == nil {
-		return scriptPath, nil
+	// The summary goes to stderr so the report on stdout stays machine readable
+	fmt.Fprintf(os.Stderr, "%d files, %d passed, %d failed, %d skipped\n", len(suites), passed, failed, skipped)
+	if failed > 0 || ctx.Err() != nil {
+		if out != os.Stdout {
+			out.Close()
+		}
+		os.Exit(1)
 	}
+}
 
-	return "", fmt.Errorf("script not found: %s (searched in ./scripts/ and current directory)", scriptPath)
+func installScript(cmd *cobra.Command, args []string) {
+	dest, _ := cmd.Flags().GetString("dest")
+	if dest == "" {
+		dest = scripting.UserScriptDir()
+		if dest == "" {
+			logger.Error("Cannot determine the user script directory, use --dest")
+			os.Exit(1)
+		}
+	}
+	force, _ := cmd.Flags().GetBool("force")
+
+	script, err := scripting.InstallScript(args[0], dest, force)
+	if err != nil {
+		logger.Error("Install failed: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := scripting.ResolveDependencies(script, scripting.SearchPaths()); err != nil {
+		logger.Warn("Unresolved dependencies: %v", err)
+	}
+	logger.Info("Installed %s %s to %s", script.Manifest.Name, script.Manifest.Version, script.Dir)
 }
 
-// getScriptDescription extracts description from script comments
-func getScriptDescription(scriptPath string) string {
-	content, err := os.ReadFile(scriptPath)
+// luaEngineConfig builds the engine configuration for the script at
+// scriptPath, which may be a .lua file or a package directory
+func luaEngineConfig(scriptPath string) (*scripting.EngineConfig, error) {
+	script, err := scripting.OpenScript(scriptPath)
 	if err != nil {
-		return ""
+		return nil, err
 	}
+	return scriptEngineConfig(script)
+}
 
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "--") && len(line) > 2 {
-			desc := strings.TrimSpace(line[2:])
-			if len(desc) > 0 && !strings.Contains(desc, "Script for GoCat") {
-				return desc
-			}
-		}
+// scriptEngineConfig builds the engine configuration for a script, granting
+// the capabilities declared in its manifest that were approved with
+// --allow-cap and making its dependencies available to require()
+func scriptEngineConfig(script *scripting.Script) (*scripting.EngineConfig, error) {
+	approved, _ := rootCmd.PersistentFlags().GetStringSlice("allow-cap")
+
+	declared, err := script.Manifest.DeclaredCapabilities()
+	if err != nil {
+		return nil, err
 	}
-	return ""
+	capabilities, err := scripting.ApproveCapabilities(declared, approved)
+	if err != nil {
+		return nil, err
+	}
+
+	config := scripting.DefaultEngineConfig()
+	config.Capabilities = capabilities
+	config.Upstreams = upstreams()
+	if err := script.ConfigureModules(config, scripting.SearchPaths()); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
 // ScriptInfo holds parsed script information
@@ -362,6 +746,10 @@ func parseScriptInfo(content string) ScriptInfo {
 				}
 			}
 
+			if strings.HasPrefix(comment, "Capability:") {
+				continue
+			}
+
 			if strings.Contains(comment, "Usage") || strings.Contains(comment, "Example") {
 				inUsageBlock = true
 				continue
@@ -430,13 +818,27 @@ func init() {
 	scriptCmd.AddCommand(scriptListCmd)
 	scriptCmd.AddCommand(scriptInfoCmd)
 	scriptCmd.AddCommand(scriptValidateCmd)
+	scriptCmd.AddCommand(scriptTestCmd)
+	scriptCmd.AddCommand(scriptInstallCmd)
 
 	// Add flags
 	scriptRunCmd.Flags().StringP("args", "a", "", "Arguments to pass to the script")
+	scriptRunCmd.Flags().MarkDeprecated("args", "pass script arguments as flags after the script name")
 	scriptRunCmd.Flags().BoolP("verbose", "v", false, "Verbose script execution")
-	scriptRunCmd.Flags().Int("timeout", 0, "Script execution timeout in seconds")
+	scriptRunCmd.Flags().Int("timeout", 0, "Script execution timeout in seconds (default 30)")
+	scriptRunCmd.Flags().StringSlice("libs", nil, "Lua standard libraries to open (default: base,table,string,math,coroutine,os,io)")
 
 	scriptListCmd.Flags().Bool("detailed", false, "Show detailed information")
+	scriptListCmd.Flags().Bool("json", false, "Print the script manifests as JSON")
+
+	scriptInstallCmd.Flags().String("dest", "", "Install directory (default $XDG_DATA_HOME/gocat/scripts)")
+	scriptInstallCmd.Flags().Bool("force", false, "Replace an installed script of the same or a newer version")
+
+	scriptTestCmd.Flags().String("format", scripting.ReportTAP, "Report format: tap or junit")
+	scriptTestCmd.Flags().StringP("output", "o", "", "Write the report to a file instead of stdout")
+	scriptTestCmd.Flags().String("run", "", "Only run tests whose name matches this regular expression")
+	scriptTestCmd.Flags().Int("timeout", 0, "Per-test timeout in seconds (default 30)")
+	scriptTestCmd.Flags().StringSlice("libs", nil, "Lua standard libraries to open (default: base,table,string,math,coroutine,os,io)")
 
 	// Add to root command
 	rootCmd.AddCommand(scriptCmd)
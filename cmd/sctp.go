@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+)
+
+// sctpConfig is the SCTP setup of connect and listen, built by loadSCTPConfig
+var sctpConfig = network.DefaultSCTPConfig()
+
+// loadSCTPConfig builds sctpConfig from the global --sctp-streams,
+// --sctp-stream, --sctp-ppid and --nodelay flags. Association and peer
+// address events are logged as they happen.
+func loadSCTPConfig(cmd *cobra.Command) {
+	flags := cmd.Root().PersistentFlags()
+	config := network.DefaultSCTPConfig()
+	if streams, _ := flags.GetInt("sctp-streams"); streams > 0 {
+		config.Streams = streams
+	}
+	config.Stream, _ = flags.GetUint16("sctp-stream")
+	config.PPID, _ = flags.GetUint32("sctp-ppid")
+	config.Nodelay, _ = flags.GetBool("nodelay")
+	if config.Stream >= uint16(config.Streams) {
+		logger.Fatal("--sctp-stream %d is out of the %d streams of --sctp-streams", config.Stream, config.Streams)
+	}
+	config.OnNotification = func(n network.SCTPNotification) {
+		logger.Info("SCTP association %d: %s", n.AssocID, n)
+	}
+	sctpConfig = config
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -135,9 +136,9 @@ func sendFile(filePath, host, port string) error {
 
 	// Connect to remote host
 	address := net.JoinHostPort(host, port)
-	conn, err := net.DialTimeout("tcp", address, transferTimeout)
+	conn, err := outboundDialer(0, transferTimeout).DialContext(context.Background(), "tcp", address)
 	if err != nil {
-		return fmt.Errorf("connection failed: %w", err)
+		return fmt.Errorf("connection failed: %w", dialCause(err))
 	}
 	defer conn.Close()
 
@@ -160,7 +161,7 @@ func sendFile(filePath, host, port string) error {
 // accepting the connection, reading metadata, creating the destination file,
 // or the data transfer fail.
 func receiveFile(port, outputFile string) error {
-	listener, err := net.Listen("tcp", ":"+port)
+	listener, err := listenTCP("tcp", ":"+port)
 	if err != nil {
 		return fmt.Errorf("failed to listen on port %s: %w", port, err)
 	}
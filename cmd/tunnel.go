@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ibrahmsql/gocat/internal/admin"
 	"github.com/ibrahmsql/gocat/internal/logger"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh"
@@ -125,6 +132,12 @@ func runTunnel(cmd *cobra.Command, args []string) {
 
 	logger.Info("SSH connection established")
 
+	loadConnectionPolicy(cmd)
+	startAdminSocket(cmd, func(srv *admin.Server) {
+		registerTunnelAdmin(srv, client)
+	})
+	startMetricsExporter(cmd)
+
 	// Create tunnel based on mode
 	if tunnelDynamic {
 		// Dynamic SOCKS proxy
@@ -196,31 +209,20 @@ func parseSSHConnection(conn string) (user, host, port string) {
 }
 
 // runLocalTunnel starts a local TCP listener on localAddr and forwards each incoming connection to remoteAddr through the provided SSH client.
-// It accepts connections in a loop and handles each connection concurrently; the listener is closed when the function returns.
+// It serves until the SSH connection closes.
 func runLocalTunnel(client *ssh.Client, localAddr, remoteAddr string) {
 	logger.Info("Starting local tunnel: %s -> %s", localAddr, remoteAddr)
 
-	listener, err := net.Listen("tcp", localAddr)
-	if err != nil {
+	if _, err := openTunnelForward(client, tunnelLocalForward, localAddr, remoteAddr); err != nil {
 		logger.Fatal("Failed to listen on %s: %v", localAddr, err)
 	}
-	defer listener.Close()
 
 	logger.Info("Local tunnel listening on %s", localAddr)
-
-	for {
-		localConn, err := listener.Accept()
-		if err != nil {
-			logger.Error("Accept error: %v", err)
-			continue
-		}
-
-		go handleLocalTunnelConnection(client, localConn, remoteAddr)
-	}
+	waitForTunnel(client)
 }
 
 // handleLocalTunnelConnection forwards data between an accepted local connection and a remote address over the provided SSH client.
-// It dials the remote address through the SSH connection, performs bidirectional copying of data until one side closes, and ensures both connections are closed when finished.
+// It dials the remote address through the SSH connection, relays data until both sides are done, and ensures both connections are closed when finished.
 func handleLocalTunnelConnection(client *ssh.Client, localConn net.Conn, remoteAddr string) {
 	defer localConn.Close()
 
@@ -231,114 +233,73 @@ func handleLocalTunnelConnection(client *ssh.Client, localConn net.Conn, remoteA
 		return
 	}
 	defer remoteConn.Close()
+	if err := sendProxyHeader(remoteConn, localConn.RemoteAddr(), localConn.LocalAddr()); err != nil {
+		logger.Error("Failed to send PROXY header to %s: %v", remoteAddr, err)
+		return
+	}
 
 	logger.Debug("Tunnel established: %s <-> %s", localConn.RemoteAddr(), remoteAddr)
 
-	// Bidirectional copy
-	done := make(chan struct{}, 2)
-
-	go func() {
-		io.Copy(remoteConn, localConn)
-		done <- struct{}{}
-	}()
-
-	go func() {
-		io.Copy(localConn, remoteConn)
-		done <- struct{}{}
-	}()
-
-	<-done
+	relayConns(localConn, remoteConn)
 }
 
 // runReverseTunnel starts a reverse SSH tunnel by asking the SSH server to listen on remoteAddr
 // and forwarding each incoming remote connection to localAddr on the client side.
 // remoteAddr and localAddr are network addresses (for example "host:port" or ":port").
-// The function accepts connections in a loop and forwards them concurrently; it logs a fatal error
-// if it fails to establish the remote listener.
+// It serves until the SSH connection closes and logs a fatal error if it fails to establish
+// the remote listener.
 func runReverseTunnel(client *ssh.Client, localAddr, remoteAddr string) {
 	logger.Info("Starting reverse tunnel: %s <- %s", remoteAddr, localAddr)
 
-	// Listen on remote server
-	listener, err := client.Listen("tcp", remoteAddr)
-	if err != nil {
+	if _, err := openTunnelForward(client, tunnelReverseForward, remoteAddr, localAddr); err != nil {
 		logger.Fatal("Failed to listen on remote %s: %v", remoteAddr, err)
 	}
-	defer listener.Close()
 
 	logger.Info("Reverse tunnel listening on remote %s", remoteAddr)
-
-	for {
-		remoteConn, err := listener.Accept()
-		if err != nil {
-			logger.Error("Accept error: %v", err)
-			continue
-		}
-
-		go handleReverseTunnelConnection(remoteConn, localAddr)
-	}
+	waitForTunnel(client)
 }
 
 // handleReverseTunnelConnection establishes a TCP connection to localAddr and proxies data
 // bidirectionally between the provided remoteConn and the newly created local connection
-// until one side closes. Both connections are closed when the function returns; if dialing
+// until both sides are done. Both connections are closed when the function returns; if dialing
 // localAddr fails the function logs the error and returns.
 func handleReverseTunnelConnection(remoteConn net.Conn, localAddr string) {
 	defer remoteConn.Close()
 
 	// Connect to local address
-	localConn, err := net.Dial("tcp", localAddr)
+	localConn, err := dialOut("tcp", localAddr)
 	if err != nil {
 		logger.Error("Failed to connect to local %s: %v", localAddr, err)
 		return
 	}
 	defer localConn.Close()
+	if err := sendProxyHeader(localConn, remoteConn.RemoteAddr(), remoteConn.LocalAddr()); err != nil {
+		logger.Error("Failed to send PROXY header to %s: %v", localAddr, err)
+		return
+	}
 
 	logger.Debug("Reverse tunnel established: %s <-> %s", remoteConn.RemoteAddr(), localAddr)
 
-	// Bidirectional copy
-	done := make(chan struct{}, 2)
-
-	go func() {
-		io.Copy(localConn, remoteConn)
-		done <- struct{}{}
-	}()
-
-	go func() {
-		io.Copy(remoteConn, localConn)
-		done <- struct{}{}
-	}()
-
-	<-done
+	relayConns(remoteConn, localConn)
 }
 
 // runDynamicTunnel starts a SOCKS5 proxy bound to localAddr that forwards proxied
-// connections through the provided SSH client. It listens for incoming TCP
-// connections on localAddr and handles each accepted connection concurrently.
+// connections through the provided SSH client. It serves until the SSH
+// connection closes.
 func runDynamicTunnel(client *ssh.Client, localAddr string) {
 	logger.Info("Starting dynamic SOCKS proxy on %s", localAddr)
 
-	listener, err := net.Listen("tcp", localAddr)
-	if err != nil {
+	if _, err := openTunnelForward(client, tunnelDynamicForward, localAddr, ""); err != nil {
 		logger.Fatal("Failed to listen on %s: %v", localAddr, err)
 	}
-	defer listener.Close()
 
 	logger.Info("SOCKS proxy listening on %s", localAddr)
-
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			logger.Error("Accept error: %v", err)
-			continue
-		}
-
-		go handleSOCKSConnection(client, conn)
-	}
+	waitForTunnel(client)
 }
 
 // handleSOCKSConnection handles a single SOCKS5 client connection on conn, negotiates the SOCKS5 handshake,
 // resolves the target address (IPv4 or domain name), establishes a TCP connection to that target through
-// the provided SSH client, and proxies data bidirectionally until one side closes.
+// the provided SSH client, and proxies data bidirectionally until both sides are done.
 //
 // It supports IPv4 and domain-name address types; IPv6 is not supported. On failure to establish the
 // remote connection a SOCKS failure response is sent. The client connection is closed when this function returns.
@@ -419,21 +380,186 @@ func handleSOCKSConnection(client *ssh.Client, conn net.Conn) {
 	}
 	defer remoteConn.Close()
 
+	if err := sendProxyHeader(remoteConn, conn.RemoteAddr(), conn.LocalAddr()); err != nil {
+		logger.Error("Failed to send PROXY header to %s: %v", targetAddr, err)
+		conn.Write([]byte{0x05, 0x01, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return
+	}
+
 	// Send success response
 	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
 
-	// Bidirectional copy
-	done := make(chan struct{}, 2)
+	relayConns(conn, remoteConn)
+}
 
-	go func() {
-		io.Copy(remoteConn, conn)
-		done <- struct{}{}
-	}()
+// Tunnel forward types
+const (
+	tunnelLocalForward   = "local"
+	tunnelReverseForward = "reverse"
+	tunnelDynamicForward = "dynamic"
+)
+
+// tunnelForward is a listener of the tunnel command. The forward given on
+// the command line is the first; more can be added over the admin API.
+type tunnelForward struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Listen  string    `json:"listen"`
+	Target  string    `json:"target,omitempty"`
+	Started time.Time `json:"started"`
 
-	go func() {
-		io.Copy(conn, remoteConn)
-		done <- struct{}{}
-	}()
+	listener net.Listener
+}
+
+var tunnelForwards = struct {
+	forwards map[string]*tunnelForward
+	nextID   int
+	mu       sync.Mutex
+}{
+	forwards: make(map[string]*tunnelForward),
+}
 
-	<-done
-}
\ No newline at end of file
+// openTunnelForward starts forwarding connections accepted on listenAddr:
+// local and dynamic forwards listen locally, reverse forwards on the SSH
+// server. target is the address connections are forwarded to, unused for
+// dynamic forwards.
+func openTunnelForward(client *ssh.Client, kind, listenAddr, target string) (*tunnelForward, error) {
+	var listener net.Listener
+	var err error
+	switch kind {
+	case tunnelLocalForward, tunnelDynamicForward:
+		listener, err = listenGuarded(listenAddr)
+	case tunnelReverseForward:
+		listener, err = client.Listen("tcp", listenAddr)
+		if err == nil {
+			listener = guardListener(listener)
+		}
+	default:
+		return nil, fmt.Errorf("unknown forward type: %s", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tunnelForwards.mu.Lock()
+	tunnelForwards.nextID++
+	fwd := &tunnelForward{
+		ID:       "f" + strconv.Itoa(tunnelForwards.nextID),
+		Type:     kind,
+		Listen:   listener.Addr().String(),
+		Target:   target,
+		Started:  time.Now(),
+		listener: listener,
+	}
+	tunnelForwards.forwards[fwd.ID] = fwd
+	tunnelForwards.mu.Unlock()
+
+	go serveTunnelForward(client, fwd)
+	return fwd, nil
+}
+
+// serveTunnelForward accepts connections on fwd until it is closed and hands
+// them to the handler for its type
+func serveTunnelForward(client *ssh.Client, fwd *tunnelForward) {
+	defer closeTunnelForward(fwd.ID)
+
+	for {
+		conn, err := fwd.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+				return
+			}
+			logger.Error("Accept error on %s: %v", fwd.Listen, err)
+			continue
+		}
+
+		switch fwd.Type {
+		case tunnelLocalForward:
+			serveConn(conn, func(c net.Conn) { handleLocalTunnelConnection(client, c, fwd.Target) })
+		case tunnelReverseForward:
+			serveConn(conn, func(c net.Conn) { handleReverseTunnelConnection(c, fwd.Target) })
+		case tunnelDynamicForward:
+			serveConn(conn, func(c net.Conn) { handleSOCKSConnection(client, c) })
+		}
+	}
+}
+
+// closeTunnelForward stops the forward with the given ID; open connections
+// are left to finish
+func closeTunnelForward(id string) error {
+	tunnelForwards.mu.Lock()
+	fwd, ok := tunnelForwards.forwards[id]
+	delete(tunnelForwards.forwards, id)
+	tunnelForwards.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown forward: %s", id)
+	}
+	return fwd.listener.Close()
+}
+
+// listTunnelForwards returns the open forwards, oldest first
+func listTunnelForwards() []*tunnelForward {
+	tunnelForwards.mu.Lock()
+	list := make([]*tunnelForward, 0, len(tunnelForwards.forwards))
+	for _, fwd := range tunnelForwards.forwards {
+		list = append(list, fwd)
+	}
+	tunnelForwards.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// waitForTunnel blocks until the SSH connection closes
+func waitForTunnel(client *ssh.Client) {
+	if err := client.Wait(); err != nil {
+		logger.Error("SSH connection closed: %v", err)
+		return
+	}
+	logger.Info("SSH connection closed")
+}
+
+// registerTunnelAdmin adds methods to list, add and remove forwards to the
+// admin API
+func registerTunnelAdmin(srv *admin.Server, client *ssh.Client) {
+	srv.Handle("forwards.list", "List tunnel forwards", func(json.RawMessage) (interface{}, error) {
+		return listTunnelForwards(), nil
+	})
+
+	srv.Handle("forwards.add", "Add a forward: {\"type\": \"local|reverse|dynamic\", \"listen\": \":8080\", \"target\": \"host:80\"}", func(params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Type   string `json:"type"`
+			Listen string `json:"listen"`
+			Target string `json:"target"`
+		}
+		if err := admin.DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Type == "" {
+			p.Type = tunnelLocalForward
+		}
+		if p.Listen == "" {
+			return nil, admin.InvalidParams("missing listen address")
+		}
+		if p.Target == "" && p.Type != tunnelDynamicForward {
+			return nil, admin.InvalidParams("missing target address")
+		}
+		fwd, err := openTunnelForward(client, p.Type, p.Listen, p.Target)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Forward %s added via admin API: %s %s -> %s", fwd.ID, fwd.Type, fwd.Listen, fwd.Target)
+		return fwd, nil
+	})
+
+	srv.Handle("forwards.remove", "Stop a forward: {\"id\": \"f2\"}", func(params json.RawMessage) (interface{}, error) {
+		id, err := decodeID(params)
+		if err != nil {
+			return nil, err
+		}
+		if err := closeTunnelForward(id); err != nil {
+			return nil, err
+		}
+		logger.Info("Forward %s removed via admin API", id)
+		return map[string]string{"removed": id}, nil
+	})
+}
@@ -2,24 +2,34 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"syscall"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/relay"
 	"github.com/spf13/cobra"
 )
 
 var (
 	unixSocketPath    string
-	unixSocketType    string // "stream" or "datagram"
+	unixSocketType    string // "stream", "datagram" or "seqpacket"
 	unixRemoveExisting bool
 	unixPermissions   uint32
 	unixBufferSize    int
+	unixAllowUIDs     []uint
+	unixAllowGIDs     []uint
+	unixAllowPIDs     []uint
+	unixSendFDs       []string
+	unixRecvFDs       bool
 )
 
 // unixCmd represents the Unix domain socket command
@@ -43,7 +53,16 @@ Examples:
   gocat unix echo /tmp/echo.sock
 
   # Datagram socket
-  gocat unix listen --type datagram /tmp/gocat.sock`,
+  gocat unix listen --type datagram /tmp/gocat.sock
+
+  # Abstract socket (Linux), seqpacket, only for root
+  gocat unix listen --type seqpacket --allow-uid 0 @gocat
+
+  # Pass stdin and a file to a daemon along with the first data
+  gocat unix connect --send-fds 0,/etc/hostname /run/daemon.sock
+
+  # Who serves a socket
+  gocat unix info /run/containerd/containerd.sock`,
 }
 
 // unixListenCmd handles Unix socket server
@@ -53,7 +72,12 @@ var unixListenCmd = &cobra.Command{
 	Long: `Start a Unix domain socket server that accepts connections.
 
 The server will create a socket file at the specified path and accept
-connections, relaying data between stdin/stdout and the socket.`,
+connections, relaying data between stdin/stdout and the socket. Paths
+starting with '@' are in the Linux abstract namespace and have no file.
+
+The credentials of each client (SO_PEERCRED) are logged, and --allow-uid,
+--allow-gid and --allow-pid admit only matching clients. Datagram peers
+have no credentials and are served as sessions, one per client address.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runUnixListen,
 }
@@ -65,6 +89,10 @@ var unixConnectCmd = &cobra.Command{
 	Short:   "Connect to a Unix domain socket",
 	Long: `Connect to a Unix domain socket and relay data between stdin/stdout.
 
+--send-fds passes descriptors with SCM_RIGHTS along with the first data
+sent: numbers are descriptors of gocat, anything else a file to open.
+--recv-fds reports the descriptors the server passes.
+
 Examples:
   gocat unix connect /tmp/gocat.sock
   echo "Hello" | gocat unix connect /tmp/gocat.sock`,
@@ -81,25 +109,47 @@ var unixEchoCmd = &cobra.Command{
 	RunE:  runUnixEcho,
 }
 
+// unixInfoCmd shows what a socket is and who serves it
+var unixInfoCmd = &cobra.Command{
+	Use:   "info [socket-path]",
+	Short: "Show a Unix socket and the process serving it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runUnixInfo,
+}
+
 func init() {
 	rootCmd.AddCommand(unixCmd)
 	unixCmd.AddCommand(unixListenCmd)
 	unixCmd.AddCommand(unixConnectCmd)
 	unixCmd.AddCommand(unixEchoCmd)
+	unixCmd.AddCommand(unixInfoCmd)
 
 	// Listen flags
-	unixListenCmd.Flags().StringVar(&unixSocketType, "type", "stream", "Socket type (stream or datagram)")
+	unixListenCmd.Flags().StringVar(&unixSocketType, "type", "stream", "Socket type (stream, datagram or seqpacket)")
 	unixListenCmd.Flags().BoolVar(&unixRemoveExisting, "remove", true, "Remove existing socket file")
 	unixListenCmd.Flags().Uint32Var(&unixPermissions, "permissions", 0660, "Socket file permissions")
 	unixListenCmd.Flags().IntVar(&unixBufferSize, "buffer", 8192, "Buffer size for I/O operations")
+	unixListenCmd.Flags().BoolVar(&unixRecvFDs, "recv-fds", false, "Report file descriptors passed by clients")
+	addUnixPeerFlags(unixListenCmd)
 
 	// Connect flags
-	unixConnectCmd.Flags().StringVar(&unixSocketType, "type", "stream", "Socket type (stream or datagram)")
+	unixConnectCmd.Flags().StringVar(&unixSocketType, "type", "stream", "Socket type (stream, datagram or seqpacket)")
 	unixConnectCmd.Flags().IntVar(&unixBufferSize, "buffer", 8192, "Buffer size for I/O operations")
+	unixConnectCmd.Flags().StringSliceVar(&unixSendFDs, "send-fds", nil, "Descriptors or files to pass with the first data")
+	unixConnectCmd.Flags().BoolVar(&unixRecvFDs, "recv-fds", false, "Report file descriptors passed by the server")
 
 	// Echo flags
+	unixEchoCmd.Flags().StringVar(&unixSocketType, "type", "stream", "Socket type (stream, datagram or seqpacket)")
 	unixEchoCmd.Flags().BoolVar(&unixRemoveExisting, "remove", true, "Remove existing socket file")
 	unixEchoCmd.Flags().Uint32Var(&unixPermissions, "permissions", 0660, "Socket file permissions")
+	addUnixPeerFlags(unixEchoCmd)
+}
+
+// addUnixPeerFlags adds the credential ACL flags of a server command
+func addUnixPeerFlags(cmd *cobra.Command) {
+	cmd.Flags().UintSliceVar(&unixAllowUIDs, "allow-uid", nil, "Only accept clients running as these user IDs")
+	cmd.Flags().UintSliceVar(&unixAllowGIDs, "allow-gid", nil, "Only accept clients running as these group IDs")
+	cmd.Flags().UintSliceVar(&unixAllowPIDs, "allow-pid", nil, "Only accept these client processes")
 }
 
 func runUnixListen(cmd *cobra.Command, args []string) error {
@@ -110,6 +160,18 @@ func runUnixListen(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// Determine network type
+	netType, err := unixNetwork(unixSocketType)
+	if err != nil {
+		return err
+	}
+	if netType == "unixgram" && unixRecvFDs {
+		return fmt.Errorf("--recv-fds needs a stream or seqpacket socket")
+	}
+	if netType == "unixgram" && unixPeerPolicySet() {
+		return fmt.Errorf("datagram clients have no credentials to check")
+	}
+
 	// Remove existing socket if requested
 	if unixRemoveExisting {
 		if err := removeSocketIfExists(socketPath); err != nil {
@@ -117,58 +179,17 @@ func runUnixListen(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Determine network type
-	network := "unix"
-	if unixSocketType == "datagram" {
-		network = "unixgram"
-	}
-
-	logger.Info("Starting Unix socket server: %s (type: %s)", socketPath, network)
+	logger.Info("Starting Unix socket server: %s (type: %s)", socketPath, netType)
 
 	// Create listener
-	listener, err := net.Listen(network, socketPath)
+	listener, err := listenUnixSocket(netType, socketPath)
 	if err != nil {
-		return fmt.Errorf("failed to create listener: %w", err)
-	}
-	defer listener.Close()
-
-	// Set permissions
-	if err := os.Chmod(socketPath, os.FileMode(unixPermissions)); err != nil {
-		logger.Warn("Failed to set socket permissions: %v", err)
+		return err
 	}
 
 	logger.Info("Unix socket listening on %s", socketPath)
 
-	// Setup signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	// Accept connections
-	go func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				select {
-				case <-sigChan:
-					return
-				default:
-					logger.Error("Accept error: %v", err)
-					continue
-				}
-			}
-
-			logger.Info("Connection accepted")
-			go handleUnixConnection(conn)
-		}
-	}()
-
-	// Wait for interrupt
-	<-sigChan
-	logger.Info("Shutting down Unix socket server...")
-	
-	// Cleanup socket file
-	os.Remove(socketPath)
-	
+	serveUnix(listener, socketPath, "Unix socket server", handleUnixConnection)
 	return nil
 }
 
@@ -176,80 +197,53 @@ func runUnixConnect(cmd *cobra.Command, args []string) error {
 	socketPath := args[0]
 
 	// Determine network type
-	network := "unix"
-	if unixSocketType == "datagram" {
-		network = "unixgram"
+	netType, err := unixNetwork(unixSocketType)
+	if err != nil {
+		return err
+	}
+	if network.IsAbstractUnixPath(socketPath) && !network.AbstractUnixSockets {
+		return fmt.Errorf("abstract sockets are only available on Linux: %s", socketPath)
+	}
+	files, err := openPassedFiles(unixSendFDs)
+	if err != nil {
+		return err
 	}
 
-	logger.Info("Connecting to Unix socket: %s (type: %s)", socketPath, network)
+	logger.Info("Connecting to Unix socket: %s (type: %s)", socketPath, netType)
+
+	// Datagram clients need an address of their own to get replies
+	var laddr *net.UnixAddr
+	if netType == "unixgram" {
+		laddr = network.UnixgramClientAddr()
+	}
 
 	// Connect to socket
-	conn, err := net.Dial(network, socketPath)
+	uconn, err := net.DialUnix(netType, laddr, &net.UnixAddr{Name: socketPath, Net: netType})
 	if err != nil {
+		closeFiles(files)
 		return fmt.Errorf("connection failed: %w", err)
 	}
-	defer conn.Close()
-
-	logger.Info("Connected to Unix socket")
+	defer uconn.Close()
 
-	// Setup signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Read from socket, write to stdout
-	go func() {
-		buffer := make([]byte, unixBufferSize)
-		for {
-			n, err := conn.Read(buffer)
-			if err != nil {
-				if err != io.EOF {
-					logger.Error("Socket read error: %v", err)
-				}
-				cancel()
-				return
-			}
-
-			if n > 0 {
-				if _, err := os.Stdout.Write(buffer[:n]); err != nil {
-					logger.Error("Stdout write error: %v", err)
-					cancel()
-					return
-				}
-			}
-		}
-	}()
-
-	// Read from stdin, write to socket
-	go func() {
-		buffer := make([]byte, unixBufferSize)
-		for {
-			n, err := os.Stdin.Read(buffer)
-			if err != nil {
-				if err != io.EOF {
-					logger.Error("Stdin read error: %v", err)
-				}
-				cancel()
-				return
-			}
-
-			if n > 0 {
-				if _, err := conn.Write(buffer[:n]); err != nil {
-					logger.Error("Socket write error: %v", err)
-					cancel()
-					return
-				}
-			}
+	if cred, err := network.PeerCred(uconn); err == nil && netType != "unixgram" {
+		logger.Info("Connected to Unix socket (peer %s)", cred)
+	} else {
+		logger.Info("Connected to Unix socket")
+	}
+	conn := passUnixFDs(uconn, files)
+
+	// Relay stdin and stdout until the socket is done or we are interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	engine := newRelay()
+	engine.BufferSize = unixBufferSize
+	if _, err := runRelayContext(ctx, engine, relay.Stdio(os.Stdin, os.Stdout), relay.Conn(conn)); err != nil {
+		if ctx.Err() != nil {
+			logger.Info("Interrupted, closing connection...")
+		} else {
+			logger.Error("Relay error: %v", err)
 		}
-	}()
-
-	// Wait for interrupt or context cancellation
-	select {
-	case <-sigChan:
-		logger.Info("Interrupted, closing connection...")
-	case <-ctx.Done():
 	}
 
 	return nil
@@ -262,6 +256,13 @@ func runUnixEcho(cmd *cobra.Command, args []string) error {
 	if err := validateSocketPath(socketPath); err != nil {
 		return err
 	}
+	netType, err := unixNetwork(unixSocketType)
+	if err != nil {
+		return err
+	}
+	if netType == "unixgram" && unixPeerPolicySet() {
+		return fmt.Errorf("datagram clients have no credentials to check")
+	}
 
 	if unixRemoveExisting {
 		if err := removeSocketIfExists(socketPath); err != nil {
@@ -272,19 +273,71 @@ func runUnixEcho(cmd *cobra.Command, args []string) error {
 	logger.Info("Starting Unix socket echo server: %s", socketPath)
 
 	// Create listener
-	listener, err := net.Listen("unix", socketPath)
+	listener, err := listenUnixSocket(netType, socketPath)
 	if err != nil {
-		return fmt.Errorf("failed to create listener: %w", err)
+		return err
 	}
-	defer listener.Close()
 
-	// Set permissions
-	if err := os.Chmod(socketPath, os.FileMode(unixPermissions)); err != nil {
-		logger.Warn("Failed to set socket permissions: %v", err)
+	logger.Info("Unix echo server listening on %s", socketPath)
+
+	serveUnix(listener, socketPath, "Unix echo server", handleUnixEcho)
+	return nil
+}
+
+func runUnixInfo(cmd *cobra.Command, args []string) error {
+	info, err := network.GetUnixSocketInfo(args[0])
+	if err != nil {
+		return err
 	}
+	fmt.Println(info)
+	return nil
+}
 
-	logger.Info("Unix echo server listening on %s", socketPath)
+// Helper functions
 
+// unixNetwork maps --type to the network of the socket
+func unixNetwork(socketType string) (string, error) {
+	switch socketType {
+	case "stream":
+		return "unix", nil
+	case "datagram":
+		return "unixgram", nil
+	case "seqpacket":
+		return "unixpacket", nil
+	}
+	return "", fmt.Errorf("unknown socket type %q (stream, datagram or seqpacket)", socketType)
+}
+
+// listenUnixSocket creates the listener of a server. Datagram sockets are
+// served as sessions, one per client address, like UDP.
+func listenUnixSocket(netType, socketPath string) (net.Listener, error) {
+	var listener net.Listener
+	if netType == "unixgram" {
+		conn, err := net.ListenUnixgram(netType, &net.UnixAddr{Name: socketPath, Net: netType})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create listener: %w", err)
+		}
+		listener = network.NewUDPListener(conn, nil)
+	} else {
+		var err error
+		listener, err = net.Listen(netType, socketPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create listener: %w", err)
+		}
+	}
+
+	// Set permissions
+	if !network.IsAbstractUnixPath(socketPath) {
+		if err := os.Chmod(socketPath, os.FileMode(unixPermissions)); err != nil {
+			logger.Warn("Failed to set socket permissions: %v", err)
+		}
+	}
+	return listener, nil
+}
+
+// serveUnix hands the admitted clients of listener to handle until
+// interrupted, then removes the socket file
+func serveUnix(listener net.Listener, socketPath, name string, handle func(net.Conn)) {
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -293,86 +346,136 @@ func runUnixEcho(cmd *cobra.Command, args []string) error {
 	go func() {
 		for {
 			conn, err := listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			if err != nil {
-				select {
-				case <-sigChan:
-					return
-				default:
-					logger.Error("Accept error: %v", err)
-					continue
-				}
+				logger.Error("Accept error: %v", err)
+				continue
 			}
 
-			logger.Info("Echo connection accepted")
-			go handleUnixEcho(conn)
+			if !admitUnixPeer(conn) {
+				conn.Close()
+				continue
+			}
+			go handle(passUnixFDs(conn, nil))
 		}
 	}()
 
 	// Wait for interrupt
 	<-sigChan
-	logger.Info("Shutting down Unix echo server...")
-	
-	// Cleanup
-	os.Remove(socketPath)
-	
-	return nil
+	logger.Info("Shutting down %s...", name)
+	listener.Close()
+
+	// Cleanup socket file
+	if !network.IsAbstractUnixPath(socketPath) {
+		os.Remove(socketPath)
+	}
 }
 
-// Helper functions
+// admitUnixPeer logs who connected and applies --allow-uid, --allow-gid and
+// --allow-pid
+func admitUnixPeer(conn net.Conn) bool {
+	if _, ok := conn.(*net.UnixConn); !ok {
+		logger.Info("Datagram session from %s", conn.RemoteAddr())
+		return true
+	}
 
-func handleUnixConnection(conn net.Conn) {
-	defer conn.Close()
+	cred, err := network.PeerCred(conn)
+	if err != nil {
+		if unixPeerPolicySet() {
+			logger.Warn("Connection denied, peer credentials unavailable: %v", err)
+			return false
+		}
+		logger.Info("Connection accepted")
+		return true
+	}
+	if !unixPeerAllowed(cred) {
+		logger.Warn("Connection from %s denied by credential policy", cred)
+		return false
+	}
+	logger.Info("Connection accepted from %s", cred)
+	return true
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+func unixPeerPolicySet() bool {
+	return len(unixAllowUIDs) > 0 || len(unixAllowGIDs) > 0 || len(unixAllowPIDs) > 0
+}
 
-	// Read from connection, write to stdout
-	go func() {
-		buffer := make([]byte, unixBufferSize)
-		for {
-			n, err := conn.Read(buffer)
-			if err != nil {
-				if err != io.EOF {
-					logger.Error("Connection read error: %v", err)
-				}
-				cancel()
-				return
-			}
+// unixPeerAllowed reports whether cred matches every credential list given
+func unixPeerAllowed(cred *network.UnixCred) bool {
+	matches := func(allowed []uint, v uint) bool {
+		return len(allowed) == 0 || slices.Contains(allowed, v)
+	}
+	return matches(unixAllowUIDs, uint(cred.UID)) &&
+		matches(unixAllowGIDs, uint(cred.GID)) &&
+		matches(unixAllowPIDs, uint(cred.PID))
+}
 
-			if n > 0 {
-				if _, err := os.Stdout.Write(buffer[:n]); err != nil {
-					logger.Error("Stdout write error: %v", err)
-					cancel()
-					return
-				}
-			}
-		}
-	}()
+// passUnixFDs wraps conn to send files with its first write and report the
+// descriptors it receives, as --send-fds and --recv-fds ask
+func passUnixFDs(conn net.Conn, files []*os.File) net.Conn {
+	uconn, ok := conn.(*net.UnixConn)
+	if !ok || (len(files) == 0 && !unixRecvFDs) {
+		return conn
+	}
+	fdConn := network.NewUnixFDConn(uconn)
+	fdConn.SendFiles(files...)
+	if unixRecvFDs {
+		fdConn.OnFiles = reportPassedFiles
+	}
+	return fdConn
+}
 
-	// Read from stdin, write to connection
-	go func() {
-		buffer := make([]byte, unixBufferSize)
-		for {
-			n, err := os.Stdin.Read(buffer)
-			if err != nil {
-				if err != io.EOF {
-					logger.Error("Stdin read error: %v", err)
-				}
-				cancel()
-				return
-			}
+// reportPassedFiles logs and closes the descriptors passed by the peer
+func reportPassedFiles(files []*os.File) {
+	for _, f := range files {
+		logger.Info("Received %s: %s", f.Name(), network.DescribeFile(f))
+		f.Close()
+	}
+}
 
-			if n > 0 {
-				if _, err := conn.Write(buffer[:n]); err != nil {
-					logger.Error("Connection write error: %v", err)
-					cancel()
-					return
-				}
+// openPassedFiles opens the --send-fds entries: descriptor numbers are
+// duplicated, anything else is opened as a file
+func openPassedFiles(specs []string) ([]*os.File, error) {
+	var files []*os.File
+	for _, spec := range specs {
+		if fd, err := strconv.Atoi(spec); err == nil {
+			dup, err := syscall.Dup(fd)
+			if err != nil {
+				closeFiles(files)
+				return nil, fmt.Errorf("cannot pass descriptor %d: %w", fd, err)
 			}
+			files = append(files, os.NewFile(uintptr(dup), spec))
+			continue
 		}
-	}()
+		f, err := os.OpenFile(spec, os.O_RDWR, 0)
+		if err != nil {
+			f, err = os.Open(spec)
+		}
+		if err != nil {
+			closeFiles(files)
+			return nil, fmt.Errorf("cannot pass %s: %w", spec, err)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func handleUnixConnection(conn net.Conn) {
+	defer conn.Close()
 
-	<-ctx.Done()
+	engine := newRelay()
+	engine.BufferSize = unixBufferSize
+	if _, err := runRelayContext(context.Background(), engine, relay.Stdio(os.Stdin, os.Stdout), relay.Conn(conn)); err != nil {
+		logger.Error("Relay error: %v", err)
+	}
 	logger.Info("Connection closed")
 }
 
@@ -402,6 +505,17 @@ func handleUnixEcho(conn net.Conn) {
 }
 
 func validateSocketPath(path string) error {
+	// Abstract sockets have a name instead of a path
+	if network.IsAbstractUnixPath(path) {
+		if !network.AbstractUnixSockets {
+			return fmt.Errorf("abstract sockets are only available on Linux: %s", path)
+		}
+		if len(path) > network.MaxAbstractUnixPath {
+			return fmt.Errorf("socket name too long (max %d characters): %s", network.MaxAbstractUnixPath, path)
+		}
+		return nil
+	}
+
 	// Check if path is absolute
 	if !filepath.IsAbs(path) {
 		return fmt.Errorf("socket path must be absolute: %s", path)
@@ -422,6 +536,10 @@ func validateSocketPath(path string) error {
 }
 
 func removeSocketIfExists(path string) error {
+	if network.IsAbstractUnixPath(path) {
+		return nil
+	}
+
 	// Check if file exists
 	if _, err := os.Stat(path); err == nil {
 		// Check if it's a socket
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upstreamWarm int
+	upstreamMax  int
+
+	upstreamLayer     atomic.Pointer[network.Upstreams]
+	upstreamLayerOnce sync.Once
+)
+
+// addUpstreamFlags adds the flags sizing the pool of backend connections
+func addUpstreamFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&upstreamWarm, "pool-warm", 0, "Idle connections to keep open to each backend")
+	cmd.Flags().IntVar(&upstreamMax, "pool-max", 0, "Maximum connections to each backend (0 = unlimited)")
+}
+
+// upstreams returns the layer the backend connections of proxy, convert
+// and scripts are taken from, starting it on first use: warm connections
+// from a pool sized by the pool flags, dialed through relayDialer so its
+// circuit breakers spare backends that keep failing
+func upstreams() *network.Upstreams {
+	if u := upstreamLayer.Load(); u != nil {
+		return u
+	}
+	upstreamLayerOnce.Do(func() {
+		config := network.DefaultPoolConfig()
+		config.MinSize = upstreamWarm
+		config.MaxSize = upstreamMax
+		config.HealthCheckInterval = 10 * time.Second
+		config.ConnectionTimeout = 10 * time.Second
+		upstreamLayer.Store(network.NewUpstreams(relayDialer, config))
+	})
+	return upstreamLayer.Load()
+}
@@ -3,15 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/relay"
 	"github.com/spf13/cobra"
 )
 
@@ -142,75 +143,7 @@ func runWSServer(cmd *cobra.Command, args []string) error {
 			return nil
 		})
 
-		// Start ping ticker
-		ticker := time.NewTicker(wsPingInterval)
-		defer ticker.Stop()
-
-		// Handle bidirectional communication
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		// Read from WebSocket, write to stdout
-		go func() {
-			for {
-				messageType, message, err := conn.ReadMessage()
-				if err != nil {
-					if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-						logger.Error("WebSocket read error: %v", err)
-					}
-					cancel()
-					return
-				}
-
-				if messageType == websocket.TextMessage || messageType == websocket.BinaryMessage {
-					if _, err := os.Stdout.Write(message); err != nil {
-						logger.Error("Stdout write error: %v", err)
-						cancel()
-						return
-					}
-				}
-			}
-		}()
-
-		// Read from stdin, write to WebSocket
-		go func() {
-			buffer := make([]byte, 4096)
-			for {
-				n, err := os.Stdin.Read(buffer)
-				if err != nil {
-					if err != io.EOF {
-						logger.Error("Stdin read error: %v", err)
-					}
-					cancel()
-					return
-				}
-
-				if n > 0 {
-					if err := conn.WriteMessage(websocket.BinaryMessage, buffer[:n]); err != nil {
-						logger.Error("WebSocket write error: %v", err)
-						cancel()
-						return
-					}
-				}
-			}
-		}()
-
-		// Ping loop
-		go func() {
-			for {
-				select {
-				case <-ticker.C:
-					if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-						cancel()
-						return
-					}
-				case <-ctx.Done():
-					return
-				}
-			}
-		}()
-
-		<-ctx.Done()
+		relayWebSocket(r.Context(), conn)
 		logger.Info("WebSocket connection closed")
 	})
 
@@ -250,6 +183,7 @@ func runWSClient(cmd *cobra.Command, args []string) error {
 		WriteBufferSize:   wsWriteBufferSize,
 		EnableCompression: wsEnableCompression,
 		HandshakeTimeout:  10 * time.Second,
+		NetDialContext:    relayDialer.DialContext,
 	}
 
 	headers := http.Header{}
@@ -265,68 +199,62 @@ func runWSClient(cmd *cobra.Command, args []string) error {
 
 	logger.Info("WebSocket connection established")
 
-	// Setup signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// Relay until either side is done or we are interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	relayWebSocket(ctx, conn)
+	if ctx.Err() != nil {
+		logger.Info("Interrupted, connection closed")
+	}
+	return nil
+}
+
+// wsCloseTimeout bounds the wait for the peer to answer a close frame
+const wsCloseTimeout = 2 * time.Second
+
+// relayWebSocket relays stdin and stdout over conn, pinging the peer every
+// wsPingInterval, until both sides are done or ctx is. When ctx ends first
+// the closing handshake is run: a close frame is sent and the relay goes on
+// until the peer answers with its own, or wsCloseTimeout passes.
+func relayWebSocket(parent context.Context, conn *websocket.Conn) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start ping ticker
-	ticker := time.NewTicker(wsPingInterval)
-	defer ticker.Stop()
+	peerClosed := make(chan struct{})
+	var closeOnce sync.Once
+	replyClose := conn.CloseHandler()
+	conn.SetCloseHandler(func(code int, text string) error {
+		closeOnce.Do(func() { close(peerClosed) })
+		return replyClose(code, text)
+	})
 
-	// Read from WebSocket, write to stdout
 	go func() {
-		for {
-			messageType, message, err := conn.ReadMessage()
-			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					logger.Error("WebSocket read error: %v", err)
-				}
-				cancel()
-				return
-			}
-
-			if messageType == websocket.TextMessage || messageType == websocket.BinaryMessage {
-				if _, err := os.Stdout.Write(message); err != nil {
-					logger.Error("Stdout write error: %v", err)
-					cancel()
-					return
-				}
-			}
+		select {
+		case <-parent.Done():
+		case <-ctx.Done():
+			return
 		}
-	}()
-
-	// Read from stdin, write to WebSocket
-	go func() {
-		buffer := make([]byte, 4096)
-		for {
-			n, err := os.Stdin.Read(buffer)
-			if err != nil {
-				if err != io.EOF {
-					logger.Error("Stdin read error: %v", err)
-				}
-				cancel()
-				return
-			}
-
-			if n > 0 {
-				if err := conn.WriteMessage(websocket.BinaryMessage, buffer[:n]); err != nil {
-					logger.Error("WebSocket write error: %v", err)
-					cancel()
-					return
-				}
-			}
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsCloseTimeout))
+		conn.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+		select {
+		case <-peerClosed:
+		case <-ctx.Done():
+		case <-time.After(wsCloseTimeout):
 		}
+		cancel()
 	}()
 
-	// Ping loop
+	// Pings go out as control frames, which may be sent concurrently with
+	// the relayed messages
 	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPongTimeout)); err != nil {
 					cancel()
 					return
 				}
@@ -336,18 +264,9 @@ func runWSClient(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	// Wait for interrupt or context cancellation
-	select {
-	case <-sigChan:
-		logger.Info("Interrupted, closing connection...")
-	case <-ctx.Done():
+	if _, err := runRelayContext(ctx, newRelay(), relay.Stdio(os.Stdin, os.Stdout), relay.WebSocket(conn)); err != nil && ctx.Err() == nil {
+		logger.Error("WebSocket relay error: %v", err)
 	}
-
-	// Clean close
-	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	time.Sleep(100 * time.Millisecond)
-
-	return nil
 }
 
 func runWSEcho(cmd *cobra.Command, args []string) error {
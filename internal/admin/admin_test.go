@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/network"
+)
+
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	s := NewServer("test")
+	if err := s.Listen(path); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go s.Serve()
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func dial(t *testing.T, path string) *Client {
+	t.Helper()
+	c, err := Dial(path, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCall(t *testing.T) {
+	s, path := startServer(t)
+	s.Handle("math.add", "Add two numbers", func(params json.RawMessage) (interface{}, error) {
+		var args struct{ A, B int }
+		if err := DecodeParams(params, &args); err != nil {
+			return nil, err
+		}
+		return args.A + args.B, nil
+	})
+	s.Handle("fail", "Always fails", func(json.RawMessage) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	s.Handle("panic", "Always panics", func(json.RawMessage) (interface{}, error) {
+		panic("oops")
+	})
+	c := dial(t, path)
+
+	var sum int
+	if err := c.Call("math.add", map[string]int{"a": 2, "b": 3}, &sum); err != nil || sum != 5 {
+		t.Errorf("math.add = %d, %v; want 5", sum, err)
+	}
+
+	var status Status
+	if err := c.Call("status", nil, &status); err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if status.Command != "test" || status.PID != os.Getpid() {
+		t.Errorf("status = %+v", status)
+	}
+
+	var methods []MethodInfo
+	if err := c.Call("rpc.methods", nil, &methods); err != nil {
+		t.Fatalf("rpc.methods failed: %v", err)
+	}
+	if len(methods) != 5 || methods[0].Name != "fail" {
+		t.Errorf("rpc.methods = %+v", methods)
+	}
+
+	tests := []struct {
+		method string
+		params interface{}
+		code   int
+	}{
+		{"missing", nil, CodeMethodNotFound},
+		{"math.add", "not an object", CodeInvalidParams},
+		{"fail", nil, CodeServerError},
+		{"panic", nil, CodeInternalError},
+	}
+	for _, tt := range tests {
+		err := c.Call(tt.method, tt.params, nil)
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != tt.code {
+			t.Errorf("%s: error = %v, want code %d", tt.method, err, tt.code)
+		}
+	}
+
+	// The connection survives failed calls
+	if err := c.Call("status", nil, nil); err != nil {
+		t.Errorf("status after errors failed: %v", err)
+	}
+}
+
+func TestRawProtocol(t *testing.T) {
+	_, path := startServer(t)
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// A notification gets no response, so the parse error is the first reply
+	conn.Write([]byte(`{"jsonrpc":"2.0","method":"status"}` + "\n"))
+	conn.Write([]byte("not json\n"))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(line, `"code":-32700`) || !strings.Contains(line, `"id":null`) {
+		t.Errorf("parse error response = %s", line)
+	}
+
+	conn.Write([]byte(`{"jsonrpc":"1.0","id":"x","method":"status"}` + "\n"))
+	line, _ = reader.ReadString('\n')
+	if !strings.Contains(line, `"code":-32600`) || !strings.Contains(line, `"id":"x"`) {
+		t.Errorf("invalid request response = %s", line)
+	}
+}
+
+func TestSocketPermissionsAndCleanup(t *testing.T) {
+	s, path := startServer(t)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket permissions = %o, want 600", perm)
+	}
+
+	s.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after Close: %v", err)
+	}
+}
+
+func TestAbstractSocketChecksPeer(t *testing.T) {
+	if !network.AbstractUnixSockets {
+		t.Skip("no abstract Unix sockets on this platform")
+	}
+	s := NewServer("test")
+	path := fmt.Sprintf("@gocat-admin-test-%d", os.Getpid())
+	if err := s.Listen(path); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go s.Serve()
+	defer s.Close()
+
+	// The current user passes the credential check
+	var status Status
+	if err := dial(t, path).Call("status", nil, &status); err != nil || status.Command != "test" {
+		t.Fatalf("status = %+v, %v", status, err)
+	}
+
+	// A client whose credentials cannot be read is refused
+	client, server := net.Pipe()
+	defer client.Close()
+	if s.authorized(server) {
+		t.Error("client without peer credentials was authorized on an abstract socket")
+	}
+}
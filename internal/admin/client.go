@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client calls methods on an admin socket
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	mu     sync.Mutex
+	nextID int64
+}
+
+// Dial connects to the admin socket at path. timeout bounds the connect and
+// every later call; zero means no limit.
+func Dial(path string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to admin socket: %w", err)
+	}
+	return &Client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}, nil
+}
+
+// Call invokes method with params and decodes its result into result, which
+// may be nil. Errors returned by the server are *Error values.
+func (c *Client) Call(method string, params interface{}, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	id := json.RawMessage(strconv.FormatInt(c.nextID, 10))
+	req := Request{Version: Version, ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode params: %w", err)
+		}
+		req.Params = data
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	if _, err := c.conn.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	reply, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(reply, &resp); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if string(resp.ID) != string(id) {
+		return errors.New("response does not match the request")
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode result: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close disconnects from the server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,334 @@
+// Package admin implements the local control API of long-running gocat
+// servers: JSON-RPC 2.0 requests and responses, one JSON object per line,
+// over a Unix domain socket.
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/network"
+)
+
+// Version is the JSON-RPC protocol version spoken by the server
+const Version = "2.0"
+
+// maxRequestSize bounds a single request line
+const maxRequestSize = 1 << 20
+
+// Standard JSON-RPC error codes, plus CodeServerError for failures reported
+// by method handlers
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000
+)
+
+// Error is a JSON-RPC error object. Handlers may return one to choose the
+// code; any other error is reported as CodeServerError.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// InvalidParams returns a CodeInvalidParams error
+func InvalidParams(format string, args ...interface{}) *Error {
+	return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// Request is a JSON-RPC request. Requests without an ID are notifications
+// and get no response.
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// HandlerFunc serves one method. params is nil when the request had none.
+type HandlerFunc func(params json.RawMessage) (interface{}, error)
+
+// MethodInfo describes a registered method
+type MethodInfo struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+}
+
+// Status is the result of the built-in "status" method
+type Status struct {
+	Command string    `json:"command"`
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	Uptime  string    `json:"uptime"`
+}
+
+type method struct {
+	help    string
+	handler HandlerFunc
+}
+
+// Server dispatches requests from admin socket clients to registered methods
+type Server struct {
+	command string
+	started time.Time
+
+	mu       sync.Mutex
+	methods  map[string]method
+	listener *network.UnixListener
+	abstract bool
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewServer creates a server for the named command with the built-in
+// "rpc.methods" and "status" methods registered
+func NewServer(command string) *Server {
+	s := &Server{
+		command: command,
+		started: time.Now(),
+		methods: make(map[string]method),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	s.Handle("rpc.methods", "List the available methods", func(json.RawMessage) (interface{}, error) {
+		return s.Methods(), nil
+	})
+	s.Handle("status", "Show the command, PID and uptime", func(json.RawMessage) (interface{}, error) {
+		return Status{
+			Command: s.command,
+			PID:     os.Getpid(),
+			Started: s.started,
+			Uptime:  time.Since(s.started).Round(time.Second).String(),
+		}, nil
+	})
+	return s
+}
+
+// Handle registers handler for method, replacing any previous handler
+func (s *Server) Handle(name, help string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[name] = method{help: help, handler: handler}
+}
+
+// Methods lists the registered methods sorted by name
+func (s *Server) Methods() []MethodInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]MethodInfo, 0, len(s.methods))
+	for name, m := range s.methods {
+		list = append(list, MethodInfo{Name: name, Help: m.help})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Listen binds the server to a Unix socket that only the current user may
+// connect to. A stale socket left by a killed process is replaced. Abstract
+// sockets ("@name") have no file permissions, so their clients are checked
+// by their peer credentials instead, where the platform has them.
+func (s *Server) Listen(path string) error {
+	abstract := network.IsAbstractUnixPath(path)
+	if abstract && !network.AbstractUnixSockets {
+		return fmt.Errorf("abstract admin socket %s is not supported on this platform", path)
+	}
+	ln := network.NewUnixListener(&network.UnixSocketConfig{
+		Permissions: 0600,
+		Cleanup:     true,
+		Timeout:     5 * time.Second,
+	})
+	if err := ln.Listen(path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = ln
+	s.abstract = abstract
+	s.mu.Unlock()
+	return nil
+}
+
+// Serve accepts clients until the server is closed
+func (s *Server) Serve() error {
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln == nil {
+		return errors.New("admin server is not listening")
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if !s.authorized(conn) {
+			conn.Close()
+			continue
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the server, disconnects its clients and removes the socket
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	ln := s.listener
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	if ln != nil {
+		return ln.Close()
+	}
+	return nil
+}
+
+// authorized reports whether the client runs as the current user or root.
+// Without peer credentials the socket file permissions are relied upon,
+// which abstract sockets do not have.
+func (s *Server) authorized(conn net.Conn) bool {
+	cred, err := network.PeerCred(conn)
+	if err != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return !s.abstract
+	}
+	return cred.UID == 0 || int(cred.UID) == os.Getuid()
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxRequestSize)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if resp := s.dispatch(line); resp != nil {
+			if err := encoder.Encode(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch runs one request line and returns its response, or nil for a
+// notification
+func (s *Server) dispatch(line []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(nil, &Error{Code: CodeParseError, Message: "parse error: " + err.Error()})
+	}
+	if req.Version != Version || req.Method == "" {
+		return errorResponse(req.ID, &Error{Code: CodeInvalidRequest, Message: "invalid request"})
+	}
+
+	s.mu.Lock()
+	m, ok := s.methods[req.Method]
+	s.mu.Unlock()
+
+	var resp *Response
+	if !ok {
+		resp = errorResponse(req.ID, &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method})
+	} else {
+		resp = call(req, m.handler)
+	}
+	if len(req.ID) == 0 {
+		return nil
+	}
+	return resp
+}
+
+// call runs handler, turning panics into internal errors so one bad request
+// cannot take the server down
+func call(req Request, handler HandlerFunc) (resp *Response) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp = errorResponse(req.ID, &Error{Code: CodeInternalError, Message: fmt.Sprintf("internal error: %v", r)})
+		}
+	}()
+
+	params := req.Params
+	if string(params) == "null" {
+		params = nil
+	}
+	result, err := handler(params)
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeServerError, Message: err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &Error{Code: CodeInternalError, Message: "failed to encode result: " + err.Error()})
+	}
+	return &Response{Version: Version, ID: req.ID, Result: data}
+}
+
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{Version: Version, ID: id, Error: err}
+}
+
+// DecodeParams unmarshals params into v, reporting failures as
+// CodeInvalidParams. Missing params leave v unchanged.
+func DecodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return InvalidParams("invalid params: %v", err)
+	}
+	return nil
+}
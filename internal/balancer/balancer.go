@@ -0,0 +1,302 @@
+// Package balancer picks backends for layer 4 load balancing: round-robin,
+// least-connections or consistent hashing on the client address, over the
+// backends that pass their health checks and are not being drained.
+package balancer
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Algorithm is a way of picking backends
+type Algorithm string
+
+// Algorithms of a Balancer
+const (
+	RoundRobin       Algorithm = "round-robin"
+	LeastConnections Algorithm = "least-connections"
+	SourceHash       Algorithm = "source-hash"
+)
+
+// ParseAlgorithm parses the name of an algorithm; ip-hash and
+// consistent-hash are taken for source-hash
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch strings.ToLower(s) {
+	case "round-robin", "rr":
+		return RoundRobin, nil
+	case "least-connections", "leastconn":
+		return LeastConnections, nil
+	case "source-hash", "ip-hash", "consistent-hash":
+		return SourceHash, nil
+	}
+	return "", fmt.Errorf("unknown algorithm %q (want round-robin, least-connections or source-hash)", s)
+}
+
+// Backend is a backend of a Balancer and its sessions
+type Backend struct {
+	Address string
+
+	active   atomic.Int64
+	total    atomic.Int64
+	failures atomic.Int64
+	healthy  atomic.Bool
+	draining atomic.Bool
+
+	mu        sync.Mutex
+	rise      int // successful checks in a row while down
+	fall      int // failed checks in a row while up
+	lastCheck time.Time
+	lastError string
+}
+
+// BackendStats describes a backend
+type BackendStats struct {
+	Address   string    `json:"address"`
+	Healthy   bool      `json:"healthy"`
+	Draining  bool      `json:"draining"`
+	Available bool      `json:"available"`
+	Active    int64     `json:"active"`
+	Total     int64     `json:"total"`
+	Failures  int64     `json:"failures"`
+	LastCheck time.Time `json:"last_check,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+func newBackend(address string) *Backend {
+	b := &Backend{Address: address}
+	b.healthy.Store(true)
+	return b
+}
+
+// Acquire counts a session on the backend; the returned function ends it
+func (b *Backend) Acquire() func() {
+	b.active.Add(1)
+	b.total.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.active.Add(-1) })
+	}
+}
+
+// Failed counts a connection the backend did not take
+func (b *Backend) Failed() {
+	b.failures.Add(1)
+}
+
+// Active returns the sessions open on the backend
+func (b *Backend) Active() int64 {
+	return b.active.Load()
+}
+
+// Healthy reports whether the backend passes its health checks
+func (b *Backend) Healthy() bool {
+	return b.healthy.Load()
+}
+
+// Draining reports whether the backend is kept from new sessions
+func (b *Backend) Draining() bool {
+	return b.draining.Load()
+}
+
+// Config configures a Balancer
+type Config struct {
+	Algorithm Algorithm
+
+	// Available reports whether a backend takes connections apart from its
+	// health, such as while its circuit breaker is closed. Nil takes all.
+	Available func(address string) bool
+}
+
+// Balancer picks backends for new sessions. Backends that fail their
+// health checks, are being drained or are not available are passed over;
+// their open sessions are left alone.
+type Balancer struct {
+	config  Config
+	counter atomic.Uint64
+
+	mu       sync.RWMutex
+	backends []*Backend
+	ring     *hashRing
+}
+
+// New creates a balancer over the backends at addresses
+func New(addresses []string, config *Config) *Balancer {
+	b := &Balancer{}
+	if config != nil {
+		b.config = *config
+	}
+	if b.config.Algorithm == "" {
+		b.config.Algorithm = RoundRobin
+	}
+	for _, address := range addresses {
+		b.backends = append(b.backends, newBackend(address))
+	}
+	b.ring = newHashRing(b.backends)
+	return b
+}
+
+// Algorithm returns the algorithm backends are picked with
+func (b *Balancer) Algorithm() Algorithm {
+	return b.config.Algorithm
+}
+
+// eligible reports whether a backend may take a new session
+func (b *Balancer) eligible(be *Backend) bool {
+	if !be.Healthy() || be.Draining() {
+		return false
+	}
+	return b.config.Available == nil || b.config.Available(be.Address)
+}
+
+// Next picks the backend for a session from client, passing over the
+// backends in exclude, which already failed to connect it. It returns nil
+// when no backend can take the session.
+func (b *Balancer) Next(client net.Addr, exclude []*Backend) *Backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	usable := func(be *Backend) bool {
+		for _, ex := range exclude {
+			if ex == be {
+				return false
+			}
+		}
+		return b.eligible(be)
+	}
+
+	switch b.config.Algorithm {
+	case SourceHash:
+		return b.ring.lookup(sourceKey(client), usable)
+
+	case LeastConnections:
+		// Ties go round the backends so they share the load when idle
+		var best *Backend
+		start := int(b.counter.Add(1))
+		for i := range b.backends {
+			be := b.backends[(start+i)%len(b.backends)]
+			if usable(be) && (best == nil || be.Active() < best.Active()) {
+				best = be
+			}
+		}
+		return best
+
+	default:
+		// Turns go round the usable backends only, so those left share the
+		// load of the others evenly
+		var candidates []*Backend
+		for _, be := range b.backends {
+			if usable(be) {
+				candidates = append(candidates, be)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		return candidates[b.counter.Add(1)%uint64(len(candidates))]
+	}
+}
+
+// sourceKey returns what sessions of a client hash on: its IP without the
+// port, so every connection of a client lands on the same backend
+func sourceKey(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	case nil:
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// Backend returns the backend at address
+func (b *Balancer) Backend(address string) (*Backend, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, be := range b.backends {
+		if be.Address == address {
+			return be, true
+		}
+	}
+	return nil, false
+}
+
+// Backends returns the backends
+func (b *Balancer) Backends() []*Backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]*Backend(nil), b.backends...)
+}
+
+// Add adds a backend unless one with its address exists
+func (b *Balancer) Add(address string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, be := range b.backends {
+		if be.Address == address {
+			return false
+		}
+	}
+	b.backends = append(b.backends, newBackend(address))
+	b.ring = newHashRing(b.backends)
+	return true
+}
+
+// Remove drops the backend at address and returns it. Its open sessions
+// carry on; new ones go elsewhere.
+func (b *Balancer) Remove(address string) (*Backend, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, be := range b.backends {
+		if be.Address == address {
+			b.backends = append(b.backends[:i:i], b.backends[i+1:]...)
+			b.ring = newHashRing(b.backends)
+			return be, true
+		}
+	}
+	return nil, false
+}
+
+// SetDraining starts or stops draining the backend at address: while it
+// drains it takes no new sessions and its open ones run to their end
+func (b *Balancer) SetDraining(address string, draining bool) bool {
+	be, ok := b.Backend(address)
+	if ok {
+		be.draining.Store(draining)
+	}
+	return ok
+}
+
+// Stats describes the backends
+func (b *Balancer) Stats() []BackendStats {
+	backends := b.Backends()
+	stats := make([]BackendStats, 0, len(backends))
+	for _, be := range backends {
+		be.mu.Lock()
+		lastCheck, lastError := be.lastCheck, be.lastError
+		be.mu.Unlock()
+		stats = append(stats, BackendStats{
+			Address:   be.Address,
+			Healthy:   be.Healthy(),
+			Draining:  be.Draining(),
+			Available: b.config.Available == nil || b.config.Available(be.Address),
+			Active:    be.Active(),
+			Total:     be.total.Load(),
+			Failures:  be.failures.Load(),
+			LastCheck: lastCheck,
+			LastError: lastError,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Address < stats[j].Address })
+	return stats
+}
@@ -0,0 +1,138 @@
+package balancer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func client(ip string) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}
+}
+
+func TestRoundRobinSkipsUnusable(t *testing.T) {
+	down := map[string]bool{"c:1": true}
+	b := New([]string{"a:1", "b:1", "c:1", "d:1"}, &Config{
+		Available: func(address string) bool { return !down[address] },
+	})
+	b.SetDraining("d:1", true)
+
+	seen := map[string]int{}
+	for i := 0; i < 10; i++ {
+		seen[b.Next(nil, nil).Address]++
+	}
+	if len(seen) != 2 || seen["a:1"] != 5 || seen["b:1"] != 5 {
+		t.Errorf("picked %v, want a and b alternately", seen)
+	}
+
+	a, _ := b.Backend("a:1")
+	if be := b.Next(nil, []*Backend{a}); be == nil || be.Address != "b:1" {
+		t.Errorf("excluding a picked %v", be)
+	}
+	b.SetDraining("d:1", false)
+	delete(down, "c:1")
+	if be := b.Next(nil, []*Backend{a}); be == nil || be.Address == "a:1" {
+		t.Errorf("excluding a picked %v", be)
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	b := New([]string{"a:1", "b:1", "c:1"}, &Config{Algorithm: LeastConnections})
+	a, _ := b.Backend("a:1")
+	c, _ := b.Backend("c:1")
+	a.Acquire()
+	a.Acquire()
+	release := c.Acquire()
+
+	if be := b.Next(nil, nil); be.Address != "b:1" {
+		t.Errorf("picked %s, want b:1", be.Address)
+	}
+	b.Next(nil, nil).Acquire()
+	release()
+	if be := b.Next(nil, nil); be.Address != "c:1" {
+		t.Errorf("picked %s, want c:1", be.Address)
+	}
+}
+
+func TestSourceHashIsSticky(t *testing.T) {
+	b := New([]string{"a:1", "b:1", "c:1"}, &Config{Algorithm: SourceHash})
+
+	before := map[string]string{}
+	for i := 0; i < 200; i++ {
+		ip := fmt.Sprintf("10.0.%d.%d", i/250, i%250)
+		be := b.Next(client(ip), nil)
+		if again := b.Next(&net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}, nil); again != be {
+			t.Fatalf("%s moved from %s to %s between connections", ip, be.Address, again.Address)
+		}
+		before[ip] = be.Address
+	}
+
+	// Only the clients of the backend taken out move
+	b.Backends()[1].healthy.Store(false)
+	moved := 0
+	for ip, address := range before {
+		now := b.Next(client(ip), nil).Address
+		if address == "b:1" {
+			moved++
+			if now == "b:1" {
+				t.Fatalf("%s still on the unhealthy backend", ip)
+			}
+		} else if now != address {
+			t.Fatalf("%s moved from %s to %s", ip, address, now)
+		}
+	}
+	if moved == 0 || moved == len(before) {
+		t.Errorf("%d of %d clients were on b", moved, len(before))
+	}
+}
+
+func TestHealthCheckSendExpect(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	reply := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			if strings.TrimSpace(line) == "PING" {
+				conn.Write([]byte(<-reply))
+			}
+			conn.Close()
+		}
+	}()
+
+	var changes []bool
+	hc := &HealthCheck{
+		Timeout:  time.Second,
+		Send:     []byte("PING\r\n"),
+		Expect:   []byte("PONG"),
+		Rise:     2,
+		Fall:     1,
+		OnChange: func(be *Backend, healthy bool, err error) { changes = append(changes, healthy) },
+	}
+	b := New([]string{ln.Addr().String()}, nil)
+	be := b.Backends()[0]
+
+	for _, r := range []string{"+PONG\r\n", "-ERR\r\n", "+PONG\r\n", "+PONG\r\n"} {
+		reply <- r
+		b.recordCheck(be, hc.Check(context.Background(), be.Address), hc)
+	}
+	if !be.Healthy() || len(changes) != 2 || changes[0] || !changes[1] {
+		t.Errorf("healthy = %v after changes %v, want out after -ERR and back after two PONGs", be.Healthy(), changes)
+	}
+
+	ln.Close()
+	if err := hc.Check(context.Background(), be.Address); err == nil {
+		t.Error("check passed with the backend gone")
+	}
+}
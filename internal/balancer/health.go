@@ -0,0 +1,182 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxExpectRead bounds how much of a response is searched for Expect
+const maxExpectRead = 64 * 1024
+
+// HealthCheck configures the health checks of a Balancer. A check connects
+// to the backend; with Send it writes a probe, with Expect it waits for a
+// response containing it. UDP backends can only be checked with a probe:
+// without Expect they pass unless the probe is refused.
+type HealthCheck struct {
+	Network  string // "tcp" or "udp"
+	Interval time.Duration
+	Timeout  time.Duration
+	Send     []byte
+	Expect   []byte
+	Rise     int // passed checks in a row that bring a backend back
+	Fall     int // failed checks in a row that take a backend out
+
+	// Dial connects to the backend; nil uses a net.Dialer
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// OnChange is called when a backend is taken out or brought back, with
+	// the error of the check that took it out
+	OnChange func(be *Backend, healthy bool, err error)
+}
+
+// Check checks the backend at address once
+func (hc *HealthCheck) Check(ctx context.Context, address string) error {
+	network := hc.Network
+	if network == "" {
+		network = "tcp"
+	}
+	if network == "udp" && len(hc.Send) == 0 {
+		return errors.New("UDP backends need a probe to send")
+	}
+	timeout := hc.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dial := hc.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	if len(hc.Send) > 0 {
+		if _, err := conn.Write(hc.Send); err != nil {
+			return err
+		}
+	}
+	if len(hc.Expect) > 0 {
+		return expect(conn, hc.Expect)
+	}
+	if network == "udp" {
+		// A refused probe shows up on the next read; silence is fine
+		var buf [1]byte
+		if _, err := conn.Read(buf[:]); err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// expect reads from conn until what was read contains want
+func expect(conn net.Conn, want []byte) error {
+	var got []byte
+	buf := make([]byte, 4096)
+	for len(got) < maxExpectRead {
+		n, err := conn.Read(buf)
+		got = append(got, buf[:n]...)
+		if bytes.Contains(got, want) {
+			return nil
+		}
+		if err != nil {
+			if len(got) == 0 {
+				return fmt.Errorf("no response: %v", err)
+			}
+			return fmt.Errorf("unexpected response %q", truncate(got, 64))
+		}
+	}
+	return fmt.Errorf("unexpected response %q", truncate(got, 64))
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
+
+// RunHealthChecks checks every backend at once and then every interval
+// until ctx is done, taking backends out after Fall failed checks in a row
+// and bringing them back after Rise passed ones
+func (b *Balancer) RunHealthChecks(ctx context.Context, hc HealthCheck) {
+	if hc.Interval <= 0 {
+		return
+	}
+	if hc.Rise <= 0 {
+		hc.Rise = 1
+	}
+	if hc.Fall <= 0 {
+		hc.Fall = 1
+	}
+
+	ticker := time.NewTicker(hc.Interval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, be := range b.Backends() {
+			wg.Add(1)
+			go func(be *Backend) {
+				defer wg.Done()
+				b.recordCheck(be, hc.Check(ctx, be.Address), &hc)
+			}(be)
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordCheck updates the health of a backend with the result of a check
+func (b *Balancer) recordCheck(be *Backend, err error, hc *HealthCheck) {
+	be.mu.Lock()
+	be.lastCheck = time.Now()
+	be.lastError = ""
+	changed := false
+	if err != nil {
+		be.lastError = err.Error()
+		be.rise = 0
+		if be.healthy.Load() {
+			be.fall++
+			if be.fall >= hc.Fall {
+				be.healthy.Store(false)
+				be.fall = 0
+				changed = true
+			}
+		}
+	} else {
+		be.fall = 0
+		if !be.healthy.Load() {
+			be.rise++
+			if be.rise >= hc.Rise {
+				be.healthy.Store(true)
+				be.rise = 0
+				changed = true
+			}
+		}
+	}
+	be.mu.Unlock()
+
+	if changed && hc.OnChange != nil {
+		hc.OnChange(be, err == nil, err)
+	}
+}
@@ -0,0 +1,65 @@
+package balancer
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// ringReplicas is the number of points each backend gets on the ring, so
+// the clients of a backend that goes away spread over all the others
+const ringReplicas = 160
+
+// hashRing maps keys to backends by consistent hashing: a key belongs to
+// the first backend point at or after its hash. Adding or removing a
+// backend only moves the keys next to its points.
+type hashRing struct {
+	points   []uint32
+	backends map[uint32]*Backend
+}
+
+func newHashRing(backends []*Backend) *hashRing {
+	r := &hashRing{backends: make(map[uint32]*Backend, len(backends)*ringReplicas)}
+	for _, be := range backends {
+		for i := 0; i < ringReplicas; i++ {
+			point := hashKey(be.Address + "#" + strconv.Itoa(i))
+			// The first backend keeps a point two of them hash to
+			if _, taken := r.backends[point]; taken {
+				continue
+			}
+			r.backends[point] = be
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// lookup returns the backend of key, walking on round the ring past the
+// backends usable rejects so their keys fall to their neighbours
+func (r *hashRing) lookup(key string, usable func(*Backend) bool) *Backend {
+	if len(r.points) == 0 {
+		return nil
+	}
+	hash := hashKey(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+
+	rejected := make(map[*Backend]bool)
+	for i := 0; i < len(r.points); i++ {
+		be := r.backends[r.points[(start+i)%len(r.points)]]
+		if rejected[be] {
+			continue
+		}
+		if usable(be) {
+			return be
+		}
+		rejected[be] = true
+	}
+	return nil
+}
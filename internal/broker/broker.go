@@ -0,0 +1,355 @@
+// Package broker implements the broker hub: every byte a client sends is
+// relayed to all other connected clients.
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/buffer"
+	"github.com/ibrahmsql/gocat/internal/network"
+)
+
+const (
+	// DefaultMaxConns is the default client limit
+	DefaultMaxConns = 10
+
+	// maxEvents bounds the recent events kept for monitoring
+	maxEvents = 200
+
+	readBufferSize = 4096
+)
+
+// Event kinds
+const (
+	EventJoin   = "join"
+	EventLeave  = "leave"
+	EventData   = "data"
+	EventReject = "reject"
+	EventKick   = "kick"
+	EventMute   = "mute"
+	EventUnmute = "unmute"
+	// EventAcceptError reports a failed Accept; the broker keeps serving
+	EventAcceptError = "accept_error"
+)
+
+// Event is something that happened on the broker
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Client string    `json:"client,omitempty"`
+	Addr   string    `json:"addr,omitempty"`
+	Bytes  int       `json:"bytes,omitempty"`
+	// Receivers is the number of clients data was relayed to
+	Receivers int    `json:"receivers,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ClientInfo describes a connected client
+type ClientInfo struct {
+	ID        string    `json:"id"`
+	Addr      string    `json:"addr"`
+	Connected time.Time `json:"connected"`
+	BytesIn   int64     `json:"bytes_in"`
+	BytesOut  int64     `json:"bytes_out"`
+	Messages  int64     `json:"messages"`
+	Muted     bool      `json:"muted"`
+}
+
+// Stats are broker totals
+type Stats struct {
+	Started           time.Time `json:"started"`
+	TotalConnections  int64     `json:"total_connections"`
+	ActiveConnections int       `json:"active_connections"`
+	Rejected          int64     `json:"rejected"`
+	BytesIn           int64     `json:"bytes_in"`
+	BytesOut          int64     `json:"bytes_out"`
+}
+
+// Options configures a broker
+type Options struct {
+	MaxConns int
+	// OnEvent is called for every event, e.g. to log it
+	OnEvent func(Event)
+	// WrapListener, when set, wraps the bound listener, e.g. to apply
+	// access control before clients reach the server
+	WrapListener func(net.Listener) net.Listener
+}
+
+type client struct {
+	ClientInfo
+	conn net.Conn
+}
+
+// Server is a broker hub
+type Server struct {
+	opts Options
+
+	mu       sync.Mutex
+	listener net.Listener
+	clients  map[string]*client
+	nextID   int64
+	stats    Stats
+	events   []Event
+	closed   bool
+}
+
+// ErrUnknownClient is returned for actions on clients that are not connected
+var ErrUnknownClient = errors.New("unknown client")
+
+// New creates a broker
+func New(opts Options) *Server {
+	if opts.MaxConns <= 0 {
+		opts.MaxConns = DefaultMaxConns
+	}
+	return &Server{
+		opts:    opts,
+		clients: make(map[string]*client),
+	}
+}
+
+// Listen binds the broker to a TCP address
+func (s *Server) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start broker listener: %w", err)
+	}
+	if s.opts.WrapListener != nil {
+		ln = s.opts.WrapListener(ln)
+	}
+	s.mu.Lock()
+	s.listener = ln
+	s.stats.Started = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Addr returns the listening address, or nil before Listen
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Serve accepts clients until the broker is closed. Other accept errors are
+// reported as events and retried after a backoff.
+func (s *Server) Serve() error {
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln == nil {
+		return errors.New("broker is not listening")
+	}
+
+	var backoff network.AcceptBackoff
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.emit(Event{Kind: EventAcceptError, Error: err.Error()})
+			backoff.Wait()
+			continue
+		}
+		backoff.Reset()
+		s.accept(conn)
+	}
+}
+
+// ListenAndServe binds to addr and serves until closed
+func (s *Server) ListenAndServe(addr string) error {
+	if err := s.Listen(addr); err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+// Close stops accepting and disconnects all clients
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	ln := s.listener
+	clients := make([]*client, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	for _, c := range clients {
+		c.conn.Close()
+	}
+	return err
+}
+
+func (s *Server) accept(conn net.Conn) {
+	s.mu.Lock()
+	if s.closed || len(s.clients) >= s.opts.MaxConns {
+		s.stats.Rejected++
+		s.mu.Unlock()
+		s.emit(Event{Kind: EventReject, Addr: conn.RemoteAddr().String()})
+		conn.Close()
+		return
+	}
+
+	s.nextID++
+	c := &client{
+		ClientInfo: ClientInfo{
+			ID:        fmt.Sprintf("c%d", s.nextID),
+			Addr:      conn.RemoteAddr().String(),
+			Connected: time.Now(),
+		},
+		conn: conn,
+	}
+	s.clients[c.ID] = c
+	s.stats.TotalConnections++
+	s.mu.Unlock()
+
+	s.emit(Event{Kind: EventJoin, Client: c.ID, Addr: c.Addr})
+	go s.handle(c)
+}
+
+func (s *Server) handle(c *client) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c.ID)
+		s.mu.Unlock()
+		c.conn.Close()
+		s.emit(Event{Kind: EventLeave, Client: c.ID, Addr: c.Addr})
+	}()
+
+	buf := buffer.Shared().Get(readBufferSize)
+	defer buf.Release()
+	for {
+		n, err := c.conn.Read(buf.Data)
+		if n > 0 {
+			s.relay(c, buf.Data[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// relay sends data from one client to all others unless it is muted
+func (s *Server) relay(from *client, data []byte) {
+	s.mu.Lock()
+	from.BytesIn += int64(len(data))
+	from.Messages++
+	s.stats.BytesIn += int64(len(data))
+	muted := from.Muted
+	var targets []*client
+	if !muted {
+		for _, c := range s.clients {
+			if c != from {
+				targets = append(targets, c)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	// Write without holding the lock so a slow client only stalls its sender
+	delivered := 0
+	for _, c := range targets {
+		if _, err := c.conn.Write(data); err != nil {
+			c.conn.Close()
+			continue
+		}
+		delivered++
+		s.mu.Lock()
+		c.BytesOut += int64(len(data))
+		s.stats.BytesOut += int64(len(data))
+		s.mu.Unlock()
+	}
+
+	s.emit(Event{Kind: EventData, Client: from.ID, Addr: from.Addr, Bytes: len(data), Receivers: delivered})
+}
+
+func (s *Server) emit(e Event) {
+	e.Time = time.Now()
+	s.mu.Lock()
+	s.events = append(s.events, e)
+	if len(s.events) > maxEvents {
+		s.events = s.events[len(s.events)-maxEvents:]
+	}
+	s.mu.Unlock()
+
+	if s.opts.OnEvent != nil {
+		s.opts.OnEvent(e)
+	}
+}
+
+// Clients returns the connected clients ordered by connection time
+func (s *Server) Clients() []ClientInfo {
+	s.mu.Lock()
+	clients := make([]ClientInfo, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c.ClientInfo)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Connected.Before(clients[j].Connected)
+	})
+	return clients
+}
+
+// Stats returns broker totals
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.ActiveConnections = len(s.clients)
+	return stats
+}
+
+// Events returns the most recent events, oldest first
+func (s *Server) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// Kick disconnects a client
+func (s *Server) Kick(id string) error {
+	s.mu.Lock()
+	c, ok := s.clients[id]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownClient, id)
+	}
+
+	s.emit(Event{Kind: EventKick, Client: c.ID, Addr: c.Addr})
+	return c.conn.Close()
+}
+
+// SetMuted stops (or resumes) relaying data sent by a client
+func (s *Server) SetMuted(id string, muted bool) error {
+	s.mu.Lock()
+	c, ok := s.clients[id]
+	if ok {
+		c.Muted = muted
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownClient, id)
+	}
+
+	kind := EventUnmute
+	if muted {
+		kind = EventMute
+	}
+	s.emit(Event{Kind: kind, Client: c.ID, Addr: c.Addr})
+	return nil
+}
@@ -0,0 +1,146 @@
+package broker
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/testutil"
+)
+
+func dial(t *testing.T, s *Server) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readN(t *testing.T, conn net.Conn, n int) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return string(buf)
+}
+
+func TestRelayAndStats(t *testing.T) {
+	s := testutil.StartServer(t, New(Options{}))
+	a, b := dial(t, s), dial(t, s)
+	testutil.WaitFor(t, "clients", func() bool { return len(s.Clients()) == 2 })
+
+	if _, err := a.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readN(t, b, 5); got != "hello" {
+		t.Errorf("b received %q, want %q", got, "hello")
+	}
+
+	clients := s.Clients()
+	if clients[0].BytesIn != 5 || clients[0].Messages != 1 {
+		t.Errorf("sender stats = %+v", clients[0])
+	}
+	if clients[1].BytesOut != 5 {
+		t.Errorf("receiver stats = %+v", clients[1])
+	}
+	stats := s.Stats()
+	if stats.ActiveConnections != 2 || stats.TotalConnections != 2 || stats.BytesIn != 5 || stats.BytesOut != 5 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	s := testutil.StartServer(t, New(Options{MaxConns: 1}))
+	dial(t, s)
+	testutil.WaitFor(t, "first client", func() bool { return len(s.Clients()) == 1 })
+
+	rejected := dial(t, s)
+	rejected.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := rejected.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the second client to be disconnected")
+	}
+	if got := s.Stats().Rejected; got != 1 {
+		t.Errorf("Rejected = %d, want 1", got)
+	}
+}
+
+func TestKickAndMute(t *testing.T) {
+	s := testutil.StartServer(t, New(Options{}))
+	a, b := dial(t, s), dial(t, s)
+	testutil.WaitFor(t, "clients", func() bool { return len(s.Clients()) == 2 })
+	clients := s.Clients()
+
+	if err := s.SetMuted(clients[0].ID, true); err != nil {
+		t.Fatalf("SetMuted failed: %v", err)
+	}
+	a.Write([]byte("dropped"))
+	testutil.WaitFor(t, "muted data", func() bool { return s.Stats().BytesIn == 7 })
+	b.Write([]byte("kept"))
+	if got := readN(t, a, 4); got != "kept" {
+		t.Errorf("a received %q, want %q", got, "kept")
+	}
+	if got := s.Stats().BytesOut; got != 4 {
+		t.Errorf("BytesOut = %d, want 4 (muted data must not be relayed)", got)
+	}
+
+	if err := s.Kick(clients[1].ID); err != nil {
+		t.Fatalf("Kick failed: %v", err)
+	}
+	testutil.WaitFor(t, "kick", func() bool { return len(s.Clients()) == 1 })
+	if err := s.Kick(clients[1].ID); !errors.Is(err, ErrUnknownClient) {
+		t.Errorf("Kick of departed client = %v, want ErrUnknownClient", err)
+	}
+
+	kinds := map[string]bool{}
+	for _, e := range s.Events() {
+		kinds[e.Kind] = true
+	}
+	for _, kind := range []string{EventJoin, EventData, EventMute, EventKick, EventLeave} {
+		if !kinds[kind] {
+			t.Errorf("missing %s event", kind)
+		}
+	}
+}
+
+// flakyListener fails its first Accepts the way a process out of file
+// descriptors does
+type flakyListener struct {
+	net.Listener
+	failures int
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServeSurvivesAcceptErrors(t *testing.T) {
+	events := make(chan Event, 16)
+	s := testutil.StartServer(t, New(Options{
+		OnEvent:      func(e Event) { events <- e },
+		WrapListener: func(ln net.Listener) net.Listener { return &flakyListener{Listener: ln, failures: 2} },
+	}))
+
+	dial(t, s)
+	testutil.WaitFor(t, "client", func() bool { return len(s.Clients()) == 1 })
+
+	var acceptErrors int
+	for len(events) > 0 {
+		if e := <-events; e.Kind == EventAcceptError {
+			acceptErrors++
+		}
+	}
+	if acceptErrors != 2 {
+		t.Errorf("%d accept errors reported, want 2", acceptErrors)
+	}
+}
@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"io"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -64,6 +65,14 @@ var DefaultSizes = []int{
 	65536, // 64KB - maximum TCP window
 }
 
+// shared is the process-wide pool used by the network relays
+var shared = NewBufferPool(1024, 65536, false)
+
+// Shared returns the process-wide buffer pool shared by the relays
+func Shared() *BufferPool {
+	return shared
+}
+
 // NewBufferPool creates a new buffer pool with specified configuration
 func NewBufferPool(minSize, maxSize int, adaptive bool) *BufferPool {
 	if minSize <= 0 {
@@ -351,6 +360,40 @@ func (bp *BufferPool) Close() {
 	bp.patterns.mu.Unlock()
 }
 
+// Copy copies from src to dst like io.Copy. Raw TCP connections and files
+// are left to the kernel (splice/sendfile on Linux), also below wrappers
+// implementing Unwrapper; anything else, such as TLS or rate-limited
+// connections, is copied through a pooled buffer of at least size bytes.
+func (bp *BufferPool) Copy(dst io.Writer, src io.Reader, size int) (int64, error) {
+	var written int64
+	for {
+		rawDst, rawSrc := unwrapWriter(dst), unwrapReader(src)
+		if !zeroCopy(rawDst, rawSrc) {
+			break
+		}
+		if rawDst == dst && rawSrc == src {
+			n, err := io.Copy(dst, src)
+			return written + n, err
+		}
+
+		// Splice in chunks so the wrappers keep live byte counts and can
+		// take over again, e.g. when a rate limit is set
+		n, err := io.Copy(rawDst, io.LimitReader(rawSrc, spliceChunkSize))
+		written += n
+		countUnwrapped(dst, src, n)
+		if err != nil || n < spliceChunkSize {
+			return written, err
+		}
+	}
+
+	buf := bp.Get(size)
+	defer buf.Release()
+
+	// Hide ReadFrom and WriteTo, whose fallbacks allocate their own buffer
+	n, err := io.CopyBuffer(writerOnly{dst}, readerOnly{src}, buf.Data)
+	return written + n, err
+}
+
 // Release returns the buffer to the pool (convenience method)
 func (mb *ManagedBuffer) Release() {
 	if mb.pool != nil {
@@ -1,6 +1,8 @@
 package buffer
 
 import (
+	"bytes"
+	"io"
 	"sync"
 	"testing"
 )
@@ -267,3 +269,22 @@ func BenchmarkDirectAllocation(b *testing.B) {
 		}
 	})
 }
+
+func TestBufferPoolCopy(t *testing.T) {
+	pool := NewBufferPool(1024, 65536, false)
+	data := bytes.Repeat([]byte("gocat"), 10000)
+
+	var dst bytes.Buffer
+	n, err := pool.Copy(&dst, struct{ io.Reader }{bytes.NewReader(data)}, 4096)
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(dst.Bytes(), data) {
+		t.Errorf("Copy wrote %d bytes, want %d", n, len(data))
+	}
+
+	stats := pool.GetStats()
+	if stats.TotalAllocations != 1 || stats.ActiveBuffers != 0 {
+		t.Errorf("Expected one released buffer, got %+v", stats)
+	}
+}
@@ -0,0 +1,101 @@
+package buffer
+
+import (
+	"io"
+	"net"
+	"os"
+)
+
+// spliceChunkSize bounds a single kernel copy between unwrapped connections
+const spliceChunkSize = 1 << 20
+
+// Unwrapper is implemented by connection wrappers that only observe the
+// traffic passing through them. Copy relays between the connections below
+// them, through any number of such wrappers, so the kernel can move the
+// data, and reports the bytes back to each wrapper.
+type Unwrapper interface {
+	// Unwrap returns the wrapped connection, or nil while the data has to
+	// pass through the wrapper, for example because it is rate limited
+	Unwrap() net.Conn
+	// CountRead records bytes read from the wrapped connection
+	CountRead(n int64)
+	// CountWritten records bytes written to the wrapped connection
+	CountWritten(n int64)
+}
+
+// ZeroCopy reports whether Copy leaves copying src to dst to the kernel
+func ZeroCopy(dst io.Writer, src io.Reader) bool {
+	return zeroCopy(unwrapWriter(dst), unwrapReader(src))
+}
+
+type readerOnly struct{ io.Reader }
+
+type writerOnly struct{ io.Writer }
+
+// unwrapped follows the Unwrapper chain below v and returns the innermost
+// connection it reaches, or nil if v does not unwrap
+func unwrapped(v any) net.Conn {
+	var conn net.Conn
+	for {
+		u, ok := v.(Unwrapper)
+		if !ok {
+			return conn
+		}
+		next := u.Unwrap()
+		if next == nil {
+			return conn
+		}
+		conn, v = next, next
+	}
+}
+
+func unwrapWriter(w io.Writer) io.Writer {
+	if conn := unwrapped(w); conn != nil {
+		return conn
+	}
+	return w
+}
+
+func unwrapReader(r io.Reader) io.Reader {
+	if conn := unwrapped(r); conn != nil {
+		return conn
+	}
+	return r
+}
+
+// countUnwrapped reports n bytes copied below the wrappers of dst and src
+// to every wrapper on the way down
+func countUnwrapped(dst io.Writer, src io.Reader, n int64) {
+	for v := any(dst); ; {
+		u, ok := v.(Unwrapper)
+		if !ok {
+			break
+		}
+		u.CountWritten(n)
+		if v = u.Unwrap(); v == nil {
+			break
+		}
+	}
+	for v := any(src); ; {
+		u, ok := v.(Unwrapper)
+		if !ok {
+			break
+		}
+		u.CountRead(n)
+		if v = u.Unwrap(); v == nil {
+			break
+		}
+	}
+}
+
+// isRaw reports whether v is a connection or file the kernel can copy
+// from or to directly
+func isRaw(v any) bool {
+	switch v := v.(type) {
+	case *net.TCPConn, *os.File:
+		return true
+	case *io.LimitedReader:
+		return isRaw(v.R)
+	}
+	return false
+}
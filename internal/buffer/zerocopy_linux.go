@@ -0,0 +1,10 @@
+package buffer
+
+import "io"
+
+// zeroCopy reports whether io.Copy moves src to dst inside the kernel: TCP
+// to TCP and socket to file use splice, file to TCP uses sendfile and file
+// to file copy_file_range
+func zeroCopy(dst io.Writer, src io.Reader) bool {
+	return isRaw(dst) && isRaw(src)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package buffer
+
+import "io"
+
+// zeroCopy reports whether io.Copy moves src to dst inside the kernel; the
+// fast path is only used on Linux
+func zeroCopy(dst io.Writer, src io.Reader) bool {
+	return false
+}
@@ -0,0 +1,149 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ibrahmsql/gocat/internal/testutil"
+)
+
+// countingConn counts traffic like the session wrappers of the commands
+type countingConn struct {
+	net.Conn
+	passthrough   bool
+	read, written int64
+}
+
+func (c *countingConn) Unwrap() net.Conn {
+	if c.passthrough {
+		return c.Conn
+	}
+	return nil
+}
+
+func (c *countingConn) CountRead(n int64)    { atomic.AddInt64(&c.read, n) }
+func (c *countingConn) CountWritten(n int64) { atomic.AddInt64(&c.written, n) }
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.CountRead(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.CountWritten(int64(n))
+	return n, err
+}
+
+// relayThrough sends data into one loopback connection, relays it to a
+// second one with Copy and returns what arrived at the far end, or nothing
+// when benchmarking
+func relayThrough(tb testing.TB, data []byte, wrap func(dst, src net.Conn) (io.Writer, io.Reader)) (int64, []byte) {
+	tb.Helper()
+	in, src := testutil.TCPPair(tb, 0)
+	dst, out := testutil.TCPPair(tb, 0)
+
+	go func() {
+		in.Write(data)
+		in.CloseWrite()
+	}()
+	received := make(chan []byte, 1)
+	go func() {
+		if _, ok := tb.(*testing.B); ok {
+			io.Copy(io.Discard, out)
+			received <- nil
+			return
+		}
+		got, _ := io.ReadAll(out)
+		received <- got
+	}()
+
+	w, r := wrap(dst, src)
+	n, err := Shared().Copy(w, r, 32*1024)
+	if err != nil {
+		tb.Fatalf("Copy failed: %v", err)
+	}
+	dst.CloseWrite()
+	return n, <-received
+}
+
+func TestCopyZeroCopy(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 200000)
+
+	tests := []struct {
+		name        string
+		passthrough bool
+		zeroCopy    bool
+	}{
+		{"counted passthrough", true, runtime.GOOS == "linux"},
+		{"counted buffered", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dstConn, srcConn *countingConn
+			n, got := relayThrough(t, data, func(dst, src net.Conn) (io.Writer, io.Reader) {
+				dstConn = &countingConn{Conn: dst, passthrough: tt.passthrough}
+				srcConn = &countingConn{Conn: src, passthrough: tt.passthrough}
+				if ZeroCopy(dstConn, srcConn) != tt.zeroCopy {
+					t.Errorf("ZeroCopy = %v, want %v", !tt.zeroCopy, tt.zeroCopy)
+				}
+				return dstConn, srcConn
+			})
+
+			if n != int64(len(data)) || !bytes.Equal(got, data) {
+				t.Fatalf("relayed %d bytes, received %d, want %d", n, len(got), len(data))
+			}
+			if srcConn.read != n || dstConn.written != n {
+				t.Errorf("counted %d read and %d written, want %d", srcConn.read, dstConn.written, n)
+			}
+		})
+	}
+}
+
+func TestZeroCopyDetection(t *testing.T) {
+	client, server := testutil.TCPPair(t, 0)
+	if got, want := ZeroCopy(client, server), runtime.GOOS == "linux"; got != want {
+		t.Errorf("ZeroCopy(TCP, TCP) = %v, want %v", got, want)
+	}
+	if ZeroCopy(client, struct{ io.Reader }{server}) {
+		t.Error("ZeroCopy should be false for a wrapped reader")
+	}
+	if ZeroCopy(&bytes.Buffer{}, server) {
+		t.Error("ZeroCopy should be false for an in-memory writer")
+	}
+}
+
+// BenchmarkCopyLoopback relays 64MB between loopback TCP connections with
+// splice, through a counting wrapper, and through the pooled buffer
+func BenchmarkCopyLoopback(b *testing.B) {
+	data := make([]byte, 64<<20)
+	cases := []struct {
+		name string
+		wrap func(dst, src net.Conn) (io.Writer, io.Reader)
+	}{
+		{"raw", func(dst, src net.Conn) (io.Writer, io.Reader) { return dst, src }},
+		{"unwrapped", func(dst, src net.Conn) (io.Writer, io.Reader) {
+			return &countingConn{Conn: dst, passthrough: true}, &countingConn{Conn: src, passthrough: true}
+		}},
+		{"buffered", func(dst, src net.Conn) (io.Writer, io.Reader) {
+			return &countingConn{Conn: dst}, &countingConn{Conn: src}
+		}},
+	}
+
+	for _, bc := range cases {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if n, _ := relayThrough(b, data, bc.wrap); n != int64(len(data)) {
+					b.Fatalf("relayed %d bytes, want %d", n, len(data))
+				}
+			}
+		})
+	}
+}
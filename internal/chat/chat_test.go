@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/testutil"
+)
+
+func dial(t *testing.T, s *Server, nick, room string) *Client {
+	t.Helper()
+	c, err := Dial(context.Background(), s.Addr().String(), nick, room)
+	if err != nil {
+		t.Fatalf("Dial(%s) failed: %v", nick, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// expect reads lines from c until one contains want
+func expect(t *testing.T, c *Client, want string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-c.Messages():
+			if !ok {
+				t.Fatalf("%s: connection closed waiting for %q", c.Nick(), want)
+			}
+			if strings.Contains(line, want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("%s: timed out waiting for %q", c.Nick(), want)
+		}
+	}
+}
+
+func TestHandshakeAndMessages(t *testing.T) {
+	s := testutil.StartServer(t, New(Options{Room: "lobby"}))
+	alice := dial(t, s, "alice", "")
+	bob := dial(t, s, "alice", "")
+
+	if alice.Nick() != "alice" || bob.Nick() != "alice2" {
+		t.Errorf("nicks = %q, %q; want alice, alice2", alice.Nick(), bob.Nick())
+	}
+	if alice.Room() != "lobby" {
+		t.Errorf("Room() = %q, want lobby", alice.Room())
+	}
+	expect(t, alice, "alice2 joined the chat")
+
+	bob.Send("/nick bob")
+	expect(t, bob, "You are now known as bob")
+	expect(t, alice, "alice2 is now known as bob")
+	if bob.Nick() != "bob" {
+		t.Errorf("Nick() after /nick = %q, want bob", bob.Nick())
+	}
+
+	alice.Send("hi bob")
+	expect(t, bob, "alice: hi bob")
+
+	testutil.WaitFor(t, "message stats", func() bool { return s.Stats().Messages == 1 })
+	for _, c := range s.Clients() {
+		if c.BytesIn == 0 || c.BytesOut == 0 {
+			t.Errorf("client %s has no traffic counted: %+v", c.Nick, c)
+		}
+	}
+}
+
+func TestRooms(t *testing.T) {
+	s := testutil.StartServer(t, New(Options{}))
+	alice := dial(t, s, "alice", "")
+	bob := dial(t, s, "bob", "ops")
+	expect(t, bob, "You joined room ops")
+	if bob.Room() != "ops" {
+		t.Errorf("Room() = %q, want ops", bob.Room())
+	}
+
+	carol := dial(t, s, "carol", "ops")
+	expect(t, bob, "carol joined ops")
+
+	// Messages stay in their room
+	alice.Send("lobby only")
+	carol.Send("ops only")
+	expect(t, bob, "carol: ops only")
+	alice.Send("/list")
+	expect(t, alice, "Online users in "+DefaultRoom+" (1)")
+
+	rooms := s.Stats().Rooms
+	if rooms[DefaultRoom] != 1 || rooms["ops"] != 2 {
+		t.Errorf("Rooms = %v", rooms)
+	}
+}
+
+func TestKickAndMute(t *testing.T) {
+	s := testutil.StartServer(t, New(Options{}))
+	alice := dial(t, s, "alice", "")
+	bob := dial(t, s, "bob", "")
+	testutil.WaitFor(t, "clients", func() bool { return len(s.Clients()) == 2 })
+	ids := map[string]string{}
+	for _, c := range s.Clients() {
+		ids[c.Nick] = c.ID
+	}
+
+	if err := s.SetMuted(ids["bob"], true); err != nil {
+		t.Fatalf("SetMuted failed: %v", err)
+	}
+	expect(t, bob, "You are muted")
+	bob.Send("can anyone hear me")
+	expect(t, bob, "You are muted")
+
+	if err := s.Kick(ids["bob"]); err != nil {
+		t.Fatalf("Kick failed: %v", err)
+	}
+	expect(t, alice, "bob was kicked")
+	testutil.WaitFor(t, "kick", func() bool { return len(s.Clients()) == 1 })
+
+	for _, e := range s.Events() {
+		if e.Kind == EventMessage && e.Nick == "bob" {
+			t.Errorf("muted message was relayed: %+v", e)
+		}
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	s := testutil.StartServer(t, New(Options{MaxConns: 1}))
+	dial(t, s, "alice", "")
+	if _, err := Dial(context.Background(), s.Addr().String(), "bob", ""); err == nil {
+		t.Error("expected the second client to be rejected")
+	}
+}
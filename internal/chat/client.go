@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	handshakeTimeout = 10 * time.Second
+	messageBuffer    = 256
+
+	nicknamePrompt = "Please enter your nickname: "
+)
+
+// Client is a connection to a chat server
+type Client struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	messages chan string
+
+	mu   sync.Mutex
+	nick string
+	room string
+	err  error
+}
+
+// Dial connects to a chat server at addr, registers nick and, if room differs
+// from the server's default room, joins it
+func Dial(ctx context.Context, addr, nick, room string) (*Client, error) {
+	nick = cleanName(nick, maxNickLength)
+	if nick == "" {
+		return nil, errors.New("nickname is required")
+	}
+
+	dialer := net.Dialer{Timeout: handshakeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:     conn,
+		reader:   bufio.NewReader(conn),
+		messages: make(chan string, messageBuffer),
+	}
+	if err := c.handshake(nick); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if room = cleanName(room, maxRoomLength); room != "" && room != c.room {
+		if err := c.Send("/join " + room); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	go c.readLoop()
+	return c, nil
+}
+
+// handshake answers the nickname prompt and waits for the join confirmation
+func (c *Client) handshake(nick string) error {
+	c.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := fmt.Fprintf(c.conn, "%s\n", nick); err != nil {
+		return err
+	}
+
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("chat handshake failed: %w", err)
+		}
+		line = strings.TrimPrefix(strings.TrimRight(line, "\r\n"), nicknamePrompt)
+
+		switch {
+		case strings.HasPrefix(line, "Welcome to ") && strings.HasSuffix(line, "!"):
+			c.room = strings.TrimSuffix(strings.TrimPrefix(line, "Welcome to "), "!")
+		case strings.HasPrefix(line, "You joined as '"):
+			c.nick, _, _ = strings.Cut(strings.TrimPrefix(line, "You joined as '"), "'")
+			c.messages <- line
+			return nil
+		case strings.Contains(line, "room is full"):
+			return errors.New(line)
+		}
+	}
+}
+
+// readLoop delivers server lines until the connection closes, tracking
+// nickname and room changes confirmed by the server
+func (c *Client) readLoop() {
+	defer close(c.messages)
+
+	for {
+		line, err := c.reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			c.track(line)
+			c.messages <- line
+		}
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (c *Client) track(line string) {
+	notice, ok := strings.CutPrefix(line, "*** ")
+	if !ok {
+		return
+	}
+	notice = strings.TrimSuffix(notice, " ***")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if nick, ok := strings.CutPrefix(notice, "You are now known as "); ok {
+		c.nick = nick
+	} else if room, ok := strings.CutPrefix(notice, "You joined room "); ok {
+		c.room = room
+	}
+}
+
+// Messages returns the lines received from the server. The channel is closed
+// when the connection ends.
+func (c *Client) Messages() <-chan string {
+	return c.messages
+}
+
+// Send sends a message, or a /command, to the server
+func (c *Client) Send(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	_, err := fmt.Fprintf(c.conn, "%s\n", text)
+	return err
+}
+
+// Nick returns the nickname confirmed by the server
+func (c *Client) Nick() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nick
+}
+
+// Room returns the current room
+func (c *Client) Room() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.room
+}
+
+// Err returns the error that ended the connection, if any
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// RemoteAddr returns the server address
+func (c *Client) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close disconnects from the server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,612 @@
+// Package chat implements the line based chat server and a client for it.
+//
+// The protocol is plain text so any netcat can take part: the server greets a
+// new connection and asks for a nickname, then every line is either a
+// message to the current room or a /command.
+package chat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/network"
+)
+
+const (
+	// DefaultRoom is the room clients join on connect
+	DefaultRoom = "GoCat-Room"
+
+	// DefaultMaxConns is the default client limit
+	DefaultMaxConns = 20
+
+	// maxEvents bounds the recent events kept for monitoring
+	maxEvents = 200
+
+	maxNickLength = 32
+	maxRoomLength = 32
+)
+
+// Event kinds
+const (
+	EventJoin    = "join"
+	EventLeave   = "leave"
+	EventMessage = "message"
+	EventNick    = "nick"
+	EventRoom    = "room"
+	EventReject  = "reject"
+	EventKick    = "kick"
+	EventMute    = "mute"
+	EventUnmute  = "unmute"
+	// EventAcceptError reports a failed Accept in Text; the server keeps
+	// serving
+	EventAcceptError = "accept_error"
+)
+
+// Event is something that happened on the chat server
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Client string    `json:"client,omitempty"`
+	Nick   string    `json:"nick,omitempty"`
+	Room   string    `json:"room,omitempty"`
+	Text   string    `json:"text,omitempty"`
+}
+
+// ClientInfo describes a connected client
+type ClientInfo struct {
+	ID        string    `json:"id"`
+	Nick      string    `json:"nick"`
+	Room      string    `json:"room"`
+	Addr      string    `json:"addr"`
+	Connected time.Time `json:"connected"`
+	BytesIn   int64     `json:"bytes_in"`
+	BytesOut  int64     `json:"bytes_out"`
+	Messages  int64     `json:"messages"`
+	Muted     bool      `json:"muted"`
+}
+
+// Stats are chat server totals
+type Stats struct {
+	Started           time.Time      `json:"started"`
+	TotalConnections  int64          `json:"total_connections"`
+	ActiveConnections int            `json:"active_connections"`
+	Rejected          int64          `json:"rejected"`
+	Messages          int64          `json:"messages"`
+	Rooms             map[string]int `json:"rooms"`
+}
+
+// Options configures a chat server
+type Options struct {
+	// Room is the room new clients join; it also names the server
+	Room     string
+	MaxConns int
+	// OnEvent is called for every event, e.g. to log it
+	OnEvent func(Event)
+	// WrapListener, when set, wraps the bound listener, e.g. to apply
+	// access control before clients reach the server
+	WrapListener func(net.Listener) net.Listener
+}
+
+type client struct {
+	ClientInfo
+	conn    net.Conn
+	writeMu sync.Mutex
+}
+
+// Server is a chat server
+type Server struct {
+	opts Options
+
+	mu       sync.Mutex
+	listener net.Listener
+	clients  map[string]*client
+	nextID   int64
+	stats    Stats
+	events   []Event
+	closed   bool
+}
+
+// ErrUnknownClient is returned for actions on clients that are not connected
+var ErrUnknownClient = errors.New("unknown client")
+
+// New creates a chat server
+func New(opts Options) *Server {
+	if opts.Room == "" {
+		opts.Room = DefaultRoom
+	}
+	if opts.MaxConns <= 0 {
+		opts.MaxConns = DefaultMaxConns
+	}
+	return &Server{
+		opts:    opts,
+		clients: make(map[string]*client),
+	}
+}
+
+// Listen binds the server to a TCP address
+func (s *Server) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start chat server: %w", err)
+	}
+	if s.opts.WrapListener != nil {
+		ln = s.opts.WrapListener(ln)
+	}
+	s.mu.Lock()
+	s.listener = ln
+	s.stats.Started = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Addr returns the listening address, or nil before Listen
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Room returns the default room
+func (s *Server) Room() string {
+	return s.opts.Room
+}
+
+// Serve accepts clients until the server is closed. Other accept errors are
+// reported as events and retried after a backoff.
+func (s *Server) Serve() error {
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln == nil {
+		return errors.New("chat server is not listening")
+	}
+
+	var backoff network.AcceptBackoff
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.emit(Event{Kind: EventAcceptError, Text: err.Error()})
+			backoff.Wait()
+			continue
+		}
+		backoff.Reset()
+
+		s.mu.Lock()
+		full := s.closed || len(s.clients) >= s.opts.MaxConns
+		if full {
+			s.stats.Rejected++
+		}
+		s.mu.Unlock()
+		if full {
+			s.emit(Event{Kind: EventReject, Text: conn.RemoteAddr().String()})
+			conn.Write([]byte("Chat room is full. Please try again later.\n"))
+			conn.Close()
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+// ListenAndServe binds to addr and serves until closed
+func (s *Server) ListenAndServe(addr string) error {
+	if err := s.Listen(addr); err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+// Close stops accepting and disconnects all clients
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	ln := s.listener
+	clients := s.snapshot("")
+	s.mu.Unlock()
+
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	for _, c := range clients {
+		c.conn.Close()
+	}
+	return err
+}
+
+// countingConn counts bytes read from a client. Bytes read before the client
+// is registered are held in pending.
+type countingConn struct {
+	net.Conn
+	server  *Server
+	client  *client
+	pending int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		if c.client == nil {
+			c.pending += int64(n)
+		} else {
+			c.server.mu.Lock()
+			c.client.BytesIn += int64(n)
+			c.server.mu.Unlock()
+		}
+	}
+	return n, err
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	counted := &countingConn{Conn: conn, server: s}
+	reader := bufio.NewReader(counted)
+
+	// Welcome message and nickname prompt
+	if _, err := fmt.Fprintf(conn, "Welcome to %s!\nPlease enter your nickname: ", s.opts.Room); err != nil {
+		return
+	}
+	nickname, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	c := s.register(conn, nickname)
+	s.mu.Lock()
+	c.BytesIn += counted.pending
+	s.mu.Unlock()
+	counted.client = c
+	defer s.unregister(c)
+
+	s.send(c, fmt.Sprintf("You joined as '%s'. Type /help for commands.", c.Nick))
+	s.broadcast(c.Room, fmt.Sprintf("*** %s joined the chat ***", c.Nick), "")
+
+	// Handle messages
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "/") {
+			if !s.command(c, line) {
+				return
+			}
+			continue
+		}
+		s.message(c, line)
+	}
+}
+
+// register adds a client under a unique nickname in the default room
+func (s *Server) register(conn net.Conn, nickname string) *client {
+	s.mu.Lock()
+	s.nextID++
+	c := &client{
+		ClientInfo: ClientInfo{
+			ID:        fmt.Sprintf("c%d", s.nextID),
+			Room:      s.opts.Room,
+			Addr:      conn.RemoteAddr().String(),
+			Connected: time.Now(),
+		},
+		conn: conn,
+	}
+	c.Nick = s.uniqueNick(cleanName(nickname, maxNickLength), "")
+	if c.Nick == "" {
+		c.Nick = s.uniqueNick(fmt.Sprintf("Guest-%d", s.nextID), "")
+	}
+	s.clients[c.ID] = c
+	s.stats.TotalConnections++
+	s.mu.Unlock()
+
+	s.emit(Event{Kind: EventJoin, Client: c.ID, Nick: c.Nick, Room: c.Room, Text: c.Addr})
+	return c
+}
+
+func (s *Server) unregister(c *client) {
+	s.mu.Lock()
+	delete(s.clients, c.ID)
+	nick, room := c.Nick, c.Room
+	s.mu.Unlock()
+
+	s.broadcast(room, fmt.Sprintf("*** %s left the chat ***", nick), "")
+	s.emit(Event{Kind: EventLeave, Client: c.ID, Nick: nick, Room: room})
+}
+
+// uniqueNick appends a counter to nick until no other client uses it.
+// The caller holds s.mu.
+func (s *Server) uniqueNick(nick, self string) string {
+	if nick == "" {
+		return ""
+	}
+	candidate := nick
+	for counter := 2; ; counter++ {
+		taken := false
+		for id, other := range s.clients {
+			if id != self && strings.EqualFold(other.Nick, candidate) {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", nick, counter)
+	}
+}
+
+// cleanName keeps the first word of a nickname or room name
+func cleanName(name string, limit int) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return ""
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, fields[0])
+	if len(name) > limit {
+		name = name[:limit]
+	}
+	return name
+}
+
+// message broadcasts a line to the sender's room unless the sender is muted
+func (s *Server) message(c *client, text string) {
+	s.mu.Lock()
+	nick, room, muted := c.Nick, c.Room, c.Muted
+	if !muted {
+		c.Messages++
+		s.stats.Messages++
+	}
+	s.mu.Unlock()
+
+	if muted {
+		s.send(c, "*** You are muted ***")
+		return
+	}
+	s.broadcast(room, fmt.Sprintf("[%s] %s: %s", time.Now().Format("15:04"), nick, text), c.ID)
+	s.emit(Event{Kind: EventMessage, Client: c.ID, Nick: nick, Room: room, Text: text})
+}
+
+// command handles a /command and reports whether the client stays connected
+func (s *Server) command(c *client, line string) bool {
+	parts := strings.Fields(line)
+	switch strings.ToLower(parts[0]) {
+	case "/help":
+		s.send(c, `Available commands:
+/help - Show this help
+/list - List users in your room
+/rooms - List rooms
+/join <room> - Switch to another room
+/nick <name> - Change your nickname
+/time - Show current time
+/quit - Leave the chat`)
+
+	case "/list":
+		s.mu.Lock()
+		room := c.Room
+		var lines []string
+		for _, other := range s.snapshot(room) {
+			duration := time.Since(other.Connected).Truncate(time.Second)
+			lines = append(lines, fmt.Sprintf("  %s (online for %s)", other.Nick, duration))
+		}
+		s.mu.Unlock()
+		sort.Strings(lines)
+		s.send(c, fmt.Sprintf("Online users in %s (%d):\n%s", room, len(lines), strings.Join(lines, "\n")))
+
+	case "/rooms":
+		rooms := s.Stats().Rooms
+		names := make([]string, 0, len(rooms))
+		for name := range rooms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var list strings.Builder
+		fmt.Fprintf(&list, "Rooms (%d):", len(names))
+		for _, name := range names {
+			fmt.Fprintf(&list, "\n  %s (%d)", name, rooms[name])
+		}
+		s.send(c, list.String())
+
+	case "/join":
+		room := ""
+		if len(parts) > 1 {
+			room = cleanName(parts[1], maxRoomLength)
+		}
+		if room == "" {
+			s.send(c, "Usage: /join <room>")
+			return true
+		}
+		s.mu.Lock()
+		old, nick := c.Room, c.Nick
+		c.Room = room
+		s.mu.Unlock()
+		if old == room {
+			s.send(c, fmt.Sprintf("*** You are already in %s ***", room))
+			return true
+		}
+		s.broadcast(old, fmt.Sprintf("*** %s left for %s ***", nick, room), c.ID)
+		s.send(c, fmt.Sprintf("*** You joined room %s ***", room))
+		s.broadcast(room, fmt.Sprintf("*** %s joined %s ***", nick, room), c.ID)
+		s.emit(Event{Kind: EventRoom, Client: c.ID, Nick: nick, Room: room, Text: old})
+
+	case "/nick":
+		nick := ""
+		if len(parts) > 1 {
+			nick = cleanName(parts[1], maxNickLength)
+		}
+		if nick == "" {
+			s.send(c, "Usage: /nick <name>")
+			return true
+		}
+		s.mu.Lock()
+		old, room := c.Nick, c.Room
+		c.Nick = s.uniqueNick(nick, c.ID)
+		nick = c.Nick
+		s.mu.Unlock()
+		s.send(c, fmt.Sprintf("*** You are now known as %s ***", nick))
+		s.broadcast(room, fmt.Sprintf("*** %s is now known as %s ***", old, nick), c.ID)
+		s.emit(Event{Kind: EventNick, Client: c.ID, Nick: nick, Room: room, Text: old})
+
+	case "/time":
+		s.send(c, fmt.Sprintf("Current time: %s", time.Now().Format("2006-01-02 15:04:05")))
+
+	case "/quit":
+		s.send(c, "Goodbye!")
+		return false
+
+	default:
+		s.send(c, fmt.Sprintf("Unknown command: %s. Type /help for available commands.", parts[0]))
+	}
+	return true
+}
+
+// snapshot returns the clients in room, or all clients if room is empty.
+// The caller holds s.mu.
+func (s *Server) snapshot(room string) []*client {
+	clients := make([]*client, 0, len(s.clients))
+	for _, c := range s.clients {
+		if room == "" || c.Room == room {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// send writes a line to one client
+func (s *Server) send(c *client, line string) {
+	c.writeMu.Lock()
+	n, err := c.conn.Write([]byte(line + "\n"))
+	c.writeMu.Unlock()
+
+	s.mu.Lock()
+	c.BytesOut += int64(n)
+	s.mu.Unlock()
+	if err != nil {
+		c.conn.Close()
+	}
+}
+
+// broadcast sends a line to everyone in room except the client with ID exclude
+func (s *Server) broadcast(room, line, exclude string) {
+	s.mu.Lock()
+	clients := s.snapshot(room)
+	s.mu.Unlock()
+
+	// Send messages without holding the lock
+	for _, c := range clients {
+		if c.ID != exclude {
+			s.send(c, line)
+		}
+	}
+}
+
+func (s *Server) emit(e Event) {
+	e.Time = time.Now()
+	s.mu.Lock()
+	s.events = append(s.events, e)
+	if len(s.events) > maxEvents {
+		s.events = s.events[len(s.events)-maxEvents:]
+	}
+	s.mu.Unlock()
+
+	if s.opts.OnEvent != nil {
+		s.opts.OnEvent(e)
+	}
+}
+
+// Clients returns the connected clients ordered by connection time
+func (s *Server) Clients() []ClientInfo {
+	s.mu.Lock()
+	clients := make([]ClientInfo, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c.ClientInfo)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Connected.Before(clients[j].Connected)
+	})
+	return clients
+}
+
+// Stats returns server totals and the number of clients per room
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.ActiveConnections = len(s.clients)
+	stats.Rooms = make(map[string]int)
+	for _, c := range s.clients {
+		stats.Rooms[c.Room]++
+	}
+	return stats
+}
+
+// Events returns the most recent events, oldest first
+func (s *Server) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// Kick disconnects a client after telling it and its room
+func (s *Server) Kick(id string) error {
+	s.mu.Lock()
+	c, ok := s.clients[id]
+	var nick, room string
+	if ok {
+		nick, room = c.Nick, c.Room
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownClient, id)
+	}
+
+	s.send(c, "*** You were kicked ***")
+	s.broadcast(room, fmt.Sprintf("*** %s was kicked ***", nick), c.ID)
+	s.emit(Event{Kind: EventKick, Client: id, Nick: nick, Room: room})
+	return c.conn.Close()
+}
+
+// SetMuted stops (or resumes) relaying messages sent by a client
+func (s *Server) SetMuted(id string, muted bool) error {
+	s.mu.Lock()
+	c, ok := s.clients[id]
+	var nick, room string
+	if ok {
+		c.Muted = muted
+		nick, room = c.Nick, c.Room
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownClient, id)
+	}
+
+	kind, notice := EventUnmute, "*** You are no longer muted ***"
+	if muted {
+		kind, notice = EventMute, "*** You are muted ***"
+	}
+	s.send(c, notice)
+	s.emit(Event{Kind: kind, Client: id, Nick: nick, Room: room})
+	return nil
+}
@@ -0,0 +1,190 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DefaultTimeout bounds an exchange with a server
+const DefaultTimeout = 5 * time.Second
+
+// Client sends queries to DNS servers
+type Client struct {
+	// Timeout bounds each exchange; 0 means DefaultTimeout
+	Timeout time.Duration
+	// TCP queries over TCP from the start instead of after a truncated
+	// UDP answer
+	TCP bool
+	// Dial connects to servers; nil uses a net.Dialer
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Response is the answer of a server to a query
+type Response struct {
+	dnsmessage.Message
+	// Server answered over Network
+	Server  string
+	Network string
+	// Fallback is set when a truncated UDP answer was retried over TCP
+	Fallback bool
+	RTT      time.Duration
+	// Size is the length of the response on the wire
+	Size int
+}
+
+// Exchange sends query to server, a host with an optional port, and waits
+// for its response. A truncated UDP answer is retried over TCP.
+func (c *Client) Exchange(ctx context.Context, query dnsmessage.Message, server string) (*Response, error) {
+	server = WithPort(server, "53")
+	packet, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack query: %w", err)
+	}
+
+	if !c.TCP {
+		resp, err := c.exchange(ctx, "udp", server, query, packet)
+		if err != nil || !resp.Truncated {
+			return resp, err
+		}
+	}
+	resp, err := c.exchange(ctx, "tcp", server, query, packet)
+	if err == nil {
+		resp.Fallback = !c.TCP
+	}
+	return resp, err
+}
+
+func (c *Client) exchange(ctx context.Context, network, server string, query dnsmessage.Message, packet []byte) (*Response, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dial := c.Dial
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+
+	start := time.Now()
+	conn, err := dial(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var raw []byte
+	if network == "udp" {
+		raw, err = packetRoundTrip(conn, packet, query)
+	} else {
+		raw, err = streamRoundTrip(conn, packet)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{Server: server, Network: network, RTT: time.Since(start), Size: len(raw)}
+	if err := resp.Unpack(raw); err != nil {
+		return nil, fmt.Errorf("malformed response: %w", err)
+	}
+	if resp.ID != query.ID || !resp.Header.Response {
+		return nil, errors.New("response does not match the query")
+	}
+	return resp, nil
+}
+
+// packetRoundTrip sends a query in a datagram and waits for the response
+// with its ID and question, ignoring strays
+func packetRoundTrip(conn net.Conn, packet []byte, query dnsmessage.Message) ([]byte, error) {
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var p dnsmessage.Parser
+		header, err := p.Start(buf[:n])
+		if err != nil || header.ID != query.ID {
+			continue
+		}
+		q, err := p.Question()
+		if err == nil && q.Type == query.Questions[0].Type && strings.EqualFold(q.Name.String(), query.Questions[0].Name.String()) {
+			return buf[:n], nil
+		}
+	}
+}
+
+// streamRoundTrip sends a query with the 2-byte length prefix of DNS over
+// TCP and reads the response
+func streamRoundTrip(conn net.Conn, packet []byte) ([]byte, error) {
+	if _, err := conn.Write(Frame(packet)); err != nil {
+		return nil, err
+	}
+	return ReadFrame(conn)
+}
+
+// Frame prefixes a message with its length, as DNS over TCP sends it
+func Frame(packet []byte) []byte {
+	framed := make([]byte, 2+len(packet))
+	binary.BigEndian.PutUint16(framed, uint16(len(packet)))
+	copy(framed[2:], packet)
+	return framed
+}
+
+// ReadFrame reads a length-prefixed message from a stream
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	packet := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// WithPort adds port to an address that has none
+func WithPort(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), port)
+}
+
+// SystemServer returns the first nameserver of /etc/resolv.conf, or the
+// local host when there is none
+func SystemServer() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				// Zones of link-local servers are kept for dialing
+				return net.JoinHostPort(fields[1], "53")
+			}
+		}
+	}
+	return "127.0.0.1:53"
+}
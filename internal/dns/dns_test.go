@@ -0,0 +1,275 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const testZone = `
+$ORIGIN example.test.
+$TTL 300
+@	IN	SOA	ns1 hostmaster (
+		2024010101 ; serial
+		3600 600 86400 60 )
+	IN	NS	ns1
+	IN	MX	10 mail
+ns1	60	A	192.0.2.53
+mail	A	192.0.2.25
+www	A	192.0.2.10
+	AAAA	2001:db8::10
+alias	CNAME	www
+chain	CNAME	alias.example.test.
+txt	TXT	"v=spf1 -all" "semi;colon \"quoted\""
+_sip._udp	SRV	0 5 5060 www
+*.dyn	1h	A	192.0.2.99
+$ORIGIN 2.0.192.in-addr.arpa.
+10	PTR	www.example.test.
+`
+
+// serve runs srv over UDP and TCP on one port of the loopback
+func serve(t *testing.T, srv *Server) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		conn.Close()
+		t.Skipf("TCP port of the UDP server is taken: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		ln.Close()
+	})
+
+	go srv.ServeUDP(conn)
+	go srv.ServeTCP(ln)
+	return conn.LocalAddr().String()
+}
+
+func query(t *testing.T, client *Client, server, name string, qtype dnsmessage.Type, opts QueryOptions) *Response {
+	t.Helper()
+	msg, err := NewQuery(name, qtype, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Exchange(context.Background(), msg, server)
+	if err != nil {
+		t.Fatalf("%s %s: %v", name, TypeString(qtype), err)
+	}
+	return resp
+}
+
+func data(records []Record) string {
+	var parts []string
+	for _, r := range records {
+		parts = append(parts, TypeString(r.Type)+" "+r.Data)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func TestParseZone(t *testing.T) {
+	zone, err := ParseZone(strings.NewReader(testZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.Origin != "example.test." {
+		t.Errorf("origin = %q", zone.Origin)
+	}
+
+	for name, want := range map[string]string{
+		"example.test.":          "SOA ns1.example.test. hostmaster.example.test. 2024010101 3600 600 86400 60, NS ns1.example.test., MX 10 mail.example.test.",
+		"WWW.example.test":       "A 192.0.2.10, AAAA 2001:db8::10",
+		"txt.example.test.":      `TXT "v=spf1 -all" "semi;colon \"quoted\""`,
+		"_sip._udp.example.test": "SRV 0 5 5060 www.example.test.",
+		"a.b.dyn.example.test.":  "A 192.0.2.99",
+	} {
+		records, exists := zone.Lookup(name)
+		if !exists || data(records) != want {
+			t.Errorf("Lookup(%s) = %s, %v; want %s", name, data(records), exists, want)
+		}
+	}
+
+	records, _ := zone.Lookup("ns1.example.test.")
+	if len(records) != 1 || records[0].TTL != 60 {
+		t.Errorf("ns1 = %v", records)
+	}
+	records, _ = zone.Lookup("x.dyn.example.test.")
+	if len(records) != 1 || records[0].TTL != 3600 || records[0].Name != "x.dyn.example.test." {
+		t.Errorf("wildcard = %v", records)
+	}
+	if _, exists := zone.Lookup("_udp.example.test."); !exists {
+		t.Error("empty non-terminal does not exist")
+	}
+	if _, exists := zone.Lookup("missing.example.test."); exists {
+		t.Error("missing name exists")
+	}
+
+	for _, bad := range []string{"www A 300.1.1.1", "www MX mail", "www (A 192.0.2.1", "www BOGUS x"} {
+		if _, err := ParseZone(strings.NewReader(bad), "example.test."); err == nil {
+			t.Errorf("ParseZone(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestServerAnswers(t *testing.T) {
+	zone, err := ParseZone(strings.NewReader(testZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := serve(t, NewServer(zone))
+	client := &Client{Timeout: 2 * time.Second}
+
+	for _, tc := range []struct {
+		name       string
+		qtype      dnsmessage.Type
+		rcode      dnsmessage.RCode
+		answers    string
+		additional string
+	}{
+		{"www.example.test", dnsmessage.TypeA, dnsmessage.RCodeSuccess, "A 192.0.2.10", ""},
+		{"chain.example.test", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess, "CNAME alias.example.test., CNAME www.example.test., AAAA 2001:db8::10", ""},
+		{"example.test", dnsmessage.TypeMX, dnsmessage.RCodeSuccess, "MX 10 mail.example.test.", "A 192.0.2.25"},
+		{"_sip._udp.example.test", dnsmessage.TypeSRV, dnsmessage.RCodeSuccess, "SRV 0 5 5060 www.example.test.", "A 192.0.2.10, AAAA 2001:db8::10"},
+		{ReverseName(net.ParseIP("192.0.2.10")), dnsmessage.TypePTR, dnsmessage.RCodeSuccess, "PTR www.example.test.", ""},
+		{"host.dyn.example.test", dnsmessage.TypeA, dnsmessage.RCodeSuccess, "A 192.0.2.99", ""},
+		{"www.example.test", dnsmessage.TypeMX, dnsmessage.RCodeSuccess, "", ""},
+		{"missing.example.test", dnsmessage.TypeA, dnsmessage.RCodeNameError, "", ""},
+		{"www.other.test", dnsmessage.TypeA, dnsmessage.RCodeRefused, "", ""},
+	} {
+		resp := query(t, client, server, tc.name, tc.qtype, QueryOptions{UDPSize: DefaultUDPSize})
+		if resp.RCode != tc.rcode || data(Records(resp.Answers)) != tc.answers || data(Records(resp.Additionals)) != tc.additional {
+			t.Errorf("%s %s = %s [%s] [%s]; want %s [%s] [%s]", tc.name, TypeString(tc.qtype),
+				RCodeString(resp.RCode), data(Records(resp.Answers)), data(Records(resp.Additionals)),
+				RCodeString(tc.rcode), tc.answers, tc.additional)
+		}
+		if size, ok := EDNS(resp.Message); !ok || size != DefaultUDPSize {
+			t.Errorf("%s: EDNS = %d, %v", tc.name, size, ok)
+		}
+		// Negative answers carry the SOA
+		if tc.answers == "" && tc.rcode != dnsmessage.RCodeRefused && len(resp.Authorities) != 1 {
+			t.Errorf("%s: %d authority records", tc.name, len(resp.Authorities))
+		}
+	}
+}
+
+func TestTruncatedAnswerFallsBackToTCP(t *testing.T) {
+	zone := NewZone("big.test.")
+	for i := 0; i < 10; i++ {
+		zone.Add(Record{Name: "txt.big.test.", Type: dnsmessage.TypeTXT, TTL: 60, Data: `"` + strings.Repeat("x", 100) + `"`})
+	}
+	server := serve(t, NewServer(zone))
+	client := &Client{Timeout: 2 * time.Second}
+
+	// Without EDNS the answer does not fit in 512 bytes
+	resp := query(t, client, server, "txt.big.test", dnsmessage.TypeTXT, QueryOptions{})
+	if !resp.Fallback || resp.Network != "tcp" || len(resp.Answers) != 10 {
+		t.Errorf("fallback = %v over %s with %d answers", resp.Fallback, resp.Network, len(resp.Answers))
+	}
+
+	resp = query(t, client, server, "txt.big.test", dnsmessage.TypeTXT, QueryOptions{UDPSize: DefaultUDPSize})
+	if resp.Fallback || resp.Network != "udp" || len(resp.Answers) != 10 {
+		t.Errorf("with EDNS: fallback = %v over %s with %d answers", resp.Fallback, resp.Network, len(resp.Answers))
+	}
+}
+
+func TestYAMLZoneFaults(t *testing.T) {
+	zone, err := ParseZoneYAML([]byte(`
+origin: fault.test
+ttl: 30
+records:
+  - {type: A, data: 192.0.2.1}
+  - {name: slow, type: A, data: 192.0.2.2}
+  - {name: broken, type: A, data: 192.0.2.3}
+  - {name: gone, type: A, data: 192.0.2.4}
+  - {name: tcp, type: A, data: 192.0.2.5, ttl: 5}
+faults:
+  - {name: slow, delay: 200ms}
+  - {name: broken, rcode: SERVFAIL}
+  - {name: gone, drop: true}
+  - {name: tcp, truncate: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(zone)
+	dropped := make(chan string, 1)
+	srv.OnQuery = func(_ net.Addr, q dnsmessage.Question, _ string, rcode, _ int) {
+		if rcode < 0 {
+			dropped <- q.Name.String()
+		}
+	}
+	server := serve(t, srv)
+	client := &Client{Timeout: time.Second}
+
+	if resp := query(t, client, server, "fault.test", dnsmessage.TypeA, QueryOptions{}); data(Records(resp.Answers)) != "A 192.0.2.1" || resp.Answers[0].Header.TTL != 30 {
+		t.Errorf("apex = %v", Records(resp.Answers))
+	}
+	if resp := query(t, client, server, "slow.fault.test", dnsmessage.TypeA, QueryOptions{}); resp.RTT < 200*time.Millisecond {
+		t.Errorf("delayed answer came after %v", resp.RTT)
+	}
+	if resp := query(t, client, server, "broken.fault.test", dnsmessage.TypeA, QueryOptions{}); resp.RCode != dnsmessage.RCodeServerFailure {
+		t.Errorf("broken = %s", RCodeString(resp.RCode))
+	}
+	if resp := query(t, client, server, "tcp.fault.test", dnsmessage.TypeA, QueryOptions{}); !resp.Fallback || resp.Answers[0].Header.TTL != 5 {
+		t.Errorf("truncate fault: fallback = %v", resp.Fallback)
+	}
+
+	msg, _ := NewQuery("gone.fault.test", dnsmessage.TypeA, QueryOptions{})
+	client.Timeout = 200 * time.Millisecond
+	if resp, err := client.Exchange(context.Background(), msg, server); err == nil {
+		t.Errorf("dropped query answered: %v", resp.Header)
+	}
+	if name := <-dropped; name != "gone.fault.test." {
+		t.Errorf("dropped %s", name)
+	}
+
+	if _, err := ParseZoneYAML([]byte("faults: [{rcode: WHATEVER}]")); err == nil {
+		t.Error("unknown rcode accepted")
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	for _, r := range []Record{
+		{Name: "a.test.", Type: dnsmessage.TypeA, TTL: 1, Data: "192.0.2.1"},
+		{Name: "a.test.", Type: dnsmessage.TypeAAAA, TTL: 1, Data: "2001:db8::1"},
+		{Name: "a.test.", Type: dnsmessage.TypeCNAME, TTL: 1, Data: "b.test."},
+		{Name: "a.test.", Type: dnsmessage.TypeMX, TTL: 1, Data: "10 mx.test."},
+		{Name: "a.test.", Type: dnsmessage.TypeSRV, TTL: 1, Data: "1 2 3 srv.test."},
+		{Name: "a.test.", Type: dnsmessage.TypeTXT, TTL: 1, Data: `"tab\009" "é"`},
+		{Name: "a.test.", Type: 65280, TTL: 1, Data: `\# 2 beef`},
+	} {
+		res, err := r.Resource()
+		if err != nil {
+			t.Errorf("%v: %v", r, err)
+			continue
+		}
+		// The wire format of the record is what the TXT escapes stand for
+		msg := dnsmessage.Message{Answers: []dnsmessage.Resource{res}}
+		packed, err := msg.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var unpacked dnsmessage.Message
+		if err := unpacked.Unpack(packed); err != nil {
+			t.Fatal(err)
+		}
+		want := r.Data
+		if r.Type == dnsmessage.TypeTXT {
+			want = `"tab\009" "\195\169"`
+		}
+		if got := RecordFromResource(unpacked.Answers[0]); got.Data != want {
+			t.Errorf("%s round trip = %s, want %s", TypeString(r.Type), got.Data, want)
+		}
+	}
+
+	if name := ReverseName(net.ParseIP("2001:db8::1")); !strings.HasPrefix(name, "1.0.0.0.") || !strings.HasSuffix(name, ".8.b.d.0.1.0.0.2.ip6.arpa.") {
+		t.Errorf("ReverseName = %s", name)
+	}
+}
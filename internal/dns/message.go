@@ -0,0 +1,112 @@
+package dns
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DefaultUDPSize is the EDNS0 payload size queries and responses
+// advertise, which avoids IP fragmentation on common paths
+const DefaultUDPSize = 1232
+
+// minUDPSize is what a UDP message can carry without EDNS0
+const minUDPSize = 512
+
+// QueryOptions control the query NewQuery builds
+type QueryOptions struct {
+	// UDPSize is the EDNS0 payload size advertised; 0 sends no OPT record
+	UDPSize uint16
+	// NoRecurse clears the recursion desired flag
+	NoRecurse bool
+}
+
+// NewQuery builds a query for the records of type qtype of name
+func NewQuery(name string, qtype dnsmessage.Type, opts QueryOptions) (dnsmessage.Message, error) {
+	qname, err := newName(name)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: !opts.NoRecurse},
+		Questions: []dnsmessage.Question{{
+			Name:  qname,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	if opts.UDPSize > 0 {
+		msg.Additionals = append(msg.Additionals, optRecord(opts.UDPSize))
+	}
+	return msg, nil
+}
+
+// NewReply starts the response to a query: the same ID, opcode, question
+// and recursion desired flag, with the response flag set
+func NewReply(query dnsmessage.Message) dnsmessage.Message {
+	return dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               query.ID,
+			Response:         true,
+			OpCode:           query.OpCode,
+			RecursionDesired: query.RecursionDesired,
+		},
+		Questions: query.Questions,
+	}
+}
+
+// optRecord is the OPT pseudo-record advertising an EDNS0 payload size
+func optRecord(udpSize uint16) dnsmessage.Resource {
+	var header dnsmessage.ResourceHeader
+	header.SetEDNS0(int(udpSize), dnsmessage.RCodeSuccess, false)
+	return dnsmessage.Resource{Header: header, Body: &dnsmessage.OPTResource{}}
+}
+
+// EDNS returns the payload size advertised by the OPT record of msg, and
+// whether it has one
+func EDNS(msg dnsmessage.Message) (uint16, bool) {
+	for _, res := range msg.Additionals {
+		if res.Header.Type == dnsmessage.TypeOPT {
+			return uint16(res.Header.Class), true
+		}
+	}
+	return 0, false
+}
+
+// Records converts a section of a message to presentation form, leaving
+// out the OPT pseudo-record
+func Records(section []dnsmessage.Resource) []Record {
+	records := make([]Record, 0, len(section))
+	for _, res := range section {
+		if res.Header.Type != dnsmessage.TypeOPT {
+			records = append(records, RecordFromResource(res))
+		}
+	}
+	return records
+}
+
+// AppendRecords adds records to a section of a message
+func AppendRecords(section []dnsmessage.Resource, records ...Record) ([]dnsmessage.Resource, error) {
+	for _, r := range records {
+		res, err := r.Resource()
+		if err != nil {
+			return section, err
+		}
+		section = append(section, res)
+	}
+	return section, nil
+}
+
+// Unpack parses a message, checking that it has a single question
+func Unpack(packet []byte) (dnsmessage.Message, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packet); err != nil {
+		return msg, fmt.Errorf("malformed DNS message: %w", err)
+	}
+	if len(msg.Questions) != 1 {
+		return msg, fmt.Errorf("DNS message has %d questions, want 1", len(msg.Questions))
+	}
+	return msg, nil
+}
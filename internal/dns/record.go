@@ -0,0 +1,376 @@
+// Package dns encodes and decodes DNS messages, sends queries to servers
+// and answers them authoritatively from a zone, on top of the wire format
+// of golang.org/x/net/dns/dnsmessage.
+//
+// Records are kept in presentation form, the way zone files write them,
+// and converted to and from the wire format at the edges.
+package dns
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// TypeANY asks a server for all the records of a name
+const TypeANY = dnsmessage.TypeALL
+
+var typeNames = map[dnsmessage.Type]string{
+	dnsmessage.TypeA:     "A",
+	dnsmessage.TypeNS:    "NS",
+	dnsmessage.TypeCNAME: "CNAME",
+	dnsmessage.TypeSOA:   "SOA",
+	dnsmessage.TypePTR:   "PTR",
+	dnsmessage.TypeMX:    "MX",
+	dnsmessage.TypeTXT:   "TXT",
+	dnsmessage.TypeAAAA:  "AAAA",
+	dnsmessage.TypeSRV:   "SRV",
+	dnsmessage.TypeOPT:   "OPT",
+	TypeANY:              "ANY",
+}
+
+// TypeString names a record type as zone files do, or TYPEn for types
+// without a name (RFC 3597)
+func TypeString(t dnsmessage.Type) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+// ParseType parses a record type name such as AAAA, or TYPEn
+func ParseType(s string) (dnsmessage.Type, error) {
+	s = strings.ToUpper(s)
+	for t, name := range typeNames {
+		if name == s && t != dnsmessage.TypeOPT {
+			return t, nil
+		}
+	}
+	if n, ok := strings.CutPrefix(s, "TYPE"); ok {
+		if v, err := strconv.ParseUint(n, 10, 16); err == nil {
+			return dnsmessage.Type(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown record type %q", s)
+}
+
+// RCodeString names a response code as dig shows it
+func RCodeString(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// ParseRCode parses a response code name such as SERVFAIL
+func ParseRCode(s string) (dnsmessage.RCode, error) {
+	for rcode := dnsmessage.RCodeSuccess; rcode <= dnsmessage.RCodeRefused; rcode++ {
+		if RCodeString(rcode) == strings.ToUpper(s) {
+			return rcode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown response code %q", s)
+}
+
+// Record is a resource record in presentation form
+type Record struct {
+	// Name is fully qualified, with its trailing dot
+	Name string
+	Type dnsmessage.Type
+	TTL  uint32
+	// Data is the RDATA as a zone file writes it: an address, a name,
+	// "10 mail.example." for MX, "0 5 5060 sip.example." for SRV, quoted
+	// strings for TXT, or \# length hex for other types
+	Data string
+}
+
+// String formats the record as a zone file line
+func (r Record) String() string {
+	return fmt.Sprintf("%s\t%d\tIN\t%s\t%s", r.Name, r.TTL, TypeString(r.Type), r.Data)
+}
+
+// Fqdn adds the trailing dot to a name that lacks it
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name PTR records of ip
+// live under
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	digits := hex.EncodeToString(ip.To16())
+	var b strings.Builder
+	for i := len(digits) - 1; i >= 0; i-- {
+		b.WriteByte(digits[i])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// newName parses a domain name for the wire format
+func newName(name string) (dnsmessage.Name, error) {
+	n, err := dnsmessage.NewName(Fqdn(name))
+	if err != nil {
+		return n, fmt.Errorf("invalid name %q: %w", name, err)
+	}
+	return n, nil
+}
+
+// Resource converts the record to the wire format
+func (r Record) Resource() (dnsmessage.Resource, error) {
+	name, err := newName(r.Name)
+	if err != nil {
+		return dnsmessage.Resource{}, err
+	}
+	header := dnsmessage.ResourceHeader{Name: name, Type: r.Type, Class: dnsmessage.ClassINET, TTL: r.TTL}
+
+	body, err := r.body()
+	if err != nil {
+		return dnsmessage.Resource{}, fmt.Errorf("%s %s record: %w", r.Name, TypeString(r.Type), err)
+	}
+	return dnsmessage.Resource{Header: header, Body: body}, nil
+}
+
+func (r Record) body() (dnsmessage.ResourceBody, error) {
+	fields, err := splitFields(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	want := map[dnsmessage.Type]int{
+		dnsmessage.TypeA: 1, dnsmessage.TypeAAAA: 1, dnsmessage.TypeNS: 1,
+		dnsmessage.TypeCNAME: 1, dnsmessage.TypePTR: 1, dnsmessage.TypeMX: 2,
+		dnsmessage.TypeSRV: 4, dnsmessage.TypeSOA: 7,
+	}
+	if n, ok := want[r.Type]; ok && len(fields) != n {
+		return nil, fmt.Errorf("want %d fields, got %q", n, r.Data)
+	}
+
+	switch r.Type {
+	case dnsmessage.TypeA:
+		ip := net.ParseIP(fields[0]).To4()
+		if ip == nil || strings.Contains(fields[0], ":") {
+			return nil, fmt.Errorf("invalid IPv4 address %q", fields[0])
+		}
+		return &dnsmessage.AResource{A: [4]byte(ip)}, nil
+	case dnsmessage.TypeAAAA:
+		ip := net.ParseIP(fields[0])
+		if ip == nil || !strings.Contains(fields[0], ":") {
+			return nil, fmt.Errorf("invalid IPv6 address %q", fields[0])
+		}
+		return &dnsmessage.AAAAResource{AAAA: [16]byte(ip.To16())}, nil
+	case dnsmessage.TypeNS, dnsmessage.TypeCNAME, dnsmessage.TypePTR:
+		target, err := newName(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		switch r.Type {
+		case dnsmessage.TypeNS:
+			return &dnsmessage.NSResource{NS: target}, nil
+		case dnsmessage.TypeCNAME:
+			return &dnsmessage.CNAMEResource{CNAME: target}, nil
+		}
+		return &dnsmessage.PTRResource{PTR: target}, nil
+	case dnsmessage.TypeMX:
+		pref, err := parseUint16(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		mx, err := newName(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		return &dnsmessage.MXResource{Pref: pref, MX: mx}, nil
+	case dnsmessage.TypeSRV:
+		var nums [3]uint16
+		for i := range nums {
+			if nums[i], err = parseUint16(fields[i]); err != nil {
+				return nil, err
+			}
+		}
+		target, err := newName(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		return &dnsmessage.SRVResource{Priority: nums[0], Weight: nums[1], Port: nums[2], Target: target}, nil
+	case dnsmessage.TypeSOA:
+		ns, err := newName(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		mbox, err := newName(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		var nums [5]uint32
+		for i := range nums {
+			v, err := strconv.ParseUint(fields[2+i], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", fields[2+i])
+			}
+			nums[i] = uint32(v)
+		}
+		return &dnsmessage.SOAResource{NS: ns, MBox: mbox, Serial: nums[0], Refresh: nums[1], Retry: nums[2], Expire: nums[3], MinTTL: nums[4]}, nil
+	case dnsmessage.TypeTXT:
+		var txt []string
+		for _, field := range fields {
+			// Strings longer than a TXT string can hold are split
+			for len(field) > 255 {
+				txt = append(txt, field[:255])
+				field = field[255:]
+			}
+			txt = append(txt, field)
+		}
+		if len(txt) == 0 {
+			txt = []string{""}
+		}
+		return &dnsmessage.TXTResource{TXT: txt}, nil
+	}
+
+	if len(fields) < 2 || fields[0] != `\#` {
+		return nil, fmt.Errorf(`unsupported type, write its data as \# length hex`)
+	}
+	data, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil || strconv.Itoa(len(data)) != fields[1] {
+		return nil, fmt.Errorf("invalid generic data %q", r.Data)
+	}
+	return &dnsmessage.UnknownResource{Type: r.Type, Data: data}, nil
+}
+
+func parseUint16(s string) (uint16, error) {
+	v, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return uint16(v), nil
+}
+
+// RecordFromResource converts a record from the wire format
+func RecordFromResource(res dnsmessage.Resource) Record {
+	r := Record{Name: res.Header.Name.String(), Type: res.Header.Type, TTL: res.Header.TTL}
+
+	switch body := res.Body.(type) {
+	case *dnsmessage.AResource:
+		r.Data = net.IP(body.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		r.Data = net.IP(body.AAAA[:]).String()
+	case *dnsmessage.NSResource:
+		r.Data = body.NS.String()
+	case *dnsmessage.CNAMEResource:
+		r.Data = body.CNAME.String()
+	case *dnsmessage.PTRResource:
+		r.Data = body.PTR.String()
+	case *dnsmessage.MXResource:
+		r.Data = fmt.Sprintf("%d %s", body.Pref, body.MX)
+	case *dnsmessage.SRVResource:
+		r.Data = fmt.Sprintf("%d %d %d %s", body.Priority, body.Weight, body.Port, body.Target)
+	case *dnsmessage.SOAResource:
+		r.Data = fmt.Sprintf("%s %s %d %d %d %d %d", body.NS, body.MBox, body.Serial, body.Refresh, body.Retry, body.Expire, body.MinTTL)
+	case *dnsmessage.TXTResource:
+		quoted := make([]string, len(body.TXT))
+		for i, s := range body.TXT {
+			quoted[i] = quoteTXT(s)
+		}
+		r.Data = strings.Join(quoted, " ")
+	case *dnsmessage.UnknownResource:
+		r.Data = fmt.Sprintf(`\# %d %x`, len(body.Data), body.Data)
+	case *dnsmessage.OPTResource:
+		r.Data = fmt.Sprintf("udp=%d", res.Header.Class)
+	}
+	return r
+}
+
+// quoteTXT quotes a TXT string, escaping quotes, backslashes and bytes
+// that are not printable ASCII as zone files do
+func quoteTXT(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// splitFields splits presentation data at whitespace, keeping quoted
+// strings, with their escapes resolved, as single fields
+func splitFields(s string) ([]string, error) {
+	var fields []string
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case ' ', '\t':
+			i++
+			continue
+		case '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(s) {
+					return nil, fmt.Errorf("unterminated string in %q", s)
+				}
+				c := s[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(s) {
+					if i+3 < len(s) && isDigits(s[i+1:i+4]) {
+						v, _ := strconv.Atoi(s[i+1 : i+4])
+						b.WriteByte(byte(v))
+						i += 4
+						continue
+					}
+					c = s[i+1]
+					i++
+				}
+				b.WriteByte(c)
+				i++
+			}
+			fields = append(fields, b.String())
+		default:
+			start := i
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+				i++
+			}
+			fields = append(fields, s[start:i])
+		}
+	}
+	return fields, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
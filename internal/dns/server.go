@@ -0,0 +1,266 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxCNAMEChain bounds how many CNAMEs a response follows inside the zone
+const maxCNAMEChain = 8
+
+// tcpIdleTimeout closes TCP clients that stop sending queries
+const tcpIdleTimeout = 10 * time.Second
+
+// Server answers queries authoritatively from a zone over UDP and TCP
+type Server struct {
+	// zone is read on every query, so SetZone can replace it while serving
+	zone *Zone
+	mu   sync.RWMutex
+
+	// OnQuery, when set, is called for each query after it is answered;
+	// rcode is -1 when the query was dropped
+	OnQuery func(peer net.Addr, q dnsmessage.Question, network string, rcode int, answers int)
+}
+
+// NewServer returns a server answering from zone
+func NewServer(zone *Zone) *Server {
+	return &Server{zone: zone}
+}
+
+// SetZone replaces the zone the server answers from
+func (s *Server) SetZone(zone *Zone) {
+	s.mu.Lock()
+	s.zone = zone
+	s.mu.Unlock()
+}
+
+func (s *Server) currentZone() *Zone {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zone
+}
+
+// ServeUDP answers the datagrams arriving on conn until it is closed
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, 65535)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		packet := append([]byte(nil), buf[:n]...)
+
+		// Each query has its own goroutine so delayed answers do not hold
+		// up the others
+		go func() {
+			if resp := s.answer(packet, peer, "udp"); resp != nil {
+				conn.WriteTo(resp, peer)
+			}
+		}()
+	}
+}
+
+// ServeTCP answers the queries of the clients ln accepts until it is closed
+func (s *Server) ServeTCP(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveStream(conn)
+	}
+}
+
+func (s *Server) serveStream(conn net.Conn) {
+	defer conn.Close()
+	var writeMu sync.Mutex
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+		packet, err := ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		// Queries on one connection may be answered out of order
+		go func() {
+			if resp := s.answer(packet, conn.RemoteAddr(), "tcp"); resp != nil {
+				writeMu.Lock()
+				conn.Write(Frame(resp))
+				writeMu.Unlock()
+			}
+		}()
+	}
+}
+
+// answer returns the packed response to a query, or nil when it goes
+// unanswered
+func (s *Server) answer(packet []byte, peer net.Addr, network string) []byte {
+	query, err := Unpack(packet)
+	if err != nil {
+		// Without a header there is nobody to answer
+		if len(packet) < 12 {
+			return nil
+		}
+		var p dnsmessage.Parser
+		header, err := p.Start(packet)
+		if err != nil || header.Response {
+			return nil
+		}
+		query = dnsmessage.Message{Header: header}
+		resp := NewReply(query)
+		resp.RCode = dnsmessage.RCodeFormatError
+		packed, _ := resp.Pack()
+		return packed
+	}
+	if query.Response {
+		return nil
+	}
+
+	zone := s.currentZone()
+	q := query.Questions[0]
+	fault := zone.fault(q.Name.String())
+	if fault.Delay > 0 {
+		time.Sleep(fault.Delay)
+	}
+	if fault.Drop {
+		s.report(peer, q, network, -1, 0)
+		return nil
+	}
+
+	resp := zone.respond(query)
+	if fault.RCode != dnsmessage.RCodeSuccess {
+		resp.RCode = fault.RCode
+		resp.Answers, resp.Authorities = nil, nil
+	}
+
+	limit := 65535
+	udpSize, hasEDNS := EDNS(query)
+	if hasEDNS {
+		resp.Additionals = append(resp.Additionals, optRecord(DefaultUDPSize))
+	}
+	if network == "udp" {
+		limit = max(minUDPSize, min(int(udpSize), DefaultUDPSize))
+	}
+
+	packed, err := resp.Pack()
+	if err != nil {
+		resp = NewReply(query)
+		resp.RCode = dnsmessage.RCodeServerFailure
+		packed, _ = resp.Pack()
+	}
+	if network == "udp" && (len(packed) > limit || fault.Truncate) {
+		resp.Truncated = true
+		resp.Answers, resp.Authorities = nil, nil
+		resp.Additionals = nil
+		if hasEDNS {
+			resp.Additionals = []dnsmessage.Resource{optRecord(DefaultUDPSize)}
+		}
+		packed, _ = resp.Pack()
+	}
+
+	s.report(peer, q, network, int(resp.RCode), len(resp.Answers))
+	return packed
+}
+
+func (s *Server) report(peer net.Addr, q dnsmessage.Question, network string, rcode, answers int) {
+	if s.OnQuery != nil {
+		s.OnQuery(peer, q, network, rcode, answers)
+	}
+}
+
+// respond builds the authoritative response of the zone to a query
+func (z *Zone) respond(query dnsmessage.Message) dnsmessage.Message {
+	resp := NewReply(query)
+	q := query.Questions[0]
+
+	switch {
+	case query.OpCode != 0:
+		resp.RCode = dnsmessage.RCodeNotImplemented
+		return resp
+	case q.Class != dnsmessage.ClassINET && q.Class != dnsmessage.ClassANY:
+		resp.RCode = dnsmessage.RCodeRefused
+		return resp
+	case !z.Contains(q.Name.String()):
+		resp.RCode = dnsmessage.RCodeRefused
+		return resp
+	}
+	resp.Authoritative = true
+
+	var answers []Record
+	name := q.Name.String()
+	for hops := 0; ; hops++ {
+		records, exists := z.Lookup(name)
+		if !exists {
+			// A CNAME into a missing name still answers with the CNAME
+			if hops == 0 {
+				resp.RCode = dnsmessage.RCodeNameError
+			}
+			break
+		}
+
+		matched := false
+		var cname *Record
+		for i, r := range records {
+			if r.Type == q.Type || q.Type == TypeANY {
+				answers = append(answers, r)
+				matched = true
+			} else if r.Type == dnsmessage.TypeCNAME {
+				cname = &records[i]
+			}
+		}
+		if matched || cname == nil || hops == maxCNAMEChain {
+			break
+		}
+		answers = append(answers, *cname)
+		name = strings.TrimSpace(cname.Data)
+		if !z.Contains(name) {
+			break
+		}
+	}
+
+	resp.Answers, _ = AppendRecords(nil, answers...)
+	if len(answers) == 0 {
+		// Negative answers carry the SOA for negative caching
+		resp.Authorities, _ = AppendRecords(nil, z.soa(q.Name.String())...)
+	}
+	resp.Additionals, _ = AppendRecords(nil, z.glue(answers)...)
+	return resp
+}
+
+// glue returns the addresses in the zone of the names NS, MX and SRV
+// answers point at
+func (z *Zone) glue(answers []Record) []Record {
+	var glue []Record
+	for _, r := range answers {
+		var target string
+		fields, _ := splitFields(r.Data)
+		switch r.Type {
+		case dnsmessage.TypeNS:
+			target = fields[0]
+		case dnsmessage.TypeMX:
+			target = fields[1]
+		case dnsmessage.TypeSRV:
+			target = fields[3]
+		default:
+			continue
+		}
+		records, _ := z.Lookup(target)
+		for _, g := range records {
+			if g.Type == dnsmessage.TypeA || g.Type == dnsmessage.TypeAAAA {
+				glue = append(glue, g)
+			}
+		}
+	}
+	return glue
+}
@@ -0,0 +1,500 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTTL is the TTL of records that name none, in zones without $TTL
+const DefaultTTL = 3600
+
+// Zone holds the records a Server answers with and the faults it injects
+type Zone struct {
+	// Origin is the apex of the zone, fully qualified. A server refuses
+	// names outside it and the other origins added with AddOrigin; the
+	// root, or empty, covers every name.
+	Origin string
+	// Faults apply to the queries they match, combined when several do
+	Faults []Fault
+
+	origins []string            // lowercased, Origin first
+	records map[string][]Record // by lowercased owner
+	names   map[string]bool     // owners and the names above them
+}
+
+// Fault makes a server misbehave for the queries it matches
+type Fault struct {
+	// Name matches queries as owners match them, wildcards included; empty
+	// matches every query
+	Name string
+	// Rate is the fraction of matching queries affected; 0 means all
+	Rate float64
+	// Delay holds the response back
+	Delay time.Duration
+	// RCode answers with this code instead of the records when not NOERROR
+	RCode dnsmessage.RCode
+	// Drop never answers
+	Drop bool
+	// Truncate answers over UDP with the truncated flag and no records,
+	// sending clients to TCP
+	Truncate bool
+}
+
+// NewZone returns an empty zone with the given origin
+func NewZone(origin string) *Zone {
+	z := &Zone{
+		records: make(map[string][]Record),
+		names:   make(map[string]bool),
+	}
+	if origin != "" {
+		z.AddOrigin(origin)
+	}
+	return z
+}
+
+// AddOrigin makes the zone answer for the names at or below origin too
+func (z *Zone) AddOrigin(origin string) {
+	origin = Fqdn(origin)
+	if z.Origin == "" {
+		z.Origin = origin
+	}
+	z.origins = append(z.origins, strings.ToLower(origin))
+}
+
+// Add adds records to the zone
+func (z *Zone) Add(records ...Record) {
+	for _, r := range records {
+		r.Name = Fqdn(r.Name)
+		owner := strings.ToLower(r.Name)
+		z.records[owner] = append(z.records[owner], r)
+
+		for name := owner; ; {
+			z.names[name] = true
+			_, parent, ok := strings.Cut(name, ".")
+			if !ok || parent == "" || !z.Contains(parent) {
+				break
+			}
+			name = parent
+		}
+	}
+}
+
+// Len returns the number of records in the zone
+func (z *Zone) Len() int {
+	n := 0
+	for _, records := range z.records {
+		n += len(records)
+	}
+	return n
+}
+
+// Contains reports whether name is at or below an origin of the zone
+func (z *Zone) Contains(name string) bool {
+	return len(z.origins) == 0 || z.originOf(name) != ""
+}
+
+// originOf returns the closest origin above name, or "" outside the zone
+func (z *Zone) originOf(name string) string {
+	name = strings.ToLower(Fqdn(name))
+	closest := ""
+	for _, origin := range z.origins {
+		if origin == "." || name == origin || strings.HasSuffix(name, "."+origin) {
+			if len(origin) > len(closest) {
+				closest = origin
+			}
+		}
+	}
+	return closest
+}
+
+// Lookup returns the records of name, synthesizing them from the closest
+// wildcard when the name has none. exists is false when neither the name
+// nor a wildcard covers it, so it does not exist.
+func (z *Zone) Lookup(name string) (records []Record, exists bool) {
+	name = Fqdn(name)
+	owner := strings.ToLower(name)
+	if z.names[owner] {
+		return z.records[owner], true
+	}
+
+	// The wildcard that applies sits at the closest existing ancestor
+	encloser := owner
+	for {
+		_, parent, ok := strings.Cut(encloser, ".")
+		if !ok || parent == "" {
+			encloser = "."
+			break
+		}
+		encloser = parent
+		if z.names[encloser] || !z.Contains(encloser) {
+			break
+		}
+	}
+	wildcard := "*." + encloser
+	if encloser == "." {
+		wildcard = "*."
+	}
+	if !z.names[wildcard] {
+		return nil, false
+	}
+	for _, r := range z.records[wildcard] {
+		r.Name = name
+		records = append(records, r)
+	}
+	return records, true
+}
+
+// soa returns the SOA record of the origin of name, if it has one
+func (z *Zone) soa(name string) []Record {
+	for _, r := range z.records[z.originOf(name)] {
+		if r.Type == dnsmessage.TypeSOA {
+			return []Record{r}
+		}
+	}
+	return nil
+}
+
+// fault combines the faults that apply to a query for name: their delays
+// add up and the first response code wins
+func (z *Zone) fault(name string) Fault {
+	name = strings.ToLower(Fqdn(name))
+	var combined Fault
+	for _, f := range z.Faults {
+		if f.Name != "" && !matchName(strings.ToLower(Fqdn(f.Name)), name) {
+			continue
+		}
+		if f.Rate > 0 && rand.Float64() >= f.Rate {
+			continue
+		}
+		combined.Delay += f.Delay
+		if combined.RCode == dnsmessage.RCodeSuccess {
+			combined.RCode = f.RCode
+		}
+		combined.Drop = combined.Drop || f.Drop
+		combined.Truncate = combined.Truncate || f.Truncate
+	}
+	return combined
+}
+
+// matchName reports whether pattern, a name or a wildcard, covers name
+func matchName(pattern, name string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(name, "."+suffix) || (suffix == "" && name != ".")
+	}
+	return pattern == name
+}
+
+// LoadZone reads a zone from a file: YAML when its extension is .yaml or
+// .yml, a zone file otherwise
+func LoadZone(path string) (*Zone, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseZoneYAML(data)
+	}
+	return ParseZone(bytes.NewReader(data), "")
+}
+
+// ParseZone parses a zone file (RFC 1035 section 5) with $ORIGIN and $TTL
+// directives, relative names, parentheses and comments. origin applies
+// until an $ORIGIN directive.
+func ParseZone(r io.Reader, origin string) (*Zone, error) {
+	zone := NewZone(origin)
+	ttl := uint32(DefaultTTL)
+	var owner string
+
+	lines, err := logicalLines(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("line %d: %s", line.number, fmt.Sprintf(format, args...))
+		}
+
+		tok, rest := nextToken(line.text)
+		switch strings.ToUpper(tok) {
+		case "":
+			continue
+		case "$ORIGIN":
+			name, _ := nextToken(rest)
+			if name == "" {
+				return nil, fail("$ORIGIN without a name")
+			}
+			origin = qualify(name, origin)
+			zone.AddOrigin(origin)
+			continue
+		case "$TTL":
+			value, _ := nextToken(rest)
+			if ttl, err = parseTTL(value); err != nil {
+				return nil, fail("%v", err)
+			}
+			continue
+		}
+
+		// A line starting with blanks belongs to the previous owner
+		if line.text[0] == ' ' || line.text[0] == '\t' {
+			if owner == "" {
+				return nil, fail("record without an owner")
+			}
+			rest = line.text
+		} else {
+			owner = qualify(tok, origin)
+		}
+
+		record := Record{Name: owner, TTL: ttl}
+		for {
+			tok, rest = nextToken(rest)
+			if strings.EqualFold(tok, "IN") {
+				continue
+			}
+			if value, err := parseTTL(tok); err == nil {
+				record.TTL = value
+				continue
+			}
+			break
+		}
+		if record.Type, err = ParseType(tok); err != nil || record.Type == TypeANY {
+			return nil, fail("unknown record type %q", tok)
+		}
+		if record.Data, err = qualifyData(record.Type, strings.TrimSpace(rest), origin); err != nil {
+			return nil, fail("%v", err)
+		}
+		if _, err := record.Resource(); err != nil {
+			return nil, fail("%v", err)
+		}
+		zone.Add(record)
+	}
+	return zone, nil
+}
+
+// zoneLine is a logical line of a zone file, joined across parentheses
+// and stripped of comments
+type zoneLine struct {
+	number int
+	text   string
+}
+
+func logicalLines(r io.Reader) ([]zoneLine, error) {
+	var lines []zoneLine
+	var current strings.Builder
+	depth, start := 0, 0
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := scanner.Text()
+		if depth == 0 {
+			current.Reset()
+			start = number
+		} else {
+			current.WriteByte(' ')
+		}
+
+		quoted := false
+	scan:
+		for i := 0; i < len(text); i++ {
+			switch c := text[i]; {
+			case c == '\\' && i+1 < len(text):
+				current.WriteByte(c)
+				i++
+				current.WriteByte(text[i])
+				continue
+			case c == '"':
+				quoted = !quoted
+			case quoted:
+			case c == ';':
+				break scan
+			case c == '(':
+				depth++
+				current.WriteByte(' ')
+				continue
+			case c == ')':
+				if depth == 0 {
+					return nil, fmt.Errorf("line %d: unbalanced parenthesis", number)
+				}
+				depth--
+				current.WriteByte(' ')
+				continue
+			}
+			current.WriteByte(text[i])
+		}
+
+		if depth == 0 && strings.TrimSpace(current.String()) != "" {
+			lines = append(lines, zoneLine{number: start, text: strings.TrimRight(current.String(), " \t")})
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unclosed parenthesis", start)
+	}
+	return lines, scanner.Err()
+}
+
+// nextToken splits the first blank-separated token off s
+func nextToken(s string) (string, string) {
+	s = strings.TrimLeft(s, " \t")
+	end := strings.IndexAny(s, " \t")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// parseTTL parses a TTL in seconds, or with units as in 1h30m
+func parseTTL(s string) (uint32, error) {
+	if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(v), nil
+	}
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		if d, err := time.ParseDuration(strings.ToLower(s)); err == nil && d >= 0 {
+			return uint32(d / time.Second), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid TTL %q", s)
+}
+
+// qualify makes a relative name absolute under origin, where @ stands for
+// the origin itself
+func qualify(name, origin string) string {
+	if name == "@" {
+		if origin == "" {
+			return "."
+		}
+		return origin
+	}
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	if origin == "" || origin == "." {
+		return name + "."
+	}
+	return name + "." + origin
+}
+
+// qualifyData makes the names in the data of a record absolute
+func qualifyData(t dnsmessage.Type, data, origin string) (string, error) {
+	positions := map[dnsmessage.Type][]int{
+		dnsmessage.TypeNS:    {0},
+		dnsmessage.TypeCNAME: {0},
+		dnsmessage.TypePTR:   {0},
+		dnsmessage.TypeMX:    {1},
+		dnsmessage.TypeSRV:   {3},
+		dnsmessage.TypeSOA:   {0, 1},
+	}[t]
+	if len(positions) == 0 {
+		return data, nil
+	}
+
+	fields, err := splitFields(data)
+	if err != nil {
+		return "", err
+	}
+	for _, i := range positions {
+		if i < len(fields) {
+			fields[i] = qualify(fields[i], origin)
+		}
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// yamlZone is the YAML form of a zone
+type yamlZone struct {
+	Origin  string       `yaml:"origin"`
+	TTL     uint32       `yaml:"ttl"`
+	Records []yamlRecord `yaml:"records"`
+	Faults  []yamlFault  `yaml:"faults"`
+}
+
+type yamlRecord struct {
+	Name string  `yaml:"name"`
+	Type string  `yaml:"type"`
+	TTL  *uint32 `yaml:"ttl"`
+	Data string  `yaml:"data"`
+}
+
+type yamlFault struct {
+	Name     string        `yaml:"name"`
+	Rate     float64       `yaml:"rate"`
+	Delay    time.Duration `yaml:"delay"`
+	RCode    string        `yaml:"rcode"`
+	Drop     bool          `yaml:"drop"`
+	Truncate bool          `yaml:"truncate"`
+}
+
+// ParseZoneYAML parses a zone written in YAML:
+//
+//	origin: example.test.
+//	ttl: 300
+//	records:
+//	  - {name: www, type: A, data: 192.0.2.10}
+//	  - {name: "*.dyn", type: TXT, data: '"any name"', ttl: 5}
+//	faults:
+//	  - {name: slow, delay: 2s}
+//	  - {name: flaky, rcode: SERVFAIL, rate: 0.5}
+//
+// Names are relative to the origin unless they end with a dot.
+func ParseZoneYAML(data []byte) (*Zone, error) {
+	var y yamlZone
+	if err := yaml.Unmarshal(data, &y); err != nil {
+		return nil, fmt.Errorf("invalid zone: %w", err)
+	}
+	if y.TTL == 0 {
+		y.TTL = DefaultTTL
+	}
+	origin := ""
+	if y.Origin != "" {
+		origin = Fqdn(y.Origin)
+	}
+	zone := NewZone(origin)
+
+	for i, yr := range y.Records {
+		t, err := ParseType(yr.Type)
+		if err != nil || t == TypeANY {
+			return nil, fmt.Errorf("record %d: unknown record type %q", i+1, yr.Type)
+		}
+		name := yr.Name
+		if name == "" {
+			name = "@"
+		}
+		record := Record{Name: qualify(name, origin), Type: t, TTL: y.TTL}
+		if yr.TTL != nil {
+			record.TTL = *yr.TTL
+		}
+		if record.Data, err = qualifyData(t, yr.Data, origin); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		if _, err := record.Resource(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		zone.Add(record)
+	}
+
+	for i, yf := range y.Faults {
+		fault := Fault{Rate: yf.Rate, Delay: yf.Delay, Drop: yf.Drop, Truncate: yf.Truncate}
+		if yf.Name != "" {
+			fault.Name = qualify(yf.Name, origin)
+		}
+		if yf.RCode != "" {
+			rcode, err := ParseRCode(yf.RCode)
+			if err != nil {
+				return nil, fmt.Errorf("fault %d: %w", i+1, err)
+			}
+			fault.RCode = rcode
+		}
+		zone.Faults = append(zone.Faults, fault)
+	}
+	return zone, nil
+}
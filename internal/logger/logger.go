@@ -353,6 +353,29 @@ func SetLevel(level LogLevel) {
 	defaultLogger.level = level
 }
 
+// GetLevel returns the log level of the default logger
+func GetLevel() LogLevel {
+	defaultLogger.mu.RLock()
+	defer defaultLogger.mu.RUnlock()
+	return defaultLogger.level
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a LogLevel
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("invalid log level '%s'", name)
+	}
+}
+
 // SetStructured enables structured logging for the default logger
 func SetStructured(structured bool) {
 	defaultLogger.SetStructured(structured)
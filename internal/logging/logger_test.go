@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -408,7 +409,7 @@ func TestGlobalLogger(t *testing.T) {
 
 func TestLogRotator(t *testing.T) {
 	// Create a temporary file for testing
-	rotator := NewLogRotator("test.log", 100, 7, 3, false)
+	rotator := NewLogRotator(filepath.Join(t.TempDir(), "test.log"), 100, 7, 3, false)
 
 	// Write some data
 	data := []byte("test log entry\n")
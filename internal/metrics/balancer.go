@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"github.com/ibrahmsql/gocat/internal/balancer"
+)
+
+// boolGauge is 1 for true and 0 for false
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// RecordBalancerStats records the backends of a load balancer as gauges
+// labelled with their address
+func (pm *PrometheusMetrics) RecordBalancerStats(backends []balancer.BackendStats) {
+	for _, backend := range backends {
+		tags := map[string]string{"backend": backend.Address}
+		pm.RecordGauge("lb_backend_healthy", boolGauge(backend.Healthy), tags)
+		pm.RecordGauge("lb_backend_draining", boolGauge(backend.Draining), tags)
+		pm.RecordGauge("lb_backend_available", boolGauge(backend.Available), tags)
+		pm.RecordGauge("lb_backend_active_sessions", float64(backend.Active), tags)
+		pm.RecordGauge("lb_backend_sessions_total", float64(backend.Total), tags)
+		pm.RecordGauge("lb_backend_failures_total", float64(backend.Failures), tags)
+	}
+}
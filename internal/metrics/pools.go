@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/ibrahmsql/gocat/internal/buffer"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/worker"
+)
+
+// RecordWorkerPoolStats records the usage of a worker pool as gauges
+// labelled with the pool name
+func (pm *PrometheusMetrics) RecordWorkerPoolStats(pool string, stats worker.PoolStats) {
+	tags := map[string]string{"pool": pool}
+	pm.RecordGauge("worker_pool_workers", float64(stats.ActiveWorkers), tags)
+	pm.RecordGauge("worker_pool_idle_workers", float64(stats.IdleWorkers), tags)
+	pm.RecordGauge("worker_pool_peak_workers", float64(stats.PeakWorkers), tags)
+	pm.RecordGauge("worker_pool_queued_tasks", float64(stats.QueuedTasks), tags)
+	pm.RecordGauge("worker_pool_tasks_total", float64(stats.TotalTasks), tags)
+	pm.RecordGauge("worker_pool_tasks_completed_total", float64(stats.CompletedTasks), tags)
+	pm.RecordGauge("worker_pool_tasks_failed_total", float64(stats.FailedTasks), tags)
+	pm.RecordGauge("worker_pool_task_seconds_avg", stats.AverageTaskTime.Seconds(), tags)
+}
+
+// RecordBufferPoolStats records the usage of a buffer pool as gauges
+// labelled with the pool name
+func (pm *PrometheusMetrics) RecordBufferPoolStats(pool string, stats buffer.PoolStats) {
+	tags := map[string]string{"pool": pool}
+	pm.RecordGauge("buffer_pool_active_buffers", float64(stats.ActiveBuffers), tags)
+	pm.RecordGauge("buffer_pool_gets_total", float64(stats.TotalAllocations), tags)
+	pm.RecordGauge("buffer_pool_misses_total", float64(stats.PoolMisses), tags)
+	pm.RecordGauge("buffer_pool_allocated_bytes_total", float64(stats.BytesAllocated), tags)
+	pm.RecordGauge("buffer_pool_reused_bytes_total", float64(stats.BytesReused), tags)
+}
+
+// RecordUpstreamStats records the backend connection pool as gauges, with
+// the connections and circuit breaker of each backend labelled with its
+// address. The circuit state is 0 when closed, 1 when open and 2 when
+// half-open.
+func (pm *PrometheusMetrics) RecordUpstreamStats(stats network.UpstreamsStats) {
+	pm.RecordGauge("upstream_pool_hits_total", float64(stats.Pool.PoolHits), nil)
+	pm.RecordGauge("upstream_pool_misses_total", float64(stats.Pool.PoolMisses), nil)
+	pm.RecordGauge("upstream_connections_created_total", float64(stats.Pool.ConnectionsCreated), nil)
+	pm.RecordGauge("upstream_connections_expired_total", float64(stats.Pool.ConnectionsExpired), nil)
+	for _, backend := range stats.Backends {
+		tags := map[string]string{"backend": backend.Address}
+		pm.RecordGauge("upstream_idle_connections", float64(backend.Idle), tags)
+		pm.RecordGauge("upstream_active_connections", float64(backend.Active), tags)
+		pm.RecordGauge("upstream_circuit_state", float64(backend.Circuit.State), tags)
+		pm.RecordGauge("upstream_dial_failures_total", float64(backend.Circuit.Failures), tags)
+		pm.RecordGauge("upstream_dial_consecutive_failures", float64(backend.Circuit.ConsecutiveFailures), tags)
+	}
+}
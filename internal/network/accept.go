@@ -0,0 +1,31 @@
+package network
+
+import "time"
+
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// AcceptBackoff paces an accept loop through errors that clear up on their
+// own, such as EMFILE or ECONNABORTED, instead of spinning or giving up.
+// The zero value is ready to use.
+type AcceptBackoff struct {
+	delay time.Duration
+}
+
+// Wait sleeps before the next Accept, twice as long as after the previous
+// error, up to a second
+func (b *AcceptBackoff) Wait() {
+	if b.delay == 0 {
+		b.delay = minAcceptDelay
+	} else if b.delay *= 2; b.delay > maxAcceptDelay {
+		b.delay = maxAcceptDelay
+	}
+	time.Sleep(b.delay)
+}
+
+// Reset starts over after a successful Accept
+func (b *AcceptBackoff) Reset() {
+	b.delay = 0
+}
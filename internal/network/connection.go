@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -163,19 +164,12 @@ func NewConnection(conn net.Conn, protocol string) *ConnectionImpl {
 	return ec
 }
 
+// connectionCounter numbers the connections of the process
+var connectionCounter atomic.Uint64
+
 // generateConnectionID generates a unique connection ID
 func generateConnectionID() string {
-	return time.Now().Format("20060102150405") + "-" + randomString(8)
-}
-
-// randomString generates a random string of specified length
-func randomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
-	}
-	return string(b)
+	return time.Now().Format("20060102150405") + "-" + strconv.FormatUint(connectionCounter.Add(1), 36)
 }
 
 // Read implements net.Conn.Read with  features
@@ -213,6 +207,12 @@ func (ec *ConnectionImpl) Read(b []byte) (int, error) {
 	atomic.AddInt64(&ec.readOps, 1)
 	ec.updateLastActivity()
 
+	// The end of the stream is not a failure, and io.Copy looks for it
+	// unwrapped; the peer may still read what is written
+	if err == io.EOF {
+		return n, err
+	}
+
 	if err != nil {
 		atomic.AddInt64(&ec.errorCount, 1)
 		ec.setLastError(err.Error())
@@ -463,6 +463,37 @@ func (ec *ConnectionImpl) IsHealthy() bool {
 	return ec.healthy
 }
 
+// Unwrap returns the wrapped connection so relays can splice it, or nil
+// while rate limiting or compression has to see the data
+func (ec *ConnectionImpl) Unwrap() net.Conn {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	if ec.rateLimitEnabled || ec.compressionEnabled {
+		return nil
+	}
+	return ec.conn
+}
+
+// CountRead records bytes read from the wrapped connection by a relay
+func (ec *ConnectionImpl) CountRead(n int64) {
+	atomic.AddInt64(&ec.bytesRead, n)
+	ec.updateLastActivity()
+}
+
+// CountWritten records bytes written to the wrapped connection by a relay
+func (ec *ConnectionImpl) CountWritten(n int64) {
+	atomic.AddInt64(&ec.bytesWritten, n)
+	ec.updateLastActivity()
+}
+
+// CloseWrite shuts down the writing side of the wrapped connection
+func (ec *ConnectionImpl) CloseWrite() error {
+	if hc, ok := ec.conn.(interface{ CloseWrite() error }); ok {
+		return hc.CloseWrite()
+	}
+	return errors.NetworkError("NET056", "Connection cannot be half-closed")
+}
+
 // GetID returns the unique connection ID
 func (ec *ConnectionImpl) GetID() string {
 	return ec.id
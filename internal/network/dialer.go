@@ -3,7 +3,9 @@ package network
 import (
 	"context"
 	"crypto/tls"
+	stderrors "errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
 	"strconv"
 	"strings"
@@ -21,9 +23,15 @@ type Dialer struct {
 	validator        *security.InputValidator
 	circuitBreakers  map[string]*CircuitBreaker
 	metricsCollector MetricsCollector
+	dualStack        *DualStackDialer
+	dialFunc         DialFunc
 	mu               sync.RWMutex
 }
 
+// DialFunc makes a single connection attempt, with the signature of
+// net.Dialer.DialContext
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
 // DialerConfig holds configuration for the  dialer
 type DialerConfig struct {
 	MaxRetries              int           `yaml:"max_retries"`
@@ -38,6 +46,13 @@ type DialerConfig struct {
 	EnableMetrics           bool          `yaml:"enable_metrics"`
 	DualStack               bool          `yaml:"dual_stack"`
 	FallbackDelay           time.Duration `yaml:"fallback_delay"`
+	RetryJitter             float64       `yaml:"retry_jitter"` // fraction each retry delay varies by
+
+	// OnRetry is told about each failed attempt followed by a retry
+	OnRetry func(attempt int, delay time.Duration, err error) `yaml:"-"`
+	// OnCircuitChange is told when the circuit breaker of an address opens,
+	// half-opens or closes
+	OnCircuitChange func(address string, from, to CircuitBreakerState) `yaml:"-"`
 }
 
 // DefaultDialerConfig returns default dialer configuration
@@ -55,6 +70,7 @@ func DefaultDialerConfig() *DialerConfig {
 		EnableMetrics:           true,
 		DualStack:               true,
 		FallbackDelay:           300 * time.Millisecond,
+		RetryJitter:             0.2,
 	}
 }
 
@@ -67,15 +83,38 @@ const (
 	CircuitBreakerHalfOpen
 )
 
-// CircuitBreaker implements the circuit breaker pattern
+// String returns the name of the state
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerClosed:
+		return "closed"
+	case CircuitBreakerOpen:
+		return "open"
+	case CircuitBreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// MarshalText reports the state by name in JSON
+func (s CircuitBreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// CircuitBreaker implements the circuit breaker pattern. It opens after
+// threshold failures in a row, refuses operations for timeout, then lets a
+// single probe through: its success closes the breaker, its failure opens
+// it again.
 type CircuitBreaker struct {
 	threshold   int
 	timeout     time.Duration
 	state       CircuitBreakerState
 	failures    int64
 	successes   int64
+	consecutive int
 	lastFailure time.Time
 	nextAttempt time.Time
+	onChange    func(from, to CircuitBreakerState)
 	mu          sync.RWMutex
 }
 
@@ -100,27 +139,42 @@ func (cb *CircuitBreaker) Allow() bool {
 		return true
 	case CircuitBreakerOpen:
 		if now.After(cb.nextAttempt) {
-			cb.state = CircuitBreakerHalfOpen
+			cb.setState(CircuitBreakerHalfOpen)
+			cb.nextAttempt = now.Add(cb.timeout)
 			return true
 		}
 		return false
 	case CircuitBreakerHalfOpen:
-		return true
+		// One probe at a time; another goes out if the last one never
+		// reported back
+		if now.After(cb.nextAttempt) {
+			cb.nextAttempt = now.Add(cb.timeout)
+			return true
+		}
+		return false
 	}
 
 	return false
 }
 
+// Available reports whether an operation would be allowed, without
+// starting a probe
+func (cb *CircuitBreaker) Available() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return cb.state == CircuitBreakerClosed || time.Now().After(cb.nextAttempt)
+}
+
 // RecordSuccess records a successful operation
 func (cb *CircuitBreaker) RecordSuccess() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
 	atomic.AddInt64(&cb.successes, 1)
+	cb.consecutive = 0
 
-	if cb.state == CircuitBreakerHalfOpen {
-		cb.state = CircuitBreakerClosed
-		cb.failures = 0
+	if cb.state != CircuitBreakerClosed {
+		cb.setState(CircuitBreakerClosed)
 	}
 }
 
@@ -130,14 +184,24 @@ func (cb *CircuitBreaker) RecordFailure() {
 	defer cb.mu.Unlock()
 
 	atomic.AddInt64(&cb.failures, 1)
+	cb.consecutive++
 	cb.lastFailure = time.Now()
 
-	if cb.failures >= int64(cb.threshold) {
-		cb.state = CircuitBreakerOpen
+	if cb.state == CircuitBreakerHalfOpen || cb.consecutive >= cb.threshold {
+		cb.setState(CircuitBreakerOpen)
 		cb.nextAttempt = time.Now().Add(cb.timeout)
 	}
 }
 
+// setState moves the breaker to state, telling onChange; called with mu held
+func (cb *CircuitBreaker) setState(state CircuitBreakerState) {
+	from := cb.state
+	cb.state = state
+	if cb.onChange != nil && from != state {
+		cb.onChange(from, state)
+	}
+}
+
 // GetState returns the current state of the circuit breaker
 func (cb *CircuitBreaker) GetState() CircuitBreakerState {
 	cb.mu.RLock()
@@ -151,21 +215,24 @@ func (cb *CircuitBreaker) GetStats() CircuitBreakerStats {
 	defer cb.mu.RUnlock()
 
 	return CircuitBreakerStats{
-		State:       cb.state,
-		Failures:    atomic.LoadInt64(&cb.failures),
-		Successes:   atomic.LoadInt64(&cb.successes),
-		LastFailure: cb.lastFailure,
-		NextAttempt: cb.nextAttempt,
+		State:               cb.state,
+		Failures:            atomic.LoadInt64(&cb.failures),
+		Successes:           atomic.LoadInt64(&cb.successes),
+		ConsecutiveFailures: cb.consecutive,
+		LastFailure:         cb.lastFailure,
+		NextAttempt:         cb.nextAttempt,
 	}
 }
 
 // CircuitBreakerStats holds circuit breaker statistics
 type CircuitBreakerStats struct {
-	State       CircuitBreakerState `json:"state"`
-	Failures    int64               `json:"failures"`
-	Successes   int64               `json:"successes"`
-	LastFailure time.Time           `json:"last_failure"`
-	NextAttempt time.Time           `json:"next_attempt"`
+	State     CircuitBreakerState `json:"state"`
+	Failures  int64               `json:"failures"`
+	Successes int64               `json:"successes"`
+	// ConsecutiveFailures counts the failures since the last success
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastFailure         time.Time `json:"last_failure"`
+	NextAttempt         time.Time `json:"next_attempt"`
 }
 
 // NewDialer creates a new  dialer
@@ -174,17 +241,33 @@ func NewDialer(config *DialerConfig) *Dialer {
 		config = DefaultDialerConfig()
 	}
 
+	dualStackConfig := DefaultDualStackConfig()
+	dualStackConfig.HappyEyeballsDelay = config.FallbackDelay
+	dualStack := NewDualStackDialer(dualStackConfig)
+	dualStack.SetBaseDialer(&net.Dialer{
+		Timeout:   config.ConnectionTimeout,
+		KeepAlive: config.KeepAlive,
+	})
+
 	return &Dialer{
 		config:          config,
 		validator:       security.NewInputValidator(),
 		circuitBreakers: make(map[string]*CircuitBreaker),
+		dualStack:       dualStack,
 	}
 }
 
+// SetDialFunc replaces the attempts of the dialer, which go on being
+// retried and guarded by the circuit breaker. Attempts dial with Happy
+// Eyeballs until it is set.
+func (d *Dialer) SetDialFunc(f DialFunc) {
+	d.mu.Lock()
+	d.dialFunc = f
+	d.mu.Unlock()
+}
+
 // Dial connects to the specified address with retry logic and circuit breaker
 func (d *Dialer) Dial(ctx context.Context, address string) (Connection, error) {
-	startTime := time.Now()
-
 	// Validate address format
 	host, portStr, err := net.SplitHostPort(address)
 	if err != nil {
@@ -202,6 +285,29 @@ func (d *Dialer) Dial(ctx context.Context, address string) (Connection, error) {
 		return nil, errors.WrapError(err, errors.ErrorTypeValidation, errors.SeverityMedium, "VAL020", "Invalid port")
 	}
 
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Create  connection
+	Conn := NewConnection(conn, "tcp")
+
+	// Set metrics collector if available
+	if d.metricsCollector != nil {
+		Conn.SetMetrics(d.metricsCollector)
+	}
+
+	return Conn, nil
+}
+
+// DialContext connects to address over network through the whole stack:
+// the circuit breaker of the address, retries with jittered exponential
+// backoff, and attempts dialing with Happy Eyeballs or the DialFunc. It
+// returns the plain connection.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	startTime := time.Now()
+
 	// Check circuit breaker
 	if d.config.EnableCircuitBreaker {
 		cb := d.getCircuitBreaker(address)
@@ -211,12 +317,20 @@ func (d *Dialer) Dial(ctx context.Context, address string) (Connection, error) {
 					"address": address,
 				})
 			}
-			return nil, errors.NetworkError("NET035", fmt.Sprintf("Circuit breaker is open for %s", address)).WithUserFriendly("Service is temporarily unavailable")
+			return nil, errors.NetworkError("NET035", fmt.Sprintf("Circuit breaker is open for %s", address)).WithUserFriendly(fmt.Sprintf("%s is temporarily unavailable", address))
 		}
 	}
 
 	// Attempt connection with retry logic
-	conn, err := d.dialWithRetry(ctx, host, port)
+	conn, err := d.dialWithRetry(ctx, network, address)
+
+	if d.config.EnableCircuitBreaker {
+		if err == nil {
+			d.getCircuitBreaker(address).RecordSuccess()
+		} else if ctx.Err() == nil {
+			d.getCircuitBreaker(address).RecordFailure()
+		}
+	}
 
 	// Record metrics
 	if d.metricsCollector != nil {
@@ -225,16 +339,8 @@ func (d *Dialer) Dial(ctx context.Context, address string) (Connection, error) {
 			"address": address,
 			"success": "false",
 		}
-
 		if err == nil {
 			tags["success"] = "true"
-			if d.config.EnableCircuitBreaker {
-				d.getCircuitBreaker(address).RecordSuccess()
-			}
-		} else {
-			if d.config.EnableCircuitBreaker {
-				d.getCircuitBreaker(address).RecordFailure()
-			}
 		}
 
 		d.metricsCollector.RecordTimer("dial_duration", duration, tags)
@@ -245,17 +351,23 @@ func (d *Dialer) Dial(ctx context.Context, address string) (Connection, error) {
 }
 
 // dialWithRetry attempts to dial with exponential backoff retry logic
-func (d *Dialer) dialWithRetry(ctx context.Context, host string, port int) (Connection, error) {
+func (d *Dialer) dialWithRetry(ctx context.Context, network, address string) (net.Conn, error) {
 	var lastErr error
 	delay := d.config.InitialRetryDelay
 
 	for attempt := 0; attempt <= d.config.MaxRetries; attempt++ {
 		if attempt > 0 {
+			// Spread retries of many clients over time
+			wait := d.jitter(delay)
+			if d.config.OnRetry != nil {
+				d.config.OnRetry(attempt, wait, lastErr)
+			}
+
 			// Wait before retry
 			select {
 			case <-ctx.Done():
 				return nil, errors.TimeoutError("NET036", "Connection cancelled during retry").WithCause(ctx.Err())
-			case <-time.After(delay):
+			case <-time.After(wait):
 			}
 
 			// Calculate next delay with exponential backoff
@@ -269,11 +381,11 @@ func (d *Dialer) dialWithRetry(ctx context.Context, host string, port int) (Conn
 		if d.metricsCollector != nil && attempt > 0 {
 			d.metricsCollector.IncrementCounter("dial_retries", map[string]string{
 				"attempt": strconv.Itoa(attempt),
-				"host":    host,
+				"address": address,
 			})
 		}
 
-		conn, err := d.dialOnce(ctx, host, port)
+		conn, err := d.dialOnce(ctx, network, address)
 		if err == nil {
 			return conn, nil
 		}
@@ -294,30 +406,41 @@ func (d *Dialer) dialWithRetry(ctx context.Context, host string, port int) (Conn
 	return nil, lastErr
 }
 
-// dialOnce performs a single dial attempt with dual-stack support
-func (d *Dialer) dialOnce(ctx context.Context, host string, port int) (Connection, error) {
-	address := net.JoinHostPort(host, strconv.Itoa(port))
+// jitter varies delay by up to RetryJitter either way
+func (d *Dialer) jitter(delay time.Duration) time.Duration {
+	if d.config.RetryJitter <= 0 {
+		return delay
+	}
+	spread := d.config.RetryJitter * (2*rand.Float64() - 1)
+	return time.Duration(float64(delay) * (1 + spread))
+}
 
+// dialOnce performs a single dial attempt with dual-stack support
+func (d *Dialer) dialOnce(ctx context.Context, network, address string) (net.Conn, error) {
 	// Create context with timeout
 	dialCtx, cancel := context.WithTimeout(ctx, d.config.ConnectionTimeout)
 	defer cancel()
 
-	// Configure dialer
-	dialer := &net.Dialer{
-		Timeout:   d.config.ConnectionTimeout,
-		KeepAlive: d.config.KeepAlive,
-		DualStack: d.config.DualStack,
-	}
+	d.mu.RLock()
+	dialFunc := d.dialFunc
+	d.mu.RUnlock()
 
 	var conn net.Conn
 	var err error
 
-	if d.config.DualStack {
+	switch {
+	case dialFunc != nil:
+		conn, err = dialFunc(dialCtx, network, address)
+	case d.config.DualStack:
 		// Use Happy Eyeballs algorithm for dual-stack
-		conn, err = d.dialDualStack(dialCtx, dialer, host, port)
-	} else {
+		conn, err = d.dualStack.DialContext(dialCtx, network, address)
+	default:
 		// Standard dial
-		conn, err = dialer.DialContext(dialCtx, "tcp", address)
+		dialer := &net.Dialer{
+			Timeout:   d.config.ConnectionTimeout,
+			KeepAlive: d.config.KeepAlive,
+		}
+		conn, err = dialer.DialContext(dialCtx, network, address)
 	}
 
 	if err != nil {
@@ -331,106 +454,7 @@ func (d *Dialer) dialOnce(ctx context.Context, host string, port int) (Connectio
 		tcpConn.SetKeepAlivePeriod(d.config.KeepAlive)
 	}
 
-	// Create  connection
-	Conn := NewConnection(conn, "tcp")
-
-	// Set metrics collector if available
-	if d.metricsCollector != nil {
-		Conn.SetMetrics(d.metricsCollector)
-	}
-
-	return Conn, nil
-}
-
-// dialDualStack implements Happy Eyeballs algorithm for IPv4/IPv6 dual-stack
-func (d *Dialer) dialDualStack(ctx context.Context, dialer *net.Dialer, host string, port int) (net.Conn, error) {
-	// Resolve addresses for both IPv4 and IPv6
-	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(addresses) == 0 {
-		return nil, &net.DNSError{
-			Err:  "no addresses found",
-			Name: host,
-		}
-	}
-
-	// Separate IPv4 and IPv6 addresses
-	var ipv4Addrs, ipv6Addrs []net.IPAddr
-	for _, addr := range addresses {
-		if addr.IP.To4() != nil {
-			ipv4Addrs = append(ipv4Addrs, addr)
-		} else {
-			ipv6Addrs = append(ipv6Addrs, addr)
-		}
-	}
-
-	// Create channels for results
-	type dialResult struct {
-		conn net.Conn
-		err  error
-	}
-
-	resultChan := make(chan dialResult, len(addresses))
-	dialCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	// Start with IPv6 if available
-	if len(ipv6Addrs) > 0 {
-		go func() {
-			addr := net.JoinHostPort(ipv6Addrs[0].IP.String(), strconv.Itoa(port))
-			conn, err := dialer.DialContext(dialCtx, "tcp6", addr)
-			resultChan <- dialResult{conn: conn, err: err}
-		}()
-
-		// Wait a bit before trying IPv4 (Happy Eyeballs delay)
-		if len(ipv4Addrs) > 0 {
-			go func() {
-				time.Sleep(d.config.FallbackDelay)
-				select {
-				case <-dialCtx.Done():
-					return
-				default:
-				}
-
-				addr := net.JoinHostPort(ipv4Addrs[0].IP.String(), strconv.Itoa(port))
-				conn, err := dialer.DialContext(dialCtx, "tcp4", addr)
-				resultChan <- dialResult{conn: conn, err: err}
-			}()
-		}
-	} else if len(ipv4Addrs) > 0 {
-		// Only IPv4 available
-		go func() {
-			addr := net.JoinHostPort(ipv4Addrs[0].IP.String(), strconv.Itoa(port))
-			conn, err := dialer.DialContext(dialCtx, "tcp4", addr)
-			resultChan <- dialResult{conn: conn, err: err}
-		}()
-	}
-
-	// Wait for first successful connection
-	var lastErr error
-	expectedResults := 1
-	if len(ipv4Addrs) > 0 && len(ipv6Addrs) > 0 {
-		expectedResults = 2
-	}
-
-	for i := 0; i < expectedResults; i++ {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case result := <-resultChan:
-			if result.err == nil {
-				// Success! Cancel other attempts and return
-				cancel()
-				return result.conn, nil
-			}
-			lastErr = result.err
-		}
-	}
-
-	return nil, lastErr
+	return conn, nil
 }
 
 // isRetryableError determines if an error should trigger a retry
@@ -474,6 +498,12 @@ func (d *Dialer) isRetryableError(err error) bool {
 func (d *Dialer) wrapDialError(err error, address string) error {
 	errStr := strings.ToLower(err.Error())
 
+	// Names that do not resolve, or may not be resolved, stay that way
+	var dnsErr *net.DNSError
+	if stderrors.As(err, &dnsErr) && !dnsErr.IsTimeout && !dnsErr.IsTemporary {
+		errStr = "no such host"
+	}
+
 	switch {
 	case strings.Contains(errStr, "connection refused"):
 		return errors.WrapError(err, errors.ErrorTypeNetwork, errors.SeverityHigh, "NET037", "Connection refused").
@@ -545,6 +575,9 @@ func (d *Dialer) getCircuitBreaker(address string) *CircuitBreaker {
 
 	// Create new circuit breaker
 	cb = NewCircuitBreaker(d.config.CircuitBreakerThreshold, d.config.CircuitBreakerTimeout)
+	if onChange := d.config.OnCircuitChange; onChange != nil {
+		cb.onChange = func(from, to CircuitBreakerState) { onChange(address, from, to) }
+	}
 	d.circuitBreakers[address] = cb
 	return cb
 }
@@ -568,6 +601,18 @@ func (d *Dialer) GetCircuitBreakerStats() map[string]CircuitBreakerStats {
 	return stats
 }
 
+// Available reports whether the circuit breaker of address would let a
+// dial through, without dialing
+func (d *Dialer) Available(address string) bool {
+	if !d.config.EnableCircuitBreaker {
+		return true
+	}
+	d.mu.RLock()
+	cb, exists := d.circuitBreakers[address]
+	d.mu.RUnlock()
+	return !exists || cb.Available()
+}
+
 // ResetCircuitBreaker resets the circuit breaker for a specific address
 func (d *Dialer) ResetCircuitBreaker(address string) {
 	d.mu.Lock()
@@ -575,9 +620,10 @@ func (d *Dialer) ResetCircuitBreaker(address string) {
 
 	if cb, exists := d.circuitBreakers[address]; exists {
 		cb.mu.Lock()
-		cb.state = CircuitBreakerClosed
+		cb.setState(CircuitBreakerClosed)
 		cb.failures = 0
 		cb.successes = 0
+		cb.consecutive = 0
 		cb.mu.Unlock()
 	}
 }
@@ -2,14 +2,19 @@ package network
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ibrahmsql/gocat/internal/errors"
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/resolver"
 )
 
 // DualStackConfig holds configuration for dual-stack networking
@@ -39,10 +44,18 @@ func DefaultDualStackConfig() *DualStackConfig {
 // DualStackDialer provides dual-stack dialing capabilities
 type DualStackDialer struct {
 	config           *DualStackConfig
+	base             net.Dialer
+	resolver         HostResolver
 	metricsCollector MetricsCollector
 	mu               sync.RWMutex
 }
 
+// HostResolver looks up the addresses of host names, as net.Resolver and
+// resolver.Resolver do
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
 // NewDualStackDialer creates a new dual-stack dialer
 func NewDualStackDialer(config *DualStackConfig) *DualStackDialer {
 	if config == nil {
@@ -51,27 +64,69 @@ func NewDualStackDialer(config *DualStackConfig) *DualStackDialer {
 
 	return &DualStackDialer{
 		config: config,
+		base:   net.Dialer{Timeout: 30 * time.Second},
 	}
 }
 
+// SetBaseDialer sets the dialer each attempt is a copy of, for its
+// timeout, keep-alive, local address and socket control
+func (d *DualStackDialer) SetBaseDialer(base *net.Dialer) {
+	d.mu.Lock()
+	d.base = *base
+	d.mu.Unlock()
+}
+
+// SetResolver sets the resolver host names are looked up with; by
+// default it is resolver.Default() at the time of each dial
+func (d *DualStackDialer) SetResolver(r HostResolver) {
+	d.mu.Lock()
+	d.resolver = r
+	d.mu.Unlock()
+}
+
 // AddressInfo holds information about a resolved address
 type AddressInfo struct {
 	IP       net.IP
 	Port     int
-	Network  string // "tcp4" or "tcp6"
+	Network  string // "tcp4", "tcp6", "udp4" or "udp6"
 	Priority int    // Lower is higher priority
 }
 
+// Family returns "IPv4" or "IPv6"
+func (a AddressInfo) Family() string {
+	if a.IP.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
 // DialResult holds the result of a dial attempt
 type DialResult struct {
-	Conn    net.Conn
-	Address AddressInfo
-	Error   error
-	Latency time.Duration
+	Conn     net.Conn
+	Address  AddressInfo
+	Error    error
+	Latency  time.Duration
+	Attempts int // addresses tried until Conn, counting it
 }
 
-// Dial performs dual-stack dialing with Happy Eyeballs algorithm
+// Dial performs dual-stack TCP dialing with the Happy Eyeballs algorithm
 func (d *DualStackDialer) Dial(ctx context.Context, address string) (net.Conn, error) {
+	return d.DialContext(ctx, "tcp", address)
+}
+
+// DialContext connects to address over network, which is tcp or udp with
+// an optional 4 or 6 restricting the family. It has the signature of
+// net.Dialer.DialContext so it can stand in for one.
+func (d *DualStackDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	result, err := d.DialDetailed(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return result.Conn, nil
+}
+
+// DialDetailed connects like DialContext and reports which address won
+func (d *DualStackDialer) DialDetailed(ctx context.Context, network, address string) (*DialResult, error) {
 	startTime := time.Now()
 
 	// Parse address
@@ -80,7 +135,7 @@ func (d *DualStackDialer) Dial(ctx context.Context, address string) (net.Conn, e
 		return nil, errors.ValidationError("VAL021", fmt.Sprintf("Invalid address format: %s", address)).WithSuggestion("Use format 'host:port'")
 	}
 
-	port, err := strconv.Atoi(portStr)
+	port, err := net.DefaultResolver.LookupPort(ctx, network, portStr)
 	if err != nil {
 		return nil, errors.ValidationError("VAL022", fmt.Sprintf("Invalid port: %s", portStr))
 	}
@@ -91,7 +146,7 @@ func (d *DualStackDialer) Dial(ctx context.Context, address string) (net.Conn, e
 	}
 
 	// Resolve addresses
-	addresses, err := d.resolveAddresses(ctx, host, port)
+	addresses, err := d.resolveAddresses(ctx, network, host, port)
 	if err != nil {
 		return nil, err
 	}
@@ -104,15 +159,22 @@ func (d *DualStackDialer) Dial(ctx context.Context, address string) (net.Conn, e
 	d.sortAddressesByPreference(addresses)
 
 	// Perform connection racing if enabled
-	var conn net.Conn
+	var result *DialResult
 	if d.config.ConnectionRacing && len(addresses) > 1 {
-		conn, err = d.dialWithRacing(ctx, addresses)
+		result, err = d.dialWithRacing(ctx, addresses)
 	} else {
-		conn, err = d.dialSequential(ctx, addresses)
+		result, err = d.dialSequential(ctx, addresses)
+	}
+	if err == nil {
+		logger.Debug("Happy Eyeballs: %s %s won for %s after %d of %d addresses",
+			result.Address.Family(), result.Address.IP, host, result.Attempts, len(addresses))
 	}
 
 	// Record metrics
-	if d.metricsCollector != nil {
+	d.mu.RLock()
+	collector := d.metricsCollector
+	d.mu.RUnlock()
+	if collector != nil {
 		duration := time.Since(startTime)
 		tags := map[string]string{
 			"host":    host,
@@ -121,117 +183,88 @@ func (d *DualStackDialer) Dial(ctx context.Context, address string) (net.Conn, e
 
 		if err == nil {
 			tags["success"] = "true"
-			if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
-				if tcpAddr.IP.To4() != nil {
-					tags["protocol"] = "ipv4"
-				} else {
-					tags["protocol"] = "ipv6"
-				}
-			}
+			tags["protocol"] = strings.ToLower(result.Address.Family())
 		}
 
-		d.metricsCollector.RecordTimer("dualstack_dial_duration", duration, tags)
-		d.metricsCollector.IncrementCounter("dualstack_dial_attempts", tags)
+		collector.RecordTimer("dualstack_dial_duration", duration, tags)
+		collector.IncrementCounter("dualstack_dial_attempts", tags)
 	}
 
-	return conn, err
+	return result, err
 }
 
-// resolveAddresses resolves both IPv4 and IPv6 addresses for the host
-func (d *DualStackDialer) resolveAddresses(ctx context.Context, host string, port int) ([]AddressInfo, error) {
-	// Create context with timeout for resolution
-	resolveCtx, cancel := context.WithTimeout(ctx, d.config.ResolutionTimeout)
-	defer cancel()
+// resolveAddresses resolves the addresses of host the network and config
+// allow
+func (d *DualStackDialer) resolveAddresses(ctx context.Context, network, host string, port int) ([]AddressInfo, error) {
+	proto := strings.TrimRight(network, "46")
+	ipv4 := d.config.IPv4Enabled && !strings.HasSuffix(network, "6")
+	ipv6 := d.config.IPv6Enabled && !strings.HasSuffix(network, "4")
+
+	// A bound local address fixes the family
+	d.mu.RLock()
+	local := d.base.LocalAddr
+	d.mu.RUnlock()
+	if ip := addrIP(local); ip != nil && !ip.IsUnspecified() {
+		ipv4 = ipv4 && ip.To4() != nil
+		ipv6 = ipv6 && ip.To4() == nil
+	}
+
+	candidate := func(ip net.IP) (AddressInfo, bool) {
+		if ip.To4() != nil {
+			return AddressInfo{IP: ip, Port: port, Network: proto + "4", Priority: d.getAddressPriority(ip)}, ipv4
+		}
+		return AddressInfo{IP: ip, Port: port, Network: proto + "6", Priority: d.getAddressPriority(ip)}, ipv6
+	}
 
 	// Check if host is already an IP address
 	if ip := net.ParseIP(host); ip != nil {
-		network := "tcp4"
-		if ip.To4() == nil {
-			network = "tcp6"
+		addr, ok := candidate(ip)
+		if !ok {
+			return nil, errors.NetworkError("NET046", fmt.Sprintf("Address %s is not usable over %s", host, network))
 		}
-		return []AddressInfo{{
-			IP:      ip,
-			Port:    port,
-			Network: network,
-		}}, nil
+		return []AddressInfo{addr}, nil
 	}
 
-	var addresses []AddressInfo
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var resolveErrors []error
-
-	// Resolve IPv4 addresses
-	if d.config.IPv4Enabled {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			ips, err := net.DefaultResolver.LookupIPAddr(resolveCtx, host)
-			if err != nil {
-				mu.Lock()
-				resolveErrors = append(resolveErrors, fmt.Errorf("IPv4 resolution failed: %w", err))
-				mu.Unlock()
-				return
-			}
+	// Create context with timeout for resolution
+	resolveCtx, cancel := context.WithTimeout(ctx, d.config.ResolutionTimeout)
+	defer cancel()
 
-			mu.Lock()
-			for _, ip := range ips {
-				if ip.IP.To4() != nil {
-					addresses = append(addresses, AddressInfo{
-						IP:       ip.IP,
-						Port:     port,
-						Network:  "tcp4",
-						Priority: d.getAddressPriority(ip.IP),
-					})
-				}
-			}
-			mu.Unlock()
-		}()
+	d.mu.RLock()
+	var lookup HostResolver = resolver.Default()
+	if d.resolver != nil {
+		lookup = d.resolver
 	}
+	d.mu.RUnlock()
 
-	// Resolve IPv6 addresses
-	if d.config.IPv6Enabled {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			ips, err := net.DefaultResolver.LookupIPAddr(resolveCtx, host)
-			if err != nil {
-				mu.Lock()
-				resolveErrors = append(resolveErrors, fmt.Errorf("IPv6 resolution failed: %w", err))
-				mu.Unlock()
-				return
-			}
-
-			mu.Lock()
-			for _, ip := range ips {
-				if ip.IP.To4() == nil {
-					addresses = append(addresses, AddressInfo{
-						IP:       ip.IP,
-						Port:     port,
-						Network:  "tcp6",
-						Priority: d.getAddressPriority(ip.IP),
-					})
-				}
-			}
-			mu.Unlock()
-		}()
+	ips, err := lookup.LookupIPAddr(resolveCtx, host)
+	if err != nil {
+		return nil, errors.NetworkError("NET045", fmt.Sprintf("DNS resolution failed for %s", host)).WithCause(err)
 	}
 
-	wg.Wait()
-
-	// Check if we got any addresses
-	if len(addresses) == 0 {
-		if len(resolveErrors) > 0 {
-			return nil, errors.NetworkError("NET045", fmt.Sprintf("DNS resolution failed for %s", host)).WithCause(resolveErrors[0])
+	var addresses []AddressInfo
+	for _, ip := range ips {
+		if addr, ok := candidate(ip.IP); ok {
+			addresses = append(addresses, addr)
 		}
+	}
+	if len(addresses) == 0 {
 		return nil, errors.NetworkError("NET046", fmt.Sprintf("No IP addresses found for %s", host))
 	}
 
 	return addresses, nil
 }
 
+// addrIP returns the IP of a TCP or UDP address
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
 // getAddressPriority returns priority for an IP address (lower is higher priority)
 func (d *DualStackDialer) getAddressPriority(ip net.IP) int {
 	if ip.To4() != nil {
@@ -249,21 +282,43 @@ func (d *DualStackDialer) getAddressPriority(ip net.IP) int {
 	}
 }
 
-// sortAddressesByPreference sorts addresses by preference and priority
+// sortAddressesByPreference orders addresses as RFC 8305 does: one of the
+// preferred family first, then alternating between the families, keeping
+// the resolver's order within each
 func (d *DualStackDialer) sortAddressesByPreference(addresses []AddressInfo) {
-	// Simple bubble sort by priority (good enough for small lists)
-	n := len(addresses)
-	for i := 0; i < n-1; i++ {
-		for j := 0; j < n-i-1; j++ {
-			if addresses[j].Priority > addresses[j+1].Priority {
-				addresses[j], addresses[j+1] = addresses[j+1], addresses[j]
-			}
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return addresses[i].Priority < addresses[j].Priority
+	})
+
+	var preferred, other []AddressInfo
+	for _, addr := range addresses {
+		if addr.Priority == addresses[0].Priority {
+			preferred = append(preferred, addr)
+		} else {
+			other = append(other, addr)
+		}
+	}
+
+	i := 0
+	for len(preferred) > 0 || len(other) > 0 {
+		if len(preferred) > 0 {
+			addresses[i] = preferred[0]
+			preferred = preferred[1:]
+			i++
+		}
+		if len(other) > 0 {
+			addresses[i] = other[0]
+			other = other[1:]
+			i++
 		}
 	}
 }
 
-// dialWithRacing performs connection racing (Happy Eyeballs)
-func (d *DualStackDialer) dialWithRacing(ctx context.Context, addresses []AddressInfo) (net.Conn, error) {
+// dialWithRacing performs connection racing (Happy Eyeballs). Attempts
+// start in order, each one HappyEyeballsDelay after the previous or as soon
+// as it failed, with at most MaxConcurrentDials in flight. The first to
+// connect wins and the others are cancelled.
+func (d *DualStackDialer) dialWithRacing(ctx context.Context, addresses []AddressInfo) (*DialResult, error) {
 	if len(addresses) == 0 {
 		return nil, errors.NetworkError("NET047", "No addresses to dial")
 	}
@@ -272,72 +327,97 @@ func (d *DualStackDialer) dialWithRacing(ctx context.Context, addresses []Addres
 	dialCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// Start with the highest priority address
-	go d.dialSingle(dialCtx, addresses[0], resultChan)
-	dialsStarted := 1
-
-	// Start additional dials with delay
-	go func() {
-		delay := d.config.HappyEyeballsDelay
-		for i := 1; i < len(addresses) && i < d.config.MaxConcurrentDials; i++ {
-			select {
-			case <-dialCtx.Done():
-				return
-			case <-time.After(delay):
-				go d.dialSingle(dialCtx, addresses[i], resultChan)
-				dialsStarted++
-				// Increase delay for subsequent attempts
-				delay = delay * 2
-			}
+	limit := d.config.MaxConcurrentDials
+	if limit < 1 {
+		limit = 1
+	}
+	next, inFlight := 0, 0
+	startNext := func() {
+		if next < len(addresses) && inFlight < limit {
+			go d.dialSingle(dialCtx, addresses[next], resultChan)
+			next++
+			inFlight++
 		}
-	}()
+	}
+
+	// Start with the highest priority address
+	startNext()
+	timer := time.NewTimer(d.config.HappyEyeballsDelay)
+	defer timer.Stop()
 
 	// Wait for first successful connection
 	var lastErr error
-	successCount := 0
-	errorCount := 0
-
-	for successCount == 0 && errorCount < dialsStarted {
+	for inFlight > 0 {
 		select {
 		case <-ctx.Done():
+			d.discardLosers(resultChan, inFlight)
 			return nil, errors.TimeoutError("NET048", "Dial context cancelled").WithCause(ctx.Err())
+		case <-timer.C:
+			startNext()
+			timer.Reset(d.config.HappyEyeballsDelay)
 		case result := <-resultChan:
+			inFlight--
 			if result.Error == nil {
 				// Success! Cancel other attempts
 				cancel()
+				d.discardLosers(resultChan, inFlight)
+				result.Attempts = next
 
 				// Record successful connection metrics
-				if d.metricsCollector != nil {
-					d.metricsCollector.RecordTimer("connection_latency", result.Latency, map[string]string{
-						"network": result.Address.Network,
-						"ip":      result.Address.IP.String(),
-					})
-				}
-
-				return result.Conn, nil
+				d.record("connection_latency", result)
+				return &result, nil
 			}
 
-			errorCount++
 			lastErr = result.Error
 
 			// Record failed connection attempt
-			if d.metricsCollector != nil {
-				d.metricsCollector.IncrementCounter("connection_failures", map[string]string{
-					"network": result.Address.Network,
-					"ip":      result.Address.IP.String(),
-				})
-			}
+			d.record("connection_failures", result)
+
+			// A failed attempt makes way for the next at once
+			startNext()
+			timer.Reset(d.config.HappyEyeballsDelay)
 		}
 	}
 
 	return nil, lastErr
 }
 
+// discardLosers closes the connections of attempts still in flight when
+// the race was decided
+func (d *DualStackDialer) discardLosers(resultChan <-chan DialResult, inFlight int) {
+	go func() {
+		for i := 0; i < inFlight; i++ {
+			if result := <-resultChan; result.Conn != nil {
+				result.Conn.Close()
+			}
+		}
+	}()
+}
+
+// record reports an attempt to the metrics collector
+func (d *DualStackDialer) record(name string, result DialResult) {
+	d.mu.RLock()
+	collector := d.metricsCollector
+	d.mu.RUnlock()
+	if collector == nil {
+		return
+	}
+	tags := map[string]string{
+		"network": result.Address.Network,
+		"ip":      result.Address.IP.String(),
+	}
+	if result.Error == nil {
+		collector.RecordTimer(name, result.Latency, tags)
+	} else {
+		collector.IncrementCounter(name, tags)
+	}
+}
+
 // dialSequential performs sequential dialing
-func (d *DualStackDialer) dialSequential(ctx context.Context, addresses []AddressInfo) (net.Conn, error) {
+func (d *DualStackDialer) dialSequential(ctx context.Context, addresses []AddressInfo) (*DialResult, error) {
 	var lastErr error
 
-	for _, addr := range addresses {
+	for i, addr := range addresses {
 		select {
 		case <-ctx.Done():
 			return nil, errors.TimeoutError("NET049", "Dial context cancelled").WithCause(ctx.Err())
@@ -345,11 +425,12 @@ func (d *DualStackDialer) dialSequential(ctx context.Context, addresses []Addres
 		}
 
 		resultChan := make(chan DialResult, 1)
-		go d.dialSingle(ctx, addr, resultChan)
+		d.dialSingle(ctx, addr, resultChan)
 
 		result := <-resultChan
 		if result.Error == nil {
-			return result.Conn, nil
+			result.Attempts = i + 1
+			return &result, nil
 		}
 
 		lastErr = result.Error
@@ -362,9 +443,9 @@ func (d *DualStackDialer) dialSequential(ctx context.Context, addresses []Addres
 func (d *DualStackDialer) dialSingle(ctx context.Context, addr AddressInfo, resultChan chan<- DialResult) {
 	startTime := time.Now()
 
-	dialer := &net.Dialer{
-		Timeout: 30 * time.Second,
-	}
+	d.mu.RLock()
+	dialer := d.base
+	d.mu.RUnlock()
 
 	address := net.JoinHostPort(addr.IP.String(), strconv.Itoa(addr.Port))
 	conn, err := dialer.DialContext(ctx, addr.Network, address)
@@ -422,7 +503,10 @@ func NewDualStackListener(config *DualStackConfig) *DualStackListener {
 	}
 }
 
-// Listen starts listening on both IPv4 and IPv6
+// Listen starts listening on both IPv4 and IPv6. A wildcard or empty host
+// binds each enabled family on its own socket, with the port of the first
+// reused for the second; a family the host cannot bind is skipped as long
+// as the other listens. A literal IP binds only its own family.
 func (l *DualStackListener) Listen(address string) error {
 	host, portStr, err := net.SplitHostPort(address)
 	if err != nil {
@@ -434,49 +518,68 @@ func (l *DualStackListener) Listen(address string) error {
 		return errors.ValidationError("VAL025", fmt.Sprintf("Invalid port: %s", portStr))
 	}
 
-	// Start IPv4 listener if enabled
-	if l.config.IPv4Enabled {
-		ipv4Addr := net.JoinHostPort("0.0.0.0", strconv.Itoa(port))
-		if host != "" && host != "::" {
-			// Use specific IPv4 address if provided
-			if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
-				ipv4Addr = net.JoinHostPort(host, strconv.Itoa(port))
-			}
+	ipv4Host, ipv6Host := "0.0.0.0", "::"
+	ipv4Enabled, ipv6Enabled := l.config.IPv4Enabled, l.config.IPv6Enabled
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		if ip.To4() != nil {
+			ipv4Host, ipv6Enabled = host, false
+		} else {
+			ipv6Host, ipv4Enabled = host, false
 		}
+	}
 
-		l.ipv4Listener, err = net.Listen("tcp4", ipv4Addr)
-		if err != nil {
-			return errors.WrapError(err, errors.ErrorTypeNetwork, errors.SeverityHigh, "NET050", "Failed to create IPv4 listener")
+	// Start IPv4 listener if enabled
+	var ipv4Err error
+	if ipv4Enabled {
+		l.ipv4Listener, ipv4Err = net.Listen("tcp4", net.JoinHostPort(ipv4Host, strconv.Itoa(port)))
+		if ipv4Err == nil {
+			// Bind IPv6 to the same port when the kernel chose it
+			port = l.ipv4Listener.Addr().(*net.TCPAddr).Port
+		} else if !ipv6Enabled || !familyUnavailable(ipv4Err) {
+			return errors.WrapError(ipv4Err, errors.ErrorTypeNetwork, errors.SeverityHigh, "NET050", "Failed to create IPv4 listener")
 		}
-
-		go l.acceptLoop(l.ipv4Listener, "ipv4")
 	}
 
 	// Start IPv6 listener if enabled
-	if l.config.IPv6Enabled {
-		ipv6Addr := net.JoinHostPort("::", strconv.Itoa(port))
-		if host != "" && host != "0.0.0.0" {
-			// Use specific IPv6 address if provided
-			if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
-				ipv6Addr = net.JoinHostPort(host, strconv.Itoa(port))
-			}
-		}
-
-		l.ipv6Listener, err = net.Listen("tcp6", ipv6Addr)
-		if err != nil {
+	if ipv6Enabled {
+		l.ipv6Listener, err = net.Listen("tcp6", net.JoinHostPort(ipv6Host, strconv.Itoa(port)))
+		if err != nil && (l.ipv4Listener == nil || !familyUnavailable(err)) {
 			// If IPv4 listener was created, close it
 			if l.ipv4Listener != nil {
 				l.ipv4Listener.Close()
 			}
 			return errors.WrapError(err, errors.ErrorTypeNetwork, errors.SeverityHigh, "NET051", "Failed to create IPv6 listener")
 		}
+		if err != nil {
+			logger.Debug("IPv6 listener skipped: %v", err)
+			l.ipv6Listener = nil
+		}
+	}
+	if ipv4Err != nil {
+		logger.Debug("IPv4 listener skipped: %v", ipv4Err)
+		l.ipv4Listener = nil
+	}
 
+	if l.ipv4Listener == nil && l.ipv6Listener == nil {
+		return errors.NetworkError("NET055", fmt.Sprintf("No address family enabled for %s", address))
+	}
+	if l.ipv4Listener != nil {
+		go l.acceptLoop(l.ipv4Listener, "ipv4")
+	}
+	if l.ipv6Listener != nil {
 		go l.acceptLoop(l.ipv6Listener, "ipv6")
 	}
 
 	return nil
 }
 
+// familyUnavailable reports whether err means the host has no usable
+// stack for the address family, rather than a conflict on the port
+func familyUnavailable(err error) bool {
+	return stderrors.Is(err, syscall.EAFNOSUPPORT) || stderrors.Is(err, syscall.EADDRNOTAVAIL) ||
+		stderrors.Is(err, syscall.EPROTONOSUPPORT)
+}
+
 // acceptLoop runs the accept loop for a specific listener
 func (l *DualStackListener) acceptLoop(listener net.Listener, protocol string) {
 	for {
@@ -512,12 +615,12 @@ func (l *DualStackListener) Accept() (net.Conn, error) {
 	l.mu.RUnlock()
 
 	if closed {
-		return nil, errors.NetworkError("NET053", "Dual-stack listener is closed")
+		return nil, errors.NetworkError("NET053", "Dual-stack listener is closed").WithCause(net.ErrClosed)
 	}
 
 	select {
 	case <-l.ctx.Done():
-		return nil, errors.NetworkError("NET054", "Dual-stack listener context cancelled")
+		return nil, errors.NetworkError("NET054", "Dual-stack listener context cancelled").WithCause(net.ErrClosed)
 	case conn := <-l.acceptChan:
 		return conn, nil
 	case err := <-l.errorChan:
@@ -551,7 +654,15 @@ func (l *DualStackListener) Close() error {
 		}
 	}
 
-	return lastErr
+	// Drop connections accepted but never handed out
+	for {
+		select {
+		case conn := <-l.acceptChan:
+			conn.Close()
+		default:
+			return lastErr
+		}
+	}
 }
 
 // Addr returns the address of the first available listener
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSortAddressesByPreference(t *testing.T) {
+	d := NewDualStackDialer(nil)
+	var addresses []AddressInfo
+	for _, ip := range []string{"2001:db8::1", "2001:db8::2", "192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		parsed := net.ParseIP(ip)
+		addresses = append(addresses, AddressInfo{IP: parsed, Priority: d.getAddressPriority(parsed)})
+	}
+
+	d.sortAddressesByPreference(addresses)
+
+	want := []string{"192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2", "192.0.2.3"}
+	for i, addr := range addresses {
+		if addr.IP.String() != want[i] {
+			t.Fatalf("order = %v, want %v", addresses, want)
+		}
+	}
+}
+
+// closedPort returns a loopback port nothing listens on
+func closedPort(t *testing.T) int {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestDialWithRacingSkipsFailedAddress(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	config := DefaultDualStackConfig()
+	config.HappyEyeballsDelay = 5 * time.Second
+	d := NewDualStackDialer(config)
+
+	loopback := net.ParseIP("127.0.0.1")
+	addresses := []AddressInfo{
+		{IP: loopback, Port: closedPort(t), Network: "tcp4"},
+		{IP: loopback, Port: ln.Addr().(*net.TCPAddr).Port, Network: "tcp4"},
+	}
+
+	// A refused attempt starts the next one without waiting out the delay
+	start := time.Now()
+	result, err := d.dialWithRacing(context.Background(), addresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Conn.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial took %v", elapsed)
+	}
+	if result.Address.Port != addresses[1].Port || result.Attempts != 2 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestDialDetailedFiltersFamily(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	result, err := NewDualStackDialer(nil).DialDetailed(context.Background(), "tcp4", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Conn.Close()
+	if result.Address.Family() != "IPv4" || result.Address.Network != "tcp4" {
+		t.Errorf("result = %+v", result)
+	}
+
+	if _, err := NewDualStackDialer(nil).DialDetailed(context.Background(), "tcp6", "127.0.0.1:"+port); err == nil {
+		t.Error("tcp6 dial to an IPv4 literal succeeded")
+	}
+}
+
+func TestDualStackListener(t *testing.T) {
+	ln := NewDualStackListener(nil)
+	if err := ln.Listen(":0"); err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	if addr, ok := ln.GetAddresses()["ipv6"]; ok && addr.(*net.TCPAddr).Port != port {
+		t.Errorf("IPv6 port %d, IPv4 port %d", addr.(*net.TCPAddr).Port, port)
+	}
+
+	client, err := net.Dial("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	ln.Close()
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept() after Close = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestDialerRetriesWithJitter(t *testing.T) {
+	config := DefaultDialerConfig()
+	config.MaxRetries = 2
+	config.InitialRetryDelay = 10 * time.Millisecond
+	config.CircuitBreakerThreshold = 1
+
+	var delays []time.Duration
+	config.OnRetry = func(attempt int, delay time.Duration, err error) {
+		delays = append(delays, delay)
+	}
+	d := NewDialer(config)
+
+	attempts := 0
+	d.SetDialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	})
+
+	address := "127.0.0.1:1"
+	if _, err := d.DialContext(context.Background(), "tcp", address); err == nil {
+		t.Fatal("dial succeeded")
+	}
+	if attempts != 3 || len(delays) != 2 {
+		t.Fatalf("%d attempts, %d retries", attempts, len(delays))
+	}
+	for i, delay := range delays {
+		base := config.InitialRetryDelay << i
+		if delay < base*8/10 || delay > base*12/10 {
+			t.Errorf("retry %d waited %v, want %v ± 20%%", i+1, delay, base)
+		}
+	}
+
+	// The failure opened the breaker, which fails fast
+	if _, err := d.DialContext(context.Background(), "tcp", address); err == nil || attempts != 3 {
+		t.Errorf("dial through open breaker = %v after %d attempts", err, attempts)
+	}
+}
@@ -3,11 +3,13 @@ package network
 import (
 	"context"
 	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ibrahmsql/gocat/internal/errors"
+	"github.com/ibrahmsql/gocat/internal/resolver"
 )
 
 // ConnectionPool interface defines the contract for connection pooling
@@ -33,7 +35,9 @@ type PoolStats struct {
 	CreatedAt          time.Time `json:"created_at"`
 }
 
-// PoolConfig holds configuration for connection pool
+// PoolConfig holds configuration for connection pool. MaxSize bounds the
+// connections to an address, in use or idle, with 0 meaning no bound;
+// MinSize is the number of idle connections kept open to each address.
 type PoolConfig struct {
 	MaxSize             int           `yaml:"max_size"`
 	MinSize             int           `yaml:"min_size"`
@@ -82,6 +86,14 @@ type ConnectionPoolImpl struct {
 	ctx              context.Context
 	cancel           context.CancelFunc
 	metricsCollector MetricsCollector
+	// refilling holds the addresses idle connections are being opened to
+	refilling map[string]bool
+}
+
+// AddressPoolStats holds the connections of the pool to one address
+type AddressPoolStats struct {
+	Active int `json:"active"`
+	Idle   int `json:"idle"`
 }
 
 // NewConnectionPool creates a new connection pool
@@ -99,8 +111,9 @@ func NewConnectionPool(config *PoolConfig, dialer *Dialer) *ConnectionPoolImpl {
 		stats: PoolStats{
 			CreatedAt: time.Now(),
 		},
-		ctx:    ctx,
-		cancel: cancel,
+		ctx:       ctx,
+		cancel:    cancel,
+		refilling: make(map[string]bool),
 	}
 
 	// Start background maintenance
@@ -127,6 +140,7 @@ func (p *ConnectionPoolImpl) Get(ctx context.Context, address string) (Connectio
 			})
 		}
 
+		p.refill(address)
 		return conn, nil
 	}
 
@@ -148,7 +162,7 @@ func (p *ConnectionPoolImpl) Get(ctx context.Context, address string) (Connectio
 	connCtx, cancel := context.WithTimeout(ctx, p.config.ConnectionTimeout)
 	defer cancel()
 
-	conn, err := p.dialer.Dial(connCtx, address)
+	conn, err := p.dial(connCtx, address)
 	if err != nil {
 		return nil, errors.WrapError(err, errors.ErrorTypeNetwork, errors.SeverityHigh, "NET032", "Failed to create pooled connection")
 	}
@@ -182,6 +196,7 @@ func (p *ConnectionPoolImpl) Get(ctx context.Context, address string) (Connectio
 		})
 	}
 
+	p.refill(address)
 	return conn, nil
 }
 
@@ -219,12 +234,14 @@ func (p *ConnectionPoolImpl) Put(conn Connection) error {
 		// Connection not from this pool, just close it
 		return conn.Close()
 	}
+	if !found.inUse {
+		// Returned twice
+		return nil
+	}
 
 	// Check if connection is still healthy and within lifetime limits
 	now := time.Now()
-	if !conn.IsHealthy() ||
-		now.Sub(found.createdAt) > p.config.MaxLifetime ||
-		now.Sub(found.lastUsed) > p.config.MaxIdleTime {
+	if !connAlive(conn) || now.Sub(found.createdAt) > p.config.MaxLifetime {
 
 		// Remove from pool and close
 		p.removeConnection(address, found)
@@ -247,7 +264,8 @@ func (p *ConnectionPoolImpl) Put(conn Connection) error {
 	atomic.AddInt64(&p.stats.ActiveConnections, -1)
 	atomic.AddInt64(&p.stats.IdleConnections, 1)
 
-	p.updateLastActivity()
+	// mu is held, so updateLastActivity would deadlock
+	p.stats.LastActivity = now
 
 	if p.metricsCollector != nil {
 		p.metricsCollector.IncrementCounter("connections_returned", map[string]string{
@@ -355,50 +373,57 @@ func (p *ConnectionPoolImpl) getFromPool(address string) Connection {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	conns, exists := p.connections[address]
-	if !exists || len(conns) == 0 {
-		return nil
-	}
+	// Find an available healthy connection, dropping the idle ones that
+	// expired or were closed by the peer on the way
+	now := time.Now()
+	for i := 0; i < len(p.connections[address]); i++ {
+		pc := p.connections[address][i]
+		if pc.inUse {
+			continue
+		}
+		if !pc.healthy || !connAlive(pc.conn) ||
+			now.Sub(pc.createdAt) > p.config.MaxLifetime ||
+			now.Sub(pc.lastUsed) > p.config.MaxIdleTime {
+			p.removeConnectionAtIndex(address, i)
+			atomic.AddInt64(&p.stats.ConnectionsExpired, 1)
+			pc.conn.Close()
+			// The last connection moved to i
+			i--
+			continue
+		}
 
-	// Find an available healthy connection
-	for i, pc := range conns {
-		if !pc.inUse && pc.healthy && pc.conn.IsHealthy() {
-			// Check if connection is still within limits
-			now := time.Now()
-			if now.Sub(pc.createdAt) <= p.config.MaxLifetime &&
-				now.Sub(pc.lastUsed) <= p.config.MaxIdleTime {
-
-				// Mark as in use
-				pc.inUse = true
-				pc.lastUsed = now
-				atomic.AddInt64(&pc.useCount, 1)
-				atomic.AddInt64(&p.stats.ActiveConnections, 1)
-				atomic.AddInt64(&p.stats.IdleConnections, -1)
+		// Mark as in use
+		pc.inUse = true
+		pc.lastUsed = now
+		atomic.AddInt64(&pc.useCount, 1)
+		atomic.AddInt64(&p.stats.ActiveConnections, 1)
+		atomic.AddInt64(&p.stats.IdleConnections, -1)
 
-				return pc.conn
-			} else {
-				// Connection expired, remove it
-				p.removeConnectionAtIndex(address, i)
-				atomic.AddInt64(&p.stats.ConnectionsExpired, 1)
-				pc.conn.Close()
-			}
-		}
+		return pc.conn
 	}
 
 	return nil
 }
 
+// connAlive reports whether a pooled connection can still carry data
+func connAlive(conn Connection) bool {
+	if !conn.IsHealthy() || conn.Context().Err() != nil {
+		return false
+	}
+	if u, ok := conn.(interface{ Unwrap() net.Conn }); ok {
+		if raw := u.Unwrap(); raw != nil {
+			return idleConnAlive(raw)
+		}
+	}
+	return true
+}
+
 // canCreateConnection checks if a new connection can be created
 func (p *ConnectionPoolImpl) canCreateConnection(address string) bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	conns := p.connections[address]
-	if len(conns) >= p.config.MaxSize {
-		return false
-	}
-
-	return true
+	return p.config.MaxSize <= 0 || len(p.connections[address]) < p.config.MaxSize
 }
 
 // removeConnection removes a specific connection from the pool
@@ -419,17 +444,158 @@ func (p *ConnectionPoolImpl) removeConnectionAtIndex(address string, index int)
 		return
 	}
 
-	// Remove from slice
-	conns[index] = conns[len(conns)-1]
-	conns = conns[:len(conns)-1]
-	p.connections[address] = conns
-
 	atomic.AddInt64(&p.stats.TotalConnections, -1)
 	if conns[index].inUse {
 		atomic.AddInt64(&p.stats.ActiveConnections, -1)
 	} else {
 		atomic.AddInt64(&p.stats.IdleConnections, -1)
 	}
+
+	// Remove from slice
+	conns[index] = conns[len(conns)-1]
+	conns[len(conns)-1] = nil
+	p.connections[address] = conns[:len(conns)-1]
+}
+
+// Discard closes a connection taken from the pool instead of returning it,
+// for connections left in an unknown state
+func (p *ConnectionPoolImpl) Discard(conn Connection) error {
+	p.mu.Lock()
+	for address, conns := range p.connections {
+		for _, pc := range conns {
+			if pc.conn.GetID() == conn.GetID() {
+				p.removeConnection(address, pc)
+				break
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	return conn.Close()
+}
+
+// Warm keeps MinSize idle connections open to address from now on, rather
+// than from its first Get
+func (p *ConnectionPoolImpl) Warm(address string) {
+	p.mu.Lock()
+	if _, exists := p.connections[address]; !exists && !p.closed {
+		p.connections[address] = make([]*pooledConnection, 0)
+	}
+	p.mu.Unlock()
+
+	p.refill(address)
+}
+
+// Flush closes the idle connections to address, which are likely dead when
+// the address stopped taking new ones
+func (p *ConnectionPoolImpl) Flush(address string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	flushed := 0
+	for i := 0; i < len(p.connections[address]); i++ {
+		pc := p.connections[address][i]
+		if pc.inUse {
+			continue
+		}
+		p.removeConnectionAtIndex(address, i)
+		pc.conn.Close()
+		flushed++
+		i--
+	}
+	return flushed
+}
+
+// Forget closes the idle connections to address and stops keeping any
+// open. Connections in use are closed when returned.
+func (p *ConnectionPoolImpl) Forget(address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pc := range p.connections[address] {
+		atomic.AddInt64(&p.stats.TotalConnections, -1)
+		if pc.inUse {
+			atomic.AddInt64(&p.stats.ActiveConnections, -1)
+		} else {
+			atomic.AddInt64(&p.stats.IdleConnections, -1)
+			pc.conn.Close()
+		}
+	}
+	delete(p.connections, address)
+}
+
+// AddressStats returns the connections of the pool to each address
+func (p *ConnectionPoolImpl) AddressStats() map[string]AddressPoolStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make(map[string]AddressPoolStats, len(p.connections))
+	for address, conns := range p.connections {
+		var s AddressPoolStats
+		for _, pc := range conns {
+			if pc.inUse {
+				s.Active++
+			} else {
+				s.Idle++
+			}
+		}
+		stats[address] = s
+	}
+	return stats
+}
+
+// refill opens idle connections to address in the background until there
+// are MinSize of them, unless that is already under way
+func (p *ConnectionPoolImpl) refill(address string) {
+	if p.config.MinSize <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || p.refilling[address] {
+		return
+	}
+	idle := 0
+	for _, pc := range p.connections[address] {
+		if !pc.inUse {
+			idle++
+		}
+	}
+	needed := p.config.MinSize - idle
+	if room := p.config.MaxSize - len(p.connections[address]); p.config.MaxSize > 0 && room < needed {
+		needed = room
+	}
+	if needed <= 0 {
+		return
+	}
+
+	p.refilling[address] = true
+	go func() {
+		p.createBackgroundConnections(address, needed)
+		p.mu.Lock()
+		delete(p.refilling, address)
+		p.mu.Unlock()
+	}()
+}
+
+// dial opens a connection to address through the dialer of the pool
+func (p *ConnectionPoolImpl) dial(ctx context.Context, address string) (Connection, error) {
+	if p.dialer != nil {
+		return p.dialer.Dial(ctx, address)
+	}
+
+	resolved, err := resolver.Default().ResolveAddr(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	rawConn, err := d.DialContext(ctx, "tcp", resolved)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnection(rawConn, "tcp"), nil
 }
 
 // isClosed checks if the pool is closed
@@ -479,20 +645,24 @@ func (p *ConnectionPoolImpl) performMaintenance() {
 		validConns := make([]*pooledConnection, 0, len(conns))
 
 		for _, pc := range conns {
-			shouldRemove := false
-
-			// Check if connection is expired
-			if now.Sub(pc.createdAt) > p.config.MaxLifetime {
-				shouldRemove = true
+			// Connections in use are the caller's until returned, unless
+			// the caller closed them instead
+			if pc.inUse {
+				if pc.conn.Context().Err() != nil {
+					atomic.AddInt64(&p.stats.ActiveConnections, -1)
+					atomic.AddInt64(&p.stats.TotalConnections, -1)
+				} else {
+					validConns = append(validConns, pc)
+				}
+				continue
 			}
 
-			// Check if idle connection is too old
-			if !pc.inUse && now.Sub(pc.lastUsed) > p.config.MaxIdleTime {
-				shouldRemove = true
-			}
+			// Check if connection is expired or idle for too long
+			shouldRemove := now.Sub(pc.createdAt) > p.config.MaxLifetime ||
+				now.Sub(pc.lastUsed) > p.config.MaxIdleTime
 
 			// Check health if enabled
-			if p.config.EnableHealthCheck && !pc.conn.IsHealthy() {
+			if p.config.EnableHealthCheck && !connAlive(pc.conn) {
 				shouldRemove = true
 				pc.healthy = false
 			}
@@ -500,12 +670,7 @@ func (p *ConnectionPoolImpl) performMaintenance() {
 			if shouldRemove {
 				expiredCount++
 				pc.conn.Close()
-
-				if pc.inUse {
-					atomic.AddInt64(&p.stats.ActiveConnections, -1)
-				} else {
-					atomic.AddInt64(&p.stats.IdleConnections, -1)
-				}
+				atomic.AddInt64(&p.stats.IdleConnections, -1)
 				atomic.AddInt64(&p.stats.TotalConnections, -1)
 			} else {
 				validConns = append(validConns, pc)
@@ -513,13 +678,6 @@ func (p *ConnectionPoolImpl) performMaintenance() {
 		}
 
 		p.connections[address] = validConns
-
-		// Ensure minimum connections if configured
-		if len(validConns) < p.config.MinSize && p.config.MinSize > 0 {
-			// Create new connections in background to maintain minimum pool size
-			needed := p.config.MinSize - len(validConns)
-			go p.createBackgroundConnections(address, needed)
-		}
 	}
 
 	if expiredCount > 0 {
@@ -527,49 +685,40 @@ func (p *ConnectionPoolImpl) performMaintenance() {
 
 		if p.metricsCollector != nil {
 			p.metricsCollector.IncrementCounter("maintenance_expired_connections", map[string]string{
-				"count": string(rune(expiredCount)),
+				"count": strconv.Itoa(expiredCount),
 			})
 		}
 	}
+
+	// Ensure minimum idle connections if configured
+	for address := range p.connections {
+		go p.refill(address)
+	}
 }
 
-// createBackgroundConnections creates new connections in the background to maintain minimum pool size
+// createBackgroundConnections creates new connections in the background to
+// maintain the minimum of idle connections. It stops at the first failure,
+// leaving the next attempt to the next checkout or maintenance cycle.
 func (p *ConnectionPoolImpl) createBackgroundConnections(address string, count int) {
 	for i := 0; i < count; i++ {
 		// Check if pool is closed
-		p.mu.RLock()
-		if p.closed {
-			p.mu.RUnlock()
+		if p.isClosed() {
 			return
 		}
-		p.mu.RUnlock()
 
 		// Create connection with timeout context
 		ctx, cancel := context.WithTimeout(p.ctx, p.config.ConnectionTimeout)
-		defer cancel()
-		
-		// Use dialer if available, otherwise use net.Dial
-		var conn Connection
-		var err error
-		if p.dialer != nil {
-			conn, err = p.dialer.Dial(ctx, address)
-		} else {
-			var rawConn net.Conn
-			rawConn, err = net.DialTimeout("tcp", address, p.config.ConnectionTimeout)
-			if err == nil {
-				conn = NewConnection(rawConn, "tcp")
-			}
-		}
+		conn, err := p.dial(ctx, address)
+		cancel()
 
 		if err != nil {
 			// Log error but don't fail - we'll retry on next maintenance cycle
 			if p.metricsCollector != nil {
 				p.metricsCollector.IncrementCounter("background_connection_failed", map[string]string{
 					"address": address,
-					"error":   err.Error(),
 				})
 			}
-			continue
+			return
 		}
 
 		// Add to pool
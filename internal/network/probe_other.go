@@ -0,0 +1,19 @@
+//go:build !unix
+
+package network
+
+import (
+	"net"
+	"os"
+)
+
+// idleConnAlive cannot peek at connections here; connections the peer has
+// closed are found by their first read
+func idleConnAlive(conn net.Conn) bool {
+	return true
+}
+
+// withUmask runs fn; there is no file mode creation mask to set here
+func withUmask(mask os.FileMode, fn func() error) error {
+	return fn()
+}
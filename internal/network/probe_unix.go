@@ -0,0 +1,53 @@
+//go:build unix
+
+package network
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// idleConnAlive peeks at an idle connection without consuming anything. A
+// connection the peer has closed reads end of stream; one with data
+// waiting, such as the greeting of a server, is still alive.
+func idleConnAlive(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return true
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	alive := true
+	var buf [1]byte
+	err = raw.Read(func(fd uintptr) bool {
+		n, _, err := unix.Recvfrom(int(fd), buf[:], unix.MSG_PEEK|unix.MSG_DONTWAIT)
+		switch {
+		case err == unix.EAGAIN || err == unix.EWOULDBLOCK:
+		case err != nil || n == 0:
+			alive = false
+		}
+		// Done without waiting for the socket to become readable
+		return true
+	})
+	return err == nil && alive
+}
+
+// umaskMu serializes umask changes, which apply to the whole process
+var umaskMu sync.Mutex
+
+// withUmask runs fn with the file mode creation mask set to mask, so files
+// and sockets fn creates never exist with looser permissions
+func withUmask(mask os.FileMode, fn func() error) error {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	old := unix.Umask(int(mask & os.ModePerm))
+	defer unix.Umask(old)
+	return fn()
+}
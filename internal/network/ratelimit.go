@@ -99,11 +99,18 @@ func parseRateString(rateStr string) (int64, error) {
 	return int64(value * float64(multiplier)), nil
 }
 
-// Wait waits for permission to transfer n bytes
+// Wait waits for permission to transfer n bytes. Transfers larger than the
+// burst are paced in burst-sized steps.
 func (rl *RateLimiter) Wait(ctx context.Context, n int) error {
 	if rl == nil {
 		return nil // No rate limiting
 	}
+	for n > rl.burst {
+		if err := rl.limiter.WaitN(ctx, rl.burst); err != nil {
+			return err
+		}
+		n -= rl.burst
+	}
 	return rl.limiter.WaitN(ctx, n)
 }
 
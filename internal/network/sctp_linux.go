@@ -0,0 +1,848 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+)
+
+// Sizes of the kernel structures exchanged with SCTP sockets
+const (
+	sizeofSndInfo    = 16  // struct sctp_sndinfo
+	sizeofRcvInfo    = 28  // struct sctp_rcvinfo
+	sizeofInitMsg    = 8   // struct sctp_initmsg
+	sizeofEvent      = 8   // struct sctp_event
+	sizeofStatus     = 176 // struct sctp_status
+	sizeofSockaddr4  = 16  // struct sockaddr_in
+	sizeofSockaddr6  = 28  // struct sockaddr_in6
+	sizeofSockaddrSt = 128 // struct sockaddr_storage
+)
+
+// maxSCTPAddrs bounds the addresses read of a multihomed endpoint
+const maxSCTPAddrs = 32
+
+// sctpEvents are the notifications subscribed to for OnNotification
+var sctpEvents = []uint16{SCTP_ASSOC_CHANGE, SCTP_PEER_ADDR_CHANGE, SCTP_SHUTDOWN_EVENT}
+
+// SCTPConn represents an SCTP connection. Its socket is non-blocking and
+// served by the runtime poller, so reads and writes park the goroutine
+// instead of a thread and deadlines interrupt them.
+type SCTPConn struct {
+	file   *os.File
+	rc     syscall.RawConn
+	laddr  *SCTPAddr
+	raddr  *SCTPAddr
+	config *SCTPConfig
+
+	readMu sync.Mutex
+	oob    []byte // ancillary data buffer of reads
+	notice []byte // notification read in parts
+}
+
+// newSCTPConn takes over the connected socket fd
+func newSCTPConn(fd int, config *SCTPConfig) (*SCTPConn, error) {
+	file := os.NewFile(uintptr(fd), "sctp")
+	rc, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	c := &SCTPConn{
+		file:   file,
+		rc:     rc,
+		config: config,
+		oob:    make([]byte, unix.CmsgSpace(sizeofRcvInfo)),
+	}
+	c.laddr = c.addrs(SCTP_GET_LOCAL_ADDRS, unix.Getsockname)
+	c.raddr = c.addrs(SCTP_GET_PEER_ADDRS, unix.Getpeername)
+	return c, nil
+}
+
+// addrs returns the addresses of one side of the association, falling
+// back to the primary one
+func (c *SCTPConn) addrs(opt int, primary func(int) (unix.Sockaddr, error)) *SCTPAddr {
+	var addr *SCTPAddr
+	c.rc.Control(func(fd uintptr) {
+		buf := make([]byte, 8+maxSCTPAddrs*sizeofSockaddr6)
+		if n, err := getsockoptBytes(int(fd), SOL_SCTP, opt, buf); err == nil && n >= 8 {
+			count := int(binary.NativeEndian.Uint32(buf[4:]))
+			addr = parseSockaddrs(buf[8:n], count)
+		}
+		if addr == nil {
+			if sa, err := primary(int(fd)); err == nil {
+				addr = sockaddrToSCTP(sa)
+			}
+		}
+	})
+	return addr
+}
+
+// Read reads data from SCTP connection. Notifications met on the way go to
+// OnNotification.
+func (c *SCTPConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadMsg(b)
+	return n, err
+}
+
+// ReadMsg reads a message, or the next part of one larger than b, with
+// the stream and PPID it came with
+func (c *SCTPConn) ReadMsg(b []byte) (int, *SCTPRcvInfo, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for {
+		var n, oobn, flags int
+		var opErr error
+		err := c.rc.Read(func(fd uintptr) bool {
+			n, oobn, flags, _, opErr = unix.Recvmsg(int(fd), b, c.oob, 0)
+			return opErr != unix.EAGAIN
+		})
+		if err == nil {
+			err = opErr
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if flags&MSG_NOTIFICATION != 0 {
+			c.notice = append(c.notice, b[:n]...)
+			if flags&unix.MSG_EOR != 0 {
+				c.notify(c.notice)
+				c.notice = c.notice[:0]
+			}
+			continue
+		}
+		if n == 0 && len(b) > 0 {
+			return 0, nil, io.EOF
+		}
+		return n, parseRcvInfo(c.oob[:oobn]), nil
+	}
+}
+
+// notify hands a notification to OnNotification
+func (c *SCTPConn) notify(b []byte) {
+	n, ok := parseSCTPNotification(b)
+	if !ok {
+		logger.Debug("Ignoring malformed SCTP notification of %d bytes", len(b))
+		return
+	}
+	if c.config.OnNotification != nil {
+		c.config.OnNotification(n)
+	}
+}
+
+// Write writes data to SCTP connection on the configured stream and PPID
+func (c *SCTPConn) Write(b []byte) (int, error) {
+	return c.WriteMsg(b, &SCTPSndInfo{Stream: c.config.Stream, PPID: c.config.PPID})
+}
+
+// WriteMsg sends b as one message on the stream and with the PPID of info
+func (c *SCTPConn) WriteMsg(b []byte, info *SCTPSndInfo) (int, error) {
+	var oob []byte
+	if info != nil && *info != (SCTPSndInfo{}) {
+		oob = marshalSndInfo(info)
+	}
+
+	var n int
+	var opErr error
+	err := c.rc.Write(func(fd uintptr) bool {
+		n, opErr = unix.SendmsgN(int(fd), b, oob, nil, 0)
+		return opErr != unix.EAGAIN
+	})
+	if err == nil {
+		err = opErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// CloseWrite starts a graceful shutdown of the association; reads go on
+// until the peer has shut down too
+func (c *SCTPConn) CloseWrite() error {
+	var opErr error
+	err := c.rc.Control(func(fd uintptr) {
+		opErr = unix.Shutdown(int(fd), unix.SHUT_WR)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
+
+// Close closes the SCTP connection
+func (c *SCTPConn) Close() error {
+	return c.file.Close()
+}
+
+// LocalAddr returns local address
+func (c *SCTPConn) LocalAddr() net.Addr {
+	if c.laddr == nil {
+		return nil
+	}
+	return c.laddr
+}
+
+// RemoteAddr returns remote address
+func (c *SCTPConn) RemoteAddr() net.Addr {
+	if c.raddr == nil {
+		return nil
+	}
+	return c.raddr
+}
+
+// SetDeadline sets read and write deadlines
+func (c *SCTPConn) SetDeadline(t time.Time) error {
+	return c.file.SetDeadline(t)
+}
+
+// SetReadDeadline sets read deadline
+func (c *SCTPConn) SetReadDeadline(t time.Time) error {
+	return c.file.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets write deadline
+func (c *SCTPConn) SetWriteDeadline(t time.Time) error {
+	return c.file.SetWriteDeadline(t)
+}
+
+// SyscallConn returns the raw connection for socket options
+func (c *SCTPConn) SyscallConn() (syscall.RawConn, error) {
+	return c.rc, nil
+}
+
+// GetSCTPInfo returns SCTP connection information
+func (c *SCTPConn) GetSCTPInfo() (*SCTPInfo, error) {
+	buf := make([]byte, sizeofStatus)
+	var opErr error
+	err := c.rc.Control(func(fd uintptr) {
+		_, opErr = getsockoptBytes(int(fd), SOL_SCTP, SCTP_STATUS, buf)
+	})
+	if err == nil {
+		err = opErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getsockopt SCTP_STATUS failed: %w", err)
+	}
+
+	info := parseStatus(buf)
+	info.Streams = c.config.Streams
+	info.LocalAddr = c.laddr
+	info.RemoteAddr = c.raddr
+	return info, nil
+}
+
+// SCTPListener represents an SCTP listener
+type SCTPListener struct {
+	file   *os.File
+	rc     syscall.RawConn
+	laddr  *SCTPAddr
+	config *SCTPConfig
+}
+
+// Accept accepts incoming SCTP connections
+func (l *SCTPListener) Accept() (net.Conn, error) {
+	return l.AcceptSCTP()
+}
+
+// AcceptSCTP accepts the next association
+func (l *SCTPListener) AcceptSCTP() (*SCTPConn, error) {
+	for {
+		var nfd int
+		var opErr error
+		err := l.rc.Read(func(fd uintptr) bool {
+			nfd, _, opErr = unix.Accept4(int(fd), unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC)
+			return opErr != unix.EAGAIN
+		})
+		if errors.Is(err, os.ErrClosed) {
+			return nil, net.ErrClosed
+		}
+		if err == nil {
+			err = opErr
+		}
+		if err == unix.ECONNABORTED {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := applySCTPOptions(nfd, l.config); err != nil {
+			unix.Close(nfd)
+			return nil, fmt.Errorf("failed to apply SCTP options: %w", err)
+		}
+		return newSCTPConn(nfd, l.config)
+	}
+}
+
+// Close closes the SCTP listener
+func (l *SCTPListener) Close() error {
+	return l.file.Close()
+}
+
+// Addr returns listener address
+func (l *SCTPListener) Addr() net.Addr {
+	return l.laddr
+}
+
+// SetDeadline sets the deadline of Accept
+func (l *SCTPListener) SetDeadline(t time.Time) error {
+	return l.file.SetReadDeadline(t)
+}
+
+// ListenSCTP creates and returns an SCTP listener bound to the given local
+// address and configured according to config.
+//
+// The network must be "sctp", "sctp4", or "sctp6". "sctp" auto-detects the
+// address family from laddr (defaults to IPv4 if indeterminate). If laddr is
+// nil or has no IPs, the listener is bound to all interfaces for the chosen
+// family; laddr.Port is used as the bind port. The listener is bound to
+// every IP of a multihomed laddr. If config is nil, DefaultSCTPConfig()
+// is used.
+func ListenSCTP(network string, laddr *SCTPAddr, config *SCTPConfig) (*SCTPListener, error) {
+	if config == nil {
+		config = DefaultSCTPConfig()
+	}
+	if laddr == nil {
+		laddr = &SCTPAddr{}
+	}
+
+	family, err := sctpFamily(network, laddr)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := newSCTPSocket(family, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set SO_REUSEADDR: %w", err)
+	}
+	if err := bindSCTP(fd, family, laddr); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	if err := unix.Listen(fd, unix.SOMAXCONN); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to listen on SCTP socket: %w", err)
+	}
+
+	file := os.NewFile(uintptr(fd), "sctp-listener")
+	rc, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	bound := laddr
+	if sa, err := unix.Getsockname(fd); err == nil {
+		if addr := sockaddrToSCTP(sa); addr != nil && len(laddr.IPs) <= 1 {
+			bound = addr
+		} else if addr != nil {
+			bound = &SCTPAddr{IPs: laddr.IPs, Port: addr.Port}
+		}
+	}
+	return &SCTPListener{file: file, rc: rc, laddr: bound, config: config}, nil
+}
+
+// DialSCTP dials an SCTP association to raddr from laddr using the specified network.
+// It is a convenience wrapper around DialSCTPTimeout with a zero timeout (blocking connect).
+// If config is nil, DefaultSCTPConfig is used.
+func DialSCTP(network string, laddr, raddr *SCTPAddr, config *SCTPConfig) (*SCTPConn, error) {
+	return DialSCTPContext(context.Background(), network, laddr, raddr, config)
+}
+
+// DialSCTPTimeout establishes an SCTP connection to raddr within the given
+// timeout; zero waits as long as the kernel does.
+func DialSCTPTimeout(network string, laddr, raddr *SCTPAddr, timeout time.Duration, config *SCTPConfig) (*SCTPConn, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return DialSCTPContext(ctx, network, laddr, raddr, config)
+}
+
+// DialSCTPContext establishes an SCTP association to raddr until ctx is
+// done. The network is "sctp", "sctp4" or "sctp6"; "sctp" takes the
+// family of raddr. The local side is bound to every IP of laddr, if
+// given, and the association is started with every IP of raddr, so both
+// ends can be multihomed.
+func DialSCTPContext(ctx context.Context, network string, laddr, raddr *SCTPAddr, config *SCTPConfig) (*SCTPConn, error) {
+	if config == nil {
+		config = DefaultSCTPConfig()
+	}
+	if raddr == nil || len(raddr.IPs) == 0 {
+		return nil, fmt.Errorf("remote address cannot be empty")
+	}
+
+	family, err := sctpFamily(network, raddr)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := newSCTPSocket(family, config)
+	if err != nil {
+		return nil, err
+	}
+	if laddr != nil && len(laddr.IPs) > 0 {
+		if err := bindSCTP(fd, family, laddr); err != nil {
+			unix.Close(fd)
+			return nil, err
+		}
+	}
+
+	peers := packSockaddrs(raddr.IPs, raddr.Port, family)
+	if len(peers) == 0 {
+		unix.Close(fd)
+		return nil, fmt.Errorf("no %s address in %s", familyName(family), raddr)
+	}
+	// CONNECTX starts the association with all addresses of the peer
+	_, err = setsockoptBytes(fd, SOL_SCTP, SCTP_SOCKOPT_CONNECTX, peers)
+	if err != nil && err != unix.EINPROGRESS {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	conn, err := newSCTPConn(fd, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.waitConnected(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The association knows all addresses now
+	conn.laddr = conn.addrs(SCTP_GET_LOCAL_ADDRS, unix.Getsockname)
+	conn.raddr = conn.addrs(SCTP_GET_PEER_ADDRS, unix.Getpeername)
+	return conn, nil
+}
+
+// waitConnected waits on the poller until the association is up or ctx is
+// done
+func (c *SCTPConn) waitConnected(ctx context.Context) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.file.SetWriteDeadline(deadline)
+		defer c.file.SetWriteDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Expiring the deadline wakes the waiting write
+		c.file.SetWriteDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	var connErr error
+	err := c.rc.Write(func(fd uintptr) bool {
+		soErr, err := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_ERROR)
+		if err != nil {
+			connErr = err
+			return true
+		}
+		switch errno := unix.Errno(soErr); errno {
+		case 0:
+		case unix.EINPROGRESS, unix.EALREADY, unix.EINTR:
+			return false
+		default:
+			connErr = errno
+			return true
+		}
+		// No error yet: connected once the peer is known
+		if _, err := unix.Getpeername(int(fd)); err == unix.ENOTCONN {
+			return false
+		} else if err != nil {
+			connErr = err
+		}
+		return true
+	})
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		if ctx.Err() == context.Canceled {
+			return fmt.Errorf("failed to connect: %w", ctx.Err())
+		}
+		return errors.New("connection timeout")
+	}
+	if err == nil {
+		err = connErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	return nil
+}
+
+// IsSCTPSupported reports whether the platform appears to support SCTP by
+// attempting to create a basic SCTP socket. It returns true if socket
+// creation succeeds and false otherwise; it does not guarantee full SCTP
+// feature availability beyond the ability to open a socket.
+func IsSCTPSupported() bool {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, IPPROTO_SCTP)
+	if err != nil {
+		return false
+	}
+	unix.Close(fd)
+	return true
+}
+
+// sctpFamily returns the address family of network for addr
+func sctpFamily(network string, addr *SCTPAddr) (int, error) {
+	switch network {
+	case "sctp4":
+		return unix.AF_INET, nil
+	case "sctp6":
+		return unix.AF_INET6, nil
+	case "sctp":
+		if len(addr.IPs) == 0 {
+			return unix.AF_INET, nil
+		}
+		for _, ip := range addr.IPs {
+			if ip.To4() == nil {
+				return unix.AF_INET6, nil
+			}
+		}
+		return unix.AF_INET, nil
+	default:
+		return 0, fmt.Errorf("unsupported network: %s", network)
+	}
+}
+
+func familyName(family int) string {
+	if family == unix.AF_INET6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// newSCTPSocket creates a non-blocking SCTP socket set up by config
+func newSCTPSocket(family int, config *SCTPConfig) (int, error) {
+	fd, err := unix.Socket(family, unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, IPPROTO_SCTP)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create SCTP socket: %w", err)
+	}
+	if err := setInitMsg(fd, config); err != nil {
+		logger.Warn("Failed to set SCTP_INITMSG: %v", err)
+	}
+	if err := applySCTPOptions(fd, config); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// setInitMsg sets the streams and init limits of new associations
+func setInitMsg(fd int, config *SCTPConfig) error {
+	msg := make([]byte, sizeofInitMsg)
+	binary.NativeEndian.PutUint16(msg[0:], clampUint16(config.Streams))
+	binary.NativeEndian.PutUint16(msg[2:], clampUint16(config.Streams))
+	binary.NativeEndian.PutUint16(msg[4:], clampUint16(config.MaxAttempts))
+	binary.NativeEndian.PutUint16(msg[6:], clampUint16(int(config.MaxInitTimeout/time.Millisecond)))
+	_, err := setsockoptBytes(fd, SOL_SCTP, SCTP_INITMSG, msg)
+	return err
+}
+
+// applySCTPOptions applies the per-connection options of config to fd
+func applySCTPOptions(fd int, config *SCTPConfig) error {
+	if config.Nodelay {
+		if err := unix.SetsockoptInt(fd, SOL_SCTP, SCTP_NODELAY, 1); err != nil {
+			logger.Warn("Failed to set SCTP_NODELAY: %v", err)
+		}
+	}
+
+	if config.AutoClose > 0 {
+		autoCloseSeconds := int(config.AutoClose.Seconds())
+		if err := unix.SetsockoptInt(fd, SOL_SCTP, SCTP_AUTOCLOSE, autoCloseSeconds); err != nil {
+			logger.Warn("Failed to set SCTP_AUTOCLOSE: %v", err)
+		}
+	}
+
+	// Reads report the stream and PPID of each message
+	if err := unix.SetsockoptInt(fd, SOL_SCTP, SCTP_RECVRCVINFO, 1); err != nil {
+		return fmt.Errorf("failed to enable SCTP_RECVRCVINFO: %w", err)
+	}
+
+	if config.OnNotification != nil {
+		for _, event := range sctpEvents {
+			if _, err := setsockoptBytes(fd, SOL_SCTP, SCTP_EVENT, marshalEvent(event, true)); err != nil {
+				return fmt.Errorf("failed to subscribe to SCTP event 0x%04x: %w", event, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bindSCTP binds fd to the first IP of addr and adds the others
+func bindSCTP(fd, family int, addr *SCTPAddr) error {
+	ips := addr.IPs
+	if len(ips) == 0 {
+		if family == unix.AF_INET6 {
+			ips = []net.IP{net.IPv6zero}
+		} else {
+			ips = []net.IP{net.IPv4zero}
+		}
+	}
+	packed := packSockaddrs(ips, addr.Port, family)
+	if len(packed) == 0 {
+		return fmt.Errorf("no %s address in %s", familyName(family), addr)
+	}
+
+	// The first address is bound as usual; BINDX adds the others with the
+	// port picked for it
+	first := sizeofSockaddr4
+	if family == unix.AF_INET6 {
+		first = sizeofSockaddr6
+	}
+	if _, err := setsockoptBytes(fd, SOL_SCTP, SCTP_SOCKOPT_BINDX_ADD, packed[:first]); err != nil {
+		return fmt.Errorf("failed to bind SCTP socket: %w", err)
+	}
+	if len(packed) > first {
+		port := addr.Port
+		if sa, err := unix.Getsockname(fd); err == nil {
+			if bound := sockaddrToSCTP(sa); bound != nil {
+				port = bound.Port
+			}
+		}
+		rest := packSockaddrs(ips, port, family)[first:]
+		if _, err := setsockoptBytes(fd, SOL_SCTP, SCTP_SOCKOPT_BINDX_ADD, rest); err != nil {
+			return fmt.Errorf("failed to bind additional SCTP addresses: %w", err)
+		}
+	}
+	return nil
+}
+
+// packSockaddrs packs the IPs of family into the sockaddr array taken by
+// BINDX and CONNECTX. IPv4 addresses are mapped for IPv6 sockets; IPv6
+// ones are left out of IPv4 sockets.
+func packSockaddrs(ips []net.IP, port, family int) []byte {
+	var buf []byte
+	for _, ip := range ips {
+		if family == unix.AF_INET {
+			ip4 := ip.To4()
+			if ip4 == nil {
+				continue
+			}
+			sa := make([]byte, sizeofSockaddr4)
+			binary.NativeEndian.PutUint16(sa[0:], unix.AF_INET)
+			binary.BigEndian.PutUint16(sa[2:], uint16(port))
+			copy(sa[4:8], ip4)
+			buf = append(buf, sa...)
+			continue
+		}
+		sa := make([]byte, sizeofSockaddr6)
+		binary.NativeEndian.PutUint16(sa[0:], unix.AF_INET6)
+		binary.BigEndian.PutUint16(sa[2:], uint16(port))
+		copy(sa[8:24], ip.To16())
+		buf = append(buf, sa...)
+	}
+	return buf
+}
+
+// parseSockaddrs reads count packed sockaddrs as returned by
+// SCTP_GET_LOCAL_ADDRS and SCTP_GET_PEER_ADDRS
+func parseSockaddrs(buf []byte, count int) *SCTPAddr {
+	addr := &SCTPAddr{}
+	for i := 0; i < count && len(buf) >= 4; i++ {
+		ip, port, size := parseSockaddr(buf)
+		if size == 0 {
+			break
+		}
+		addr.IPs = append(addr.IPs, ip)
+		addr.Port = port
+		buf = buf[size:]
+	}
+	if len(addr.IPs) == 0 {
+		return nil
+	}
+	return addr
+}
+
+// parseSockaddr reads a sockaddr_in or sockaddr_in6, returning its size,
+// or 0 when it is neither
+func parseSockaddr(b []byte) (net.IP, int, int) {
+	if len(b) < 4 {
+		return nil, 0, 0
+	}
+	port := int(binary.BigEndian.Uint16(b[2:]))
+	switch binary.NativeEndian.Uint16(b) {
+	case unix.AF_INET:
+		if len(b) < sizeofSockaddr4 {
+			return nil, 0, 0
+		}
+		return net.IPv4(b[4], b[5], b[6], b[7]), port, sizeofSockaddr4
+	case unix.AF_INET6:
+		if len(b) < sizeofSockaddr6 {
+			return nil, 0, 0
+		}
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, b[8:24])
+		return ip, port, sizeofSockaddr6
+	}
+	return nil, 0, 0
+}
+
+// sockaddrToSCTP converts a socket address to an SCTPAddr
+func sockaddrToSCTP(sa unix.Sockaddr) *SCTPAddr {
+	switch s := sa.(type) {
+	case *unix.SockaddrInet4:
+		return &SCTPAddr{IPs: []net.IP{net.IPv4(s.Addr[0], s.Addr[1], s.Addr[2], s.Addr[3])}, Port: s.Port}
+	case *unix.SockaddrInet6:
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, s.Addr[:])
+		return &SCTPAddr{IPs: []net.IP{ip}, Port: s.Port}
+	}
+	return nil
+}
+
+// marshalSndInfo encodes info as an SCTP_SNDINFO control message. The PPID
+// is opaque to the kernel and goes on the wire as stored, in network order.
+func marshalSndInfo(info *SCTPSndInfo) []byte {
+	oob := make([]byte, unix.CmsgSpace(sizeofSndInfo))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = SOL_SCTP
+	h.Type = SCTP_SNDINFO
+	h.SetLen(unix.CmsgLen(sizeofSndInfo))
+
+	data := oob[unix.CmsgLen(0):]
+	binary.NativeEndian.PutUint16(data[0:], info.Stream)
+	binary.NativeEndian.PutUint16(data[2:], info.Flags)
+	binary.BigEndian.PutUint32(data[4:], info.PPID)
+	binary.NativeEndian.PutUint32(data[8:], info.Context)
+	return oob
+}
+
+// parseRcvInfo decodes the SCTP_RCVINFO control message of a read
+func parseRcvInfo(oob []byte) *SCTPRcvInfo {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+	for _, m := range msgs {
+		if m.Header.Level != SOL_SCTP || m.Header.Type != SCTP_RCVINFO || len(m.Data) < sizeofRcvInfo {
+			continue
+		}
+		d := m.Data
+		return &SCTPRcvInfo{
+			Stream:  binary.NativeEndian.Uint16(d[0:]),
+			SSN:     binary.NativeEndian.Uint16(d[2:]),
+			Flags:   binary.NativeEndian.Uint16(d[4:]),
+			PPID:    binary.BigEndian.Uint32(d[8:]),
+			TSN:     binary.NativeEndian.Uint32(d[12:]),
+			CumTSN:  binary.NativeEndian.Uint32(d[16:]),
+			Context: binary.NativeEndian.Uint32(d[20:]),
+			AssocID: int32(binary.NativeEndian.Uint32(d[24:])),
+		}
+	}
+	return nil
+}
+
+// marshalEvent encodes a struct sctp_event subscribing to event
+func marshalEvent(event uint16, on bool) []byte {
+	buf := make([]byte, sizeofEvent)
+	binary.NativeEndian.PutUint16(buf[4:], event)
+	if on {
+		buf[6] = 1
+	}
+	return buf
+}
+
+// parseSCTPNotification decodes a notification read from the socket
+func parseSCTPNotification(b []byte) (SCTPNotification, bool) {
+	if len(b) < 8 {
+		return SCTPNotification{}, false
+	}
+	n := SCTPNotification{Type: binary.NativeEndian.Uint16(b)}
+	switch n.Type {
+	case SCTP_ASSOC_CHANGE:
+		// struct sctp_assoc_change
+		if len(b) < 20 {
+			return n, false
+		}
+		n.State = int(binary.NativeEndian.Uint16(b[8:]))
+		n.Error = int(binary.NativeEndian.Uint16(b[10:]))
+		n.OutboundStreams = int(binary.NativeEndian.Uint16(b[12:]))
+		n.InboundStreams = int(binary.NativeEndian.Uint16(b[14:]))
+		n.AssocID = int32(binary.NativeEndian.Uint32(b[16:]))
+	case SCTP_PEER_ADDR_CHANGE:
+		// struct sctp_paddr_change, packed
+		const state = 8 + sizeofSockaddrSt
+		if len(b) < state+12 {
+			return n, false
+		}
+		n.Addr, _, _ = parseSockaddr(b[8:state])
+		n.State = int(int32(binary.NativeEndian.Uint32(b[state:])))
+		n.Error = int(int32(binary.NativeEndian.Uint32(b[state+4:])))
+		n.AssocID = int32(binary.NativeEndian.Uint32(b[state+8:]))
+	case SCTP_SHUTDOWN_EVENT:
+		// struct sctp_shutdown_event
+		if len(b) < 12 {
+			return n, false
+		}
+		n.AssocID = int32(binary.NativeEndian.Uint32(b[8:]))
+	}
+	return n, true
+}
+
+// parseStatus decodes struct sctp_status
+func parseStatus(b []byte) *SCTPInfo {
+	states := []string{"EMPTY", "CLOSED", "COOKIE_WAIT", "COOKIE_ECHOED", "ESTABLISHED",
+		"SHUTDOWN_PENDING", "SHUTDOWN_SENT", "SHUTDOWN_RECEIVED", "SHUTDOWN_ACK_SENT"}
+
+	info := &SCTPInfo{State: "UNKNOWN"}
+	if state := int(int32(binary.NativeEndian.Uint32(b[4:]))); state >= 0 && state < len(states) {
+		info.State = states[state]
+	}
+	info.UnackedData = int(binary.NativeEndian.Uint16(b[12:]))
+	info.InboundStreams = int(binary.NativeEndian.Uint16(b[16:]))
+	info.OutboundStreams = int(binary.NativeEndian.Uint16(b[18:]))
+
+	// struct sctp_paddrinfo of the primary path follows at 24, packed
+	const primary = 24 + 4 + sizeofSockaddrSt
+	info.RTO = time.Duration(binary.NativeEndian.Uint32(b[primary+12:])) * time.Millisecond
+	info.MTU = int(binary.NativeEndian.Uint32(b[primary+16:]))
+	return info
+}
+
+// getsockoptBytes reads a socket option into buf and returns its length
+func getsockoptBytes(fd, level, opt int, buf []byte) (int, error) {
+	n := uint32(len(buf))
+	_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), uintptr(level), uintptr(opt),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&n)), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// setsockoptBytes sets a socket option from buf and returns what the call
+// returned, which is the association ID for CONNECTX
+func setsockoptBytes(fd, level, opt int, buf []byte) (int, error) {
+	r, _, errno := unix.Syscall6(unix.SYS_SETSOCKOPT, uintptr(fd), uintptr(level), uintptr(opt),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+func clampUint16(v int) uint16 {
+	if v < 0 {
+		return 0
+	}
+	if v > 0xffff {
+		return 0xffff
+	}
+	return uint16(v)
+}
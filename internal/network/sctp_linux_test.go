@@ -0,0 +1,199 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestResolveSCTPAddrMultihomed(t *testing.T) {
+	addr, err := ResolveSCTPAddr("sctp", "10.0.0.1,10.0.1.1:3868")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addr.IPs) != 2 || addr.Port != 3868 {
+		t.Fatalf("ResolveSCTPAddr() = %v", addr)
+	}
+	if got := addr.String(); got != "10.0.0.1,10.0.1.1:3868" {
+		t.Errorf("String() = %q", got)
+	}
+
+	addr, err = ResolveSCTPAddr("sctp6", "[fd00::1,fd00::2]:2905")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addr.IPs) != 2 || !addr.IPs[1].Equal(net.ParseIP("fd00::2")) {
+		t.Errorf("ResolveSCTPAddr() = %v", addr)
+	}
+}
+
+func TestPackSockaddrs(t *testing.T) {
+	ips := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")}
+
+	v4 := packSockaddrs(ips, 2905, unix.AF_INET)
+	if len(v4) != sizeofSockaddr4 {
+		t.Fatalf("IPv4 socket got %d bytes of addresses", len(v4))
+	}
+	addr := parseSockaddrs(v4, 1)
+	if addr == nil || !addr.IPs[0].Equal(ips[0]) || addr.Port != 2905 {
+		t.Errorf("round trip = %v", addr)
+	}
+
+	// IPv4 addresses are mapped on IPv6 sockets
+	v6 := packSockaddrs(ips, 2905, unix.AF_INET6)
+	if len(v6) != 2*sizeofSockaddr6 {
+		t.Fatalf("IPv6 socket got %d bytes of addresses", len(v6))
+	}
+	addr = parseSockaddrs(v6, 2)
+	if addr == nil || len(addr.IPs) != 2 || !addr.IPs[0].Equal(ips[0]) || !addr.IPs[1].Equal(ips[1]) {
+		t.Errorf("round trip = %v", addr)
+	}
+}
+
+func TestSCTPInfoControlMessages(t *testing.T) {
+	oob := marshalSndInfo(&SCTPSndInfo{Stream: 3, PPID: 46})
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("ParseSocketControlMessage() = %v, %v", msgs, err)
+	}
+	if h := msgs[0].Header; h.Level != SOL_SCTP || h.Type != SCTP_SNDINFO {
+		t.Errorf("header = %+v", h)
+	}
+	if ppid := binary.BigEndian.Uint32(msgs[0].Data[4:]); ppid != 46 {
+		t.Errorf("PPID on the wire = %d", ppid)
+	}
+
+	// A received message reads back in the layout of struct sctp_rcvinfo
+	rcv := make([]byte, unix.CmsgSpace(sizeofRcvInfo))
+	copy(rcv, oob[:unix.CmsgLen(0)])
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&rcv[0]))
+	h.Type = SCTP_RCVINFO
+	h.SetLen(unix.CmsgLen(sizeofRcvInfo))
+	data := rcv[unix.CmsgLen(0):]
+	binary.NativeEndian.PutUint16(data[0:], 3)
+	binary.NativeEndian.PutUint16(data[2:], 7)
+	binary.BigEndian.PutUint32(data[8:], 46)
+	binary.NativeEndian.PutUint32(data[24:], 9)
+
+	info := parseRcvInfo(rcv)
+	if info == nil || info.Stream != 3 || info.SSN != 7 || info.PPID != 46 || info.AssocID != 9 {
+		t.Errorf("parseRcvInfo() = %+v", info)
+	}
+}
+
+func TestParseSCTPNotification(t *testing.T) {
+	change := make([]byte, 20)
+	binary.NativeEndian.PutUint16(change[0:], SCTP_ASSOC_CHANGE)
+	binary.NativeEndian.PutUint32(change[4:], 20)
+	binary.NativeEndian.PutUint16(change[8:], SCTP_COMM_UP)
+	binary.NativeEndian.PutUint16(change[12:], 4)
+	binary.NativeEndian.PutUint16(change[14:], 5)
+	binary.NativeEndian.PutUint32(change[16:], 1)
+
+	n, ok := parseSCTPNotification(change)
+	if !ok || n.State != SCTP_COMM_UP || n.OutboundStreams != 4 || n.InboundStreams != 5 || n.AssocID != 1 {
+		t.Fatalf("parseSCTPNotification() = %+v, %v", n, ok)
+	}
+	if got := n.String(); got != "association up (5 inbound, 4 outbound streams)" {
+		t.Errorf("String() = %q", got)
+	}
+
+	paddr := make([]byte, 8+sizeofSockaddrSt+12)
+	binary.NativeEndian.PutUint16(paddr[0:], SCTP_PEER_ADDR_CHANGE)
+	copy(paddr[8:], packSockaddrs([]net.IP{net.ParseIP("10.0.1.1")}, 3868, unix.AF_INET))
+	binary.NativeEndian.PutUint32(paddr[8+sizeofSockaddrSt:], SCTP_ADDR_UNREACHABLE)
+
+	n, ok = parseSCTPNotification(paddr)
+	if !ok || !n.Addr.Equal(net.ParseIP("10.0.1.1")) || n.State != SCTP_ADDR_UNREACHABLE {
+		t.Fatalf("parseSCTPNotification() = %+v, %v", n, ok)
+	}
+	if got := n.String(); got != "peer address 10.0.1.1 unreachable" {
+		t.Errorf("String() = %q", got)
+	}
+
+	if _, ok := parseSCTPNotification(change[:6]); ok {
+		t.Error("short notification parsed")
+	}
+}
+
+func TestSCTPLoopback(t *testing.T) {
+	if !IsSCTPSupported() {
+		t.Skip("SCTP not supported by this kernel")
+	}
+
+	notes := make(chan SCTPNotification, 16)
+	config := DefaultSCTPConfig()
+	config.OnNotification = func(n SCTPNotification) { notes <- n }
+
+	ln, err := ListenSCTP("sctp4", &SCTPAddr{IPs: []net.IP{net.IPv4(127, 0, 0, 1)}}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*SCTPAddr).Port
+
+	accepted := make(chan *SCTPConn, 1)
+	go func() {
+		if c, err := ln.AcceptSCTP(); err == nil {
+			accepted <- c
+		}
+	}()
+
+	client, err := DialSCTPTimeout("sctp4", nil, &SCTPAddr{IPs: []net.IP{net.IPv4(127, 0, 0, 1)}, Port: port}, 2*time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server := <-accepted
+	defer server.Close()
+
+	if _, err := client.WriteMsg([]byte("diameter"), &SCTPSndInfo{Stream: 2, PPID: 46}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, info, err := server.ReadMsg(buf)
+	if err != nil || string(buf[:n]) != "diameter" {
+		t.Fatalf("ReadMsg() = %q, %v", buf[:n], err)
+	}
+	if info == nil || info.Stream != 2 || info.PPID != 46 {
+		t.Errorf("rcvinfo = %+v", info)
+	}
+
+	// A deadline interrupts a blocked read
+	server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := server.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read() = %v, want deadline exceeded", err)
+	}
+
+	select {
+	case n := <-notes:
+		if n.Type != SCTP_ASSOC_CHANGE || n.State != SCTP_COMM_UP {
+			t.Errorf("first notification = %v", n)
+		}
+	default:
+		t.Error("no association notification")
+	}
+}
+
+func TestDialSCTPContextCanceled(t *testing.T) {
+	if !IsSCTPSupported() {
+		t.Skip("SCTP not supported by this kernel")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// TEST-NET-1 never answers
+	_, err := DialSCTPContext(ctx, "sctp4", nil, &SCTPAddr{IPs: []net.IP{net.IPv4(192, 0, 2, 1)}, Port: 2905}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DialSCTPContext() = %v, want canceled", err)
+	}
+}
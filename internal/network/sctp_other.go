@@ -0,0 +1,99 @@
+//go:build !linux
+
+package network
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+)
+
+// SCTPConn represents an SCTP connection. SCTP is only available on Linux.
+type SCTPConn struct{}
+
+// Read reads data from SCTP connection
+func (c *SCTPConn) Read(b []byte) (int, error) { return 0, ErrSCTPUnsupported }
+
+// ReadMsg reads a message with the stream and PPID it came with
+func (c *SCTPConn) ReadMsg(b []byte) (int, *SCTPRcvInfo, error) {
+	return 0, nil, ErrSCTPUnsupported
+}
+
+// Write writes data to SCTP connection
+func (c *SCTPConn) Write(b []byte) (int, error) { return 0, ErrSCTPUnsupported }
+
+// WriteMsg sends b as one message on the stream and with the PPID of info
+func (c *SCTPConn) WriteMsg(b []byte, info *SCTPSndInfo) (int, error) {
+	return 0, ErrSCTPUnsupported
+}
+
+// CloseWrite starts a graceful shutdown of the association
+func (c *SCTPConn) CloseWrite() error { return ErrSCTPUnsupported }
+
+// Close closes the SCTP connection
+func (c *SCTPConn) Close() error { return ErrSCTPUnsupported }
+
+// LocalAddr returns local address
+func (c *SCTPConn) LocalAddr() net.Addr { return nil }
+
+// RemoteAddr returns remote address
+func (c *SCTPConn) RemoteAddr() net.Addr { return nil }
+
+// SetDeadline sets read and write deadlines
+func (c *SCTPConn) SetDeadline(t time.Time) error { return ErrSCTPUnsupported }
+
+// SetReadDeadline sets read deadline
+func (c *SCTPConn) SetReadDeadline(t time.Time) error { return ErrSCTPUnsupported }
+
+// SetWriteDeadline sets write deadline
+func (c *SCTPConn) SetWriteDeadline(t time.Time) error { return ErrSCTPUnsupported }
+
+// SyscallConn returns the raw connection for socket options
+func (c *SCTPConn) SyscallConn() (syscall.RawConn, error) { return nil, ErrSCTPUnsupported }
+
+// GetSCTPInfo returns SCTP connection information
+func (c *SCTPConn) GetSCTPInfo() (*SCTPInfo, error) { return nil, ErrSCTPUnsupported }
+
+// SCTPListener represents an SCTP listener
+type SCTPListener struct{}
+
+// Accept accepts incoming SCTP connections
+func (l *SCTPListener) Accept() (net.Conn, error) { return nil, ErrSCTPUnsupported }
+
+// AcceptSCTP accepts the next association
+func (l *SCTPListener) AcceptSCTP() (*SCTPConn, error) { return nil, ErrSCTPUnsupported }
+
+// Close closes the SCTP listener
+func (l *SCTPListener) Close() error { return ErrSCTPUnsupported }
+
+// Addr returns listener address
+func (l *SCTPListener) Addr() net.Addr { return nil }
+
+// SetDeadline sets the deadline of Accept
+func (l *SCTPListener) SetDeadline(t time.Time) error { return ErrSCTPUnsupported }
+
+// ListenSCTP creates an SCTP listener; SCTP is only available on Linux
+func ListenSCTP(network string, laddr *SCTPAddr, config *SCTPConfig) (*SCTPListener, error) {
+	return nil, ErrSCTPUnsupported
+}
+
+// DialSCTP dials an SCTP association; SCTP is only available on Linux
+func DialSCTP(network string, laddr, raddr *SCTPAddr, config *SCTPConfig) (*SCTPConn, error) {
+	return nil, ErrSCTPUnsupported
+}
+
+// DialSCTPTimeout dials an SCTP association; SCTP is only available on Linux
+func DialSCTPTimeout(network string, laddr, raddr *SCTPAddr, timeout time.Duration, config *SCTPConfig) (*SCTPConn, error) {
+	return nil, ErrSCTPUnsupported
+}
+
+// DialSCTPContext dials an SCTP association; SCTP is only available on Linux
+func DialSCTPContext(ctx context.Context, network string, laddr, raddr *SCTPAddr, config *SCTPConfig) (*SCTPConn, error) {
+	return nil, ErrSCTPUnsupported
+}
+
+// IsSCTPSupported reports whether the platform supports SCTP
+func IsSCTPSupported() bool {
+	return false
+}
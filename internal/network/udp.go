@@ -0,0 +1,350 @@
+package network
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// MaxDatagramSize is the largest UDP payload. Writes to a UDP session are
+// split into datagrams of at most this size.
+const MaxDatagramSize = 65507
+
+// Defaults of UDPListenerConfig
+const (
+	DefaultUDPBacklog = 16
+	udpSessionQueue   = 64
+)
+
+// UDPListenerConfig configures a UDPListener
+type UDPListenerConfig struct {
+	IdleTimeout time.Duration       // end sessions without traffic for this long, 0 keeps them
+	Backlog     int                 // new sessions waiting for Accept
+	Allow       func(net.Addr) bool // filters new peers, nil allows all
+}
+
+// DefaultUDPListenerConfig returns a configuration keeping sessions until
+// they are closed
+func DefaultUDPListenerConfig() *UDPListenerConfig {
+	return &UDPListenerConfig{Backlog: DefaultUDPBacklog}
+}
+
+// UDPListener turns the peers of a UDP socket into pseudo-sessions. The
+// first datagram of a new peer starts a session returned by Accept; later
+// datagrams of that peer are read from the session.
+type UDPListener struct {
+	conn   net.PacketConn
+	config UDPListenerConfig
+
+	mu       sync.Mutex
+	sessions map[string]*UDPSession
+	closing  bool // no new sessions, the socket closes with the last one
+
+	accept    chan *UDPSession
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ListenUDP listens for UDP sessions on address
+func ListenUDP(network, address string, config *UDPListenerConfig) (*UDPListener, error) {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewUDPListener(conn, config), nil
+}
+
+// NewUDPListener serves sessions on conn, which it takes over
+func NewUDPListener(conn net.PacketConn, config *UDPListenerConfig) *UDPListener {
+	if config == nil {
+		config = DefaultUDPListenerConfig()
+	}
+	l := &UDPListener{
+		conn:     conn,
+		config:   *config,
+		sessions: make(map[string]*UDPSession),
+		done:     make(chan struct{}),
+	}
+	if l.config.Backlog <= 0 {
+		l.config.Backlog = DefaultUDPBacklog
+	}
+	l.accept = make(chan *UDPSession, l.config.Backlog)
+	go l.readLoop()
+	return l
+}
+
+// readLoop hands the datagrams of the socket to their sessions
+func (l *UDPListener) readLoop() {
+	defer l.shutdown()
+
+	buf := make([]byte, MaxDatagramSize+1)
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if s := l.session(addr); s != nil {
+			s.deliver(append([]byte(nil), buf[:n]...))
+		}
+	}
+}
+
+// session returns the session of addr, starting one for a new peer. It
+// returns nil when the datagram is dropped.
+func (l *UDPListener) session(addr net.Addr) *UDPSession {
+	key := addr.String()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.sessions[key]; ok {
+		return s
+	}
+	if l.closing || (l.config.Allow != nil && !l.config.Allow(addr)) {
+		return nil
+	}
+
+	s := newUDPSession(l, addr)
+	if l.config.IdleTimeout > 0 {
+		s.idle = time.AfterFunc(l.config.IdleTimeout, func() { s.Close() })
+	}
+	select {
+	case l.accept <- s:
+	default:
+		// Backlog full: drop the peer, its next datagram tries again
+		if s.idle != nil {
+			s.idle.Stop()
+		}
+		return nil
+	}
+	l.sessions[key] = s
+	return s
+}
+
+// Accept waits for the next peer
+func (l *UDPListener) Accept() (net.Conn, error) {
+	select {
+	case s := <-l.accept:
+		return s, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting peers. Accepted sessions go on and the socket is
+// closed with the last of them, so closing after the first Accept locks the
+// listener onto that peer.
+func (l *UDPListener) Close() error {
+	l.mu.Lock()
+	l.closing = true
+	idle := len(l.sessions) == 0
+	l.mu.Unlock()
+
+	l.closeOnce.Do(func() { close(l.done) })
+	if idle {
+		return l.conn.Close()
+	}
+	// Sessions never accepted are dropped
+	for len(l.accept) > 0 {
+		(<-l.accept).Close()
+	}
+	return nil
+}
+
+// shutdown ends every session once the socket is gone
+func (l *UDPListener) shutdown() {
+	l.closeOnce.Do(func() { close(l.done) })
+	l.mu.Lock()
+	sessions := make([]*UDPSession, 0, len(l.sessions))
+	for _, s := range l.sessions {
+		sessions = append(sessions, s)
+	}
+	l.mu.Unlock()
+	for _, s := range sessions {
+		s.Close()
+	}
+}
+
+// remove forgets a closed session and closes the socket after the last one
+// of a closed listener
+func (l *UDPListener) remove(s *UDPSession) {
+	l.mu.Lock()
+	if l.sessions[s.peer.String()] == s {
+		delete(l.sessions, s.peer.String())
+	}
+	last := l.closing && len(l.sessions) == 0
+	l.mu.Unlock()
+	if last {
+		l.conn.Close()
+	}
+}
+
+// Addr returns the address of the socket
+func (l *UDPListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Sessions returns the number of open sessions
+func (l *UDPListener) Sessions() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.sessions)
+}
+
+// UDPSession is the traffic of one peer of a UDPListener. A read returns
+// one datagram; the rest of a datagram larger than the buffer is returned
+// by the next reads. It ends when closed, when the peer was idle for the
+// listener's IdleTimeout or when the socket fails, after which reads
+// return io.EOF. Like a UDP socket it cannot be half-closed.
+type UDPSession struct {
+	listener *UDPListener
+	peer     net.Addr
+
+	inbox     chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	idle      *time.Timer
+
+	readMu  sync.Mutex
+	pending []byte
+
+	mu       sync.Mutex
+	deadline time.Time
+	wake     chan struct{} // closed when the read deadline changes
+}
+
+func newUDPSession(l *UDPListener, peer net.Addr) *UDPSession {
+	return &UDPSession{
+		listener: l,
+		peer:     peer,
+		inbox:    make(chan []byte, udpSessionQueue),
+		closed:   make(chan struct{}),
+		wake:     make(chan struct{}),
+	}
+}
+
+// deliver queues a datagram of the peer, dropping it when the session
+// falls behind like a full socket buffer would
+func (s *UDPSession) deliver(data []byte) {
+	s.touch()
+	select {
+	case s.inbox <- data:
+	case <-s.closed:
+	default:
+	}
+}
+
+// touch restarts the idle timer
+func (s *UDPSession) touch() {
+	if s.idle != nil {
+		s.idle.Reset(s.listener.config.IdleTimeout)
+	}
+}
+
+func (s *UDPSession) Read(p []byte) (int, error) {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+
+	for len(s.pending) == 0 {
+		s.mu.Lock()
+		deadline, wake := s.deadline, s.wake
+		s.mu.Unlock()
+
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		select {
+		case s.pending = <-s.inbox:
+		case <-s.closed:
+			return 0, io.EOF
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		case <-wake:
+		}
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write sends p to the peer, in several datagrams when it exceeds
+// MaxDatagramSize
+func (s *UDPSession) Write(p []byte) (int, error) {
+	select {
+	case <-s.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	s.touch()
+
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > MaxDatagramSize {
+			chunk = chunk[:MaxDatagramSize]
+		}
+		n, err := s.listener.conn.WriteTo(chunk, s.peer)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Close ends the session. Later datagrams of the peer start a new session
+// unless the listener was closed.
+func (s *UDPSession) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		if s.idle != nil {
+			s.idle.Stop()
+		}
+		s.listener.remove(s)
+	})
+	return nil
+}
+
+// Done is closed when the session ended
+func (s *UDPSession) Done() <-chan struct{} {
+	return s.closed
+}
+
+func (s *UDPSession) LocalAddr() net.Addr {
+	return s.listener.conn.LocalAddr()
+}
+
+func (s *UDPSession) RemoteAddr() net.Addr {
+	return s.peer
+}
+
+func (s *UDPSession) SetDeadline(t time.Time) error {
+	return s.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of reads, waking blocked ones
+func (s *UDPSession) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.deadline = t
+	close(s.wake)
+	s.wake = make(chan struct{})
+	s.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline does nothing: datagrams are sent without blocking
+func (s *UDPSession) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+var _ net.Listener = (*UDPListener)(nil)
@@ -0,0 +1,188 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func listenUDPTest(t *testing.T, config *UDPListenerConfig) *UDPListener {
+	t.Helper()
+	ln, err := ListenUDP("udp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+func dialUDPTest(t *testing.T, ln *UDPListener) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("udp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestUDPListenerSessions(t *testing.T) {
+	ln := listenUDPTest(t, nil)
+	c1 := dialUDPTest(t, ln)
+	c2 := dialUDPTest(t, ln)
+
+	c1.Write([]byte("one"))
+	s1, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.Write([]byte("two"))
+	s2, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.RemoteAddr().String() != c1.LocalAddr().String() {
+		t.Errorf("session peer = %s, want %s", s1.RemoteAddr(), c1.LocalAddr())
+	}
+
+	buf := make([]byte, 16)
+	s1.SetReadDeadline(time.Now().Add(2 * time.Second))
+	c1.Write([]byte("again"))
+	for _, want := range []string{"one", "again"} {
+		n, err := s1.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read() = %q, %v, want %q", buf[:n], err, want)
+		}
+	}
+
+	s2.Write([]byte("reply"))
+	c2.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := c2.Read(buf)
+	if err != nil || string(buf[:n]) != "reply" {
+		t.Fatalf("peer read %q, %v", buf[:n], err)
+	}
+	if ln.Sessions() != 2 {
+		t.Errorf("Sessions() = %d, want 2", ln.Sessions())
+	}
+}
+
+func TestUDPSessionLargeDatagram(t *testing.T) {
+	ln := listenUDPTest(t, nil)
+	c := dialUDPTest(t, ln)
+
+	data := bytes.Repeat([]byte("x"), 60000)
+	if _, err := c.Write(data); err != nil {
+		t.Skipf("cannot send a %d byte datagram: %v", len(data), err)
+	}
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A small buffer gets the datagram in pieces
+	got, err := io.ReadAll(io.LimitReader(s, int64(len(data))))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, %v", len(got), err)
+	}
+}
+
+func TestUDPSessionIdleTimeout(t *testing.T) {
+	ln := listenUDPTest(t, &UDPListenerConfig{IdleTimeout: 50 * time.Millisecond})
+	c := dialUDPTest(t, ln)
+
+	c.Write([]byte("hi"))
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Read(make([]byte, 16))
+	if _, err := s.Read(make([]byte, 16)); err != io.EOF {
+		t.Fatalf("Read() after idle timeout = %v, want EOF", err)
+	}
+	if ln.Sessions() != 0 {
+		t.Errorf("expired session still listed")
+	}
+}
+
+func TestUDPSessionReadDeadline(t *testing.T) {
+	ln := listenUDPTest(t, nil)
+	c := dialUDPTest(t, ln)
+	c.Write([]byte("hi"))
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Read(make([]byte, 16))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Read(make([]byte, 16))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.SetReadDeadline(time.Now())
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("Read() = %v, want deadline exceeded", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("deadline did not interrupt Read")
+	}
+}
+
+func TestUDPListenerCloseLocksPeer(t *testing.T) {
+	ln := listenUDPTest(t, nil)
+	c1 := dialUDPTest(t, ln)
+	c2 := dialUDPTest(t, ln)
+
+	c1.Write([]byte("first"))
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	// Other peers are ignored, the accepted one goes on
+	c2.Write([]byte("intruder"))
+	c1.Write([]byte("second"))
+	buf := make([]byte, 16)
+	s.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for _, want := range []string{"first", "second"} {
+		n, err := s.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read() = %q, %v, want %q", buf[:n], err, want)
+		}
+	}
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept() after Close = %v", err)
+	}
+
+	// The socket goes with the last session
+	s.Close()
+	if _, err := s.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write() after Close = %v", err)
+	}
+}
+
+func TestUDPListenerAllow(t *testing.T) {
+	ln := listenUDPTest(t, &UDPListenerConfig{Allow: func(net.Addr) bool { return false }})
+	c := dialUDPTest(t, ln)
+	c.Write([]byte("denied"))
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if s, err := ln.Accept(); err == nil {
+			accepted <- s
+		}
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("denied peer was accepted")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -2,10 +2,12 @@ package network
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -52,8 +54,10 @@ func (d *UnixDialer) Dial(socketPath string) (net.Conn, error) {
 	}
 
 	// Check if socket file exists
-	if _, err := os.Stat(socketPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("Unix socket does not exist: %s", socketPath)
+	if !IsAbstractUnixPath(socketPath) {
+		if _, err := os.Stat(socketPath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("Unix socket does not exist: %s", socketPath)
+		}
 	}
 
 	logger.Debug("Connecting to Unix socket: %s", socketPath)
@@ -96,17 +100,21 @@ func (l *UnixListener) Listen(socketPath string) error {
 		return fmt.Errorf("invalid Unix socket path: %s", socketPath)
 	}
 
-	l.path = socketPath
+	// Abstract sockets have no file to prepare or clean up
+	abstract := IsAbstractUnixPath(socketPath)
+	if !abstract {
+		l.path = socketPath
 
-	// Remove existing socket file if it exists
-	if err := l.removeExistingSocket(socketPath); err != nil {
-		return fmt.Errorf("failed to remove existing socket: %w", err)
-	}
+		// Remove existing socket file if it exists
+		if err := l.removeExistingSocket(socketPath); err != nil {
+			return fmt.Errorf("failed to remove existing socket: %w", err)
+		}
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(socketPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create socket directory: %w", err)
+		// Create directory if it doesn't exist
+		dir := filepath.Dir(socketPath)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create socket directory: %w", err)
+		}
 	}
 
 	logger.Debug("Creating Unix socket listener: %s", socketPath)
@@ -117,8 +125,18 @@ func (l *UnixListener) Listen(socketPath string) error {
 		return fmt.Errorf("failed to resolve Unix address: %w", err)
 	}
 
-	// Start listening
-	listener, err := net.ListenUnix("unix", addr)
+	// Start listening. The umask keeps the socket file from being reachable
+	// with looser permissions before the chmod below.
+	var listener *net.UnixListener
+	listen := func() error {
+		listener, err = net.ListenUnix("unix", addr)
+		return err
+	}
+	if abstract {
+		err = listen()
+	} else {
+		err = withUmask(^l.config.Permissions, listen)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to listen on Unix socket: %w", err)
 	}
@@ -126,8 +144,10 @@ func (l *UnixListener) Listen(socketPath string) error {
 	l.listener = listener
 
 	// Set socket permissions
-	if err := os.Chmod(socketPath, l.config.Permissions); err != nil {
-		logger.Warn("Failed to set socket permissions: %v", err)
+	if !abstract {
+		if err := os.Chmod(socketPath, l.config.Permissions); err != nil {
+			logger.Warn("Failed to set socket permissions: %v", err)
+		}
 	}
 
 	logger.Info("Listening on Unix socket: %s", socketPath)
@@ -206,17 +226,28 @@ func (l *UnixListener) isSocketInUse(socketPath string) bool {
 	return true // Socket is in use
 }
 
+// IsAbstractUnixPath reports whether path names a socket in the Linux
+// abstract namespace, written with a leading '@'
+func IsAbstractUnixPath(path string) bool {
+	return strings.HasPrefix(path, "@")
+}
+
 // isValidUnixSocketPath reports whether path is an acceptable Unix domain socket path.
 // It returns true for non-empty paths up to 104 characters that are either absolute,
 // start with "./" or "../", or are simple relative filenames without any '/' characters.
 // Paths that exceed the length limit, are empty, or contain nested relative segments
 // (i.e., contain '/' but are not absolute or explicitly prefixed with "./" or "../")
-// are considered invalid.
+// are considered invalid. Abstract names ("@name") are valid where the
+// platform has them.
 func isValidUnixSocketPath(path string) bool {
 	if path == "" {
 		return false
 	}
 
+	if IsAbstractUnixPath(path) {
+		return AbstractUnixSockets && len(path) > 1 && len(path) <= MaxAbstractUnixPath
+	}
+
 	// Check path length (Unix socket paths have a limit)
 	if len(path) > 104 { // Typical limit on most systems
 		return false
@@ -238,34 +269,46 @@ func isValidUnixSocketPath(path string) bool {
 // It stats the file and populates a UnixSocketInfo with path, permissions, size, modification
 // time and whether the filesystem entry is a socket. When available, UID, GID and inode are
 // filled from the platform-specific stat data. The function also attempts a short (100ms)
-// Unix-domain dial to mark the socket as InUse if a connection can be established.
-// Returns an error if the initial os.Stat fails.
+// Unix-domain dial to mark the socket as InUse if a connection can be established, and
+// records the credentials of the process serving it as Peer. Abstract sockets have no
+// file and only get the dial. Returns an error if the initial os.Stat fails.
 func GetUnixSocketInfo(socketPath string) (*UnixSocketInfo, error) {
-	stat, err := os.Stat(socketPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat socket: %w", err)
-	}
+	info := &UnixSocketInfo{Path: socketPath}
+	if IsAbstractUnixPath(socketPath) {
+		info.Abstract = true
+		info.IsSocket = true
+	} else {
+		stat, err := os.Stat(socketPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat socket: %w", err)
+		}
 
-	info := &UnixSocketInfo{
-		Path:        socketPath,
-		Permissions: stat.Mode(),
-		Size:        stat.Size(),
-		ModTime:     stat.ModTime(),
-		IsSocket:    stat.Mode()&os.ModeSocket != 0,
-	}
+		info.Permissions = stat.Mode()
+		info.Size = stat.Size()
+		info.ModTime = stat.ModTime()
+		info.IsSocket = stat.Mode()&os.ModeSocket != 0
 
-	// Get additional system info if available
-	if sysInfo, ok := stat.Sys().(*syscall.Stat_t); ok {
-		info.UID = sysInfo.Uid
-		info.GID = sysInfo.Gid
-		info.Inode = sysInfo.Ino
+		// Get additional system info if available
+		if sysInfo, ok := stat.Sys().(*syscall.Stat_t); ok {
+			info.UID = sysInfo.Uid
+			info.GID = sysInfo.Gid
+			info.Inode = sysInfo.Ino
+		}
 	}
 
-	// Check if socket is in use
-	conn, err := net.DialTimeout("unix", socketPath, 100*time.Millisecond)
-	if err == nil {
-		conn.Close()
+	// Check if socket is in use, and by whom
+	for _, network := range []string{"unix", "unixpacket"} {
+		conn, err := net.DialTimeout(network, socketPath, 100*time.Millisecond)
+		if err != nil {
+			continue
+		}
 		info.InUse = true
+		info.Type = network
+		if cred, err := PeerCred(conn); err == nil {
+			info.Peer = cred
+		}
+		conn.Close()
+		break
 	}
 
 	return info, nil
@@ -282,6 +325,9 @@ type UnixSocketInfo struct {
 	UID         uint32
 	GID         uint32
 	Inode       uint64
+	Abstract    bool      // in the Linux abstract namespace, without a file
+	Type        string    // "unix" or "unixpacket" when in use
+	Peer        *UnixCred // process serving the socket, where the platform tells
 }
 
 // String returns a string representation of the socket info
@@ -291,6 +337,11 @@ func (info *UnixSocketInfo) String() string {
 		status = "in use"
 	}
 
+	if info.Abstract {
+		s := fmt.Sprintf("Unix Socket: %s (abstract)\n  Status: %s", info.Path, status)
+		return s + info.peerString()
+	}
+
 	return fmt.Sprintf("Unix Socket: %s\n"+
 		"  Permissions: %s\n"+
 		"  Size: %d bytes\n"+
@@ -305,5 +356,131 @@ func (info *UnixSocketInfo) String() string {
 		status,
 		info.UID,
 		info.GID,
-		info.Inode)
+		info.Inode) + info.peerString()
+}
+
+func (info *UnixSocketInfo) peerString() string {
+	var s string
+	if info.Type != "" {
+		s += "\n  Type: " + map[string]string{"unix": "stream", "unixpacket": "seqpacket"}[info.Type]
+	}
+	if info.Peer != nil {
+		s += "\n  Peer: " + info.Peer.String()
+	}
+	return s
+}
+
+// UnixCred identifies the process at the other end of a Unix socket
+type UnixCred struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// String returns a string representation of the credentials
+func (c *UnixCred) String() string {
+	return fmt.Sprintf("pid %d uid %d gid %d", c.PID, c.UID, c.GID)
+}
+
+// UnixFDConn is a Unix socket passing file descriptors with SCM_RIGHTS. The
+// files given to it go with the next write; files received along with data
+// are handed to OnFiles, or closed when it is nil.
+type UnixFDConn struct {
+	*net.UnixConn
+
+	// OnFiles receives the files passed by the peer, which it then owns
+	OnFiles func([]*os.File)
+
+	mu   sync.Mutex
+	send []*os.File
+}
+
+// NewUnixFDConn wraps conn to pass descriptors over it
+func NewUnixFDConn(conn *net.UnixConn) *UnixFDConn {
+	return &UnixFDConn{UnixConn: conn}
+}
+
+// SendFiles queues files to go with the next write, which closes them once
+// they are sent. Stream sockets cannot pass descriptors without data.
+func (c *UnixFDConn) SendFiles(files ...*os.File) {
+	c.mu.Lock()
+	c.send = append(c.send, files...)
+	c.mu.Unlock()
+}
+
+// Read reads data, collecting the descriptors passed with it
+func (c *UnixFDConn) Read(b []byte) (int, error) {
+	oob := make([]byte, syscall.CmsgSpace(maxPassedFDs*4))
+	n, oobn, _, _, err := c.ReadMsgUnix(b, oob)
+	if oobn > 0 {
+		c.received(oob[:oobn])
+	}
+	if err != nil {
+		return n, err
+	}
+	// Only datagrams can be empty; elsewhere nothing read is the end
+	if n == 0 && len(b) > 0 && c.LocalAddr().Network() != "unixgram" {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// received hands the descriptors of a control message to OnFiles
+func (c *UnixFDConn) received(oob []byte) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		logger.Warn("Failed to parse control message: %v", err)
+		return
+	}
+	var files []*os.File
+	for _, m := range msgs {
+		fds, err := syscall.ParseUnixRights(&m)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			files = append(files, os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd)))
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	if c.OnFiles == nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return
+	}
+	c.OnFiles(files)
+}
+
+// Write writes data along with the queued files
+func (c *UnixFDConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	files := c.send
+	c.send = nil
+	c.mu.Unlock()
+	if len(files) == 0 {
+		return c.UnixConn.Write(b)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	fds := make([]int, len(files))
+	for i, f := range files {
+		fds[i] = int(f.Fd())
+	}
+	n, _, err := c.WriteMsgUnix(b, syscall.UnixRights(fds...), nil)
+	if err != nil {
+		return n, err
+	}
+	// A stream may take part of b with the descriptors
+	if n < len(b) && c.LocalAddr().Network() != "unixgram" {
+		m, err := c.UnixConn.Write(b[n:])
+		return n + m, err
+	}
+	return n, nil
 }
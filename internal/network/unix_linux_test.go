@@ -0,0 +1,153 @@
+//go:build linux
+
+package network
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func abstractName(t *testing.T) string {
+	return fmt.Sprintf("@gocat-test-%d-%s", os.Getpid(), t.Name())
+}
+
+func TestIsValidUnixSocketPathAbstract(t *testing.T) {
+	for path, want := range map[string]bool{
+		"@gocat": true,
+		"@":      false,
+		"@" + string(make([]byte, MaxAbstractUnixPath)): false,
+		"/run/gocat.sock": true,
+	} {
+		if got := isValidUnixSocketPath(path); got != want {
+			t.Errorf("isValidUnixSocketPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestPeerCred(t *testing.T) {
+	ln, err := net.Listen("unixpacket", abstractName(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("unixpacket", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	cred, err := PeerCred(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(cred.PID) != os.Getpid() || int(cred.UID) != os.Getuid() || int(cred.GID) != os.Getgid() {
+		t.Errorf("PeerCred() = %v", cred)
+	}
+
+	info, err := GetUnixSocketInfo(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Abstract || !info.InUse || info.Type != "unixpacket" || info.Peer == nil {
+		t.Errorf("GetUnixSocketInfo() = %+v", info)
+	}
+}
+
+func TestUnixFDConnPassesFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fd.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ln.AcceptUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passed := filepath.Join(t.TempDir(), "passed")
+	if err := os.WriteFile(passed, []byte("through the socket"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(passed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := NewUnixFDConn(client)
+	sender.SendFiles(f)
+	if _, err := sender.Write([]byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	sender.Close()
+
+	var received []*os.File
+	receiver := NewUnixFDConn(server)
+	receiver.OnFiles = func(files []*os.File) { received = append(received, files...) }
+	data, err := io.ReadAll(receiver)
+	if err != nil || string(data) != "hi" {
+		t.Fatalf("ReadAll() = %q, %v", data, err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("received %d files", len(received))
+	}
+	defer received[0].Close()
+	if DescribeFile(received[0]) != passed {
+		t.Errorf("DescribeFile() = %q, want %q", DescribeFile(received[0]), passed)
+	}
+	content, _ := io.ReadAll(received[0])
+	if string(content) != "through the socket" {
+		t.Errorf("passed file reads %q", content)
+	}
+}
+
+func TestUnixgramSessions(t *testing.T) {
+	name := abstractName(t)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := NewUDPListener(conn, nil)
+	defer ln.Close()
+
+	client, err := net.DialUnix("unixgram", UnixgramClientAddr(), &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Write([]byte("ping"))
+
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := s.Read(buf)
+	if err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("Read() = %q, %v", buf[:n], err)
+	}
+
+	// Bound clients get replies
+	s.Write([]byte("pong"))
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err = client.Read(buf)
+	if err != nil || string(buf[:n]) != "pong" {
+		t.Fatalf("client Read() = %q, %v", buf[:n], err)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// AbstractUnixSockets reports whether "@name" paths reach the abstract
+// namespace
+const AbstractUnixSockets = true
+
+// MaxAbstractUnixPath is the longest abstract name, with its '@'
+const MaxAbstractUnixPath = 108
+
+// maxPassedFDs bounds the descriptors taken from one read
+const maxPassedFDs = 64
+
+var unixgramClients atomic.Uint32
+
+// PeerCred returns the credentials of the process at the other end of a
+// stream or seqpacket Unix socket, as they were when it connected or
+// listened
+func PeerCred(conn net.Conn) (*UnixCred, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("not a socket: %T", conn)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var ucred *unix.Ucred
+	var credErr error
+	if err := rc.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, fmt.Errorf("getsockopt SO_PEERCRED failed: %w", credErr)
+	}
+	return &UnixCred{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}, nil
+}
+
+// UnixgramClientAddr returns an address to bind datagram clients to, so
+// servers can reply. It is a fresh abstract name, which needs no cleanup.
+func UnixgramClientAddr() *net.UnixAddr {
+	name := fmt.Sprintf("@gocat-%d-%d", os.Getpid(), unixgramClients.Add(1))
+	return &net.UnixAddr{Name: name, Net: "unixgram"}
+}
+
+// DescribeFile describes what a passed descriptor refers to
+func DescribeFile(f *os.File) string {
+	if target, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", f.Fd())); err == nil {
+		return target
+	}
+	return f.Name()
+}
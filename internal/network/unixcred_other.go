@@ -0,0 +1,36 @@
+//go:build !linux
+
+package network
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// AbstractUnixSockets reports whether "@name" paths reach the abstract
+// namespace
+const AbstractUnixSockets = false
+
+// MaxAbstractUnixPath is the longest abstract name, with its '@'
+const MaxAbstractUnixPath = 0
+
+// maxPassedFDs bounds the descriptors taken from one read
+const maxPassedFDs = 64
+
+// PeerCred returns the credentials of the process at the other end of a
+// Unix socket; SO_PEERCRED is only available on Linux
+func PeerCred(conn net.Conn) (*UnixCred, error) {
+	return nil, errors.New("peer credentials not supported on this platform")
+}
+
+// UnixgramClientAddr returns an address to bind datagram clients to. Without
+// an abstract namespace clients stay unbound and cannot get replies.
+func UnixgramClientAddr() *net.UnixAddr {
+	return nil
+}
+
+// DescribeFile describes what a passed descriptor refers to
+func DescribeFile(f *os.File) string {
+	return f.Name()
+}
@@ -0,0 +1,165 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ibrahmsql/gocat/internal/errors"
+)
+
+// Upstreams hands out connections to backends. Connections come warm from
+// a pool when it has them and are dialed through a Dialer otherwise, whose
+// circuit breakers fail fast for backends that keep refusing, so flapping
+// backends are not hammered by every client that arrives.
+type Upstreams struct {
+	dialer *Dialer
+	pool   *ConnectionPoolImpl
+}
+
+// UpstreamStats holds the pool connections and breaker state of a backend
+type UpstreamStats struct {
+	Address string `json:"address"`
+	AddressPoolStats
+	Circuit CircuitBreakerStats `json:"circuit"`
+}
+
+// UpstreamsStats holds the statistics of the pool and of each backend
+type UpstreamsStats struct {
+	Pool     PoolStats       `json:"pool"`
+	Backends []UpstreamStats `json:"backends"`
+}
+
+// NewUpstreams creates upstreams dialing through dialer, whose circuit
+// breakers guard the backends, and pooling with config
+func NewUpstreams(dialer *Dialer, config *PoolConfig) *Upstreams {
+	if dialer == nil {
+		dialer = NewDialer(nil)
+	}
+	return &Upstreams{
+		dialer: dialer,
+		pool:   NewConnectionPool(config, dialer),
+	}
+}
+
+// Warm starts keeping idle connections open to a backend before its first
+// connection is asked for
+func (u *Upstreams) Warm(address string) {
+	u.pool.Warm(address)
+}
+
+// Forget closes the idle connections to a backend that is no longer used
+// and stops keeping them
+func (u *Upstreams) Forget(address string) {
+	u.pool.Forget(address)
+}
+
+// Get returns a connection to a backend. Close discards it; Release hands
+// it back for reuse once the conversation on it is complete.
+func (u *Upstreams) Get(ctx context.Context, address string) (*UpstreamConn, error) {
+	// Idle connections to a backend that stopped taking new ones are most
+	// likely dead too
+	if !u.Available(address) {
+		u.pool.Flush(address)
+		return nil, errors.NetworkError("NET057", fmt.Sprintf("Circuit breaker is open for %s", address)).WithUserFriendly(fmt.Sprintf("%s is temporarily unavailable", address))
+	}
+
+	conn, err := u.pool.Get(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return &UpstreamConn{Connection: conn, upstreams: u}, nil
+}
+
+// Available reports whether the circuit breaker of a backend lets new
+// connections through
+func (u *Upstreams) Available(address string) bool {
+	return u.dialer.Available(address)
+}
+
+// DialContext returns a connection to a backend, for http.Transport and
+// the other users of a dial function. Only TCP backends are pooled.
+func (u *Upstreams) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if !strings.HasPrefix(network, "tcp") {
+		return u.dialer.DialContext(ctx, network, address)
+	}
+	return u.Get(ctx, address)
+}
+
+// Stats returns the statistics of the pool and of each backend, by address
+func (u *Upstreams) Stats() UpstreamsStats {
+	stats := UpstreamsStats{Pool: u.pool.Stats()}
+	pooled := u.pool.AddressStats()
+	circuits := u.dialer.GetCircuitBreakerStats()
+
+	// Backends that never connected have a breaker but nothing pooled
+	for address := range circuits {
+		if _, ok := pooled[address]; !ok {
+			pooled[address] = AddressPoolStats{}
+		}
+	}
+	for address, conns := range pooled {
+		stats.Backends = append(stats.Backends, UpstreamStats{
+			Address:          address,
+			AddressPoolStats: conns,
+			Circuit:          circuits[address],
+		})
+	}
+	sort.Slice(stats.Backends, func(i, j int) bool {
+		return stats.Backends[i].Address < stats.Backends[j].Address
+	})
+	return stats
+}
+
+// Close closes the pool and every connection in it
+func (u *Upstreams) Close() error {
+	return u.pool.Close()
+}
+
+// UpstreamConn is a connection to a backend taken from Upstreams
+type UpstreamConn struct {
+	Connection
+	upstreams *Upstreams
+	done      atomic.Bool
+}
+
+// Release hands the connection back to the pool for the next Get. Only
+// release connections whose conversation is complete, with nothing left
+// unread.
+func (c *UpstreamConn) Release() error {
+	if c.done.Swap(true) {
+		return nil
+	}
+	return c.upstreams.pool.Put(c.Connection)
+}
+
+// Close closes the connection without returning it to the pool
+func (c *UpstreamConn) Close() error {
+	if c.done.Swap(true) {
+		return nil
+	}
+	return c.upstreams.pool.Discard(c.Connection)
+}
+
+// Unwrap returns the pooled connection so relays can splice the socket
+// below it
+func (c *UpstreamConn) Unwrap() net.Conn {
+	return c.Connection
+}
+
+// CountRead is counted by the pooled connection
+func (c *UpstreamConn) CountRead(int64) {}
+
+// CountWritten is counted by the pooled connection
+func (c *UpstreamConn) CountWritten(int64) {}
+
+// CloseWrite shuts down the writing side of the connection
+func (c *UpstreamConn) CloseWrite() error {
+	if hc, ok := c.Connection.(interface{ CloseWrite() error }); ok {
+		return hc.CloseWrite()
+	}
+	return errors.NetworkError("NET056", "Connection cannot be half-closed")
+}
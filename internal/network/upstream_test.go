@@ -0,0 +1,163 @@
+package network
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// acceptAll accepts connections on ln until it is closed, handing them over
+func acceptAll(ln net.Listener) <-chan net.Conn {
+	conns := make(chan net.Conn, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(conns)
+				return
+			}
+			conns <- conn
+		}
+	}()
+	return conns
+}
+
+func testUpstreams(minSize int) *Upstreams {
+	dialerConfig := DefaultDialerConfig()
+	dialerConfig.MaxRetries = 0
+	dialerConfig.CircuitBreakerThreshold = 2
+	dialerConfig.CircuitBreakerTimeout = time.Hour
+
+	poolConfig := DefaultPoolConfig()
+	poolConfig.MinSize = minSize
+	poolConfig.MaxSize = 0
+	return NewUpstreams(NewDialer(dialerConfig), poolConfig)
+}
+
+// waitIdle waits until the pool holds idle connections to address
+func waitIdle(t *testing.T, u *Upstreams, address string, idle int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for u.pool.AddressStats()[address].Idle != idle {
+		if time.Now().After(deadline) {
+			t.Fatalf("idle = %d, want %d", u.pool.AddressStats()[address].Idle, idle)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUpstreamsWarmAndReuse(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := acceptAll(ln)
+	address := ln.Addr().String()
+
+	u := testUpstreams(2)
+	defer u.Close()
+
+	u.Warm(address)
+	waitIdle(t, u, address, 2)
+
+	conn, err := u.Get(context.Background(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := conn.GetID()
+	// Taking one out opens another to stay warm
+	waitIdle(t, u, address, 2)
+
+	if err := conn.Release(); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, u, address, 3)
+
+	// Connections the backend closed are skipped
+	for i := 0; i < 3; i++ {
+		(<-accepted).Close()
+	}
+	time.Sleep(50 * time.Millisecond)
+	conn, err = u.Get(context.Background(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.GetID() == id {
+		t.Error("got the connection the backend closed")
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Errorf("write to fresh connection: %v", err)
+	}
+}
+
+func TestUpstreamsBreakerFailsFast(t *testing.T) {
+	u := testUpstreams(0)
+	defer u.Close()
+
+	address := "127.0.0.1:" + strconv.Itoa(closedPort(t))
+	for i := 0; i < 2; i++ {
+		if _, err := u.Get(context.Background(), address); err == nil {
+			t.Fatal("connected to a closed port")
+		}
+	}
+
+	stats := u.Stats()
+	if len(stats.Backends) != 1 || stats.Backends[0].Circuit.State != CircuitBreakerOpen {
+		t.Fatalf("backends = %+v, want one with an open circuit", stats.Backends)
+	}
+	if _, err := u.Get(context.Background(), address); err == nil {
+		t.Error("open circuit let a connection through")
+	}
+	if failures := stats.Backends[0].Circuit.Failures; failures != 2 {
+		t.Errorf("%d failures recorded, want 2", failures)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	var changes []string
+	cb := NewCircuitBreaker(2, 20*time.Millisecond)
+	cb.onChange = func(from, to CircuitBreakerState) {
+		changes = append(changes, to.String())
+	}
+
+	// A success in between starts the count again
+	cb.RecordFailure()
+	cb.RecordSuccess()
+	cb.RecordFailure()
+	if cb.GetState() != CircuitBreakerClosed {
+		t.Fatal("breaker opened before threshold failures in a row")
+	}
+	cb.RecordFailure()
+	if cb.Allow() {
+		t.Fatal("open breaker allowed an operation")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !cb.Allow() {
+		t.Fatal("breaker did not let a probe through")
+	}
+	if cb.Allow() {
+		t.Error("breaker let a second probe through")
+	}
+	cb.RecordFailure()
+	if cb.GetState() != CircuitBreakerOpen {
+		t.Fatal("failed probe did not open the breaker again")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	cb.Allow()
+	cb.RecordSuccess()
+	want := []string{"open", "half-open", "open", "half-open", "closed"}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes = %v, want %v", changes, want)
+		}
+	}
+}
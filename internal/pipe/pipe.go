@@ -4,10 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"sync"
 	"time"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/relay"
 )
 
 // PipeData pipes data between two io.ReadWriter interfaces with graceful shutdown
@@ -23,95 +23,10 @@ func PipeDataWithContext(ctx context.Context, conn1, conn2 io.ReadWriter) {
 		}
 	}()
 
-	var wg sync.WaitGroup
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	wg.Add(2)
-
-	// Copy from conn1 to conn2
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				logger.Error("Panic in pipe goroutine (conn1->conn2): %v", r)
-			}
-			wg.Done()
-			cancel() // Cancel context when this goroutine exits
-		}()
-		if _, err := copyWithContext(ctx, conn2, conn1); err != nil {
-			if ctx.Err() == nil { // Only log if not cancelled
-				logger.Warn("Connection lost: %v", err)
-			}
-		}
-	}()
-
-	// Copy from conn2 to conn1
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				logger.Error("Panic in pipe goroutine (conn2->conn1): %v", r)
-			}
-			wg.Done()
-			cancel() // Cancel context when this goroutine exits
-		}()
-		if _, err := copyWithContext(ctx, conn1, conn2); err != nil {
-			if ctx.Err() == nil { // Only log if not cancelled
-				logger.Warn("Connection lost: %v", err)
-			}
-		}
-	}()
-
-	wg.Wait()
-}
-
-// copyWithContext copies data with context cancellation support
-func copyWithContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
-	defer func() {
-		if r := recover(); r != nil {
-			logger.Error("Panic in copyWithContext: %v", r)
-		}
-	}()
-
-	buf := make([]byte, 32*1024) // 32KB buffer
-	var written int64
-
-	for {
-		select {
-		case <-ctx.Done():
-			return written, ctx.Err()
-		default:
-		}
-
-		// Set read timeout if possible
-		if conn, ok := src.(interface{ SetReadDeadline(time.Time) error }); ok {
-			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
-		}
-
-		nr, er := src.Read(buf)
-		if nr > 0 {
-			nw, ew := dst.Write(buf[0:nr])
-			if nw < 0 || nr < nw {
-				nw = 0
-				if ew == nil {
-					ew = fmt.Errorf("invalid write result")
-				}
-			}
-			written += int64(nw)
-			if ew != nil {
-				return written, ew
-			}
-			if nr != nw {
-				return written, io.ErrShortWrite
-			}
-		}
-		if er != nil {
-			if er != io.EOF {
-				return written, er
-			}
-			break
-		}
+	_, err := relay.NewEngine().Run(ctx, relay.ReadWriter(conn1), relay.ReadWriter(conn2))
+	if err != nil && ctx.Err() == nil { // Only log if not cancelled
+		logger.Warn("Connection lost: %v", err)
 	}
-	return written, nil
 }
 
 // PipeWithBuffer pipes data with a custom buffer size and graceful shutdown
@@ -0,0 +1,253 @@
+// Package process runs the commands gocat attaches to connections, with or
+// without a pseudo-terminal, as relay endpoints.
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/ibrahmsql/gocat/internal/relay"
+)
+
+// GracePeriod is how long Close waits for a command to exit after it was
+// hung up before its process group is killed
+const GracePeriod = 500 * time.Millisecond
+
+// Default terminal size of a PTY whose peer never reported one
+const (
+	DefaultCols = 80
+	DefaultRows = 24
+)
+
+// eot is the terminal EOF character sent when the peer half-closes a PTY
+const eot = 0x04
+
+// Config describes a command to run for a connection
+type Config struct {
+	Args       []string // command and arguments
+	PTY        bool     // run under a pseudo-terminal
+	Env        []string // KEY=VALUE pairs added to the environment
+	ClearEnv   bool     // start from an empty environment instead of ours
+	Dir        string   // working directory, ours when empty
+	ReportExit bool     // append the exit status to the output
+}
+
+// Process is a running command. It is a relay endpoint writing to the
+// command's input and reading its combined output. The command runs in its
+// own process group, which is killed when the endpoint is closed.
+type Process struct {
+	cmd    *exec.Cmd
+	pty    *os.File // terminal master, nil without a PTY
+	stdin  *os.File
+	output *os.File
+
+	reportExit bool
+	report     []byte
+	reported   bool
+
+	exited  chan struct{}
+	waitErr error
+
+	closeOnce sync.Once
+}
+
+// Start starts the command described by cfg
+func Start(cfg Config) (*Process, error) {
+	if len(cfg.Args) == 0 {
+		return nil, errors.New("no command given")
+	}
+
+	cmd := exec.Command(cfg.Args[0], cfg.Args[1:]...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = environment(cfg)
+
+	p := &Process{cmd: cmd, reportExit: cfg.ReportExit, exited: make(chan struct{})}
+	if cfg.PTY {
+		ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: DefaultCols, Rows: DefaultRows})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start %s on a pty: %w", cfg.Args[0], err)
+		}
+		p.pty, p.stdin, p.output = ptmx, ptmx, ptmx
+	} else if err := p.startPipes(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		p.waitErr = cmd.Wait()
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+// startPipes starts the command with its stdin and combined output on pipes
+func (p *Process) startPipes() error {
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return fmt.Errorf("failed to create output pipe: %w", err)
+	}
+
+	p.cmd.Stdin = stdinR
+	p.cmd.Stdout = outW
+	p.cmd.Stderr = outW
+	setProcessGroup(p.cmd)
+	err = p.cmd.Start()
+	stdinR.Close()
+	outW.Close()
+	if err != nil {
+		stdinW.Close()
+		outR.Close()
+		return fmt.Errorf("failed to start %s: %w", p.cmd.Path, err)
+	}
+
+	p.stdin, p.output = stdinW, outR
+	return nil
+}
+
+// environment returns the environment of the command. Terminals get a TERM
+// unless one is configured.
+func environment(cfg Config) []string {
+	var env []string
+	if !cfg.ClearEnv {
+		env = os.Environ()
+	}
+	env = append(env, cfg.Env...)
+	if cfg.PTY {
+		for _, kv := range env {
+			if strings.HasPrefix(kv, "TERM=") {
+				return env
+			}
+		}
+		env = append(env, "TERM=xterm")
+	}
+	return env
+}
+
+// Read returns the command's output. When it ends and ReportExit is set,
+// a line with the exit status follows before EOF.
+func (p *Process) Read(b []byte) (int, error) {
+	if !p.reported {
+		n, err := p.output.Read(b)
+		if err == nil || n > 0 {
+			return n, nil
+		}
+		if !errors.Is(err, io.EOF) && !(p.pty != nil && isPTYClosed(err)) {
+			return 0, err
+		}
+		p.reported = true
+		if p.reportExit {
+			<-p.exited
+			p.report = []byte(p.exitReport())
+		}
+	}
+	if len(p.report) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, p.report)
+	p.report = p.report[n:]
+	return n, nil
+}
+
+// exitReport describes how the command ended
+func (p *Process) exitReport() string {
+	status := "exit status 0"
+	if p.cmd.ProcessState != nil {
+		status = p.cmd.ProcessState.String()
+	}
+	if p.pty != nil {
+		return "\r\n[" + status + "]\r\n"
+	}
+	return "[" + status + "]\n"
+}
+
+func (p *Process) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+// CloseWrite closes the command's input. A terminal cannot be half-closed,
+// so it gets the EOF character instead.
+func (p *Process) CloseWrite() error {
+	if p.pty != nil {
+		_, err := p.pty.Write([]byte{eot})
+		return err
+	}
+	err := p.stdin.Close()
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// SetDeadline interrupts blocked reads of the output and writes to the input
+func (p *Process) SetDeadline(t time.Time) error {
+	if err := p.output.SetDeadline(t); err != nil {
+		return err
+	}
+	if p.stdin == p.output {
+		return nil
+	}
+	if err := p.stdin.SetDeadline(t); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// Resize sets the terminal size of a command running under a PTY
+func (p *Process) Resize(cols, rows int) error {
+	if p.pty == nil {
+		return errors.New("process has no terminal")
+	}
+	return pty.Setsize(p.pty, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+}
+
+// Close hangs up the command and kills its process group if it has not
+// exited within GracePeriod. What the command left running in its group is
+// killed as well.
+func (p *Process) Close() error {
+	p.closeOnce.Do(func() {
+		if p.pty != nil {
+			signalGroup(p.cmd, hangupSignal)
+		} else {
+			p.stdin.Close()
+		}
+		select {
+		case <-p.exited:
+		case <-time.After(GracePeriod):
+		}
+		killGroup(p.cmd)
+		<-p.exited
+		p.output.Close()
+	})
+	return nil
+}
+
+// Wait closes the process and returns the command's exit error
+func (p *Process) Wait() error {
+	p.Close()
+	return p.waitErr
+}
+
+// Pid returns the process ID of the command
+func (p *Process) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// String returns the command line
+func (p *Process) String() string {
+	return strings.Join(p.cmd.Args, " ")
+}
+
+// Process is a relay endpoint
+var _ relay.Endpoint = (*Process)(nil)
@@ -0,0 +1,162 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/ibrahmsql/gocat/internal/telnet"
+	"github.com/ibrahmsql/gocat/internal/testutil"
+)
+
+func skipWithoutShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+}
+
+func TestProcessPipes(t *testing.T) {
+	skipWithoutShell(t)
+	client, server := testutil.TCPPair(t, 5*time.Second)
+
+	proc, err := Start(Config{
+		Args:       []string{"/bin/sh", "-c", `tr a-z A-Z; echo "$GREETING" >&2; pwd; exit 3`},
+		Env:        []string{"GREETING=hi"},
+		Dir:        "/",
+		ReportExit: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go relay.NewEngine().Run(context.Background(), relay.Conn(server), proc)
+
+	client.Write([]byte("shout\n"))
+	client.CloseWrite()
+	got, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SHOUT\nhi\n/\n[exit status 3]\n"; string(got) != want {
+		t.Errorf("client read %q, want %q", got, want)
+	}
+	if err := proc.Wait(); err == nil {
+		t.Error("Wait() = nil, want the exit error")
+	}
+}
+
+func TestProcessClearEnv(t *testing.T) {
+	skipWithoutShell(t)
+	proc, err := Start(Config{Args: []string{"/usr/bin/env"}, ClearEnv: true, Env: []string{"ONLY=1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	got, _ := io.ReadAll(proc)
+	if string(got) != "ONLY=1\n" {
+		t.Errorf("environment = %q, want only ONLY=1", got)
+	}
+}
+
+func TestProcessPTYResize(t *testing.T) {
+	skipWithoutShell(t)
+	client, server := testutil.TCPPair(t, 5*time.Second)
+
+	proc, err := Start(Config{Args: []string{"/bin/sh", "-c", "stty size; read line; stty size"}, PTY: true, ReportExit: true})
+	if err != nil {
+		t.Skipf("no pty available: %v", err)
+	}
+	engine := relay.NewEngine().Use(proc.ResizeControl(relay.Forward, false))
+	go engine.Run(context.Background(), relay.Conn(server), proc)
+
+	// The first size is the default one; resize before the second
+	waitFor(t, client, "24 80")
+	client.Write(FormatResize(132, 50))
+	client.Write([]byte("\n"))
+	waitFor(t, client, "50 132")
+	waitFor(t, client, "[exit status 0]")
+}
+
+// waitFor reads from r until want was seen
+func waitFor(t *testing.T, r io.Reader, want string) {
+	t.Helper()
+	var seen []byte
+	buf := make([]byte, 256)
+	for !bytes.Contains(seen, []byte(want)) {
+		n, err := r.Read(buf)
+		seen = append(seen, buf[:n]...)
+		if err != nil {
+			t.Fatalf("read %q waiting for %q: %v", seen, want, err)
+		}
+	}
+}
+
+func TestResizeReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		telnet bool
+		input  string
+		want   string
+		sizes  []string
+	}{
+		{name: "plain data", input: "ls -l\n", want: "ls -l\n"},
+		{name: "in-band resize", input: "a\x1b[8;40;100tb", want: "ab", sizes: []string{"100x40"}},
+		{name: "other escapes pass", input: "\x1b[A\x1b[8;x", want: "\x1b[A\x1b[8;x"},
+		{name: "incomplete at EOF", input: "\x1b[8;4", want: "\x1b[8;4"},
+		{name: "telnet ignored by default", input: "\xff\xfb\x1f", want: "\xff\xfb\x1f"},
+		{
+			name:   "telnet NAWS",
+			telnet: true,
+			input:  "\xff\xfb\x1f\xff\xfa\x1f\x00\x78\x00\x1e\xff\xf0x\xff\xffy",
+			want:   "x\xffy",
+			sizes:  []string{"120x30"},
+		},
+		{
+			name:   "NAWS with escaped IAC",
+			telnet: true,
+			input:  "\xff\xfa\x1f\x01\xff\xff\x00\x19\xff\xf0",
+			want:   "",
+			sizes:  []string{"511x25"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []string
+			resize := func(cols, rows int) error {
+				sizes = append(sizes, fmt.Sprintf("%dx%d", cols, rows))
+				return nil
+			}
+			// One byte at a time exercises sequences split across reads
+			r := &resizeReader{src: iotest.OneByteReader(strings.NewReader(tt.input)), resize: resize, telnet: tt.telnet}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("data = %q, want %q", got, tt.want)
+			}
+			if strings.Join(sizes, ",") != strings.Join(tt.sizes, ",") {
+				t.Errorf("sizes = %v, want %v", sizes, tt.sizes)
+			}
+		})
+	}
+}
+
+func TestRequestWindowSize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RequestWindowSize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{telnet.IAC, telnet.DO, telnet.WINDOW_SIZE}) {
+		t.Errorf("request = %v", buf.Bytes())
+	}
+}
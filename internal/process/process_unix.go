@@ -0,0 +1,33 @@
+//go:build !windows
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// hangupSignal is sent to the process group of a terminal when it is closed
+const hangupSignal = syscall.SIGHUP
+
+// setProcessGroup puts the command in a process group of its own
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup signals the process group led by the command
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) {
+	syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// killGroup kills the process group led by the command
+func killGroup(cmd *exec.Cmd) {
+	signalGroup(cmd, syscall.SIGKILL)
+}
+
+// isPTYClosed reports whether a read error of a terminal master means the
+// other side is gone, which Linux signals with EIO
+func isPTYClosed(err error) bool {
+	return errors.Is(err, syscall.EIO)
+}
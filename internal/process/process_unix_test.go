@@ -0,0 +1,38 @@
+//go:build !windows
+
+package process
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProcessGroupCleanup(t *testing.T) {
+	skipWithoutShell(t)
+	proc, err := Start(Config{Args: []string{"/bin/sh", "-c", "sleep 60 & echo $!; exec cat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := make([]byte, 32)
+	n, err := proc.Read(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(line[:n])))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("background process %d survived Close", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package process
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// hangupSignal is unused: terminals are not supported on Windows
+const hangupSignal = syscall.Signal(0)
+
+// setProcessGroup does nothing; Windows has no process groups to clean up
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup does nothing on Windows
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) {}
+
+// killGroup kills the command
+func killGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// isPTYClosed reports false, Windows has no terminal masters
+func isPTYClosed(err error) bool {
+	return false
+}
@@ -0,0 +1,197 @@
+package process
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/relay"
+	"github.com/ibrahmsql/gocat/internal/telnet"
+)
+
+// maxControlSequence bounds the bytes held back while a control sequence is
+// incomplete; longer ones are passed on as data
+const maxControlSequence = 64
+
+// RequestWindowSize asks a telnet client to report its window size (NAWS)
+func RequestWindowSize(w io.Writer) error {
+	_, err := w.Write([]byte{telnet.IAC, telnet.DO, telnet.WINDOW_SIZE})
+	return err
+}
+
+// ResizeControl returns a middleware that resizes the terminal of p from
+// what the peer sends in the inbound direction. The in-band message is the
+// xterm window manipulation sequence "ESC [ 8 ; rows ; cols t". With
+// withTelnet set, NAWS subnegotiations resize as well and all other telnet
+// commands are removed from the stream.
+func (p *Process) ResizeControl(inbound relay.Direction, withTelnet bool) relay.Middleware {
+	return func(dir relay.Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader) {
+		if dir != inbound {
+			return dst, src
+		}
+		return dst, &resizeReader{src: src, resize: p.Resize, telnet: withTelnet}
+	}
+}
+
+// resizeReader removes resize messages from a stream and applies them
+type resizeReader struct {
+	src    io.Reader
+	resize func(cols, rows int) error
+	telnet bool
+
+	buf     []byte
+	pending []byte // data that may start an incomplete control sequence
+	out     []byte // filtered data not yet returned
+	err     error
+}
+
+func (r *resizeReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			// The stream ended inside what looked like a control sequence
+			r.out, r.pending = r.pending, nil
+			if len(r.out) == 0 {
+				return 0, r.err
+			}
+			break
+		}
+		if r.buf == nil {
+			r.buf = make([]byte, 4096)
+		}
+		n, err := r.src.Read(r.buf)
+		r.err = err
+		r.filter(r.buf[:n])
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// filter moves data to out, applying the control sequences it contains
+func (r *resizeReader) filter(data []byte) {
+	data = append(r.pending, data...)
+	r.pending = nil
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, 0x1b)
+		if r.telnet {
+			if j := bytes.IndexByte(data, telnet.IAC); j >= 0 && (i < 0 || j < i) {
+				i = j
+			}
+		}
+		if i < 0 {
+			r.out = append(r.out, data...)
+			return
+		}
+		r.out = append(r.out, data[:i]...)
+		data = data[i:]
+
+		var n int
+		var complete bool
+		if data[0] == telnet.IAC {
+			n, complete = r.telnetCommand(data)
+		} else {
+			n, complete = r.resizeSequence(data)
+		}
+		switch {
+		case !complete && len(data) < maxControlSequence:
+			r.pending = append(r.pending, data...)
+			return
+		case !complete || n == 0:
+			// Not a control sequence after all
+			r.out = append(r.out, data[0])
+			data = data[1:]
+		default:
+			data = data[n:]
+		}
+	}
+}
+
+// resizeSequence parses "ESC [ 8 ; rows ; cols t" at the start of data. It
+// returns the length consumed, 0 when data does not start the sequence, and
+// whether the data was conclusive.
+func (r *resizeReader) resizeSequence(data []byte) (int, bool) {
+	const prefix = "\x1b[8;"
+	if len(data) < len(prefix) {
+		return 0, !bytes.HasPrefix([]byte(prefix), data)
+	}
+	if string(data[:len(prefix)]) != prefix {
+		return 0, true
+	}
+
+	var fields [2]int
+	field, digits := 0, 0
+	for i := len(prefix); i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c >= '0' && c <= '9' && digits < 5:
+			fields[field] = fields[field]*10 + int(c-'0')
+			digits++
+		case c == ';' && field == 0 && digits > 0:
+			field, digits = 1, 0
+		case c == 't' && field == 1 && digits > 0:
+			r.apply(fields[1], fields[0])
+			return i + 1, true
+		default:
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// telnetCommand parses the telnet command at the start of data, applying a
+// window size subnegotiation. IAC IAC yields a literal 255.
+func (r *resizeReader) telnetCommand(data []byte) (int, bool) {
+	if len(data) < 2 {
+		return 0, false
+	}
+	switch data[1] {
+	case telnet.IAC:
+		r.out = append(r.out, telnet.IAC)
+		return 2, true
+	case telnet.DO, telnet.DONT, telnet.WILL, telnet.WONT:
+		if len(data) < 3 {
+			return 0, false
+		}
+		return 3, true
+	case telnet.SB:
+		// Collect the parameters up to IAC SE, undoing IAC doubling
+		var params []byte
+		for i := 2; i+1 < len(data); i++ {
+			if data[i] != telnet.IAC {
+				params = append(params, data[i])
+				continue
+			}
+			if data[i+1] == telnet.SE {
+				if len(params) == 5 && params[0] == telnet.WINDOW_SIZE {
+					cols := int(params[1])<<8 | int(params[2])
+					rows := int(params[3])<<8 | int(params[4])
+					r.apply(cols, rows)
+				}
+				return i + 2, true
+			}
+			params = append(params, data[i+1])
+			i++
+		}
+		return 0, false
+	default:
+		return 2, true
+	}
+}
+
+// apply resizes the terminal, ignoring sizes no terminal has
+func (r *resizeReader) apply(cols, rows int) {
+	if cols <= 0 || rows <= 0 || cols > 0xffff || rows > 0xffff {
+		return
+	}
+	if err := r.resize(cols, rows); err != nil {
+		logger.Debug("Failed to resize terminal to %dx%d: %v", cols, rows, err)
+		return
+	}
+	logger.Debug("Terminal resized to %dx%d", cols, rows)
+}
+
+// FormatResize returns the in-band message resizing a peer's terminal
+func FormatResize(cols, rows int) []byte {
+	return []byte("\x1b[8;" + strconv.Itoa(rows) + ";" + strconv.Itoa(cols) + "t")
+}
@@ -0,0 +1,257 @@
+// Package proxyproto implements the PROXY protocol of HAProxy, which
+// prefixes a connection with the addresses of the client it was accepted
+// from so the server behind a balancer sees who is really connecting.
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Version is a version of the PROXY protocol
+type Version byte
+
+// Versions of the PROXY protocol: v1 is a line of text, v2 a binary block
+const (
+	V1 Version = 1
+	V2 Version = 2
+)
+
+// ParseVersion parses "v1", "v2" or the bare numbers
+func ParseVersion(s string) (Version, error) {
+	switch strings.ToLower(s) {
+	case "1", "v1":
+		return V1, nil
+	case "2", "v2":
+		return V2, nil
+	}
+	return 0, fmt.Errorf("unknown PROXY protocol version %q (want v1 or v2)", s)
+}
+
+func (v Version) String() string {
+	return "v" + strconv.Itoa(int(v))
+}
+
+// Command tells the receiver whether the header describes a proxied client
+type Command byte
+
+// Commands of a v2 header. Local connections, such as health checks, carry
+// no addresses and are taken as coming from the sender itself.
+const (
+	CommandLocal Command = 0x0
+	CommandProxy Command = 0x1
+)
+
+// Signature starts every v2 header
+var Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v2UnixLength is the size of a Unix socket path in a v2 header
+const v2UnixLength = 108
+
+// Header is what a PROXY protocol header says about a connection
+type Header struct {
+	Version     Version
+	Command     Command
+	Source      net.Addr // the client
+	Destination net.Addr // the address the client connected to
+	TLVs        []TLV    // v2 only
+}
+
+// NewHeader returns a header describing a connection from source to
+// destination
+func NewHeader(version Version, source, destination net.Addr) *Header {
+	return &Header{
+		Version:     version,
+		Command:     CommandProxy,
+		Source:      source,
+		Destination: destination,
+	}
+}
+
+// Format encodes the header
+func (h *Header) Format() ([]byte, error) {
+	switch h.Version {
+	case V1:
+		return h.formatV1()
+	case V2:
+		return h.formatV2()
+	}
+	return nil, fmt.Errorf("unknown PROXY protocol version %d", h.Version)
+}
+
+// WriteTo writes the encoded header to w
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	b, err := h.Format()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// formatV1 encodes "PROXY TCP4 src dst sport dport\r\n". Anything but a
+// pair of TCP addresses of one family is sent as UNKNOWN, which the
+// receiver treats like a local connection.
+func (h *Header) formatV1() ([]byte, error) {
+	if _, ok := h.Source.(*net.UDPAddr); ok {
+		return nil, errors.New("PROXY protocol v1 cannot describe UDP")
+	}
+	src, srcOK := h.Source.(*net.TCPAddr)
+	dst, dstOK := h.Destination.(*net.TCPAddr)
+	if h.Command == CommandLocal || !srcOK || !dstOK {
+		return []byte("PROXY UNKNOWN\r\n"), nil
+	}
+
+	// IPv4 addresses next to IPv6 ones are written mapped
+	srcIP, srcOK := netip.AddrFromSlice(src.IP)
+	dstIP, dstOK := netip.AddrFromSlice(dst.IP)
+	if !srcOK || !dstOK {
+		return []byte("PROXY UNKNOWN\r\n"), nil
+	}
+	family := "TCP4"
+	if srcIP.Unmap().Is4() && dstIP.Unmap().Is4() {
+		srcIP, dstIP = srcIP.Unmap(), dstIP.Unmap()
+	} else {
+		family = "TCP6"
+		srcIP, dstIP = netip.AddrFrom16(srcIP.As16()), netip.AddrFrom16(dstIP.As16())
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, srcIP, dstIP, src.Port, dst.Port)), nil
+}
+
+// Address families and transports of a v2 header
+const (
+	familyUnspec = 0x00
+	familyInet   = 0x10
+	familyInet6  = 0x20
+	familyUnix   = 0x30
+
+	transportStream = 0x01
+	transportDgram  = 0x02
+)
+
+// formatV2 encodes the binary header: the signature, version and command,
+// family and transport, the length of what follows, the addresses and the
+// TLVs
+func (h *Header) formatV2() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(Signature)
+	buf.WriteByte(0x20 | byte(h.Command))
+
+	var addrs []byte
+	proto := byte(familyUnspec)
+	if h.Command == CommandProxy {
+		proto, addrs = v2Addresses(h.Source, h.Destination)
+	}
+	for _, tlv := range h.TLVs {
+		if len(tlv.Value) > 0xffff {
+			return nil, fmt.Errorf("TLV %#x of %d bytes is too long", tlv.Type, len(tlv.Value))
+		}
+		addrs = append(addrs, tlv.Type)
+		addrs = binary.BigEndian.AppendUint16(addrs, uint16(len(tlv.Value)))
+		addrs = append(addrs, tlv.Value...)
+	}
+	if len(addrs) > 0xffff {
+		return nil, errors.New("PROXY v2 header too long")
+	}
+	buf.WriteByte(proto)
+	binary.Write(&buf, binary.BigEndian, uint16(len(addrs)))
+	buf.Write(addrs)
+	return buf.Bytes(), nil
+}
+
+// v2Addresses encodes a pair of addresses with their family and transport.
+// Mixed families are sent as IPv6, with the IPv4 address mapped; pairs the
+// header cannot describe are sent as unspecified.
+func v2Addresses(source, destination net.Addr) (byte, []byte) {
+	var srcIP, dstIP net.IP
+	var srcPort, dstPort int
+	var transport byte
+	switch src := source.(type) {
+	case *net.TCPAddr:
+		dst, ok := destination.(*net.TCPAddr)
+		if !ok {
+			return familyUnspec, nil
+		}
+		srcIP, srcPort, dstIP, dstPort = src.IP, src.Port, dst.IP, dst.Port
+		transport = transportStream
+	case *net.UDPAddr:
+		dst, ok := destination.(*net.UDPAddr)
+		if !ok {
+			return familyUnspec, nil
+		}
+		srcIP, srcPort, dstIP, dstPort = src.IP, src.Port, dst.IP, dst.Port
+		transport = transportDgram
+	case *net.UnixAddr:
+		dst, ok := destination.(*net.UnixAddr)
+		if !ok {
+			return familyUnspec, nil
+		}
+		transport = transportStream
+		if src.Net == "unixgram" {
+			transport = transportDgram
+		}
+		addrs := make([]byte, 2*v2UnixLength)
+		copy(addrs[:v2UnixLength-1], src.Name)
+		copy(addrs[v2UnixLength:2*v2UnixLength-1], dst.Name)
+		return familyUnix | transport, addrs
+	default:
+		return familyUnspec, nil
+	}
+
+	family := byte(familyInet)
+	if srcIP.To4() != nil && dstIP.To4() != nil {
+		srcIP, dstIP = srcIP.To4(), dstIP.To4()
+	} else {
+		family = familyInet6
+		srcIP, dstIP = srcIP.To16(), dstIP.To16()
+	}
+	if srcIP == nil || dstIP == nil {
+		return familyUnspec, nil
+	}
+
+	addrs := make([]byte, 0, 2*len(srcIP)+4)
+	addrs = append(addrs, srcIP...)
+	addrs = append(addrs, dstIP...)
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(srcPort))
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(dstPort))
+	return family | transport, addrs
+}
+
+// datagramConn prefixes every datagram written with a header
+type datagramConn struct {
+	net.Conn
+	header []byte
+}
+
+// DatagramConn returns conn with the v2 header h in front of every
+// datagram written to it, the way the protocol carries UDP, where there is
+// no stream for a single header to start
+func DatagramConn(conn net.Conn, h *Header) (net.Conn, error) {
+	if h.Version != V2 {
+		return nil, fmt.Errorf("PROXY protocol %s cannot describe datagrams", h.Version)
+	}
+	header, err := h.Format()
+	if err != nil {
+		return nil, err
+	}
+	return &datagramConn{Conn: conn, header: header}, nil
+}
+
+func (c *datagramConn) Write(p []byte) (int, error) {
+	datagram := make([]byte, 0, len(c.header)+len(p))
+	datagram = append(datagram, c.header...)
+	datagram = append(datagram, p...)
+	n, err := c.Conn.Write(datagram)
+	n -= len(c.header)
+	if n < 0 {
+		n = 0
+	}
+	return n, err
+}
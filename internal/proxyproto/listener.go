@@ -0,0 +1,187 @@
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout is how long a connection gets to send its header
+const DefaultHeaderTimeout = 10 * time.Second
+
+// ListenerConfig configures a Listener
+type ListenerConfig struct {
+	// Trusted reports whether a peer, such as a load balancer, may speak
+	// for its clients. Trusted peers must send a header; connections from
+	// any other peer are passed on as they are, so a header they send is
+	// only data. Nil trusts no peer.
+	Trusted func(net.Addr) bool
+
+	// HeaderTimeout bounds the wait for the header, 0 uses the default
+	HeaderTimeout time.Duration
+
+	// OnError is called with the peer and the error when a connection is
+	// dropped for a missing or malformed header
+	OnError func(peer net.Addr, err error)
+}
+
+// Listener reads the PROXY protocol header of accepted connections. The
+// headers are read as connections arrive, so one that is slow to send its
+// header does not hold up the others.
+type Listener struct {
+	net.Listener
+	config ListenerConfig
+
+	ready     chan net.Conn
+	errc      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewListener returns ln with the headers of its connections read
+func NewListener(ln net.Listener, config *ListenerConfig) *Listener {
+	l := &Listener{
+		Listener: ln,
+		ready:    make(chan net.Conn),
+		errc:     make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+	if config != nil {
+		l.config = *config
+	}
+	if l.config.HeaderTimeout <= 0 {
+		l.config.HeaderTimeout = DefaultHeaderTimeout
+	}
+	go l.acceptLoop()
+	return l
+}
+
+func (l *Listener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errc <- err:
+			case <-l.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go l.readHeader(conn)
+	}
+}
+
+// readHeader hands conn to Accept once its header is read
+func (l *Listener) readHeader(conn net.Conn) {
+	if l.config.Trusted == nil || !l.config.Trusted(conn.RemoteAddr()) {
+		l.deliver(conn)
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(l.config.HeaderTimeout))
+	reader := bufio.NewReader(conn)
+	header, err := Read(reader)
+	if err != nil {
+		if l.config.OnError != nil {
+			l.config.OnError(conn.RemoteAddr(), err)
+		}
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	l.deliver(&Conn{Conn: conn, reader: reader, header: header})
+}
+
+func (l *Listener) deliver(conn net.Conn) {
+	select {
+	case l.ready <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// Accept returns the next connection whose header was read
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.ready:
+		return conn, nil
+	case err := <-l.errc:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener and the connections still sending headers
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// Conn is a connection whose header was read. Its addresses are those of
+// the client the header describes.
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+	header *Header
+}
+
+// Header returns the header the connection started with
+func (c *Conn) Header() *Header {
+	return c.header
+}
+
+// Read reads past the header
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the client the header describes, or the sender for
+// local connections
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.header.Command == CommandProxy && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the address the client connected to
+func (c *Conn) LocalAddr() net.Addr {
+	if c.header.Command == CommandProxy && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// ProxyAddr returns the address of the proxy that sent the header
+func (c *Conn) ProxyAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
+
+// CloseWrite shuts down the writing side of the connection
+func (c *Conn) CloseWrite() error {
+	if hc, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return hc.CloseWrite()
+	}
+	return errors.New("connection cannot be half-closed")
+}
+
+// Unwrap lets relays splice the connection once the data that arrived
+// with the header has been read
+func (c *Conn) Unwrap() net.Conn {
+	if c.reader.Buffered() > 0 {
+		return nil
+	}
+	return c.Conn
+}
+
+// CountRead is not counted
+func (c *Conn) CountRead(int64) {}
+
+// CountWritten is not counted
+func (c *Conn) CountWritten(int64) {}
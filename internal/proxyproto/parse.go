@@ -0,0 +1,262 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrNoHeader is returned by Read when the connection does not start with
+// a PROXY protocol header
+var ErrNoHeader = errors.New("no PROXY protocol header")
+
+// MaxV1Length is the longest v1 line, CRLF included
+const MaxV1Length = 107
+
+// v2HeaderLength is the fixed part of a v2 header, before the addresses
+const v2HeaderLength = 16
+
+// Types of the TLVs following the addresses of a v2 header
+const (
+	TLVTypeALPN      byte = 0x01
+	TLVTypeAuthority byte = 0x02
+	TLVTypeCRC32C    byte = 0x03
+	TLVTypeNoop      byte = 0x04
+	TLVTypeUniqueID  byte = 0x05
+	TLVTypeSSL       byte = 0x20
+	TLVTypeNetNS     byte = 0x30
+)
+
+// TLV is a type-length-value field of a v2 header
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// TLV returns the value of the first TLV of type t
+func (h *Header) TLV(t byte) ([]byte, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == t {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Authority returns the host name the client asked for, such as the TLS
+// server name, if the sender passed it on
+func (h *Header) Authority() string {
+	v, _ := h.TLV(TLVTypeAuthority)
+	return string(v)
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Read reads a v1 or v2 header from the start of r. It returns ErrNoHeader
+// when r starts with anything else, having consumed nothing.
+func Read(r *bufio.Reader) (*Header, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch first[0] {
+	case 'P':
+		if prefix, err := r.Peek(6); err != nil || string(prefix) != "PROXY " {
+			return nil, ErrNoHeader
+		}
+		return readV1(r)
+	case Signature[0]:
+		if sig, err := r.Peek(len(Signature)); err != nil || !bytes.Equal(sig, Signature) {
+			return nil, ErrNoHeader
+		}
+		return readV2(r)
+	}
+	return nil, ErrNoHeader
+}
+
+// readV1 reads "PROXY TCP4|TCP6 src dst sport dport\r\n" or
+// "PROXY UNKNOWN ...\r\n"
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < MaxV1Length {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("truncated PROXY v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("PROXY v1 header too long or not ended by CRLF")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	h := &Header{Version: V1, Command: CommandProxy}
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		h.Command = CommandLocal
+		return h, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("malformed PROXY v1 header %q", line)
+	}
+
+	src, err := v1Address(fields[2], fields[4], fields[1])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := v1Address(fields[3], fields[5], fields[1])
+	if err != nil {
+		return nil, err
+	}
+	h.Source, h.Destination = src, dst
+	return h, nil
+}
+
+// v1Address parses an address and port of a v1 header of the family
+func v1Address(ip, port, family string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || (family == "TCP4") != (addr.To4() != nil && !strings.Contains(ip, ":")) {
+		return nil, fmt.Errorf("invalid %s address %q in PROXY v1 header", family, ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || (len(port) > 1 && port[0] == '0') {
+		return nil, fmt.Errorf("invalid port %q in PROXY v1 header", port)
+	}
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+// readV2 reads the binary header and its TLVs, checking the CRC32C when
+// the sender included one
+func readV2(r *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("truncated PROXY v2 header: %w", err)
+	}
+	if fixed[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY v2 version %d", fixed[12]>>4)
+	}
+	h := &Header{Version: V2, Command: Command(fixed[12] & 0x0f)}
+	if h.Command != CommandLocal && h.Command != CommandProxy {
+		return nil, fmt.Errorf("unknown PROXY v2 command %#x", byte(h.Command))
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("truncated PROXY v2 header: %w", err)
+	}
+
+	var addrLen int
+	family, transport := fixed[13]&0xf0, fixed[13]&0x0f
+	switch family {
+	case familyInet:
+		addrLen = 12
+	case familyInet6:
+		addrLen = 36
+	case familyUnix:
+		addrLen = 2 * v2UnixLength
+	case familyUnspec:
+	default:
+		return nil, fmt.Errorf("unknown PROXY v2 address family %#x", family)
+	}
+	if len(body) < addrLen {
+		return nil, fmt.Errorf("PROXY v2 addresses need %d bytes, header has %d", addrLen, len(body))
+	}
+
+	tlvs, err := parseTLVs(body[addrLen:])
+	if err != nil {
+		return nil, err
+	}
+	h.TLVs = tlvs
+	if err := checkCRC(fixed, body, addrLen); err != nil {
+		return nil, err
+	}
+
+	// Local connections come from the sender itself, whatever it says
+	if h.Command == CommandProxy && family != familyUnspec {
+		h.Source, h.Destination = v2ParseAddresses(family, transport, body[:addrLen])
+	}
+	return h, nil
+}
+
+// v2ParseAddresses decodes the addresses of a v2 header
+func v2ParseAddresses(family, transport byte, b []byte) (net.Addr, net.Addr) {
+	if family == familyUnix {
+		network := "unix"
+		if transport == transportDgram {
+			network = "unixgram"
+		}
+		name := func(b []byte) string {
+			if i := bytes.IndexByte(b, 0); i >= 0 {
+				return string(b[:i])
+			}
+			return string(b)
+		}
+		return &net.UnixAddr{Name: name(b[:v2UnixLength]), Net: network},
+			&net.UnixAddr{Name: name(b[v2UnixLength:]), Net: network}
+	}
+
+	size := net.IPv4len
+	if family == familyInet6 {
+		size = net.IPv6len
+	}
+	srcIP := net.IP(append([]byte(nil), b[:size]...))
+	dstIP := net.IP(append([]byte(nil), b[size:2*size]...))
+	srcPort := int(binary.BigEndian.Uint16(b[2*size:]))
+	dstPort := int(binary.BigEndian.Uint16(b[2*size+2:]))
+	if transport == transportDgram {
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}
+}
+
+// parseTLVs splits the TLVs after the addresses
+func parseTLVs(b []byte) ([]TLV, error) {
+	var tlvs []TLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, errors.New("truncated TLV in PROXY v2 header")
+		}
+		n := int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+n {
+			return nil, fmt.Errorf("TLV %#x of %d bytes overruns the PROXY v2 header", b[0], n)
+		}
+		tlvs = append(tlvs, TLV{Type: b[0], Value: append([]byte(nil), b[3:3+n]...)})
+		b = b[3+n:]
+	}
+	return tlvs, nil
+}
+
+// checkCRC verifies the CRC32C TLV, computed over the whole header with
+// its own value zeroed
+func checkCRC(fixed, body []byte, addrLen int) error {
+	for b := body[addrLen:]; len(b) >= 3; {
+		n := int(binary.BigEndian.Uint16(b[1:3]))
+		if b[0] != TLVTypeCRC32C {
+			b = b[3+n:]
+			continue
+		}
+		if n != 4 {
+			return fmt.Errorf("CRC32C TLV of %d bytes in PROXY v2 header", n)
+		}
+		want := binary.BigEndian.Uint32(b[3:7])
+		zeroed := append([]byte(nil), body...)
+		offset := len(body) - len(b) + 3
+		copy(zeroed[offset:offset+4], []byte{0, 0, 0, 0})
+
+		crc := crc32.Update(crc32.Checksum(fixed, castagnoli), castagnoli, zeroed)
+		if crc != want {
+			return fmt.Errorf("PROXY v2 header checksum %#08x does not match %#08x", crc, want)
+		}
+		return nil
+	}
+	return nil
+}
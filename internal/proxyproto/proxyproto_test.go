@@ -0,0 +1,278 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func tcpAddr(s string) *net.TCPAddr {
+	addr, err := net.ResolveTCPAddr("tcp", s)
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+func TestFormatV1(t *testing.T) {
+	tests := []struct {
+		src, dst string
+		want     string
+	}{
+		{"192.0.2.1:56324", "198.51.100.7:5432", "PROXY TCP4 192.0.2.1 198.51.100.7 56324 5432\r\n"},
+		{"[2001:db8::1]:56324", "[2001:db8::2]:443", "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"},
+		{"192.0.2.1:56324", "[2001:db8::2]:443", "PROXY TCP6 ::ffff:192.0.2.1 2001:db8::2 56324 443\r\n"},
+	}
+	for _, tt := range tests {
+		b, err := NewHeader(V1, tcpAddr(tt.src), tcpAddr(tt.dst)).Format()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s -> %s: got %q, want %q", tt.src, tt.dst, b, tt.want)
+		}
+	}
+
+	local := &Header{Version: V1, Command: CommandLocal}
+	if b, _ := local.Format(); string(b) != "PROXY UNKNOWN\r\n" {
+		t.Errorf("local header = %q", b)
+	}
+	udp := NewHeader(V1, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 514}, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 514})
+	if _, err := udp.Format(); err == nil {
+		t.Error("v1 encoded a UDP header")
+	}
+}
+
+func TestFormatV2(t *testing.T) {
+	b, err := NewHeader(V2, tcpAddr("192.0.2.1:56324"), tcpAddr("198.51.100.7:5432")).Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0d0a0d0a000d0a515549540a" + // signature
+		"21" + "11" + "000c" + // v2 PROXY, TCP over IPv4, 12 bytes
+		"c0000201" + "c6336407" + "dc04" + "1538"
+	if got := hex.EncodeToString(b); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	local, _ := (&Header{Version: V2, Command: CommandLocal}).Format()
+	if got := hex.EncodeToString(local[12:]); got != "20000000" {
+		t.Errorf("local header ends %s, want 20000000", got)
+	}
+
+	b, _ = NewHeader(V2, &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 514}, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 514}).Format()
+	if b[13] != 0x22 || len(b) != 16+36 {
+		t.Errorf("mixed UDP header: family %#x, %d bytes", b[13], len(b))
+	}
+}
+
+func TestDatagramConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	h := NewHeader(V2, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5000}, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 514})
+	if _, err := DatagramConn(a, &Header{Version: V1}); err == nil {
+		t.Error("v1 datagram conn created")
+	}
+	conn, err := DatagramConn(a, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := h.Format()
+
+	go func() {
+		if n, err := conn.Write([]byte("<13>hello")); n != 9 || err != nil {
+			t.Errorf("Write = %d, %v", n, err)
+		}
+	}()
+	buf := make([]byte, 256)
+	n, _ := b.Read(buf)
+	if !bytes.HasPrefix(buf[:n], header) || string(buf[len(header):n]) != "<13>hello" {
+		t.Errorf("datagram = %q", buf[:n])
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	headers := []*Header{
+		NewHeader(V1, tcpAddr("192.0.2.1:56324"), tcpAddr("198.51.100.7:5432")),
+		NewHeader(V1, tcpAddr("[2001:db8::1]:1"), tcpAddr("[2001:db8::2]:2")),
+		NewHeader(V2, tcpAddr("192.0.2.1:56324"), tcpAddr("198.51.100.7:5432")),
+		NewHeader(V2, &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 514}, &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 514}),
+		NewHeader(V2, &net.UnixAddr{Name: "/run/client.sock", Net: "unix"}, &net.UnixAddr{Name: "/run/app.sock", Net: "unix"}),
+	}
+	headers[2].TLVs = []TLV{{TLVTypeAuthority, []byte("db.example.com")}, {TLVTypeUniqueID, []byte{1, 2, 3}}}
+
+	for _, h := range headers {
+		b, err := h.Format()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bufio.NewReader(bytes.NewReader(append(b, "payload"...)))
+		got, err := Read(r)
+		if err != nil {
+			t.Fatalf("%s %v: %v", h.Version, h.Source, err)
+		}
+		if got.Source.String() != h.Source.String() || got.Destination.String() != h.Destination.String() {
+			t.Errorf("%s: read %v -> %v, want %v -> %v", h.Version, got.Source, got.Destination, h.Source, h.Destination)
+		}
+		if rest, _ := io.ReadAll(r); string(rest) != "payload" {
+			t.Errorf("%s: data after header = %q", h.Version, rest)
+		}
+	}
+
+	got, _ := Read(bufio.NewReader(bytes.NewReader(mustFormat(headers[2]))))
+	if got.Authority() != "db.example.com" || len(got.TLVs) != 2 {
+		t.Errorf("TLVs = %+v", got.TLVs)
+	}
+}
+
+func mustFormat(h *Header) []byte {
+	b, err := h.Format()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestReadRejects(t *testing.T) {
+	h := NewHeader(V2, tcpAddr("192.0.2.1:1"), tcpAddr("192.0.2.2:2"))
+	h.TLVs = []TLV{{TLVTypeCRC32C, make([]byte, 4)}}
+	b := mustFormat(h)
+	crc := crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli))
+	binary.BigEndian.PutUint32(b[len(b)-4:], crc)
+	if _, err := Read(bufio.NewReader(bytes.NewReader(b))); err != nil {
+		t.Errorf("valid checksum rejected: %v", err)
+	}
+	b[20]++
+	if _, err := Read(bufio.NewReader(bytes.NewReader(b))); err == nil {
+		t.Error("corrupted header passed its checksum")
+	}
+
+	for _, input := range []string{
+		"GET / HTTP/1.1\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 1\r\n",
+		"PROXY TCP4 2001:db8::1 192.0.2.2 1 2\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 1 2\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 1 99999\r\n",
+	} {
+		if _, err := Read(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("%q read as a header", input)
+		}
+	}
+	if _, err := Read(bufio.NewReader(strings.NewReader("SSH-2.0-OpenSSH\r\n"))); err != ErrNoHeader {
+		t.Errorf("err = %v, want ErrNoHeader", err)
+	}
+	local, err := Read(bufio.NewReader(strings.NewReader("PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n")))
+	if err != nil || local.Command != CommandLocal {
+		t.Errorf("UNKNOWN read as %+v, %v", local, err)
+	}
+}
+
+func TestListener(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 4)
+	var untrusted atomic.Bool
+	pl := NewListener(ln, &ListenerConfig{
+		Trusted:       func(net.Addr) bool { return !untrusted.Load() },
+		HeaderTimeout: 200 * time.Millisecond,
+		OnError:       func(_ net.Addr, err error) { errs <- err },
+	})
+	defer pl.Close()
+
+	// One client stalling on its header does not hold up the next
+	stalled, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	stalled.Write([]byte("PRO"))
+
+	c, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write(append(mustFormat(NewHeader(V2, tcpAddr("203.0.113.9:4000"), tcpAddr("192.0.2.80:80"))), "hello"...))
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.RemoteAddr().String() != "203.0.113.9:4000" || conn.LocalAddr().String() != "192.0.2.80:80" {
+		t.Errorf("addresses %v -> %v", conn.RemoteAddr(), conn.LocalAddr())
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("read %q, %v", buf, err)
+	}
+	conn.Close()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("stalled header dropped without an error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("stalled header was not dropped")
+	}
+
+	// Untrusted peers are taken as they are; a header they forge is data
+	untrusted.Store(true)
+	forged := "PROXY TCP4 203.0.113.9 192.0.2.80 4000 80\r\n"
+	c2, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	c2.Write([]byte(forged))
+	conn, err = pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.RemoteAddr().String() != c2.LocalAddr().String() {
+		t.Errorf("untrusted peer seen as %v", conn.RemoteAddr())
+	}
+	buf = make([]byte, len(forged))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != forged {
+		t.Errorf("forged header read as %q, %v", buf, err)
+	}
+	conn.Close()
+
+	// Without Trusted no peer is trusted
+	ln2, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := NewListener(ln2, nil)
+	defer open.Close()
+	c3, err := net.Dial("tcp4", ln2.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c3.Close()
+	c3.Write([]byte(forged))
+	if conn, err = open.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conn.(*Conn); ok || conn.RemoteAddr().String() != c3.LocalAddr().String() {
+		t.Errorf("nil Trusted read the header of %v", conn.RemoteAddr())
+	}
+	conn.Close()
+
+	pl.Close()
+	if _, err := pl.Accept(); err == nil {
+		t.Error("Accept succeeded after Close")
+	}
+}
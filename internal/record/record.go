@@ -0,0 +1,294 @@
+// Package record captures relayed sessions with their timing to a file and
+// reads them back for replay.
+//
+// A recording starts with a 4-byte magic and a version, followed by
+// blocks of a 1-byte type, a 4-byte big-endian body length and the body.
+// Readers skip block types they do not know, so new ones can be added
+// without breaking older readers.
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/relay"
+)
+
+// Magic starts every recording
+const Magic = "GCR\x00"
+
+// Version is the format version written by Recorder
+const Version = 1
+
+// Block types
+const (
+	blockSession = 1 // JSON SessionInfo
+	blockData    = 2 // session ID, offset, direction, data
+	blockEnd     = 3 // JSON sessionEnd
+)
+
+// maxBlock bounds the blocks accepted by Load
+const maxBlock = 16 << 20
+
+// ErrNotRecording is returned when a file does not start with Magic
+var ErrNotRecording = errors.New("not a gocat recording")
+
+// Endpoint describes one side of a recorded relay
+type Endpoint struct {
+	Kind    string `json:"kind"` // conn, websocket, exec or stdio
+	Network string `json:"network,omitempty"`
+	Local   string `json:"local,omitempty"`
+	Remote  string `json:"remote,omitempty"`
+}
+
+// SessionInfo describes a recorded relay. Data of the Forward direction
+// went from A to B.
+type SessionInfo struct {
+	ID      uint32    `json:"id"`
+	Start   time.Time `json:"start"`
+	Command string    `json:"command,omitempty"`
+	A       Endpoint  `json:"a"`
+	B       Endpoint  `json:"b"`
+}
+
+// sessionEnd closes a session in the recording
+type sessionEnd struct {
+	ID       uint32        `json:"id"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Recorder writes sessions to a recording. It is safe for concurrent use;
+// the blocks of concurrent sessions are interleaved.
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	nextID uint32
+	err    error
+}
+
+// Create creates a recording at path, replacing any file there
+func Create(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := NewRecorder(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	rec.closer = file
+	return rec, nil
+}
+
+// NewRecorder starts a recording on w
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	header := make([]byte, 8)
+	copy(header, Magic)
+	binary.BigEndian.PutUint16(header[4:], Version)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w}, nil
+}
+
+// Start begins a session. The ID and start time of info are assigned.
+func (r *Recorder) Start(info SessionInfo) *Session {
+	r.mu.Lock()
+	r.nextID++
+	info.ID = r.nextID
+	r.mu.Unlock()
+
+	info.Start = time.Now()
+	body, _ := json.Marshal(info)
+	r.writeBlock(blockSession, body)
+	return &Session{rec: r, id: info.ID, start: info.Start}
+}
+
+// Err returns the first write error of the recording
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the file of a recording made by Create
+func (r *Recorder) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// writeBlock writes a block in one write so blocks never interleave. After
+// the first failure the recording stops.
+func (r *Recorder) writeBlock(kind byte, body []byte) {
+	block := make([]byte, 5+len(body))
+	block[0] = kind
+	binary.BigEndian.PutUint32(block[1:], uint32(len(body)))
+	copy(block[5:], body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		_, r.err = r.w.Write(block)
+	}
+}
+
+// Session records the data of one relay
+type Session struct {
+	rec   *Recorder
+	id    uint32
+	start time.Time
+}
+
+// Middleware returns the relay middleware recording what both directions
+// write, stamped with the time since the session started
+func (s *Session) Middleware() relay.Middleware {
+	return func(dir relay.Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader) {
+		return &recordingWriter{session: s, dir: dir, dst: dst}, src
+	}
+}
+
+// End closes the session with the error the relay ended with
+func (s *Session) End(err error) {
+	end := sessionEnd{ID: s.id, Duration: time.Since(s.start)}
+	if err != nil {
+		end.Error = err.Error()
+	}
+	body, _ := json.Marshal(end)
+	s.rec.writeBlock(blockEnd, body)
+}
+
+// data records a chunk of one direction
+func (s *Session) data(dir relay.Direction, p []byte) {
+	body := make([]byte, 13+len(p))
+	binary.BigEndian.PutUint32(body, s.id)
+	binary.BigEndian.PutUint64(body[4:], uint64(time.Since(s.start)))
+	body[12] = byte(dir)
+	copy(body[13:], p)
+	s.rec.writeBlock(blockData, body)
+}
+
+// recordingWriter records what is written to the destination of a direction
+type recordingWriter struct {
+	session *Session
+	dir     relay.Direction
+	dst     io.Writer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.dst.Write(p)
+	if n > 0 {
+		w.session.data(w.dir, p[:n])
+	}
+	return n, err
+}
+
+// Event is a chunk of recorded data
+type Event struct {
+	Offset time.Duration   // time since the session started
+	Dir    relay.Direction // Forward went from A to B
+	Data   []byte
+}
+
+// SessionLog is a recorded session read back by Load
+type SessionLog struct {
+	SessionInfo
+	Events   []Event
+	Duration time.Duration
+	Error    string
+	Complete bool // the session end was recorded
+}
+
+// Bytes returns the bytes recorded in dir
+func (l *SessionLog) Bytes(dir relay.Direction) int64 {
+	var n int64
+	for _, ev := range l.Events {
+		if ev.Dir == dir {
+			n += int64(len(ev.Data))
+		}
+	}
+	return n
+}
+
+// LoadFile reads the sessions of the recording at path
+func LoadFile(path string) ([]*SessionLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+// Load reads the sessions of a recording in the order they started. A
+// recording cut short, for example by a crash, yields what it holds.
+func Load(r io.Reader) ([]*SessionLog, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:4]) != Magic {
+		return nil, ErrNotRecording
+	}
+	if v := binary.BigEndian.Uint16(header[4:]); v > Version {
+		return nil, fmt.Errorf("recording version %d is newer than supported version %d", v, Version)
+	}
+
+	var sessions []*SessionLog
+	byID := make(map[uint32]*SessionLog)
+	for {
+		var head [5]byte
+		if _, err := io.ReadFull(br, head[:]); err != nil {
+			// A partial block at the end is a recording cut short
+			return sessions, nil
+		}
+		size := binary.BigEndian.Uint32(head[1:])
+		if size > maxBlock {
+			return sessions, fmt.Errorf("block of %d bytes exceeds %d", size, maxBlock)
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(br, body); err != nil {
+			return sessions, nil
+		}
+
+		switch head[0] {
+		case blockSession:
+			log := &SessionLog{}
+			if err := json.Unmarshal(body, &log.SessionInfo); err != nil {
+				return sessions, fmt.Errorf("invalid session block: %w", err)
+			}
+			sessions = append(sessions, log)
+			byID[log.ID] = log
+		case blockData:
+			if len(body) < 13 {
+				return sessions, errors.New("invalid data block")
+			}
+			log := byID[binary.BigEndian.Uint32(body)]
+			if log == nil {
+				continue
+			}
+			log.Events = append(log.Events, Event{
+				Offset: time.Duration(binary.BigEndian.Uint64(body[4:])),
+				Dir:    relay.Direction(body[12]),
+				Data:   body[13:],
+			})
+		case blockEnd:
+			var end sessionEnd
+			if err := json.Unmarshal(body, &end); err != nil {
+				return sessions, fmt.Errorf("invalid end block: %w", err)
+			}
+			if log := byID[end.ID]; log != nil {
+				log.Duration, log.Error, log.Complete = end.Duration, end.Error, true
+			}
+		}
+	}
+}
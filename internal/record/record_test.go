@@ -0,0 +1,191 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/relay"
+)
+
+// recordExchange records a relay where A sends "hello", B answers "world"
+func recordExchange(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	rec, err := NewRecorder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := rec.Start(SessionInfo{
+		Command: "connect",
+		A:       Endpoint{Kind: "stdio"},
+		B:       Endpoint{Kind: "conn", Network: "tcp", Local: "127.0.0.1:40000", Remote: "127.0.0.1:8080"},
+	})
+
+	// One direction after the other keeps the order of the events fixed
+	var outA, outB bytes.Buffer
+	a := relay.Stdio(strings.NewReader("hello"), &outA)
+	b := relay.Stdio(strings.NewReader("world"), &outB)
+	for _, mode := range []relay.RelayMode{relay.ModeForward, relay.ModeReverse} {
+		e := relay.NewEngine().Use(session.Middleware())
+		e.Mode = mode
+		if _, err := e.Run(context.Background(), a, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session.End(nil)
+	return buf.Bytes()
+}
+
+func TestRecordLoad(t *testing.T) {
+	data := recordExchange(t)
+
+	sessions, err := Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(sessions))
+	}
+	s := sessions[0]
+	if s.ID != 1 || s.Command != "connect" || s.B.Remote != "127.0.0.1:8080" {
+		t.Errorf("session info = %+v", s.SessionInfo)
+	}
+	if !s.Complete {
+		t.Error("session end missing")
+	}
+	if len(s.Events) != 2 || string(s.Events[0].Data) != "hello" || s.Events[1].Dir != relay.Reverse ||
+		string(s.Events[1].Data) != "world" || s.Events[1].Offset < s.Events[0].Offset {
+		t.Errorf("events = %+v", s.Events)
+	}
+	if s.Bytes(relay.Forward) != 5 {
+		t.Errorf("forward bytes = %d, want 5", s.Bytes(relay.Forward))
+	}
+}
+
+func TestLoadTruncated(t *testing.T) {
+	data := recordExchange(t)
+	sessions, err := Load(bytes.NewReader(data[:len(data)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Complete {
+		t.Errorf("truncated recording gave %d sessions, complete %v", len(sessions), sessions[0].Complete)
+	}
+
+	if _, err := Load(strings.NewReader("not a recording")); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("Load() error = %v, want ErrNotRecording", err)
+	}
+}
+
+// serve runs handler for one connection on a loopback listener
+func serve(t *testing.T, handler func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}()
+	return ln.Addr().String()
+}
+
+func testSession() *SessionLog {
+	return &SessionLog{Events: []Event{
+		{Offset: 0, Dir: relay.Forward, Data: []byte("PING\n")},
+		{Offset: 10 * time.Millisecond, Dir: relay.Reverse, Data: []byte("PONG\n")},
+		{Offset: 20 * time.Millisecond, Dir: relay.Forward, Data: []byte("QUIT\n")},
+	}}
+}
+
+func TestReplayAssert(t *testing.T) {
+	addr := serve(t, func(conn net.Conn) {
+		buf := make([]byte, 5)
+		io.ReadFull(conn, buf)
+		conn.Write([]byte("PONG\n"))
+		io.ReadFull(conn, buf)
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := &Replayer{Session: testSession(), Play: relay.Forward, Speed: 1, Assert: true, Timeout: 2 * time.Second}
+	result, err := r.Run(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 10 || result.Received != 5 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	addr := serve(t, func(conn net.Conn) {
+		buf := make([]byte, 5)
+		io.ReadFull(conn, buf)
+		conn.Write([]byte("PANG\n"))
+		io.Copy(io.Discard, conn)
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := &Replayer{Session: testSession(), Play: relay.Forward, Assert: true, Timeout: 2 * time.Second}
+	if _, err := r.Run(context.Background(), conn); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Run() error = %v, want ErrMismatch", err)
+	}
+}
+
+func TestReplayServerSide(t *testing.T) {
+	// Playing Reverse answers like the recorded server did
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		r := &Replayer{Session: testSession(), Play: relay.Reverse, Assert: true, Timeout: 2 * time.Second}
+		_, err = r.Run(context.Background(), conn)
+		done <- err
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	client.Write([]byte("PING\n"))
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(client, reply); err != nil || string(reply) != "PONG\n" {
+		t.Fatalf("reply = %q, %v", reply, err)
+	}
+	client.Write([]byte("QUIT\n"))
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,159 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/relay"
+)
+
+// ErrMismatch is returned by Replay when asserted data differs from the
+// recording
+var ErrMismatch = errors.New("response does not match the recording")
+
+// Replayer reproduces one side of a recorded session against a live peer.
+// It sends the data of the played direction and waits for the data of the
+// other one before going on, so the conversation keeps its order.
+type Replayer struct {
+	Session *SessionLog
+	Play    relay.Direction // direction whose data is sent
+	Speed   float64         // timing scale: 2 is twice as fast, 0 sends without delays
+	Assert  bool            // fail when the peer's data differs from the recording
+	Timeout time.Duration   // how long to wait for each expected chunk
+}
+
+// ReplayResult describes a finished replay
+type ReplayResult struct {
+	Sent     int64
+	Received int64
+	Expected int64
+}
+
+// Run replays the session on conn. The caller closes conn.
+func (r *Replayer) Run(ctx context.Context, conn net.Conn) (ReplayResult, error) {
+	var result ReplayResult
+	in := newInbox(conn)
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	var last time.Duration
+	for _, ev := range r.Session.Events {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if ev.Dir == r.Play {
+			if r.Speed > 0 {
+				delay := time.Duration(float64(ev.Offset-last) / r.Speed)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return result, ctx.Err()
+				}
+			}
+			last = ev.Offset
+			if _, err := conn.Write(ev.Data); err != nil {
+				return result, fmt.Errorf("send failed after %d bytes: %w", result.Sent, err)
+			}
+			result.Sent += int64(len(ev.Data))
+			continue
+		}
+
+		// Wait for the peer's part before going on
+		last = ev.Offset
+		start := result.Expected
+		result.Expected += int64(len(ev.Data))
+		got, err := in.waitFor(ctx, result.Expected, timeout)
+		result.Received = int64(len(got))
+		if err != nil {
+			if r.Assert {
+				return result, fmt.Errorf("waiting for %d bytes at offset %d: %w", len(ev.Data), start, err)
+			}
+			logger.Debug("Replay: peer sent %d of %d expected bytes: %v", len(got), result.Expected, err)
+			continue
+		}
+		if r.Assert && !bytes.Equal(got[start:result.Expected], ev.Data) {
+			return result, fmt.Errorf("%w at offset %d: got %q, want %q", ErrMismatch, start,
+				got[start:result.Expected], ev.Data)
+		}
+	}
+
+	if hc, ok := conn.(interface{ CloseWrite() error }); ok {
+		hc.CloseWrite()
+	}
+	if got := in.received(); int64(len(got)) > result.Received {
+		result.Received = int64(len(got))
+	}
+	return result, nil
+}
+
+// inbox collects what the peer sends
+type inbox struct {
+	mu     sync.Mutex
+	data   []byte
+	err    error
+	notify chan struct{}
+}
+
+func newInbox(conn net.Conn) *inbox {
+	in := &inbox{notify: make(chan struct{}, 1)}
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := conn.Read(buf)
+			in.mu.Lock()
+			in.data = append(in.data, buf[:n]...)
+			if err != nil {
+				in.err = err
+			}
+			in.mu.Unlock()
+			select {
+			case in.notify <- struct{}{}:
+			default:
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return in
+}
+
+func (in *inbox) received() []byte {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.data
+}
+
+// waitFor waits until n bytes arrived and returns all received so far
+func (in *inbox) waitFor(ctx context.Context, n int64, timeout time.Duration) ([]byte, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		in.mu.Lock()
+		data, err := in.data, in.err
+		in.mu.Unlock()
+		if int64(len(data)) >= n {
+			return data, nil
+		}
+		if err != nil {
+			return data, err
+		}
+		select {
+		case <-in.notify:
+		case <-deadline.C:
+			return data, errors.New("timed out")
+		case <-ctx.Done():
+			return data, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package relay
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrNoHalfClose is returned by CloseWrite of endpoints that cannot signal
+// the end of their input to the peer
+var ErrNoHalfClose = errors.New("endpoint does not support half-close")
+
+// Endpoint is one side of a relay
+type Endpoint interface {
+	io.Reader
+	io.Writer
+	// CloseWrite tells the peer no more data follows while reads go on
+	CloseWrite() error
+	// Close releases the endpoint
+	Close() error
+}
+
+// halfCloser is implemented by connections that can shut down their write side
+type halfCloser interface {
+	CloseWrite() error
+}
+
+// connEndpoint relays a connection: TCP, UDP, Unix, SCTP, TLS or a wrapper
+// of one. It is itself a net.Conn so Copy can splice the socket below it.
+type connEndpoint struct {
+	net.Conn
+}
+
+// Conn returns an endpoint for a connection. Connections without a
+// CloseWrite method, like UDP, end the relay when their peer is done.
+func Conn(c net.Conn) Endpoint {
+	return &connEndpoint{Conn: c}
+}
+
+func (c *connEndpoint) CloseWrite() error {
+	if hc, ok := c.Conn.(halfCloser); ok {
+		return hc.CloseWrite()
+	}
+	return ErrNoHalfClose
+}
+
+// Unwrap returns the connection for Copy
+func (c *connEndpoint) Unwrap() net.Conn { return c.Conn }
+
+func (c *connEndpoint) CountRead(int64) {}
+
+func (c *connEndpoint) CountWritten(int64) {}
+
+// streamEndpoint relays a reader and a writer, such as stdin and stdout
+type streamEndpoint struct {
+	io.Reader
+	io.Writer
+	rw any
+}
+
+// Stdio returns an endpoint reading in and writing out. It cannot be
+// half-closed and closing it leaves in and out open.
+func Stdio(in io.Reader, out io.Writer) Endpoint {
+	return &streamEndpoint{Reader: in, Writer: out}
+}
+
+// ReadWriter returns an endpoint for rw. Connections get the same handling
+// as with Conn; other values are half-closed and closed through CloseWrite
+// and Close methods if they have them.
+func ReadWriter(rw io.ReadWriter) Endpoint {
+	if c, ok := rw.(net.Conn); ok {
+		return Conn(c)
+	}
+	return &streamEndpoint{Reader: rw, Writer: rw, rw: rw}
+}
+
+func (s *streamEndpoint) CloseWrite() error {
+	if hc, ok := s.rw.(halfCloser); ok {
+		return hc.CloseWrite()
+	}
+	return ErrNoHalfClose
+}
+
+func (s *streamEndpoint) Close() error {
+	if c, ok := s.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SetDeadline interrupts blocked I/O where the underlying stream allows it
+func (s *streamEndpoint) SetDeadline(t time.Time) error {
+	if d, ok := s.rw.(deadliner); ok {
+		return d.SetDeadline(t)
+	}
+	return errors.ErrUnsupported
+}
+
+// WebSocketEndpoint relays the data messages of a WebSocket connection
+type WebSocketEndpoint struct {
+	ws *websocket.Conn
+	// MessageType is used for writes, unless the endpoint is bridged to
+	// another WebSocket whose message types it mirrors
+	MessageType int
+
+	reader   io.Reader
+	lastType atomic.Int32
+	peer     *WebSocketEndpoint
+}
+
+// WebSocket returns an endpoint sending binary messages on ws. CloseWrite
+// sends a close frame, after which the peer's messages are still read.
+func WebSocket(ws *websocket.Conn) *WebSocketEndpoint {
+	return &WebSocketEndpoint{ws: ws, MessageType: websocket.BinaryMessage}
+}
+
+// WebSocketBridge returns endpoints for relaying between two WebSockets
+// that keep the text or binary type of every message
+func WebSocketBridge(a, b *websocket.Conn) (*WebSocketEndpoint, *WebSocketEndpoint) {
+	epA, epB := WebSocket(a), WebSocket(b)
+	epA.peer, epB.peer = epB, epA
+	return epA, epB
+}
+
+// Read returns data from the current message, moving on to the next one
+// when it is exhausted. A normal close by the peer reads as EOF.
+func (w *WebSocketEndpoint) Read(p []byte) (int, error) {
+	for {
+		if w.reader == nil {
+			messageType, r, err := w.ws.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			w.lastType.Store(int32(messageType))
+			w.reader = r
+		}
+		n, err := w.reader.Read(p)
+		if err == io.EOF {
+			w.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (w *WebSocketEndpoint) Write(p []byte) (int, error) {
+	messageType := w.MessageType
+	if w.peer != nil {
+		if t := int(w.peer.lastType.Load()); t != 0 {
+			messageType = t
+		}
+	}
+	if err := w.ws.WriteMessage(messageType, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *WebSocketEndpoint) CloseWrite() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	return w.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+func (w *WebSocketEndpoint) SetDeadline(t time.Time) error {
+	if err := w.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return w.ws.SetWriteDeadline(t)
+}
+
+// LocalAddr returns the local address of the WebSocket connection
+func (w *WebSocketEndpoint) LocalAddr() net.Addr { return w.ws.LocalAddr() }
+
+// RemoteAddr returns the remote address of the WebSocket connection
+func (w *WebSocketEndpoint) RemoteAddr() net.Addr { return w.ws.RemoteAddr() }
+
+func (w *WebSocketEndpoint) Close() error {
+	return w.ws.Close()
+}
@@ -0,0 +1,292 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/buffer"
+	"github.com/ibrahmsql/gocat/internal/logger"
+)
+
+// ErrIdleTimeout is returned by Run when no data moved for IdleTimeout
+var ErrIdleTimeout = errors.New("relay idle timeout")
+
+// Direction identifies one half of a relay
+type Direction int
+
+const (
+	// Forward carries data from the first endpoint to the second
+	Forward Direction = iota
+	// Reverse carries data from the second endpoint to the first
+	Reverse
+)
+
+func (d Direction) String() string {
+	if d == Reverse {
+		return "reverse"
+	}
+	return "forward"
+}
+
+// DirectionStats describes the traffic of one relay direction
+type DirectionStats struct {
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+}
+
+// Stats describes the traffic of a finished relay
+type Stats struct {
+	Forward DirectionStats `json:"forward"`
+	Reverse DirectionStats `json:"reverse"`
+}
+
+// Total returns the bytes relayed in both directions
+func (s Stats) Total() int64 {
+	return s.Forward.Bytes + s.Reverse.Bytes
+}
+
+// Engine relays data between two endpoints. Each direction runs until its
+// source reports EOF, after which the destination is half-closed and the
+// other direction carries on. The relay ends early when a direction fails,
+// when a destination cannot be half-closed, when the context is done or
+// when it was idle for IdleTimeout.
+type Engine struct {
+	Mode        RelayMode
+	BufferSize  int
+	Timeout     time.Duration // absolute deadline for the whole relay
+	IdleTimeout time.Duration // ends the relay when no data moved for this long
+
+	middlewares []Middleware
+}
+
+// NewEngine creates a bidirectional engine with a 32KB buffer
+func NewEngine() *Engine {
+	return &Engine{
+		Mode:       ModeBidirectional,
+		BufferSize: 32 * 1024,
+	}
+}
+
+// Use appends middlewares. They are layered from the endpoints inwards:
+// the first one added reads from the source and writes to the destination
+// directly, so for a pair of relays undoing each other's transforms both
+// list the middlewares in the same order.
+func (e *Engine) Use(mw ...Middleware) *Engine {
+	e.middlewares = append(e.middlewares, mw...)
+	return e
+}
+
+// deadliner is implemented by endpoints whose blocked I/O can be interrupted
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// Run relays between a and b until both directions are done or the relay
+// ends early. Endpoints are not closed; a direction blocked on an endpoint
+// that cannot be interrupted, such as a terminal, is left to finish when
+// the caller closes it.
+func (e *Engine) Run(ctx context.Context, a, b Endpoint) (Stats, error) {
+	if e.Timeout > 0 {
+		deadline := time.Now().Add(e.Timeout)
+		for _, ep := range []Endpoint{a, b} {
+			if d, ok := ep.(deadliner); ok {
+				d.SetDeadline(deadline)
+			}
+		}
+	}
+
+	var activity atomic.Int64
+	activity.Store(time.Now().UnixNano())
+
+	pumps := []*pump{
+		{dir: Forward, dst: b, src: a, activity: &activity},
+		{dir: Reverse, dst: a, src: b, activity: &activity},
+	}
+	if e.Mode == ModeForward {
+		pumps = pumps[:1]
+	} else if e.Mode == ModeReverse {
+		pumps = pumps[1:]
+	}
+
+	done := make(chan *pump, len(pumps))
+	for _, p := range pumps {
+		p.finished = make(chan struct{})
+		go func(p *pump) {
+			defer close(p.finished)
+			p.err = e.copy(p)
+			done <- p
+		}(p)
+	}
+
+	var idle <-chan time.Time
+	if e.IdleTimeout > 0 {
+		ticker := time.NewTicker(e.IdleTimeout / 2)
+		defer ticker.Stop()
+		idle = ticker.C
+	}
+
+	var err error
+	for running := len(pumps); running > 0 && err == nil; {
+		select {
+		case p := <-done:
+			running--
+			if p.err != nil {
+				err = p.err
+				break
+			}
+			// Pass the EOF on; if the destination cannot take a half-close
+			// the peer would never see it, so the relay is over
+			if closeErr := p.dst.CloseWrite(); closeErr != nil && running > 0 {
+				logger.Debug("Relay ends after %s EOF: %v", p.dir, closeErr)
+				running = 0
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-idle:
+			if time.Since(time.Unix(0, activity.Load())) >= e.IdleTimeout {
+				err = ErrIdleTimeout
+			}
+		}
+	}
+
+	// Unblock whatever is still running and wait for it where that worked
+	for _, p := range pumps {
+		select {
+		case <-p.finished:
+			continue
+		default:
+		}
+		if interrupt(p.src) && interrupt(p.dst) {
+			<-p.finished
+		}
+	}
+
+	var stats Stats
+	for _, p := range pumps {
+		s := DirectionStats{Bytes: atomic.LoadInt64(&p.bytes), Duration: p.duration()}
+		select {
+		case <-p.finished:
+			s.Err = p.err
+		default:
+		}
+		if p.dir == Forward {
+			stats.Forward = s
+		} else {
+			stats.Reverse = s
+		}
+	}
+	return stats, err
+}
+
+// interrupt expires the deadlines of ep and reports whether it could
+func interrupt(ep Endpoint) bool {
+	d, ok := ep.(deadliner)
+	return ok && d.SetDeadline(time.Now()) == nil
+}
+
+// copy runs one direction through the middlewares. Without middlewares raw
+// connections below the endpoints are spliced by the kernel.
+func (e *Engine) copy(p *pump) error {
+	p.start()
+	defer p.stop()
+
+	var w io.Writer = p.dst
+	var r io.Reader = &meter{pump: p, splice: e.IdleTimeout == 0}
+	var closers []io.Closer
+	for _, mw := range e.middlewares {
+		nw, nr := mw(p.dir, w, r)
+		if c, ok := nw.(io.Closer); ok && nw != w {
+			closers = append(closers, c)
+		}
+		w, r = nw, nr
+	}
+
+	_, err := buffer.Shared().Copy(w, r, e.BufferSize)
+
+	// Flush what the middlewares hold back before the half-close
+	for i := len(closers) - 1; i >= 0; i-- {
+		if closeErr := closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// pump is the state of one relay direction
+type pump struct {
+	dir      Direction
+	dst      Endpoint
+	src      Endpoint
+	activity *atomic.Int64
+
+	bytes    int64
+	err      error
+	finished chan struct{}
+
+	mu      sync.Mutex
+	started time.Time
+	ended   time.Time
+}
+
+func (p *pump) start() {
+	p.mu.Lock()
+	p.started = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *pump) stop() {
+	p.mu.Lock()
+	p.ended = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *pump) duration() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started.IsZero() {
+		return 0
+	}
+	if p.ended.IsZero() {
+		return time.Since(p.started)
+	}
+	return p.ended.Sub(p.started)
+}
+
+func (p *pump) count(n int64) {
+	if n > 0 {
+		atomic.AddInt64(&p.bytes, n)
+		p.activity.Store(time.Now().UnixNano())
+	}
+}
+
+// meter counts what a direction reads from its source. It lets Copy reach
+// the connection below the source so counting does not cost the splice.
+type meter struct {
+	pump   *pump
+	splice bool
+}
+
+func (m *meter) Read(p []byte) (int, error) {
+	n, err := m.pump.src.Read(p)
+	m.pump.count(int64(n))
+	return n, err
+}
+
+// Unwrap returns the source connection unless the idle timeout needs to see
+// every read
+func (m *meter) Unwrap() net.Conn {
+	if conn, ok := m.pump.src.(net.Conn); ok && m.splice {
+		return conn
+	}
+	return nil
+}
+
+func (m *meter) CountRead(n int64) { m.pump.count(n) }
+
+func (m *meter) CountWritten(int64) {}
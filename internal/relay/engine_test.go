@@ -0,0 +1,149 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/security"
+	"github.com/ibrahmsql/gocat/internal/testutil"
+)
+
+// runAsync runs the engine between a and b in the background
+func runAsync(e *Engine, a, b Endpoint) <-chan Stats {
+	result := make(chan Stats, 1)
+	go func() {
+		stats, _ := e.Run(context.Background(), a, b)
+		result <- stats
+	}()
+	return result
+}
+
+func TestEngineHalfClose(t *testing.T) {
+	left, relayA := testutil.TCPPair(t, 5*time.Second)
+	relayB, right := testutil.TCPPair(t, 5*time.Second)
+	result := runAsync(NewEngine(), Conn(relayA), Conn(relayB))
+
+	// The left side finishes sending, the right side answers afterwards
+	left.Write([]byte("request"))
+	left.CloseWrite()
+
+	got, err := io.ReadAll(right)
+	if err != nil || string(got) != "request" {
+		t.Fatalf("right read %q, %v", got, err)
+	}
+	right.Write([]byte("late response"))
+	right.CloseWrite()
+
+	got, err = io.ReadAll(left)
+	if err != nil || string(got) != "late response" {
+		t.Fatalf("left read %q, %v", got, err)
+	}
+
+	stats := <-result
+	if stats.Forward.Bytes != 7 || stats.Reverse.Bytes != 13 {
+		t.Errorf("stats = %d forward, %d reverse; want 7, 13", stats.Forward.Bytes, stats.Reverse.Bytes)
+	}
+}
+
+func TestEngineEndsWithoutHalfClose(t *testing.T) {
+	client, relayConn := testutil.TCPPair(t, 5*time.Second)
+	var out bytes.Buffer
+	stdio := Stdio(strings.NewReader(""), &out)
+
+	result := runAsync(NewEngine(), stdio, Conn(relayConn))
+	client.Write([]byte("hello"))
+	client.CloseWrite()
+
+	select {
+	case stats := <-result:
+		if out.String() != "hello" || stats.Reverse.Bytes != 5 {
+			t.Errorf("stdout got %q, stats %+v", out.String(), stats.Reverse)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay did not end after the connection closed")
+	}
+}
+
+func TestEngineIdleTimeout(t *testing.T) {
+	_, relayA := testutil.TCPPair(t, 5*time.Second)
+	relayB, _ := testutil.TCPPair(t, 5*time.Second)
+
+	e := NewEngine()
+	e.IdleTimeout = 100 * time.Millisecond
+	start := time.Now()
+	_, err := e.Run(context.Background(), Conn(relayA), Conn(relayB))
+	if !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("Run() error = %v, want ErrIdleTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("idle relay took %v to end", elapsed)
+	}
+}
+
+func TestEngineContextCancel(t *testing.T) {
+	_, relayA := testutil.TCPPair(t, 5*time.Second)
+	relayB, _ := testutil.TCPPair(t, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := NewEngine().Run(ctx, Conn(relayA), Conn(relayB)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// TestEngineMiddlewarePair chains two relays whose middlewares undo each
+// other: left -> compress -> encrypt -> wire -> decrypt -> inflate -> right
+func TestEngineMiddlewarePair(t *testing.T) {
+	enc, err := security.NewEncryptor(security.AlgorithmAES256GCM, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	left, inA := testutil.TCPPair(t, 5*time.Second)
+	outA, inB := testutil.TCPPair(t, 5*time.Second)
+	outB, right := testutil.TCPPair(t, 5*time.Second)
+
+	first := NewEngine().Use(Encrypt(enc, Forward), Compress(Forward))
+	second := NewEngine().Use(Encrypt(enc, Reverse), Compress(Reverse))
+	runAsync(first, Conn(inA), Conn(outA))
+	runAsync(second, Conn(inB), Conn(outB))
+
+	data := bytes.Repeat([]byte("compressible "), 10000)
+	go func() {
+		left.Write(data)
+		left.CloseWrite()
+	}()
+	got, err := io.ReadAll(right)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("right received %d bytes, %v; want %d", len(got), err, len(data))
+	}
+
+	right.Write([]byte("pong"))
+	right.CloseWrite()
+	got, err = io.ReadAll(left)
+	if err != nil || string(got) != "pong" {
+		t.Fatalf("left received %q, %v", got, err)
+	}
+}
+
+func TestHexCapture(t *testing.T) {
+	var capture, out bytes.Buffer
+	e := NewEngine().Use(HexCapture(&capture))
+	e.Mode = ModeForward
+	if _, err := e.Run(context.Background(), Stdio(strings.NewReader("GET / HTTP/1.0\r\n"), nil), Stdio(nil, &out)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "> 00000000  47 45 54 20 2f 20 48 54 54 50 2f 31 2e 30 0d 0a  |GET / HTTP/1.0..|\n"
+	if capture.String() != want {
+		t.Errorf("capture = %q, want %q", capture.String(), want)
+	}
+	if out.String() != "GET / HTTP/1.0\r\n" {
+		t.Errorf("relayed %q", out.String())
+	}
+}
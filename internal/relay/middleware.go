@@ -0,0 +1,198 @@
+package relay
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
+	"github.com/ibrahmsql/gocat/internal/security"
+)
+
+// maxEncryptedFrame bounds the frames accepted by the Encrypt middleware
+const maxEncryptedFrame = 1 << 20
+
+// Middleware observes or transforms the data of one relay direction. It
+// may wrap the destination, the source or both. A wrapped destination that
+// is an io.Closer is closed when the direction ends, before the half-close,
+// so it can flush what it holds back.
+type Middleware func(dir Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader)
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// Logging logs every chunk relayed, labelled with name, at debug level
+func Logging(name string) Middleware {
+	return func(dir Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader) {
+		return writerFunc(func(p []byte) (int, error) {
+			n, err := dst.Write(p)
+			logger.Debug("%s %s: %d bytes", name, dir, n)
+			return n, err
+		}), src
+	}
+}
+
+// HexCapture writes a hex dump of both directions to w. Lines of the
+// forward direction start with '>' and those of the reverse one with '<';
+// each direction keeps its own offset.
+func HexCapture(w io.Writer) Middleware {
+	var mu sync.Mutex
+	return func(dir Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader) {
+		marker := byte('>')
+		if dir == Reverse {
+			marker = '<'
+		}
+		var offset int64
+		return writerFunc(func(p []byte) (int, error) {
+			n, err := dst.Write(p)
+			mu.Lock()
+			writeHexDump(w, marker, offset, p[:n])
+			mu.Unlock()
+			offset += int64(n)
+			return n, err
+		}), src
+	}
+}
+
+// writeHexDump writes p as offset, hex and ASCII columns of 16 bytes
+func writeHexDump(w io.Writer, marker byte, offset int64, p []byte) {
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	for i := 0; i < len(p); i += 16 {
+		end := i + 16
+		if end > len(p) {
+			end = len(p)
+		}
+
+		fmt.Fprintf(bw, "%c %08x  ", marker, offset+int64(i))
+		for j := i; j < i+16; j++ {
+			if j < end {
+				fmt.Fprintf(bw, "%02x ", p[j])
+			} else {
+				bw.WriteString("   ")
+			}
+		}
+
+		bw.WriteString(" |")
+		for j := i; j < end; j++ {
+			if p[j] >= 32 && p[j] <= 126 {
+				bw.WriteByte(p[j])
+			} else {
+				bw.WriteByte('.')
+			}
+		}
+		bw.WriteString("|\n")
+	}
+}
+
+// RateLimit throttles the writes of each direction; a nil limiter leaves
+// that direction unlimited
+func RateLimit(forward, reverse *network.RateLimiter) Middleware {
+	return func(dir Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader) {
+		limiter := forward
+		if dir == Reverse {
+			limiter = reverse
+		}
+		if limiter == nil {
+			return dst, src
+		}
+		return network.NewRateLimitedWriter(dst, limiter), src
+	}
+}
+
+// Encrypt seals the data of the outbound direction with enc and opens the
+// data of the other one. Each chunk travels as a frame of a 4-byte
+// big-endian length followed by the sealed chunk.
+func Encrypt(enc *security.Encryptor, outbound Direction) Middleware {
+	return func(dir Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader) {
+		if dir == outbound {
+			return writerFunc(func(p []byte) (int, error) {
+				sealed, err := enc.Encrypt(p)
+				if err != nil {
+					return 0, err
+				}
+				frame := make([]byte, 4+len(sealed))
+				binary.BigEndian.PutUint32(frame, uint32(len(sealed)))
+				copy(frame[4:], sealed)
+				if _, err := dst.Write(frame); err != nil {
+					return 0, err
+				}
+				return len(p), nil
+			}), src
+		}
+		return dst, &decryptReader{enc: enc, src: src}
+	}
+}
+
+// decryptReader opens the frames written by Encrypt
+type decryptReader struct {
+	enc     *security.Encryptor
+	src     io.Reader
+	pending []byte
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(d.src, header[:]); err != nil {
+			return 0, err
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size > maxEncryptedFrame {
+			return 0, fmt.Errorf("encrypted frame of %d bytes exceeds %d", size, maxEncryptedFrame)
+		}
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(d.src, sealed); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		plain, err := d.enc.Decrypt(sealed)
+		if err != nil {
+			return 0, err
+		}
+		d.pending = plain
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// Compress deflates the data of the outbound direction and inflates the
+// data of the other one. Every chunk is flushed so interactive traffic is
+// not held back.
+func Compress(outbound Direction) Middleware {
+	return func(dir Direction, dst io.Writer, src io.Reader) (io.Writer, io.Reader) {
+		if dir == outbound {
+			fw, _ := flate.NewWriter(dst, flate.DefaultCompression)
+			return &flushWriter{fw: fw}, src
+		}
+		return dst, flate.NewReader(src)
+	}
+}
+
+// flushWriter flushes a deflate stream after every write
+type flushWriter struct {
+	fw *flate.Writer
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.fw.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.fw.Flush()
+}
+
+// Close ends the deflate stream
+func (f *flushWriter) Close() error {
+	return f.fw.Close()
+}
@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/resolver"
 )
 
 // RelayMode defines different relay operation modes
@@ -47,100 +49,33 @@ func NewRelay() *Relay {
 func (r *Relay) RelayConnections(conn1, conn2 net.Conn) error {
 	logger.Info("Starting relay between %s and %s", conn1.RemoteAddr(), conn2.RemoteAddr())
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, 2)
+	engine := NewEngine()
+	engine.Mode = r.Mode
+	engine.BufferSize = r.BufferSize
+	engine.Timeout = r.Timeout
 
-	// Set timeouts if specified
-	if r.Timeout > 0 {
-		deadline := time.Now().Add(r.Timeout)
-		conn1.SetDeadline(deadline)
-		conn2.SetDeadline(deadline)
-	}
-
-	// Forward direction: conn1 -> conn2
-	if r.Mode == ModeBidirectional || r.Mode == ModeForward {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			bytes, err := r.copyData(conn2, conn1, "forward")
-			r.mutex.Lock()
-			r.bytesForward += bytes
-			r.mutex.Unlock()
-			if err != nil {
-				errChan <- fmt.Errorf("forward relay error: %v", err)
-			}
-		}()
-	}
+	stats, err := engine.Run(context.Background(), Conn(conn1), Conn(conn2))
 
-	// Reverse direction: conn2 -> conn1
-	if r.Mode == ModeBidirectional || r.Mode == ModeReverse {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			bytes, err := r.copyData(conn1, conn2, "reverse")
-			r.mutex.Lock()
-			r.bytesReverse += bytes
-			r.mutex.Unlock()
-			if err != nil {
-				errChan <- fmt.Errorf("reverse relay error: %v", err)
-			}
-		}()
-	}
+	r.mutex.Lock()
+	r.bytesForward += stats.Forward.Bytes
+	r.bytesReverse += stats.Reverse.Bytes
+	r.mutex.Unlock()
 
-	// Wait for completion or error
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
-
-	// Collect any errors
-	var errors []error
-	for err := range errChan {
-		if err != nil {
-			errors = append(errors, err)
-		}
-	}
+	logger.Debug("Relay done: %d bytes forward in %v, %d bytes reverse in %v",
+		stats.Forward.Bytes, stats.Forward.Duration, stats.Reverse.Bytes, stats.Reverse.Duration)
 
 	// Show statistics
 	if r.ShowStats {
 		r.printStats()
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("relay errors: %v", errors)
+	if err != nil {
+		return fmt.Errorf("relay error: %v", err)
 	}
 
 	return nil
 }
 
-// copyData copies data from src to dst with statistics
-func (r *Relay) copyData(dst io.Writer, src io.Reader, direction string) (int64, error) {
-	buffer := make([]byte, r.BufferSize)
-	var totalBytes int64
-
-	for {
-		n, err := src.Read(buffer)
-		if err != nil {
-			if err == io.EOF {
-				logger.Debug("EOF reached in %s direction", direction)
-				return totalBytes, nil
-			}
-			return totalBytes, err
-		}
-
-		written, err := dst.Write(buffer[:n])
-		if err != nil {
-			return totalBytes, err
-		}
-
-		totalBytes += int64(written)
-
-		if r.ShowStats && totalBytes%1048576 == 0 { // Every MB
-			logger.Debug("Relayed %d MB in %s direction", totalBytes/1048576, direction)
-		}
-	}
-}
-
 // printStats prints relay statistics
 func (r *Relay) printStats() {
 	r.mutex.RLock()
@@ -196,6 +131,15 @@ func (ps *ProxyServer) Start() error {
 	}
 }
 
+// dialTCP connects to address, looking its host up with the resolver
+func dialTCP(address string) (net.Conn, error) {
+	resolved, err := resolver.Default().ResolveAddr(context.Background(), "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return net.Dial("tcp", resolved)
+}
+
 // handleConnection handles a single proxy connection
 func (ps *ProxyServer) handleConnection(clientConn net.Conn) {
 	defer clientConn.Close()
@@ -203,7 +147,7 @@ func (ps *ProxyServer) handleConnection(clientConn net.Conn) {
 	logger.Info("New proxy connection from %s", clientConn.RemoteAddr())
 
 	// Connect to target
-	targetConn, err := net.Dial("tcp", ps.TargetAddr)
+	targetConn, err := dialTCP(ps.TargetAddr)
 	if err != nil {
 		logger.Error("Failed to connect to target %s: %v", ps.TargetAddr, err)
 		return
@@ -262,7 +206,7 @@ func (pf *PortForwarder) handleForward(localConn net.Conn) {
 	logger.Debug("Forwarding connection from %s", localConn.RemoteAddr())
 
 	// Connect to remote
-	remoteConn, err := net.Dial("tcp", pf.RemoteAddr)
+	remoteConn, err := dialTCP(pf.RemoteAddr)
 	if err != nil {
 		logger.Error("Failed to connect to remote %s: %v", pf.RemoteAddr, err)
 		return
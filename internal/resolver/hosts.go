@@ -0,0 +1,55 @@
+package resolver
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultHostsFile is where static host entries live on this system
+var DefaultHostsFile = "/etc/hosts"
+
+func init() {
+	if runtime.GOOS == "windows" {
+		DefaultHostsFile = filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+	}
+}
+
+// readHostsFile reads the addresses of the names in a hosts file. A
+// missing file has no entries.
+func readHostsFile(path string) (map[string][]net.IP, error) {
+	hosts := make(map[string][]net.IP)
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return hosts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		// Zones of link-local addresses are dropped
+		addr, _, _ := strings.Cut(fields[0], "%")
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = canonicalName(name)
+			hosts[name] = append(hosts[name], ip)
+		}
+	}
+	return hosts, scanner.Err()
+}
@@ -0,0 +1,453 @@
+// Package resolver resolves host names for every connection gocat makes.
+//
+// Names are answered, in order, by static overrides (like curl --resolve),
+// a TTL cache, the hosts file and an upstream: the system resolver or a DNS
+// server reached over UDP, TCP, TLS (RFC 7858) or HTTPS (RFC 8484). With
+// NoDNS set only literal addresses and overrides resolve.
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// DefaultTimeout bounds a query to the upstream
+	DefaultTimeout = 5 * time.Second
+	// DefaultMaxTTL caps how long answers are cached
+	DefaultMaxTTL = 5 * time.Minute
+	// systemTTL is how long system resolver answers, which carry no TTL,
+	// are cached
+	systemTTL = 30 * time.Second
+)
+
+// ErrNoDNS is returned for names looked up while name resolution is off
+var ErrNoDNS = errors.New("hostname resolution disabled")
+
+// Config configures a Resolver
+type Config struct {
+	// Upstream is empty for the system resolver, or a DNS server as
+	// udp://host[:port], tcp://host[:port], tls://host[:port] or
+	// https://host/path. A bare host[:port] means UDP.
+	Upstream string
+	// Overrides answer names ahead of any lookup, even with NoDNS
+	Overrides map[string][]net.IP
+	// HostsFile is consulted before a custom upstream; the system resolver
+	// reads it on its own
+	HostsFile string
+	// NoDNS refuses names that are neither literal addresses nor overridden
+	NoDNS bool
+	// MaxTTL caps how long answers are cached; negative disables the cache
+	MaxTTL  time.Duration
+	Timeout time.Duration
+	// TLSConfig is used for tls:// and https:// upstreams
+	TLSConfig *tls.Config
+}
+
+// Resolver answers name lookups as configured. It satisfies the lookup
+// methods of net.Resolver, so it can stand in for one.
+type Resolver struct {
+	config    Config
+	upstream  transport // nil for the system resolver
+	netResolv *net.Resolver
+	hosts     map[string][]net.IP
+	cache     map[string]cacheEntry
+	mu        sync.Mutex
+}
+
+type cacheEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultResolver = mustNew(Config{})
+)
+
+// Default returns the resolver connections use unless told otherwise
+func Default() *Resolver {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultResolver
+}
+
+// SetDefault replaces the resolver returned by Default
+func SetDefault(r *Resolver) {
+	defaultMu.Lock()
+	defaultResolver = r
+	defaultMu.Unlock()
+}
+
+func mustNew(config Config) *Resolver {
+	r, err := New(config)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// New creates a resolver, checking the upstream and reading the hosts file
+func New(config Config) (*Resolver, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.MaxTTL == 0 {
+		config.MaxTTL = DefaultMaxTTL
+	}
+
+	r := &Resolver{
+		config:    config,
+		netResolv: net.DefaultResolver,
+		cache:     make(map[string]cacheEntry),
+	}
+
+	overrides := make(map[string][]net.IP, len(config.Overrides))
+	for name, ips := range config.Overrides {
+		overrides[canonicalName(name)] = ips
+	}
+	r.config.Overrides = overrides
+
+	if config.Upstream == "" {
+		return r, nil
+	}
+
+	upstream, err := parseUpstream(config.Upstream, config.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	r.upstream = upstream
+	r.netResolv = &net.Resolver{PreferGo: true, Dial: upstream.dial}
+
+	if config.HostsFile != "" {
+		if r.hosts, err = readHostsFile(config.HostsFile); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Upstream describes where lookups go
+func (r *Resolver) Upstream() string {
+	if r.upstream == nil {
+		return "system"
+	}
+	return r.upstream.String()
+}
+
+// LookupIPAddr returns the addresses of host
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []net.IPAddr{{IP: addr.AsSlice(), Zone: addr.Zone()}}, nil
+	}
+
+	name := canonicalName(host)
+	if ips, ok := r.config.Overrides[name]; ok {
+		return ipAddrs(ips), nil
+	}
+	if r.config.NoDNS {
+		return nil, &net.DNSError{Err: ErrNoDNS.Error(), Name: host}
+	}
+	if ips, ok := r.hosts[name]; ok {
+		return ipAddrs(ips), nil
+	}
+
+	if addrs, ok := r.cached(name); ok {
+		return addrs, nil
+	}
+
+	addrs, ttl, err := r.query(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	r.store(name, addrs, ttl)
+	return addrs, nil
+}
+
+// LookupIP returns the addresses of host for network "ip", "ip4" or "ip6"
+func (r *Resolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, addr := range addrs {
+		is4 := addr.IP.To4() != nil
+		if network == "ip" || (network == "ip4" && is4) || (network == "ip6" && !is4) {
+			ips = append(ips, addr.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+// LookupHost returns the addresses of host as strings
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = addr.String()
+	}
+	return hosts, nil
+}
+
+// ResolveAddr replaces the host of a host:port address with its first
+// address of the family network asks for, if any ("tcp4", "udp6", ...)
+func (r *Resolver) ResolveAddr(ctx context.Context, network, address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return address, err
+	}
+
+	family := "ip"
+	if strings.HasSuffix(network, "4") || strings.HasSuffix(network, "6") {
+		family += network[len(network)-1:]
+	}
+	ips, err := r.LookupIP(ctx, family, host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// Other record types go to the upstream through net.Resolver, uncached
+
+// LookupAddr returns the names of an address
+func (r *Resolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if err := r.allowDNS(addr); err != nil {
+		return nil, err
+	}
+	return r.netResolv.LookupAddr(ctx, addr)
+}
+
+// LookupCNAME returns the canonical name of host
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	if err := r.allowDNS(host); err != nil {
+		return "", err
+	}
+	return r.netResolv.LookupCNAME(ctx, host)
+}
+
+// LookupMX returns the mail exchangers of name
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if err := r.allowDNS(name); err != nil {
+		return nil, err
+	}
+	return r.netResolv.LookupMX(ctx, name)
+}
+
+// LookupNS returns the name servers of name
+func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	if err := r.allowDNS(name); err != nil {
+		return nil, err
+	}
+	return r.netResolv.LookupNS(ctx, name)
+}
+
+// LookupTXT returns the text records of name
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if err := r.allowDNS(name); err != nil {
+		return nil, err
+	}
+	return r.netResolv.LookupTXT(ctx, name)
+}
+
+// LookupSRV returns the service records of _service._proto.name
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if err := r.allowDNS(name); err != nil {
+		return "", nil, err
+	}
+	return r.netResolv.LookupSRV(ctx, service, proto, name)
+}
+
+// allowDNS refuses queries while name resolution is off
+func (r *Resolver) allowDNS(name string) error {
+	if r.config.NoDNS {
+		return &net.DNSError{Err: ErrNoDNS.Error(), Name: name}
+	}
+	return nil
+}
+
+// FlushCache forgets cached answers
+func (r *Resolver) FlushCache() {
+	r.mu.Lock()
+	r.cache = make(map[string]cacheEntry)
+	r.mu.Unlock()
+}
+
+func (r *Resolver) cached(name string) ([]net.IPAddr, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.cache[name]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(r.cache, name)
+		return nil, false
+	}
+	return entry.addrs, true
+}
+
+func (r *Resolver) store(name string, addrs []net.IPAddr, ttl time.Duration) {
+	if ttl > r.config.MaxTTL {
+		ttl = r.config.MaxTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	r.cache[name] = cacheEntry{addrs: addrs, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+}
+
+// query asks the upstream for the A and AAAA records of name and returns
+// them with the lowest TTL among them
+func (r *Resolver) query(ctx context.Context, name string) ([]net.IPAddr, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	if r.upstream == nil {
+		addrs, err := r.netResolv.LookupIPAddr(ctx, name)
+		return addrs, systemTTL, err
+	}
+
+	type answer struct {
+		addrs []net.IPAddr
+		ttl   time.Duration
+		err   error
+	}
+	answers := make(chan answer, 2)
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		go func() {
+			addrs, ttl, err := r.queryType(ctx, name, qtype)
+			answers <- answer{addrs, ttl, err}
+		}()
+	}
+
+	var addrs []net.IPAddr
+	var ttl time.Duration
+	var lastErr error
+	for range 2 {
+		a := <-answers
+		if a.err != nil {
+			lastErr = a.err
+			continue
+		}
+		addrs = append(addrs, a.addrs...)
+		if len(a.addrs) > 0 && (ttl == 0 || a.ttl < ttl) {
+			ttl = a.ttl
+		}
+	}
+	if len(addrs) == 0 && lastErr != nil {
+		return nil, 0, lastErr
+	}
+	return addrs, ttl, nil
+}
+
+// queryType asks the upstream for the records of one type
+func (r *Resolver) queryType(ctx context.Context, name string, qtype dnsmessage.Type) ([]net.IPAddr, time.Duration, error) {
+	query, err := dns.NewQuery(name, qtype, dns.QueryOptions{UDPSize: dns.DefaultUDPSize})
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: name}
+	}
+
+	resp, err := r.upstream.exchange(ctx, query)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: name, Server: r.upstream.String(), IsTimeout: ctx.Err() != nil}
+	}
+	addrs, ttl, err := answerAddrs(resp, qtype)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: name, Server: r.upstream.String()}
+	}
+	return addrs, ttl, nil
+}
+
+// answerAddrs returns the addresses of type qtype in a response, with the
+// lowest TTL among them. A name that does not exist has no addresses.
+func answerAddrs(resp dnsmessage.Message, qtype dnsmessage.Type) ([]net.IPAddr, time.Duration, error) {
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("server misbehaving (%v)", resp.RCode)
+	}
+
+	var addrs []net.IPAddr
+	var ttl time.Duration
+	for _, res := range resp.Answers {
+		// CNAMEs leading to the addresses, and anything else, are skipped
+		var ip net.IP
+		switch body := res.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		}
+		if ip == nil || res.Header.Type != qtype {
+			continue
+		}
+
+		addrs = append(addrs, net.IPAddr{IP: ip})
+		if recordTTL := time.Duration(res.Header.TTL) * time.Second; len(addrs) == 1 || recordTTL < ttl {
+			ttl = recordTTL
+		}
+	}
+	return addrs, ttl, nil
+}
+
+// canonicalName lowercases a name and drops its trailing dot
+func canonicalName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func ipAddrs(ips []net.IP) []net.IPAddr {
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: ip}
+	}
+	return addrs
+}
+
+// ParseOverride parses a static answer given as host:addr[,addr...], as
+// curl --resolve takes it without the port. IPv6 addresses may be
+// bracketed.
+func ParseOverride(spec string) (string, []net.IP, error) {
+	host, list, ok := strings.Cut(spec, ":")
+	if !ok || host == "" || list == "" {
+		return "", nil, fmt.Errorf("invalid override %q: expected host:address", spec)
+	}
+
+	var ips []net.IP
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(field), "["), "]")
+		ip := net.ParseIP(field)
+		if ip == nil {
+			return "", nil, fmt.Errorf("invalid override %q: %q is not an IP address", spec, field)
+		}
+		ips = append(ips, ip)
+	}
+	return host, ips, nil
+}
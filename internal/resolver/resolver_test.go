@@ -0,0 +1,288 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testZone answers queries for app.test and only4.test and counts them
+type testZone struct {
+	ttl     uint32
+	queries atomic.Int32
+}
+
+func newZone() *testZone {
+	return &testZone{ttl: 60}
+}
+
+func (z *testZone) server() *dns.Server {
+	zone := dns.NewZone("test.")
+	zone.Add(
+		dns.Record{Name: "app.test.", Type: dnsmessage.TypeA, TTL: z.ttl, Data: "192.0.2.10"},
+		dns.Record{Name: "app.test.", Type: dnsmessage.TypeAAAA, TTL: z.ttl, Data: "2001:db8::10"},
+		dns.Record{Name: "only4.test.", Type: dnsmessage.TypeA, TTL: z.ttl, Data: "192.0.2.4"},
+	)
+	srv := dns.NewServer(zone)
+	srv.OnQuery = func(net.Addr, dnsmessage.Question, string, int, int) { z.queries.Add(1) }
+	return srv
+}
+
+// serveUDP answers datagrams until the test ends and returns the address
+func (z *testZone) serveUDP(t *testing.T, addr string) string {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go z.server().ServeUDP(conn)
+	return conn.LocalAddr().String()
+}
+
+// serveStream answers length-prefixed queries on ln until the test ends
+func (z *testZone) serveStream(t *testing.T, ln net.Listener) {
+	t.Cleanup(func() { ln.Close() })
+	go z.server().ServeTCP(ln)
+}
+
+func lookup(t *testing.T, r *Resolver, host string) []string {
+	t.Helper()
+	addrs, err := r.LookupHost(context.Background(), host)
+	if err != nil {
+		t.Fatalf("LookupHost(%q): %v", host, err)
+	}
+	return addrs
+}
+
+func sameSet(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]bool)
+	for _, s := range got {
+		seen[s] = true
+	}
+	for _, s := range want {
+		if !seen[s] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUDPUpstreamCachesByTTL(t *testing.T) {
+	zone := newZone()
+	r, err := New(Config{Upstream: "udp://" + zone.serveUDP(t, "127.0.0.1:0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addrs := lookup(t, r, "App.Test."); !sameSet(addrs, "192.0.2.10", "2001:db8::10") {
+		t.Errorf("addresses = %v", addrs)
+	}
+	lookup(t, r, "app.test")
+	if n := zone.queries.Load(); n != 2 {
+		t.Errorf("%d queries, want one A and one AAAA", n)
+	}
+
+	_, err = r.LookupHost(context.Background(), "missing.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("missing name: %v", err)
+	}
+
+	ips, err := r.LookupIP(context.Background(), "ip6", "only4.test")
+	if err == nil {
+		t.Errorf("LookupIP(ip6) = %v", ips)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	zone := newZone()
+	zone.ttl = 1
+	r, err := New(Config{Upstream: zone.serveUDP(t, "127.0.0.1:0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookup(t, r, "only4.test")
+	r.mu.Lock()
+	entry := r.cache["only4.test"]
+	entry.expires = time.Now().Add(-time.Millisecond)
+	r.cache["only4.test"] = entry
+	r.mu.Unlock()
+	lookup(t, r, "only4.test")
+
+	if n := zone.queries.Load(); n != 4 {
+		t.Errorf("%d queries after expiry, want 4", n)
+	}
+}
+
+func testTLSConfig(srv *httptest.Server) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return &tls.Config{RootCAs: pool}
+}
+
+func TestTLSUpstream(t *testing.T) {
+	// The httptest certificate is valid for 127.0.0.1
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	zone := newZone()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone.serveStream(t, ln)
+
+	r, err := New(Config{Upstream: "tls://" + ln.Addr().String(), TLSConfig: testTLSConfig(srv)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs := lookup(t, r, "app.test"); !sameSet(addrs, "192.0.2.10", "2001:db8::10") {
+		t.Errorf("addresses = %v", addrs)
+	}
+
+	assertTXTReachesZone(t, r, zone)
+}
+
+// assertTXTReachesZone checks that record types besides addresses, which
+// go through net.Resolver, reach the upstream
+func assertTXTReachesZone(t *testing.T, r *Resolver, zone *testZone) {
+	t.Helper()
+	before := zone.queries.Load()
+	if _, err := r.LookupTXT(context.Background(), "missing.test"); err == nil {
+		t.Error("TXT lookup of a missing name succeeded")
+	}
+	if zone.queries.Load() == before {
+		t.Error("TXT lookup did not reach the server")
+	}
+}
+
+func TestHTTPSUpstream(t *testing.T) {
+	zone := newZone()
+	address := zone.serveUDP(t, "127.0.0.1:0")
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		packet, _ := io.ReadAll(req.Body)
+		query, err := dns.Unpack(packet)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := (&dns.Client{}).Exchange(req.Context(), query, address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		packet, _ = resp.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packet)
+	}))
+	defer srv.Close()
+
+	r, err := New(Config{Upstream: srv.URL + "/dns-query", TLSConfig: testTLSConfig(srv)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs := lookup(t, r, "app.test"); !sameSet(addrs, "192.0.2.10", "2001:db8::10") {
+		t.Errorf("addresses = %v", addrs)
+	}
+	if r.Upstream() != srv.URL+"/dns-query" {
+		t.Errorf("Upstream() = %q", r.Upstream())
+	}
+	assertTXTReachesZone(t, r, zone)
+}
+
+func TestOverridesHostsAndNoDNS(t *testing.T) {
+	zone := newZone()
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	os.WriteFile(hostsFile, []byte("# comment\n192.0.2.77 box.test box # trailing\n"), 0600)
+
+	host, ips, err := ParseOverride("app.test:[2001:db8::99],192.0.2.99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{
+		Upstream:  zone.serveUDP(t, "127.0.0.1:0"),
+		Overrides: map[string][]net.IP{host: ips},
+		HostsFile: hostsFile,
+	}
+	r, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addrs := lookup(t, r, "app.test"); !sameSet(addrs, "2001:db8::99", "192.0.2.99") {
+		t.Errorf("override = %v", addrs)
+	}
+	if addrs := lookup(t, r, "BOX"); !sameSet(addrs, "192.0.2.77") {
+		t.Errorf("hosts entry = %v", addrs)
+	}
+	if n := zone.queries.Load(); n != 0 {
+		t.Errorf("%d queries reached the server", n)
+	}
+
+	config.NoDNS = true
+	if r, err = New(config); err != nil {
+		t.Fatal(err)
+	}
+	if addrs := lookup(t, r, "app.test"); len(addrs) != 2 {
+		t.Errorf("override with NoDNS = %v", addrs)
+	}
+	if addrs := lookup(t, r, "192.0.2.1"); !sameSet(addrs, "192.0.2.1") {
+		t.Errorf("literal with NoDNS = %v", addrs)
+	}
+	for _, name := range []string{"box.test", "only4.test"} {
+		if _, err := r.LookupHost(context.Background(), name); err == nil {
+			t.Errorf("%s resolved with NoDNS", name)
+		}
+	}
+	if _, err := r.LookupMX(context.Background(), "app.test"); err == nil {
+		t.Error("MX lookup with NoDNS succeeded")
+	}
+
+	address, err := r.ResolveAddr(context.Background(), "tcp4", "app.test:80")
+	if err != nil || address != "192.0.2.99:80" {
+		t.Errorf("ResolveAddr() = %q, %v", address, err)
+	}
+}
+
+func TestParseUpstream(t *testing.T) {
+	for spec, want := range map[string]string{
+		"10.0.0.2":                  "udp://10.0.0.2:53",
+		"udp://10.0.0.2:5353":       "udp://10.0.0.2:5353",
+		"tcp://[2001:db8::1]":       "tcp://[2001:db8::1]:53",
+		"tls://1.1.1.1":             "tls://1.1.1.1:853",
+		"https://dns.example":       "https://dns.example/dns-query",
+		"https://dns.example/q?x=1": "https://dns.example/q?x=1",
+	} {
+		upstream, err := parseUpstream(spec, nil)
+		if err != nil || upstream.String() != want {
+			t.Errorf("parseUpstream(%q) = %v, %v; want %s", spec, upstream, err, want)
+		}
+	}
+	if _, err := parseUpstream("quic://1.1.1.1", nil); err == nil {
+		t.Error("unsupported scheme accepted")
+	}
+	if _, _, err := ParseOverride("app.test:not-an-ip"); err == nil {
+		t.Error("invalid override accepted")
+	}
+}
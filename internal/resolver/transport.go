@@ -0,0 +1,220 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxMessage bounds DNS messages read from upstreams
+const maxMessage = 65535
+
+// transport carries DNS messages to an upstream
+type transport interface {
+	// exchange sends a query and returns the response to it
+	exchange(ctx context.Context, query dnsmessage.Message) (dnsmessage.Message, error)
+	// dial opens a connection net.Resolver can speak DNS over
+	dial(ctx context.Context, network, address string) (net.Conn, error)
+	String() string
+}
+
+// parseUpstream parses an upstream as Config.Upstream describes it
+func parseUpstream(spec string, tlsConfig *tls.Config) (transport, error) {
+	scheme, rest, ok := strings.Cut(spec, "://")
+	if !ok {
+		scheme, rest = "udp", spec
+	}
+
+	switch scheme {
+	case "udp", "tcp":
+		return &dnsServer{network: scheme, address: dns.WithPort(rest, "53")}, nil
+	case "tls":
+		address := dns.WithPort(rest, "853")
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if tlsConfig != nil {
+			config = tlsConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		return &dnsServer{network: "tcp", address: address, tls: config}, nil
+	case "https":
+		u, err := url.Parse(spec)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid DNS-over-HTTPS upstream %q", spec)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		return &dohServer{
+			url: u.String(),
+			client: &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   tlsConfig,
+				ForceAttemptHTTP2: true,
+				IdleConnTimeout:   90 * time.Second,
+			}},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported resolver scheme %q: use udp, tcp, tls or https", scheme)
+	}
+}
+
+// dnsServer is a classic DNS server over UDP or TCP, or TLS when tls is set
+type dnsServer struct {
+	network string
+	address string
+	tls     *tls.Config
+}
+
+func (s *dnsServer) String() string {
+	if s.tls != nil {
+		return "tls://" + s.address
+	}
+	return s.network + "://" + s.address
+}
+
+// dial connects to the server; a UDP server is reached over whichever
+// network net.Resolver asks for, the others over their own
+func (s *dnsServer) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	if s.tls != nil {
+		d := &tls.Dialer{Config: s.tls}
+		return d.DialContext(ctx, "tcp", s.address)
+	}
+	if s.network == "tcp" {
+		network = "tcp"
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, s.address)
+}
+
+// exchange sends the query over the network of the server; truncated UDP
+// answers are retried over TCP
+func (s *dnsServer) exchange(ctx context.Context, query dnsmessage.Message) (dnsmessage.Message, error) {
+	client := &dns.Client{TCP: s.network == "tcp", Dial: s.dial}
+	if deadline, ok := ctx.Deadline(); ok {
+		client.Timeout = time.Until(deadline)
+	}
+	resp, err := client.Exchange(ctx, query, s.address)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	return resp.Message, nil
+}
+
+// dohServer is a DNS-over-HTTPS server
+type dohServer struct {
+	url    string
+	client *http.Client
+}
+
+func (s *dohServer) String() string { return s.url }
+
+// exchange posts the query with ID 0, as RFC 8484 recommends for HTTP
+// caches
+func (s *dohServer) exchange(ctx context.Context, query dnsmessage.Message) (dnsmessage.Message, error) {
+	query.ID = 0
+	packet, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, fmt.Errorf("failed to pack query: %w", err)
+	}
+	raw, err := s.post(ctx, packet)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	resp, err := dns.Unpack(raw)
+	if err != nil {
+		return dnsmessage.Message{}, err
+	}
+	if resp.ID != query.ID || !resp.Response {
+		return dnsmessage.Message{}, errors.New("response does not match the query")
+	}
+	return resp, nil
+}
+
+// post sends a packed query and returns the packed answer
+func (s *dohServer) post(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS server returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxMessage))
+}
+
+func (s *dohServer) dial(ctx context.Context, _, _ string) (net.Conn, error) {
+	return &dohConn{ctx: ctx, server: s}, nil
+}
+
+// dohConn lets net.Resolver speak DNS over TCP framing to a DoH server:
+// each query written is posted, and its answer is read back
+type dohConn struct {
+	ctx      context.Context
+	server   *dohServer
+	out      bytes.Buffer
+	in       bytes.Buffer
+	deadline time.Time
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	return c.out.Write(b)
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.in.Len() == 0 {
+		query, err := dns.ReadFrame(&c.out)
+		if err != nil {
+			return 0, err
+		}
+
+		ctx := c.ctx
+		if !c.deadline.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, c.deadline)
+			defer cancel()
+		}
+		resp, err := c.server.post(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+		if len(resp) > maxMessage {
+			return 0, errors.New("DNS-over-HTTPS answer too large")
+		}
+		c.in.Write(dns.Frame(resp))
+	}
+	return c.in.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr(c.server.url) }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.server.url) }
+func (c *dohConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { c.deadline = t; return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// dohAddr names a DoH server as a net.Addr
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
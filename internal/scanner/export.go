@@ -0,0 +1,54 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Report is the exported form of a scan
+type Report struct {
+	Targets  string    `json:"targets"`
+	Ports    string    `json:"ports"`
+	Network  string    `json:"network"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	Results  []Result  `json:"results"`
+}
+
+// WriteJSON writes the report as indented JSON
+func WriteJSON(w io.Writer, report Report) error {
+	if report.Results == nil {
+		report.Results = []Result{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteCSV writes the results as CSV with a header row
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"host", "port", "network", "status", "service", "banner", "latency_ms"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := []string{
+			r.Host,
+			strconv.Itoa(r.Port),
+			r.Network,
+			string(r.Status),
+			r.Service,
+			r.Banner,
+			fmt.Sprintf("%.3f", float64(r.Latency)/float64(time.Millisecond)),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
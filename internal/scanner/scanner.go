@@ -0,0 +1,357 @@
+// Package scanner implements the TCP connect and UDP port scanner shared by
+// the scan command and the TUI scan mode.
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/ibrahmsql/gocat/internal/worker"
+)
+
+// Status is the state of a scanned port
+type Status string
+
+const (
+	StatusOpen         Status = "open"
+	StatusClosed       Status = "closed"
+	StatusFiltered     Status = "filtered"
+	StatusOpenFiltered Status = "open|filtered"
+)
+
+const (
+	// DefaultTimeout is the default per-port connect timeout
+	DefaultTimeout = 3 * time.Second
+
+	// DefaultConcurrency is the default number of ports probed at once
+	DefaultConcurrency = 100
+
+	// DefaultBannerTimeout bounds how long an open port is read for a banner
+	DefaultBannerTimeout = 2 * time.Second
+
+	maxBannerLength = 120
+)
+
+// Options configures a scan
+type Options struct {
+	// Network is tcp, tcp4, tcp6, udp, udp4 or udp6
+	Network     string
+	Timeout     time.Duration
+	Concurrency int
+	// Banner reads a banner from open TCP ports to identify the service
+	Banner        bool
+	BannerTimeout time.Duration
+	// Pool runs the probes; by default each scan starts its own pool of
+	// Concurrency workers
+	Pool *worker.WorkerPool
+	// Dial connects probes, within Timeout; by default a net.Dialer
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Result is the outcome of probing one port
+type Result struct {
+	Host    string        `json:"host"`
+	Port    int           `json:"port"`
+	Network string        `json:"network"`
+	Status  Status        `json:"status"`
+	Service string        `json:"service,omitempty"`
+	Banner  string        `json:"banner,omitempty"`
+	Latency time.Duration `json:"latency_ns"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// Scanner probes ports with bounded concurrency
+type Scanner struct {
+	opts Options
+}
+
+// New creates a scanner, filling unset options with defaults
+func New(opts Options) *Scanner {
+	if opts.Network == "" {
+		opts.Network = "tcp"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.BannerTimeout <= 0 {
+		opts.BannerTimeout = DefaultBannerTimeout
+	}
+	if opts.Dial == nil {
+		opts.Dial = (&net.Dialer{}).DialContext
+	}
+	return &Scanner{opts: opts}
+}
+
+// Scan probes every port on every host and streams the results. The channel
+// is closed when all probes finished or ctx is cancelled.
+func (s *Scanner) Scan(ctx context.Context, hosts []string, ports []int) <-chan Result {
+	results := make(chan Result, s.opts.Concurrency)
+
+	go func() {
+		defer close(results)
+
+		pool := s.opts.Pool
+		if pool == nil {
+			pool = worker.NewWorkerPool(&worker.PoolConfig{
+				MinWorkers:    1,
+				MaxWorkers:    s.opts.Concurrency,
+				QueueSize:     s.opts.Concurrency,
+				IdleTimeout:   s.opts.Timeout,
+				ScaleInterval: time.Second,
+			})
+			defer pool.Shutdown(time.Second)
+		}
+
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, s.opts.Concurrency)
+
+	dispatch:
+		for _, host := range hosts {
+			for _, port := range ports {
+				select {
+				case semaphore <- struct{}{}:
+				case <-ctx.Done():
+					break dispatch
+				}
+
+				wg.Add(1)
+				err := pool.SubmitWait(ctx, &worker.TaskFunc{
+					ID: "scan",
+					Fn: func(taskCtx context.Context) error {
+						defer wg.Done()
+						defer func() { <-semaphore }()
+
+						// A pool shutting down runs what is left queued
+						// with a cancelled context
+						if taskCtx.Err() != nil {
+							return taskCtx.Err()
+						}
+						result := s.ScanPort(ctx, host, port)
+						if ctx.Err() != nil {
+							return ctx.Err()
+						}
+						select {
+						case results <- result:
+						case <-ctx.Done():
+						}
+						return nil
+					},
+				})
+				if err != nil {
+					wg.Done()
+					<-semaphore
+					break dispatch
+				}
+			}
+		}
+
+		wg.Wait()
+	}()
+
+	return results
+}
+
+// ScanPort probes a single port
+func (s *Scanner) ScanPort(ctx context.Context, host string, port int) Result {
+	result := Result{Host: host, Port: port, Network: s.opts.Network}
+	if strings.HasPrefix(s.opts.Network, "udp") {
+		s.probeUDP(ctx, &result)
+	} else {
+		s.probeTCP(ctx, &result)
+	}
+	if result.Service == "" {
+		result.Service = ServiceName(s.opts.Network, port)
+	}
+	return result
+}
+
+func (s *Scanner) probeTCP(ctx context.Context, result *Result) {
+	address := net.JoinHostPort(result.Host, strconv.Itoa(result.Port))
+	start := time.Now()
+	conn, err := s.dial(ctx, address)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Status = classifyError(err)
+		result.Error = err.Error()
+		return
+	}
+	defer conn.Close()
+
+	result.Status = StatusOpen
+	if s.opts.Banner {
+		result.Banner = s.grabBanner(conn, result.Port)
+		if service := identifyBanner(result.Banner); service != "" {
+			result.Service = service
+		}
+	}
+}
+
+// dial connects a probe to address within the timeout
+func (s *Scanner) dial(ctx context.Context, address string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	return s.opts.Dial(ctx, s.opts.Network, address)
+}
+
+// probeUDP sends an empty datagram. A reply means open, an ICMP port
+// unreachable means closed and silence means open|filtered.
+func (s *Scanner) probeUDP(ctx context.Context, result *Result) {
+	address := net.JoinHostPort(result.Host, strconv.Itoa(result.Port))
+	start := time.Now()
+	conn, err := s.dial(ctx, address)
+	if err != nil {
+		result.Status = StatusFiltered
+		result.Error = err.Error()
+		return
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(udpProbe(result.Port)); err != nil {
+		result.Status = classifyError(err)
+		result.Error = err.Error()
+		return
+	}
+
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	result.Latency = time.Since(start)
+	switch {
+	case err == nil:
+		result.Status = StatusOpen
+		result.Banner = sanitizeBanner(string(buf[:n]))
+	case errors.Is(err, syscall.ECONNREFUSED):
+		result.Status = StatusClosed
+	default:
+		result.Status = StatusOpenFiltered
+	}
+}
+
+// udpProbe returns a payload likely to get a reply from the service on port
+func udpProbe(port int) []byte {
+	switch port {
+	case 53:
+		// DNS query for the root NS records
+		return []byte{0x13, 0x37, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01}
+	case 123:
+		// NTP version 3 client request
+		probe := make([]byte, 48)
+		probe[0] = 0x1b
+		return probe
+	}
+	return []byte{}
+}
+
+// grabBanner reads what the service sends first; HTTP-like ports that stay
+// silent are sent a HEAD request
+func (s *Scanner) grabBanner(conn net.Conn, port int) string {
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(s.opts.BannerTimeout))
+	line, err := reader.ReadString('\n')
+	if line == "" && err != nil && isHTTPPort(port) {
+		conn.SetDeadline(time.Now().Add(s.opts.BannerTimeout))
+		if _, err := conn.Write([]byte("HEAD / HTTP/1.0\r\n\r\n")); err == nil {
+			line, _ = reader.ReadString('\n')
+		}
+	}
+	return sanitizeBanner(line)
+}
+
+func isHTTPPort(port int) bool {
+	switch port {
+	case 80, 81, 3000, 5000, 8000, 8008, 8080, 8081, 8088, 8888, 9000:
+		return true
+	}
+	return false
+}
+
+// sanitizeBanner keeps the first line of printable text
+func sanitizeBanner(banner string) string {
+	if idx := strings.IndexAny(banner, "\r\n"); idx >= 0 {
+		banner = banner[:idx]
+	}
+	banner = strings.Map(func(r rune) rune {
+		if unicode.IsPrint(r) {
+			return r
+		}
+		return '.'
+	}, banner)
+	banner = strings.TrimSpace(banner)
+	if len(banner) > maxBannerLength {
+		banner = banner[:maxBannerLength]
+	}
+	return banner
+}
+
+// classifyError maps a dial error to a port status
+func classifyError(err error) Status {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return StatusClosed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return StatusFiltered
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return StatusFiltered
+	}
+	return StatusClosed
+}
+
+// ParsePorts parses a comma separated list of ports and ranges such as
+// "22,80,8000-8100". Duplicates are dropped and the order is preserved.
+func ParsePorts(spec string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+				return nil, fmt.Errorf("invalid port range: %s", part)
+			}
+		}
+		if start < 1 || end > 65535 || start > end {
+			return nil, fmt.Errorf("invalid port range: %s", part)
+		}
+
+		for port := start; port <= end; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports specified")
+	}
+	return ports, nil
+}
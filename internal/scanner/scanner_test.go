@@ -0,0 +1,260 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/worker"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{"80", []int{80}, false},
+		{"22,80,443", []int{22, 80, 443}, false},
+		{"8000-8003", []int{8000, 8001, 8002, 8003}, false},
+		{"22, 20-23 ,22", []int{22, 20, 21, 23}, false},
+		{"0", nil, true},
+		{"65536", nil, true},
+		{"100-50", nil, true},
+		{"http", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePorts(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePorts(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTargets(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"localhost", []string{"localhost"}, false},
+		{"10.0.0.0/30", []string{"10.0.0.1", "10.0.0.2"}, false},
+		{"10.0.0.4/31", []string{"10.0.0.4", "10.0.0.5"}, false},
+		{"10.0.0.5-7", []string{"10.0.0.5", "10.0.0.6", "10.0.0.7"}, false},
+		{"10.0.0.1, 10.0.0.1-2", []string{"10.0.0.1", "10.0.0.2"}, false},
+		{"[::1]", []string{"::1"}, false},
+		{"10.0.0.0/8", nil, true},
+		{"10.0.0.9-3", nil, true},
+		{"bad host!", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandTargets(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandTargets(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandTargets(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// listen starts a TCP listener that greets every connection with banner
+func listen(t *testing.T, banner string) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if banner != "" {
+				conn.Write([]byte(banner))
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a local port with nothing listening on it
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestScanTCP(t *testing.T) {
+	open := listen(t, "SSH-2.0-OpenSSH_9.6\r\n")
+	closed := closedPort(t)
+
+	s := New(Options{Timeout: time.Second, Banner: true})
+	results := make(map[int]Result)
+	for r := range s.Scan(context.Background(), []string{"127.0.0.1"}, []int{open, closed}) {
+		results[r.Port] = r
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if r := results[open]; r.Status != StatusOpen || r.Service != "ssh" || r.Banner != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("open port result = %+v", r)
+	}
+	if r := results[closed]; r.Status != StatusClosed {
+		t.Errorf("closed port status = %s, want %s", r.Status, StatusClosed)
+	}
+}
+
+func TestScanCancel(t *testing.T) {
+	ports, _ := ParsePorts("1-65535")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := New(Options{Timeout: 200 * time.Millisecond, Concurrency: 4})
+	results := s.Scan(ctx, []string{"127.0.0.1"}, ports)
+	<-results
+	cancel()
+
+	count := 0
+	done := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				if count >= len(ports)-1 {
+					t.Errorf("scan was not cancelled (%d results)", count)
+				}
+				return
+			}
+			count++
+		case <-done:
+			t.Fatal("results channel not closed after cancel")
+		}
+	}
+}
+
+func TestScanPoolShutdown(t *testing.T) {
+	ports, _ := ParsePorts("1-1000")
+	pool := worker.NewWorkerPool(&worker.PoolConfig{
+		MinWorkers:    1,
+		MaxWorkers:    2,
+		QueueSize:     16,
+		IdleTimeout:   time.Minute,
+		ScaleInterval: time.Second,
+	})
+	slow := func(ctx context.Context, network, address string) (net.Conn, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, &net.OpError{Op: "dial", Err: context.DeadlineExceeded}
+	}
+
+	s := New(Options{Concurrency: 16, Pool: pool, Dial: slow})
+	results := s.Scan(context.Background(), []string{"127.0.0.1"}, ports)
+	<-results
+	// Tasks still queued when the drain deadline passes must not hang the scan
+	go pool.Shutdown(10 * time.Millisecond)
+
+	done := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				return
+			}
+		case <-done:
+			t.Fatal("results channel not closed after the pool shut down")
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	results := []Result{
+		{Host: "10.0.0.1", Port: 22, Network: "tcp", Status: StatusOpen, Service: "ssh", Banner: "SSH-2.0, test", Latency: 1500 * time.Microsecond},
+		{Host: "10.0.0.1", Port: 23, Network: "tcp", Status: StatusClosed, Service: "telnet"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, Report{Targets: "10.0.0.1", Ports: "22-23", Network: "tcp", Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(report.Results, results) {
+		t.Errorf("JSON round trip = %+v, want %+v", report.Results, results)
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d CSV records, want 3", len(records))
+	}
+	if got := records[1]; got[4] != "ssh" || got[5] != "SSH-2.0, test" || got[6] != "1.500" {
+		t.Errorf("CSV record = %v", got)
+	}
+}
+
+// BenchmarkScan10kPorts probes 10k loopback ports, with a pool per scan and
+// with one pool shared across scans
+func BenchmarkScan10kPorts(b *testing.B) {
+	ports, err := ParsePorts("50001-60000")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	run := func(b *testing.B, pool *worker.WorkerPool) {
+		b.ReportAllocs()
+		s := New(Options{Timeout: time.Second, Concurrency: 1000, Pool: pool})
+		for i := 0; i < b.N; i++ {
+			count := 0
+			for range s.Scan(context.Background(), []string{"127.0.0.1"}, ports) {
+				count++
+			}
+			if count != len(ports) {
+				b.Fatalf("got %d results, want %d", count, len(ports))
+			}
+		}
+	}
+
+	b.Run("pool-per-scan", func(b *testing.B) { run(b, nil) })
+	b.Run("shared-pool", func(b *testing.B) {
+		pool := worker.NewWorkerPool(&worker.PoolConfig{
+			MinWorkers:    1,
+			MaxWorkers:    1000,
+			QueueSize:     1000,
+			IdleTimeout:   time.Minute,
+			ScaleInterval: time.Second,
+		})
+		defer pool.ForceShutdown()
+		run(b, pool)
+	})
+}
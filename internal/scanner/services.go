@@ -0,0 +1,65 @@
+package scanner
+
+import "strings"
+
+// tcpServices maps well-known TCP ports to service names
+var tcpServices = map[int]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "dns", 80: "http",
+	110: "pop3", 111: "rpcbind", 135: "msrpc", 139: "netbios", 143: "imap",
+	389: "ldap", 443: "https", 445: "smb", 465: "smtps", 587: "submission",
+	636: "ldaps", 993: "imaps", 995: "pop3s", 1433: "mssql", 1521: "oracle",
+	2049: "nfs", 2375: "docker", 2376: "docker-tls", 3000: "http-alt",
+	3306: "mysql", 3389: "rdp", 5432: "postgresql", 5672: "amqp",
+	5900: "vnc", 6379: "redis", 6443: "kubernetes", 8000: "http-alt",
+	8080: "http-proxy", 8443: "https-alt", 8888: "http-alt", 9000: "http-alt",
+	9200: "elasticsearch", 11211: "memcached", 27017: "mongodb",
+}
+
+// udpServices maps well-known UDP ports to service names
+var udpServices = map[int]string{
+	53: "dns", 67: "dhcp", 69: "tftp", 123: "ntp", 137: "netbios-ns",
+	161: "snmp", 500: "isakmp", 514: "syslog", 1900: "ssdp", 5353: "mdns",
+}
+
+// ServiceName returns the well-known service for a port, or "" if unknown
+func ServiceName(network string, port int) string {
+	if strings.HasPrefix(network, "udp") {
+		return udpServices[port]
+	}
+	return tcpServices[port]
+}
+
+// bannerServices identifies services from banner prefixes
+var bannerServices = []struct {
+	prefix  string
+	service string
+}{
+	{"SSH-", "ssh"},
+	{"HTTP/", "http"},
+	{"220 ", "ftp/smtp"},
+	{"220-", "ftp/smtp"},
+	{"+OK", "pop3"},
+	{"* OK", "imap"},
+	{"RFB ", "vnc"},
+	{"-ERR", "redis"},
+}
+
+// identifyBanner returns the service a banner belongs to, or "" if unknown
+func identifyBanner(banner string) string {
+	for _, b := range bannerServices {
+		if strings.HasPrefix(banner, b.prefix) {
+			if b.service == "ftp/smtp" {
+				lower := strings.ToLower(banner)
+				switch {
+				case strings.Contains(lower, "ftp"):
+					return "ftp"
+				case strings.Contains(lower, "smtp"), strings.Contains(lower, "esmtp"), strings.Contains(lower, "mail"):
+					return "smtp"
+				}
+				return ""
+			}
+			return b.service
+		}
+	}
+	return ""
+}
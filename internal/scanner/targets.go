@@ -0,0 +1,126 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// MaxHosts bounds the number of hosts a target specification may expand to
+const MaxHosts = 65536
+
+// ExpandTargets expands a comma separated list of hosts, CIDR networks
+// (192.168.1.0/24) and last-octet ranges (192.168.1.10-20) into hosts.
+// Network and broadcast addresses of IPv4 networks larger than /31 are skipped.
+func ExpandTargets(spec string) ([]string, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+
+	add := func(host string) error {
+		if seen[host] {
+			return nil
+		}
+		if len(hosts) >= MaxHosts {
+			return fmt.Errorf("target expands to more than %d hosts", MaxHosts)
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+		return nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		switch {
+		case strings.Contains(part, "/"):
+			prefix, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q", part)
+			}
+			if err := expandPrefix(prefix.Masked(), add); err != nil {
+				return nil, err
+			}
+		case isOctetRange(part):
+			if err := expandOctetRange(part, add); err != nil {
+				return nil, err
+			}
+		default:
+			host := strings.Trim(part, "[]")
+			if net.ParseIP(host) == nil && !validHostname(host) {
+				return nil, fmt.Errorf("invalid target %q", part)
+			}
+			if err := add(host); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no targets specified")
+	}
+	return hosts, nil
+}
+
+func expandPrefix(prefix netip.Prefix, add func(string) error) error {
+	bits := prefix.Addr().BitLen() - prefix.Bits()
+	if bits > 16 {
+		return fmt.Errorf("network %s is too large (max %d hosts)", prefix, MaxHosts)
+	}
+
+	skipEdges := prefix.Addr().Is4() && bits > 1
+	total := 1 << bits
+	addr := prefix.Addr()
+	for i := 0; i < total; i++ {
+		if !(skipEdges && (i == 0 || i == total-1)) {
+			if err := add(addr.String()); err != nil {
+				return err
+			}
+		}
+		addr = addr.Next()
+	}
+	return nil
+}
+
+// isOctetRange reports whether part looks like a.b.c.d-e
+func isOctetRange(part string) bool {
+	base, _, found := strings.Cut(part, "-")
+	return found && net.ParseIP(base).To4() != nil
+}
+
+func expandOctetRange(part string, add func(string) error) error {
+	base, end, _ := strings.Cut(part, "-")
+	ip := net.ParseIP(base).To4()
+
+	last, err := strconv.Atoi(end)
+	if err != nil || last < int(ip[3]) || last > 255 {
+		return fmt.Errorf("invalid address range %q", part)
+	}
+	for octet := int(ip[3]); octet <= last; octet++ {
+		if err := add(fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], octet)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
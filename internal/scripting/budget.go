@@ -0,0 +1,80 @@
+package scripting
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// budget bounds the time a script spends running Lua. Time spent blocked
+// in the API, waiting for the network or a timer, is not counted, so a
+// handler may hold a conversation for as long as the peer keeps it up.
+type budget struct {
+	limit  time.Duration
+	cancel context.CancelCauseFunc
+
+	mu     sync.Mutex
+	used   time.Duration // running time before since
+	since  time.Time     // start of the current running period
+	paused int           // blocking calls in progress
+	timer  *time.Timer
+}
+
+// newBudget returns a context cancelled with context.DeadlineExceeded once
+// limit of running time is used
+func newBudget(ctx context.Context, limit time.Duration) (context.Context, *budget) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	b := &budget{limit: limit, cancel: cancel, since: time.Now()}
+	b.timer = time.AfterFunc(limit, b.check)
+	return ctx, b
+}
+
+// check cancels the context if the budget is spent, or waits for the rest
+func (b *budget) check() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paused > 0 {
+		return
+	}
+	if left := b.limit - b.used - time.Since(b.since); left > 0 {
+		b.timer.Reset(left)
+		return
+	}
+	b.cancel(context.DeadlineExceeded)
+}
+
+// pause stops counting time until the matching resume
+func (b *budget) pause() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paused == 0 {
+		b.used += time.Since(b.since)
+		b.timer.Stop()
+	}
+	b.paused++
+}
+
+// resume counts time again once no blocking call is in progress
+func (b *budget) resume() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.paused--
+	if b.paused > 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.since = time.Now()
+	b.mu.Unlock()
+	b.check()
+}
+
+// stop releases the timer and cancels the context
+func (b *budget) stop() {
+	b.timer.Stop()
+	b.cancel(context.Canceled)
+}
@@ -0,0 +1,391 @@
+package scripting
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Capability kinds scripts can declare in their header
+const (
+	CapabilityNet  = "net"
+	CapabilityFS   = "fs"
+	CapabilityExec = "exec"
+
+	// capabilityHeader is the comment prefix used to declare a capability, e.g.
+	//   -- Capability: net:*.example.com:80,443
+	//   -- Capability: fs:/tmp/gocat
+	//   -- Capability: exec
+	capabilityHeader = "Capability:"
+
+	// approveAll approves every capability a script declares
+	approveAll = "all"
+)
+
+// Capability is a single permission declared by a script
+type Capability struct {
+	Kind  string
+	Scope string
+
+	// Parsed scope for network capabilities
+	host  string
+	ports []portRange
+}
+
+type portRange struct {
+	from, to int
+}
+
+// CapabilitySet holds the capabilities granted to a running script.
+// A nil set grants nothing.
+type CapabilitySet struct {
+	caps []Capability
+}
+
+// String returns the capability in its header form
+func (c Capability) String() string {
+	if c.Scope == "" {
+		return c.Kind
+	}
+	return c.Kind + ":" + c.Scope
+}
+
+// Description returns a human readable explanation of the capability
+func (c Capability) Description() string {
+	switch c.Kind {
+	case CapabilityNet:
+		host := c.host
+		if host == "" || host == "*" {
+			host = "any host"
+		}
+		if len(c.ports) == 0 {
+			return fmt.Sprintf("Network access to %s on any port", host)
+		}
+		return fmt.Sprintf("Network access to %s on port(s) %s", host, c.portString())
+	case CapabilityFS:
+		if c.Scope == "" {
+			return "Filesystem access to any path"
+		}
+		return fmt.Sprintf("Filesystem access to %s", c.Scope)
+	case CapabilityExec:
+		return "Execute external commands"
+	}
+	return c.String()
+}
+
+func (c Capability) portString() string {
+	parts := make([]string, 0, len(c.ports))
+	for _, r := range c.ports {
+		if r.from == r.to {
+			parts = append(parts, strconv.Itoa(r.from))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.from, r.to))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseCapability parses a capability in the form kind[:scope]
+func ParseCapability(spec string) (Capability, error) {
+	spec = strings.TrimSpace(spec)
+	kind, scope, _ := strings.Cut(spec, ":")
+	capability := Capability{Kind: strings.ToLower(kind), Scope: scope}
+
+	switch capability.Kind {
+	case CapabilityNet:
+		if err := capability.parseNetScope(); err != nil {
+			return Capability{}, fmt.Errorf("invalid capability %q: %w", spec, err)
+		}
+	case CapabilityFS:
+		if scope != "" {
+			capability.Scope = filepath.Clean(scope)
+		}
+	case CapabilityExec:
+		if scope != "" {
+			return Capability{}, fmt.Errorf("invalid capability %q: exec takes no scope", spec)
+		}
+	default:
+		return Capability{}, fmt.Errorf("unknown capability %q", spec)
+	}
+
+	return capability, nil
+}
+
+// parseNetScope parses host[:ports] where host may be bracketed for IPv6
+func (c *Capability) parseNetScope() error {
+	scope := c.Scope
+	if scope == "" || scope == "*" {
+		c.host = "*"
+		return nil
+	}
+
+	var host, ports string
+	if strings.HasPrefix(scope, "[") {
+		end := strings.Index(scope, "]")
+		if end < 0 {
+			return fmt.Errorf("missing ']' in host")
+		}
+		host = scope[1:end]
+		ports = strings.TrimPrefix(scope[end+1:], ":")
+	} else if strings.Count(scope, ":") <= 1 {
+		host, ports, _ = strings.Cut(scope, ":")
+	} else {
+		return fmt.Errorf("IPv6 hosts must be enclosed in brackets")
+	}
+
+	if host == "" {
+		host = "*"
+	}
+	if strings.Contains(host, "/") {
+		if _, _, err := net.ParseCIDR(host); err != nil {
+			return fmt.Errorf("invalid network %q", host)
+		}
+	}
+	c.host = strings.ToLower(host)
+
+	if ports == "" || ports == "*" {
+		return nil
+	}
+	ranges, err := parsePortRanges(ports)
+	if err != nil {
+		return err
+	}
+	c.ports = ranges
+	return nil
+}
+
+// parsePortRanges parses a comma separated list of ports and port ranges
+func parsePortRanges(ports string) ([]portRange, error) {
+	var ranges []portRange
+	for _, part := range strings.Split(ports, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			to = from
+		}
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		end, err := strconv.Atoi(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		if start < 0 || end > 65535 || start > end {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, portRange{start, end})
+	}
+	return ranges, nil
+}
+
+// ParseCapabilities extracts the capabilities declared in a script header.
+// Only the leading comment block is inspected.
+func ParseCapabilities(content string) ([]Capability, error) {
+	var caps []Capability
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		if !strings.HasPrefix(comment, capabilityHeader) {
+			continue
+		}
+
+		capability, err := ParseCapability(strings.TrimPrefix(comment, capabilityHeader))
+		if err != nil {
+			return nil, err
+		}
+		caps = append(caps, capability)
+	}
+
+	return caps, nil
+}
+
+// ApproveCapabilities checks the declared capabilities against the ones approved
+// on the command line and returns the granted set. Approvals may name a
+// capability exactly, a whole kind (net, fs, exec) or "all".
+func ApproveCapabilities(declared []Capability, approved []string) (*CapabilitySet, error) {
+	var missing []string
+
+	for _, capability := range declared {
+		if !isApproved(capability, approved) {
+			missing = append(missing, capability.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		flags := make([]string, len(missing))
+		for i, m := range missing {
+			flags[i] = "--allow-cap " + m
+		}
+		return nil, fmt.Errorf("script requires capabilities that were not approved: %s (use %s)",
+			strings.Join(missing, ", "), strings.Join(flags, " "))
+	}
+
+	return &CapabilitySet{caps: declared}, nil
+}
+
+// LoadCapabilities reads the script header and approves its capabilities
+func LoadCapabilities(scriptPath string, approved []string) (*CapabilitySet, error) {
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script file: %w", err)
+	}
+
+	declared, err := ParseCapabilities(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return ApproveCapabilities(declared, approved)
+}
+
+func isApproved(capability Capability, approved []string) bool {
+	for _, a := range approved {
+		a = strings.TrimSpace(a)
+		if strings.EqualFold(a, approveAll) || strings.EqualFold(a, capability.Kind) {
+			return true
+		}
+		if parsed, err := ParseCapability(a); err == nil && parsed.String() == capability.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the granted capabilities
+func (s *CapabilitySet) List() []Capability {
+	if s == nil {
+		return nil
+	}
+	return s.caps
+}
+
+// Has reports whether any capability of the given kind is granted
+func (s *CapabilitySet) Has(kind string) bool {
+	if s == nil {
+		return false
+	}
+	for _, c := range s.caps {
+		if c.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowNet reports whether connecting to host:port is permitted.
+// Listening is checked with host "*", which only wildcard grants match.
+func (s *CapabilitySet) AllowNet(host string, port int) bool {
+	if s == nil {
+		return false
+	}
+	for _, c := range s.caps {
+		if c.Kind == CapabilityNet && c.matchHost(host) && c.matchPort(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowPath reports whether the filesystem path is permitted. Symlinks are
+// resolved first, so a link inside a permitted directory does not lead
+// outside it.
+func (s *CapabilitySet) AllowPath(path string) bool {
+	if s == nil {
+		return false
+	}
+
+	abs, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+
+	for _, c := range s.caps {
+		if c.Kind != CapabilityFS {
+			continue
+		}
+		if c.Scope == "" {
+			return true
+		}
+
+		scope, err := resolvePath(c.Scope)
+		if err != nil {
+			continue
+		}
+		if abs == scope || strings.HasPrefix(abs, scope+string(filepath.Separator)) {
+			return true
+		}
+		if matched, _ := filepath.Match(scope, abs); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath makes path absolute and resolves its symlinks. A path that
+// does not exist yet, such as a file about to be created, is resolved up to
+// its deepest existing directory; a dangling symlink is an error.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rest := ""
+	for dir := abs; ; {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if _, err := os.Lstat(dir); err == nil {
+			return "", fmt.Errorf("dangling symlink %s", dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+func (c Capability) matchHost(host string) bool {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	switch {
+	case c.host == "*":
+		return true
+	case strings.HasPrefix(c.host, "*."):
+		return strings.HasSuffix(host, c.host[1:])
+	case strings.Contains(c.host, "/"):
+		_, network, err := net.ParseCIDR(c.host)
+		ip := net.ParseIP(host)
+		return err == nil && ip != nil && network.Contains(ip)
+	}
+	return host == c.host
+}
+
+func (c Capability) matchPort(port int) bool {
+	if len(c.ports) == 0 {
+		return true
+	}
+	for _, r := range c.ports {
+		if port >= r.from && port <= r.to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+package scripting
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	content := `-- Example Script
+-- Purpose: testing
+-- Capability: net:*.example.com:80,443
+-- Capability: net:[::1]:8000-8080
+-- Capability: fs:/tmp/gocat
+-- Capability: exec
+
+local x = 1
+-- Capability: net:ignored.example.org
+`
+
+	caps, err := ParseCapabilities(content)
+	if err != nil {
+		t.Fatalf("ParseCapabilities failed: %v", err)
+	}
+
+	expected := []string{"net:*.example.com:80,443", "net:[::1]:8000-8080", "fs:/tmp/gocat", "exec"}
+	if len(caps) != len(expected) {
+		t.Fatalf("Expected %d capabilities, got %d: %v", len(expected), len(caps), caps)
+	}
+	for i, c := range caps {
+		if c.String() != expected[i] {
+			t.Errorf("Capability %d: expected %s, got %s", i, expected[i], c)
+		}
+	}
+}
+
+func TestParseCapabilityErrors(t *testing.T) {
+	invalid := []string{
+		"network",
+		"net:example.com:99999",
+		"net:example.com:abc",
+		"net:::1:80",
+		"exec:/bin/sh",
+	}
+
+	for _, spec := range invalid {
+		if _, err := ParseCapability(spec); err == nil {
+			t.Errorf("Expected error for capability %q", spec)
+		}
+	}
+}
+
+func TestApproveCapabilities(t *testing.T) {
+	declared, err := ParseCapabilities("-- Capability: net:example.com:80\n-- Capability: exec\n")
+	if err != nil {
+		t.Fatalf("ParseCapabilities failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		approved []string
+		wantErr  bool
+	}{
+		{"none approved", nil, true},
+		{"partial", []string{"net"}, true},
+		{"by kind", []string{"net", "exec"}, false},
+		{"exact", []string{"net:example.com:80", "exec"}, false},
+		{"all", []string{"all"}, false},
+		{"different scope", []string{"net:example.org:80", "exec"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ApproveCapabilities(declared, tt.approved)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ApproveCapabilities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCapabilitySetAllowNet(t *testing.T) {
+	declared, err := ParseCapabilities(strings.Join([]string{
+		"-- Capability: net:*.example.com:443",
+		"-- Capability: net:10.0.0.0/8",
+		"-- Capability: net:*:8080",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseCapabilities failed: %v", err)
+	}
+	caps, err := ApproveCapabilities(declared, []string{"all"})
+	if err != nil {
+		t.Fatalf("ApproveCapabilities failed: %v", err)
+	}
+
+	tests := []struct {
+		host    string
+		port    int
+		allowed bool
+	}{
+		{"www.example.com", 443, true},
+		{"www.example.com", 80, false},
+		{"example.org", 443, false},
+		{"10.1.2.3", 22, true},
+		{"192.168.1.1", 22, false},
+		{"anything", 8080, true},
+		{"*", 8080, true},
+		{"*", 443, false},
+	}
+
+	for _, tt := range tests {
+		if got := caps.AllowNet(tt.host, tt.port); got != tt.allowed {
+			t.Errorf("AllowNet(%s, %d) = %v, want %v", tt.host, tt.port, got, tt.allowed)
+		}
+	}
+
+	var none *CapabilitySet
+	if none.AllowNet("example.com", 80) {
+		t.Error("Nil capability set should not allow network access")
+	}
+}
+
+func TestCapabilitySetAllowPath(t *testing.T) {
+	dir := t.TempDir()
+	declared := []Capability{{Kind: CapabilityFS, Scope: dir}}
+	caps, err := ApproveCapabilities(declared, []string{"fs"})
+	if err != nil {
+		t.Fatalf("ApproveCapabilities failed: %v", err)
+	}
+
+	if !caps.AllowPath(filepath.Join(dir, "file.txt")) {
+		t.Error("Expected path inside granted directory to be allowed")
+	}
+	if caps.AllowPath(dir + "-other/file.txt") {
+		t.Error("Expected sibling path to be denied")
+	}
+	if caps.AllowPath("/etc/passwd") {
+		t.Error("Expected path outside granted directory to be denied")
+	}
+
+	// Symlinks inside the directory do not lead outside it
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if caps.AllowPath(filepath.Join(dir, "escape", "secret")) {
+		t.Error("Expected path through a symlink leaving the directory to be denied")
+	}
+	if err := os.Symlink(filepath.Join(outside, "new"), filepath.Join(dir, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if caps.AllowPath(filepath.Join(dir, "dangling")) {
+		t.Error("Expected dangling symlink leaving the directory to be denied")
+	}
+	if err := os.Symlink(dir, filepath.Join(outside, "back")); err != nil {
+		t.Fatal(err)
+	}
+	if !caps.AllowPath(filepath.Join(outside, "back", "file.txt")) {
+		t.Error("Expected symlink into the granted directory to be allowed")
+	}
+}
+
+func TestEngineSandbox(t *testing.T) {
+	engine := NewLuaEngine(nil)
+	if engine == nil {
+		t.Fatal("NewLuaEngine returned nil")
+	}
+	defer engine.Close()
+
+	if err := engine.L.DoString(`assert(os.time() > 0)`); err != nil {
+		t.Errorf("Safe os functions should be available: %v", err)
+	}
+	if err := engine.L.DoString(`os.execute("true")`); err == nil {
+		t.Error("os.execute should not be available without the exec capability")
+	}
+	if err := engine.L.DoString(`io.open("/etc/passwd")`); err == nil {
+		t.Error("io.open should not be available without the fs capability")
+	}
+
+	results, err := engine.L.LoadString(`local c, err = connect("127.0.0.1", 1, "tcp") return err`)
+	if err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	engine.L.Push(results)
+	engine.L.Call(0, 1)
+	if msg := engine.L.ToString(-1); !strings.Contains(msg, "not granted") {
+		t.Errorf("Expected capability error from connect, got %q", msg)
+	}
+}
+
+func TestEngineFilesystemCapability(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed.txt")
+	if err := os.WriteFile(allowed, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	caps, err := ApproveCapabilities([]Capability{{Kind: CapabilityFS, Scope: dir}}, []string{"fs"})
+	if err != nil {
+		t.Fatalf("ApproveCapabilities failed: %v", err)
+	}
+	config := DefaultEngineConfig()
+	config.Capabilities = caps
+
+	engine := NewLuaEngine(config)
+	if engine == nil {
+		t.Fatal("NewLuaEngine returned nil")
+	}
+	defer engine.Close()
+
+	if err := engine.L.DoString(`local f = assert(io.open("` + allowed + `")) assert(f:read("*a") == "data") f:close()`); err != nil {
+		t.Errorf("Reading granted path failed: %v", err)
+	}
+	if err := engine.L.DoString(`io.open("/etc/passwd")`); err == nil {
+		t.Error("Reading path outside the grant should fail")
+	}
+}
+
+func TestEngineLibraries(t *testing.T) {
+	config := DefaultEngineConfig()
+	config.Libraries = []string{LibBase, LibString}
+
+	engine := NewLuaEngine(config)
+	if engine == nil {
+		t.Fatal("NewLuaEngine returned nil")
+	}
+	defer engine.Close()
+
+	if err := engine.L.DoString(`assert(string.upper("a") == "A")`); err != nil {
+		t.Errorf("string library should be loaded: %v", err)
+	}
+	if err := engine.L.DoString(`return math.floor(1.5)`); err == nil {
+		t.Error("math library should not be loaded")
+	}
+
+	config.Libraries = []string{"debug"}
+	if NewLuaEngine(config) != nil {
+		t.Error("Unknown library should be rejected")
+	}
+}
+
+func TestEngineExecutionBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loop.lua")
+	if err := os.WriteFile(path, []byte("while true do end"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	config := DefaultEngineConfig()
+	config.MaxExecutionTime = 100 * time.Millisecond
+	engine := NewLuaEngine(config)
+	if engine == nil {
+		t.Fatal("NewLuaEngine returned nil")
+	}
+	defer engine.Close()
+
+	start := time.Now()
+	err := engine.LoadScript(path)
+	if err == nil || !strings.Contains(err.Error(), "execution time limit") {
+		t.Errorf("Expected execution time error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Script was not interrupted in time: %v", elapsed)
+	}
+}
+
+func TestEngineCancellation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sleep.lua")
+	if err := os.WriteFile(path, []byte("sleep(10)"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	engine := NewLuaEngine(nil)
+	if engine == nil {
+		t.Fatal("NewLuaEngine returned nil")
+	}
+	defer engine.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := engine.LoadScriptWithContext(ctx, path); err == nil {
+		t.Error("Expected cancelled script to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("sleep was not interrupted by cancellation: %v", elapsed)
+	}
+}
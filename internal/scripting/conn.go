@@ -0,0 +1,301 @@
+package scripting
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	connTypeName     = "gocat.conn"
+	defaultReadChunk = 4096
+	maxConnReadSize  = 1024 * 1024 // 1MB cap for a single conn:read()
+)
+
+// luaConn is the Go side of the connection object exposed to scripts. It backs
+// connections handed to --lua-exec handlers as well as those opened through
+// gocat.tls and gocat.udp.
+type luaConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	// datagram connections return one datagram per read
+	datagram bool
+	closed   bool
+	mu       sync.Mutex
+	// release is called once the connection is closed
+	release func()
+}
+
+func newLuaConn(conn net.Conn) *luaConn {
+	return &luaConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+// newConnValue wraps lc in a connection object
+func newConnValue(L *lua.LState, lc *luaConn) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = lc
+	L.SetMetatable(ud, L.GetTypeMetatable(connTypeName))
+	return ud
+}
+
+// registerConnType registers the metatable backing connection objects
+func (e *LuaEngine) registerConnType() {
+	mt := e.L.NewTypeMetatable(connTypeName)
+	e.L.SetField(mt, "__index", e.L.SetFuncs(e.L.NewTable(), map[string]lua.LGFunction{
+		"read":       e.connRead,
+		"readline":   e.connReadLine,
+		"write":      e.connWrite,
+		"settimeout": connSetTimeout,
+		"peer":       connPeer,
+		"localaddr":  connLocal,
+		"tls":        connTLS,
+		"close":      connClose,
+	}))
+	e.L.SetField(mt, "__tostring", e.L.NewFunction(connToString))
+}
+
+// checkConn extracts the connection object from the first argument
+func checkConn(L *lua.LState) *luaConn {
+	ud := L.CheckUserData(1)
+	if lc, ok := ud.Value.(*luaConn); ok {
+		return lc
+	}
+	L.ArgError(1, "connection expected")
+	return nil
+}
+
+// connRead implements conn:read([n]); returns up to n bytes or nil, err
+func (e *LuaEngine) connRead(L *lua.LState) int {
+	lc := checkConn(L)
+	defaultSize := defaultReadChunk
+	if lc.datagram {
+		defaultSize = maxDatagramSize
+	}
+	size := L.OptInt(2, defaultSize)
+	if size <= 0 {
+		size = defaultSize
+	}
+	if size > maxConnReadSize {
+		size = maxConnReadSize
+	}
+
+	return e.async(L, func() asyncResult {
+		buffer := make([]byte, size)
+		n, err := lc.Read(buffer)
+		if n > 0 {
+			return stringResult(string(buffer[:n]))
+		}
+		return errorResult(readErrorString(err))
+	})
+}
+
+// Read reads from the connection honouring the script's timeout. Datagram
+// connections bypass the line reader so each call returns one datagram.
+func (lc *luaConn) Read(p []byte) (int, error) {
+	lc.applyReadDeadline()
+	if lc.datagram {
+		return lc.conn.Read(p)
+	}
+	return lc.reader.Read(p)
+}
+
+// connReadLine implements conn:readline(); returns a line without its terminator or nil, err
+func (e *LuaEngine) connReadLine(L *lua.LState) int {
+	lc := checkConn(L)
+
+	return e.async(L, func() asyncResult {
+		lc.applyReadDeadline()
+		line, err := lc.reader.ReadString('\n')
+		if err != nil && line == "" {
+			return errorResult(readErrorString(err))
+		}
+		return stringResult(strings.TrimRight(line, "\r\n"))
+	})
+}
+
+// connWrite implements conn:write(data); returns bytes written or nil, err
+func (e *LuaEngine) connWrite(L *lua.LState) int {
+	lc := checkConn(L)
+	data := L.CheckString(2)
+
+	return e.async(L, func() asyncResult {
+		if lc.timeout > 0 {
+			_ = lc.conn.SetWriteDeadline(time.Now().Add(lc.timeout))
+		}
+		n, err := lc.conn.Write([]byte(data))
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		return numberResult(float64(n))
+	})
+}
+
+// connSetTimeout implements conn:settimeout(seconds); 0 disables the timeout
+func connSetTimeout(L *lua.LState) int {
+	lc := checkConn(L)
+	seconds := float64(L.CheckNumber(2))
+	if seconds < 0 {
+		seconds = 0
+	}
+	lc.timeout = time.Duration(seconds * float64(time.Second))
+	if lc.timeout == 0 {
+		_ = lc.conn.SetDeadline(time.Time{})
+	}
+	return 0
+}
+
+// connPeer implements conn:peer(); returns the remote host and port
+func connPeer(L *lua.LState) int {
+	return pushAddr(L, checkConn(L).conn.RemoteAddr())
+}
+
+// connLocal implements conn:localaddr(); returns the local host and port
+func connLocal(L *lua.LState) int {
+	return pushAddr(L, checkConn(L).conn.LocalAddr())
+}
+
+// connTLS implements conn:tls(); returns the TLS session details or nil for plain connections
+func connTLS(L *lua.LState) int {
+	tlsConn, ok := checkConn(L).conn.(*tls.Conn)
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	state := tlsConn.ConnectionState()
+	info := L.NewTable()
+	info.RawSetString("version", lua.LString(tls.VersionName(state.Version)))
+	info.RawSetString("cipher", lua.LString(tls.CipherSuiteName(state.CipherSuite)))
+	info.RawSetString("server_name", lua.LString(state.ServerName))
+	info.RawSetString("alpn", lua.LString(state.NegotiatedProtocol))
+	info.RawSetString("resumed", lua.LBool(state.DidResume))
+
+	certs := L.NewTable()
+	for _, cert := range state.PeerCertificates {
+		certs.Append(certificateTable(L, cert))
+	}
+	info.RawSetString("certificates", certs)
+
+	L.Push(info)
+	return 1
+}
+
+// connClose implements conn:close()
+func connClose(L *lua.LState) int {
+	checkConn(L).close()
+	return 0
+}
+
+// connToString implements tostring(conn)
+func connToString(L *lua.LState) int {
+	lc := checkConn(L)
+	L.Push(lua.LString(fmt.Sprintf("conn(%s)", lc.conn.RemoteAddr())))
+	return 1
+}
+
+func certificateTable(L *lua.LState, cert *x509.Certificate) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("subject", lua.LString(cert.Subject.String()))
+	t.RawSetString("issuer", lua.LString(cert.Issuer.String()))
+	t.RawSetString("serial", lua.LString(cert.SerialNumber.String()))
+	t.RawSetString("not_before", lua.LNumber(cert.NotBefore.Unix()))
+	t.RawSetString("not_after", lua.LNumber(cert.NotAfter.Unix()))
+
+	names := L.NewTable()
+	for _, name := range cert.DNSNames {
+		names.Append(lua.LString(name))
+	}
+	t.RawSetString("dns_names", names)
+	return t
+}
+
+// pushAddr pushes host and port of addr, falling back to its string form
+func pushAddr(L *lua.LState, addr net.Addr) int {
+	if addr == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		L.Push(lua.LString(addr.String()))
+		return 1
+	}
+
+	portNum, _ := strconv.Atoi(port)
+	L.Push(lua.LString(host))
+	L.Push(lua.LNumber(portNum))
+	return 2
+}
+
+// readErrorString maps read errors to the strings scripts compare against
+func readErrorString(err error) string {
+	if err == nil || err == io.EOF {
+		return "eof"
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return "timeout"
+	}
+	return err.Error()
+}
+
+// stringResult, numberResult and errorResult build the common async return shapes
+func stringResult(s string) asyncResult {
+	return func(L *lua.LState) []lua.LValue {
+		return []lua.LValue{lua.LString(s)}
+	}
+}
+
+func numberResult(n float64) asyncResult {
+	return func(L *lua.LState) []lua.LValue {
+		return []lua.LValue{lua.LNumber(n)}
+	}
+}
+
+func errorResult(msg string) asyncResult {
+	return func(L *lua.LState) []lua.LValue {
+		return []lua.LValue{lua.LNil, lua.LString(msg)}
+	}
+}
+
+func (lc *luaConn) applyReadDeadline() {
+	if lc.timeout > 0 {
+		_ = lc.conn.SetReadDeadline(time.Now().Add(lc.timeout))
+	}
+}
+
+func (lc *luaConn) close() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.closed {
+		return
+	}
+	lc.closed = true
+	if err := lc.conn.Close(); err != nil {
+		logger.Debug("Error closing script connection: %v", err)
+	}
+	if lc.release != nil {
+		lc.release()
+	}
+}
+
+func (lc *luaConn) isClosed() bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.closed
+}
@@ -0,0 +1,183 @@
+package scripting
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const (
+	hookOnConnect = "on_connect"
+	hookOnData    = "on_data"
+	hookOnClose   = "on_close"
+)
+
+// ConnHandler runs a Lua script against individual network connections.
+// The script is compiled once and executed in a fresh, sandboxed Lua state
+// for every connection so that handlers cannot leak state between peers.
+type ConnHandler struct {
+	scriptPath string
+	proto      *lua.FunctionProto
+	config     *EngineConfig
+}
+
+// NewConnHandler compiles the script at scriptPath for use as a connection handler
+func NewConnHandler(scriptPath string, config *EngineConfig) (*ConnHandler, error) {
+	if config == nil {
+		config = DefaultEngineConfig()
+	}
+	if err := ValidateLibraries(config.Libraries); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open script file: %w", err)
+	}
+	defer file.Close()
+
+	chunk, err := parse.Parse(bufio.NewReader(file), scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+
+	proto, err := lua.Compile(chunk, scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile script: %w", err)
+	}
+
+	return &ConnHandler{
+		scriptPath: scriptPath,
+		proto:      proto,
+		config:     config,
+	}, nil
+}
+
+// ScriptPath returns the path of the compiled handler script
+func (h *ConnHandler) ScriptPath() string {
+	return h.scriptPath
+}
+
+// Handle runs the handler script for a single connection.
+//
+// The script's top-level chunk is executed first, then the optional hooks are
+// invoked: on_connect(conn) once, on_data(conn, data) for every chunk read from
+// the peer, and on_close(conn, reason) when the connection ends. When on_data
+// is not defined the script is expected to drive I/O itself from on_connect.
+// Every hook call runs under its own MaxExecutionTime budget, which counts
+// the time spent running Lua but not time blocked on the connection, and
+// tasks spawned by a hook finish before the next hook is invoked.
+// The connection is closed when Handle returns.
+func (h *ConnHandler) Handle(conn net.Conn) error {
+	L, err := newRestrictedState(h.config.Libraries, h.config.Capabilities)
+	if err != nil {
+		return err
+	}
+	engine := newEngine(L, h.config)
+	defer engine.Close()
+
+	lc := newLuaConn(conn)
+	defer lc.close()
+
+	ctx, cancel := engine.withBudget(context.Background())
+	L.Push(L.NewFunctionFromProto(h.proto))
+	err = L.PCall(0, lua.MultRet, nil)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to run handler script: %w", engine.budgetError(ctx, err))
+	}
+	L.SetTop(0)
+
+	ud := newConnValue(L, lc)
+
+	closeReason := "eof"
+
+	if err := engine.callHook(hookOnConnect, ud); err != nil {
+		closeReason = err.Error()
+		logger.Error("[Script] %s: %v", hookOnConnect, err)
+	} else if onData := L.GetGlobal(hookOnData); onData.Type() == lua.LTFunction {
+		buffer := make([]byte, defaultReadChunk)
+		for !lc.isClosed() {
+			n, err := lc.reader.Read(buffer)
+			if n > 0 {
+				keepOpen, hookErr := engine.callDataHook(onData, ud, string(buffer[:n]))
+				if hookErr != nil {
+					closeReason = hookErr.Error()
+					logger.Error("[Script] %s: %v", hookOnData, hookErr)
+					break
+				}
+				if !keepOpen {
+					closeReason = "closed by script"
+					break
+				}
+			}
+			if err != nil {
+				if err != io.EOF && !lc.isClosed() {
+					closeReason = err.Error()
+				}
+				break
+			}
+		}
+	}
+
+	if lc.isClosed() && closeReason == "eof" {
+		closeReason = "closed by script"
+	}
+
+	if err := engine.callHook(hookOnClose, ud, lua.LString(closeReason)); err != nil {
+		logger.Error("[Script] %s: %v", hookOnClose, err)
+	}
+
+	return nil
+}
+
+// callHook invokes a global hook function if the script defines it
+func (e *LuaEngine) callHook(name string, args ...lua.LValue) error {
+	fn := e.L.GetGlobal(name)
+	if fn.Type() != lua.LTFunction {
+		return nil
+	}
+
+	ctx, cancel := e.withBudget(context.Background())
+	defer cancel()
+	defer e.L.SetTop(0)
+	if err := e.L.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    0,
+		Protect: true,
+	}, args...); err != nil {
+		return e.budgetError(ctx, err)
+	}
+	if err := e.drainTasks(ctx); err != nil {
+		return e.budgetError(ctx, err)
+	}
+	return nil
+}
+
+// callDataHook invokes on_data and reports whether the connection should stay open.
+// Returning false from on_data closes the connection.
+func (e *LuaEngine) callDataHook(fn lua.LValue, ud *lua.LUserData, data string) (bool, error) {
+	ctx, cancel := e.withBudget(context.Background())
+	defer cancel()
+	defer e.L.SetTop(0)
+	if err := e.L.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    1,
+		Protect: true,
+	}, ud, lua.LString(data)); err != nil {
+		return false, e.budgetError(ctx, err)
+	}
+
+	ret := e.L.Get(-1)
+	if err := e.drainTasks(ctx); err != nil {
+		return false, e.budgetError(ctx, err)
+	}
+	return ret != lua.LFalse, nil
+}
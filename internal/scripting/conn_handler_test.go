@@ -0,0 +1,202 @@
+package scripting
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScript(t *testing.T, source string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "handler.lua")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	return path
+}
+
+// runHandler runs the handler against one side of a pipe and returns the other side
+func runHandler(t *testing.T, handler *ConnHandler) (net.Conn, <-chan error) {
+	t.Helper()
+
+	server, client := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.Handle(server)
+	}()
+
+	if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("Failed to set deadline: %v", err)
+	}
+	return client, done
+}
+
+func TestConnHandlerHooks(t *testing.T) {
+	path := writeScript(t, `
+function on_connect(conn)
+    conn:write("hello\n")
+end
+
+function on_data(conn, data)
+    if data == "quit\n" then
+        return false
+    end
+    conn:write(string.upper(data))
+end
+`)
+
+	handler, err := NewConnHandler(path, nil)
+	if err != nil {
+		t.Fatalf("NewConnHandler failed: %v", err)
+	}
+
+	client, done := runHandler(t, handler)
+	defer client.Close()
+	reader := bufio.NewReader(client)
+
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "hello\n" {
+		t.Fatalf("Expected greeting, got %q (%v)", line, err)
+	}
+
+	if _, err := client.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line, err = reader.ReadString('\n')
+	if err != nil || line != "PING\n" {
+		t.Fatalf("Expected echo, got %q (%v)", line, err)
+	}
+
+	if _, err := client.Write([]byte("quit\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Handle returned error: %v", err)
+	}
+}
+
+func TestConnHandlerReadLine(t *testing.T) {
+	path := writeScript(t, `
+function on_connect(conn)
+    local line = conn:readline()
+    conn:write("got " .. line .. "\n")
+    conn:close()
+end
+`)
+
+	handler, err := NewConnHandler(path, nil)
+	if err != nil {
+		t.Fatalf("NewConnHandler failed: %v", err)
+	}
+
+	client, done := runHandler(t, handler)
+	defer client.Close()
+
+	if _, err := client.Write([]byte("abc\r\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil || line != "got abc\n" {
+		t.Fatalf("Expected readline result, got %q (%v)", line, err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Handle returned error: %v", err)
+	}
+}
+
+func TestConnHandlerBudgetExcludesIO(t *testing.T) {
+	path := writeScript(t, `
+function on_connect(conn)
+    while true do
+        local line = conn:readline()
+        if line == nil or line == "quit" then
+            return
+        end
+        conn:write(line .. "\n")
+    end
+end
+`)
+
+	// The conversation lasts several budgets, but runs Lua for much less
+	config := DefaultEngineConfig()
+	config.MaxExecutionTime = 100 * time.Millisecond
+	handler, err := NewConnHandler(path, config)
+	if err != nil {
+		t.Fatalf("NewConnHandler failed: %v", err)
+	}
+
+	client, done := runHandler(t, handler)
+	defer client.Close()
+	reader := bufio.NewReader(client)
+	for i := 0; i < 5; i++ {
+		time.Sleep(60 * time.Millisecond)
+		if _, err := client.Write([]byte("ping\n")); err != nil {
+			t.Fatalf("Write %d failed: %v", i, err)
+		}
+		if line, err := reader.ReadString('\n'); err != nil || line != "ping\n" {
+			t.Fatalf("Reply %d = %q (%v)", i, line, err)
+		}
+	}
+	client.Write([]byte("quit\n"))
+	if err := <-done; err != nil {
+		t.Errorf("Handle returned error: %v", err)
+	}
+}
+
+func TestConnHandlerSandbox(t *testing.T) {
+	path := writeScript(t, `
+function on_connect(conn)
+    local libs = {}
+    for _, name in ipairs({"package", "debug", "dofile", "loadfile"}) do
+        if _G[name] ~= nil then
+            table.insert(libs, name)
+        end
+    end
+    for _, name in ipairs({"execute", "remove", "rename", "getenv", "exit"}) do
+        if os[name] ~= nil then
+            table.insert(libs, "os." .. name)
+        end
+    end
+    for _, name in ipairs({"open", "lines", "popen"}) do
+        if io[name] ~= nil then
+            table.insert(libs, "io." .. name)
+        end
+    end
+    conn:write(table.concat(libs, ",") .. "\n")
+    conn:close()
+end
+`)
+
+	handler, err := NewConnHandler(path, nil)
+	if err != nil {
+		t.Fatalf("NewConnHandler failed: %v", err)
+	}
+
+	client, done := runHandler(t, handler)
+	defer client.Close()
+
+	line, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if exposed := strings.TrimSpace(line); exposed != "" {
+		t.Errorf("Sandbox exposes unsafe globals: %s", exposed)
+	}
+	<-done
+}
+
+func TestConnHandlerCompileError(t *testing.T) {
+	path := writeScript(t, "function on_connect(conn")
+
+	if _, err := NewConnHandler(path, nil); err == nil {
+		t.Error("Expected error for invalid script")
+	}
+	if _, err := NewConnHandler(filepath.Join(t.TempDir(), "missing.lua"), nil); err == nil {
+		t.Error("Expected error for missing script")
+	}
+}
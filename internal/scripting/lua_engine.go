@@ -1,16 +1,23 @@
 package scripting
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 	"encoding/hex"
 	"encoding/base64"
 	"crypto/tls"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/network"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -19,46 +26,106 @@ const (
 	defaultMaxExecutionTime = 30 * time.Second
 	defaultMaxMemory        = 64 * 1024 * 1024 // 64MB
 	defaultConnectionTimeout = 10 * time.Second
-	maxReceiveBufferSize    = 2048
-	gocatVersion           = "dev"
-	gocatPlatform          = "linux/amd64"
+	maxReceiveBufferSize    = 64 * 1024
 )
 
+// gocatVersion is reported to scripts as gocat.version; set from the build info
+var gocatVersion = "dev"
+
+// SetVersion sets the GoCat version reported to scripts
+func SetVersion(version string) {
+	if version != "" {
+		gocatVersion = version
+	}
+}
+
 // LuaEngine represents a Lua scripting engine for GoCat
 type LuaEngine struct {
 	L        *lua.LState
 	config   *EngineConfig
 	loadedScripts map[string]bool
+	sched         *scheduler
+	taskCounter   int
+	trampoline    *lua.LFunction
+	regexCache    map[string]*regexp.Regexp
+	modules       map[string]lua.LValue
+	// ownUpstreams pools the connections of gocat.pool when the
+	// configuration brings no upstreams
+	ownUpstreams *network.Upstreams
+	// budget of the running call; nil without MaxExecutionTime
+	budget *budget
+	// resources maps the sockets scripts opened to their close functions;
+	// Close closes those still open
+	resMu     sync.Mutex
+	resources map[interface{}]func()
+	resClosed bool
 }
 
 // EngineConfig holds configuration for the Lua engine
 type EngineConfig struct {
+	// MaxExecutionTime bounds the time a single script run or function call
+	// spends running Lua, not waiting on I/O; zero disables the budget
 	MaxExecutionTime time.Duration
 	MaxMemory        int64
 	RestrictedMode   bool
 	AllowedHosts     []string
 	DeniedHosts      []string
+	// Libraries lists the standard libraries to open; nil opens DefaultLibraries
+	Libraries []string
+	// Capabilities granted to the script; nil grants none
+	Capabilities *CapabilitySet
+	// ModulePaths are the directories require() searches, in order
+	ModulePaths []string
+	// Packages maps required package names to their entry scripts
+	Packages map[string]string
+	// Args holds the typed script arguments exposed as the global args table
+	Args map[string]interface{}
+	// Resolver answers gocat.dns and the host lookups of connections; nil
+	// uses resolver.Default()
+	Resolver DNSResolver
+	// Upstreams hands out the connections of gocat.pool; nil gives the
+	// engine a pool of its own
+	Upstreams *network.Upstreams
+}
+
+// DefaultEngineConfig returns the default engine configuration
+func DefaultEngineConfig() *EngineConfig {
+	return &EngineConfig{
+		MaxExecutionTime: defaultMaxExecutionTime,
+		MaxMemory:        defaultMaxMemory,
+		RestrictedMode:   false,
+	}
 }
 
-// NewLuaEngine creates a new Lua engine with optional configuration
+// NewLuaEngine creates a new Lua engine with optional configuration.
+// It returns nil if the configured libraries are invalid.
 func NewLuaEngine(config *EngineConfig) *LuaEngine {
 	if config == nil {
-		config = &EngineConfig{
-			MaxExecutionTime: defaultMaxExecutionTime,
-			MaxMemory:        defaultMaxMemory,
-			RestrictedMode:   false,
-		}
+		config = DefaultEngineConfig()
+	}
+
+	L, err := newRestrictedState(config.Libraries, config.Capabilities)
+	if err != nil {
+		logger.Error("Failed to create Lua state: %v", err)
+		return nil
 	}
 
-	L := lua.NewState()
-	
+	return newEngine(L, config)
+}
+
+// newEngine wraps an already opened Lua state and registers the GoCat API on it
+func newEngine(L *lua.LState, config *EngineConfig) *LuaEngine {
 	// Configure Lua state limits
 	L.SetMx(int(config.MaxMemory))
-	
+
 	engine := &LuaEngine{
 		L:             L,
 		config:        config,
 		loadedScripts: make(map[string]bool),
+		sched:         newScheduler(),
+		regexCache:    make(map[string]*regexp.Regexp),
+		modules:       make(map[string]lua.LValue),
+		resources:     make(map[interface{}]func()),
 	}
 
 	// Register GoCat API functions
@@ -69,14 +136,63 @@ func NewLuaEngine(config *EngineConfig) *LuaEngine {
 
 // Close closes the Lua engine and releases resources
 func (e *LuaEngine) Close() {
+	e.sched.close()
+
+	e.resMu.Lock()
+	resources := e.resources
+	e.resources = nil
+	e.resClosed = true
+	e.resMu.Unlock()
+	for _, closeFn := range resources {
+		closeFn()
+	}
+
+	if e.ownUpstreams != nil {
+		e.ownUpstreams.Close()
+		e.ownUpstreams = nil
+	}
 	if e.L != nil {
 		e.L.Close()
 		e.L = nil
 	}
 }
 
+// track registers a socket opened by a script so Close closes it. It may
+// be called from I/O goroutines; once the engine is closed the socket is
+// closed right away, as no script will ever see it.
+func (e *LuaEngine) track(key interface{}, closeFn func()) {
+	e.resMu.Lock()
+	if !e.resClosed {
+		e.resources[key] = closeFn
+		e.resMu.Unlock()
+		return
+	}
+	e.resMu.Unlock()
+	closeFn()
+}
+
+// untrack forgets a socket the script closed itself
+func (e *LuaEngine) untrack(key interface{}) {
+	e.resMu.Lock()
+	delete(e.resources, key)
+	e.resMu.Unlock()
+}
+
+// trackConn registers lc, which leaves the set when the script closes it
+func (e *LuaEngine) trackConn(lc *luaConn) *luaConn {
+	lc.release = func() { e.untrack(lc) }
+	e.track(lc, lc.close)
+	return lc
+}
+
 // LoadScript loads a Lua script from file
 func (e *LuaEngine) LoadScript(scriptPath string) error {
+	return e.LoadScriptWithContext(context.Background(), scriptPath)
+}
+
+// LoadScriptWithContext loads and runs a Lua script from file. The script is
+// aborted when ctx is cancelled or the MaxExecutionTime budget is exhausted.
+func (e *LuaEngine) LoadScriptWithContext(ctx context.Context, scriptPath string) error {
 	if e.L == nil {
 		return fmt.Errorf("lua engine is closed")
 	}
@@ -94,8 +210,15 @@ func (e *LuaEngine) LoadScript(scriptPath string) error {
 	}
 
 	// Compile and load script
+	ctx, cancel := e.withBudget(ctx)
+	defer cancel()
 	if err := e.L.DoString(string(content)); err != nil {
-		return fmt.Errorf("failed to load script: %w", err)
+		return fmt.Errorf("failed to load script: %w", e.budgetError(ctx, err))
+	}
+
+	// Run tasks the script spawned but did not wait for
+	if err := e.drainTasks(ctx); err != nil {
+		return fmt.Errorf("failed to run script tasks: %w", e.budgetError(ctx, err))
 	}
 
 	e.loadedScripts[scriptPath] = true
@@ -127,6 +250,8 @@ func (e *LuaEngine) ExecuteFunction(functionName string, args ...lua.LValue) ([]
 	}
 
 	// Call function with arguments
+	ctx, cancel := e.withBudget(context.Background())
+	defer cancel()
 	err := e.L.CallByParam(lua.P{
 		Fn:      fn,
 		NRet:    lua.MultRet,
@@ -134,7 +259,7 @@ func (e *LuaEngine) ExecuteFunction(functionName string, args ...lua.LValue) ([]
 	}, args...)
 
 	if err != nil {
-		return nil, fmt.Errorf("error executing function '%s': %w", functionName, err)
+		return nil, fmt.Errorf("error executing function '%s': %w", functionName, e.budgetError(ctx, err))
 	}
 
 	// Collect return values
@@ -148,6 +273,48 @@ func (e *LuaEngine) ExecuteFunction(functionName string, args ...lua.LValue) ([]
 	return results, nil
 }
 
+// withBudget attaches ctx, bounded by MaxExecutionTime of running Lua, to
+// the Lua state. The VM checks the context before every instruction, so
+// runaway loops are interrupted as well as blocking API calls that honour it.
+func (e *LuaEngine) withBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc
+	outer := e.budget
+	if e.config.MaxExecutionTime > 0 {
+		var b *budget
+		ctx, b = newBudget(ctx, e.config.MaxExecutionTime)
+		e.budget, cancel = b, b.stop
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+		e.budget = nil
+	}
+
+	e.L.SetContext(ctx)
+	return ctx, func() {
+		e.L.RemoveContext()
+		e.budget = outer
+		cancel()
+	}
+}
+
+// budgetError replaces the raw VM error when the script was stopped by its context
+func (e *LuaEngine) budgetError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(context.Cause(ctx), context.DeadlineExceeded):
+		return fmt.Errorf("script exceeded execution time limit of %v", e.config.MaxExecutionTime)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("script cancelled")
+	}
+	return err
+}
+
+// scriptContext returns the context of the running script
+func scriptContext(L *lua.LState) context.Context {
+	if ctx := L.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
 // registerAPI registers GoCat's Lua API functions
 func (e *LuaEngine) registerAPI() {
 	// Network functions
@@ -168,9 +335,18 @@ func (e *LuaEngine) registerAPI() {
 	// GoCat environment info
 	gocatTable := e.L.NewTable()
 	gocatTable.RawSetString("version", lua.LString(gocatVersion))
-	gocatTable.RawSetString("platform", lua.LString(gocatPlatform))
+	gocatTable.RawSetString("platform", lua.LString(runtime.GOOS+"/"+runtime.GOARCH))
 	e.L.SetGlobal("gocat", gocatTable)
 
+	// Connection objects, coroutine scheduler and gocat.* modules
+	e.registerConnType()
+	e.registerScheduler(gocatTable)
+	e.registerStdlib(gocatTable)
+
+	// Script modules and arguments
+	e.L.SetGlobal("require", e.L.NewFunction(e.luaRequire))
+	e.L.SetGlobal("args", goToLua(e.L, e.config.Args))
+
 	logger.Debug("Registered GoCat Lua API functions")
 }
 
@@ -194,39 +370,47 @@ func (e *LuaEngine) luaConnect(L *lua.LState) int {
 		L.Push(lua.LString("host not allowed in restricted mode"))
 		return 2
 	}
-
-	// Use net.JoinHostPort for proper IPv6 support
-	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-
-	var conn net.Conn
-	var err error
-
-	switch strings.ToLower(protocol) {
-	case "tcp", "":
-		conn, err = net.DialTimeout("tcp", address, defaultConnectionTimeout)
-	case "udp":
-		conn, err = net.DialTimeout("udp", address, defaultConnectionTimeout)
-	case "ssl", "tls":
-		config := &tls.Config{InsecureSkipVerify: true} // #nosec G402 - Required for script flexibility
-		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: defaultConnectionTimeout}, "tcp", address, config)
-	default:
+	if !e.config.Capabilities.AllowNet(host, port) {
 		L.Push(lua.LNil)
-		L.Push(lua.LString("unsupported protocol: " + protocol))
+		L.Push(lua.LString(fmt.Sprintf("capability net:%s:%d not granted", host, port)))
 		return 2
 	}
 
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
+	// Use net.JoinHostPort for proper IPv6 support
+	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
 
-	// Store connection in userdata
-	userData := L.NewUserData()
-	userData.Value = conn
-	L.Push(userData)
-	L.Push(lua.LNil)
-	return 2
+	ctx := scriptContext(L)
+	return e.async(L, func() asyncResult {
+		dialCtx, cancel := context.WithTimeout(ctx, defaultConnectionTimeout)
+		defer cancel()
+
+		var conn net.Conn
+		var err error
+		switch strings.ToLower(protocol) {
+		case "tcp", "":
+			conn, err = e.dialContext(dialCtx, "tcp", address)
+		case "udp":
+			conn, err = e.dialContext(dialCtx, "udp", address)
+		case "ssl", "tls":
+			config := &tls.Config{InsecureSkipVerify: true} // #nosec G402 - Required for script flexibility
+			conn, err = e.dialTLS(dialCtx, address, config)
+		default:
+			return errorResult("unsupported protocol: " + protocol)
+		}
+
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		// Tracked here, so a result no task picks up is still closed
+		lc := newLuaConn(conn)
+		lc.datagram = strings.EqualFold(protocol, "udp")
+		e.trackConn(lc)
+
+		// Connections are conn objects, so both send(conn, data) and conn:write(data) work
+		return func(L *lua.LState) []lua.LValue {
+			return []lua.LValue{newConnValue(L, lc), lua.LNil}
+		}
+	})
 }
 
 // luaListen implements listen(port, protocol) function
@@ -240,6 +424,12 @@ func (e *LuaEngine) luaListen(L *lua.LState) int {
 		return 2
 	}
 
+	if !e.config.Capabilities.AllowNet("*", port) {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("capability net:*:%d not granted", port)))
+		return 2
+	}
+
 	address := fmt.Sprintf(":%d", port)
 
 	var listener net.Listener
@@ -262,6 +452,7 @@ func (e *LuaEngine) luaListen(L *lua.LState) int {
 			L.Push(lua.LString(udpErr.Error()))
 			return 2
 		}
+		e.track(conn, func() { conn.Close() })
 		userData := L.NewUserData()
 		userData.Value = conn
 		L.Push(userData)
@@ -279,6 +470,8 @@ func (e *LuaEngine) luaListen(L *lua.LState) int {
 		return 2
 	}
 
+	e.track(listener, func() { listener.Close() })
+
 	// Store listener in userdata
 	userData := L.NewUserData()
 	userData.Value = listener
@@ -298,23 +491,24 @@ func (e *LuaEngine) luaSend(L *lua.LState) int {
 		return 2
 	}
 
-	conn, ok := userData.Value.(net.Conn)
+	conn, ok := userDataConn(userData)
 	if !ok {
 		L.Push(lua.LNumber(0))
 		L.Push(lua.LString("invalid connection type"))
 		return 2
 	}
 
-	n, err := conn.Write([]byte(data))
-	if err != nil {
-		L.Push(lua.LNumber(0))
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
-
-	L.Push(lua.LNumber(n))
-	L.Push(lua.LNil)
-	return 2
+	return e.async(L, func() asyncResult {
+		n, err := conn.Write([]byte(data))
+		if err != nil {
+			return func(L *lua.LState) []lua.LValue {
+				return []lua.LValue{lua.LNumber(0), lua.LString(err.Error())}
+			}
+		}
+		return func(L *lua.LState) []lua.LValue {
+			return []lua.LValue{lua.LNumber(n), lua.LNil}
+		}
+	})
 }
 
 // luaReceive implements receive(conn, size) function
@@ -328,28 +522,43 @@ func (e *LuaEngine) luaReceive(L *lua.LState) int {
 		return 2
 	}
 
-	conn, ok := userData.Value.(net.Conn)
+	conn, ok := userDataConn(userData)
 	if !ok {
 		L.Push(lua.LString(""))
 		L.Push(lua.LString("invalid connection type"))
 		return 2
 	}
 
-	if size <= 0 {
+	if size <= 0 || size > maxReceiveBufferSize {
 		size = maxReceiveBufferSize
 	}
 
-	buffer := make([]byte, size)
-	n, err := conn.Read(buffer)
-	if err != nil {
-		L.Push(lua.LString(""))
-		L.Push(lua.LString(err.Error()))
-		return 2
+	var source io.Reader = conn
+	if lc, ok := userData.Value.(*luaConn); ok {
+		source = lc
 	}
 
-	L.Push(lua.LString(string(buffer[:n])))
-	L.Push(lua.LNil)
-	return 2
+	return e.async(L, func() asyncResult {
+		buffer := make([]byte, size)
+		n, err := source.Read(buffer)
+		return func(L *lua.LState) []lua.LValue {
+			if err != nil {
+				return []lua.LValue{lua.LString(""), lua.LString(err.Error())}
+			}
+			return []lua.LValue{lua.LString(string(buffer[:n])), lua.LNil}
+		}
+	})
+}
+
+// userDataConn returns the network connection held by a connection userdata
+func userDataConn(userData *lua.LUserData) (net.Conn, bool) {
+	switch v := userData.Value.(type) {
+	case net.Conn:
+		return v, true
+	case *luaConn:
+		return v.conn, true
+	}
+	return nil, false
 }
 
 // luaClose implements close(conn) function
@@ -361,14 +570,22 @@ func (e *LuaEngine) luaClose(L *lua.LState) int {
 		return 1
 	}
 
+	if lc, ok := userData.Value.(*luaConn); ok {
+		lc.close()
+		L.Push(lua.LBool(true))
+		return 1
+	}
+
 	if conn, ok := userData.Value.(net.Conn); ok {
 		conn.Close()
+		e.untrack(conn)
 		L.Push(lua.LBool(true))
 		return 1
 	}
 
 	if listener, ok := userData.Value.(net.Listener); ok {
 		listener.Close()
+		e.untrack(listener)
 		L.Push(lua.LBool(true))
 		return 1
 	}
@@ -398,13 +615,24 @@ func (e *LuaEngine) luaLog(L *lua.LState) int {
 	return 0
 }
 
-// luaSleep implements sleep(seconds) function
+// luaSleep implements sleep(seconds) and gocat.timer.sleep(seconds); inside a
+// spawned task only the task sleeps
 func (e *LuaEngine) luaSleep(L *lua.LState) int {
-	seconds := L.ToNumber(1)
-	if seconds > 0 {
-		time.Sleep(time.Duration(float64(seconds) * float64(time.Second)))
-	}
-	return 0
+	duration := parseSeconds(L.ToNumber(1))
+	if duration <= 0 {
+		return 0
+	}
+
+	ctx := scriptContext(L)
+	return e.async(L, func() asyncResult {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		return func(L *lua.LState) []lua.LValue { return nil }
+	})
 }
 
 // luaHexEncode implements hex_encode(data) function
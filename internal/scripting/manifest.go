@@ -0,0 +1,535 @@
+package scripting
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the manifest of a script package directory
+const ManifestFile = "script.yaml"
+
+// Header keys recognised in the leading comment block of single-file scripts:
+//
+//	-- Name: smtp-probe
+//	-- Version: 1.2.0
+//	-- Author: Jane Doe
+//	-- Description: Checks SMTP servers for STARTTLS
+//	-- Arg: target:host! Host to probe
+//	-- Arg: ports:ports=25,587 Ports to check
+//	-- Require: gocat-utils >=1.0
+//	-- Capability: net
+const (
+	headerName        = "Name:"
+	headerVersion     = "Version:"
+	headerAuthor      = "Author:"
+	headerDescription = "Description:"
+	headerPurpose     = "Purpose:"
+	headerArg         = "Arg:"
+	headerRequire     = "Require:"
+)
+
+// Argument types accepted in a manifest's args schema
+const (
+	ArgString   = "string"
+	ArgInt      = "int"
+	ArgNumber   = "number"
+	ArgBool     = "bool"
+	ArgPort     = "port"
+	ArgPorts    = "ports"
+	ArgHost     = "host"
+	ArgDuration = "duration"
+	ArgList     = "list"
+)
+
+var (
+	argTypes = map[string]bool{
+		ArgString: true, ArgInt: true, ArgNumber: true, ArgBool: true, ArgPort: true,
+		ArgPorts: true, ArgHost: true, ArgDuration: true, ArgList: true,
+	}
+
+	// reservedArgNames are flags of "script run" itself
+	reservedArgNames = map[string]bool{"timeout": true, "libs": true, "args": true, "verbose": true, "help": true}
+
+	scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	argNamePattern    = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	versionPattern    = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:[-+].*)?$`)
+	hostnamePattern   = regexp.MustCompile(`^(?i:[a-z0-9_](?:[a-z0-9_-]{0,61}[a-z0-9])?)(?:\.(?i:[a-z0-9_](?:[a-z0-9_-]{0,61}[a-z0-9])?))*\.?$`)
+)
+
+// maxPortsArg bounds the number of ports a ports argument may expand to
+const maxPortsArg = 65535
+
+// Manifest describes a script: its identity, the capabilities it needs, the
+// scripts it requires and the typed arguments it accepts
+type Manifest struct {
+	Name         string       `yaml:"name" json:"name"`
+	Version      string       `yaml:"version" json:"version"`
+	Description  string       `yaml:"description,omitempty" json:"description,omitempty"`
+	Author       string       `yaml:"author,omitempty" json:"author,omitempty"`
+	Main         string       `yaml:"main,omitempty" json:"main,omitempty"`
+	Capabilities []string     `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+	Requires     []Dependency `yaml:"requires,omitempty" json:"requires,omitempty"`
+	Args         []ArgSpec    `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+// Dependency names a required script package and an optional version constraint
+type Dependency struct {
+	Name    string `yaml:"name" json:"name"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+}
+
+// ArgSpec declares one typed script argument
+type ArgSpec struct {
+	Name        string   `yaml:"name" json:"name"`
+	Type        string   `yaml:"type,omitempty" json:"type"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool     `yaml:"required,omitempty" json:"required,omitempty"`
+	Default     string   `yaml:"default,omitempty" json:"default,omitempty"`
+	Choices     []string `yaml:"choices,omitempty" json:"choices,omitempty"`
+}
+
+// UnmarshalYAML accepts a dependency either as a mapping or as "name constraint"
+func (d *Dependency) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		parsed, err := ParseDependency(node.Value)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	}
+
+	type plain Dependency
+	return node.Decode((*plain)(d))
+}
+
+// String returns the dependency in "name constraint" form
+func (d Dependency) String() string {
+	if d.Version == "" {
+		return d.Name
+	}
+	return d.Name + " " + d.Version
+}
+
+// ParseDependency parses "name", "name >=1.0" or "name>=1.0,<2"
+func ParseDependency(spec string) (Dependency, error) {
+	spec = strings.TrimSpace(spec)
+	idx := strings.IndexAny(spec, " <>=^~")
+	if idx < 0 {
+		idx = len(spec)
+	}
+
+	d := Dependency{Name: spec[:idx], Version: strings.TrimSpace(spec[idx:])}
+	if !scriptNamePattern.MatchString(d.Name) {
+		return Dependency{}, fmt.Errorf("invalid dependency %q", spec)
+	}
+	if _, err := VersionSatisfies("0.0.0", d.Version); err != nil {
+		return Dependency{}, fmt.Errorf("invalid dependency %q: %w", spec, err)
+	}
+	return d, nil
+}
+
+// LoadManifest reads and validates a script.yaml manifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// ParseHeaderManifest builds a manifest from the leading comment block of a
+// single-file script. The name defaults to the file name; a script without a
+// Description header is described by its first comment line.
+func ParseHeaderManifest(content, fileName string) (*Manifest, error) {
+	m := &Manifest{Name: strings.TrimSuffix(filepath.Base(fileName), ".lua")}
+	firstComment := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") || strings.HasPrefix(line, "--[[") {
+			break
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		key, value, found := strings.Cut(comment, ":")
+		value = strings.TrimSpace(value)
+		if !found {
+			if firstComment == "" {
+				firstComment = comment
+			}
+			continue
+		}
+
+		switch key + ":" {
+		case headerName:
+			m.Name = value
+		case headerVersion:
+			m.Version = value
+		case headerAuthor:
+			m.Author = value
+		case headerDescription, headerPurpose:
+			if m.Description == "" {
+				m.Description = value
+			}
+		case capabilityHeader:
+			m.Capabilities = append(m.Capabilities, value)
+		case headerRequire:
+			d, err := ParseDependency(value)
+			if err != nil {
+				return nil, err
+			}
+			m.Requires = append(m.Requires, d)
+		case headerArg:
+			arg, err := parseHeaderArg(value)
+			if err != nil {
+				return nil, err
+			}
+			m.Args = append(m.Args, arg)
+		default:
+			if firstComment == "" {
+				firstComment = comment
+			}
+		}
+	}
+
+	if m.Description == "" {
+		m.Description = firstComment
+	}
+	if m.Version == "" {
+		m.Version = "0.0.0"
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid script header in %s: %w", fileName, err)
+	}
+	return m, nil
+}
+
+// parseHeaderArg parses "name:type[=default][!] description"; "!" marks the
+// argument as required
+func parseHeaderArg(value string) (ArgSpec, error) {
+	spec, description, _ := strings.Cut(value, " ")
+	arg := ArgSpec{Description: strings.TrimSpace(description)}
+
+	if strings.HasSuffix(spec, "!") {
+		arg.Required = true
+		spec = strings.TrimSuffix(spec, "!")
+	}
+	spec, arg.Default, _ = strings.Cut(spec, "=")
+	arg.Name, arg.Type, _ = strings.Cut(spec, ":")
+	if arg.Name == "" {
+		return ArgSpec{}, fmt.Errorf("invalid argument declaration %q", value)
+	}
+	return arg, nil
+}
+
+// Validate checks the manifest fields and normalises argument types
+func (m *Manifest) Validate() error {
+	if !scriptNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid script name %q", m.Name)
+	}
+	if m.Version == "" {
+		return fmt.Errorf("version is required")
+	}
+	if _, err := parseVersion(m.Version); err != nil {
+		return err
+	}
+	if m.Main != "" && (filepath.IsAbs(m.Main) || strings.HasPrefix(filepath.Clean(m.Main), "..")) {
+		return fmt.Errorf("main must be a path inside the package: %s", m.Main)
+	}
+	for _, c := range m.Capabilities {
+		if _, err := ParseCapability(c); err != nil {
+			return err
+		}
+	}
+	for _, d := range m.Requires {
+		if !scriptNamePattern.MatchString(d.Name) {
+			return fmt.Errorf("invalid dependency name %q", d.Name)
+		}
+		if _, err := VersionSatisfies("0.0.0", d.Version); err != nil {
+			return fmt.Errorf("dependency %s: %w", d.Name, err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range m.Args {
+		arg := &m.Args[i]
+		if arg.Type == "" {
+			arg.Type = ArgString
+		}
+		arg.Type = strings.ToLower(arg.Type)
+
+		switch {
+		case !argNamePattern.MatchString(arg.Name):
+			return fmt.Errorf("invalid argument name %q", arg.Name)
+		case reservedArgNames[arg.Name]:
+			return fmt.Errorf("argument name %q is reserved", arg.Name)
+		case seen[arg.Name]:
+			return fmt.Errorf("duplicate argument %q", arg.Name)
+		case !argTypes[arg.Type]:
+			return fmt.Errorf("argument %s: unknown type %q", arg.Name, arg.Type)
+		case len(arg.Choices) > 0 && arg.Type != ArgString:
+			return fmt.Errorf("argument %s: choices are only supported for string arguments", arg.Name)
+		}
+		seen[arg.Name] = true
+
+		if arg.Default != "" {
+			if _, err := arg.Parse(arg.Default); err != nil {
+				return fmt.Errorf("argument %s: invalid default: %w", arg.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DeclaredCapabilities parses the capabilities listed in the manifest
+func (m *Manifest) DeclaredCapabilities() ([]Capability, error) {
+	caps := make([]Capability, 0, len(m.Capabilities))
+	for _, spec := range m.Capabilities {
+		c, err := ParseCapability(spec)
+		if err != nil {
+			return nil, err
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
+
+// ResolveArgs validates raw argument values against the schema, applies
+// defaults and returns the typed values keyed by argument name
+func (m *Manifest) ResolveArgs(values map[string]string) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{})
+
+	for _, arg := range m.Args {
+		raw, ok := values[arg.Name]
+		if !ok {
+			if arg.Required {
+				return nil, fmt.Errorf("missing required argument --%s", arg.Name)
+			}
+			if arg.Default == "" {
+				continue
+			}
+			raw = arg.Default
+		}
+
+		value, err := arg.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for --%s: %w", arg.Name, err)
+		}
+		resolved[arg.Name] = value
+	}
+
+	for name := range values {
+		if _, ok := m.arg(name); !ok {
+			return nil, fmt.Errorf("unknown argument --%s", name)
+		}
+	}
+	return resolved, nil
+}
+
+func (m *Manifest) arg(name string) (ArgSpec, bool) {
+	for _, arg := range m.Args {
+		if arg.Name == name {
+			return arg, true
+		}
+	}
+	return ArgSpec{}, false
+}
+
+// Parse converts a raw value according to the argument type. Values are
+// returned as the types gocat.json uses: float64, bool, string and lists.
+func (a ArgSpec) Parse(raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+
+	switch a.Type {
+	case ArgString, "":
+		if len(a.Choices) > 0 {
+			for _, choice := range a.Choices {
+				if raw == choice {
+					return raw, nil
+				}
+			}
+			return nil, fmt.Errorf("%q is not one of %s", raw, strings.Join(a.Choices, ", "))
+		}
+		return raw, nil
+	case ArgInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return float64(n), nil
+	case ArgNumber:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return f, nil
+	case ArgBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return b, nil
+	case ArgPort:
+		port, err := strconv.Atoi(raw)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("%q is not a valid port", raw)
+		}
+		return float64(port), nil
+	case ArgPorts:
+		ranges, err := parsePortRanges(raw)
+		if err != nil {
+			return nil, err
+		}
+		var ports []interface{}
+		for _, r := range ranges {
+			if r.from < 1 {
+				return nil, fmt.Errorf("invalid port range %q", raw)
+			}
+			for p := r.from; p <= r.to; p++ {
+				if len(ports) >= maxPortsArg {
+					return nil, fmt.Errorf("too many ports in %q", raw)
+				}
+				ports = append(ports, float64(p))
+			}
+		}
+		return ports, nil
+	case ArgHost:
+		if net.ParseIP(strings.Trim(raw, "[]")) == nil && !hostnamePattern.MatchString(raw) {
+			return nil, fmt.Errorf("%q is not a valid host", raw)
+		}
+		return strings.Trim(raw, "[]"), nil
+	case ArgDuration:
+		if seconds, err := strconv.ParseFloat(raw, 64); err == nil && seconds >= 0 {
+			return seconds, nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%q is not a valid duration", raw)
+		}
+		return d.Seconds(), nil
+	case ArgList:
+		var items []interface{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", a.Type)
+}
+
+// Versions
+
+// parseVersion parses major[.minor[.patch]] with an optional pre-release or
+// build suffix, which is ignored for comparisons
+func parseVersion(version string) ([3]int, error) {
+	var parts [3]int
+	match := versionPattern.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return parts, fmt.Errorf("invalid version %q", version)
+	}
+	for i := 0; i < 3; i++ {
+		if match[i+1] != "" {
+			parts[i], _ = strconv.Atoi(match[i+1])
+		}
+	}
+	return parts, nil
+}
+
+// CompareVersions returns -1, 0 or 1 as a is older than, equal to or newer than b
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < 3; i++ {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// VersionSatisfies reports whether version meets constraint. A constraint is a
+// comma or space separated list of clauses using =, >, >=, <, <=, ^ (same
+// major) or ~ (same minor); an empty constraint or "*" matches any version.
+func VersionSatisfies(version, constraint string) (bool, error) {
+	clauses := strings.FieldsFunc(constraint, func(r rune) bool { return r == ',' || r == ' ' })
+
+	// Allow "op version" written with a space, e.g. ">= 1.2"
+	var merged []string
+	for i := 0; i < len(clauses); i++ {
+		clause := clauses[i]
+		if strings.Trim(clause, "<>=^~") == "" && i+1 < len(clauses) {
+			clause += clauses[i+1]
+			i++
+		}
+		merged = append(merged, clause)
+	}
+
+	ok := true
+	for _, clause := range merged {
+		if clause == "*" {
+			continue
+		}
+
+		op := strings.TrimRight(clause, "0123456789.v-+abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		target := strings.TrimPrefix(clause, op)
+		cmp, err := CompareVersions(version, target)
+		if err != nil {
+			return false, err
+		}
+		tv, _ := parseVersion(target)
+		vv, _ := parseVersion(version)
+
+		var match bool
+		switch op {
+		case "", "=", "==":
+			match = cmp == 0
+		case ">":
+			match = cmp > 0
+		case ">=":
+			match = cmp >= 0
+		case "<":
+			match = cmp < 0
+		case "<=":
+			match = cmp <= 0
+		case "^":
+			match = cmp >= 0 && vv[0] == tv[0]
+		case "~":
+			match = cmp >= 0 && vv[0] == tv[0] && vv[1] == tv[1]
+		default:
+			return false, fmt.Errorf("invalid version constraint %q", clause)
+		}
+		ok = ok && match
+	}
+	return ok, nil
+}
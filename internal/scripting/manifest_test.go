@@ -0,0 +1,214 @@
+package scripting
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeaderManifest(t *testing.T) {
+	content := `-- SMTP probe for GoCat
+-- Name: smtp-probe
+-- Version: 1.2.0
+-- Author: Jane Doe
+-- Purpose: Checks SMTP servers for STARTTLS
+-- Arg: target:host! Host to probe
+-- Arg: ports:ports=25,587 Ports to check
+-- Arg: mode=fast
+-- Require: utils >=1.0
+-- Capability: net
+
+local x = 1
+-- Arg: ignored:int
+`
+	m, err := ParseHeaderManifest(content, "scripts/probe.lua")
+	if err != nil {
+		t.Fatalf("ParseHeaderManifest failed: %v", err)
+	}
+
+	if m.Name != "smtp-probe" || m.Version != "1.2.0" || m.Author != "Jane Doe" {
+		t.Errorf("Unexpected identity: %+v", m)
+	}
+	if m.Description != "Checks SMTP servers for STARTTLS" {
+		t.Errorf("Unexpected description %q", m.Description)
+	}
+	if !reflect.DeepEqual(m.Capabilities, []string{"net"}) {
+		t.Errorf("Unexpected capabilities %v", m.Capabilities)
+	}
+	if len(m.Requires) != 1 || m.Requires[0] != (Dependency{Name: "utils", Version: ">=1.0"}) {
+		t.Errorf("Unexpected requires %+v", m.Requires)
+	}
+
+	want := []ArgSpec{
+		{Name: "target", Type: ArgHost, Required: true, Description: "Host to probe"},
+		{Name: "ports", Type: ArgPorts, Default: "25,587", Description: "Ports to check"},
+		{Name: "mode", Type: ArgString, Default: "fast"},
+	}
+	if !reflect.DeepEqual(m.Args, want) {
+		t.Errorf("Expected args %+v, got %+v", want, m.Args)
+	}
+
+	// Defaults for scripts without a header
+	m, err = ParseHeaderManifest("-- Banner grabber\nprint(1)", "/x/banner.lua")
+	if err != nil {
+		t.Fatalf("ParseHeaderManifest failed: %v", err)
+	}
+	if m.Name != "banner" || m.Version != "0.0.0" || m.Description != "Banner grabber" {
+		t.Errorf("Unexpected defaults: %+v", m)
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		wantErr  string
+	}{
+		{"valid", Manifest{Name: "probe", Version: "1.0", Args: []ArgSpec{{Name: "target"}}}, ""},
+		{"bad name", Manifest{Name: "../x", Version: "1.0"}, "invalid script name"},
+		{"no version", Manifest{Name: "probe"}, "version is required"},
+		{"bad version", Manifest{Name: "probe", Version: "latest"}, "invalid version"},
+		{"bad capability", Manifest{Name: "probe", Version: "1", Capabilities: []string{"root"}}, "unknown capability"},
+		{"main outside", Manifest{Name: "probe", Version: "1", Main: "../evil.lua"}, "inside the package"},
+		{"reserved arg", Manifest{Name: "probe", Version: "1", Args: []ArgSpec{{Name: "timeout"}}}, "reserved"},
+		{"duplicate arg", Manifest{Name: "probe", Version: "1", Args: []ArgSpec{{Name: "a"}, {Name: "a"}}}, "duplicate"},
+		{"unknown type", Manifest{Name: "probe", Version: "1", Args: []ArgSpec{{Name: "a", Type: "ip"}}}, "unknown type"},
+		{"bad default", Manifest{Name: "probe", Version: "1", Args: []ArgSpec{{Name: "a", Type: "port", Default: "0"}}}, "invalid default"},
+		{"choices on int", Manifest{Name: "probe", Version: "1", Args: []ArgSpec{{Name: "a", Type: "int", Choices: []string{"1"}}}}, "choices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manifest.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestArgSpecParse(t *testing.T) {
+	tests := []struct {
+		arg     ArgSpec
+		raw     string
+		want    interface{}
+		wantErr bool
+	}{
+		{ArgSpec{Type: ArgString}, "x", "x", false},
+		{ArgSpec{Type: ArgString, Choices: []string{"a", "b"}}, "b", "b", false},
+		{ArgSpec{Type: ArgString, Choices: []string{"a", "b"}}, "c", nil, true},
+		{ArgSpec{Type: ArgInt}, "42", float64(42), false},
+		{ArgSpec{Type: ArgInt}, "4.2", nil, true},
+		{ArgSpec{Type: ArgNumber}, "4.5", 4.5, false},
+		{ArgSpec{Type: ArgBool}, "true", true, false},
+		{ArgSpec{Type: ArgBool}, "maybe", nil, true},
+		{ArgSpec{Type: ArgPort}, "443", float64(443), false},
+		{ArgSpec{Type: ArgPort}, "70000", nil, true},
+		{ArgSpec{Type: ArgPorts}, "22,80-82", []interface{}{float64(22), float64(80), float64(81), float64(82)}, false},
+		{ArgSpec{Type: ArgPorts}, "0-10", nil, true},
+		{ArgSpec{Type: ArgPorts}, "5-1", nil, true},
+		{ArgSpec{Type: ArgHost}, "example.com", "example.com", false},
+		{ArgSpec{Type: ArgHost}, "[::1]", "::1", false},
+		{ArgSpec{Type: ArgHost}, "bad host", nil, true},
+		{ArgSpec{Type: ArgDuration}, "1.5", 1.5, false},
+		{ArgSpec{Type: ArgDuration}, "250ms", 0.25, false},
+		{ArgSpec{Type: ArgDuration}, "-1s", nil, true},
+		{ArgSpec{Type: ArgList}, "a, b,,c", []interface{}{"a", "b", "c"}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.arg.Parse(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s %q: expected error, got %v", tt.arg.Type, tt.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: unexpected error: %v", tt.arg.Type, tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q: expected %#v, got %#v", tt.arg.Type, tt.raw, tt.want, got)
+		}
+	}
+}
+
+func TestResolveArgs(t *testing.T) {
+	m := &Manifest{Name: "probe", Version: "1", Args: []ArgSpec{
+		{Name: "target", Type: ArgHost, Required: true},
+		{Name: "count", Type: ArgInt, Default: "3"},
+		{Name: "verbose-scan", Type: ArgBool},
+	}}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	got, err := m.ResolveArgs(map[string]string{"target": "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("ResolveArgs failed: %v", err)
+	}
+	want := map[string]interface{}{"target": "10.0.0.1", "count": float64(3)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	for values, wantErr := range map[*map[string]string]string{
+		{}:                                 "missing required argument --target",
+		{"target": "x y"}:                  "invalid value for --target",
+		{"target": "h", "other": "1"}:      "unknown argument --other",
+		{"target": "h", "count": "plenty"}: "invalid value for --count",
+	} {
+		if _, err := m.ResolveArgs(*values); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%v: expected error %q, got %v", *values, wantErr, err)
+		}
+	}
+}
+
+func TestVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"1.2.3", "", true},
+		{"1.2.3", "*", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "=1.2", false},
+		{"1.2.3", ">=1.2", true},
+		{"1.2.3", ">= 1.3", false},
+		{"1.2.3", ">1.2.2", true},
+		{"1.2.3", "<2", true},
+		{"1.2.3", "<=1.2.3", true},
+		{"1.2.3", ">=1.0,<1.2", false},
+		{"1.9.0", "^1.2", true},
+		{"2.0.0", "^1.2", false},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"v2.0.0-beta", ">=2.0", true},
+	}
+
+	for _, tt := range tests {
+		got, err := VersionSatisfies(tt.version, tt.constraint)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error: %v", tt.version, tt.constraint, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("VersionSatisfies(%q, %q) = %v, want %v", tt.version, tt.constraint, got, tt.want)
+		}
+	}
+
+	if _, err := VersionSatisfies("1.0", "!1.0"); err == nil {
+		t.Error("Expected error for invalid constraint")
+	}
+	if _, err := ParseDependency("utils >=x"); err == nil {
+		t.Error("Expected error for invalid dependency version")
+	}
+}
@@ -0,0 +1,512 @@
+package scripting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	mockTypeName = "gocat.mock"
+
+	// mockStepTimeout bounds how long a mock waits for the data a step expects
+	mockStepTimeout = 5 * time.Second
+)
+
+// mockStep is one entry of a TCP mock's script: wait for expect, then send
+type mockStep struct {
+	expect string
+	send   string
+	delay  time.Duration
+	close  bool
+}
+
+// mockRule answers any received data matching pattern with reply
+type mockRule struct {
+	pattern *regexp.Regexp
+	reply   string
+}
+
+// mockSpec describes the behaviour of a fake server declared from Lua
+type mockSpec struct {
+	host   string
+	port   int
+	banner string
+	steps  []mockStep
+	rules  []mockRule
+	reply  string
+	echo   bool
+	close  bool
+	delay  time.Duration
+}
+
+// mockServer is an in-process TCP or UDP server bound to a loopback address.
+// It records everything it receives so tests can inspect the traffic.
+type mockServer struct {
+	proto    string
+	spec     mockSpec
+	listener net.Listener
+	packet   net.PacketConn
+
+	mu          sync.Mutex
+	received    bytes.Buffer
+	datagrams   []string
+	connections int
+	errs        []string
+	conns       map[net.Conn]struct{}
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// startMockServer binds spec's address and starts serving in the background
+func startMockServer(proto string, spec mockSpec) (*mockServer, error) {
+	if !isLoopbackHost(spec.host) {
+		return nil, fmt.Errorf("mock servers only listen on loopback addresses, not %s", spec.host)
+	}
+
+	m := &mockServer{
+		proto: proto,
+		spec:  spec,
+		conns: make(map[net.Conn]struct{}),
+	}
+	address := net.JoinHostPort(spec.host, strconv.Itoa(spec.port))
+
+	var err error
+	switch proto {
+	case "tcp":
+		m.listener, err = net.Listen("tcp", address)
+		if err == nil {
+			m.wg.Add(1)
+			go m.serveTCP()
+		}
+	case "udp":
+		m.packet, err = net.ListenPacket("udp", address)
+		if err == nil {
+			m.wg.Add(1)
+			go m.serveUDP()
+		}
+	default:
+		err = fmt.Errorf("unsupported mock protocol: %s", proto)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// isLoopbackHost reports whether host names a loopback address
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// addr returns the address the mock is bound to
+func (m *mockServer) addr() net.Addr {
+	if m.listener != nil {
+		return m.listener.Addr()
+	}
+	return m.packet.LocalAddr()
+}
+
+func (m *mockServer) serveTCP() {
+	defer m.wg.Done()
+
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			conn.Close()
+			return
+		}
+		m.connections++
+		m.conns[conn] = struct{}{}
+		m.wg.Add(1)
+		m.mu.Unlock()
+
+		go m.handleTCP(conn)
+	}
+}
+
+// handleTCP plays the mock's script against one client connection
+func (m *mockServer) handleTCP(conn net.Conn) {
+	defer m.wg.Done()
+	defer func() {
+		m.mu.Lock()
+		delete(m.conns, conn)
+		m.mu.Unlock()
+		conn.Close()
+	}()
+
+	if m.spec.delay > 0 {
+		time.Sleep(m.spec.delay)
+	}
+	if m.spec.banner != "" {
+		if _, err := conn.Write([]byte(m.spec.banner)); err != nil {
+			return
+		}
+	}
+
+	var pending []byte
+	buffer := make([]byte, defaultReadChunk)
+	for i, step := range m.spec.steps {
+		if step.expect != "" {
+			var ok bool
+			pending, ok = m.waitFor(conn, pending, buffer, step.expect)
+			if !ok {
+				m.fail("step %d: expected %q, got %q", i+1, step.expect, string(pending))
+				return
+			}
+		}
+		if step.delay > 0 {
+			time.Sleep(step.delay)
+		}
+		if step.send != "" {
+			if _, err := conn.Write([]byte(step.send)); err != nil {
+				m.fail("step %d: %v", i+1, err)
+				return
+			}
+		}
+		if step.close {
+			return
+		}
+	}
+	if m.spec.close {
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+	if len(pending) > 0 {
+		if reply, ok := m.respond(string(pending)); ok {
+			if _, err := conn.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}
+	for {
+		n, err := conn.Read(buffer)
+		if n > 0 {
+			data := string(buffer[:n])
+			m.record(data)
+			if reply, ok := m.respond(data); ok {
+				if _, err := conn.Write([]byte(reply)); err != nil {
+					return
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// waitFor reads from conn until the received data contains expect. It returns
+// the data following the match, or everything read so far on failure.
+func (m *mockServer) waitFor(conn net.Conn, pending, buffer []byte, expect string) ([]byte, bool) {
+	_ = conn.SetReadDeadline(time.Now().Add(mockStepTimeout))
+	for {
+		if idx := bytes.Index(pending, []byte(expect)); idx >= 0 {
+			return pending[idx+len(expect):], true
+		}
+
+		n, err := conn.Read(buffer)
+		if n > 0 {
+			m.record(string(buffer[:n]))
+			pending = append(pending, buffer[:n]...)
+		}
+		if err != nil {
+			return pending, bytes.Contains(pending, []byte(expect))
+		}
+	}
+}
+
+func (m *mockServer) serveUDP() {
+	defer m.wg.Done()
+
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := m.packet.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+
+		data := string(buffer[:n])
+		m.mu.Lock()
+		m.datagrams = append(m.datagrams, data)
+		m.mu.Unlock()
+		m.record(data)
+
+		if reply, ok := m.respond(data); ok {
+			if m.spec.delay > 0 {
+				time.Sleep(m.spec.delay)
+			}
+			_, _ = m.packet.WriteTo([]byte(reply), addr)
+		}
+	}
+}
+
+// respond picks the reply for received data: the first matching rule, then
+// echo, then the default reply
+func (m *mockServer) respond(data string) (string, bool) {
+	for _, rule := range m.spec.rules {
+		if rule.pattern.MatchString(data) {
+			return rule.reply, true
+		}
+	}
+	if m.spec.echo {
+		return data, true
+	}
+	if m.spec.reply != "" {
+		return m.spec.reply, true
+	}
+	return "", false
+}
+
+func (m *mockServer) record(data string) {
+	m.mu.Lock()
+	m.received.WriteString(data)
+	m.mu.Unlock()
+}
+
+func (m *mockServer) fail(format string, args ...interface{}) {
+	m.mu.Lock()
+	m.errs = append(m.errs, fmt.Sprintf(format, args...))
+	m.mu.Unlock()
+}
+
+// verify reports script steps that were not satisfied
+func (m *mockServer) verify() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.errs) > 0 {
+		return errors.New(strings.Join(m.errs, "; "))
+	}
+	if len(m.spec.steps) > 0 && m.connections == 0 {
+		return fmt.Errorf("mock %s %s received no connections", m.proto, m.addr())
+	}
+	return nil
+}
+
+// close stops the mock and drops its open connections
+func (m *mockServer) close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	for conn := range m.conns {
+		conn.Close()
+	}
+	m.mu.Unlock()
+
+	if m.listener != nil {
+		m.listener.Close()
+	}
+	if m.packet != nil {
+		m.packet.Close()
+	}
+	m.wg.Wait()
+}
+
+// parseMockSpec reads a mock declaration such as
+//
+//	mock.tcp{banner = "220 ready\r\n", steps = {{expect = "QUIT", send = "221 bye\r\n", close = true}}}
+//	mock.udp{responses = {["^ping"] = "pong"}}
+func parseMockSpec(L *lua.LState, t *lua.LTable) mockSpec {
+	spec := mockSpec{
+		host:   "127.0.0.1",
+		banner: lua.LVAsString(t.RawGetString("banner")),
+		reply:  lua.LVAsString(t.RawGetString("reply")),
+		echo:   lua.LVAsBool(t.RawGetString("echo")),
+		close:  lua.LVAsBool(t.RawGetString("close")),
+	}
+	if host := lua.LVAsString(t.RawGetString("host")); host != "" {
+		spec.host = host
+	}
+	if port, ok := t.RawGetString("port").(lua.LNumber); ok {
+		spec.port = int(port)
+	}
+	if delay, ok := t.RawGetString("delay").(lua.LNumber); ok {
+		spec.delay = parseSeconds(delay)
+	}
+
+	if steps, ok := t.RawGetString("steps").(*lua.LTable); ok {
+		for i := 1; i <= steps.Len(); i++ {
+			step, ok := steps.RawGetInt(i).(*lua.LTable)
+			if !ok {
+				L.RaiseError("mock step %d must be a table", i)
+			}
+			s := mockStep{
+				expect: lua.LVAsString(step.RawGetString("expect")),
+				send:   lua.LVAsString(step.RawGetString("send")),
+				close:  lua.LVAsBool(step.RawGetString("close")),
+			}
+			if delay, ok := step.RawGetString("delay").(lua.LNumber); ok {
+				s.delay = parseSeconds(delay)
+			}
+			spec.steps = append(spec.steps, s)
+		}
+	}
+
+	switch responses := t.RawGetString("responses").(type) {
+	case *lua.LTable:
+		if responses.Len() > 0 {
+			// Ordered form: {{pattern, reply}, ...}
+			for i := 1; i <= responses.Len(); i++ {
+				pair, ok := responses.RawGetInt(i).(*lua.LTable)
+				if !ok {
+					L.RaiseError("mock response %d must be a {pattern, reply} pair", i)
+				}
+				spec.rules = append(spec.rules, mockRule{
+					pattern: compileMockPattern(L, lua.LVAsString(pair.RawGetInt(1))),
+					reply:   lua.LVAsString(pair.RawGetInt(2)),
+				})
+			}
+		} else {
+			// Map form: {[pattern] = reply}, tried in pattern order
+			var patterns []string
+			responses.ForEach(func(key, _ lua.LValue) {
+				patterns = append(patterns, lua.LVAsString(key))
+			})
+			sort.Strings(patterns)
+			for _, pattern := range patterns {
+				spec.rules = append(spec.rules, mockRule{
+					pattern: compileMockPattern(L, pattern),
+					reply:   lua.LVAsString(responses.RawGetString(pattern)),
+				})
+			}
+		}
+	case *lua.LNilType:
+	default:
+		L.RaiseError("mock responses must be a table")
+	}
+
+	return spec
+}
+
+func compileMockPattern(L *lua.LState, pattern string) *regexp.Regexp {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		L.RaiseError("invalid mock response pattern %q: %v", pattern, err)
+	}
+	return re
+}
+
+// registerMockType adds the metatable for mock server handles
+func registerMockType(L *lua.LState) {
+	methods := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"received":    mockReceived,
+		"datagrams":   mockDatagrams,
+		"connections": mockConnections,
+		"verify":      mockVerify,
+		"close":       mockClose,
+	})
+
+	mt := L.NewTypeMetatable(mockTypeName)
+	L.SetField(mt, "__index", L.NewFunction(func(L *lua.LState) int {
+		m := checkMock(L)
+		switch key := L.CheckString(2); key {
+		case "host":
+			host, _, _ := net.SplitHostPort(m.addr().String())
+			L.Push(lua.LString(host))
+		case "port":
+			_, port, _ := net.SplitHostPort(m.addr().String())
+			n, _ := strconv.Atoi(port)
+			L.Push(lua.LNumber(n))
+		case "proto":
+			L.Push(lua.LString(m.proto))
+		default:
+			L.Push(methods.RawGetString(key))
+		}
+		return 1
+	}))
+	L.SetField(mt, "__tostring", L.NewFunction(func(L *lua.LState) int {
+		m := checkMock(L)
+		L.Push(lua.LString(fmt.Sprintf("mock %s %s", m.proto, m.addr())))
+		return 1
+	}))
+}
+
+func newMockValue(L *lua.LState, m *mockServer) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = m
+	L.SetMetatable(ud, L.GetTypeMetatable(mockTypeName))
+	return ud
+}
+
+func checkMock(L *lua.LState) *mockServer {
+	ud := L.CheckUserData(1)
+	if m, ok := ud.Value.(*mockServer); ok {
+		return m
+	}
+	L.ArgError(1, "mock expected")
+	return nil
+}
+
+// mockReceived implements mock:received(); returns all bytes received so far
+func mockReceived(L *lua.LState) int {
+	m := checkMock(L)
+	m.mu.Lock()
+	data := m.received.String()
+	m.mu.Unlock()
+	L.Push(lua.LString(data))
+	return 1
+}
+
+// mockDatagrams implements mock:datagrams(); returns the datagrams a UDP mock received
+func mockDatagrams(L *lua.LState) int {
+	m := checkMock(L)
+	result := L.NewTable()
+	m.mu.Lock()
+	for _, d := range m.datagrams {
+		result.Append(lua.LString(d))
+	}
+	m.mu.Unlock()
+	L.Push(result)
+	return 1
+}
+
+// mockConnections implements mock:connections(); returns the number of accepted connections
+func mockConnections(L *lua.LState) int {
+	m := checkMock(L)
+	m.mu.Lock()
+	count := m.connections
+	if m.proto == "udp" {
+		count = len(m.datagrams)
+	}
+	m.mu.Unlock()
+	L.Push(lua.LNumber(count))
+	return 1
+}
+
+// mockVerify implements mock:verify(); raises an error if the mock's steps were not met
+func mockVerify(L *lua.LState) int {
+	if err := checkMock(L).verify(); err != nil {
+		L.RaiseError("%v", err)
+	}
+	return 0
+}
+
+// mockClose implements mock:close()
+func mockClose(L *lua.LState) int {
+	checkMock(L).close()
+	return 0
+}
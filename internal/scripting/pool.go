@@ -0,0 +1,184 @@
+package scripting
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/network"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const poolTypeName = "gocat.pool"
+
+// luaPool is the Go side of the pool objects returned by gocat.pool. The
+// connections live in the engine's upstreams, shared by every pool object
+// for the same backend.
+type luaPool struct {
+	address string
+}
+
+// registerPoolType registers gocat.pool and the metatable of pool objects
+func (e *LuaEngine) registerPoolType(gocatTable *lua.LTable) {
+	L := e.L
+
+	mt := L.NewTypeMetatable(poolTypeName)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"get":   e.poolGet,
+		"put":   poolPut,
+		"stats": e.poolStats,
+	}))
+	L.SetField(mt, "__tostring", L.NewFunction(poolToString))
+	gocatTable.RawSetString("pool", L.NewFunction(e.luaPool))
+}
+
+// upstreams returns the pool connections are taken from. Without one in
+// the configuration the engine keeps its own, dialing like connect().
+func (e *LuaEngine) upstreams() *network.Upstreams {
+	if e.config.Upstreams != nil {
+		return e.config.Upstreams
+	}
+	if e.ownUpstreams == nil {
+		config := network.DefaultDialerConfig()
+		config.MaxRetries = 0
+		config.ConnectionTimeout = defaultConnectionTimeout
+		dialer := network.NewDialer(config)
+		dialer.SetDialFunc(e.dialContext)
+
+		poolConfig := network.DefaultPoolConfig()
+		poolConfig.MinSize = 0
+		poolConfig.ConnectionTimeout = defaultConnectionTimeout
+		e.ownUpstreams = network.NewUpstreams(dialer, poolConfig)
+	}
+	return e.ownUpstreams
+}
+
+// luaPool implements gocat.pool(addr) and gocat.pool(host, port); returns a
+// pool object for the backend
+func (e *LuaEngine) luaPool(L *lua.LState) int {
+	host, port := L.CheckString(1), 0
+	if L.GetTop() >= 2 {
+		port = L.CheckInt(2)
+	} else {
+		h, p, err := net.SplitHostPort(host)
+		if err != nil {
+			L.ArgError(1, "host:port expected")
+		}
+		host = h
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			L.ArgError(1, "invalid port")
+		}
+	}
+	if host == "" || port <= 0 || port > 65535 {
+		L.ArgError(1, "invalid host or port")
+	}
+	e.checkNet(L, host, port)
+
+	ud := L.NewUserData()
+	ud.Value = &luaPool{address: net.JoinHostPort(host, strconv.Itoa(port))}
+	L.SetMetatable(ud, L.GetTypeMetatable(poolTypeName))
+	L.Push(ud)
+	return 1
+}
+
+// checkPool extracts the pool object from the first argument
+func checkPool(L *lua.LState) *luaPool {
+	ud := L.CheckUserData(1)
+	if lp, ok := ud.Value.(*luaPool); ok {
+		return lp
+	}
+	L.ArgError(1, "pool expected")
+	return nil
+}
+
+// poolGet implements pool:get(); returns a conn, idle from the pool when
+// there is one, or nil, err. conn:close() discards it, pool:put(conn)
+// hands it back.
+func (e *LuaEngine) poolGet(L *lua.LState) int {
+	lp := checkPool(L)
+	upstreams := e.upstreams()
+	ctx := scriptContext(L)
+
+	return e.async(L, func() asyncResult {
+		dialCtx, cancel := context.WithTimeout(ctx, defaultConnectionTimeout)
+		defer cancel()
+
+		conn, err := upstreams.Get(dialCtx, lp.address)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		return connResult(e.trackConn(newLuaConn(conn)))
+	})
+}
+
+// poolPut implements pool:put(conn); hands a conn from pool:get() back for
+// reuse and returns true, or closes it and returns false when it cannot be
+// reused because data it received is still unread
+func poolPut(L *lua.LState) int {
+	checkPool(L)
+	ud := L.CheckUserData(2)
+	lc, ok := ud.Value.(*luaConn)
+	if !ok {
+		L.ArgError(2, "connection expected")
+	}
+	conn, ok := lc.conn.(*network.UpstreamConn)
+	if !ok {
+		L.ArgError(2, "connection not taken from a pool")
+	}
+
+	lc.mu.Lock()
+	closed := lc.closed
+	lc.closed = true
+	lc.mu.Unlock()
+	if closed {
+		L.Push(lua.LFalse)
+		return 1
+	}
+	if lc.release != nil {
+		lc.release()
+	}
+
+	// The next user would miss what was read ahead
+	if lc.reader.Buffered() > 0 {
+		conn.Close()
+		L.Push(lua.LFalse)
+		return 1
+	}
+	_ = conn.SetDeadline(time.Time{})
+	L.Push(lua.LBool(conn.Release() == nil))
+	return 1
+}
+
+// poolStats implements pool:stats(); returns {address, idle, active,
+// circuit, failures} with the state of the backend's circuit breaker and
+// its failed connection attempts
+func (e *LuaEngine) poolStats(L *lua.LState) int {
+	lp := checkPool(L)
+
+	t := L.NewTable()
+	t.RawSetString("address", lua.LString(lp.address))
+	t.RawSetString("idle", lua.LNumber(0))
+	t.RawSetString("active", lua.LNumber(0))
+	t.RawSetString("circuit", lua.LString(network.CircuitBreakerClosed.String()))
+	t.RawSetString("failures", lua.LNumber(0))
+	for _, backend := range e.upstreams().Stats().Backends {
+		if backend.Address != lp.address {
+			continue
+		}
+		t.RawSetString("idle", lua.LNumber(backend.Idle))
+		t.RawSetString("active", lua.LNumber(backend.Active))
+		t.RawSetString("circuit", lua.LString(backend.Circuit.State.String()))
+		t.RawSetString("failures", lua.LNumber(backend.Circuit.Failures))
+	}
+	L.Push(t)
+	return 1
+}
+
+// poolToString implements tostring(pool)
+func poolToString(L *lua.LState) int {
+	L.Push(lua.LString(fmt.Sprintf("pool(%s)", checkPool(L).address)))
+	return 1
+}
@@ -0,0 +1,473 @@
+package scripting
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// EnvScriptPath lists extra script directories, separated like PATH
+	EnvScriptPath = "GOCAT_SCRIPT_PATH"
+
+	// ProjectScriptDir is the project-local script directory
+	ProjectScriptDir = "./scripts"
+
+	luaExtension = ".lua"
+
+	// Install limits for script packages
+	maxInstallFiles = 1000
+	maxInstallSize  = 50 * 1024 * 1024
+)
+
+// Script is a script located in a search path: a single .lua file or a
+// package directory with a script.yaml manifest
+type Script struct {
+	Manifest *Manifest `json:"manifest"`
+	// Path is the .lua file to run
+	Path string `json:"path"`
+	// Dir is the package directory, or the directory holding a single-file script
+	Dir string `json:"dir"`
+	// Package reports whether the script is a manifest package
+	Package bool `json:"package"`
+}
+
+// SearchPaths returns the script directories in lookup order: the project
+// directory, GOCAT_SCRIPT_PATH entries, then the user data directory
+func SearchPaths() []string {
+	paths := []string{ProjectScriptDir}
+	for _, dir := range filepath.SplitList(os.Getenv(EnvScriptPath)) {
+		if dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+	if dir := UserScriptDir(); dir != "" {
+		paths = append(paths, dir)
+	}
+	return paths
+}
+
+// UserScriptDir returns $XDG_DATA_HOME/gocat/scripts, defaulting to
+// ~/.local/share/gocat/scripts. Scripts are installed here.
+func UserScriptDir() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "gocat", "scripts")
+}
+
+// OpenScript loads the manifest of a package directory or a single .lua file
+func OpenScript(path string) (*Script, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read script file: %w", err)
+		}
+		manifest, err := ParseHeaderManifest(string(content), path)
+		if err != nil {
+			return nil, err
+		}
+		return &Script{Manifest: manifest, Path: path, Dir: filepath.Dir(path)}, nil
+	}
+
+	manifest, err := LoadManifest(filepath.Join(path, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{manifest.Main, manifest.Name + luaExtension, "main.lua", "init.lua"}
+	if manifest.Main != "" {
+		candidates = candidates[:1]
+	}
+	for _, candidate := range candidates {
+		main := filepath.Join(path, candidate)
+		if fi, err := os.Stat(main); err == nil && !fi.IsDir() {
+			return &Script{Manifest: manifest, Path: main, Dir: path, Package: true}, nil
+		}
+	}
+	return nil, fmt.Errorf("script package %s has no entry point (tried %s)", path, strings.Join(candidates, ", "))
+}
+
+// FindScript resolves a script name or path. Explicit paths are opened
+// directly; bare names are looked up in each search path as a package
+// directory, then as name.lua.
+func FindScript(name string, paths []string) (*Script, error) {
+	if filepath.IsAbs(name) || strings.ContainsRune(name, filepath.Separator) || strings.HasPrefix(name, ".") {
+		return OpenScript(name)
+	}
+
+	base := strings.TrimSuffix(name, luaExtension)
+	for _, dir := range paths {
+		for _, candidate := range []string{filepath.Join(dir, base), filepath.Join(dir, base+luaExtension)} {
+			if _, err := os.Stat(candidate); err == nil {
+				return OpenScript(candidate)
+			}
+		}
+	}
+
+	// Fall back to the current directory for "script run foo.lua"
+	if strings.HasSuffix(name, luaExtension) {
+		if _, err := os.Stat(name); err == nil {
+			return OpenScript(name)
+		}
+	}
+
+	return nil, fmt.Errorf("script '%s' not found in %s", name, strings.Join(paths, string(os.PathListSeparator)))
+}
+
+// ListScripts returns every script in the search paths sorted by name. A
+// name found in several paths is reported once, from the first path. Scripts
+// with invalid manifests are returned in errs.
+func ListScripts(paths []string) (scripts []*Script, errs []error) {
+	seen := make(map[string]bool)
+
+	for _, dir := range paths {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || strings.HasSuffix(name, TestFileSuffix) {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if entry.IsDir() {
+				if _, err := os.Stat(filepath.Join(path, ManifestFile)); err != nil {
+					continue
+				}
+			} else if !strings.HasSuffix(name, luaExtension) {
+				continue
+			}
+
+			script, err := OpenScript(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if seen[script.Manifest.Name] {
+				continue
+			}
+			seen[script.Manifest.Name] = true
+			scripts = append(scripts, script)
+		}
+	}
+
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Manifest.Name < scripts[j].Manifest.Name
+	})
+	return scripts, errs
+}
+
+// ResolveDependencies finds the packages a script requires, recursively, and
+// returns them in dependency order
+func ResolveDependencies(script *Script, paths []string) ([]*Script, error) {
+	var deps []*Script
+	resolved := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(m *Manifest) error
+	visit = func(m *Manifest) error {
+		visiting[m.Name] = true
+		defer delete(visiting, m.Name)
+
+		for _, dep := range m.Requires {
+			if visiting[dep.Name] {
+				return fmt.Errorf("dependency cycle: %s requires %s", m.Name, dep.Name)
+			}
+
+			found, err := FindScript(dep.Name, paths)
+			if err != nil {
+				return fmt.Errorf("%s requires %s: not installed", m.Name, dep)
+			}
+			ok, err := VersionSatisfies(found.Manifest.Version, dep.Version)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s requires %s, found version %s", m.Name, dep, found.Manifest.Version)
+			}
+
+			if resolved[dep.Name] {
+				continue
+			}
+			if err := visit(found.Manifest); err != nil {
+				return err
+			}
+			resolved[dep.Name] = true
+			deps = append(deps, found)
+		}
+		return nil
+	}
+
+	if err := visit(script.Manifest); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+// ConfigureModules resolves the script's dependencies and sets the module
+// search paths and packages require() can load
+func (s *Script) ConfigureModules(config *EngineConfig, paths []string) error {
+	deps, err := ResolveDependencies(s, paths)
+	if err != nil {
+		return err
+	}
+
+	config.ModulePaths = []string{s.Dir}
+	config.Packages = make(map[string]string, len(deps))
+	for _, dep := range deps {
+		config.ModulePaths = append(config.ModulePaths, dep.Dir)
+		config.Packages[dep.Manifest.Name] = dep.Path
+	}
+	return nil
+}
+
+// InstallScript installs a package directory, a .tar.gz archive of one, or a
+// single .lua file into dest. Replacing an installed script with the same or
+// a newer version requires force.
+func InstallScript(src, dest string, force bool) (*Script, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() && strings.HasSuffix(src, luaExtension) {
+		return installFile(src, dest, force)
+	}
+
+	// Stage inside dest so the final rename stays on one filesystem; hidden
+	// directories are ignored by ListScripts
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	staging, err := os.MkdirTemp(dest, ".install-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	var root string
+	switch {
+	case info.IsDir():
+		root = filepath.Join(staging, "pkg")
+		if err := copyTree(src, root); err != nil {
+			return nil, err
+		}
+	case strings.HasSuffix(src, ".tar.gz") || strings.HasSuffix(src, ".tgz"):
+		if err := extractTarGz(src, staging); err != nil {
+			return nil, err
+		}
+		if root, err = packageRoot(staging); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported script source %s (expected a directory, .tar.gz or .lua file)", src)
+	}
+
+	script, err := OpenScript(root)
+	if err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(dest, script.Manifest.Name)
+	if err := checkReplace(target, script.Manifest, force); err != nil {
+		return nil, err
+	}
+
+	if err := os.RemoveAll(target); err != nil {
+		return nil, fmt.Errorf("failed to remove previous install: %w", err)
+	}
+	if err := os.Rename(root, target); err != nil {
+		return nil, fmt.Errorf("failed to install %s: %w", script.Manifest.Name, err)
+	}
+
+	return OpenScript(target)
+}
+
+// installFile installs a single-file script as dest/<name>.lua
+func installFile(src, dest string, force bool) (*Script, error) {
+	script, err := OpenScript(src)
+	if err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(dest, script.Manifest.Name+luaExtension)
+	if err := checkReplace(target, script.Manifest, force); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	if err := copyFile(src, target, 0644); err != nil {
+		return nil, err
+	}
+	return OpenScript(target)
+}
+
+// checkReplace refuses to overwrite an installed script with an older or
+// equal version unless forced
+func checkReplace(target string, m *Manifest, force bool) error {
+	if _, err := os.Stat(target); err != nil || force {
+		return nil
+	}
+
+	existing, err := OpenScript(target)
+	if err != nil {
+		return fmt.Errorf("%s already exists (use --force to replace it)", target)
+	}
+	if cmp, err := CompareVersions(m.Version, existing.Manifest.Version); err == nil && cmp > 0 {
+		return nil
+	}
+	return fmt.Errorf("%s %s is already installed (use --force to replace it with %s)",
+		existing.Manifest.Name, existing.Manifest.Version, m.Version)
+}
+
+// packageRoot returns the extracted package directory: the archive root if it
+// holds the manifest, otherwise its single top-level directory
+func packageRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+		return dir, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root := filepath.Join(dir, entries[0].Name())
+		if _, err := os.Stat(filepath.Join(root, ManifestFile)); err == nil {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("archive does not contain a %s manifest", ManifestFile)
+}
+
+// extractTarGz extracts regular files and directories only, rejecting
+// absolute paths, parent references and links
+func extractTarGz(src, dest string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid archive %s: %w", src, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var files int
+	var total int64
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive %s: %w", src, err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("archive entry %q escapes the package directory", hdr.Name)
+		}
+		target := filepath.Join(dest, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			files++
+			total += hdr.Size
+			if files > maxInstallFiles || total > maxInstallSize {
+				return fmt.Errorf("archive exceeds install limits (%d files, %d bytes)", maxInstallFiles, maxInstallSize)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, io.LimitReader(tr, hdr.Size))
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return fmt.Errorf("archive entry %q is not a regular file or directory", hdr.Name)
+		}
+	}
+}
+
+// copyTree copies the regular files and directories under src into dest
+func copyTree(src, dest string) error {
+	var files int
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch {
+		case info.IsDir():
+			if rel != "." && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		case info.Mode().IsRegular():
+			if files++; files > maxInstallFiles {
+				return fmt.Errorf("package exceeds %d files", maxInstallFiles)
+			}
+			return copyFile(path, target, 0644)
+		}
+		return fmt.Errorf("%s is not a regular file or directory", path)
+	})
+}
+
+func copyFile(src, dest string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,239 @@
+package scripting
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePackage(t *testing.T, dir, name, manifest string, files map[string]string) string {
+	t.Helper()
+
+	pkg := filepath.Join(dir, name)
+	writeTestFile(t, pkg, ManifestFile, manifest)
+	for file, content := range files {
+		writeTestFile(t, pkg, file, content)
+	}
+	return pkg
+}
+
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	t.Setenv(EnvScriptPath, "/opt/a"+string(os.PathListSeparator)+"/opt/b")
+	t.Setenv("XDG_DATA_HOME", "/data")
+
+	want := []string{ProjectScriptDir, "/opt/a", "/opt/b", filepath.Join("/data", "gocat", "scripts")}
+	if got := SearchPaths(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestFindAndListScripts(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeTestFile(t, first, "banner.lua", "-- Version: 2.0\n")
+	writeTestFile(t, first, "banner_test.lua", "")
+	writeTestFile(t, second, "banner.lua", "-- Version: 1.0\n")
+	writePackage(t, second, "probe", "name: probe\nversion: 0.3.0\n", map[string]string{"main.lua": ""})
+	writePackage(t, second, "broken", "name: broken\nversion: 1\nargs: [{name: timeout}]\n", map[string]string{"main.lua": ""})
+	writeTestFile(t, second, "notes/readme.txt", "")
+	paths := []string{first, second}
+
+	script, err := FindScript("banner", paths)
+	if err != nil || script.Manifest.Version != "2.0" {
+		t.Fatalf("Expected banner 2.0 from the first path, got %+v, %v", script, err)
+	}
+	script, err = FindScript("probe", paths)
+	if err != nil || !script.Package || script.Path != filepath.Join(second, "probe", "main.lua") {
+		t.Fatalf("Expected probe package, got %+v, %v", script, err)
+	}
+	if _, err := FindScript("missing", paths); err == nil {
+		t.Error("Expected error for missing script")
+	}
+
+	scripts, errs := ListScripts(paths)
+	var names []string
+	for _, s := range scripts {
+		names = append(names, s.Manifest.Name+"@"+s.Manifest.Version)
+	}
+	if strings.Join(names, ",") != "banner@2.0,probe@0.3.0" {
+		t.Errorf("Unexpected scripts %v", names)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "reserved") {
+		t.Errorf("Expected one manifest error, got %v", errs)
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writePackage(t, dir, "base", "name: base\nversion: 1.4.0\n", map[string]string{"base.lua": ""})
+	writePackage(t, dir, "mid", "name: mid\nversion: 2.0.0\nrequires: [base ^1.2]\n", map[string]string{"mid.lua": ""})
+	writePackage(t, dir, "app", "name: app\nversion: 1.0.0\nrequires: [mid >=2, base]\n", map[string]string{"app.lua": ""})
+	writePackage(t, dir, "old", "name: old\nversion: 1.0.0\nrequires: [base >=2]\n", map[string]string{"old.lua": ""})
+	writePackage(t, dir, "loop-a", "name: loop-a\nversion: 1.0.0\nrequires: [loop-b]\n", map[string]string{"main.lua": ""})
+	writePackage(t, dir, "loop-b", "name: loop-b\nversion: 1.0.0\nrequires: [loop-a]\n", map[string]string{"main.lua": ""})
+	paths := []string{dir}
+
+	app, err := FindScript("app", paths)
+	if err != nil {
+		t.Fatalf("FindScript failed: %v", err)
+	}
+	deps, err := ResolveDependencies(app, paths)
+	if err != nil {
+		t.Fatalf("ResolveDependencies failed: %v", err)
+	}
+	if len(deps) != 2 || deps[0].Manifest.Name != "base" || deps[1].Manifest.Name != "mid" {
+		t.Errorf("Expected base then mid, got %+v", deps)
+	}
+
+	for name, wantErr := range map[string]string{"old": "found version 1.4.0", "loop-a": "dependency cycle"} {
+		script, err := FindScript(name, paths)
+		if err != nil {
+			t.Fatalf("FindScript(%s) failed: %v", name, err)
+		}
+		if _, err := ResolveDependencies(script, paths); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%s: expected error %q, got %v", name, wantErr, err)
+		}
+	}
+}
+
+func TestInstallScript(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+
+	pkg := writePackage(t, src, "probe-src", "name: probe\nversion: 1.0.0\n", map[string]string{"probe.lua": "return 1", "lib/util.lua": ""})
+	script, err := InstallScript(pkg, dest, false)
+	if err != nil {
+		t.Fatalf("InstallScript(dir) failed: %v", err)
+	}
+	if script.Path != filepath.Join(dest, "probe", "probe.lua") {
+		t.Errorf("Unexpected install path %s", script.Path)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "probe", "lib", "util.lua")); err != nil {
+		t.Errorf("Package files were not copied: %v", err)
+	}
+
+	// Same version needs --force, newer version replaces
+	if _, err := InstallScript(pkg, dest, false); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("Expected already installed error, got %v", err)
+	}
+	if _, err := InstallScript(pkg, dest, true); err != nil {
+		t.Errorf("Forced install failed: %v", err)
+	}
+
+	archive := filepath.Join(src, "probe-1.1.0.tar.gz")
+	writeTarGz(t, archive, map[string]string{
+		"probe-1.1.0/script.yaml": "name: probe\nversion: 1.1.0\n",
+		"probe-1.1.0/probe.lua":   "return 2",
+	})
+	if script, err = InstallScript(archive, dest, false); err != nil || script.Manifest.Version != "1.1.0" {
+		t.Fatalf("InstallScript(tar.gz) failed: %+v, %v", script, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "probe", "lib")); !os.IsNotExist(err) {
+		t.Error("Upgrade should replace the previous install")
+	}
+
+	single := writeTestFile(t, src, "banner.lua", "-- Version: 0.2\nprint(1)\n")
+	if script, err = InstallScript(single, dest, false); err != nil || script.Path != filepath.Join(dest, "banner.lua") {
+		t.Fatalf("InstallScript(lua) failed: %+v, %v", script, err)
+	}
+
+	scripts, _ := ListScripts([]string{dest})
+	if len(scripts) != 2 {
+		t.Errorf("Staging directories should not be listed, got %+v", scripts)
+	}
+}
+
+func TestInstallRejectsUnsafeArchives(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+
+	for name, files := range map[string]map[string]string{
+		"escape.tar.gz":   {"script.yaml": "name: x\nversion: 1\n", "../evil.lua": ""},
+		"absolute.tar.gz": {"script.yaml": "name: x\nversion: 1\n", "/tmp/evil.lua": ""},
+		"nomanifest.tgz":  {"x/main.lua": ""},
+	} {
+		archive := filepath.Join(src, name)
+		writeTarGz(t, archive, files)
+		if _, err := InstallScript(archive, dest, false); err == nil {
+			t.Errorf("%s: expected install to fail", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dest), "evil.lua")); err == nil {
+		t.Error("Archive entry escaped the install directory")
+	}
+}
+
+func TestRequireAndArgs(t *testing.T) {
+	dir := t.TempDir()
+	writePackage(t, dir, "utils", "name: utils\nversion: 1.0.0\n", map[string]string{
+		"utils.lua": "return {greet = function(n) return 'hello ' .. n end}",
+	})
+	app := writePackage(t, dir, "app", "name: app\nversion: 1.0.0\nrequires: [utils]\n", map[string]string{
+		"main.lua":        "",
+		"lib/helper.lua":  "counter = (counter or 0) + 1\nreturn {n = counter}",
+		"cycle/a.lua":     "return require('cycle.b')",
+		"cycle/b.lua":     "return require('cycle.a')",
+		"nested/init.lua": "return 'nested'",
+	})
+
+	script, err := OpenScript(app)
+	if err != nil {
+		t.Fatalf("OpenScript failed: %v", err)
+	}
+	config := DefaultEngineConfig()
+	if err := script.ConfigureModules(config, []string{dir}); err != nil {
+		t.Fatalf("ConfigureModules failed: %v", err)
+	}
+	config.Args = map[string]interface{}{"target": "example.com", "ports": []interface{}{float64(80)}}
+
+	engine := NewLuaEngine(config)
+	if engine == nil {
+		t.Fatal("Failed to create engine")
+	}
+	defer engine.Close()
+
+	err = engine.L.DoString(`
+		assert(require("utils").greet("x") == "hello x")
+		assert(require("lib.helper").n == 1 and require("lib.helper").n == 1, "modules are cached")
+		assert(require("nested") == "nested")
+		assert(args.target == "example.com" and args.ports[1] == 80)
+		assert(not pcall(require, "../secret"))
+		assert(not pcall(require, "missing"))
+		local ok, err = pcall(require, "cycle.a")
+		assert(not ok and err:find("requires itself"), err)
+	`)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	// Modules are also resolved next to test files
+	path := writeTestFile(t, filepath.Join(app, "lib"), "helper_test.lua", `
+test("require", function() assert.equal(require("helper").n, 1) end)
+`)
+	suite := (&TestRunner{}).RunFile(context.Background(), path)
+	if len(suite.Tests) != 1 || suite.Tests[0].Status != TestPassed {
+		t.Errorf("Expected require to work in tests, got %+v", suite.Tests)
+	}
+}
@@ -0,0 +1,80 @@
+package scripting
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// moduleNamePattern restricts require() to dotted names so a module can never
+// be loaded from outside the module paths
+var moduleNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*(\.[A-Za-z_][A-Za-z0-9_-]*)*$`)
+
+// moduleLoading marks a module whose chunk is still running
+var moduleLoading = lua.LString("\x00loading")
+
+// luaRequire implements require(name). The package library is never opened,
+// so modules are resolved only from required packages and the configured
+// module paths, as name.lua or name/init.lua with dots mapped to directories.
+func (e *LuaEngine) luaRequire(L *lua.LState) int {
+	name := L.CheckString(1)
+	if !moduleNamePattern.MatchString(name) {
+		L.ArgError(1, "invalid module name")
+	}
+
+	if value, ok := e.modules[name]; ok {
+		if value == moduleLoading {
+			L.RaiseError("module '%s' requires itself", name)
+		}
+		L.Push(value)
+		return 1
+	}
+
+	path := e.findModule(name)
+	if path == "" {
+		L.RaiseError("module '%s' not found in %s", name, strings.Join(e.config.ModulePaths, ", "))
+	}
+
+	fn, err := L.LoadFile(path)
+	if err != nil {
+		L.RaiseError("error loading module '%s': %v", name, err)
+	}
+
+	e.modules[name] = moduleLoading
+	L.Push(fn)
+	L.Push(lua.LString(name))
+	if err := L.PCall(1, 1, nil); err != nil {
+		delete(e.modules, name)
+		L.RaiseError("error loading module '%s': %v", name, err)
+	}
+
+	value := L.Get(-1)
+	L.Pop(1)
+	if value == lua.LNil {
+		value = lua.LTrue
+	}
+	e.modules[name] = value
+	L.Push(value)
+	return 1
+}
+
+// findModule returns the file a module name resolves to, or "" if none exists
+func (e *LuaEngine) findModule(name string) string {
+	if path, ok := e.config.Packages[name]; ok {
+		return path
+	}
+
+	rel := filepath.FromSlash(strings.ReplaceAll(name, ".", "/"))
+	for _, dir := range e.config.ModulePaths {
+		for _, candidate := range []string{rel + luaExtension, filepath.Join(rel, "init.lua")} {
+			path := filepath.Join(dir, candidate)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
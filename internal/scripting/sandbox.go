@@ -0,0 +1,189 @@
+package scripting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Library names accepted in EngineConfig.Libraries
+const (
+	LibBase      = "base"
+	LibTable     = "table"
+	LibString    = "string"
+	LibMath      = "math"
+	LibCoroutine = "coroutine"
+	LibOS        = "os"
+	LibIO        = "io"
+	LibChannel   = "channel"
+)
+
+// DefaultLibraries is the set of standard libraries opened when none are configured.
+// os and io are always restricted to what the granted capabilities allow.
+var DefaultLibraries = []string{LibBase, LibTable, LibString, LibMath, LibCoroutine, LibOS, LibIO}
+
+var libraryOpeners = map[string]lua.LGFunction{
+	LibBase:      lua.OpenBase,
+	LibTable:     lua.OpenTable,
+	LibString:    lua.OpenString,
+	LibMath:      lua.OpenMath,
+	LibCoroutine: lua.OpenCoroutine,
+	LibOS:        lua.OpenOs,
+	LibIO:        lua.OpenIo,
+	LibChannel:   lua.OpenChannel,
+}
+
+var libraryModuleNames = map[string]string{
+	LibBase:      lua.BaseLibName,
+	LibTable:     lua.TabLibName,
+	LibString:    lua.StringLibName,
+	LibMath:      lua.MathLibName,
+	LibCoroutine: lua.CoroutineLibName,
+	LibOS:        lua.OsLibName,
+	LibIO:        lua.IoLibName,
+	LibChannel:   lua.ChannelLibName,
+}
+
+// Functions kept from the os and io libraries regardless of capabilities
+var (
+	safeOSFuncs = []string{"clock", "date", "difftime", "time"}
+	safeIOFuncs = []string{"write", "read", "type", "stdout", "stderr", "stdin"}
+)
+
+// ValidateLibraries checks that every library name is known
+func ValidateLibraries(libs []string) error {
+	for _, lib := range libs {
+		if _, ok := libraryOpeners[lib]; !ok {
+			names := make([]string, 0, len(libraryOpeners))
+			for name := range libraryOpeners {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown Lua library %q (available: %s)", lib, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// newRestrictedState opens a Lua state with the requested libraries and strips
+// everything the granted capabilities do not cover. package and debug are never opened.
+func newRestrictedState(libs []string, caps *CapabilitySet) (*lua.LState, error) {
+	if libs == nil {
+		libs = DefaultLibraries
+	}
+	if err := ValidateLibraries(libs); err != nil {
+		return nil, err
+	}
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range libs {
+		L.Push(L.NewFunction(libraryOpeners[lib]))
+		L.Push(lua.LString(libraryModuleNames[lib]))
+		L.Call(1, 0)
+	}
+
+	restrictBase(L, caps)
+	restrictOS(L, caps)
+	restrictIO(L, caps)
+
+	return L, nil
+}
+
+// restrictBase guards the base functions that load code from disk
+func restrictBase(L *lua.LState, caps *CapabilitySet) {
+	for _, name := range []string{"dofile", "loadfile"} {
+		fn := L.GetGlobal(name)
+		if fn == lua.LNil {
+			continue
+		}
+		if caps.Has(CapabilityFS) {
+			L.SetGlobal(name, guardPath(L, fn, caps))
+		} else {
+			L.SetGlobal(name, lua.LNil)
+		}
+	}
+}
+
+// restrictOS keeps time functions and exposes file and process functions by capability
+func restrictOS(L *lua.LState, caps *CapabilitySet) {
+	full, ok := L.GetGlobal(lua.OsLibName).(*lua.LTable)
+	if !ok {
+		return
+	}
+
+	restricted := L.NewTable()
+	copyFields(full, restricted, safeOSFuncs)
+	if caps.Has(CapabilityFS) {
+		restricted.RawSetString("remove", guardPath(L, full.RawGetString("remove"), caps))
+		restricted.RawSetString("rename", guardPaths(L, full.RawGetString("rename"), caps, 2))
+	}
+	if caps.Has(CapabilityExec) {
+		copyFields(full, restricted, []string{"execute"})
+	}
+
+	setLibrary(L, lua.OsLibName, restricted)
+}
+
+// restrictIO keeps stdio access and exposes file and pipe functions by capability
+func restrictIO(L *lua.LState, caps *CapabilitySet) {
+	full, ok := L.GetGlobal(lua.IoLibName).(*lua.LTable)
+	if !ok {
+		return
+	}
+
+	restricted := L.NewTable()
+	copyFields(full, restricted, safeIOFuncs)
+	if caps.Has(CapabilityFS) {
+		restricted.RawSetString("open", guardPath(L, full.RawGetString("open"), caps))
+		restricted.RawSetString("lines", guardPath(L, full.RawGetString("lines"), caps))
+	}
+	if caps.Has(CapabilityExec) {
+		copyFields(full, restricted, []string{"popen"})
+	}
+
+	setLibrary(L, lua.IoLibName, restricted)
+}
+
+// guardPath wraps fn so that its first argument must be a permitted path
+func guardPath(L *lua.LState, fn lua.LValue, caps *CapabilitySet) *lua.LFunction {
+	return guardPaths(L, fn, caps, 1)
+}
+
+// guardPaths wraps fn so that its first n arguments must be permitted paths
+func guardPaths(L *lua.LState, fn lua.LValue, caps *CapabilitySet, n int) *lua.LFunction {
+	return L.NewFunction(func(L *lua.LState) int {
+		for i := 1; i <= n; i++ {
+			path := L.CheckString(i)
+			if !caps.AllowPath(path) {
+				L.RaiseError("capability fs:%s not granted", path)
+				return 0
+			}
+		}
+
+		top := L.GetTop()
+		L.Push(fn)
+		for i := 1; i <= top; i++ {
+			L.Push(L.Get(i))
+		}
+		L.Call(top, lua.MultRet)
+		return L.GetTop() - top
+	})
+}
+
+func copyFields(from, to *lua.LTable, names []string) {
+	for _, name := range names {
+		if value := from.RawGetString(name); value != lua.LNil {
+			to.RawSetString(name, value)
+		}
+	}
+}
+
+// setLibrary replaces a library table both as a global and in package.loaded
+func setLibrary(L *lua.LState, name string, table *lua.LTable) {
+	L.SetGlobal(name, table)
+	if loaded, ok := L.GetField(L.Get(lua.RegistryIndex), "_LOADED").(*lua.LTable); ok {
+		loaded.RawSetString(name, table)
+	}
+}
@@ -0,0 +1,324 @@
+package scripting
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ibrahmsql/gocat/internal/logger"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const taskTypeName = "gocat.task"
+
+// taskTrampoline runs a task body one call level down. gopher-lua loses the
+// results of a Go function that yields from a tail call in the coroutine's
+// outermost frame (return conn:read()), so task functions never run there.
+const taskTrampoline = `
+local select, unpack = select, unpack
+if not select or not unpack then
+    return nil
+end
+local function pack(...)
+    return {n = select("#", ...), ...}
+end
+return function(fn, ...)
+    local results = pack(fn(...))
+    return unpack(results, 1, results.n)
+end
+`
+
+// asyncResult converts the outcome of a Go-side operation into Lua values.
+// It always runs on the scheduler's thread, never on the I/O goroutine.
+type asyncResult func(L *lua.LState) []lua.LValue
+
+// scheduler runs coroutines spawned with gocat.spawn. Coroutines execute one
+// at a time on the engine's Lua state; blocking API calls made from a task are
+// handed to a goroutine and the task is suspended until the result arrives, so
+// network I/O of many tasks proceeds concurrently.
+type scheduler struct {
+	tasks   map[*lua.LState]*task
+	ready   []*task
+	blocked int
+	done    chan completion
+	closed  chan struct{}
+}
+
+// task is a single coroutine managed by the scheduler
+type task struct {
+	id        int
+	co        *lua.LState
+	cancelCtx context.CancelFunc
+	fn        *lua.LFunction
+	args      []lua.LValue
+	waiting   bool
+	finished  bool
+	cancelled bool
+	results   []lua.LValue
+	err       error
+}
+
+type completion struct {
+	task   *task
+	result asyncResult
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		tasks:  make(map[*lua.LState]*task),
+		done:   make(chan completion, 64),
+		closed: make(chan struct{}),
+	}
+}
+
+// current returns the task running on L, or nil when L is not a spawned coroutine
+func (s *scheduler) current(L *lua.LState) *task {
+	return s.tasks[L]
+}
+
+// pending reports whether any task still has work to do
+func (s *scheduler) pending() bool {
+	return len(s.ready) > 0 || s.blocked > 0
+}
+
+// async runs op without blocking other tasks. Called from a spawned task the
+// coroutine yields and is resumed with the op's results; anywhere else op runs
+// synchronously. op must not touch the Lua state.
+func (e *LuaEngine) async(L *lua.LState, op func() asyncResult) int {
+	t := e.sched.current(L)
+	if t == nil {
+		e.budget.pause()
+		result := op()
+		e.budget.resume()
+		values := result(L)
+		for _, v := range values {
+			L.Push(v)
+		}
+		return len(values)
+	}
+
+	t.waiting = true
+	e.sched.blocked++
+	go func(s *scheduler) {
+		select {
+		case s.done <- completion{task: t, result: op()}:
+		case <-s.closed:
+		}
+	}(e.sched)
+	return L.Yield()
+}
+
+// runScheduler resumes tasks from L until until() reports true or no task has work left
+func (e *LuaEngine) runScheduler(ctx context.Context, L *lua.LState, until func() bool) error {
+	s := e.sched
+
+	for s.pending() && !until() {
+		if len(s.ready) == 0 {
+			// Every task is blocked: waiting costs no budget
+			e.budget.pause()
+			select {
+			case c := <-s.done:
+				e.budget.resume()
+				s.blocked--
+				c.task.waiting = false
+				if !c.task.cancelled {
+					c.task.args = c.result(L)
+					s.ready = append(s.ready, c.task)
+				}
+			case <-ctx.Done():
+				e.budget.resume()
+				return ctx.Err()
+			}
+			continue
+		}
+
+		t := s.ready[0]
+		s.ready = s.ready[1:]
+		if t.cancelled {
+			continue
+		}
+
+		state, err, values := L.Resume(t.co, t.fn, t.args...)
+		t.args = nil
+
+		switch {
+		case err != nil:
+			t.err = err
+			e.finishTask(t)
+			logger.Error("[Script] task %d failed: %v", t.id, err)
+		case state == lua.ResumeOK:
+			t.results = values
+			e.finishTask(t)
+		case !t.waiting:
+			// Plain coroutine.yield from a task: give the others a turn
+			s.ready = append(s.ready, t)
+		}
+	}
+
+	return nil
+}
+
+// close stops delivering results to the engine; pending I/O goroutines exit
+func (s *scheduler) close() {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
+func (e *LuaEngine) finishTask(t *task) {
+	t.finished = true
+	delete(e.sched.tasks, t.co)
+	if t.cancelCtx != nil {
+		t.cancelCtx()
+	}
+}
+
+// drainTasks runs remaining spawned tasks after the main chunk returns
+func (e *LuaEngine) drainTasks(ctx context.Context) error {
+	if !e.sched.pending() {
+		return nil
+	}
+	return e.runScheduler(ctx, e.L, func() bool { return false })
+}
+
+// registerScheduler adds gocat.spawn and gocat.wait and the task type
+func (e *LuaEngine) registerScheduler(gocatTable *lua.LTable) {
+	mt := e.L.NewTypeMetatable(taskTypeName)
+	e.L.SetField(mt, "__index", e.L.SetFuncs(e.L.NewTable(), map[string]lua.LGFunction{
+		"done":   taskDone,
+		"result": taskResult,
+		"cancel": e.taskCancel,
+		"wait":   e.luaWait,
+	}))
+
+	if fn, err := e.L.LoadString(taskTrampoline); err == nil {
+		e.L.Push(fn)
+		e.L.Call(0, 1)
+		e.trampoline, _ = e.L.Get(-1).(*lua.LFunction)
+		e.L.Pop(1)
+	}
+
+	gocatTable.RawSetString("spawn", e.L.NewFunction(e.luaSpawn))
+	gocatTable.RawSetString("wait", e.L.NewFunction(e.luaWait))
+}
+
+// spawnTask creates a task for fn and queues it
+func (e *LuaEngine) spawnTask(L *lua.LState, fn *lua.LFunction, args []lua.LValue) *lua.LUserData {
+	co, cancel := L.NewThread()
+	e.taskCounter++
+	if e.trampoline != nil {
+		args = append([]lua.LValue{fn}, args...)
+		fn = e.trampoline
+	}
+	t := &task{
+		id:        e.taskCounter,
+		co:        co,
+		cancelCtx: cancel,
+		fn:        fn,
+		args:      args,
+	}
+	e.sched.tasks[co] = t
+	e.sched.ready = append(e.sched.ready, t)
+
+	ud := L.NewUserData()
+	ud.Value = t
+	L.SetMetatable(ud, L.GetTypeMetatable(taskTypeName))
+	return ud
+}
+
+// luaSpawn implements gocat.spawn(fn, ...); returns a task handle
+func (e *LuaEngine) luaSpawn(L *lua.LState) int {
+	fn := L.CheckFunction(1)
+	var args []lua.LValue
+	for i := 2; i <= L.GetTop(); i++ {
+		args = append(args, L.Get(i))
+	}
+
+	L.Push(e.spawnTask(L, fn, args))
+	return 1
+}
+
+// luaWait implements gocat.wait([task]) and task:wait(). Without a task it runs
+// the scheduler until every task has finished; with a task it returns that
+// task's results, or nil and the error it failed with.
+func (e *LuaEngine) luaWait(L *lua.LState) int {
+	if e.sched.current(L) != nil {
+		L.RaiseError("gocat.wait cannot be called from a spawned task")
+		return 0
+	}
+
+	var target *task
+	if L.GetTop() >= 1 && L.Get(1) != lua.LNil {
+		target = checkTask(L, 1)
+	}
+
+	until := func() bool { return false }
+	if target != nil {
+		until = func() bool { return target.finished || target.cancelled }
+	}
+
+	if err := e.runScheduler(scriptContext(L), L, until); err != nil {
+		L.RaiseError("%v", err)
+		return 0
+	}
+
+	if target == nil {
+		return 0
+	}
+	return pushTaskResult(L, target)
+}
+
+// checkTask extracts the task handle at argument n
+func checkTask(L *lua.LState, n int) *task {
+	ud := L.CheckUserData(n)
+	if t, ok := ud.Value.(*task); ok {
+		return t
+	}
+	L.ArgError(n, "task expected")
+	return nil
+}
+
+// taskDone implements task:done()
+func taskDone(L *lua.LState) int {
+	t := checkTask(L, 1)
+	L.Push(lua.LBool(t.finished || t.cancelled))
+	return 1
+}
+
+// taskResult implements task:result(); returns the task's results or nil, err
+func taskResult(L *lua.LState) int {
+	return pushTaskResult(L, checkTask(L, 1))
+}
+
+// taskCancel implements task:cancel(); the task is never resumed again
+func (e *LuaEngine) taskCancel(L *lua.LState) int {
+	t := checkTask(L, 1)
+	if !t.finished && !t.cancelled {
+		t.cancelled = true
+		e.finishTask(t)
+	}
+	return 0
+}
+
+func pushTaskResult(L *lua.LState, t *task) int {
+	switch {
+	case t.err != nil:
+		L.Push(lua.LNil)
+		L.Push(lua.LString(t.err.Error()))
+		return 2
+	case t.cancelled:
+		L.Push(lua.LNil)
+		L.Push(lua.LString("task cancelled"))
+		return 2
+	case !t.finished:
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("task %d still running", t.id)))
+		return 2
+	}
+
+	for _, v := range t.results {
+		L.Push(v)
+	}
+	return len(t.results)
+}
@@ -0,0 +1,372 @@
+package scripting
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	maxJSONDepth     = 64
+	maxRegexCacheLen = 128
+)
+
+// timerPrelude builds gocat.timer.after and gocat.timer.every on top of spawn and sleep
+const timerPrelude = `
+local spawn, sleep = ...
+
+local function after(seconds, fn, ...)
+    local args = {...}
+    return spawn(function()
+        sleep(seconds)
+        return fn(unpack(args))
+    end)
+end
+
+local function every(seconds, fn, ...)
+    local args = {...}
+    return spawn(function()
+        while true do
+            sleep(seconds)
+            if fn(unpack(args)) == false then
+                return
+            end
+        end
+    end)
+end
+
+return after, every
+`
+
+// registerStdlib registers the gocat.* modules on the gocat table
+func (e *LuaEngine) registerStdlib(gocatTable *lua.LTable) {
+	L := e.L
+
+	gocatTable.RawSetString("json", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"encode": luaJSONEncode,
+		"decode": luaJSONDecode,
+	}))
+
+	gocatTable.RawSetString("re", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"test":     e.luaReTest,
+		"match":    e.luaReMatch,
+		"find":     e.luaReFind,
+		"find_all": e.luaReFindAll,
+		"gsub":     e.luaReGsub,
+		"split":    e.luaReSplit,
+	}))
+
+	timer := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"now":   luaTimerNow,
+		"sleep": e.luaSleep,
+	})
+	if fn, err := L.LoadString(timerPrelude); err == nil {
+		L.Push(fn)
+		L.Push(gocatTable.RawGetString("spawn"))
+		L.Push(timer.RawGetString("sleep"))
+		L.Call(2, 2)
+		timer.RawSetString("after", L.Get(-2))
+		timer.RawSetString("every", L.Get(-1))
+		L.Pop(2)
+	}
+	gocatTable.RawSetString("timer", timer)
+
+	e.registerNetStdlib(gocatTable)
+	e.registerPoolType(gocatTable)
+}
+
+// JSON
+
+// luaJSONEncode implements gocat.json.encode(value[, pretty]); returns string or nil, err
+func luaJSONEncode(L *lua.LState) int {
+	value, err := luaToGo(L.CheckAny(1), 0)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	var data []byte
+	if L.OptBool(2, false) {
+		data, err = json.MarshalIndent(value, "", "  ")
+	} else {
+		data, err = json.Marshal(value)
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LString(string(data)))
+	return 1
+}
+
+// luaJSONDecode implements gocat.json.decode(string); returns value or nil, err
+func luaJSONDecode(L *lua.LState) int {
+	var value interface{}
+	if err := json.Unmarshal([]byte(L.CheckString(1)), &value); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(goToLua(L, value))
+	return 1
+}
+
+// luaToGo converts a Lua value into a JSON-encodable Go value. Tables with
+// keys 1..n become arrays, all other tables become objects.
+func luaToGo(value lua.LValue, depth int) (interface{}, error) {
+	if depth > maxJSONDepth {
+		return nil, fmt.Errorf("nesting too deep or table contains a cycle")
+	}
+
+	switch v := value.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		f := float64(v)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("cannot encode %v", f)
+		}
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f), nil
+		}
+		return f, nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		if n := v.MaxN(); n > 0 && tableLen(v) == n {
+			array := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				item, err := luaToGo(v.RawGetInt(i), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, item)
+			}
+			return array, nil
+		}
+
+		object := make(map[string]interface{})
+		var err error
+		v.ForEach(func(key, val lua.LValue) {
+			if err != nil {
+				return
+			}
+			var item interface{}
+			item, err = luaToGo(val, depth+1)
+			object[key.String()] = item
+		})
+		return object, err
+	}
+
+	return nil, fmt.Errorf("cannot encode value of type %s", value.Type())
+}
+
+// goToLua converts a decoded JSON value into a Lua value
+func goToLua(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for i, item := range v {
+			t.RawSetInt(i+1, goToLua(L, item))
+		}
+		return t
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			t.RawSetString(key, goToLua(L, v[key]))
+		}
+		return t
+	}
+	return lua.LString(fmt.Sprint(value))
+}
+
+func tableLen(t *lua.LTable) int {
+	count := 0
+	t.ForEach(func(lua.LValue, lua.LValue) { count++ })
+	return count
+}
+
+// Regular expressions (Go RE2 syntax)
+
+// compileRegex returns a cached compiled pattern, raising a Lua error on bad syntax
+func (e *LuaEngine) compileRegex(L *lua.LState, pattern string) *regexp.Regexp {
+	if re, ok := e.regexCache[pattern]; ok {
+		return re
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		L.RaiseError("invalid regular expression: %v", err)
+		return nil
+	}
+
+	if len(e.regexCache) >= maxRegexCacheLen {
+		e.regexCache = make(map[string]*regexp.Regexp)
+	}
+	e.regexCache[pattern] = re
+	return re
+}
+
+// luaReTest implements gocat.re.test(s, pattern); returns true if pattern matches
+func (e *LuaEngine) luaReTest(L *lua.LState) int {
+	s := L.CheckString(1)
+	re := e.compileRegex(L, L.CheckString(2))
+	L.Push(lua.LBool(re.MatchString(s)))
+	return 1
+}
+
+// luaReMatch implements gocat.re.match(s, pattern); like string.match it returns
+// the captures, or the whole match when the pattern has no groups, or nil
+func (e *LuaEngine) luaReMatch(L *lua.LState) int {
+	s := L.CheckString(1)
+	re := e.compileRegex(L, L.CheckString(2))
+
+	match := re.FindStringSubmatch(s)
+	if match == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	if len(match) == 1 {
+		L.Push(lua.LString(match[0]))
+		return 1
+	}
+	for _, group := range match[1:] {
+		L.Push(lua.LString(group))
+	}
+	return len(match) - 1
+}
+
+// luaReFind implements gocat.re.find(s, pattern); returns 1-based start and end or nil
+func (e *LuaEngine) luaReFind(L *lua.LState) int {
+	s := L.CheckString(1)
+	re := e.compileRegex(L, L.CheckString(2))
+
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(lua.LNumber(loc[0] + 1))
+	L.Push(lua.LNumber(loc[1]))
+	return 2
+}
+
+// luaReFindAll implements gocat.re.find_all(s, pattern[, n]); returns a table of matches
+func (e *LuaEngine) luaReFindAll(L *lua.LState) int {
+	s := L.CheckString(1)
+	re := e.compileRegex(L, L.CheckString(2))
+	n := L.OptInt(3, -1)
+
+	result := L.NewTable()
+	for _, match := range re.FindAllString(s, n) {
+		result.Append(lua.LString(match))
+	}
+	L.Push(result)
+	return 1
+}
+
+// luaReGsub implements gocat.re.gsub(s, pattern, repl). repl is either a string
+// using $1-style group references or a function called with the match and its
+// groups. Returns the new string and the number of replacements.
+func (e *LuaEngine) luaReGsub(L *lua.LState) int {
+	s := L.CheckString(1)
+	re := e.compileRegex(L, L.CheckString(2))
+	count := 0
+
+	var result string
+	switch repl := L.CheckAny(3).(type) {
+	case *lua.LFunction:
+		// Groups come from the match in s, so assertions see its context
+		var b strings.Builder
+		last := 0
+		for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+			count++
+			match := s[loc[0]:loc[1]]
+			L.Push(repl)
+			for i := 0; i < len(loc); i += 2 {
+				if loc[i] < 0 {
+					L.Push(lua.LString(""))
+				} else {
+					L.Push(lua.LString(s[loc[i]:loc[i+1]]))
+				}
+			}
+			L.Call(len(loc)/2, 1)
+			ret := L.Get(-1)
+			L.Pop(1)
+
+			b.WriteString(s[last:loc[0]])
+			if ret == lua.LNil || ret == lua.LFalse {
+				b.WriteString(match)
+			} else {
+				b.WriteString(ret.String())
+			}
+			last = loc[1]
+		}
+		b.WriteString(s[last:])
+		result = b.String()
+	default:
+		template := lua.LVAsString(repl)
+		count = len(re.FindAllStringIndex(s, -1))
+		result = re.ReplaceAllString(s, template)
+	}
+
+	L.Push(lua.LString(result))
+	L.Push(lua.LNumber(count))
+	return 2
+}
+
+// luaReSplit implements gocat.re.split(s, pattern[, n]); returns a table of parts
+func (e *LuaEngine) luaReSplit(L *lua.LState) int {
+	s := L.CheckString(1)
+	re := e.compileRegex(L, L.CheckString(2))
+	n := L.OptInt(3, -1)
+
+	result := L.NewTable()
+	for _, part := range re.Split(s, n) {
+		result.Append(lua.LString(part))
+	}
+	L.Push(result)
+	return 1
+}
+
+// Timers
+
+// luaTimerNow implements gocat.timer.now(); returns the Unix time with sub-second precision
+func luaTimerNow(L *lua.LState) int {
+	now := time.Now()
+	L.Push(lua.LNumber(float64(now.UnixNano()) / float64(time.Second)))
+	return 1
+}
+
+// parseSeconds converts a Lua number of seconds into a duration
+func parseSeconds(value lua.LNumber) time.Duration {
+	if value <= 0 {
+		return 0
+	}
+	return time.Duration(float64(value) * float64(time.Second))
+}
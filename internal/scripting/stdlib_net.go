@@ -0,0 +1,672 @@
+package scripting
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/resolver"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	udpSocketTypeName  = "gocat.udp"
+	maxDatagramSize    = 65535
+	maxHTTPBodySize    = 16 * 1024 * 1024 // 16MB
+	defaultHTTPTimeout = 30 * time.Second
+	defaultHTTPAgent   = "GoCat-Lua"
+	maxHTTPRedirects   = 10
+)
+
+// registerNetStdlib registers gocat.http, gocat.dns, gocat.tls and gocat.udp
+func (e *LuaEngine) registerNetStdlib(gocatTable *lua.LTable) {
+	L := e.L
+
+	gocatTable.RawSetString("http", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"request": e.luaHTTPRequest,
+		"get":     e.luaHTTPGet,
+		"post":    e.luaHTTPPost,
+	}))
+
+	gocatTable.RawSetString("dns", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"lookup":  e.luaDNSLookup,
+		"query":   e.luaDNSQuery,
+		"reverse": e.luaDNSReverse,
+	}))
+
+	gocatTable.RawSetString("tls", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"connect": e.luaTLSConnect,
+		"wrap":    e.luaTLSWrap,
+	}))
+
+	mt := L.NewTypeMetatable(udpSocketTypeName)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"recvfrom":   e.udpRecvFrom,
+		"sendto":     e.udpSendTo,
+		"settimeout": udpSetTimeout,
+		"localaddr":  udpLocal,
+		"close":      e.udpClose,
+	}))
+	gocatTable.RawSetString("udp", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"connect": e.luaUDPConnect,
+		"listen":  e.luaUDPListen,
+	}))
+}
+
+// checkNet raises a Lua error unless the script may reach host:port
+func (e *LuaEngine) checkNet(L *lua.LState, host string, port int) {
+	if e.config.RestrictedMode && !e.isHostAllowed(host) {
+		L.RaiseError("host %s not allowed in restricted mode", host)
+	}
+	if !e.config.Capabilities.AllowNet(host, port) {
+		L.RaiseError("capability net:%s:%d not granted", host, port)
+	}
+}
+
+// DNSResolver answers the name lookups of scripts, as net.Resolver does
+type DNSResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// resolver returns the resolver used by gocat.dns and script connections
+func (e *LuaEngine) resolver() DNSResolver {
+	if e.config.Resolver != nil {
+		return e.config.Resolver
+	}
+	return resolver.Default()
+}
+
+// resolveAddr replaces the host of a host:port address with its first
+// address of the family network asks for
+func (e *LuaEngine) resolveAddr(ctx context.Context, network, address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host == "" || net.ParseIP(host) != nil {
+		return address, err
+	}
+
+	family := "ip"
+	if strings.HasSuffix(network, "4") || strings.HasSuffix(network, "6") {
+		family += network[len(network)-1:]
+	}
+	ips, err := e.resolver().LookupIP(ctx, family, host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// dialContext connects a script, looking the host up with the resolver
+func (e *LuaEngine) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	resolved, err := e.resolveAddr(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, resolved)
+}
+
+// dialTLS connects a script over TLS, naming the host for SNI and
+// verification
+func (e *LuaEngine) dialTLS(ctx context.Context, address string, config *tls.Config) (net.Conn, error) {
+	conn, err := e.dialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(address)
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// HTTP
+
+// httpOptions holds the fields accepted by gocat.http.request
+type httpOptions struct {
+	method    string
+	url       *url.URL
+	headers   map[string]string
+	body      string
+	timeout   time.Duration
+	verify    bool
+	redirects bool
+}
+
+// luaHTTPRequest implements gocat.http.request{url=, method=, headers=, body=, timeout=, verify=, follow_redirects=}.
+// Returns a response table {status, status_text, proto, headers, body} or nil, err.
+func (e *LuaEngine) luaHTTPRequest(L *lua.LState) int {
+	opts := L.CheckTable(1)
+
+	req := httpOptions{
+		method:    strings.ToUpper(lua.LVAsString(opts.RawGetString("method"))),
+		headers:   tableToStringMap(opts.RawGetString("headers")),
+		body:      lua.LVAsString(opts.RawGetString("body")),
+		timeout:   defaultHTTPTimeout,
+		verify:    opts.RawGetString("verify") != lua.LFalse,
+		redirects: opts.RawGetString("follow_redirects") != lua.LFalse,
+	}
+	if req.method == "" {
+		req.method = http.MethodGet
+	}
+	if timeout, ok := opts.RawGetString("timeout").(lua.LNumber); ok && timeout > 0 {
+		req.timeout = parseSeconds(timeout)
+	}
+
+	rawURL := lua.LVAsString(opts.RawGetString("url"))
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("invalid URL: %s", rawURL)))
+		return 2
+	}
+	req.url = parsed
+	host, port := urlHostPort(parsed)
+	e.checkNet(L, host, port)
+
+	return e.doHTTP(L, req)
+}
+
+// luaHTTPGet implements gocat.http.get(url[, headers])
+func (e *LuaEngine) luaHTTPGet(L *lua.LState) int {
+	opts := L.NewTable()
+	opts.RawSetString("url", lua.LString(L.CheckString(1)))
+	opts.RawSetString("headers", L.Get(2))
+	L.SetTop(0)
+	L.Push(opts)
+	return e.luaHTTPRequest(L)
+}
+
+// luaHTTPPost implements gocat.http.post(url, body[, headers])
+func (e *LuaEngine) luaHTTPPost(L *lua.LState) int {
+	opts := L.NewTable()
+	opts.RawSetString("method", lua.LString(http.MethodPost))
+	opts.RawSetString("url", lua.LString(L.CheckString(1)))
+	opts.RawSetString("body", lua.LString(L.OptString(2, "")))
+	opts.RawSetString("headers", L.Get(3))
+	L.SetTop(0)
+	L.Push(opts)
+	return e.luaHTTPRequest(L)
+}
+
+func (e *LuaEngine) doHTTP(L *lua.LState, opts httpOptions) int {
+	ctx := scriptContext(L)
+	caps := e.config.Capabilities
+	restricted := e.config.RestrictedMode
+
+	client := &http.Client{
+		Timeout: opts.timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			DialContext:     e.dialContext,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: !opts.verify}, // #nosec G402 - opt-in per request
+		},
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			if !opts.redirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= maxHTTPRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxHTTPRedirects)
+			}
+			host, port := urlHostPort(r.URL)
+			if restricted && !e.isHostAllowed(host) {
+				return fmt.Errorf("redirect to %s not allowed in restricted mode", host)
+			}
+			if !caps.AllowNet(host, port) {
+				return fmt.Errorf("redirect to %s:%d: capability not granted", host, port)
+			}
+			return nil
+		},
+	}
+
+	return e.async(L, func() asyncResult {
+		defer client.CloseIdleConnections()
+
+		req, err := http.NewRequestWithContext(ctx, opts.method, opts.url.String(), strings.NewReader(opts.body))
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		req.Header.Set("User-Agent", defaultHTTPAgent)
+		for name, value := range opts.headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBodySize))
+		if err != nil {
+			return errorResult(err.Error())
+		}
+
+		return func(L *lua.LState) []lua.LValue {
+			t := L.NewTable()
+			t.RawSetString("status", lua.LNumber(resp.StatusCode))
+			t.RawSetString("status_text", lua.LString(resp.Status))
+			t.RawSetString("proto", lua.LString(resp.Proto))
+			t.RawSetString("body", lua.LString(string(body)))
+
+			headers := L.NewTable()
+			for name, values := range resp.Header {
+				headers.RawSetString(strings.ToLower(name), lua.LString(strings.Join(values, ", ")))
+			}
+			t.RawSetString("headers", headers)
+			return []lua.LValue{t}
+		}
+	})
+}
+
+// urlHostPort returns the host and effective port of u
+func urlHostPort(u *url.URL) (string, int) {
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		port = 80
+		if u.Scheme == "https" {
+			port = 443
+		}
+	}
+	return u.Hostname(), port
+}
+
+// tableToStringMap converts a Lua table of strings into a Go map
+func tableToStringMap(value lua.LValue) map[string]string {
+	result := make(map[string]string)
+	if t, ok := value.(*lua.LTable); ok {
+		t.ForEach(func(k, v lua.LValue) {
+			result[k.String()] = v.String()
+		})
+	}
+	return result
+}
+
+// DNS
+
+// checkDNS raises a Lua error unless the script holds a network capability
+func (e *LuaEngine) checkDNS(L *lua.LState) {
+	if !e.config.Capabilities.Has(CapabilityNet) {
+		L.RaiseError("capability net not granted: DNS lookups require network access")
+	}
+}
+
+// luaDNSLookup implements gocat.dns.lookup(name); returns a table of addresses or nil, err
+func (e *LuaEngine) luaDNSLookup(L *lua.LState) int {
+	name := L.CheckString(1)
+	e.checkDNS(L)
+	ctx := scriptContext(L)
+	resolver := e.resolver()
+
+	return e.async(L, func() asyncResult {
+		addrs, err := resolver.LookupHost(ctx, name)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		return stringListResult(addrs)
+	})
+}
+
+// luaDNSReverse implements gocat.dns.reverse(ip); returns a table of names or nil, err
+func (e *LuaEngine) luaDNSReverse(L *lua.LState) int {
+	addr := L.CheckString(1)
+	e.checkDNS(L)
+	ctx := scriptContext(L)
+	resolver := e.resolver()
+
+	return e.async(L, func() asyncResult {
+		names, err := resolver.LookupAddr(ctx, addr)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		return stringListResult(names)
+	})
+}
+
+// luaDNSQuery implements gocat.dns.query(name[, type]) for A, AAAA, CNAME, MX, NS,
+// TXT, PTR and SRV records. MX and SRV records are returned as tables.
+func (e *LuaEngine) luaDNSQuery(L *lua.LState) int {
+	name := L.CheckString(1)
+	qtype := strings.ToUpper(L.OptString(2, "A"))
+	e.checkDNS(L)
+	ctx := scriptContext(L)
+	resolver := e.resolver()
+
+	return e.async(L, func() asyncResult {
+		switch qtype {
+		case "A", "AAAA":
+			network := "ip4"
+			if qtype == "AAAA" {
+				network = "ip6"
+			}
+			ips, err := resolver.LookupIP(ctx, network, name)
+			if err != nil {
+				return errorResult(err.Error())
+			}
+			addrs := make([]string, len(ips))
+			for i, ip := range ips {
+				addrs[i] = ip.String()
+			}
+			return stringListResult(addrs)
+		case "CNAME":
+			cname, err := resolver.LookupCNAME(ctx, name)
+			if err != nil {
+				return errorResult(err.Error())
+			}
+			return stringListResult([]string{cname})
+		case "NS":
+			records, err := resolver.LookupNS(ctx, name)
+			if err != nil {
+				return errorResult(err.Error())
+			}
+			hosts := make([]string, len(records))
+			for i, ns := range records {
+				hosts[i] = ns.Host
+			}
+			return stringListResult(hosts)
+		case "TXT":
+			records, err := resolver.LookupTXT(ctx, name)
+			if err != nil {
+				return errorResult(err.Error())
+			}
+			return stringListResult(records)
+		case "PTR":
+			names, err := resolver.LookupAddr(ctx, name)
+			if err != nil {
+				return errorResult(err.Error())
+			}
+			return stringListResult(names)
+		case "MX":
+			records, err := resolver.LookupMX(ctx, name)
+			if err != nil {
+				return errorResult(err.Error())
+			}
+			return func(L *lua.LState) []lua.LValue {
+				t := L.NewTable()
+				for _, mx := range records {
+					rec := L.NewTable()
+					rec.RawSetString("host", lua.LString(mx.Host))
+					rec.RawSetString("pref", lua.LNumber(mx.Pref))
+					t.Append(rec)
+				}
+				return []lua.LValue{t}
+			}
+		case "SRV":
+			_, records, err := resolver.LookupSRV(ctx, "", "", name)
+			if err != nil {
+				return errorResult(err.Error())
+			}
+			return func(L *lua.LState) []lua.LValue {
+				t := L.NewTable()
+				for _, srv := range records {
+					rec := L.NewTable()
+					rec.RawSetString("target", lua.LString(srv.Target))
+					rec.RawSetString("port", lua.LNumber(srv.Port))
+					rec.RawSetString("priority", lua.LNumber(srv.Priority))
+					rec.RawSetString("weight", lua.LNumber(srv.Weight))
+					t.Append(rec)
+				}
+				return []lua.LValue{t}
+			}
+		}
+		return errorResult("unsupported record type: " + qtype)
+	})
+}
+
+func stringListResult(items []string) asyncResult {
+	return func(L *lua.LState) []lua.LValue {
+		t := L.CreateTable(len(items), 0)
+		for _, item := range items {
+			t.Append(lua.LString(item))
+		}
+		return []lua.LValue{t}
+	}
+}
+
+// TLS
+
+// tlsConfigFromTable builds a client TLS configuration from the options table
+// {verify=false, server_name=, alpn={...}}
+func tlsConfigFromTable(value lua.LValue, host string) *tls.Config {
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true, // #nosec G402 - scripts opt in to verification
+	}
+
+	opts, ok := value.(*lua.LTable)
+	if !ok {
+		return config
+	}
+	if opts.RawGetString("verify") == lua.LTrue {
+		config.InsecureSkipVerify = false
+	}
+	if name := lua.LVAsString(opts.RawGetString("server_name")); name != "" {
+		config.ServerName = name
+	}
+	if alpn, ok := opts.RawGetString("alpn").(*lua.LTable); ok {
+		alpn.ForEach(func(_, v lua.LValue) {
+			config.NextProtos = append(config.NextProtos, v.String())
+		})
+	}
+	return config
+}
+
+// luaTLSConnect implements gocat.tls.connect(host, port[, opts]); returns conn or nil, err
+func (e *LuaEngine) luaTLSConnect(L *lua.LState) int {
+	host := L.CheckString(1)
+	port := L.CheckInt(2)
+	config := tlsConfigFromTable(L.Get(3), host)
+	e.checkNet(L, host, port)
+
+	ctx := scriptContext(L)
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	return e.async(L, func() asyncResult {
+		dialCtx, cancel := context.WithTimeout(ctx, defaultConnectionTimeout)
+		defer cancel()
+
+		conn, err := e.dialTLS(dialCtx, address, config)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		return connResult(e.trackConn(newLuaConn(conn)))
+	})
+}
+
+// luaTLSWrap implements gocat.tls.wrap(conn[, opts]) for STARTTLS style upgrades.
+// Returns a new conn; the original must not be used afterwards.
+func (e *LuaEngine) luaTLSWrap(L *lua.LState) int {
+	lc := checkConn(L)
+	host, _, _ := net.SplitHostPort(lc.conn.RemoteAddr().String())
+	config := tlsConfigFromTable(L.Get(2), host)
+	ctx := scriptContext(L)
+
+	return e.async(L, func() asyncResult {
+		handshakeCtx, cancel := context.WithTimeout(ctx, defaultConnectionTimeout)
+		defer cancel()
+
+		// Hand bytes already buffered by the plain reader over to TLS
+		tlsConn := tls.Client(&bufferedConn{Conn: lc.conn, reader: lc.reader}, config)
+		if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+			return errorResult(err.Error())
+		}
+		return connResult(e.trackConn(newLuaConn(tlsConn)))
+	})
+}
+
+// bufferedConn reads through a bufio.Reader that may hold data already read from Conn
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func connResult(lc *luaConn) asyncResult {
+	return func(L *lua.LState) []lua.LValue {
+		return []lua.LValue{newConnValue(L, lc)}
+	}
+}
+
+// UDP
+
+// luaUDPConnect implements gocat.udp.connect(host, port); returns a conn whose
+// read() returns one datagram
+func (e *LuaEngine) luaUDPConnect(L *lua.LState) int {
+	host := L.CheckString(1)
+	port := L.CheckInt(2)
+	e.checkNet(L, host, port)
+
+	ctx := scriptContext(L)
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	return e.async(L, func() asyncResult {
+		dialCtx, cancel := context.WithTimeout(ctx, defaultConnectionTimeout)
+		defer cancel()
+		conn, err := e.dialContext(dialCtx, "udp", address)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		lc := newLuaConn(conn)
+		lc.datagram = true
+		return connResult(e.trackConn(lc))
+	})
+}
+
+// luaUDPListen implements gocat.udp.listen([host, ]port); returns a socket or nil, err
+func (e *LuaEngine) luaUDPListen(L *lua.LState) int {
+	host := ""
+	if L.GetTop() >= 2 {
+		host = L.CheckString(1)
+		L.Remove(1)
+	}
+	port := L.CheckInt(1)
+	e.checkNet(L, "*", port)
+
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	s := &udpSocket{conn: conn}
+	e.track(s, func() { conn.Close() })
+
+	ud := L.NewUserData()
+	ud.Value = s
+	L.SetMetatable(ud, L.GetTypeMetatable(udpSocketTypeName))
+	L.Push(ud)
+	return 1
+}
+
+// udpSocket is an unconnected UDP socket exposed to scripts
+type udpSocket struct {
+	conn    net.PacketConn
+	timeout time.Duration
+}
+
+func checkUDPSocket(L *lua.LState) *udpSocket {
+	ud := L.CheckUserData(1)
+	if s, ok := ud.Value.(*udpSocket); ok {
+		return s
+	}
+	L.ArgError(1, "udp socket expected")
+	return nil
+}
+
+// udpRecvFrom implements sock:recvfrom([size]); returns data, host, port or nil, err
+func (e *LuaEngine) udpRecvFrom(L *lua.LState) int {
+	s := checkUDPSocket(L)
+	size := L.OptInt(2, maxDatagramSize)
+	if size <= 0 || size > maxDatagramSize {
+		size = maxDatagramSize
+	}
+
+	return e.async(L, func() asyncResult {
+		if s.timeout > 0 {
+			_ = s.conn.SetReadDeadline(time.Now().Add(s.timeout))
+		}
+		buffer := make([]byte, size)
+		n, addr, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			return errorResult(readErrorString(err))
+		}
+
+		host, portStr, _ := net.SplitHostPort(addr.String())
+		port, _ := strconv.Atoi(portStr)
+		return func(L *lua.LState) []lua.LValue {
+			return []lua.LValue{lua.LString(string(buffer[:n])), lua.LString(host), lua.LNumber(port)}
+		}
+	})
+}
+
+// udpSendTo implements sock:sendto(data, host, port); returns bytes sent or nil, err
+func (e *LuaEngine) udpSendTo(L *lua.LState) int {
+	s := checkUDPSocket(L)
+	data := L.CheckString(2)
+	host := L.CheckString(3)
+	port := L.CheckInt(4)
+	e.checkNet(L, host, port)
+
+	return e.async(L, func() asyncResult {
+		address, err := e.resolveAddr(context.Background(), "udp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		addr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		n, err := s.conn.WriteTo([]byte(data), addr)
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		return numberResult(float64(n))
+	})
+}
+
+// udpSetTimeout implements sock:settimeout(seconds)
+func udpSetTimeout(L *lua.LState) int {
+	s := checkUDPSocket(L)
+	s.timeout = parseSeconds(L.CheckNumber(2))
+	if s.timeout == 0 {
+		_ = s.conn.SetReadDeadline(time.Time{})
+	}
+	return 0
+}
+
+// udpLocal implements sock:localaddr(); returns the bound host and port
+func udpLocal(L *lua.LState) int {
+	return pushAddr(L, checkUDPSocket(L).conn.LocalAddr())
+}
+
+// udpClose implements sock:close()
+func (e *LuaEngine) udpClose(L *lua.LState) int {
+	s := checkUDPSocket(L)
+	_ = s.conn.Close()
+	e.untrack(s)
+	return 0
+}
@@ -0,0 +1,405 @@
+package scripting
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/resolver"
+)
+
+// newStdlibEngine returns an engine with the given capabilities approved
+func newStdlibEngine(t *testing.T, capabilities ...string) *LuaEngine {
+	t.Helper()
+
+	config := DefaultEngineConfig()
+	if len(capabilities) > 0 {
+		var declared []Capability
+		for _, spec := range capabilities {
+			c, err := ParseCapability(spec)
+			if err != nil {
+				t.Fatalf("ParseCapability(%s) failed: %v", spec, err)
+			}
+			declared = append(declared, c)
+		}
+		caps, err := ApproveCapabilities(declared, []string{"all"})
+		if err != nil {
+			t.Fatalf("ApproveCapabilities failed: %v", err)
+		}
+		config.Capabilities = caps
+	}
+
+	engine := NewLuaEngine(config)
+	if engine == nil {
+		t.Fatal("NewLuaEngine returned nil")
+	}
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+// runScript writes source to a file and runs it through LoadScript
+func runScript(t *testing.T, engine *LuaEngine, source string) error {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script.lua")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	return engine.LoadScript(path)
+}
+
+func TestStdlibBuildInfo(t *testing.T) {
+	SetVersion("1.2.3")
+	defer SetVersion("dev")
+
+	engine := newStdlibEngine(t)
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	if err := engine.L.DoString(`assert(gocat.version == "1.2.3", gocat.version)
+assert(gocat.platform == "` + platform + `", gocat.platform)`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStdlibJSON(t *testing.T) {
+	engine := newStdlibEngine(t)
+
+	script := `
+local s = gocat.json.encode({name = "gocat", ports = {80, 443}, tls = true})
+assert(s == '{"name":"gocat","ports":[80,443],"tls":true}', s)
+
+local v = assert(gocat.json.decode('{"a": [1, 2.5, null, "x"], "b": {"c": false}}'))
+assert(v.a[1] == 1 and v.a[2] == 2.5 and v.a[3] == nil and v.a[4] == "x")
+assert(v.b.c == false)
+
+local bad, err = gocat.json.decode("{")
+assert(bad == nil and err ~= nil)
+
+local t = {}
+t.self = t
+local cyc, err2 = gocat.json.encode(t)
+assert(cyc == nil and err2:find("too deep"), err2)
+`
+	if err := engine.L.DoString(script); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStdlibRegex(t *testing.T) {
+	engine := newStdlibEngine(t)
+
+	script := `
+local re = gocat.re
+assert(re.test("HTTP/1.1 200 OK", [[^HTTP/\d\.\d (\d+)]]))
+assert(re.match("HTTP/1.1 404 Not Found", [[(\d{3}) (.*)$]]) == "404")
+local code, reason = re.match("HTTP/1.1 404 Not Found", [[(\d{3}) (.*)$]])
+assert(reason == "Not Found")
+assert(re.match("abc", "x") == nil)
+
+local s, e = re.find("hello world", "wor")
+assert(s == 7 and e == 9)
+
+local all = re.find_all("a1 b22 c333", [[\d+]])
+assert(#all == 3 and all[3] == "333")
+
+local out, n = re.gsub("k1=v1 k2=v2", [[(\w+)=(\w+)]], "$2=$1")
+assert(out == "v1=k1 v2=k2" and n == 2, out)
+local up = re.gsub("abc", "[ac]", function(m) return m:upper() end)
+assert(up == "AbC", up)
+-- Groups are matched in context: \B holds for "x" in "ax" only
+local tagged, m = re.gsub("ax x", [[\B(x)]], function(all, g) return "<" .. all .. "|" .. g .. ">" end)
+assert(tagged == "a<x|x> x" and m == 1, tagged)
+
+local parts = re.split("a, b,c", [[,\s*]])
+assert(#parts == 3 and parts[2] == "b")
+
+assert(not pcall(re.test, "x", "("))
+`
+	if err := engine.L.DoString(script); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStdlibSpawnRunsConcurrently(t *testing.T) {
+	engine := newStdlibEngine(t)
+
+	start := time.Now()
+	err := runScript(t, engine, `
+local results = {}
+local tasks = {}
+for i = 1, 3 do
+    tasks[i] = gocat.spawn(function(n)
+        gocat.timer.sleep(0.2)
+        results[#results + 1] = n
+        return n * 10
+    end, i)
+end
+gocat.wait()
+assert(#results == 3)
+assert(tasks[2]:done())
+assert(tasks[2]:result() == 20)
+`)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Tasks did not sleep concurrently: %v", elapsed)
+	}
+}
+
+func TestStdlibTaskErrorsAndCancel(t *testing.T) {
+	engine := newStdlibEngine(t)
+
+	err := runScript(t, engine, `
+local failing = gocat.spawn(function() error("boom") end)
+local v, err = failing:wait()
+assert(v == nil and err:find("boom"), err)
+
+local slow = gocat.spawn(function() gocat.timer.sleep(5) end)
+slow:cancel()
+local _, cerr = slow:result()
+assert(cerr == "task cancelled")
+
+local fired = false
+gocat.timer.after(0.05, function() fired = true end)
+gocat.wait()
+assert(fired)
+
+local count = 0
+gocat.timer.every(0.01, function()
+    count = count + 1
+    return count < 3
+end)
+gocat.wait()
+assert(count == 3, count)
+`)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+}
+
+func TestStdlibUDPEcho(t *testing.T) {
+	engine := newStdlibEngine(t, "net:127.0.0.1", "net:*")
+
+	err := runScript(t, engine, `
+local server = assert(gocat.udp.listen("127.0.0.1", 0))
+server:settimeout(2)
+local _, port = server:localaddr()
+
+gocat.spawn(function()
+    local data, host, from = server:recvfrom()
+    server:sendto(data:upper(), host, from)
+end)
+
+local client = assert(gocat.udp.connect("127.0.0.1", port))
+client:settimeout(2)
+client:write("ping")
+local reply = gocat.spawn(function() return client:read() end)
+assert(reply:wait() == "PING")
+client:close()
+server:close()
+`)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+}
+
+// slowResolver answers every lookup with 127.0.0.1 after a delay
+type slowResolver struct{ DNSResolver }
+
+func (slowResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	time.Sleep(100 * time.Millisecond)
+	return []net.IP{net.ParseIP("127.0.0.1")}, nil
+}
+
+// openFDs counts the file descriptors of the test process
+func openFDs(t *testing.T) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("no /proc/self/fd")
+	}
+	return len(fds)
+}
+
+func TestStdlibCloseReleasesSockets(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	before := openFDs(t)
+	engine := newStdlibEngine(t, "net")
+	engine.config.Resolver = slowResolver{}
+
+	// Sockets left open, and a connect whose task is cancelled before it
+	// completes
+	port := ln.Addr().(*net.TCPAddr).Port
+	err = runScript(t, engine, fmt.Sprintf(`
+local tcp = assert(connect("127.0.0.1", %[1]d))
+local udp = assert(gocat.udp.connect("127.0.0.1", %[1]d))
+local sock = assert(gocat.udp.listen("127.0.0.1", 0))
+local pending = gocat.spawn(function() return connect("slow.test", %[1]d) end)
+gocat.spawn(function() end):wait()
+pending:cancel()
+`, port))
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	engine.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for openFDs(t) > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d fds open after Close, %d before the script", openFDs(t), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStdlibPoolReusesConnections(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// Echo each line, counting the connections
+	accepted := make(chan struct{}, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- struct{}{}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 64)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					conn.Write(buf[:n])
+				}
+			}()
+		}
+	}()
+
+	engine := newStdlibEngine(t, "net:127.0.0.1")
+	err = runScript(t, engine, `
+local pool = gocat.pool("`+ln.Addr().String()+`")
+for i = 1, 3 do
+    local conn = assert(pool:get())
+    conn:settimeout(2)
+    conn:write("ping " .. i .. "\n")
+    assert(conn:readline() == "ping " .. i)
+    assert(pool:stats().active == 1)
+    assert(pool:put(conn))
+end
+local stats = pool:stats()
+assert(stats.idle == 1 and stats.active == 0, stats.idle)
+assert(stats.circuit == "closed", stats.circuit)
+`)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	if len(accepted) != 1 {
+		t.Errorf("%d connections for three gets, want 1", len(accepted))
+	}
+}
+
+func TestStdlibHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		fmt.Fprintf(w, "hello %s", r.URL.Query().Get("name"))
+	}))
+	defer server.Close()
+
+	engine := newStdlibEngine(t, "net:127.0.0.1")
+
+	err := runScript(t, engine, `
+local resp = assert(gocat.http.get("`+server.URL+`/?name=lua"))
+assert(resp.status == 200, resp.status)
+assert(resp.body == "hello lua", resp.body)
+assert(resp.headers["x-method"] == "GET")
+
+local post = assert(gocat.http.post("`+server.URL+`", "data"))
+assert(post.headers["x-method"] == "POST")
+`)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+}
+
+func TestStdlibDNSUsesResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "resolved")
+	}))
+	defer server.Close()
+
+	r, err := resolver.New(resolver.Config{
+		Overrides: map[string][]net.IP{"svc.test": {net.ParseIP("127.0.0.1")}},
+		NoDNS:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := newStdlibEngine(t, "net")
+	engine.config.Resolver = r
+
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+	err = runScript(t, engine, fmt.Sprintf(`
+local addrs = assert(gocat.dns.lookup("svc.test"))
+assert(#addrs == 1 and addrs[1] == "127.0.0.1", addrs[1])
+
+local none, err = gocat.dns.lookup("example.com")
+assert(none == nil and err:find("disabled"), err)
+
+local resp = assert(gocat.http.get("http://svc.test:%d/"))
+assert(resp.body == "resolved", resp.body)
+`, port))
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+}
+
+func TestStdlibCapabilityDenied(t *testing.T) {
+	engine := newStdlibEngine(t)
+
+	for _, call := range []string{
+		`gocat.http.get("http://127.0.0.1:1/")`,
+		`gocat.udp.connect("127.0.0.1", 53)`,
+		`gocat.udp.listen(0)`,
+		`gocat.dns.lookup("localhost")`,
+		`gocat.tls.connect("127.0.0.1", 443)`,
+		`gocat.pool("127.0.0.1:80")`,
+	} {
+		err := engine.L.DoString(call)
+		if err == nil || !strings.Contains(err.Error(), "not granted") {
+			t.Errorf("%s: expected capability error, got %v", call, err)
+		}
+	}
+}
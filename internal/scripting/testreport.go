@@ -0,0 +1,175 @@
+package scripting
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Test report formats
+const (
+	ReportTAP   = "tap"
+	ReportJUnit = "junit"
+)
+
+// WriteTestReport writes suites in the given format
+func WriteTestReport(w io.Writer, format string, suites []TestSuite) error {
+	switch strings.ToLower(format) {
+	case ReportTAP, "":
+		return WriteTAP(w, suites)
+	case ReportJUnit, "xml":
+		return WriteJUnit(w, suites)
+	}
+	return fmt.Errorf("unknown report format %q (supported: tap, junit)", format)
+}
+
+// WriteTAP writes suites as a TAP version 13 stream. Failure details and the
+// output of failed tests are attached as YAML diagnostics.
+func WriteTAP(w io.Writer, suites []TestSuite) error {
+	total := 0
+	for _, suite := range suites {
+		total += len(suite.Tests)
+	}
+
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", total)
+
+	n := 0
+	for _, suite := range suites {
+		fmt.Fprintf(&b, "# %s\n", suite.File)
+		for _, t := range suite.Tests {
+			n++
+			name := tapEscape(t.Name)
+			switch t.Status {
+			case TestPassed:
+				fmt.Fprintf(&b, "ok %d - %s\n", n, name)
+			case TestSkipped:
+				fmt.Fprintf(&b, "ok %d - %s # SKIP %s\n", n, name, tapEscape(t.Message))
+			default:
+				fmt.Fprintf(&b, "not ok %d - %s\n", n, name)
+				b.WriteString("  ---\n")
+				writeYAMLField(&b, "message", t.Message)
+				writeYAMLField(&b, "file", suite.File)
+				fmt.Fprintf(&b, "  duration_ms: %d\n", t.Duration.Milliseconds())
+				if t.Output != "" {
+					writeYAMLField(&b, "output", t.Output)
+				}
+				b.WriteString("  ...\n")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tapEscape keeps a description on one line and escapes TAP directives
+func tapEscape(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "#", "\\#")
+}
+
+func writeYAMLField(b *strings.Builder, key, value string) {
+	value = strings.TrimRight(value, "\n")
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "  %s: %q\n", key, value)
+		return
+	}
+	fmt.Fprintf(b, "  %s: |\n", key)
+	for _, line := range strings.Split(value, "\n") {
+		fmt.Fprintf(b, "    %s\n", line)
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes suites as JUnit XML, one <testsuite> per test file
+func WriteJUnit(w io.Writer, suites []TestSuite) error {
+	report := junitTestSuites{}
+	var total float64
+
+	for _, suite := range suites {
+		js := junitTestSuite{
+			Name:     suite.File,
+			Tests:    len(suite.Tests),
+			Failures: suite.Count(TestFailed),
+			Skipped:  suite.Count(TestSkipped),
+			Time:     fmt.Sprintf("%.3f", suite.Duration.Seconds()),
+		}
+		className := strings.TrimSuffix(filepath.ToSlash(suite.File), ".lua")
+
+		for _, t := range suite.Tests {
+			tc := junitTestCase{
+				Name:      t.Name,
+				ClassName: className,
+				Time:      fmt.Sprintf("%.3f", t.Duration.Seconds()),
+				SystemOut: t.Output,
+			}
+			switch t.Status {
+			case TestFailed:
+				tc.Failure = &junitMessage{Message: firstLine(t.Message), Body: t.Message}
+			case TestSkipped:
+				tc.Skipped = &junitMessage{Message: t.Message}
+			}
+			js.Cases = append(js.Cases, tc)
+		}
+
+		report.Tests += js.Tests
+		report.Failures += js.Failures
+		report.Skipped += js.Skipped
+		total += suite.Duration.Seconds()
+		report.Suites = append(report.Suites, js)
+	}
+	report.Time = fmt.Sprintf("%.3f", total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
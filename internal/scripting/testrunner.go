@@ -0,0 +1,675 @@
+package scripting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// TestFileSuffix marks Lua files discovered by the test runner
+const TestFileSuffix = "_test.lua"
+
+// loopbackCapabilities are granted to every test file so scripts can reach mock servers
+var loopbackCapabilities = []string{"net:127.0.0.1", "net:[::1]", "net:localhost"}
+
+// testPrelude defines the assert and expect helpers available to test files.
+// It receives gocat.re and returns the assert table, which remains callable
+// like the builtin, and the expect function.
+const testPrelude = `
+local re = ...
+local error, pcall, pairs, ipairs, type, tostring, format = error, pcall, pairs, ipairs, type, tostring, string.format
+local table = table
+
+local function repr(v, depth)
+    if type(v) == "string" then
+        return format("%q", v)
+    end
+    if type(v) ~= "table" or table == nil then
+        return tostring(v)
+    end
+    depth = depth or 0
+    if depth > 2 then
+        return "{...}"
+    end
+
+    local parts, n = {}, #v
+    for i = 1, n do
+        parts[#parts + 1] = repr(v[i], depth + 1)
+    end
+    local keys = {}
+    for k in pairs(v) do
+        if type(k) ~= "number" or k < 1 or k > n or k % 1 ~= 0 then
+            keys[#keys + 1] = k
+        end
+    end
+    table.sort(keys, function(a, b) return tostring(a) < tostring(b) end)
+    for _, k in ipairs(keys) do
+        parts[#parts + 1] = format("[%s] = %s", repr(k, depth + 1), repr(v[k], depth + 1))
+    end
+    return "{" .. table.concat(parts, ", ") .. "}"
+end
+
+local function deep_equal(a, b, seen)
+    if a == b then
+        return true
+    end
+    if type(a) ~= "table" or type(b) ~= "table" then
+        return false
+    end
+    seen = seen or {}
+    if seen[a] == b then
+        return true
+    end
+    seen[a] = b
+    for k, v in pairs(a) do
+        if not deep_equal(v, b[k], seen) then
+            return false
+        end
+    end
+    for k in pairs(b) do
+        if a[k] == nil then
+            return false
+        end
+    end
+    return true
+end
+
+local function contains(haystack, needle)
+    if type(haystack) == "string" then
+        return haystack:find(tostring(needle), 1, true) ~= nil
+    end
+    if type(haystack) == "table" then
+        for _, v in pairs(haystack) do
+            if deep_equal(v, needle) then
+                return true
+            end
+        end
+    end
+    return false
+end
+
+-- fail reports the failure at the line of the test that called the helper.
+-- gopher-lua counts error() itself as level 1.
+local function fail(msg)
+    error(msg, 4)
+end
+
+local A = {}
+
+function A.equal(actual, expected, msg)
+    if actual ~= expected then
+        fail(msg or format("expected %s, got %s", repr(expected), repr(actual)))
+    end
+end
+
+function A.not_equal(actual, unexpected, msg)
+    if actual == unexpected then
+        fail(msg or format("expected value other than %s", repr(unexpected)))
+    end
+end
+
+function A.same(actual, expected, msg)
+    if not deep_equal(actual, expected) then
+        fail(msg or format("expected tables to be equal, got %s", repr(actual)))
+    end
+end
+
+function A.is_true(value, msg)
+    if value ~= true then
+        fail(msg or format("expected true, got %s", repr(value)))
+    end
+end
+
+function A.is_false(value, msg)
+    if value ~= false then
+        fail(msg or format("expected false, got %s", repr(value)))
+    end
+end
+
+function A.is_nil(value, msg)
+    if value ~= nil then
+        fail(msg or format("expected nil, got %s", repr(value)))
+    end
+end
+
+function A.not_nil(value, msg)
+    if value == nil then
+        fail(msg or "expected a value, got nil")
+    end
+end
+
+function A.matches(s, pattern, msg)
+    if type(s) ~= "string" or not re.test(s, pattern) then
+        fail(msg or format("expected %s to match /%s/", repr(s), pattern))
+    end
+end
+
+function A.contains(haystack, needle, msg)
+    if not contains(haystack, needle) then
+        fail(msg or format("expected %s to contain %s", repr(haystack), repr(needle)))
+    end
+end
+
+function A.fails(fn, pattern, msg)
+    local ok, err = pcall(fn)
+    if ok then
+        fail(msg or "expected function to fail")
+    end
+    if pattern and not re.test(tostring(err), pattern) then
+        fail(msg or format("expected error matching /%s/, got %s", pattern, repr(err)))
+    end
+    return err
+end
+
+setmetatable(A, {__call = function(_, value, msg, ...)
+    if not value then
+        fail(msg or "assertion failed!")
+    end
+    return value, msg, ...
+end})
+
+local Expect = {}
+
+local function expectation(value, negate)
+    return setmetatable({value = value, negate = negate}, Expect)
+end
+
+Expect.__index = function(self, key)
+    if key == "never" then
+        return expectation(self.value, not self.negate)
+    end
+    return Expect[key]
+end
+
+-- check is tail-called by the matchers, so level 3 is the test itself
+local function check(self, ok, msg, negated_msg)
+    if self.negate then
+        ok, msg = not ok, negated_msg
+    end
+    if not ok then
+        error(msg, 3)
+    end
+    return self
+end
+
+function Expect:to_be(expected)
+    return check(self, self.value == expected,
+        format("expected %s, got %s", repr(expected), repr(self.value)),
+        format("expected value other than %s", repr(expected)))
+end
+
+function Expect:to_equal(expected)
+    return check(self, deep_equal(self.value, expected),
+        format("expected %s to equal %s", repr(self.value), repr(expected)),
+        format("expected %s not to equal %s", repr(self.value), repr(expected)))
+end
+
+function Expect:to_be_nil()
+    return check(self, self.value == nil,
+        format("expected nil, got %s", repr(self.value)),
+        "expected a value, got nil")
+end
+
+function Expect:to_be_truthy()
+    return check(self, not not self.value,
+        format("expected truthy value, got %s", repr(self.value)),
+        format("expected falsy value, got %s", repr(self.value)))
+end
+
+function Expect:to_be_falsy()
+    return check(self, not self.value,
+        format("expected falsy value, got %s", repr(self.value)),
+        format("expected truthy value, got %s", repr(self.value)))
+end
+
+function Expect:to_be_a(typename)
+    return check(self, type(self.value) == typename,
+        format("expected %s, got %s", typename, type(self.value)),
+        format("expected value not of type %s", typename))
+end
+
+function Expect:to_contain(needle)
+    return check(self, contains(self.value, needle),
+        format("expected %s to contain %s", repr(self.value), repr(needle)),
+        format("expected %s not to contain %s", repr(self.value), repr(needle)))
+end
+
+function Expect:to_match(pattern)
+    local ok = type(self.value) == "string" and re.test(self.value, pattern)
+    return check(self, ok,
+        format("expected %s to match /%s/", repr(self.value), pattern),
+        format("expected %s not to match /%s/", repr(self.value), pattern))
+end
+
+function Expect:to_fail(pattern)
+    local ok, err = pcall(self.value)
+    local failed = not ok and (pattern == nil or re.test(tostring(err), pattern))
+    return check(self, failed,
+        ok and "expected function to fail" or format("expected error matching /%s/, got %s", tostring(pattern), repr(err)),
+        format("expected function not to fail, got %s", repr(err)))
+end
+
+local function expect(value)
+    return expectation(value, false)
+end
+
+return A, expect
+`
+
+// TestStatus is the outcome of a single test case
+type TestStatus string
+
+// Test outcomes
+const (
+	TestPassed  TestStatus = "pass"
+	TestFailed  TestStatus = "fail"
+	TestSkipped TestStatus = "skip"
+)
+
+// TestResult holds the result of one test case
+type TestResult struct {
+	Name     string
+	Status   TestStatus
+	Message  string
+	Output   string
+	Duration time.Duration
+}
+
+// TestSuite holds the results of one *_test.lua file
+type TestSuite struct {
+	File     string
+	Tests    []TestResult
+	Duration time.Duration
+}
+
+// Count returns the number of tests in the suite with the given status
+func (s TestSuite) Count(status TestStatus) int {
+	count := 0
+	for _, t := range s.Tests {
+		if t.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// TestRunner runs Lua test files
+type TestRunner struct {
+	// Approved lists capabilities approved with --allow-cap; loopback access is always granted
+	Approved []string
+	// Timeout bounds each test case and the file's top-level chunk
+	Timeout time.Duration
+	// Filter selects test cases by name; nil runs all
+	Filter *regexp.Regexp
+	// Libraries lists the standard libraries to open; nil opens DefaultLibraries
+	Libraries []string
+}
+
+// DiscoverTestScripts returns the *_test.lua files in paths. Directories are
+// searched recursively; files are used as given.
+func DiscoverTestScripts(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			add(root)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), TestFileSuffix) {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// RunFile runs the test cases declared in a single test file. A file that
+// fails to load is reported as a single failed test.
+func (r *TestRunner) RunFile(ctx context.Context, path string) TestSuite {
+	start := time.Now()
+	suite := TestSuite{File: path}
+
+	h, err := r.newHarness(path)
+	if err != nil {
+		suite.Tests = []TestResult{{Name: filepath.Base(path), Status: TestFailed, Message: err.Error()}}
+		suite.Duration = time.Since(start)
+		return suite
+	}
+	defer h.close()
+
+	if err := h.load(ctx); err != nil {
+		suite.Tests = []TestResult{{
+			Name:    filepath.Base(path),
+			Status:  TestFailed,
+			Message: err.Error(),
+			Output:  h.output.String(),
+		}}
+		suite.Duration = time.Since(start)
+		return suite
+	}
+
+	for _, tc := range h.tests {
+		if r.Filter != nil && !r.Filter.MatchString(tc.name) {
+			continue
+		}
+		suite.Tests = append(suite.Tests, h.run(ctx, tc))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	suite.Duration = time.Since(start)
+	return suite
+}
+
+// testCase is a test registered with test(name, fn)
+type testCase struct {
+	name string
+	fn   *lua.LFunction
+}
+
+// skipSignal is raised by skip(reason) to end a test without failing it
+type skipSignal struct {
+	reason string
+}
+
+// testHarness is the Lua state of one test file plus the test API registered on it
+type testHarness struct {
+	path   string
+	engine *LuaEngine
+	tests  []testCase
+	mocks  []*mockServer
+	output strings.Builder
+}
+
+func (r *TestRunner) newHarness(path string) (*testHarness, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test file: %w", err)
+	}
+
+	declared, err := ParseCapabilities(string(content))
+	if err != nil {
+		return nil, err
+	}
+	approved := append(append([]string{}, r.Approved...), loopbackCapabilities...)
+	for _, spec := range loopbackCapabilities {
+		c, err := ParseCapability(spec)
+		if err != nil {
+			return nil, err
+		}
+		declared = append(declared, c)
+	}
+	capabilities, err := ApproveCapabilities(declared, approved)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultEngineConfig()
+	config.Libraries = r.Libraries
+	config.Capabilities = capabilities
+	config.ModulePaths = []string{filepath.Dir(path)}
+	if r.Timeout > 0 {
+		config.MaxExecutionTime = r.Timeout
+	}
+
+	engine := NewLuaEngine(config)
+	if engine == nil {
+		return nil, fmt.Errorf("failed to create Lua engine")
+	}
+
+	h := &testHarness{path: path, engine: engine}
+	if err := h.register(); err != nil {
+		engine.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// register installs the test API: test, skip, include, mock, assert and
+// expect, and redirects print and log into the test's captured output
+func (h *testHarness) register() error {
+	L := h.engine.L
+
+	L.SetGlobal("test", L.NewFunction(h.luaTest))
+	L.SetGlobal("skip", L.NewFunction(luaSkip))
+	L.SetGlobal("include", L.NewFunction(h.luaInclude))
+	L.SetGlobal("print", L.NewFunction(h.luaPrint))
+	L.SetGlobal("log", L.NewFunction(h.luaLog))
+
+	registerMockType(L)
+	L.SetGlobal("mock", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"tcp":       h.luaMockTCP,
+		"udp":       h.luaMockUDP,
+		"free_port": luaFreePort,
+	}))
+
+	gocatTable, ok := L.GetGlobal("gocat").(*lua.LTable)
+	if !ok {
+		return fmt.Errorf("gocat module not available")
+	}
+	fn, err := L.LoadString(testPrelude)
+	if err != nil {
+		return fmt.Errorf("failed to load test helpers: %w", err)
+	}
+	L.Push(fn)
+	L.Push(gocatTable.RawGetString("re"))
+	if err := L.PCall(1, 2, nil); err != nil {
+		return fmt.Errorf("failed to load test helpers: %w", err)
+	}
+	L.SetGlobal("assert", L.Get(-2))
+	L.SetGlobal("expect", L.Get(-1))
+	L.Pop(2)
+	return nil
+}
+
+// load runs the test file's top-level chunk, which registers the test cases
+func (h *testHarness) load(ctx context.Context) error {
+	e := h.engine
+	fn, err := e.L.LoadFile(h.path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := e.withBudget(ctx)
+	defer cancel()
+	e.L.Push(fn)
+	if err := e.L.PCall(0, 0, nil); err != nil {
+		return e.budgetError(ctx, errors.New(luaErrorMessage(err)))
+	}
+	if err := e.drainTasks(ctx); err != nil {
+		return e.budgetError(ctx, err)
+	}
+	if len(h.tests) == 0 {
+		return fmt.Errorf("no tests declared; use test(name, fn)")
+	}
+	return nil
+}
+
+// run executes a single test case, closing the mocks it created afterwards
+func (h *testHarness) run(ctx context.Context, tc testCase) TestResult {
+	e := h.engine
+	result := TestResult{Name: tc.name, Status: TestPassed}
+	start := time.Now()
+	h.output.Reset()
+	mocks := len(h.mocks)
+
+	ctx, cancel := e.withBudget(ctx)
+	e.L.Push(tc.fn)
+	err := e.L.PCall(0, 0, nil)
+	if err == nil {
+		err = e.drainTasks(ctx)
+	}
+	if err != nil {
+		err = e.budgetError(ctx, err)
+	}
+	cancel()
+	e.L.SetTop(0)
+
+	for _, m := range h.mocks[mocks:] {
+		m.close()
+	}
+	h.mocks = h.mocks[:mocks]
+
+	if err != nil {
+		result.Status = TestFailed
+		result.Message = luaErrorMessage(err)
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			if ud, ok := apiErr.Object.(*lua.LUserData); ok {
+				if skip, ok := ud.Value.(skipSignal); ok {
+					result.Status = TestSkipped
+					result.Message = skip.reason
+				}
+			}
+		}
+	}
+
+	result.Output = h.output.String()
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (h *testHarness) close() {
+	for _, m := range h.mocks {
+		m.close()
+	}
+	h.engine.Close()
+}
+
+// luaErrorMessage returns the Lua error without gopher-lua's stack traceback
+func luaErrorMessage(err error) string {
+	var apiErr *lua.ApiError
+	if errors.As(err, &apiErr) && apiErr.Object != nil {
+		return apiErr.Object.String()
+	}
+	return err.Error()
+}
+
+// luaTest implements test(name, fn)
+func (h *testHarness) luaTest(L *lua.LState) int {
+	name := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	for _, tc := range h.tests {
+		if tc.name == name {
+			L.RaiseError("duplicate test name %q", name)
+		}
+	}
+	h.tests = append(h.tests, testCase{name: name, fn: fn})
+	return 0
+}
+
+// luaSkip implements skip([reason]); ends the running test as skipped
+func luaSkip(L *lua.LState) int {
+	ud := L.NewUserData()
+	ud.Value = skipSignal{reason: L.OptString(1, "")}
+	L.Error(ud, 0)
+	return 0
+}
+
+// luaInclude implements include(path); runs another Lua file in the test's
+// state, resolving relative paths from the test file's directory
+func (h *testHarness) luaInclude(L *lua.LState) int {
+	path := L.CheckString(1)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(h.path), path)
+	}
+
+	top := L.GetTop()
+	fn, err := L.LoadFile(path)
+	if err != nil {
+		L.RaiseError("include %s: %v", path, err)
+	}
+	L.Push(fn)
+	L.Call(0, lua.MultRet)
+	return L.GetTop() - top
+}
+
+// luaPrint captures print output for the test report
+func (h *testHarness) luaPrint(L *lua.LState) int {
+	parts := make([]string, 0, L.GetTop())
+	for i := 1; i <= L.GetTop(); i++ {
+		parts = append(parts, L.ToStringMeta(L.Get(i)).String())
+	}
+	h.output.WriteString(strings.Join(parts, "\t"))
+	h.output.WriteByte('\n')
+	return 0
+}
+
+// luaLog captures log(level, message) output for the test report
+func (h *testHarness) luaLog(L *lua.LState) int {
+	fmt.Fprintf(&h.output, "[%s] %s\n", strings.ToUpper(L.OptString(1, "info")), L.OptString(2, ""))
+	return 0
+}
+
+// luaMockTCP implements mock.tcp(spec); starts a fake TCP server
+func (h *testHarness) luaMockTCP(L *lua.LState) int {
+	return h.startMock(L, "tcp")
+}
+
+// luaMockUDP implements mock.udp(spec); starts a fake UDP server
+func (h *testHarness) luaMockUDP(L *lua.LState) int {
+	return h.startMock(L, "udp")
+}
+
+func (h *testHarness) startMock(L *lua.LState, proto string) int {
+	spec := mockSpec{host: "127.0.0.1"}
+	if L.GetTop() >= 1 {
+		spec = parseMockSpec(L, L.CheckTable(1))
+	}
+
+	m, err := startMockServer(proto, spec)
+	if err != nil {
+		L.RaiseError("mock.%s: %v", proto, err)
+	}
+	h.mocks = append(h.mocks, m)
+	L.Push(newMockValue(L, m))
+	return 1
+}
+
+// luaFreePort implements mock.free_port(); returns a loopback TCP port with no listener
+func luaFreePort(L *lua.LState) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		L.RaiseError("mock.free_port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	L.Push(lua.LNumber(port))
+	return 1
+}
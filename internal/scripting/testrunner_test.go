@@ -0,0 +1,296 @@
+package scripting
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, dir, name, source string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestDiscoverTestScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a_test.lua", "")
+	writeTestFile(t, dir, "sub/b_test.lua", "")
+	writeTestFile(t, dir, "probe.lua", "")
+	writeTestFile(t, dir, ".hidden/c_test.lua", "")
+	single := writeTestFile(t, t.TempDir(), "other.lua", "")
+
+	files, err := DiscoverTestScripts([]string{dir, single})
+	if err != nil {
+		t.Fatalf("DiscoverTestScripts failed: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a_test.lua"),
+		filepath.Join(dir, "sub/b_test.lua"),
+		single,
+	}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	if _, err := DiscoverTestScripts([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("Expected error for missing path")
+	}
+}
+
+func TestRunnerResults(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "results_test.lua", `
+test("passes", function()
+    assert.equal(1 + 1, 2)
+    expect("abc"):to_match("^a")
+    expect({1, {x = 2}}):to_equal({1, {x = 2}})
+    expect(nil).never:to_be_truthy()
+end)
+
+test("fails", function()
+    print("diagnostic")
+    assert.equal("got", "want")
+end)
+
+test("skips", function()
+    skip("no network")
+end)
+
+test("raises", function()
+    assert.fails(function() error("boom") end, "boom")
+    expect(function() end).never:to_fail()
+end)
+`)
+
+	runner := &TestRunner{}
+	suite := runner.RunFile(context.Background(), path)
+
+	want := map[string]TestStatus{
+		"passes": TestPassed,
+		"fails":  TestFailed,
+		"skips":  TestSkipped,
+		"raises": TestPassed,
+	}
+	if len(suite.Tests) != len(want) {
+		t.Fatalf("Expected %d results, got %+v", len(want), suite.Tests)
+	}
+	for _, result := range suite.Tests {
+		if result.Status != want[result.Name] {
+			t.Errorf("%s: expected %s, got %s (%s)", result.Name, want[result.Name], result.Status, result.Message)
+		}
+	}
+
+	failed := suite.Tests[1]
+	if !strings.Contains(failed.Message, `results_test.lua:11: expected "want", got "got"`) {
+		t.Errorf("Failure message should point at the test line: %q", failed.Message)
+	}
+	if failed.Output != "diagnostic\n" {
+		t.Errorf("Expected captured print output, got %q", failed.Output)
+	}
+	if suite.Tests[2].Message != "no network" {
+		t.Errorf("Expected skip reason, got %q", suite.Tests[2].Message)
+	}
+
+	runner.Filter = regexpMust(t, "^pass")
+	if suite := runner.RunFile(context.Background(), path); len(suite.Tests) != 1 {
+		t.Errorf("Filter should select one test, got %d", len(suite.Tests))
+	}
+}
+
+func TestRunnerLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	runner := &TestRunner{}
+
+	for name, source := range map[string]string{
+		"syntax_test.lua":  "test(",
+		"empty_test.lua":   "local x = 1",
+		"capable_test.lua": "-- Capability: exec\ntest('x', function() end)",
+	} {
+		suite := runner.RunFile(context.Background(), writeTestFile(t, dir, name, source))
+		if len(suite.Tests) != 1 || suite.Tests[0].Status != TestFailed {
+			t.Errorf("%s: expected a single failed result, got %+v", name, suite.Tests)
+		}
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "loop_test.lua", `
+test("loops", function() while true do end end)
+test("after", function() end)
+`)
+
+	runner := &TestRunner{Timeout: 100 * time.Millisecond}
+	suite := runner.RunFile(context.Background(), path)
+	if len(suite.Tests) != 2 {
+		t.Fatalf("Expected 2 results, got %+v", suite.Tests)
+	}
+	if suite.Tests[0].Status != TestFailed || !strings.Contains(suite.Tests[0].Message, "execution time limit") {
+		t.Errorf("Expected timeout failure, got %+v", suite.Tests[0])
+	}
+	if suite.Tests[1].Status != TestPassed {
+		t.Errorf("Test after a timeout should still run, got %+v", suite.Tests[1])
+	}
+}
+
+func TestMockServers(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "probe.lua", `
+function greet(host, port)
+    local conn = assert(connect(host, port, "tcp"))
+    local banner = conn:readline()
+    conn:write("HELO gocat\r\n")
+    local reply = conn:readline()
+    conn:close()
+    return banner, reply
+end
+`)
+	path := writeTestFile(t, dir, "mock_test.lua", `
+include("probe.lua")
+
+test("tcp steps", function()
+    local srv = mock.tcp{banner = "220 ready\r\n", steps = {
+        {expect = "HELO", send = "250 hi\r\n"},
+    }}
+    local banner, reply = greet(srv.host, srv.port)
+    assert.equal(banner, "220 ready")
+    assert.equal(reply, "250 hi")
+    assert.contains(srv:received(), "HELO gocat")
+    srv:verify()
+end)
+
+test("tcp unmet step", function()
+    local srv = mock.tcp{steps = {{expect = "never sent"}}}
+    assert.fails(function() srv:verify() end, "no connections")
+end)
+
+test("tcp responses", function()
+    local srv = mock.tcp{responses = {{"^GET", "HTTP/1.0 200 OK\r\n\r\n"}}, echo = true}
+    local conn = assert(connect(srv.host, srv.port, "tcp"))
+    send(conn, "GET / HTTP/1.0\r\n\r\n")
+    assert.matches(receive(conn), "^HTTP/1.0 200")
+    send(conn, "other")
+    assert.equal(receive(conn), "other")
+    close(conn)
+end)
+
+test("udp responses", function()
+    local srv = mock.udp{responses = {["^ping"] = "pong"}}
+    local conn = assert(gocat.udp.connect(srv.host, srv.port))
+    conn:settimeout(2)
+    conn:write("ping")
+    assert.equal(conn:read(), "pong")
+    expect(srv:datagrams()):to_equal({"ping"})
+    assert.equal(srv:connections(), 1)
+end)
+
+test("loopback only", function()
+    assert.fails(function() mock.tcp{host = "0.0.0.0"} end, "loopback")
+end)
+
+test("external network denied", function()
+    local conn, err = connect("192.0.2.1", 80, "tcp")
+    assert.is_nil(conn)
+    assert.matches(err, "not granted")
+end)
+`)
+
+	suite := (&TestRunner{Timeout: 10 * time.Second}).RunFile(context.Background(), path)
+	if len(suite.Tests) != 6 {
+		t.Fatalf("Expected 6 results, got %+v", suite.Tests)
+	}
+	for _, result := range suite.Tests {
+		if result.Status != TestPassed {
+			t.Errorf("%s: %s", result.Name, result.Message)
+		}
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	suites := []TestSuite{{
+		File: "probe_test.lua",
+		Tests: []TestResult{
+			{Name: "ok case", Status: TestPassed},
+			{Name: "bad # case", Status: TestFailed, Message: "probe_test.lua:3: boom", Output: "line1\nline2\n"},
+			{Name: "later", Status: TestSkipped, Message: "offline"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteTAP(&buf, suites); err != nil {
+		t.Fatalf("WriteTAP failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"TAP version 13\n1..3\n",
+		"ok 1 - ok case\n",
+		"not ok 2 - bad \\# case\n",
+		`  message: "probe_test.lua:3: boom"`,
+		"  output: |\n    line1\n    line2\n",
+		"ok 3 - later # SKIP offline\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("TAP output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	suites := []TestSuite{{
+		File: "dir/probe_test.lua",
+		Tests: []TestResult{
+			{Name: "ok case", Status: TestPassed},
+			{Name: "bad case", Status: TestFailed, Message: "boom <tag>"},
+			{Name: "later", Status: TestSkipped, Message: "offline"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteTestReport(&buf, ReportJUnit, suites); err != nil {
+		t.Fatalf("WriteTestReport failed: %v", err)
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Report is not valid XML: %v\n%s", err, buf.String())
+	}
+	if report.Tests != 3 || report.Failures != 1 || report.Skipped != 1 {
+		t.Errorf("Unexpected totals: %+v", report)
+	}
+	cases := report.Suites[0].Cases
+	if cases[1].Failure == nil || cases[1].Failure.Message != "boom <tag>" {
+		t.Errorf("Expected failure element, got %+v", cases[1])
+	}
+	if cases[0].ClassName != "dir/probe_test" {
+		t.Errorf("Unexpected classname %q", cases[0].ClassName)
+	}
+
+	if err := WriteTestReport(&buf, "html", suites); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func regexpMust(t *testing.T, pattern string) *regexp.Regexp {
+	t.Helper()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Fatalf("Invalid pattern %q: %v", pattern, err)
+	}
+	return re
+}
@@ -2,6 +2,7 @@ package security
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"sync"
 
 	"github.com/ibrahmsql/gocat/internal/logger"
+	"github.com/ibrahmsql/gocat/internal/resolver"
 )
 
 // AccessControl manages IP-based access control
@@ -65,7 +67,7 @@ func (ac *AccessControl) AddAllowedHost(host string) error {
 	}
 
 	// Try to resolve hostname
-	ips, err := net.LookupIP(host)
+	ips, err := resolver.Default().LookupIP(context.Background(), "ip", host)
 	if err != nil {
 		return fmt.Errorf("failed to resolve hostname %s: %w", host, err)
 	}
@@ -102,7 +104,7 @@ func (ac *AccessControl) AddDeniedHost(host string) error {
 	}
 
 	// Try to resolve hostname
-	ips, err := net.LookupIP(host)
+	ips, err := resolver.Default().LookupIP(context.Background(), "ip", host)
 	if err != nil {
 		return fmt.Errorf("failed to resolve hostname %s: %w", host, err)
 	}
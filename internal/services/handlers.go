@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/buffer"
+)
+
+// maxDatagram bounds the datagrams the datagram handlers read
+const maxDatagram = 64 * 1024
+
+// streamBuffer is the buffer echo reads streams through
+const streamBuffer = 4096
+
+// chargenLine is the length of RFC 864 lines, CRLF excluded
+const chargenLine = 72
+
+// ServeEcho sends back everything read from conn; datagrams are sent back
+// whole
+func ServeEcho(conn net.Conn, datagram bool) error {
+	size := streamBuffer
+	if datagram {
+		size = maxDatagram
+	}
+	buf := buffer.Shared().Get(size)
+	defer buf.Release()
+	for {
+		n, err := conn.Read(buf.Data)
+		if n > 0 {
+			if _, werr := conn.Write(buf.Data[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			return endOfInput(err)
+		}
+	}
+}
+
+// ServeDiscard reads and drops everything read from conn
+func ServeDiscard(conn net.Conn) error {
+	_, err := io.Copy(io.Discard, conn)
+	return err
+}
+
+// ServeChargen sends RFC 864 lines: the printable ASCII characters,
+// shifted by one on every line. On a stream it sends until the peer goes
+// away; on datagrams it answers each with one line.
+func ServeChargen(conn net.Conn, datagram bool) error {
+	line := 0
+	next := func() []byte {
+		b := ChargenLine(line)
+		line++
+		return b
+	}
+	if datagram {
+		return respond(conn, next)
+	}
+	for {
+		if _, err := conn.Write(next()); err != nil {
+			return err
+		}
+	}
+}
+
+// ChargenLine returns line n of the RFC 864 pattern
+func ChargenLine(n int) []byte {
+	b := make([]byte, chargenLine+2)
+	for i := 0; i < chargenLine; i++ {
+		b[i] = byte(' ' + (n+i)%95)
+	}
+	b[chargenLine], b[chargenLine+1] = '\r', '\n'
+	return b
+}
+
+// ServeDaytime sends the time of day as RFC 867 suggests, then closes the
+// stream; on datagrams it answers each with the time
+func ServeDaytime(conn net.Conn, datagram bool) error {
+	now := func() []byte {
+		return []byte(time.Now().Format("Monday, January 2, 2006 15:04:05-MST") + "\r\n")
+	}
+	if datagram {
+		return respond(conn, now)
+	}
+	_, err := conn.Write(now())
+	return err
+}
+
+// ServeBanner sends text and closes the stream; on datagrams it answers
+// each with text
+func ServeBanner(conn net.Conn, text string, datagram bool) error {
+	if datagram {
+		return respond(conn, func() []byte { return []byte(text) })
+	}
+	_, err := io.WriteString(conn, text)
+	return err
+}
+
+// respond answers every datagram read from conn with reply()
+func respond(conn net.Conn, reply func() []byte) error {
+	buf := make([]byte, maxDatagram)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return endOfInput(err)
+		}
+		if _, err := conn.Write(reply()); err != nil {
+			return err
+		}
+	}
+}
+
+// endOfInput turns EOF, the normal end of a connection, into nil
+func endOfInput(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// FileServer returns a handler serving the files below root over HTTP, one
+// connection at a time
+func FileServer(root string) func(net.Conn) error {
+	handler := http.FileServer(http.Dir(root))
+	return func(conn net.Conn) error {
+		ln := newConnListener(conn)
+		srv := &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 30 * time.Second,
+			ConnState: func(_ net.Conn, state http.ConnState) {
+				if state == http.StateClosed || state == http.StateHijacked {
+					ln.Close()
+				}
+			},
+		}
+		if err := srv.Serve(ln); !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// connListener hands one connection to an http.Server, then waits until
+// it is closed
+type connListener struct {
+	conn      chan net.Conn
+	addr      net.Addr
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	l := &connListener{
+		conn: make(chan net.Conn, 1),
+		addr: conn.LocalAddr(),
+		done: make(chan struct{}),
+	}
+	l.conn <- conn
+	return l
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conn:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,296 @@
+// Package services describes the services of a multi-port listener: which
+// handler answers on which ports, over which protocol and under which
+// access rules and limits. Services are read from a YAML file such as
+//
+//	bind: 0.0.0.0
+//	services:
+//	  - name: ssh
+//	    ports: 22
+//	    handler: banner
+//	    banner: "SSH-2.0-OpenSSH_9.6\r\n"
+//	  - name: syslog
+//	    ports: 514
+//	    protocol: udp
+//	    handler: discard
+//	  - name: web
+//	    ports: 8000-8010
+//	    protocol: tls
+//	    cert: server.pem
+//	    key: server.key
+//	    handler: http
+//	    root: ./public
+//	    allow: [10.0.0.0/8]
+//	    max_connections: 100
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ibrahmsql/gocat/internal/scanner"
+	"github.com/ibrahmsql/gocat/internal/security"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultIdleTimeout ends UDP sessions without traffic for this long
+const DefaultIdleTimeout = 2 * time.Minute
+
+// Protocol is the protocol a service is served over
+type Protocol string
+
+// Protocols of a Service
+const (
+	TCP Protocol = "tcp"
+	UDP Protocol = "udp"
+	TLS Protocol = "tls"
+)
+
+// Network returns the network the protocol listens on
+func (p Protocol) Network() string {
+	if p == UDP {
+		return "udp"
+	}
+	return "tcp"
+}
+
+// Handler is what answers the connections of a service
+type Handler string
+
+// Handlers of a Service
+const (
+	Echo    Handler = "echo"    // sends back what it receives
+	Discard Handler = "discard" // reads and drops everything
+	Chargen Handler = "chargen" // RFC 864 character generator
+	Daytime Handler = "daytime" // RFC 867 time of day
+	Banner  Handler = "banner"  // sends a fixed text and closes
+	Exec    Handler = "exec"    // runs a command for each connection
+	Forward Handler = "forward" // relays to another address
+	Lua     Handler = "lua"     // runs a Lua connection handler
+	HTTP    Handler = "http"    // serves static files over HTTP
+)
+
+// Service is a handler served on a set of ports
+type Service struct {
+	Name     string   `yaml:"name"`
+	Ports    string   `yaml:"ports"` // ports and ranges, e.g. "80,8000-8010"
+	Bind     string   `yaml:"bind"`  // defaults to the bind of the Config
+	Protocol Protocol `yaml:"protocol"`
+	Handler  Handler  `yaml:"handler"`
+
+	// Handler settings
+	Banner    string `yaml:"banner"`     // text of banner services
+	Exec      string `yaml:"exec"`       // command of exec services, split on whitespace
+	ShExec    string `yaml:"sh_exec"`    // command of exec services, run through the shell
+	ForwardTo string `yaml:"forward_to"` // host:port forward services relay to
+	Script    string `yaml:"script"`     // Lua connection handler
+	Root      string `yaml:"root"`       // directory http services serve
+
+	// TLS certificate and key
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+
+	// Access rules, applied after the global --allow and --deny
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+
+	// Limits, per port
+	MaxConnections int           `yaml:"max_connections"` // 0 is unlimited
+	Timeout        time.Duration `yaml:"timeout"`         // lifetime of a connection, 0 is unlimited
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`    // of UDP sessions
+
+	ports []int
+	acl   *security.AccessControl
+}
+
+// Config is a set of services
+type Config struct {
+	Bind     string     `yaml:"bind"`
+	Services []*Service `yaml:"services"`
+}
+
+// Load reads and validates a YAML service file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Parse decodes and validates services from YAML. Unknown keys are errors,
+// so a misspelled setting does not go unnoticed.
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate fills in defaults and checks every service
+func (c *Config) Validate() error {
+	if c.Bind == "" {
+		c.Bind = "0.0.0.0"
+	}
+	if len(c.Services) == 0 {
+		return errors.New("no services defined")
+	}
+	for i, s := range c.Services {
+		if s == nil {
+			return fmt.Errorf("service %d is empty", i+1)
+		}
+		if s.Bind == "" {
+			s.Bind = c.Bind
+		}
+		if err := s.validate(); err != nil {
+			name := s.Name
+			if name == "" {
+				name = strconv.Itoa(i + 1)
+			}
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+	}
+	_, err := c.Endpoints()
+	return err
+}
+
+func (s *Service) validate() error {
+	if s.Protocol == "" {
+		s.Protocol = TCP
+	}
+	switch s.Protocol {
+	case TCP, UDP:
+	case TLS:
+		if s.Cert == "" || s.Key == "" {
+			return errors.New("tls needs cert and key")
+		}
+	default:
+		return fmt.Errorf("unknown protocol %q (want tcp, udp or tls)", s.Protocol)
+	}
+
+	switch s.Handler {
+	case Echo, Discard, Chargen, Daytime:
+	case Banner:
+		if s.Banner == "" {
+			return errors.New("banner handler needs banner")
+		}
+	case Exec:
+		if s.Exec == "" && s.ShExec == "" {
+			return errors.New("exec handler needs exec or sh_exec")
+		}
+	case Forward:
+		if _, _, err := net.SplitHostPort(s.ForwardTo); err != nil {
+			return fmt.Errorf("forward handler needs forward_to host:port: %v", err)
+		}
+	case Lua:
+		if s.Script == "" {
+			return errors.New("lua handler needs script")
+		}
+	case HTTP:
+		if s.Root == "" {
+			return errors.New("http handler needs root")
+		}
+		if s.Protocol == UDP {
+			return errors.New("http cannot be served over udp")
+		}
+	case "":
+		return errors.New("no handler")
+	default:
+		return fmt.Errorf("unknown handler %q", s.Handler)
+	}
+	if s.Name == "" {
+		s.Name = string(s.Handler)
+	}
+
+	ports, err := scanner.ParsePorts(s.Ports)
+	if err != nil {
+		return err
+	}
+	s.ports = ports
+
+	if s.acl, err = newAccessControl(s.Allow, s.Deny); err != nil {
+		return err
+	}
+	if s.MaxConnections < 0 || s.Timeout < 0 || s.IdleTimeout < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	if s.IdleTimeout == 0 {
+		s.IdleTimeout = DefaultIdleTimeout
+	}
+	return nil
+}
+
+// AccessControl returns the access rules of the service, nil when it has
+// none
+func (s *Service) AccessControl() *security.AccessControl {
+	return s.acl
+}
+
+func newAccessControl(allow, deny []string) (*security.AccessControl, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	acl := security.NewAccessControl()
+	for _, host := range allow {
+		if err := acl.AddAllowedHost(host); err != nil {
+			return nil, err
+		}
+	}
+	for _, host := range deny {
+		if err := acl.AddDeniedHost(host); err != nil {
+			return nil, err
+		}
+	}
+	return acl, nil
+}
+
+// Endpoint is a service on one port
+type Endpoint struct {
+	Service *Service
+	Port    int
+}
+
+// Address returns the address the endpoint listens on
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Service.Bind, strconv.Itoa(e.Port))
+}
+
+// Key identifies the socket of the endpoint: its network and address
+func (e Endpoint) Key() string {
+	return e.Service.Protocol.Network() + "/" + e.Address()
+}
+
+// Endpoints returns every port of every service, ordered by port. Two
+// services may share a port only over different networks.
+func (c *Config) Endpoints() ([]Endpoint, error) {
+	var endpoints []Endpoint
+	owner := make(map[string]*Service)
+	for _, s := range c.Services {
+		for _, port := range s.ports {
+			e := Endpoint{Service: s, Port: port}
+			if other, ok := owner[e.Key()]; ok {
+				return nil, fmt.Errorf("services %s and %s both listen on %s", other.Name, s.Name, e.Key())
+			}
+			owner[e.Key()] = s
+			endpoints = append(endpoints, e)
+		}
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool { return endpoints[i].Port < endpoints[j].Port })
+	return endpoints, nil
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`
+bind: 127.0.0.1
+services:
+  - name: web
+    ports: 8000-8002
+    protocol: tls
+    cert: c.pem
+    key: k.pem
+    handler: http
+    root: ./public
+    allow: [10.0.0.0/8]
+    timeout: 30s
+  - ports: 53
+    protocol: udp
+    handler: forward
+    forward_to: 10.0.0.53:53
+  - ports: 53
+    bind: 0.0.0.0
+    handler: banner
+    banner: "hi\r\n"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web, udp, banner := config.Services[0], config.Services[1], config.Services[2]
+	if web.Timeout != 30*time.Second || web.AccessControl() == nil || web.Bind != "127.0.0.1" {
+		t.Errorf("web = %+v", web)
+	}
+	if udp.Name != "forward" || udp.IdleTimeout != DefaultIdleTimeout || udp.AccessControl() != nil {
+		t.Errorf("udp = %+v", udp)
+	}
+	if banner.Protocol != TCP || banner.Banner != "hi\r\n" {
+		t.Errorf("banner = %+v", banner)
+	}
+
+	endpoints, err := config.Endpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range endpoints {
+		keys = append(keys, e.Key())
+	}
+	want := "udp/127.0.0.1:53 tcp/0.0.0.0:53 tcp/127.0.0.1:8000 tcp/127.0.0.1:8001 tcp/127.0.0.1:8002"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("endpoints %s\nwant      %s", got, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, input := range []string{
+		``,
+		`services: [{ports: 80}]`,
+		`services: [{ports: 80, handler: gopher}]`,
+		`services: [{ports: 80, handler: echo, protocol: sctp}]`,
+		`services: [{ports: 80, handler: echo, protocol: tls}]`,
+		`services: [{ports: 80, handler: http, root: ., protocol: udp}]`,
+		`services: [{ports: 80, handler: forward, forward_to: nowhere}]`,
+		`services: [{ports: 80, handler: exec}]`,
+		`services: [{ports: 70000, handler: echo}]`,
+		`services: [{ports: 80, handler: echo, alow: [10.0.0.1]}]`,
+		`services: [{ports: 80, handler: echo, max_connections: -1}]`,
+		`services: [{ports: 80, handler: echo}, {ports: 79-81, handler: discard}]`,
+	} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("%q was accepted", input)
+		}
+	}
+}
+
+func TestStreamHandlers(t *testing.T) {
+	serve := func(handler func(net.Conn) error) net.Conn {
+		client, server := net.Pipe()
+		go func() {
+			handler(server)
+			server.Close()
+		}()
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+
+	r := bufio.NewReader(serve(func(c net.Conn) error { return ServeChargen(c, false) }))
+	for i := 0; i < 100; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil || line != string(ChargenLine(i)) {
+			t.Fatalf("line %d = %q, %v", i, line, err)
+		}
+	}
+	if first := string(ChargenLine(0)); !strings.HasPrefix(first, ` !"#$%&`) || len(first) != 74 {
+		t.Errorf("first line = %q", first)
+	}
+
+	banner, _ := io.ReadAll(serve(func(c net.Conn) error { return ServeBanner(c, "SSH-2.0-test\r\n", false) }))
+	if string(banner) != "SSH-2.0-test\r\n" {
+		t.Errorf("banner = %q", banner)
+	}
+
+	daytime, _ := io.ReadAll(serve(func(c net.Conn) error { return ServeDaytime(c, false) }))
+	if !strings.Contains(string(daytime), time.Now().Format("2006")) || !strings.HasSuffix(string(daytime), "\r\n") {
+		t.Errorf("daytime = %q", daytime)
+	}
+}
+
+func TestDatagramHandlers(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go ServeBanner(server, "pong", true)
+
+	buf := make([]byte, 16)
+	for i := 0; i < 2; i++ {
+		go client.Write([]byte("ping"))
+		n, err := client.Read(buf)
+		if err != nil || string(buf[:n]) != "pong" {
+			t.Fatalf("reply %d = %q, %v", i, buf[:n], err)
+		}
+	}
+}
+
+func TestFileServer(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client, server := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- FileServer(root)(server) }()
+
+	go client.Write([]byte("GET /hello.txt HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("response %d %q", resp.StatusCode, body)
+	}
+	client.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("FileServer returned %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("FileServer did not return after the connection closed")
+	}
+}
@@ -0,0 +1,79 @@
+// Package testutil holds the helpers the tests of several packages share:
+// loopback servers and connections, and waiting on conditions.
+package testutil
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// Server is a server tests start on the loopback
+type Server interface {
+	Listen(addr string) error
+	Serve() error
+	Close() error
+}
+
+// StartServer listens on a free loopback port, serves in the background
+// and closes s when the test ends
+func StartServer[S Server](tb testing.TB, s S) S {
+	tb.Helper()
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		tb.Fatalf("Listen failed: %v", err)
+	}
+	go s.Serve()
+	tb.Cleanup(func() { s.Close() })
+	return s
+}
+
+// WaitFor polls cond until it holds, failing the test after two seconds
+func WaitFor(tb testing.TB, what string, cond func() bool) {
+	tb.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			tb.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TCPPair returns both ends of a loopback TCP connection, closed when the
+// test ends. A non-zero timeout sets a deadline on both ends, so a stuck
+// test fails instead of hanging.
+func TCPPair(tb testing.TB, timeout time.Duration) (client, server *net.TCPConn) {
+	tb.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	peer := <-accepted
+	if peer == nil {
+		conn.Close()
+		tb.Fatal("accept failed")
+	}
+	tb.Cleanup(func() {
+		conn.Close()
+		peer.Close()
+	})
+
+	client, server = conn.(*net.TCPConn), peer.(*net.TCPConn)
+	if timeout > 0 {
+		deadline := time.Now().Add(timeout)
+		client.SetDeadline(deadline)
+		server.SetDeadline(deadline)
+	}
+	return client, server
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/ibrahmsql/gocat/internal/buffer"
 	"github.com/ibrahmsql/gocat/internal/logger"
 )
 
@@ -116,12 +117,36 @@ func (ft *FileTransfer) ReceiveFile(reader io.Reader, outputDir string) error {
 	return nil
 }
 
+// progressChunkSize is how much a kernel copy moves between progress updates
+const progressChunkSize = 1 << 20
+
 // copyWithProgress copies data with progress indication and rate limiting
 func (ft *FileTransfer) copyWithProgress(dst io.Writer, src io.Reader, size int64, filename string) error {
-	buffer := make([]byte, ft.BufferSize)
 	var written int64
 	startTime := time.Now()
 
+	// Unthrottled file<->socket transfers use sendfile/splice on Linux
+	if ft.RateLimit == 0 && buffer.ZeroCopy(dst, src) {
+		for {
+			n, err := buffer.Shared().Copy(dst, io.LimitReader(src, progressChunkSize), ft.BufferSize)
+			written += n
+			if ft.ShowProgress && size > 0 {
+				ft.showProgress(filename, written, size)
+			}
+			if err != nil {
+				return err
+			}
+			if n < progressChunkSize {
+				break
+			}
+		}
+		ft.finishProgress(filename, written, startTime)
+		return nil
+	}
+
+	buf := buffer.Shared().Get(ft.BufferSize)
+	defer buf.Release()
+
 	for {
 		// Rate limiting
 		if ft.RateLimit > 0 {
@@ -132,7 +157,7 @@ func (ft *FileTransfer) copyWithProgress(dst io.Writer, src io.Reader, size int6
 			}
 		}
 
-		n, err := src.Read(buffer)
+		n, err := src.Read(buf.Data)
 		if err != nil {
 			if err == io.EOF {
 				break
@@ -140,7 +165,7 @@ func (ft *FileTransfer) copyWithProgress(dst io.Writer, src io.Reader, size int6
 			return err
 		}
 
-		_, err = dst.Write(buffer[:n])
+		_, err = dst.Write(buf.Data[:n])
 		if err != nil {
 			return err
 		}
@@ -153,13 +178,17 @@ func (ft *FileTransfer) copyWithProgress(dst io.Writer, src io.Reader, size int6
 		}
 	}
 
+	ft.finishProgress(filename, written, startTime)
+	return nil
+}
+
+// finishProgress ends the progress line and logs the transfer summary
+func (ft *FileTransfer) finishProgress(filename string, written int64, startTime time.Time) {
 	if ft.ShowProgress {
 		fmt.Printf("\n")
 		logger.Info("Transfer completed: %s (%d bytes in %v)",
 			filename, written, time.Since(startTime))
 	}
-
-	return nil
 }
 
 // showProgress displays transfer progress
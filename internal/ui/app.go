@@ -90,6 +90,7 @@ func RunTUI() error {
 	)
 
 	// Run the program
+	defer m.Close()
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("error running TUI: %w", err)
 	}
@@ -132,6 +133,7 @@ func RunTUIWithGracefulShutdown() error {
 	app.mu.Unlock()
 
 	// Run the program
+	defer m.Close()
 	if _, err := app.program.Run(); err != nil {
 		return fmt.Errorf("error running TUI: %w", err)
 	}
@@ -172,6 +174,7 @@ func RunTUIWithArgs(args []string) error {
 	)
 
 	// Run the program
+	defer m.Close()
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("error running TUI: %w", err)
 	}
@@ -2,60 +2,147 @@ package ui
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
-	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-// BrokerConnection represents a broker connection
-type BrokerConnection struct {
-	ID      string
-	ClientA string
-	ClientB string
-	Status  string
-	Started time.Time
-	Bytes   int64
+// Broker form fields, cycled with Tab
+const (
+	brokerFieldPort = iota
+	brokerFieldMaxConns
+	brokerFieldSocket
+	brokerFieldClients
+	brokerFieldCount
+)
+
+// BrokerState represents the broker mode state
+type BrokerState struct {
+	port     string
+	maxConns string
+	// socket is the admin socket of a broker run by another gocat process;
+	// when set, the TUI attaches to that broker instead of hosting one
+	socket  string
+	focused int
+	hub     *HubState
 }
 
 // updateBroker handles broker mode input
 func (m Model) updateBroker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	bs := m.brokerState
+
 	switch msg.String() {
-	case "q", "ctrl+c":
+	case "ctrl+c":
 		return m, tea.Quit
 
-	case "esc":
-		m.switchToMode(ModeMenu)
-		return m, nil
-
 	case "?":
 		m.switchToMode(ModeHelp)
 		return m, nil
 
-	case "s":
+	case "tab":
+		bs.focused = (bs.focused + 1) % brokerFieldCount
+		return m, nil
+
+	case "shift+tab":
+		bs.focused = (bs.focused + brokerFieldCount - 1) % brokerFieldCount
+		return m, nil
+
+	case "enter":
 		// Start/Stop broker
-		if m.listening {
-			m.listening = false
-			m.setSuccess("Broker stopped")
+		return m.toggleBroker()
+
+	case "s":
+		if bs.focused != brokerFieldSocket {
+			return m.toggleBroker()
+		}
+	}
+
+	if bs.focused == brokerFieldClients {
+		if bs.hub.Running() {
+			m.updateHubClients(bs.hub, msg)
+		}
+		return m, nil
+	}
+
+	switch bs.focused {
+	case brokerFieldPort:
+		port := editField(bs.port, msg)
+		if _, err := strconv.Atoi(port); err == nil || port == "" {
+			bs.port = port
+		}
+	case brokerFieldMaxConns:
+		maxConns := editField(bs.maxConns, msg)
+		if _, err := strconv.Atoi(maxConns); err == nil || maxConns == "" {
+			bs.maxConns = maxConns
+		}
+	case brokerFieldSocket:
+		if !bs.hub.Running() {
+			bs.socket = editText(bs.socket, msg)
+		}
+	}
+	return m, nil
+}
+
+// toggleBroker starts an in-process broker on the configured port, or
+// attaches to the broker behind the admin socket, and stops or detaches
+// from a running one
+func (m Model) toggleBroker() (tea.Model, tea.Cmd) {
+	bs := m.brokerState
+	if bs.hub.Running() {
+		_, remote := bs.hub.monitor.(*remoteHub)
+		if err := bs.hub.stop(); err != nil {
+			m.setError("Failed to stop broker: " + err.Error())
+			return m, nil
+		}
+		if remote {
+			m.setSuccess("Detached from broker")
 		} else {
-			m.listening = true
-			m.setSuccess("Broker started on port 8080")
+			m.setSuccess("Broker stopped")
 		}
 		return m, nil
+	}
+
+	if socket := strings.TrimSpace(bs.socket); socket != "" {
+		hub, err := attachHub(socket, "broker")
+		if err != nil {
+			bs.focused = brokerFieldSocket
+			m.setError("Attach failed: " + err.Error())
+			return m, nil
+		}
+		bs.focused = brokerFieldClients
+		m.setSuccess("Attached to broker at " + socket)
+		return m, bs.hub.start(hub)
+	}
 
-	case "c":
-		// Clear connections
-		m.setSuccess("All connections cleared")
+	port, err := strconv.Atoi(bs.port)
+	if err != nil || port < 1 || port > 65535 {
+		bs.focused = brokerFieldPort
+		m.setError("Please enter a port between 1 and 65535")
+		return m, nil
+	}
+	maxConns, err := strconv.Atoi(bs.maxConns)
+	if err != nil || maxConns < 2 {
+		bs.focused = brokerFieldMaxConns
+		m.setError("A broker needs room for at least 2 clients")
 		return m, nil
 	}
 
-	return m, nil
+	b, err := hostBroker(":"+bs.port, maxConns)
+	if err != nil {
+		m.setError("Broker failed: " + err.Error())
+		return m, nil
+	}
+	bs.focused = brokerFieldClients
+	m.setSuccess("Broker started on port " + bs.port)
+	return m, bs.hub.start(b)
 }
 
 // viewBroker renders the broker interface
 func (m Model) viewBroker() string {
 	var content strings.Builder
+	bs := m.brokerState
 
 	// Title
 	title := HeaderStyle.Render("🔄 Network Broker")
@@ -72,171 +159,99 @@ func (m Model) viewBroker() string {
 	content.WriteString(controls)
 	content.WriteString("\n\n")
 
-	// Active broker connections
-	connections := m.renderBrokerConnections()
-	content.WriteString(connections)
-	content.WriteString("\n\n")
+	// Connected clients
+	clients := renderHubClients(bs.hub, "Connected Clients:", bs.focused == brokerFieldClients, false)
+	content.WriteString(clients)
+	content.WriteString("\n")
+
+	// Message flow
+	flow := renderHubEvents(bs.hub, "Message Flow:")
+	content.WriteString(flow)
+	content.WriteString("\n")
 
 	// Traffic statistics
-	stats := m.renderBrokerStats()
+	stats := renderHubStats(bs.hub, "Broker Statistics:")
 	content.WriteString(stats)
 
+	if status := m.renderStatusMessage(); status != "" {
+		content.WriteString("\n\n")
+		content.WriteString(status)
+	}
+
 	return content.String()
 }
 
 // renderBrokerConfig renders broker configuration
 func (m Model) renderBrokerConfig() string {
 	var config strings.Builder
+	bs := m.brokerState
+
+	input := func(label, value string, index int) string {
+		style := BoxStyle.Width(15)
+		if index == brokerFieldSocket {
+			style = BoxStyle.Width(32)
+		}
+		if bs.focused == index {
+			style = style.BorderForeground(PrimaryColor)
+			value += "█"
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Center, InfoStyle.Width(14).Render(label), style.Render(value))
+	}
 
 	// Listen port
-	portLabel := InfoStyle.Render("Broker Port:")
-	portValue := BoxStyle.Width(15).Render("8080")
-	portRow := lipgloss.JoinHorizontal(lipgloss.Left, portLabel, "  ", portValue)
-	config.WriteString(portRow)
+	config.WriteString(input("Broker Port:", bs.port, brokerFieldPort))
 	config.WriteString("\n")
 
-	// Target host
-	targetLabel := InfoStyle.Render("Target Host:")
-	targetValue := BoxStyle.Width(25).Render("localhost:9090")
-	targetRow := lipgloss.JoinHorizontal(lipgloss.Left, targetLabel, "  ", targetValue)
-	config.WriteString(targetRow)
+	// Client limit
+	config.WriteString(input("Max Clients:", bs.maxConns, brokerFieldMaxConns))
 	config.WriteString("\n")
 
-	// Broker mode
-	modeLabel := InfoStyle.Render("Mode:")
-	modes := []string{"TCP Proxy", "HTTP Proxy", "SOCKS5"}
-	modeButtons := make([]string, len(modes))
-
-	for i, mode := range modes {
-		if i == 0 { // TCP Proxy selected by default
-			modeButtons[i] = ActiveButtonStyle.Render(mode)
-		} else {
-			modeButtons[i] = ButtonStyle.Render(mode)
-		}
+	// Admin socket of an external broker
+	config.WriteString(input("Admin Socket:", bs.socket, brokerFieldSocket))
+	if bs.socket == "" && bs.focused != brokerFieldSocket {
+		config.WriteString(MutedStyle.Render("  optional: attach to gocat broker --admin-socket"))
 	}
 
-	modeRow := lipgloss.JoinHorizontal(lipgloss.Left,
-		modeLabel, "  ",
-		lipgloss.JoinHorizontal(lipgloss.Left, modeButtons...),
-	)
-	config.WriteString(modeRow)
-
 	return config.String()
 }
 
 // renderBrokerControls renders broker control buttons
 func (m Model) renderBrokerControls() string {
 	var controls strings.Builder
+	bs := m.brokerState
 
 	// Status indicator
-	var status string
-	if m.listening {
-		status = SuccessStyle.Render("● Broker Active")
+	if bs.hub.Running() {
+		controls.WriteString(SuccessStyle.Render("● Broker Active"))
+		controls.WriteString(MutedStyle.Render(" " + bs.hub.monitor.Describe()))
 	} else {
-		status = MutedStyle.Render("● Broker Inactive")
+		controls.WriteString(MutedStyle.Render("● Broker Inactive"))
 	}
-	controls.WriteString(status)
 	controls.WriteString("\n\n")
 
 	// Control buttons
+	_, remote := bs.hub.monitor.(*remoteHub)
 	var startStopBtn string
-	if m.listening {
+	switch {
+	case remote:
+		startStopBtn = ErrorStyle.Render("[S] Detach")
+	case bs.hub.Running():
 		startStopBtn = ErrorStyle.Render("[S] Stop Broker")
-	} else {
+	case strings.TrimSpace(bs.socket) != "":
+		startStopBtn = SuccessStyle.Render("[Enter] Attach")
+	default:
 		startStopBtn = SuccessStyle.Render("[S] Start Broker")
 	}
 
-	clearBtn := WarningStyle.Render("[C] Clear Connections")
-	helpBtn := InfoStyle.Render("[?] Help")
-	backBtn := MutedStyle.Render("[Esc] Back")
-
-	buttonRow := lipgloss.JoinHorizontal(lipgloss.Left,
-		startStopBtn, "  ",
-		clearBtn, "  ",
-		helpBtn, "  ",
-		backBtn,
-	)
-	controls.WriteString(buttonRow)
-
-	return controls.String()
-}
-
-// renderBrokerConnections renders active broker connections
-func (m Model) renderBrokerConnections() string {
-	var connections strings.Builder
-
-	connTitle := InfoStyle.Render("Active Broker Connections:")
-	connections.WriteString(connTitle)
-	connections.WriteString("\n")
-
-	if m.brokerState.isListening {
-		// Display real broker connections
-		for _, conn := range m.brokerState.connections {
-			duration := time.Since(conn.Started).Round(time.Second)
-			bytesFormatted := formatBytes(conn.Bytes)
-
-			var statusStyle lipgloss.Style
-			switch conn.Status {
-			case "Active":
-				statusStyle = SuccessStyle
-			case "Idle":
-				statusStyle = WarningStyle
-			default:
-				statusStyle = MutedStyle
-			}
-
-			connInfo := fmt.Sprintf("● %s: %s ↔ %s [%s] - %s - %v",
-				conn.ID, conn.ClientA, conn.ClientB,
-				statusStyle.Render(conn.Status), bytesFormatted, duration)
-			connections.WriteString("  " + connInfo)
-			connections.WriteString("\n")
-		}
-	} else {
-		connections.WriteString(MutedStyle.Render("  No active broker connections"))
-		connections.WriteString("\n")
+	buttons := []string{startStopBtn, InfoStyle.Render("[Tab] Clients")}
+	if bs.focused == brokerFieldClients {
+		buttons = append(buttons, WarningStyle.Render("[K] Kick"), WarningStyle.Render("[M] Mute/Unmute"))
 	}
+	buttons = append(buttons, InfoStyle.Render("[?] Help"), MutedStyle.Render("[Esc] Back"))
 
-	return connections.String()
-}
-
-// renderBrokerStats renders broker statistics
-func (m Model) renderBrokerStats() string {
-	var stats strings.Builder
-
-	statsTitle := InfoStyle.Render("Broker Statistics:")
-	stats.WriteString(statsTitle)
-	stats.WriteString("\n")
-
-	if m.brokerState.isListening {
-		// Calculate real statistics
-		totalConns := len(m.brokerState.connections)
-		activeConns := 0
-		var totalBytesTransferred int64
-
-		for _, conn := range m.brokerState.connections {
-			if conn.Status == "Active" {
-				activeConns++
-			}
-			totalBytesTransferred += conn.Bytes
-		}
-
-		totalConnections := MutedStyle.Render(fmt.Sprintf("  Total connections: %d", totalConns))
-		activeConnections := SuccessStyle.Render(fmt.Sprintf("  Active connections: %d", activeConns))
-		totalBytes := MutedStyle.Render(fmt.Sprintf("  Total bytes transferred: %s", formatBytes(totalBytesTransferred)))
-		uptime := MutedStyle.Render(fmt.Sprintf("  Broker uptime: %v", time.Since(m.lastActivity).Round(time.Second)))
-
-		stats.WriteString(totalConnections)
-		stats.WriteString("\n")
-		stats.WriteString(activeConnections)
-		stats.WriteString("\n")
-		stats.WriteString(totalBytes)
-		stats.WriteString("\n")
-		stats.WriteString(uptime)
-	} else {
-		stats.WriteString(MutedStyle.Render("  No statistics available"))
-	}
+	controls.WriteString(strings.Join(buttons, "  "))
 
-	return stats.String()
+	return controls.String()
 }
 
 // formatBytes formats byte count into human readable format
@@ -1,14 +1,31 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/ibrahmsql/gocat/internal/chat"
 )
 
+// Chat form fields, cycled with Tab
+const (
+	chatFieldServer = iota
+	chatFieldNick
+	chatFieldRoom
+	chatFieldMessage
+	chatFieldClients
+	chatFieldCount
+)
+
+// maxChatMessages bounds the chat history kept in the TUI
+const maxChatMessages = 500
+
 // ChatMessage represents a chat message
 type ChatMessage struct {
 	Timestamp time.Time
@@ -19,82 +36,342 @@ type ChatMessage struct {
 
 // ChatState represents the state of the chat mode
 type ChatState struct {
-	messages    []ChatMessage
-	inputBuffer string
-	connected   bool
-	remoteHost  string
-	scrollPos   int
+	server     string
+	nick       string
+	room       string
+	input      string
+	focused    int
+	client     *chat.Client
+	connecting bool
+	gen        int
+	messages   []ChatMessage
+	scrollPos  int
+	// host monitors a chat server hosted by the TUI
+	host *HubState
+}
+
+// chatDialedMsg delivers the result of joining a chat server
+type chatDialedMsg struct {
+	gen    int
+	client *chat.Client
+	target string
+	err    error
+}
+
+// chatLineMsg delivers a line received from the chat server
+type chatLineMsg struct {
+	gen  int
+	line string
+	ok   bool
+}
+
+// waitForChatLine returns a command that delivers the next server line
+func waitForChatLine(client *chat.Client, gen int) tea.Cmd {
+	return func() tea.Msg {
+		line, ok := <-client.Messages()
+		return chatLineMsg{gen: gen, line: line, ok: ok}
+	}
 }
 
 // updateChat handles chat mode input
 func (m Model) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	cs := m.chatState
+
 	switch msg.String() {
-	case "q", "ctrl+c":
+	case "ctrl+c":
 		return m, tea.Quit
 
-	case "esc":
-		m.switchToMode(ModeMenu)
+	case "tab", "shift+tab":
+		// Cycle through fields; the client list only exists while hosting
+		delta := 1
+		if msg.String() == "shift+tab" {
+			delta = chatFieldCount - 1
+		}
+		cs.focused = (cs.focused + delta) % chatFieldCount
+		if cs.focused == chatFieldClients && !cs.host.Running() {
+			cs.focused = (cs.focused + delta) % chatFieldCount
+		}
+		return m, nil
+
+	case "ctrl+s":
+		// Host/stop a chat server on the server port
+		return m.toggleChatServer()
+
+	case "ctrl+d":
+		// Leave the chat
+		if cs.client == nil {
+			return m, nil
+		}
+		m.disconnectChat()
+		m.setSuccess("Disconnected from chat")
+		return m, nil
+
+	case "pgup":
+		cs.scrollPos += m.chatPaneHeight()
 		return m, nil
 
-	case "?":
-		m.switchToMode(ModeHelp)
+	case "pgdown":
+		cs.scrollPos = max(cs.scrollPos-m.chatPaneHeight(), 0)
 		return m, nil
+	}
 
-	case "enter":
-		// Send message
-		if strings.TrimSpace(m.input) != "" {
-			// Add real message using ChatMessage struct
-			newMessage := ChatMessage{
-				Timestamp: time.Now(),
-				Sender:    "You",
-				Message:   m.input,
-				IsLocal:   true,
-			}
-			m.chatState.messages = append(m.chatState.messages, newMessage)
-			m.input = ""
-			m.setSuccess("Message sent")
+	if cs.focused == chatFieldClients {
+		if msg.String() == "?" {
+			m.switchToMode(ModeHelp)
+		} else if cs.host.Running() {
+			m.updateHubClients(cs.host, msg)
 		}
 		return m, nil
+	}
 
-	case "backspace":
-		// Remove last character
-		if len(m.input) > 0 {
-			m.input = m.input[:len(m.input)-1]
+	if msg.String() == "enter" {
+		if cs.client != nil && cs.focused == chatFieldMessage {
+			return m.sendChatMessage()
 		}
+		return m.joinChat()
+	}
+
+	switch cs.focused {
+	case chatFieldServer:
+		cs.server = editField(cs.server, msg)
+	case chatFieldNick:
+		cs.nick = editField(cs.nick, msg)
+	case chatFieldRoom:
+		cs.room = editField(cs.room, msg)
+	case chatFieldMessage:
+		cs.input = editText(cs.input, msg)
+	}
+	return m, nil
+}
+
+// chatTarget returns the server address, defaulting the host to localhost
+func (cs *ChatState) chatTarget() (string, error) {
+	server := strings.TrimSpace(cs.server)
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		// A bare port number means a local server
+		host, port = "", server
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid server address %q", server)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// joinChat connects to the chat server as a client
+func (m Model) joinChat() (tea.Model, tea.Cmd) {
+	cs := m.chatState
+	if cs.connecting {
+		return m, nil
+	}
+	if cs.client != nil {
+		m.setError("Already connected; press Ctrl+D to leave first")
 		return m, nil
+	}
 
-	case "up":
-		// Scroll up in chat history
+	target, err := cs.chatTarget()
+	if err != nil {
+		cs.focused = chatFieldServer
+		m.setError(err.Error())
+		return m, nil
+	}
+	if strings.TrimSpace(cs.nick) == "" {
+		cs.focused = chatFieldNick
+		m.setError("Please enter a nickname")
 		return m, nil
+	}
 
-	case "down":
-		// Scroll down in chat history
+	cs.connecting = true
+	cs.gen++
+	gen, nick, room := cs.gen, cs.nick, cs.room
+	m.setSuccess("Joining chat at " + target + "...")
+	return m, func() tea.Msg {
+		client, err := chat.Dial(context.Background(), target, nick, room)
+		return chatDialedMsg{gen: gen, client: client, target: target, err: err}
+	}
+}
+
+// handleChatDialed starts reading from a newly joined chat server
+func (m Model) handleChatDialed(msg chatDialedMsg) (tea.Model, tea.Cmd) {
+	cs := m.chatState
+	if msg.gen != cs.gen {
+		if msg.client != nil {
+			msg.client.Close()
+		}
 		return m, nil
+	}
+	cs.connecting = false
+	if msg.err != nil {
+		m.setError(fmt.Sprintf("Failed to join chat at %s: %v", msg.target, msg.err))
+		return m, nil
+	}
 
-	default:
-		// Add character to input
-		if len(msg.String()) == 1 {
-			m.input += msg.String()
+	cs.client = msg.client
+	cs.nick = msg.client.Nick()
+	cs.focused = chatFieldMessage
+	cs.scrollPos = 0
+	m.setSuccess("Joined chat at " + msg.target + " as " + cs.nick)
+	return m, waitForChatLine(cs.client, cs.gen)
+}
+
+// handleChatLine records a line from the server and waits for the next one
+func (m Model) handleChatLine(msg chatLineMsg) (tea.Model, tea.Cmd) {
+	cs := m.chatState
+	if msg.gen != cs.gen || cs.client == nil {
+		return m, nil
+	}
+	if !msg.ok {
+		reason := "connection closed"
+		if err := cs.client.Err(); err != nil && !strings.Contains(err.Error(), "EOF") {
+			reason = err.Error()
+		}
+		cs.client.Close()
+		cs.client = nil
+		cs.gen++
+		m.addChatMessage(ChatMessage{Timestamp: time.Now(), Message: "*** Disconnected: " + reason + " ***"})
+		return m, nil
+	}
+
+	m.addChatMessage(parseChatLine(msg.line))
+	if nick := cs.client.Nick(); nick != "" {
+		cs.nick = nick
+	}
+	if room := cs.client.Room(); room != "" {
+		cs.room = room
+	}
+	return m, waitForChatLine(cs.client, cs.gen)
+}
+
+// parseChatLine splits "[15:04] nick: text" lines; anything else is shown as
+// a server notice
+func parseChatLine(line string) ChatMessage {
+	message := ChatMessage{Timestamp: time.Now(), Message: line}
+	if len(line) > 8 && line[0] == '[' && line[6] == ']' && line[7] == ' ' {
+		if sender, text, ok := strings.Cut(line[8:], ": "); ok && !strings.ContainsRune(sender, ' ') {
+			message.Sender = sender
+			message.Message = text
 		}
 	}
+	return message
+}
 
+// sendChatMessage sends the message input, which may be a /command
+func (m Model) sendChatMessage() (tea.Model, tea.Cmd) {
+	cs := m.chatState
+	text := strings.TrimSpace(cs.input)
+	if text == "" {
+		return m, nil
+	}
+	if err := cs.client.Send(text); err != nil {
+		m.setError("Send failed: " + err.Error())
+		return m, nil
+	}
+	cs.input = ""
+	cs.scrollPos = 0
+	m.addChatMessage(ChatMessage{Timestamp: time.Now(), Sender: cs.nick, Message: text, IsLocal: true})
 	return m, nil
 }
 
+func (m *Model) addChatMessage(msg ChatMessage) {
+	cs := m.chatState
+	cs.messages = append(cs.messages, msg)
+	if len(cs.messages) > maxChatMessages {
+		cs.messages = cs.messages[len(cs.messages)-maxChatMessages:]
+	}
+}
+
+// disconnectChat leaves the chat server
+func (m *Model) disconnectChat() {
+	cs := m.chatState
+	if cs.client != nil {
+		cs.client.Close()
+		cs.client = nil
+	}
+	cs.connecting = false
+	cs.gen++
+}
+
+// toggleChatServer hosts a chat server on the port of the server field, or
+// stops the hosted one. A server field of "unix:<path>" attaches to the chat
+// server behind that admin socket instead.
+func (m Model) toggleChatServer() (tea.Model, tea.Cmd) {
+	cs := m.chatState
+	if cs.host.Running() {
+		_, remote := cs.host.monitor.(*remoteHub)
+		if err := cs.host.stop(); err != nil {
+			m.setError("Failed to stop chat server: " + err.Error())
+			return m, nil
+		}
+		if cs.focused == chatFieldClients {
+			cs.focused = chatFieldServer
+		}
+		if remote {
+			m.setSuccess("Detached from chat server")
+		} else {
+			m.setSuccess("Chat server stopped")
+		}
+		return m, nil
+	}
+
+	if socket, ok := strings.CutPrefix(strings.TrimSpace(cs.server), "unix:"); ok {
+		hub, err := attachHub(socket, "chat")
+		if err != nil {
+			cs.focused = chatFieldServer
+			m.setError("Attach failed: " + err.Error())
+			return m, nil
+		}
+		m.setSuccess("Attached to chat server at " + socket)
+		return m, cs.host.start(hub)
+	}
+
+	target, err := cs.chatTarget()
+	if err != nil {
+		cs.focused = chatFieldServer
+		m.setError(err.Error())
+		return m, nil
+	}
+	_, port, _ := net.SplitHostPort(target)
+	room := strings.TrimSpace(cs.room)
+	if room == "" {
+		room = chat.DefaultRoom
+	}
+
+	server, err := hostChat(":"+port, room)
+	if err != nil {
+		m.setError("Chat server failed: " + err.Error())
+		return m, nil
+	}
+	m.setSuccess(fmt.Sprintf("Hosting chat room %s on port %s", room, port))
+	return m, cs.host.start(server)
+}
+
+// chatPaneHeight is the number of message lines shown
+func (m Model) chatPaneHeight() int {
+	height := m.height - 24
+	if m.chatState.host.Running() {
+		height -= hubVisibleClients
+	}
+	return max(height, 5)
+}
+
 // viewChat renders the chat interface
 func (m Model) viewChat() string {
 	var content strings.Builder
-
-	// Add GoCat banner for chat
-	banner := m.renderChatBanner()
-	content.WriteString(banner)
-	content.WriteString("\n\n")
+	cs := m.chatState
 
 	// Title with connection status
 	title := HeaderStyle.Render("💬 Chat Mode")
 	content.WriteString(title)
 	content.WriteString("\n\n")
 
+	// Server, nickname and room
+	form := m.renderChatForm()
+	content.WriteString(form)
+	content.WriteString("\n\n")
+
 	// Connection info
 	connInfo := m.renderChatConnectionInfo()
 	content.WriteString(connInfo)
@@ -103,36 +380,82 @@ func (m Model) viewChat() string {
 	// Chat messages area
 	messagesArea := m.renderChatMessages()
 	content.WriteString(messagesArea)
-	content.WriteString("\n\n")
+	content.WriteString("\n")
 
 	// Input area
 	inputArea := m.renderChatInput()
 	content.WriteString(inputArea)
 	content.WriteString("\n\n")
 
+	// Hosted server
+	if cs.host.Running() {
+		content.WriteString(renderHubClients(cs.host, "Chat Server Clients:", cs.focused == chatFieldClients, true))
+		content.WriteString("\n")
+	}
+
 	// Chat controls
 	controls := m.renderChatControls()
 	content.WriteString(controls)
 
+	if status := m.renderStatusMessage(); status != "" {
+		content.WriteString("\n\n")
+		content.WriteString(status)
+	}
+
 	return content.String()
 }
 
+// renderChatForm renders the server, nickname and room inputs
+func (m Model) renderChatForm() string {
+	cs := m.chatState
+
+	input := func(label, value, placeholder string, index int) string {
+		style := BoxStyle.Width(22)
+		if value == "" {
+			value = MutedStyle.Render(placeholder)
+		}
+		if cs.focused == index {
+			style = style.BorderForeground(PrimaryColor)
+			value += "█"
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Center, InfoStyle.Render(label), " ", style.Render(value))
+	}
+
+	server := input("Server:", cs.server, "localhost:9000", chatFieldServer)
+	nick := input("Nick:", cs.nick, "your nickname", chatFieldNick)
+	room := input("Room:", cs.room, chat.DefaultRoom, chatFieldRoom)
+
+	// Side by side when the terminal is wide enough
+	if m.width < 110 {
+		return lipgloss.JoinHorizontal(lipgloss.Center, server, "  ", nick) + "\n" + room
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Center, server, "  ", nick, "  ", room)
+}
+
 // renderChatConnectionInfo renders connection information
 func (m Model) renderChatConnectionInfo() string {
 	var info strings.Builder
+	cs := m.chatState
+
+	switch {
+	case cs.client != nil:
+		remote := InfoStyle.Render(fmt.Sprintf("Connected to %s as %s in %s", cs.client.RemoteAddr(), cs.nick, cs.room))
+		info.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, StatusConnected(), "  ", remote))
+	case cs.connecting:
+		info.WriteString(StatusConnecting())
+	default:
+		message := MutedStyle.Render("Not connected. Enter a server and nickname, then press Enter")
+		info.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, StatusDisconnected(), "  ", message))
+	}
 
-	if m.connected {
-		status := StatusConnected()
-		remote := InfoStyle.Render("Connected to: localhost:8080")
-		connInfo := lipgloss.JoinHorizontal(lipgloss.Left, status, "  ", remote)
-		info.WriteString(connInfo)
-	} else {
-		status := StatusDisconnected()
-		message := ErrorStyle.Render("Not connected to any remote host")
-		connInfo := lipgloss.JoinHorizontal(lipgloss.Left, status, "  ", message)
-		info.WriteString(connInfo)
+	if cs.host.Running() {
 		info.WriteString("\n")
-		info.WriteString(MutedStyle.Render("  Use Connect mode first to establish a connection"))
+		info.WriteString(StatusListening())
+		if _, remote := cs.host.monitor.(*remoteHub); remote {
+			info.WriteString(MutedStyle.Render(" Monitoring chat server " + cs.host.monitor.Describe()))
+		} else {
+			info.WriteString(MutedStyle.Render(" Hosting chat server " + cs.host.monitor.Describe()))
+		}
 	}
 
 	return info.String()
@@ -141,42 +464,46 @@ func (m Model) renderChatConnectionInfo() string {
 // renderChatMessages renders the chat message history
 func (m Model) renderChatMessages() string {
 	var messages strings.Builder
+	cs := m.chatState
 
 	messagesTitle := InfoStyle.Render("Messages:")
 	messages.WriteString(messagesTitle)
 	messages.WriteString("\n")
 
-	// Create a box for messages
-	messageBox := BoxStyle.Width(60).Height(10)
+	width := max(m.width-10, 40)
+	height := m.chatPaneHeight()
+	messageBox := BoxStyle.Width(width).Height(height)
 
-	if len(m.chatState.messages) == 0 {
+	if len(cs.messages) == 0 {
 		// No messages yet
-		emptyMsg := MutedStyle.Render("No messages yet. Start typing to send a message!")
-		messageContent := lipgloss.NewStyle().Padding(2).Render(emptyMsg)
-		messages.WriteString(messageBox.Render(messageContent))
-	} else {
-		// Display real chat messages
-		var messageContent strings.Builder
-		for _, msg := range m.chatState.messages {
-			timestamp := msg.Timestamp.Format("15:04")
-			var msgStyle lipgloss.Style
-			var prefix string
-
-			if msg.IsLocal {
-				msgStyle = SuccessStyle
-				prefix = "→"
-			} else {
-				msgStyle = InfoStyle
-				prefix = "←"
-			}
-
-			msgLine := fmt.Sprintf("%s [%s] %s: %s",
-				prefix, timestamp, msgStyle.Render(msg.Sender), msg.Message)
-			messageContent.WriteString(msgLine)
-			messageContent.WriteString("\n")
+		emptyMsg := MutedStyle.Render("No messages yet. Join a chat server to start talking!")
+		messages.WriteString(messageBox.Render(emptyMsg))
+		return messages.String()
+	}
+
+	lines := make([]string, 0, len(cs.messages))
+	for _, msg := range cs.messages {
+		timestamp := MutedStyle.Render(msg.Timestamp.Format("15:04"))
+		var line string
+		switch {
+		case msg.IsLocal:
+			line = fmt.Sprintf("→ %s %s: %s", timestamp, SuccessStyle.Render(msg.Sender), msg.Message)
+		case msg.Sender != "":
+			line = fmt.Sprintf("← %s %s: %s", timestamp, InfoStyle.Render(msg.Sender), msg.Message)
+		default:
+			line = fmt.Sprintf("  %s %s", timestamp, WarningStyle.Render(msg.Message))
 		}
+		lines = append(lines, strings.Split(line, "\n")...)
+	}
 
-		messages.WriteString(messageBox.Render(messageContent.String()))
+	// Show the newest lines that fit, offset by the scroll position
+	scroll := min(cs.scrollPos, max(len(lines)-height, 0))
+	end := len(lines) - scroll
+	start := max(end-height, 0)
+	messages.WriteString(messageBox.Render(strings.Join(lines[start:end], "\n")))
+	if scroll > 0 {
+		messages.WriteString("\n")
+		messages.WriteString(MutedStyle.Render(fmt.Sprintf("  scrolled back %d lines (PgDn to return)", scroll)))
 	}
 
 	return messages.String()
@@ -184,97 +511,52 @@ func (m Model) renderChatMessages() string {
 
 // renderChatInput renders the message input area
 func (m Model) renderChatInput() string {
-	var input strings.Builder
+	cs := m.chatState
 
-	inputLabel := InfoStyle.Render("Message:")
-	input.WriteString(inputLabel)
-	input.WriteString("\n")
-
-	// Input box with current text
-	inputText := m.input
+	inputText := cs.input
 	if inputText == "" {
-		inputText = MutedStyle.Render("Type your message here...")
+		inputText = MutedStyle.Render("Type a message or /help...")
 	}
 
-	// Add cursor
-	if len(m.input) > 0 {
-		inputText = m.input + "█"
+	style := BoxStyle.Width(max(m.width-10, 40))
+	if cs.focused == chatFieldMessage {
+		style = style.BorderForeground(PrimaryColor)
+		if cs.input != "" {
+			inputText = cs.input + "█"
+		}
 	}
 
-	inputBox := BoxStyle.Width(60).Render(inputText)
-	input.WriteString(inputBox)
-
-	return input.String()
+	return style.Render(inputText)
 }
 
-// renderChatControls renders chat control buttons and help
+// renderChatControls renders chat control buttons
 func (m Model) renderChatControls() string {
-	var controls strings.Builder
-
-	// Control buttons
-	sendBtn := SuccessStyle.Render("[Enter] Send Message")
-	clearBtn := WarningStyle.Render("[Ctrl+L] Clear Chat")
-	scrollBtn := InfoStyle.Render("[↑↓] Scroll History")
-	helpBtn := InfoStyle.Render("[?] Help")
-	backBtn := MutedStyle.Render("[Esc] Back to Menu")
-
-	buttonRow := lipgloss.JoinHorizontal(lipgloss.Left,
-		sendBtn, "  ",
-		clearBtn, "  ",
-		scrollBtn, "  ",
-		helpBtn, "  ",
-		backBtn,
-	)
-	controls.WriteString(buttonRow)
-	controls.WriteString("\n\n")
+	cs := m.chatState
 
-	// Chat statistics
-	stats := m.renderChatStats()
-	controls.WriteString(stats)
-
-	return controls.String()
-}
-
-// renderChatStats renders chat statistics
-func (m Model) renderChatStats() string {
-	var stats strings.Builder
-
-	statsTitle := MutedStyle.Render("Session Stats:")
-	stats.WriteString(statsTitle)
-	stats.WriteString("\n")
-
-	// Calculate real statistics
-	messageCount := MutedStyle.Render(fmt.Sprintf("  Messages sent: %d", len(m.chatState.messages)))
-
-	// Calculate bytes transferred (approximate)
-	var totalBytes int64
-	for _, msg := range m.chatState.messages {
-		totalBytes += int64(len(msg.Message) + len(msg.Sender) + 20) // +20 for timestamp and formatting
+	var buttons []string
+	if cs.client != nil {
+		buttons = append(buttons, SuccessStyle.Render("[Enter] Send"), WarningStyle.Render("[Ctrl+D] Leave"))
+	} else {
+		buttons = append(buttons, SuccessStyle.Render("[Enter] Join"))
 	}
-	bytesTransferred := MutedStyle.Render(fmt.Sprintf("  Bytes transferred: %d B", totalBytes))
-
-	// Calculate session time
-	sessionDuration := time.Since(m.lastActivity).Round(time.Second)
-	sessionTime := MutedStyle.Render(fmt.Sprintf("  Session time: %v", sessionDuration))
-
-	stats.WriteString(messageCount)
-	stats.WriteString("\n")
-	stats.WriteString(bytesTransferred)
-	stats.WriteString("\n")
-	stats.WriteString(sessionTime)
-
-	return stats.String()
-}
+	if _, remote := cs.host.monitor.(*remoteHub); remote {
+		buttons = append(buttons, ErrorStyle.Render("[Ctrl+S] Detach"))
+		if cs.focused == chatFieldClients {
+			buttons = append(buttons, WarningStyle.Render("[K] Kick"), WarningStyle.Render("[M] Mute/Unmute"))
+		}
+	} else if cs.host.Running() {
+		buttons = append(buttons, ErrorStyle.Render("[Ctrl+S] Stop Server"))
+		if cs.focused == chatFieldClients {
+			buttons = append(buttons, WarningStyle.Render("[K] Kick"), WarningStyle.Render("[M] Mute/Unmute"))
+		}
+	} else {
+		buttons = append(buttons, InfoStyle.Render("[Ctrl+S] Host Server"))
+	}
+	buttons = append(buttons,
+		InfoStyle.Render("[Tab] Next Field"),
+		InfoStyle.Render("[PgUp/PgDn] Scroll"),
+		MutedStyle.Render("[Esc] Back"),
+	)
 
-// renderChatBanner renders the GoCat banner for chat mode
-func (m Model) renderChatBanner() string {
-	logo := `
-  ██████╗  ██████╗  ██████╗ █████╗ ████████╗
- ██╔════╝ ██╔═══██╗██╔════╝██╔══██╗╚══██╔══╝
- ██║  ███╗██║   ██║██║     ███████║   ██║   
- ██║   ██║██║   ██║██║     ██╔══██║   ██║   
- ╚██████╔╝╚██████╔╝╚██████╗██║  ██║   ██║   
-  ╚═════╝  ╚═════╝  ╚═════╝╚═╝  ╚═╝   ╚═╝   
-`
-	return TitleStyle.Render(logo)
+	return strings.Join(buttons, "  ")
 }
@@ -1,65 +1,146 @@
 package ui
 
 import (
+	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+// Connect form fields, cycled with Tab
+const (
+	connectFieldHost = iota
+	connectFieldPort
+	connectFieldProtocol
+	connectFieldSessions
+	connectFieldCount
+)
+
+// maxRecentConnections bounds the recent targets shown in connect mode
+const maxRecentConnections = 5
+
 // ConnectState represents the state of the connect mode
 type ConnectState struct {
 	host          string
 	port          string
 	protocol      string
-	focused       int // 0: host, 1: port, 2: protocol
+	focused       int
 	protocols     []string
 	protocolIndex int
+	connecting    bool
+	recent        []string
 }
 
 // updateConnect handles connect mode input
 func (m Model) updateConnect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	cs := m.connectState
+
 	switch msg.String() {
-	case "q", "ctrl+c":
+	case "ctrl+c":
 		return m, tea.Quit
 
-	case "esc":
-		m.switchToMode(ModeMenu)
-		return m, nil
-
 	case "?":
-		m.switchToMode(ModeHelp)
-		return m, nil
+		if cs.focused != connectFieldHost {
+			m.switchToMode(ModeHelp)
+			return m, nil
+		}
 
 	case "tab":
 		// Cycle through input fields
-		m.connectState.focused = (m.connectState.focused + 1) % 3 // 0: host, 1: port, 2: protocol
+		cs.focused = (cs.focused + 1) % connectFieldCount
+		return m, nil
+
+	case "shift+tab":
+		cs.focused = (cs.focused + connectFieldCount - 1) % connectFieldCount
 		return m, nil
+	}
+
+	if cs.focused == connectFieldSessions {
+		return m.updateSessionList(msg)
+	}
 
+	switch msg.String() {
 	case "enter":
 		// Attempt connection with validation
-		if strings.TrimSpace(m.input) == "" {
-			m.errorMsg = "Please enter a valid host:port"
+		if cs.connecting {
+			return m, nil
+		}
+		host := strings.TrimSpace(cs.host)
+		if host == "" {
+			host = "localhost"
+		}
+		if port, err := strconv.Atoi(cs.port); err != nil || port < 1 || port > 65535 {
+			cs.focused = connectFieldPort
+			m.setError("Please enter a valid host:port")
 			return m, nil
 		}
-		m.setSuccess("Attempting to connect to " + m.input + "...")
-		m.connected = true
-		m.connectionInfo = "Connected to " + m.input
+
+		target := net.JoinHostPort(host, cs.port)
+		cs.connecting = true
+		m.setSuccess("Attempting to connect to " + target + "...")
+		return m, m.sessions.dialSession(cs.protocol, target)
+
+	case "left", "right", " ":
+		if cs.focused == connectFieldProtocol {
+			delta := 1
+			if msg.String() == "left" {
+				delta = len(cs.protocols) - 1
+			}
+			cs.protocolIndex = (cs.protocolIndex + delta) % len(cs.protocols)
+			cs.protocol = cs.protocols[cs.protocolIndex]
+			return m, nil
+		}
+	}
+
+	// Handle text input
+	switch cs.focused {
+	case connectFieldHost:
+		cs.host = editField(cs.host, msg)
+	case connectFieldPort:
+		port := editField(cs.port, msg)
+		if _, err := strconv.Atoi(port); err == nil || port == "" {
+			cs.port = port
+		}
+	}
+
+	return m, nil
+}
+
+// handleSessionDialed attaches to a newly connected session
+func (m Model) handleSessionDialed(msg sessionDialedMsg) (tea.Model, tea.Cmd) {
+	cs := m.connectState
+	cs.connecting = false
+	if msg.err != nil {
+		m.setError(fmt.Sprintf("Connection to %s failed: %v", msg.target, msg.err))
 		return m, nil
+	}
 
-	default:
-		// Handle text input
-		if len(msg.String()) == 1 {
-			m.input += msg.String()
+	recent := fmt.Sprintf("%s (%s)", msg.target, strings.ToUpper(msg.session.Network))
+	cs.recent = append([]string{recent}, cs.recent...)
+	for i := 1; i < len(cs.recent); i++ {
+		if cs.recent[i] == recent {
+			cs.recent = append(cs.recent[:i], cs.recent[i+1:]...)
+			break
 		}
 	}
+	if len(cs.recent) > maxRecentConnections {
+		cs.recent = cs.recent[:maxRecentConnections]
+	}
 
+	if m.mode == ModeConnect {
+		m.attachSession(msg.session)
+	}
+	m.setSuccess("Connected to " + msg.target)
 	return m, nil
 }
 
 // viewConnect renders the connect interface
 func (m Model) viewConnect() string {
 	var content strings.Builder
+	cs := m.connectState
 
 	// Title
 	title := HeaderStyle.Render("🔗 Connect to Remote Host")
@@ -72,21 +153,29 @@ func (m Model) viewConnect() string {
 	content.WriteString("\n\n")
 
 	// Connection status
-	if m.connected {
-		status := SuccessStyle.Render("✓ Connected successfully!")
-		content.WriteString(status)
+	if cs.connecting {
+		content.WriteString(StatusConnecting())
 	} else {
-		status := MutedStyle.Render("● Not connected")
-		content.WriteString(status)
+		active := 0
+		for _, s := range m.sessions.Sessions() {
+			if s.Kind == "connect" && s.Active() {
+				active++
+			}
+		}
+		if active > 0 {
+			content.WriteString(SuccessStyle.Render(fmt.Sprintf("● %d outbound session(s) connected", active)))
+		} else {
+			content.WriteString(MutedStyle.Render("● Not connected"))
+		}
 	}
 	content.WriteString("\n\n")
 
 	// Instructions
 	instructions := []string{
-		"Tab: Switch between fields",
+		"Tab: Switch between fields and sessions",
 		"Enter: Connect",
+		"←/→: Change protocol",
 		"Esc: Back to menu",
-		"?: Help",
 	}
 
 	for _, instruction := range instructions {
@@ -94,21 +183,26 @@ func (m Model) viewConnect() string {
 		content.WriteString("\n")
 	}
 
-	// Recent connections
-	content.WriteString("\n")
-	recentTitle := InfoStyle.Render("Recent Connections:")
-	content.WriteString(recentTitle)
+	// Sessions
 	content.WriteString("\n")
+	content.WriteString(m.renderActiveConnections())
 
-	recentConnections := []string{
-		"localhost:8080 (TCP)",
-		"192.168.1.100:22 (SSH)",
-		"example.com:443 (HTTPS)",
+	// Recent connections
+	if len(cs.recent) > 0 {
+		content.WriteString("\n")
+		recentTitle := InfoStyle.Render("Recent Connections:")
+		content.WriteString(recentTitle)
+		content.WriteString("\n")
+
+		for _, conn := range cs.recent {
+			content.WriteString(MutedStyle.Render("  • " + conn))
+			content.WriteString("\n")
+		}
 	}
 
-	for _, conn := range recentConnections {
-		content.WriteString(MutedStyle.Render("  • " + conn))
+	if status := m.renderStatusMessage(); status != "" {
 		content.WriteString("\n")
+		content.WriteString(status)
 	}
 
 	return content.String()
@@ -117,45 +211,48 @@ func (m Model) viewConnect() string {
 // renderConnectionForm renders the connection input form
 func (m Model) renderConnectionForm() string {
 	var form strings.Builder
+	cs := m.connectState
+
+	input := func(label, value, placeholder string, index int) string {
+		style := BoxStyle.Width(30)
+		if value == "" {
+			value = MutedStyle.Render(placeholder)
+		}
+		if cs.focused == index {
+			style = style.BorderForeground(PrimaryColor)
+			value += "█"
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Center, InfoStyle.Width(10).Render(label), style.Render(value))
+	}
 
 	// Host input
-	hostLabel := InfoStyle.Render("Host:")
-	hostInput := BoxStyle.Width(30).Render("localhost")
-	hostRow := lipgloss.JoinHorizontal(lipgloss.Left, hostLabel, "  ", hostInput)
-	form.WriteString(hostRow)
+	form.WriteString(input("Host:", cs.host, "localhost", connectFieldHost))
 	form.WriteString("\n")
 
 	// Port input
-	portLabel := InfoStyle.Render("Port:")
-	portInput := BoxStyle.Width(30).Render("8080")
-	portRow := lipgloss.JoinHorizontal(lipgloss.Left, portLabel, "  ", portInput)
-	form.WriteString(portRow)
+	form.WriteString(input("Port:", cs.port, "8080", connectFieldPort))
 	form.WriteString("\n")
 
 	// Protocol selection
-	protocolLabel := InfoStyle.Render("Protocol:")
-	protocols := []string{"TCP", "UDP", "HTTP", "HTTPS", "SSH"}
-	protocolButtons := make([]string, len(protocols))
+	protocolLabel := InfoStyle.Width(10).Render("Protocol:")
+	if cs.focused == connectFieldProtocol {
+		protocolLabel = HighlightStyle.Width(10).Render("Protocol:")
+	}
+	protocolButtons := make([]string, len(cs.protocols))
 
-	for i, protocol := range protocols {
-		if i == 0 { // TCP selected by default
-			protocolButtons[i] = ActiveButtonStyle.Render(protocol)
+	for i, protocol := range cs.protocols {
+		if i == cs.protocolIndex {
+			protocolButtons[i] = ActiveButtonStyle.Render(strings.ToUpper(protocol))
 		} else {
-			protocolButtons[i] = ButtonStyle.Render(protocol)
+			protocolButtons[i] = ButtonStyle.Render(strings.ToUpper(protocol))
 		}
 	}
 
-	protocolRow := lipgloss.JoinHorizontal(lipgloss.Left,
-		protocolLabel, "  ",
+	protocolRow := lipgloss.JoinHorizontal(lipgloss.Center,
+		protocolLabel,
 		lipgloss.JoinHorizontal(lipgloss.Left, protocolButtons...),
 	)
 	form.WriteString(protocolRow)
-	form.WriteString("\n")
-
-	// Connect button
-	form.WriteString("\n")
-	connectBtn := ButtonStyle.Width(20).Align(lipgloss.Center).Render("🔗 Connect")
-	form.WriteString(lipgloss.NewStyle().Width(50).Align(lipgloss.Center).Render(connectBtn))
 
 	return form.String()
 }
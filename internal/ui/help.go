@@ -105,9 +105,9 @@ func (m Model) renderModuleHelp() string {
 	connectTitle := SuccessStyle.Render("🔗 Connect Module:")
 	moduleHelp.WriteString(connectTitle)
 	moduleHelp.WriteString("\n")
-	connectDesc := `Establish connections to remote hosts using various protocols.
-Supports TCP, UDP, HTTP, HTTPS, and SSH connections.
-Configure host, port, and protocol before connecting.`
+	connectDesc := `Open TCP or UDP connections to remote hosts as live sessions.
+A new connection is attached straight away; detach with Esc and it keeps running.
+Every session is listed below the form for attaching, killing or saving.`
 	moduleHelp.WriteString(MutedStyle.Render(connectDesc))
 	moduleHelp.WriteString("\n\n")
 
@@ -115,9 +115,9 @@ Configure host, port, and protocol before connecting.`
 	listenTitle := SuccessStyle.Render("👂 Listen Module:")
 	moduleHelp.WriteString(listenTitle)
 	moduleHelp.WriteString("\n")
-	listenDesc := `Listen for incoming connections on specified ports.
-Monitor active connections and view real-time logs.
-Supports TCP, UDP, and HTTP protocols.`
+	listenDesc := `Listen for incoming TCP connections or UDP peers on any number of ports.
+Each connection becomes a session with byte counts and a live transcript.
+Attach to a session to read its stream and send input.`
 	moduleHelp.WriteString(MutedStyle.Render(listenDesc))
 	moduleHelp.WriteString("\n\n")
 
@@ -125,9 +125,11 @@ Supports TCP, UDP, and HTTP protocols.`
 	chatTitle := SuccessStyle.Render("💬 Chat Module:")
 	moduleHelp.WriteString(chatTitle)
 	moduleHelp.WriteString("\n")
-	chatDesc := `Real-time text communication over network connections.
-Requires an active connection established via Connect module.
-Supports message history and session statistics.`
+	chatDesc := `Join a GoCat chat server with a nickname and room, or host one with Ctrl+S.
+Lines starting with / are server commands: /nick, /join, /rooms, /list, /help.
+While hosting, Tab to the client list to kick or mute participants.
+Enter unix:<path> as the server and press Ctrl+S to monitor a chat server
+started elsewhere with --admin-socket.`
 	moduleHelp.WriteString(MutedStyle.Render(chatDesc))
 	moduleHelp.WriteString("\n\n")
 
@@ -135,9 +137,10 @@ Supports message history and session statistics.`
 	brokerTitle := SuccessStyle.Render("🔄 Broker Module:")
 	moduleHelp.WriteString(brokerTitle)
 	moduleHelp.WriteString("\n")
-	brokerDesc := `Act as a network proxy/broker between clients and servers.
-Supports TCP proxy, HTTP proxy, and SOCKS5 modes.
-Monitor traffic statistics and active connections.`
+	brokerDesc := `Run a broker hub that relays data from each client to all the others.
+Watch connected clients with live throughput and the message flow between them.
+Kick or mute clients from the client list. Fill in Admin Socket to attach
+to a broker started with gocat broker --admin-socket instead of hosting one.`
 	moduleHelp.WriteString(MutedStyle.Render(brokerDesc))
 	moduleHelp.WriteString("\n\n")
 
@@ -145,9 +148,9 @@ Monitor traffic statistics and active connections.`
 	scanTitle := SuccessStyle.Render("🔍 Scan Module:")
 	moduleHelp.WriteString(scanTitle)
 	moduleHelp.WriteString("\n")
-	scanDesc := `Comprehensive network and port scanning capabilities.
-Supports TCP Connect, SYN, UDP, and Service scans.
-Detect open ports, services, and banners.`
+	scanDesc := `Scan hosts, CIDR networks and address ranges.
+Supports TCP Connect, Service (banner grab) and UDP scans.
+Results stream into a sortable table and export to JSON or CSV.`
 	moduleHelp.WriteString(MutedStyle.Render(scanDesc))
 
 	return moduleHelp.String()
@@ -186,10 +189,20 @@ func (m Model) renderKeyboardShortcuts() string {
 	moduleShortcuts := []string{
 		"s: Start/Stop (Listen, Broker, Scan modules)",
 		"c: Clear logs/results/connections",
-		"e: Export results (Scan module)",
-		"Enter: Send message (Chat module)",
+		"Enter/a, x, w: Attach, kill, save transcript (session list)",
+		"Ctrl+X, Ctrl+S: Kill, save transcript (attached session)",
+		"Ctrl+N/Ctrl+P: Next/previous session (attached session)",
+		"Ctrl+T: Toggle traffic inspector (attached session)",
+		"v, d, /, b, e/E: View, direction, search, bookmark, export (inspector)",
+		"Esc, Ctrl+D: Detach from session",
+		"e/E: Export results to JSON/CSV (Scan module)",
+		"o/r: Change/reverse sort order (Scan module)",
+		"a: Show/hide closed ports (Scan module)",
+		"k, m: Kick, mute/unmute client (Broker and Chat client lists)",
+		"Enter: Join chat / send message (Chat module)",
+		"Ctrl+S, Ctrl+D: Host server, leave chat (Chat module)",
+		"PgUp/PgDn: Scroll messages (Chat module)",
 		"Backspace: Delete character (input fields)",
-		"Ctrl+L: Clear chat history (Chat module)",
 	}
 	for _, shortcut := range moduleShortcuts {
 		shortcuts.WriteString(MutedStyle.Render("  " + shortcut))
@@ -219,27 +232,27 @@ func (m Model) renderExamples() string {
 				"2. Enter target host (e.g., localhost)",
 				"3. Enter port (e.g., 8080)",
 				"4. Select TCP protocol",
-				"5. Press Enter to connect",
+				"5. Press Enter to connect and attach to the session",
 			},
 		},
 		{
 			title: "Port Scanning:",
 			steps: []string{
 				"1. Select 'Scan' from main menu",
-				"2. Enter target host (e.g., 192.168.1.1)",
-				"3. Set port range (e.g., 1-1000)",
-				"4. Choose scan type (TCP Connect)",
-				"5. Press 's' to start scanning",
+				"2. Enter targets (e.g., 192.168.1.0/24)",
+				"3. Set port range (e.g., 22,80,443,8000-8100)",
+				"4. Choose scan type with ←/→ (TCP Connect)",
+				"5. Press Enter to start, Ctrl+C to cancel",
 			},
 		},
 		{
 			title: "Network Brokering:",
 			steps: []string{
 				"1. Select 'Broker' from main menu",
-				"2. Configure broker port (e.g., 8080)",
-				"3. Set target host (e.g., localhost:9090)",
-				"4. Choose proxy mode (TCP Proxy)",
-				"5. Press 's' to start broker",
+				"2. Configure broker port (e.g., 9000) and client limit",
+				"3. Press 's' to start the broker",
+				"4. Connect clients, e.g. gocat connect localhost 9000",
+				"5. Tab to the client list to watch, kick (k) or mute (m)",
 			},
 		},
 	}
@@ -0,0 +1,638 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/broker"
+	"github.com/ibrahmsql/gocat/internal/chat"
+)
+
+const (
+	// hubPollInterval is how often a monitored hub is polled
+	hubPollInterval = 500 * time.Millisecond
+
+	hubVisibleClients = 8
+	hubVisibleEvents  = 8
+)
+
+// hubClient is a client of a broker or chat server
+type hubClient struct {
+	ID        string
+	Name      string
+	Room      string
+	Addr      string
+	Connected time.Time
+	BytesIn   int64
+	BytesOut  int64
+	Messages  int64
+	Muted     bool
+}
+
+// hubStats are broker or chat server totals
+type hubStats struct {
+	Started  time.Time
+	Total    int64
+	Active   int
+	Rejected int64
+	BytesIn  int64
+	BytesOut int64
+	Messages int64
+	Rooms    map[string]int
+}
+
+// hubEvent is a line of a hub's message flow
+type hubEvent struct {
+	Time time.Time
+	Kind string
+	Text string
+}
+
+// hubSnapshot is the state of a hub at one poll
+type hubSnapshot struct {
+	Clients []hubClient
+	Stats   hubStats
+	Events  []hubEvent
+}
+
+// hubMonitor is a broker or chat server watched by the TUI, either hosted
+// in-process or reached over an admin socket
+type hubMonitor interface {
+	// Describe says where the hub runs, e.g. "in-process on [::]:9000"
+	Describe() string
+	Snapshot() (hubSnapshot, error)
+	Kick(id string) error
+	SetMuted(id string, muted bool) error
+	// Close stops a hosted hub or detaches from a remote one
+	Close() error
+}
+
+// hubRate is a client's throughput in bytes per second
+type hubRate struct {
+	in, out float64
+}
+
+// HubState is the monitoring state of a broker or chat hub
+type HubState struct {
+	monitor  hubMonitor
+	snapshot hubSnapshot
+	rates    map[string]hubRate
+	polled   time.Time
+	cursor   int
+	gen      int
+	err      error
+}
+
+// hubTickMsg asks the model to poll a hub
+type hubTickMsg struct {
+	hub *HubState
+	gen int
+}
+
+// Running reports whether the hub is being monitored
+func (h *HubState) Running() bool {
+	return h.monitor != nil
+}
+
+// start begins monitoring a hub and returns the first poll tick
+func (h *HubState) start(monitor hubMonitor) tea.Cmd {
+	h.monitor = monitor
+	h.snapshot = hubSnapshot{}
+	h.rates = make(map[string]hubRate)
+	h.polled = time.Time{}
+	h.cursor = 0
+	h.err = nil
+	h.gen++
+	h.poll()
+	return h.tick()
+}
+
+// stop closes the hub monitor and drops pending ticks
+func (h *HubState) stop() error {
+	if h.monitor == nil {
+		return nil
+	}
+	err := h.monitor.Close()
+	h.monitor = nil
+	h.gen++
+	return err
+}
+
+func (h *HubState) tick() tea.Cmd {
+	hub, gen := h, h.gen
+	return tea.Tick(hubPollInterval, func(time.Time) tea.Msg {
+		return hubTickMsg{hub: hub, gen: gen}
+	})
+}
+
+// poll refreshes the snapshot and derives per-client throughput from the
+// byte counters of the previous poll
+func (h *HubState) poll() {
+	if h.monitor == nil {
+		return
+	}
+	snap, err := h.monitor.Snapshot()
+	h.err = err
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	if !h.polled.IsZero() {
+		elapsed := now.Sub(h.polled).Seconds()
+		previous := make(map[string]hubClient, len(h.snapshot.Clients))
+		for _, c := range h.snapshot.Clients {
+			previous[c.ID] = c
+		}
+		rates := make(map[string]hubRate, len(snap.Clients))
+		for _, c := range snap.Clients {
+			if p, ok := previous[c.ID]; ok && elapsed > 0 {
+				rates[c.ID] = hubRate{
+					in:  float64(c.BytesIn-p.BytesIn) / elapsed,
+					out: float64(c.BytesOut-p.BytesOut) / elapsed,
+				}
+			}
+		}
+		h.rates = rates
+	}
+	h.snapshot = snap
+	h.polled = now
+	if h.cursor >= len(snap.Clients) {
+		h.cursor = max(len(snap.Clients)-1, 0)
+	}
+}
+
+// selected returns the client under the cursor
+func (h *HubState) selected() (hubClient, bool) {
+	if h.cursor < 0 || h.cursor >= len(h.snapshot.Clients) {
+		return hubClient{}, false
+	}
+	return h.snapshot.Clients[h.cursor], true
+}
+
+// handleHubTick polls a hub and schedules the next tick
+func (m Model) handleHubTick(msg hubTickMsg) (tea.Model, tea.Cmd) {
+	if msg.hub.gen != msg.gen || !msg.hub.Running() {
+		return m, nil
+	}
+	msg.hub.poll()
+	return m, msg.hub.tick()
+}
+
+// updateHubClients handles keys on a focused hub client table. It reports
+// whether the key was used.
+func (m *Model) updateHubClients(h *HubState, msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "up":
+		if h.cursor > 0 {
+			h.cursor--
+		}
+	case "down":
+		if h.cursor < len(h.snapshot.Clients)-1 {
+			h.cursor++
+		}
+	case "k", "delete":
+		return m.kickHubClient(h)
+	case "m":
+		c, ok := h.selected()
+		if !ok {
+			m.setError("No client selected")
+			return true
+		}
+		if err := h.monitor.SetMuted(c.ID, !c.Muted); err != nil {
+			m.setError("Mute failed: " + err.Error())
+			return true
+		}
+		if c.Muted {
+			m.setSuccess("Unmuted " + hubClientLabel(c))
+		} else {
+			m.setSuccess("Muted " + hubClientLabel(c))
+		}
+		h.poll()
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *Model) kickHubClient(h *HubState) bool {
+	c, ok := h.selected()
+	if !ok {
+		m.setError("No client selected")
+		return true
+	}
+	if err := h.monitor.Kick(c.ID); err != nil {
+		m.setError("Kick failed: " + err.Error())
+		return true
+	}
+	m.setSuccess("Kicked " + hubClientLabel(c))
+	h.poll()
+	return true
+}
+
+func hubClientLabel(c hubClient) string {
+	if c.Name != "" {
+		return fmt.Sprintf("%s (%s)", c.Name, c.ID)
+	}
+	return fmt.Sprintf("%s (%s)", c.Addr, c.ID)
+}
+
+// renderHubClients renders the client table with live throughput
+func renderHubClients(h *HubState, title string, focused, named bool) string {
+	var b strings.Builder
+
+	if focused {
+		b.WriteString(HighlightStyle.Render(title))
+	} else {
+		b.WriteString(InfoStyle.Render(title))
+	}
+	b.WriteString("\n")
+
+	clients := h.snapshot.Clients
+	if len(clients) == 0 {
+		b.WriteString(MutedStyle.Render("  No clients connected"))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	header := fmt.Sprintf("  %-5s %-21s %8s %9s %9s %9s %9s %6s  %s", "ID", "Address", "Online", "In", "Out", "In/s", "Out/s", "Msgs", "State")
+	if named {
+		header = fmt.Sprintf("  %-5s %-12s %-10s %-21s %9s %9s %6s  %s", "ID", "Nick", "Room", "Address", "In", "Out", "Msgs", "State")
+	}
+	b.WriteString(MutedStyle.Render(header))
+	b.WriteString("\n")
+
+	start := 0
+	if h.cursor >= hubVisibleClients {
+		start = h.cursor - hubVisibleClients + 1
+	}
+	end := min(start+hubVisibleClients, len(clients))
+	for i := start; i < end; i++ {
+		c := clients[i]
+		state := SuccessStyle.Render("active")
+		if c.Muted {
+			state = WarningStyle.Render("muted")
+		}
+
+		var row string
+		if named {
+			row = fmt.Sprintf("%-5s %-12s %-10s %-21s %9s %9s %6d  ",
+				c.ID, truncate(c.Name, 12), truncate(c.Room, 10), truncate(c.Addr, 21),
+				formatBytes(c.BytesIn), formatBytes(c.BytesOut), c.Messages)
+		} else {
+			rate := h.rates[c.ID]
+			row = fmt.Sprintf("%-5s %-21s %8s %9s %9s %9s %9s %6d  ",
+				c.ID, truncate(c.Addr, 21), time.Since(c.Connected).Round(time.Second),
+				formatBytes(c.BytesIn), formatBytes(c.BytesOut),
+				formatRate(rate.in), formatRate(rate.out), c.Messages)
+		}
+
+		if focused && i == h.cursor {
+			b.WriteString(HighlightStyle.Render("> " + row))
+		} else {
+			b.WriteString("  " + row)
+		}
+		b.WriteString(state)
+		b.WriteString("\n")
+	}
+	if len(clients) > hubVisibleClients {
+		b.WriteString(MutedStyle.Render(fmt.Sprintf("  %d-%d of %d clients", start+1, end, len(clients))))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderHubEvents renders the most recent events of a hub
+func renderHubEvents(h *HubState, title string) string {
+	var b strings.Builder
+	b.WriteString(InfoStyle.Render(title))
+	b.WriteString("\n")
+
+	events := h.snapshot.Events
+	if len(events) == 0 {
+		b.WriteString(MutedStyle.Render("  No activity yet"))
+		b.WriteString("\n")
+		return b.String()
+	}
+	if len(events) > hubVisibleEvents {
+		events = events[len(events)-hubVisibleEvents:]
+	}
+	for _, e := range events {
+		style := MutedStyle
+		switch e.Kind {
+		case broker.EventJoin:
+			style = SuccessStyle
+		case broker.EventLeave, broker.EventReject, broker.EventKick:
+			style = ErrorStyle
+		case broker.EventMute, broker.EventUnmute:
+			style = WarningStyle
+		case broker.EventData, chat.EventMessage:
+			style = InfoStyle
+		}
+		b.WriteString(fmt.Sprintf("  %s %s\n", MutedStyle.Render(e.Time.Format("15:04:05")), style.Render(e.Text)))
+	}
+	return b.String()
+}
+
+// renderHubStats renders hub totals
+func renderHubStats(h *HubState, title string) string {
+	var b strings.Builder
+	b.WriteString(InfoStyle.Render(title))
+	b.WriteString("\n")
+
+	if !h.Running() {
+		b.WriteString(MutedStyle.Render("  No statistics available"))
+		return b.String()
+	}
+	if h.err != nil {
+		b.WriteString(ErrorStyle.Render("  " + h.err.Error()))
+		return b.String()
+	}
+
+	s := h.snapshot.Stats
+	lines := []string{
+		fmt.Sprintf("  Total connections: %d", s.Total),
+		fmt.Sprintf("  Active connections: %d", s.Active),
+		fmt.Sprintf("  Rejected: %d", s.Rejected),
+		fmt.Sprintf("  Messages: %d", s.Messages),
+	}
+	if s.BytesIn > 0 || s.BytesOut > 0 {
+		lines = append(lines, fmt.Sprintf("  Bytes in/out: %s / %s", formatBytes(s.BytesIn), formatBytes(s.BytesOut)))
+	}
+	if len(s.Rooms) > 0 {
+		rooms := make([]string, 0, len(s.Rooms))
+		for name, n := range s.Rooms {
+			rooms = append(rooms, fmt.Sprintf("%s (%d)", name, n))
+		}
+		sort.Strings(rooms)
+		lines = append(lines, "  Rooms: "+strings.Join(rooms, ", "))
+	}
+	if !s.Started.IsZero() {
+		lines = append(lines, fmt.Sprintf("  Uptime: %v", time.Since(s.Started).Round(time.Second)))
+	}
+
+	for i, line := range lines {
+		if i == 1 {
+			b.WriteString(SuccessStyle.Render(line))
+		} else {
+			b.WriteString(MutedStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatRate formats a throughput in bytes per second
+func formatRate(rate float64) string {
+	if rate <= 0 {
+		return "-"
+	}
+	return formatBytes(int64(rate)) + "/s"
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// localBroker is a broker hosted in the TUI process
+type localBroker struct {
+	server *broker.Server
+}
+
+// hostBroker starts an in-process broker on addr
+func hostBroker(addr string, maxConns int) (*localBroker, error) {
+	server := broker.New(broker.Options{MaxConns: maxConns})
+	if err := server.Listen(addr); err != nil {
+		return nil, err
+	}
+	go server.Serve()
+	return &localBroker{server: server}, nil
+}
+
+func (b *localBroker) Describe() string {
+	return "in-process on " + b.server.Addr().String()
+}
+
+func (b *localBroker) Snapshot() (hubSnapshot, error) {
+	return brokerSnapshot(b.server.Clients(), b.server.Stats(), b.server.Events()), nil
+}
+
+// brokerSnapshot converts broker state for the hub views
+func brokerSnapshot(clients []broker.ClientInfo, s broker.Stats, events []broker.Event) hubSnapshot {
+	var snap hubSnapshot
+	for _, c := range clients {
+		snap.Clients = append(snap.Clients, hubClient{
+			ID: c.ID, Addr: c.Addr, Connected: c.Connected,
+			BytesIn: c.BytesIn, BytesOut: c.BytesOut, Messages: c.Messages, Muted: c.Muted,
+		})
+	}
+	snap.Stats = hubStats{
+		Started: s.Started, Total: s.TotalConnections, Active: s.ActiveConnections,
+		Rejected: s.Rejected, BytesIn: s.BytesIn, BytesOut: s.BytesOut,
+	}
+	for _, c := range snap.Clients {
+		snap.Stats.Messages += c.Messages
+	}
+	for _, e := range events {
+		snap.Events = append(snap.Events, hubEvent{Time: e.Time, Kind: e.Kind, Text: brokerEventText(e)})
+	}
+	return snap
+}
+
+func (b *localBroker) Kick(id string) error {
+	return b.server.Kick(id)
+}
+
+func (b *localBroker) SetMuted(id string, muted bool) error {
+	return b.server.SetMuted(id, muted)
+}
+
+func (b *localBroker) Close() error {
+	return b.server.Close()
+}
+
+// brokerEventText describes a broker event for the message flow
+func brokerEventText(e broker.Event) string {
+	switch e.Kind {
+	case broker.EventJoin:
+		return fmt.Sprintf("%s connected from %s", e.Client, e.Addr)
+	case broker.EventLeave:
+		return fmt.Sprintf("%s disconnected", e.Client)
+	case broker.EventReject:
+		return fmt.Sprintf("rejected %s (server full)", e.Addr)
+	case broker.EventData:
+		return fmt.Sprintf("%s → %d client(s): %s", e.Client, e.Receivers, formatBytes(int64(e.Bytes)))
+	case broker.EventKick:
+		return fmt.Sprintf("%s kicked", e.Client)
+	case broker.EventMute:
+		return fmt.Sprintf("%s muted", e.Client)
+	case broker.EventUnmute:
+		return fmt.Sprintf("%s unmuted", e.Client)
+	}
+	return fmt.Sprintf("%s %s", e.Client, e.Kind)
+}
+
+// localChat is a chat server hosted in the TUI process
+type localChat struct {
+	server *chat.Server
+}
+
+// hostChat starts an in-process chat server on addr
+func hostChat(addr, room string) (*localChat, error) {
+	server := chat.New(chat.Options{Room: room})
+	if err := server.Listen(addr); err != nil {
+		return nil, err
+	}
+	go server.Serve()
+	return &localChat{server: server}, nil
+}
+
+func (c *localChat) Describe() string {
+	return "in-process on " + c.server.Addr().String()
+}
+
+func (c *localChat) Snapshot() (hubSnapshot, error) {
+	return chatSnapshot(c.server.Clients(), c.server.Stats(), c.server.Events()), nil
+}
+
+// chatSnapshot converts chat server state for the hub views
+func chatSnapshot(clients []chat.ClientInfo, s chat.Stats, events []chat.Event) hubSnapshot {
+	var snap hubSnapshot
+	for _, cl := range clients {
+		snap.Clients = append(snap.Clients, hubClient{
+			ID: cl.ID, Name: cl.Nick, Room: cl.Room, Addr: cl.Addr, Connected: cl.Connected,
+			BytesIn: cl.BytesIn, BytesOut: cl.BytesOut, Messages: cl.Messages, Muted: cl.Muted,
+		})
+	}
+	snap.Stats = hubStats{
+		Started: s.Started, Total: s.TotalConnections, Active: s.ActiveConnections,
+		Rejected: s.Rejected, Messages: s.Messages, Rooms: s.Rooms,
+	}
+	for _, e := range events {
+		snap.Events = append(snap.Events, hubEvent{Time: e.Time, Kind: e.Kind, Text: chatEventText(e)})
+	}
+	return snap
+}
+
+func (c *localChat) Kick(id string) error {
+	return c.server.Kick(id)
+}
+
+func (c *localChat) SetMuted(id string, muted bool) error {
+	return c.server.SetMuted(id, muted)
+}
+
+func (c *localChat) Close() error {
+	return c.server.Close()
+}
+
+// chatEventText describes a chat event for the message flow
+func chatEventText(e chat.Event) string {
+	switch e.Kind {
+	case chat.EventJoin:
+		return fmt.Sprintf("%s joined %s from %s", e.Nick, e.Room, e.Text)
+	case chat.EventLeave:
+		return fmt.Sprintf("%s left", e.Nick)
+	case chat.EventReject:
+		return fmt.Sprintf("rejected %s (server full)", e.Text)
+	case chat.EventMessage:
+		return fmt.Sprintf("%s@%s: %s", e.Nick, e.Room, e.Text)
+	case chat.EventNick:
+		return fmt.Sprintf("%s is now %s", e.Text, e.Nick)
+	case chat.EventRoom:
+		return fmt.Sprintf("%s moved from %s to %s", e.Nick, e.Text, e.Room)
+	case chat.EventKick:
+		return fmt.Sprintf("%s kicked", e.Nick)
+	case chat.EventMute:
+		return fmt.Sprintf("%s muted", e.Nick)
+	case chat.EventUnmute:
+		return fmt.Sprintf("%s unmuted", e.Nick)
+	}
+	return fmt.Sprintf("%s %s", e.Nick, e.Kind)
+}
+
+// remoteHub is a broker or chat server of another gocat process, reached
+// over its admin socket
+type remoteHub struct {
+	client  *admin.Client
+	path    string
+	command string
+}
+
+// attachHub connects to the admin socket at path. want is "broker" or
+// "chat"; other servers are refused.
+func attachHub(path, want string) (*remoteHub, error) {
+	client, err := admin.Dial(path, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var status admin.Status
+	if err := client.Call("status", nil, &status); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if status.Command != want {
+		client.Close()
+		return nil, fmt.Errorf("%s is a %s server, not a %s", path, status.Command, want)
+	}
+	return &remoteHub{client: client, path: path, command: status.Command}, nil
+}
+
+func (r *remoteHub) Describe() string {
+	return "over admin socket " + r.path
+}
+
+func (r *remoteHub) Snapshot() (hubSnapshot, error) {
+	if r.command == "chat" {
+		var clients []chat.ClientInfo
+		var stats chat.Stats
+		var events []chat.Event
+		if err := r.fetch(&clients, &stats, &events); err != nil {
+			return hubSnapshot{}, err
+		}
+		return chatSnapshot(clients, stats, events), nil
+	}
+	var clients []broker.ClientInfo
+	var stats broker.Stats
+	var events []broker.Event
+	if err := r.fetch(&clients, &stats, &events); err != nil {
+		return hubSnapshot{}, err
+	}
+	return brokerSnapshot(clients, stats, events), nil
+}
+
+// fetch reads the clients, totals and recent events of the remote hub
+func (r *remoteHub) fetch(clients, stats, events interface{}) error {
+	if err := r.client.Call("sessions.list", nil, clients); err != nil {
+		return err
+	}
+	if err := r.client.Call("stats", nil, stats); err != nil {
+		return err
+	}
+	return r.client.Call("events", nil, events)
+}
+
+func (r *remoteHub) Kick(id string) error {
+	return r.client.Call("sessions.kill", map[string]string{"id": id}, nil)
+}
+
+func (r *remoteHub) SetMuted(id string, muted bool) error {
+	return r.client.Call("sessions.mute", map[string]interface{}{"id": id, "muted": muted}, nil)
+}
+
+// Close detaches from the hub, which keeps running
+func (r *remoteHub) Close() error {
+	return r.client.Close()
+}
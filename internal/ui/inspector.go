@@ -0,0 +1,599 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Inspector views, cycled with v
+const (
+	inspectText = iota
+	inspectHex
+	inspectEscaped
+	inspectViewCount
+)
+
+var inspectViewNames = []string{"text", "hex", "escaped"}
+
+// Direction filters, cycled with d
+const (
+	filterAll = iota
+	filterIn
+	filterOut
+	filterCount
+)
+
+var filterNames = []string{"both", "in", "out"}
+
+// chunkPreviewLines bounds the body shown for chunks other than the cursor
+const chunkPreviewLines = 4
+
+// chunkKey identifies a chunk independently of its position in the
+// transcript, which shifts when old chunks are dropped
+type chunkKey struct {
+	dir    Direction
+	offset int64
+}
+
+func keyOf(c Chunk) chunkKey {
+	return chunkKey{dir: c.Dir, offset: c.Offset}
+}
+
+// searchMatch is the position of a search hit
+type searchMatch struct {
+	chunk chunkKey
+	pos   int // byte position within the chunk
+}
+
+// InspectorState represents the traffic inspector pane of a session
+type InspectorState struct {
+	open    bool
+	view    int
+	filter  int
+	cursor  int  // index into the filtered chunks
+	follow  bool // keep the cursor on the newest chunk
+	top     int  // first chunk shown
+	lineOff int  // first body line shown for the cursor chunk
+
+	anchor    int // selection start, -1 when nothing is selected
+	bookmarks map[chunkKey]bool
+
+	searching bool
+	query     string
+	pattern   []byte
+	matches   []searchMatch
+	matchIdx  int
+}
+
+// newInspectorState creates a closed inspector following the newest chunk
+func newInspectorState() *InspectorState {
+	return &InspectorState{
+		view:      inspectText,
+		follow:    true,
+		anchor:    -1,
+		bookmarks: make(map[chunkKey]bool),
+	}
+}
+
+// filterChunks applies the direction filter
+func (in *InspectorState) filterChunks(chunks []Chunk) []Chunk {
+	if in.filter == filterAll {
+		return chunks
+	}
+	want := DirIn
+	if in.filter == filterOut {
+		want = DirOut
+	}
+	filtered := make([]Chunk, 0, len(chunks))
+	for _, c := range chunks {
+		if c.Dir == want {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// moveTo places the cursor on a chunk and resets the body scroll
+func (in *InspectorState) moveTo(index, count int) {
+	in.cursor = max(min(index, count-1), 0)
+	in.follow = in.cursor == count-1
+	in.lineOff = 0
+}
+
+// indexOf returns the position of the chunk with key k, or -1
+func indexOf(chunks []Chunk, k chunkKey) int {
+	for i, c := range chunks {
+		if keyOf(c) == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// parsePattern turns a search query into bytes. Queries starting with
+// "hex:" or "0x" are hex byte patterns; spaces between bytes are ignored.
+func parsePattern(query string) ([]byte, error) {
+	lower := strings.ToLower(query)
+	for _, prefix := range []string{"hex:", "0x"} {
+		if strings.HasPrefix(lower, prefix) {
+			digits := strings.NewReplacer(" ", "", ":", "").Replace(query[len(prefix):])
+			pattern, err := hex.DecodeString(digits)
+			if err != nil || len(pattern) == 0 {
+				return nil, fmt.Errorf("invalid hex pattern %q", query)
+			}
+			return pattern, nil
+		}
+	}
+	if query == "" {
+		return nil, fmt.Errorf("empty search")
+	}
+	return []byte(query), nil
+}
+
+// findMatches returns every occurrence of pattern in the chunks
+func findMatches(chunks []Chunk, pattern []byte) []searchMatch {
+	var matches []searchMatch
+	for _, c := range chunks {
+		for pos := 0; ; {
+			i := bytes.Index(c.Data[pos:], pattern)
+			if i < 0 {
+				break
+			}
+			matches = append(matches, searchMatch{chunk: keyOf(c), pos: pos + i})
+			pos += i + 1
+		}
+	}
+	return matches
+}
+
+// updateInspector handles keys while the inspector pane is open
+func (m Model) updateInspector(msg tea.KeyMsg, s *Session) (tea.Model, tea.Cmd) {
+	in := m.sessionState.inspector
+	all, _ := s.Chunks()
+	chunks := in.filterChunks(all)
+	count := len(chunks)
+	if in.follow {
+		in.cursor = max(count-1, 0)
+	}
+
+	if in.searching {
+		switch msg.Type {
+		case tea.KeyEsc:
+			in.searching = false
+		case tea.KeyEnter:
+			in.searching = false
+			pattern, err := parsePattern(in.query)
+			if err != nil {
+				m.setError(err.Error())
+				return m, nil
+			}
+			in.pattern = pattern
+			in.matches = findMatches(chunks, pattern)
+			in.matchIdx = -1
+			if len(in.matches) == 0 {
+				m.setError(fmt.Sprintf("No matches for %q", in.query))
+				return m, nil
+			}
+			m.jumpToMatch(chunks, 1)
+		case tea.KeyBackspace:
+			if len(in.query) > 0 {
+				in.query = in.query[:len(in.query)-1]
+			}
+		case tea.KeySpace:
+			in.query += " "
+		case tea.KeyRunes:
+			in.query += string(msg.Runes)
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "ctrl+t":
+		in.open = false
+	case "ctrl+c":
+		m.detachSession()
+
+	case "up", "k":
+		in.moveTo(in.cursor-1, count)
+	case "down", "j":
+		in.moveTo(in.cursor+1, count)
+	case "home", "g":
+		in.moveTo(0, count)
+	case "end", "G":
+		in.moveTo(count-1, count)
+	case "pgdown", "pgup":
+		page := m.inspectorHeight() - 1
+		body := 0
+		if in.cursor < count {
+			body = len(chunkBody(chunks[in.cursor], in.view, m.inspectorWidth()))
+		}
+		if msg.String() == "pgdown" {
+			if in.lineOff+page < body {
+				in.lineOff += page
+			} else {
+				in.moveTo(in.cursor+1, count)
+			}
+		} else if in.lineOff > 0 {
+			in.lineOff = max(in.lineOff-page, 0)
+		} else {
+			in.moveTo(in.cursor-1, count)
+		}
+
+	case "v":
+		in.view = (in.view + 1) % inspectViewCount
+		in.lineOff = 0
+	case "d":
+		var current chunkKey
+		if in.cursor < count {
+			current = keyOf(chunks[in.cursor])
+		}
+		in.filter = (in.filter + 1) % filterCount
+		in.anchor = -1
+		filtered := in.filterChunks(all)
+		if i := indexOf(filtered, current); i >= 0 && !in.follow {
+			in.moveTo(i, len(filtered))
+		} else {
+			in.moveTo(len(filtered)-1, len(filtered))
+		}
+
+	case "/":
+		in.searching = true
+		in.query = ""
+	case "n":
+		m.jumpToMatch(chunks, 1)
+	case "N":
+		m.jumpToMatch(chunks, -1)
+
+	case "b":
+		if in.cursor < count {
+			k := keyOf(chunks[in.cursor])
+			if in.bookmarks[k] {
+				delete(in.bookmarks, k)
+			} else {
+				in.bookmarks[k] = true
+			}
+		}
+	case "]", "[":
+		step := 1
+		if msg.String() == "[" {
+			step = -1
+		}
+		for i := in.cursor + step; i >= 0 && i < count; i += step {
+			if in.bookmarks[keyOf(chunks[i])] {
+				in.moveTo(i, count)
+				return m, nil
+			}
+		}
+		m.setError("No more bookmarks")
+
+	case " ":
+		if in.anchor >= 0 {
+			in.anchor = -1
+		} else {
+			in.anchor = in.cursor
+		}
+	case "e":
+		m.exportChunks(s, chunks, false)
+	case "E":
+		m.exportChunks(s, chunks, true)
+	}
+	return m, nil
+}
+
+// jumpToMatch moves to the next (step 1) or previous (step -1) search match
+func (m *Model) jumpToMatch(chunks []Chunk, step int) {
+	in := m.sessionState.inspector
+	if len(in.matches) == 0 {
+		m.setError("No search results")
+		return
+	}
+	for range in.matches {
+		in.matchIdx = (in.matchIdx + step + len(in.matches)) % len(in.matches)
+		match := in.matches[in.matchIdx]
+		i := indexOf(chunks, match.chunk)
+		if i < 0 {
+			// Dropped from the transcript or hidden by the filter
+			continue
+		}
+		in.moveTo(i, len(chunks))
+		in.lineOff = matchLine(chunks[i], match.pos, in.view, m.inspectorWidth())
+		m.setSuccess(fmt.Sprintf("Match %d/%d at offset %d", in.matchIdx+1, len(in.matches), chunks[i].Offset+int64(match.pos)))
+		return
+	}
+	m.setError("Search results are no longer visible")
+}
+
+// matchLine returns the body line of a chunk that contains byte pos
+func matchLine(c Chunk, pos, view, width int) int {
+	switch view {
+	case inspectHex:
+		return pos / 16
+	case inspectText:
+		return bytes.Count(c.Data[:pos], []byte("\n"))
+	}
+	return len(strconv.Quote(string(c.Data[:pos]))) / max(width, 1)
+}
+
+// selection returns the chunks to export: the selected range, else the
+// bookmarked chunks, else the chunk under the cursor
+func (in *InspectorState) selection(chunks []Chunk) []Chunk {
+	if len(chunks) == 0 {
+		return nil
+	}
+	if in.anchor >= 0 {
+		from, to := min(in.anchor, in.cursor), max(in.anchor, in.cursor)
+		return chunks[from:min(to+1, len(chunks))]
+	}
+	var marked []Chunk
+	for _, c := range chunks {
+		if in.bookmarks[keyOf(c)] {
+			marked = append(marked, c)
+		}
+	}
+	if len(marked) > 0 {
+		return marked
+	}
+	return chunks[min(in.cursor, len(chunks)-1) : min(in.cursor, len(chunks)-1)+1]
+}
+
+// exportChunks writes the selected chunks as an annotated hex dump or as
+// raw bytes
+func (m *Model) exportChunks(s *Session, chunks []Chunk, raw bool) {
+	selected := m.sessionState.inspector.selection(chunks)
+	if len(selected) == 0 {
+		m.setError("Nothing to export")
+		return
+	}
+
+	ext := "hex"
+	if raw {
+		ext = "bin"
+	}
+	path := fmt.Sprintf("gocat-session-%d-%s.%s", s.ID, time.Now().Format("20060102-150405"), ext)
+	file, err := os.Create(path)
+	if err != nil {
+		m.setError("Export failed: " + err.Error())
+		return
+	}
+	defer file.Close()
+
+	var total int
+	for _, c := range selected {
+		if raw {
+			_, err = file.Write(c.Data)
+		} else {
+			_, err = fmt.Fprintf(file, "# %s %s offset %d length %d\n%s\n",
+				c.Time.Format("15:04:05.000"), c.Dir, c.Offset, len(c.Data),
+				strings.Join(hexDumpLines(c.Data, c.Offset), "\n"))
+		}
+		if err != nil {
+			m.setError("Export failed: " + err.Error())
+			return
+		}
+		total += len(c.Data)
+	}
+	m.setSuccess(fmt.Sprintf("Exported %d chunk(s), %d bytes to %s", len(selected), total, path))
+}
+
+// hexDumpLines formats data as offset, hex and ASCII columns, 16 bytes per
+// line, in the same layout as the -x output
+func hexDumpLines(data []byte, base int64) []string {
+	var lines []string
+	for i := 0; i < len(data); i += 16 {
+		end := min(i+16, len(data))
+		var line strings.Builder
+		fmt.Fprintf(&line, "%08x  ", base+int64(i))
+		for j := i; j < i+16; j++ {
+			if j < end {
+				fmt.Fprintf(&line, "%02x ", data[j])
+			} else {
+				line.WriteString("   ")
+			}
+		}
+		line.WriteString(" |")
+		for _, b := range data[i:end] {
+			if b >= 32 && b <= 126 {
+				line.WriteByte(b)
+			} else {
+				line.WriteByte('.')
+			}
+		}
+		line.WriteString("|")
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// chunkBody renders the body lines of a chunk in the given view
+func chunkBody(c Chunk, view, width int) []string {
+	switch view {
+	case inspectHex:
+		return hexDumpLines(c.Data, c.Offset)
+	case inspectEscaped:
+		quoted := strconv.Quote(string(c.Data))
+		quoted = quoted[1 : len(quoted)-1]
+		var lines []string
+		for len(quoted) > width {
+			lines = append(lines, quoted[:width])
+			quoted = quoted[width:]
+		}
+		return append(lines, quoted)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(c.Data), "\n"), "\n")
+	for i, line := range lines {
+		line = escapeText(strings.TrimSuffix(line, "\r"))
+		if len(line) > width {
+			line = line[:width]
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// highlightHexLine highlights the bytes [from, to) of the 16-byte row
+// starting at rowStart in a hex dump line
+func highlightHexLine(line string, rowStart, from, to int) string {
+	if to <= rowStart || from >= rowStart+16 {
+		return line
+	}
+	first := max(from-rowStart, 0)
+	last := min(to-rowStart, 16)
+	start := 10 + first*3
+	end := 10 + last*3 - 1
+	if end > len(line) {
+		return line
+	}
+	return line[:start] + HighlightStyle.Render(line[start:end]) + line[end:]
+}
+
+func (m Model) inspectorHeight() int {
+	return max(m.height-12, 6)
+}
+
+func (m Model) inspectorWidth() int {
+	return max(m.width-14, 20)
+}
+
+// renderInspector renders the chunk list of the traffic inspector
+func (m Model) renderInspector(s *Session) string {
+	in := m.sessionState.inspector
+	all, dropped := s.Chunks()
+	chunks := in.filterChunks(all)
+	height := m.inspectorHeight()
+	width := m.inspectorWidth()
+
+	if in.follow || in.cursor >= len(chunks) {
+		in.cursor = max(len(chunks)-1, 0)
+	}
+
+	var current *searchMatch
+	if in.matchIdx >= 0 && in.matchIdx < len(in.matches) {
+		current = &in.matches[in.matchIdx]
+	}
+
+	// Lines each chunk occupies on screen
+	size := func(i int) int {
+		body := len(chunkBody(chunks[i], in.view, width))
+		if i == in.cursor {
+			return 1 + min(body-in.lineOff, height-1)
+		}
+		return 1 + min(body, chunkPreviewLines)
+	}
+
+	// Keep the cursor chunk on screen
+	if in.cursor < in.top {
+		in.top = in.cursor
+	}
+	for in.top < in.cursor {
+		used := 0
+		for i := in.top; i <= in.cursor; i++ {
+			used += size(i)
+		}
+		if used <= height {
+			break
+		}
+		in.top++
+	}
+	in.top = min(in.top, max(len(chunks)-1, 0))
+
+	var pane strings.Builder
+	if dropped > 0 && in.top == 0 {
+		pane.WriteString(MutedStyle.Render(fmt.Sprintf("… %s dropped from the transcript", formatBytes(dropped))))
+		pane.WriteString("\n")
+	}
+	if len(chunks) == 0 {
+		pane.WriteString(MutedStyle.Render("No traffic yet"))
+	}
+
+	used := 0
+	for i := in.top; i < len(chunks) && used < height; i++ {
+		c := chunks[i]
+		k := keyOf(c)
+
+		// Chunk header: direction, time, stream offset and length
+		arrow, style := "←", SuccessStyle
+		if c.Dir == DirOut {
+			arrow, style = "→", InfoStyle
+		}
+		header := fmt.Sprintf("%s %-3s %s  off %-8d len %-6d", arrow, c.Dir, c.Time.Format("15:04:05.000"), c.Offset, len(c.Data))
+		if n := countMatches(in.matches, k); n > 0 {
+			header += fmt.Sprintf(" %d match(es)", n)
+		}
+		if in.bookmarks[k] {
+			header += " ★"
+		}
+
+		marker := "  "
+		if in.anchor >= 0 && i >= min(in.anchor, in.cursor) && i <= max(in.anchor, in.cursor) {
+			marker = "┃ "
+		}
+		if i == in.cursor {
+			pane.WriteString(marker + HighlightStyle.Render(header))
+		} else {
+			pane.WriteString(marker + style.Render(header))
+		}
+		pane.WriteString("\n")
+		used++
+
+		body := chunkBody(c, in.view, width)
+		from, limit := 0, chunkPreviewLines
+		if i == in.cursor {
+			from, limit = min(in.lineOff, max(len(body)-1, 0)), height-used
+		}
+		shown := body[from:min(from+limit, len(body))]
+		for j, line := range shown {
+			if in.view == inspectHex && current != nil && current.chunk == k {
+				line = highlightHexLine(line, (from+j)*16, current.pos, current.pos+len(in.pattern))
+			}
+			pane.WriteString(marker + "  " + line)
+			pane.WriteString("\n")
+		}
+		used += len(shown)
+		if rest := len(body) - from - len(shown); rest > 0 && used < height {
+			pane.WriteString(MutedStyle.Render(fmt.Sprintf("%s  … %d more line(s)", marker, rest)))
+			pane.WriteString("\n")
+			used++
+		}
+	}
+
+	return strings.TrimRight(pane.String(), "\n")
+}
+
+func countMatches(matches []searchMatch, k chunkKey) int {
+	n := 0
+	for _, match := range matches {
+		if match.chunk == k {
+			n++
+		}
+	}
+	return n
+}
+
+// renderInspectorStatus renders the inspector mode line or search prompt
+func (m Model) renderInspectorStatus() string {
+	in := m.sessionState.inspector
+	if in.searching {
+		return SuccessStyle.Render("/") + in.query + "█" + MutedStyle.Render("  (text, or hex:de ad be ef)")
+	}
+
+	status := fmt.Sprintf("view: %s  direction: %s  bookmarks: %d",
+		inspectViewNames[in.view], filterNames[in.filter], len(in.bookmarks))
+	if len(in.matches) > 0 {
+		status += fmt.Sprintf("  matches: %d", len(in.matches))
+	}
+	if in.anchor >= 0 {
+		status += "  selecting"
+	}
+	if in.follow {
+		status += "  following"
+	}
+	return MutedStyle.Render(status)
+}
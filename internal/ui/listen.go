@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,22 +10,21 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// Listen form fields, cycled with Tab
+const (
+	listenFieldPort = iota
+	listenFieldProtocol
+	listenFieldSessions
+	listenFieldCount
+)
+
 // ListenState represents the state of the listen mode
 type ListenState struct {
-	port        string
-	protocol    string
-	connections []Connection
-	logMessages []LogMessage
-}
-
-// Connection represents an active connection
-type Connection struct {
-	ID        string
-	RemoteIP  string
-	Port      string
-	Protocol  string
-	StartTime time.Time
-	Status    string
+	port          string
+	protocol      string
+	focused       int
+	protocols     []string
+	protocolIndex int
 }
 
 // LogMessage represents a log entry
@@ -36,36 +36,85 @@ type LogMessage struct {
 
 // updateListen handles listen mode input
 func (m Model) updateListen(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	ls := m.listenState
+
 	switch msg.String() {
-	case "q", "ctrl+c":
+	case "ctrl+c":
 		return m, tea.Quit
 
-	case "esc":
-		m.switchToMode(ModeMenu)
-		return m, nil
-
 	case "?":
 		m.switchToMode(ModeHelp)
 		return m, nil
 
-	case "s":
+	case "tab":
+		ls.focused = (ls.focused + 1) % listenFieldCount
+		return m, nil
+
+	case "shift+tab":
+		ls.focused = (ls.focused + listenFieldCount - 1) % listenFieldCount
+		return m, nil
+	}
+
+	if ls.focused == listenFieldSessions {
+		if msg.String() == "s" {
+			return m.toggleListener()
+		}
+		return m.updateSessionList(msg)
+	}
+
+	switch msg.String() {
+	case "enter", "s":
 		// Start/Stop listening
-		if m.listening {
-			m.listening = false
-			m.setSuccess("Stopped listening")
-		} else {
-			m.listening = true
-			m.setSuccess("Started listening on port 8080")
+		return m.toggleListener()
+
+	case "c":
+		// Clear logs and closed sessions
+		return m.updateSessionList(msg)
+
+	case "left", "right", " ":
+		if ls.focused == listenFieldProtocol {
+			delta := 1
+			if msg.String() == "left" {
+				delta = len(ls.protocols) - 1
+			}
+			ls.protocolIndex = (ls.protocolIndex + delta) % len(ls.protocols)
+			ls.protocol = ls.protocols[ls.protocolIndex]
 		}
 		return m, nil
+	}
 
-	case "c":
-		// Clear logs
-		m.messages = []string{}
-		m.setSuccess("Logs cleared")
+	if ls.focused == listenFieldPort {
+		port := editField(ls.port, msg)
+		if _, err := strconv.Atoi(port); err == nil || port == "" {
+			ls.port = port
+		}
+	}
+	return m, nil
+}
+
+// toggleListener starts a listener on the configured port, or stops it if
+// one is already open
+func (m Model) toggleListener() (tea.Model, tea.Cmd) {
+	ls := m.listenState
+	port, err := strconv.Atoi(ls.port)
+	if err != nil || port < 1 || port > 65535 {
+		ls.focused = listenFieldPort
+		m.setError("Please enter a port between 1 and 65535")
 		return m, nil
 	}
 
+	addr := ":" + ls.port
+	if l := m.sessions.Listener(ls.protocol, addr); l != nil {
+		m.sessions.StopListener(l)
+		m.setSuccess("Stopped listening on port " + ls.port)
+		return m, nil
+	}
+
+	if _, err := m.sessions.Listen(ls.protocol, addr); err != nil {
+		m.setError("Listen failed: " + err.Error())
+		return m, nil
+	}
+	m.setSuccess(fmt.Sprintf("Started listening on port %s (%s)", ls.port, strings.ToUpper(ls.protocol)))
 	return m, nil
 }
 
@@ -97,34 +146,47 @@ func (m Model) viewListen() string {
 	logs := m.renderLiveLogs()
 	content.WriteString(logs)
 
+	if status := m.renderStatusMessage(); status != "" {
+		content.WriteString("\n")
+		content.WriteString(status)
+	}
+
 	return content.String()
 }
 
 // renderListenConfig renders the listening configuration
 func (m Model) renderListenConfig() string {
 	var config strings.Builder
+	ls := m.listenState
 
 	// Port configuration
 	portLabel := InfoStyle.Render("Listen Port:")
-	portValue := BoxStyle.Width(15).Render("8080")
-	portRow := lipgloss.JoinHorizontal(lipgloss.Left, portLabel, "  ", portValue)
+	portStyle := BoxStyle.Width(15)
+	port := ls.port
+	if ls.focused == listenFieldPort {
+		portStyle = portStyle.BorderForeground(PrimaryColor)
+		port += "█"
+	}
+	portRow := lipgloss.JoinHorizontal(lipgloss.Center, portLabel, "  ", portStyle.Render(port))
 	config.WriteString(portRow)
 	config.WriteString("\n")
 
 	// Protocol configuration
 	protocolLabel := InfoStyle.Render("Protocol:")
-	protocols := []string{"TCP", "UDP", "HTTP"}
-	protocolButtons := make([]string, len(protocols))
+	if ls.focused == listenFieldProtocol {
+		protocolLabel = HighlightStyle.Render("Protocol:")
+	}
+	protocolButtons := make([]string, len(ls.protocols))
 
-	for i, protocol := range protocols {
-		if i == 0 { // TCP selected by default
-			protocolButtons[i] = ActiveButtonStyle.Render(protocol)
+	for i, protocol := range ls.protocols {
+		if i == ls.protocolIndex {
+			protocolButtons[i] = ActiveButtonStyle.Render(strings.ToUpper(protocol))
 		} else {
-			protocolButtons[i] = ButtonStyle.Render(protocol)
+			protocolButtons[i] = ButtonStyle.Render(strings.ToUpper(protocol))
 		}
 	}
 
-	protocolRow := lipgloss.JoinHorizontal(lipgloss.Left,
+	protocolRow := lipgloss.JoinHorizontal(lipgloss.Center,
 		protocolLabel, "  ",
 		lipgloss.JoinHorizontal(lipgloss.Left, protocolButtons...),
 	)
@@ -136,32 +198,38 @@ func (m Model) renderListenConfig() string {
 // renderListenControls renders the control buttons and status
 func (m Model) renderListenControls() string {
 	var controls strings.Builder
+	ls := m.listenState
 
 	// Status indicator
-	var status string
-	if m.listening {
-		status = StatusListening()
-	} else {
-		status = MutedStyle.Render("● Not listening")
+	listeners := m.sessions.Listeners()
+	if len(listeners) == 0 {
+		controls.WriteString(MutedStyle.Render("● Not listening"))
+	}
+	for i, l := range listeners {
+		if i > 0 {
+			controls.WriteString("\n")
+		}
+		controls.WriteString(StatusListening())
+		controls.WriteString(MutedStyle.Render(fmt.Sprintf(" on %s (%s) since %s",
+			l.Addr, strings.ToUpper(l.Network), l.Started.Format("15:04:05"))))
 	}
-	controls.WriteString(status)
 	controls.WriteString("\n\n")
 
 	// Control buttons
 	var startStopBtn string
-	if m.listening {
+	if m.sessions.Listener(ls.protocol, ":"+ls.port) != nil {
 		startStopBtn = ErrorStyle.Render("[S] Stop Listening")
 	} else {
 		startStopBtn = SuccessStyle.Render("[S] Start Listening")
 	}
 
-	clearBtn := WarningStyle.Render("[C] Clear Logs")
+	sessionsBtn := InfoStyle.Render("[Tab] Sessions")
 	helpBtn := InfoStyle.Render("[?] Help")
 	backBtn := MutedStyle.Render("[Esc] Back")
 
 	buttonRow := lipgloss.JoinHorizontal(lipgloss.Left,
 		startStopBtn, "  ",
-		clearBtn, "  ",
+		sessionsBtn, "  ",
 		helpBtn, "  ",
 		backBtn,
 	)
@@ -169,67 +237,3 @@ func (m Model) renderListenControls() string {
 
 	return controls.String()
 }
-
-// renderActiveConnections renders the list of active connections
-func (m Model) renderActiveConnections() string {
-	var connections strings.Builder
-
-	connTitle := InfoStyle.Render("Active Connections:")
-	connections.WriteString(connTitle)
-	connections.WriteString("\n")
-
-	if m.listening {
-		// Display real connections
-		for _, conn := range m.listenState.connections {
-			duration := time.Since(conn.StartTime).Round(time.Second)
-			connInfo := fmt.Sprintf("● %s:%s (%s) - %s - %v",
-				conn.RemoteIP, conn.Port, conn.Protocol, conn.Status, duration)
-			connections.WriteString(SuccessStyle.Render("  " + connInfo))
-			connections.WriteString("\n")
-		}
-	} else {
-		connections.WriteString(MutedStyle.Render("  No active connections"))
-		connections.WriteString("\n")
-	}
-
-	return connections.String()
-}
-
-// renderLiveLogs renders the live log messages
-func (m Model) renderLiveLogs() string {
-	var logs strings.Builder
-
-	logTitle := InfoStyle.Render("Live Logs:")
-	logs.WriteString(logTitle)
-	logs.WriteString("\n")
-
-	if m.listening {
-		// Display real log messages
-		for _, log := range m.listenState.logMessages {
-			timestamp := log.Timestamp.Format("15:04:05")
-			var levelStyle lipgloss.Style
-			switch log.Level {
-			case "INFO":
-				levelStyle = InfoStyle
-			case "WARN":
-				levelStyle = WarningStyle
-			case "ERROR":
-				levelStyle = ErrorStyle
-			default:
-				levelStyle = MutedStyle
-			}
-
-			logLine := fmt.Sprintf("[%s] %s %s",
-				timestamp,
-				levelStyle.Render(log.Level),
-				log.Message)
-			logs.WriteString("  " + logLine)
-			logs.WriteString("\n")
-		}
-	} else {
-		logs.WriteString(MutedStyle.Render("  No logs available"))
-		logs.WriteString("\n")
-	}
-
-	return logs.String()
-}
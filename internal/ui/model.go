@@ -2,10 +2,13 @@ package ui
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ibrahmsql/gocat/internal/broker"
+	"github.com/ibrahmsql/gocat/internal/chat"
 	"github.com/ibrahmsql/gocat/internal/readline"
 )
 
@@ -20,25 +23,9 @@ const (
 	ModeBroker
 	ModeScan
 	ModeHelp
+	ModeSession
 )
 
-// BrokerState represents the broker mode state
-type BrokerState struct {
-	port        string
-	protocol    string
-	connections []BrokerConnection
-	isListening bool
-	stats       BrokerStats
-}
-
-// BrokerStats represents broker statistics
-type BrokerStats struct {
-	TotalConnections  int64
-	ActiveConnections int
-	BytesTransferred  int64
-	Uptime            time.Duration
-}
-
 // Model represents the main application model
 type Model struct {
 	// Current application mode
@@ -71,6 +58,10 @@ type Model struct {
 	chatState    *ChatState
 	scanState    *ScanState
 	brokerState  *BrokerState
+	sessionState *SessionState
+
+	// Live listen and connect sessions
+	sessions *SessionManager
 
 	// Additional state fields
 	listening    bool
@@ -123,17 +114,18 @@ func NewModel() Model {
 			protocolIndex: 0,
 		},
 		listenState: &ListenState{
-			port:        "8080",
-			protocol:    "tcp",
-			connections: make([]Connection, 0),
-			logMessages: make([]LogMessage, 0),
+			port:          "8080",
+			protocol:      "tcp",
+			protocols:     []string{"tcp", "udp"},
+			protocolIndex: 0,
 		},
+		sessionState: &SessionState{},
+		sessions:     NewSessionManager(),
 		chatState: &ChatState{
-			messages:    make([]ChatMessage, 0),
-			inputBuffer: "",
-			connected:   false,
-			remoteHost:  "",
-			scrollPos:   0,
+			server:   "localhost:9000",
+			room:     chat.DefaultRoom,
+			messages: make([]ChatMessage, 0),
+			host:     &HubState{},
 		},
 		scanState: &ScanState{
 			targetHost: "",
@@ -145,11 +137,9 @@ func NewModel() Model {
 			scanType:   "tcp",
 		},
 		brokerState: &BrokerState{
-			port:        "8080",
-			protocol:    "tcp",
-			connections: make([]BrokerConnection, 0),
-			isListening: false,
-			stats:       BrokerStats{},
+			port:     "9000",
+			maxConns: strconv.Itoa(broker.DefaultMaxConns),
+			hub:      &HubState{},
 		},
 		listening:    false,
 		lastActivity: time.Now(),
@@ -161,7 +151,16 @@ func NewModel() Model {
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
-	return nil
+	return m.sessions.wait()
+}
+
+// Close releases everything the TUI owns: sessions, listeners, hosted
+// broker and chat servers and the chat connection
+func (m *Model) Close() {
+	m.sessions.Close()
+	m.brokerState.hub.stop()
+	m.chatState.host.stop()
+	m.disconnectChat()
 }
 
 // Update handles messages and updates the model
@@ -172,6 +171,27 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		return m, nil
 
+	case scanResultsMsg:
+		return m.handleScanResults(msg)
+
+	case scanTickMsg:
+		return m.handleScanTick(msg)
+
+	case sessionEventMsg:
+		return m, m.sessions.wait()
+
+	case sessionDialedMsg:
+		return m.handleSessionDialed(msg)
+
+	case hubTickMsg:
+		return m.handleHubTick(msg)
+
+	case chatDialedMsg:
+		return m.handleChatDialed(msg)
+
+	case chatLineMsg:
+		return m.handleChatLine(msg)
+
 	case tea.KeyMsg:
 		// Handle readline mode if enabled
 		if m.readlineMode && m.readlineEditor != nil {
@@ -266,6 +286,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			// For other modes, let mode-specific handlers deal with it
 		case "esc":
+			if m.mode == ModeSession {
+				return m.updateSession(msg)
+			}
 			m.mode = ModeMenu
 			m.errorMsg = ""
 			m.successMsg = ""
@@ -307,6 +330,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateScan(msg)
 		case ModeHelp:
 			return m.updateHelp(msg)
+		case ModeSession:
+			return m.updateSession(msg)
 		}
 	}
 
@@ -334,6 +359,8 @@ func (m Model) View() string {
 		return m.viewScan()
 	case ModeHelp:
 		return m.viewHelp()
+	case ModeSession:
+		return m.viewSession()
 	default:
 		return "Unknown mode\n"
 	}
@@ -358,6 +385,17 @@ func (m *Model) setSuccess(msg string) {
 	m.errorMsg = ""
 }
 
+// renderStatusMessage renders the current error or success message
+func (m Model) renderStatusMessage() string {
+	switch {
+	case m.errorMsg != "":
+		return ErrorStyle.Render("✗ " + m.errorMsg)
+	case m.successMsg != "":
+		return SuccessStyle.Render("✓ " + m.successMsg)
+	}
+	return ""
+}
+
 // addToHistory adds a command to readline history
 func (m *Model) addToHistory(command string) {
 	if m.historyEnabled && m.readlineEditor != nil {
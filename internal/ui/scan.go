@@ -1,74 +1,478 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"net/netip"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/ibrahmsql/gocat/internal/scanner"
 )
 
 // ScanResult represents a port scan result
-type ScanResult struct {
-	Host    string
-	Port    int
-	Status  string
-	Service string
-	Banner  string
+type ScanResult = scanner.Result
+
+// Scan form fields, cycled with Tab
+const (
+	scanFieldTarget = iota
+	scanFieldPorts
+	scanFieldType
+	scanFieldResults
+	scanFieldCount
+)
+
+// Result table sort columns, cycled with o
+const (
+	scanSortHost = iota
+	scanSortPort
+	scanSortStatus
+	scanSortService
+	scanSortLatency
+	scanSortCount
+)
+
+var scanSortNames = []string{"host", "port", "status", "service", "latency"}
+
+// scanType is a selectable scan mode
+type scanType struct {
+	id      string
+	label   string
+	network string
+	banner  bool
 }
 
+var scanTypes = []scanType{
+	{id: "tcp", label: "TCP Connect", network: "tcp"},
+	{id: "service", label: "Service Scan", network: "tcp", banner: true},
+	{id: "udp", label: "UDP Scan", network: "udp"},
+}
+
+// maxScanBatch bounds the results delivered in one message
+const maxScanBatch = 256
+
 // ScanState represents the state of the scan mode
 type ScanState struct {
 	targetHost string
 	portRange  string
 	scanType   string
+	focused    int
 	results    []ScanResult
 	scanning   bool
 	progress   int
 	totalPorts int
+
+	// Running scan
+	scanID    int
+	cancel    context.CancelFunc
+	startTime time.Time
+	endTime   time.Time
+	counts    map[scanner.Status]int
+
+	// Result table
+	sortBy     int
+	sortDesc   bool
+	showClosed bool
+	selected   int
+	view       []ScanResult
+	viewDirty  bool
+}
+
+// scanResultsMsg delivers a batch of results from a running scan
+type scanResultsMsg struct {
+	id      int
+	results []ScanResult
+	done    bool
+	next    tea.Cmd
+}
+
+// scanTickMsg refreshes elapsed time and ETA while a scan runs
+type scanTickMsg struct {
+	id int
 }
 
 // updateScan handles scan mode input
 func (m Model) updateScan(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "q", "ctrl+c":
+	s := m.scanState
+	key := msg.String()
+
+	switch key {
+	case "ctrl+c":
+		if s.scanning {
+			m.stopScan()
+			return m, nil
+		}
 		return m, tea.Quit
 
-	case "esc":
-		m.switchToMode(ModeMenu)
+	case "tab":
+		s.focused = (s.focused + 1) % scanFieldCount
+		return m, nil
+
+	case "shift+tab":
+		s.focused = (s.focused + scanFieldCount - 1) % scanFieldCount
 		return m, nil
 
+	case "enter":
+		if s.focused != scanFieldResults && !s.scanning {
+			return m.startScan()
+		}
+		return m, nil
+	}
+
+	switch s.focused {
+	case scanFieldTarget:
+		s.targetHost = editField(s.targetHost, msg)
+		return m, nil
+	case scanFieldPorts:
+		s.portRange = editField(s.portRange, msg)
+		return m, nil
+	case scanFieldType:
+		switch key {
+		case "left", "h":
+			s.scanType = scanTypes[(s.scanTypeIndex()+len(scanTypes)-1)%len(scanTypes)].id
+		case "right", "l", " ":
+			s.scanType = scanTypes[(s.scanTypeIndex()+1)%len(scanTypes)].id
+		}
+		return m, nil
+	}
+
+	// Result table shortcuts
+	switch key {
+	case "q":
+		m.stopScan()
+		return m, tea.Quit
+
 	case "?":
 		m.switchToMode(ModeHelp)
 		return m, nil
 
 	case "s":
 		// Start/Stop scan
-		if m.listening { // Using listening as scanning flag
-			m.listening = false
-			m.setSuccess("Scan stopped")
-		} else {
-			m.listening = true
-			m.setSuccess("Starting port scan...")
+		if s.scanning {
+			m.stopScan()
+			return m, nil
 		}
-		return m, nil
+		return m.startScan()
 
 	case "c":
 		// Clear results
-		m.messages = []string{}
+		if s.scanning {
+			m.setError("Stop the scan before clearing results")
+			return m, nil
+		}
+		s.results = s.results[:0]
+		s.counts = make(map[scanner.Status]int)
+		s.progress, s.totalPorts, s.selected = 0, 0, 0
+		s.viewDirty = true
 		m.setSuccess("Scan results cleared")
 		return m, nil
 
 	case "e":
-		// Export results
-		m.setSuccess("Results exported to scan_results.txt")
+		m.exportScan("json")
+		return m, nil
+
+	case "E":
+		m.exportScan("csv")
+		return m, nil
+
+	case "o":
+		s.sortBy = (s.sortBy + 1) % scanSortCount
+		s.viewDirty = true
+		return m, nil
+
+	case "r":
+		s.sortDesc = !s.sortDesc
+		s.viewDirty = true
+		return m, nil
+
+	case "a":
+		s.showClosed = !s.showClosed
+		s.viewDirty = true
+		s.selected = 0
 		return m, nil
+
+	case "up", "k":
+		if s.selected > 0 {
+			s.selected--
+		}
+	case "down", "j":
+		if s.selected < len(s.visibleResults())-1 {
+			s.selected++
+		}
+	case "pgup":
+		s.selected = max(s.selected-m.scanTableRows(), 0)
+	case "pgdown":
+		s.selected = max(min(s.selected+m.scanTableRows(), len(s.visibleResults())-1), 0)
+	case "home", "g":
+		s.selected = 0
+	case "end", "G":
+		s.selected = max(len(s.visibleResults())-1, 0)
 	}
 
 	return m, nil
 }
 
+// editField applies a text editing key to an input field value
+func editField(value string, msg tea.KeyMsg) string {
+	switch msg.Type {
+	case tea.KeyBackspace:
+		if len(value) > 0 {
+			return value[:len(value)-1]
+		}
+	case tea.KeyCtrlU:
+		return ""
+	case tea.KeyRunes, tea.KeySpace:
+		return value + strings.TrimSpace(string(msg.Runes))
+	}
+	return value
+}
+
+func (s *ScanState) scanTypeIndex() int {
+	for i, t := range scanTypes {
+		if t.id == s.scanType {
+			return i
+		}
+	}
+	return 0
+}
+
+// startScan validates the form and starts the scan engine as a command
+func (m *Model) startScan() (tea.Model, tea.Cmd) {
+	s := m.scanState
+
+	hosts, err := scanner.ExpandTargets(s.targetHost)
+	if err != nil {
+		s.focused = scanFieldTarget
+		m.setError("Invalid target: " + err.Error())
+		return *m, nil
+	}
+	ports, err := scanner.ParsePorts(s.portRange)
+	if err != nil {
+		s.focused = scanFieldPorts
+		m.setError("Invalid port range: " + err.Error())
+		return *m, nil
+	}
+
+	kind := scanTypes[s.scanTypeIndex()]
+	ctx, cancel := context.WithCancel(context.Background())
+	results := scanner.New(scanner.Options{
+		Network: kind.network,
+		Banner:  kind.banner,
+	}).Scan(ctx, hosts, ports)
+
+	s.scanID++
+	s.cancel = cancel
+	s.scanning = true
+	s.results = s.results[:0]
+	s.counts = make(map[scanner.Status]int)
+	s.progress = 0
+	s.totalPorts = len(hosts) * len(ports)
+	s.startTime = time.Now()
+	s.endTime = time.Time{}
+	s.selected = 0
+	s.viewDirty = true
+	s.focused = scanFieldResults
+
+	m.setSuccess(fmt.Sprintf("Scanning %d host(s), %d port(s) with %s", len(hosts), len(ports), kind.label))
+	return *m, tea.Batch(waitForScanResults(s.scanID, results), scanTick(s.scanID))
+}
+
+// stopScan cancels the running scan, keeping the results gathered so far
+func (m *Model) stopScan() {
+	s := m.scanState
+	if !s.scanning {
+		return
+	}
+	s.cancel()
+	s.scanID++ // drop the batches still queued by the cancelled scan
+	s.scanning = false
+	s.endTime = time.Now()
+	m.setSuccess(fmt.Sprintf("Scan stopped after %d of %d probes", s.progress, s.totalPorts))
+}
+
+// waitForScanResults returns a command that delivers the next batch of results
+func waitForScanResults(id int, results <-chan scanner.Result) tea.Cmd {
+	return func() tea.Msg {
+		first, ok := <-results
+		if !ok {
+			return scanResultsMsg{id: id, done: true}
+		}
+
+		batch := []ScanResult{first}
+		for len(batch) < maxScanBatch {
+			select {
+			case r, ok := <-results:
+				if !ok {
+					return scanResultsMsg{id: id, results: batch, done: true}
+				}
+				batch = append(batch, r)
+			default:
+				return scanResultsMsg{id: id, results: batch, next: waitForScanResults(id, results)}
+			}
+		}
+		return scanResultsMsg{id: id, results: batch, next: waitForScanResults(id, results)}
+	}
+}
+
+func scanTick(id int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return scanTickMsg{id: id} })
+}
+
+// handleScanResults records a batch of results and waits for the next one
+func (m Model) handleScanResults(msg scanResultsMsg) (tea.Model, tea.Cmd) {
+	s := m.scanState
+	if msg.id != s.scanID {
+		return m, nil
+	}
+
+	for _, r := range msg.results {
+		s.results = append(s.results, r)
+		s.counts[r.Status]++
+	}
+	s.progress += len(msg.results)
+	s.viewDirty = true
+
+	if msg.done {
+		if s.scanning {
+			s.scanning = false
+			s.endTime = time.Now()
+			m.setSuccess(fmt.Sprintf("Scan complete: %d open of %d probes in %s",
+				s.counts[scanner.StatusOpen], s.progress, s.endTime.Sub(s.startTime).Round(time.Millisecond)))
+		}
+		return m, nil
+	}
+	return m, msg.next
+}
+
+// handleScanTick keeps the progress line current while results are slow
+func (m Model) handleScanTick(msg scanTickMsg) (tea.Model, tea.Cmd) {
+	if msg.id != m.scanState.scanID || !m.scanState.scanning {
+		return m, nil
+	}
+	return m, scanTick(msg.id)
+}
+
+// visibleResults returns the results shown in the table, filtered and sorted
+func (s *ScanState) visibleResults() []ScanResult {
+	if !s.viewDirty && s.view != nil {
+		return s.view
+	}
+
+	view := make([]ScanResult, 0, len(s.results))
+	for _, r := range s.results {
+		if s.showClosed || r.Status != scanner.StatusClosed {
+			view = append(view, r)
+		}
+	}
+
+	sort.SliceStable(view, func(i, j int) bool {
+		a, b := view[i], view[j]
+		if s.sortDesc {
+			a, b = b, a
+		}
+		switch s.sortBy {
+		case scanSortHost:
+			if a.Host != b.Host {
+				return compareHosts(a.Host, b.Host)
+			}
+			return a.Port < b.Port
+		case scanSortStatus:
+			if a.Status != b.Status {
+				return statusRank(a.Status) < statusRank(b.Status)
+			}
+		case scanSortService:
+			if a.Service != b.Service {
+				return a.Service < b.Service
+			}
+		case scanSortLatency:
+			return a.Latency < b.Latency
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return compareHosts(a.Host, b.Host)
+	})
+
+	s.view = view
+	s.viewDirty = false
+	if s.selected >= len(view) {
+		s.selected = max(len(view)-1, 0)
+	}
+	return view
+}
+
+// compareHosts orders IP addresses numerically and names alphabetically
+func compareHosts(a, b string) bool {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	if errA == nil && errB == nil {
+		return ipA.Less(ipB)
+	}
+	return a < b
+}
+
+func statusRank(status scanner.Status) int {
+	switch status {
+	case scanner.StatusOpen:
+		return 0
+	case scanner.StatusOpenFiltered:
+		return 1
+	case scanner.StatusFiltered:
+		return 2
+	}
+	return 3
+}
+
+// exportScan writes the visible results to a timestamped JSON or CSV file
+func (m *Model) exportScan(format string) {
+	s := m.scanState
+	results := s.visibleResults()
+	if len(results) == 0 {
+		m.setError("No scan results to export")
+		return
+	}
+
+	path := fmt.Sprintf("gocat-scan-%s.%s", time.Now().Format("20060102-150405"), format)
+	file, err := os.Create(path)
+	if err != nil {
+		m.setError("Export failed: " + err.Error())
+		return
+	}
+	defer file.Close()
+
+	if format == "csv" {
+		err = scanner.WriteCSV(file, results)
+	} else {
+		err = scanner.WriteJSON(file, scanner.Report{
+			Targets:  s.targetHost,
+			Ports:    s.portRange,
+			Network:  scanTypes[s.scanTypeIndex()].network,
+			Started:  s.startTime,
+			Duration: s.elapsed().Round(time.Millisecond).String(),
+			Results:  results,
+		})
+	}
+	if err != nil {
+		m.setError("Export failed: " + err.Error())
+		return
+	}
+	m.setSuccess(fmt.Sprintf("Exported %d results to %s", len(results), path))
+}
+
+func (s *ScanState) elapsed() time.Duration {
+	switch {
+	case s.startTime.IsZero():
+		return 0
+	case s.endTime.IsZero():
+		return time.Since(s.startTime)
+	}
+	return s.endTime.Sub(s.startTime)
+}
+
 // viewScan renders the scan interface
 func (m Model) viewScan() string {
 	var content strings.Builder
@@ -89,7 +493,7 @@ func (m Model) viewScan() string {
 	content.WriteString("\n\n")
 
 	// Scan progress
-	if m.listening { // Using listening as scanning flag
+	if m.scanState.totalPorts > 0 {
 		progress := m.renderScanProgress()
 		content.WriteString(progress)
 		content.WriteString("\n\n")
@@ -99,42 +503,56 @@ func (m Model) viewScan() string {
 	results := m.renderScanResults()
 	content.WriteString(results)
 
+	if status := m.renderStatusMessage(); status != "" {
+		content.WriteString("\n\n")
+		content.WriteString(status)
+	}
+
 	return content.String()
 }
 
 // renderScanConfig renders scan configuration
 func (m Model) renderScanConfig() string {
 	var config strings.Builder
+	s := m.scanState
+
+	field := func(label, value, placeholder string, index int) string {
+		style := BoxStyle.Width(40)
+		if value == "" {
+			value = MutedStyle.Render(placeholder)
+		}
+		if s.focused == index {
+			style = style.BorderForeground(PrimaryColor)
+			value += "█"
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Center, InfoStyle.Width(13).Render(label), style.Render(value))
+	}
 
 	// Target host
-	hostLabel := InfoStyle.Render("Target Host:")
-	hostValue := BoxStyle.Width(25).Render("192.168.1.1")
-	hostRow := lipgloss.JoinHorizontal(lipgloss.Left, hostLabel, "  ", hostValue)
-	config.WriteString(hostRow)
+	config.WriteString(field("Targets:", s.targetHost, "host, 10.0.0.0/24, 10.0.0.1-20", scanFieldTarget))
 	config.WriteString("\n")
 
 	// Port range
-	portLabel := InfoStyle.Render("Port Range:")
-	portValue := BoxStyle.Width(25).Render("1-1000")
-	portRow := lipgloss.JoinHorizontal(lipgloss.Left, portLabel, "  ", portValue)
-	config.WriteString(portRow)
+	config.WriteString(field("Port Range:", s.portRange, "22,80,443,8000-8100", scanFieldPorts))
 	config.WriteString("\n")
 
 	// Scan type
-	typeLabel := InfoStyle.Render("Scan Type:")
-	scanTypes := []string{"TCP Connect", "SYN Scan", "UDP Scan", "Service Scan"}
+	typeLabel := InfoStyle.Width(13).Render("Scan Type:")
 	typeButtons := make([]string, len(scanTypes))
 
-	for i, scanType := range scanTypes {
-		if i == 0 { // TCP Connect selected by default
-			typeButtons[i] = ActiveButtonStyle.Render(scanType)
+	for i, t := range scanTypes {
+		if i == s.scanTypeIndex() {
+			typeButtons[i] = ActiveButtonStyle.Render(t.label)
 		} else {
-			typeButtons[i] = ButtonStyle.Render(scanType)
+			typeButtons[i] = ButtonStyle.Render(t.label)
 		}
 	}
+	if s.focused == scanFieldType {
+		typeLabel = HighlightStyle.Width(13).Render("Scan Type:")
+	}
 
-	typeRow := lipgloss.JoinHorizontal(lipgloss.Left,
-		typeLabel, "  ",
+	typeRow := lipgloss.JoinHorizontal(lipgloss.Center,
+		typeLabel,
 		lipgloss.JoinHorizontal(lipgloss.Left, typeButtons...),
 	)
 	config.WriteString(typeRow)
@@ -145,12 +563,16 @@ func (m Model) renderScanConfig() string {
 // renderScanControls renders scan control buttons
 func (m Model) renderScanControls() string {
 	var controls strings.Builder
+	s := m.scanState
 
 	// Status indicator
 	var status string
-	if m.listening { // Using listening as scanning flag
+	switch {
+	case s.scanning:
 		status = WarningStyle.Render("● Scanning...")
-	} else {
+	case s.totalPorts > 0:
+		status = SuccessStyle.Render("● Scan finished")
+	default:
 		status = MutedStyle.Render("● Ready to scan")
 	}
 	controls.WriteString(status)
@@ -158,22 +580,27 @@ func (m Model) renderScanControls() string {
 
 	// Control buttons
 	var startStopBtn string
-	if m.listening {
+	if s.scanning {
 		startStopBtn = ErrorStyle.Render("[S] Stop Scan")
 	} else {
-		startStopBtn = SuccessStyle.Render("[S] Start Scan")
+		startStopBtn = SuccessStyle.Render("[S/Enter] Start Scan")
 	}
 
-	clearBtn := WarningStyle.Render("[C] Clear Results")
-	exportBtn := InfoStyle.Render("[E] Export Results")
-	helpBtn := InfoStyle.Render("[?] Help")
-	backBtn := MutedStyle.Render("[Esc] Back")
+	clearBtn := WarningStyle.Render("[C] Clear")
+	exportBtn := InfoStyle.Render("[E] JSON [Shift+E] CSV")
+	sortBtn := InfoStyle.Render(fmt.Sprintf("[O] Sort: %s [R] Reverse", scanSortNames[s.sortBy]))
+	closedBtn := InfoStyle.Render("[A] Show closed")
+	if s.showClosed {
+		closedBtn = InfoStyle.Render("[A] Hide closed")
+	}
+	backBtn := MutedStyle.Render("[Tab] Fields [Esc] Back")
 
 	buttonRow := lipgloss.JoinHorizontal(lipgloss.Left,
 		startStopBtn, "  ",
 		clearBtn, "  ",
 		exportBtn, "  ",
-		helpBtn, "  ",
+		sortBtn, "  ",
+		closedBtn, "  ",
 		backBtn,
 	)
 	controls.WriteString(buttonRow)
@@ -184,26 +611,13 @@ func (m Model) renderScanControls() string {
 // renderScanProgress renders scan progress bar
 func (m Model) renderScanProgress() string {
 	var progress strings.Builder
+	s := m.scanState
 
 	progressTitle := InfoStyle.Render("Scan Progress:")
 	progress.WriteString(progressTitle)
 	progress.WriteString("\n")
 
-	// Calculate progress based on scan activity
-	var currentPort, totalPorts int
-	if m.listening { // Using listening as scanning flag
-		// Calculate realistic progress based on time
-		elapsed := time.Since(m.lastActivity).Seconds()
-		currentPort = int(elapsed * 10) // 10 ports per second
-		totalPorts = 1000
-		if currentPort > totalPorts {
-			currentPort = totalPorts
-		}
-	} else {
-		currentPort = 0
-		totalPorts = 1000
-	}
-	progressPercent := float64(currentPort) / float64(totalPorts) * 100
+	progressPercent := float64(s.progress) / float64(s.totalPorts) * 100
 
 	// Progress bar
 	barWidth := 50
@@ -213,68 +627,117 @@ func (m Model) renderScanProgress() string {
 	progressBar := SuccessStyle.Render(strings.Repeat("█", filledWidth)) +
 		MutedStyle.Render(strings.Repeat("░", emptyWidth))
 
-	progressInfo := fmt.Sprintf("[%s] %.1f%% (%d/%d ports)",
-		progressBar, progressPercent, currentPort, totalPorts)
+	progressInfo := fmt.Sprintf("[%s] %.1f%% (%d/%d probes)",
+		progressBar, progressPercent, s.progress, s.totalPorts)
 
 	progress.WriteString("  " + progressInfo)
 	progress.WriteString("\n")
 
-	// Current scanning info
-	currentInfo := MutedStyle.Render(fmt.Sprintf("  Currently scanning: 192.168.1.1:%d", currentPort))
-	progress.WriteString(currentInfo)
+	// Rate and ETA
+	elapsed := s.elapsed()
+	info := fmt.Sprintf("  Elapsed: %s", elapsed.Round(time.Second))
+	if elapsed > 0 && s.progress > 0 {
+		rate := float64(s.progress) / elapsed.Seconds()
+		info += fmt.Sprintf("  Rate: %.0f/s", rate)
+		if s.scanning {
+			eta := time.Duration(float64(s.totalPorts-s.progress) / rate * float64(time.Second))
+			info += fmt.Sprintf("  ETA: %s", eta.Round(time.Second))
+		}
+	}
+	info += fmt.Sprintf("  Open: %d  Closed: %d  Filtered: %d",
+		s.counts[scanner.StatusOpen],
+		s.counts[scanner.StatusClosed],
+		s.counts[scanner.StatusFiltered]+s.counts[scanner.StatusOpenFiltered])
+	progress.WriteString(MutedStyle.Render(info))
 
 	return progress.String()
 }
 
+// scanTableRows returns how many result rows fit on screen
+func (m Model) scanTableRows() int {
+	return max(m.height-24, 5)
+}
+
 // renderScanResults renders scan results
 func (m Model) renderScanResults() string {
 	var results strings.Builder
+	s := m.scanState
 
-	resultsTitle := InfoStyle.Render("Scan Results:")
+	view := s.visibleResults()
+	resultsTitle := InfoStyle.Render(fmt.Sprintf("Scan Results (%d):", len(view)))
+	if s.focused == scanFieldResults {
+		resultsTitle = HighlightStyle.Render(fmt.Sprintf("Scan Results (%d):", len(view)))
+	}
 	results.WriteString(resultsTitle)
 	results.WriteString("\n")
 
-	if len(m.scanState.results) > 0 {
-		// Display real scan results
-		// Results header
-		header := fmt.Sprintf("%-15s %-6s %-10s %-10s %s",
-			"HOST", "PORT", "STATUS", "SERVICE", "BANNER")
-		results.WriteString(MutedStyle.Render("  " + header))
-		results.WriteString("\n")
-		results.WriteString(MutedStyle.Render("  " + strings.Repeat("-", 70)))
-		results.WriteString("\n")
+	if len(view) == 0 {
+		if len(s.results) > 0 {
+			results.WriteString(MutedStyle.Render("  No open ports yet. Press 'a' to show closed ports."))
+		} else {
+			results.WriteString(MutedStyle.Render("  No scan results yet. Start a scan to see results here."))
+		}
+		return results.String()
+	}
 
-		// Results data
-		for _, result := range m.scanState.results {
-			var statusStyle lipgloss.Style
-			switch result.Status {
-			case "Open":
-				statusStyle = SuccessStyle
-			case "Closed":
-				statusStyle = ErrorStyle
-			case "Filtered":
-				statusStyle = WarningStyle
-			default:
-				statusStyle = MutedStyle
-			}
+	// Results header
+	header := fmt.Sprintf("%-18s %-6s %-14s %-12s %-9s %s",
+		"HOST", "PORT", "STATUS", "SERVICE", "LATENCY", "BANNER")
+	results.WriteString(MutedStyle.Render("  " + header))
+	results.WriteString("\n")
+	results.WriteString(MutedStyle.Render("  " + strings.Repeat("-", 80)))
+	results.WriteString("\n")
 
-			resultLine := fmt.Sprintf("%-15s %-6d %-10s %-10s %s",
-				result.Host,
-				result.Port,
-				statusStyle.Render(result.Status),
-				result.Service,
-				result.Banner)
+	// Keep the selected row in the window
+	rows := m.scanTableRows()
+	start := 0
+	if s.selected >= rows {
+		start = s.selected - rows + 1
+	}
+	end := min(start+rows, len(view))
+
+	bannerWidth := max(m.width-70, 10)
+	for i := start; i < end; i++ {
+		result := view[i]
+		var statusStyle lipgloss.Style
+		switch result.Status {
+		case scanner.StatusOpen:
+			statusStyle = SuccessStyle
+		case scanner.StatusClosed:
+			statusStyle = ErrorStyle
+		case scanner.StatusFiltered, scanner.StatusOpenFiltered:
+			statusStyle = WarningStyle
+		default:
+			statusStyle = MutedStyle
+		}
 
-			results.WriteString("  " + resultLine)
-			results.WriteString("\n")
+		banner := result.Banner
+		if len(banner) > bannerWidth {
+			banner = banner[:bannerWidth-1] + "…"
+		}
+		latency := ""
+		if result.Latency > 0 {
+			latency = result.Latency.Round(100 * time.Microsecond).String()
 		}
 
-		// Summary
+		resultLine := fmt.Sprintf("%-18s %-6d %s %-12s %-9s %s",
+			result.Host,
+			result.Port,
+			statusStyle.Render(fmt.Sprintf("%-14s", strings.ToUpper(string(result.Status)))),
+			result.Service,
+			latency,
+			banner)
+
+		if i == s.selected && s.focused == scanFieldResults {
+			results.WriteString(HighlightStyle.Render("▶") + " " + resultLine)
+		} else {
+			results.WriteString("  " + resultLine)
+		}
 		results.WriteString("\n")
-		summary := MutedStyle.Render("  Summary: 5 open, 1 closed, 2 filtered ports found")
-		results.WriteString(summary)
-	} else {
-		results.WriteString(MutedStyle.Render("  No scan results yet. Start a scan to see results here."))
+	}
+
+	if len(view) > rows {
+		results.WriteString(MutedStyle.Render(fmt.Sprintf("  Showing %d-%d of %d (↑/↓, PgUp/PgDn)", start+1, end, len(view))))
 	}
 
 	return results.String()
@@ -0,0 +1,478 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SessionState represents the session list and the attached session pane
+type SessionState struct {
+	cursor     int     // selected row in the session list
+	attached   int     // ID of the attached session
+	returnMode AppMode // mode to return to on detach
+	input      string
+	scroll     int // lines scrolled up from the bottom of the pane
+	inspector  *InspectorState
+}
+
+// transcriptLine is one rendered line of a session transcript
+type transcriptLine struct {
+	Time time.Time
+	Dir  Direction
+	Text string
+}
+
+// updateSessionList handles keys for the session list shared by the listen
+// and connect modes
+func (m Model) updateSessionList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	st := m.sessionState
+	sessions := m.sessions.Sessions()
+
+	switch msg.String() {
+	case "up", "k":
+		if st.cursor > 0 {
+			st.cursor--
+		}
+	case "down", "j":
+		if st.cursor < len(sessions)-1 {
+			st.cursor++
+		}
+	case "enter", "a":
+		if st.cursor < len(sessions) {
+			m.attachSession(sessions[st.cursor])
+		}
+	case "x":
+		if st.cursor < len(sessions) {
+			s := sessions[st.cursor]
+			s.Close()
+			m.setSuccess(fmt.Sprintf("Killed session #%d", s.ID))
+		}
+	case "w":
+		if st.cursor < len(sessions) {
+			m.saveTranscript(sessions[st.cursor])
+		}
+	case "c":
+		removed := m.sessions.Prune()
+		m.sessions.ClearLogs()
+		st.cursor = 0
+		m.setSuccess(fmt.Sprintf("Cleared logs and %d closed session(s)", removed))
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// attachSession shows a session's byte stream in the session pane
+func (m *Model) attachSession(s *Session) {
+	st := m.sessionState
+	if m.mode != ModeSession {
+		st.returnMode = m.mode
+	}
+	st.attached = s.ID
+	st.input = ""
+	st.scroll = 0
+	st.inspector = newInspectorState()
+	m.switchToMode(ModeSession)
+}
+
+// detachSession leaves the session pane, keeping the session running
+func (m *Model) detachSession() {
+	returnMode := m.sessionState.returnMode
+	if returnMode == ModeSession || returnMode == ModeMenu {
+		returnMode = ModeListen
+	}
+	m.switchToMode(returnMode)
+}
+
+// cycleSession attaches to the next (delta 1) or previous (delta -1) session
+func (m *Model) cycleSession(delta int) {
+	sessions := m.sessions.Sessions()
+	if len(sessions) == 0 {
+		return
+	}
+	current := 0
+	for i, s := range sessions {
+		if s.ID == m.sessionState.attached {
+			current = i
+		}
+	}
+	next := (current + delta + len(sessions)) % len(sessions)
+	m.sessionState.cursor = next
+	m.attachSession(sessions[next])
+}
+
+// saveTranscript writes a session transcript to a timestamped file
+func (m *Model) saveTranscript(s *Session) {
+	path := fmt.Sprintf("gocat-session-%d-%s.log", s.ID, time.Now().Format("20060102-150405"))
+	file, err := os.Create(path)
+	if err != nil {
+		m.setError("Save failed: " + err.Error())
+		return
+	}
+	defer file.Close()
+
+	chunks, dropped := s.Chunks()
+	fmt.Fprintf(file, "# session #%d %s %s %s -> %s started %s\n",
+		s.ID, s.Kind, s.Network, s.LocalAddr, s.RemoteAddr, s.Started.Format(time.RFC3339))
+	if dropped > 0 {
+		fmt.Fprintf(file, "# %d earlier bytes were dropped from the transcript\n", dropped)
+	}
+	for _, line := range transcriptLines(chunks) {
+		marker := "<"
+		if line.Dir == DirOut {
+			marker = ">"
+		}
+		if _, err := fmt.Fprintf(file, "%s %s %s\n", line.Time.Format("15:04:05.000"), marker, line.Text); err != nil {
+			m.setError("Save failed: " + err.Error())
+			return
+		}
+	}
+	m.setSuccess(fmt.Sprintf("Saved session #%d transcript to %s", s.ID, path))
+}
+
+// transcriptLines splits chunks into printable lines. A line continues
+// across chunks of the same direction until a newline is seen.
+func transcriptLines(chunks []Chunk) []transcriptLine {
+	var lines []transcriptLine
+	open := false
+
+	for _, chunk := range chunks {
+		if open && lines[len(lines)-1].Dir != chunk.Dir {
+			open = false
+		}
+		for _, part := range strings.SplitAfter(string(chunk.Data), "\n") {
+			if part == "" {
+				continue
+			}
+			ended := strings.HasSuffix(part, "\n")
+			text := escapeText(strings.TrimRight(part, "\r\n"))
+			if open {
+				lines[len(lines)-1].Text += text
+			} else {
+				lines = append(lines, transcriptLine{Time: chunk.Time, Dir: chunk.Dir, Text: text})
+			}
+			open = !ended
+		}
+	}
+	return lines
+}
+
+// escapeText replaces control characters and invalid UTF-8 with escapes
+func escapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+		case r == '\t':
+			b.WriteString("    ")
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// sessionPaneHeight returns how many transcript lines fit on screen
+func (m Model) sessionPaneHeight() int {
+	return max(m.height-10, 5)
+}
+
+// updateSession handles input while attached to a session
+func (m Model) updateSession(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	st := m.sessionState
+	s := m.sessions.Session(st.attached)
+	if s == nil {
+		m.detachSession()
+		return m, nil
+	}
+
+	if st.inspector.open {
+		return m.updateInspector(msg, s)
+	}
+
+	switch msg.String() {
+	case "esc", "ctrl+c", "ctrl+d":
+		m.detachSession()
+		return m, nil
+
+	case "ctrl+t":
+		st.inspector.open = true
+		return m, nil
+
+	case "enter":
+		if err := s.Send([]byte(st.input + "\n")); err != nil {
+			m.setError("Send failed: " + err.Error())
+			return m, nil
+		}
+		st.input = ""
+		st.scroll = 0
+		return m, nil
+
+	case "ctrl+x":
+		s.Close()
+		m.setSuccess(fmt.Sprintf("Killed session #%d", s.ID))
+		return m, nil
+
+	case "ctrl+s":
+		m.saveTranscript(s)
+		return m, nil
+
+	case "ctrl+n":
+		m.cycleSession(1)
+		return m, nil
+
+	case "ctrl+p":
+		m.cycleSession(-1)
+		return m, nil
+
+	case "up":
+		st.scroll++
+	case "down":
+		st.scroll = max(st.scroll-1, 0)
+	case "pgup":
+		st.scroll += m.sessionPaneHeight()
+	case "pgdown":
+		st.scroll = max(st.scroll-m.sessionPaneHeight(), 0)
+	case "end":
+		st.scroll = 0
+	default:
+		st.input = editText(st.input, msg)
+	}
+	return m, nil
+}
+
+// editText applies a key to free text input; unlike editField it keeps
+// spaces
+func editText(value string, msg tea.KeyMsg) string {
+	switch msg.Type {
+	case tea.KeyBackspace:
+		if len(value) > 0 {
+			_, size := utf8.DecodeLastRuneInString(value)
+			return value[:len(value)-size]
+		}
+	case tea.KeyCtrlU:
+		return ""
+	case tea.KeySpace:
+		return value + " "
+	case tea.KeyRunes:
+		return value + string(msg.Runes)
+	}
+	return value
+}
+
+// viewSession renders the attached session pane
+func (m Model) viewSession() string {
+	var content strings.Builder
+	st := m.sessionState
+
+	s := m.sessions.Session(st.attached)
+	if s == nil {
+		return MutedStyle.Render("Session no longer exists. Press Esc to go back.")
+	}
+
+	// Header
+	in, out, duration := s.Stats()
+	title := HeaderStyle.Render(fmt.Sprintf("🖧 Session #%d", s.ID))
+	var status string
+	switch s.Status() {
+	case "active":
+		status = StatusConnected()
+	case "error":
+		status = ErrorStyle.Render("● " + s.Err().Error())
+	default:
+		status = StatusDisconnected()
+	}
+	info := MutedStyle.Render(fmt.Sprintf("%s %s  %s ⇄ %s  in %s  out %s  %s",
+		s.Kind, strings.ToUpper(s.Network), s.LocalAddr, s.RemoteAddr,
+		formatBytes(in), formatBytes(out), duration.Round(time.Second)))
+	content.WriteString(title)
+	content.WriteString("\n")
+	content.WriteString(status + "  " + info)
+	content.WriteString("\n\n")
+
+	if st.inspector.open {
+		// Traffic inspector
+		content.WriteString(BoxStyle.Width(max(m.width-2, 40)).Render(m.renderInspector(s)))
+		content.WriteString("\n")
+		content.WriteString(m.renderInspectorStatus())
+		content.WriteString("\n")
+		if status := m.renderStatusMessage(); status != "" {
+			content.WriteString(status)
+			content.WriteString("\n")
+		}
+		content.WriteString(HelpStyle.Render("↑/↓: Chunk  v: View  d: Direction  /: Search  n/N: Match  b: Bookmark  [/]: Prev/Next bookmark  Space: Select  e/E: Export hex/raw  Ctrl+T: Close"))
+		return content.String()
+	}
+
+	// Transcript pane
+	content.WriteString(BoxStyle.Width(max(m.width-2, 40)).Render(m.renderTranscript(s)))
+	content.WriteString("\n")
+
+	// Input line
+	prompt := SuccessStyle.Render("> ")
+	content.WriteString(prompt + st.input + "█")
+	if st.scroll > 0 {
+		content.WriteString(MutedStyle.Render(fmt.Sprintf("  (scrolled up %d lines, End to follow)", st.scroll)))
+	}
+	content.WriteString("\n")
+
+	if status := m.renderStatusMessage(); status != "" {
+		content.WriteString(status)
+		content.WriteString("\n")
+	}
+	content.WriteString(HelpStyle.Render("Enter: Send  Esc/Ctrl+D: Detach  Ctrl+T: Inspect  Ctrl+X: Kill  Ctrl+S: Save  Ctrl+N/P: Next/Prev session  ↑/↓ PgUp/PgDn: Scroll"))
+
+	return content.String()
+}
+
+// renderTranscript renders the tail of a session transcript as text lines
+func (m Model) renderTranscript(s *Session) string {
+	st := m.sessionState
+	chunks, dropped := s.Chunks()
+	lines := transcriptLines(chunks)
+	height := m.sessionPaneHeight()
+	st.scroll = min(st.scroll, max(len(lines)-height, 0))
+	end := len(lines) - st.scroll
+	start := max(end-height, 0)
+
+	var pane strings.Builder
+	if dropped > 0 && start == 0 {
+		pane.WriteString(MutedStyle.Render(fmt.Sprintf("… %s dropped", formatBytes(dropped))))
+		pane.WriteString("\n")
+	}
+	width := max(m.width-10, 20)
+	for _, line := range lines[start:end] {
+		text := line.Text
+		if len(text) > width {
+			text = text[:width]
+		}
+		if line.Dir == DirOut {
+			pane.WriteString(InfoStyle.Render("> " + text))
+		} else {
+			pane.WriteString("  " + text)
+		}
+		pane.WriteString("\n")
+	}
+	if len(lines) == 0 {
+		pane.WriteString(MutedStyle.Render("No data yet"))
+	}
+	return strings.TrimRight(pane.String(), "\n")
+}
+
+// renderActiveConnections renders the list of sessions
+func (m Model) renderActiveConnections() string {
+	var connections strings.Builder
+	st := m.sessionState
+	sessions := m.sessions.Sessions()
+
+	connTitle := InfoStyle.Render(fmt.Sprintf("Sessions (%d):", len(sessions)))
+	if m.sessionListFocused() {
+		connTitle = HighlightStyle.Render(fmt.Sprintf("Sessions (%d):", len(sessions)))
+	}
+	connections.WriteString(connTitle)
+	connections.WriteString("\n")
+
+	if len(sessions) == 0 {
+		connections.WriteString(MutedStyle.Render("  No active connections"))
+		connections.WriteString("\n")
+		return connections.String()
+	}
+
+	header := fmt.Sprintf("%-4s %-8s %-5s %-24s %-9s %-9s %-8s %s",
+		"ID", "KIND", "PROTO", "PEER", "IN", "OUT", "TIME", "STATUS")
+	connections.WriteString(MutedStyle.Render("  " + header))
+	connections.WriteString("\n")
+
+	for i, s := range sessions {
+		in, out, duration := s.Stats()
+		status := s.Status()
+		line := fmt.Sprintf("%-4s %-8s %-5s %-24s %-9s %-9s %-8s %s",
+			"#"+strconv.Itoa(s.ID), s.Kind, strings.ToUpper(s.Network), s.RemoteAddr,
+			formatBytes(in), formatBytes(out), duration.Round(time.Second), status)
+
+		style := MutedStyle
+		if status == "active" {
+			style = SuccessStyle
+		} else if status == "error" {
+			style = ErrorStyle
+		}
+
+		if i == st.cursor && m.sessionListFocused() {
+			connections.WriteString(HighlightStyle.Render("▶") + " " + style.Render(line))
+		} else {
+			connections.WriteString("  " + style.Render(line))
+		}
+		connections.WriteString("\n")
+	}
+
+	if m.sessionListFocused() {
+		connections.WriteString(HelpStyle.Render("  Enter: Attach  x: Kill  w: Save transcript  c: Clear closed"))
+	}
+	return connections.String()
+}
+
+// sessionListFocused reports whether keys go to the session list
+func (m Model) sessionListFocused() bool {
+	switch m.mode {
+	case ModeListen:
+		return m.listenState.focused == listenFieldSessions
+	case ModeConnect:
+		return m.connectState.focused == connectFieldSessions
+	}
+	return false
+}
+
+// renderLiveLogs renders the session and listener log
+func (m Model) renderLiveLogs() string {
+	var logs strings.Builder
+
+	logTitle := InfoStyle.Render("Live Logs:")
+	logs.WriteString(logTitle)
+	logs.WriteString("\n")
+
+	messages := m.sessions.Logs()
+	if len(messages) == 0 {
+		logs.WriteString(MutedStyle.Render("  No logs available"))
+		logs.WriteString("\n")
+		return logs.String()
+	}
+
+	if limit := max(m.height-28, 5); len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	for _, log := range messages {
+		timestamp := log.Timestamp.Format("15:04:05")
+		var levelStyle lipgloss.Style
+		switch log.Level {
+		case "INFO":
+			levelStyle = InfoStyle
+		case "WARN":
+			levelStyle = WarningStyle
+		case "ERROR":
+			levelStyle = ErrorStyle
+		default:
+			levelStyle = MutedStyle
+		}
+
+		logLine := fmt.Sprintf("[%s] %s %s",
+			timestamp,
+			levelStyle.Render(log.Level),
+			log.Message)
+		logs.WriteString("  " + logLine)
+		logs.WriteString("\n")
+	}
+
+	return logs.String()
+}
@@ -0,0 +1,476 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Direction is the direction of a transcript chunk
+type Direction int
+
+const (
+	// DirIn marks bytes received from the peer
+	DirIn Direction = iota
+	// DirOut marks bytes sent to the peer
+	DirOut
+)
+
+func (d Direction) String() string {
+	if d == DirOut {
+		return "out"
+	}
+	return "in"
+}
+
+// Chunk is a single read from or write to a session
+type Chunk struct {
+	Time time.Time
+	Dir  Direction
+	// Offset is the number of bytes transferred in Dir before this chunk
+	Offset int64
+	Data   []byte
+}
+
+const (
+	// maxTranscriptBytes bounds the transcript kept per session; the oldest
+	// chunks are dropped first
+	maxTranscriptBytes = 1 << 20
+
+	maxSessionLogs  = 200
+	sessionReadSize = 32 * 1024
+	dialTimeout     = 10 * time.Second
+)
+
+// Session is a live connection owned by the TUI
+type Session struct {
+	ID         int
+	Kind       string // "listen" or "connect"
+	Network    string
+	LocalAddr  string
+	RemoteAddr string
+	Started    time.Time
+
+	manager *SessionManager
+	conn    io.WriteCloser
+
+	mu       sync.Mutex
+	ended    time.Time
+	err      error
+	bytesIn  int64
+	bytesOut int64
+	chunks   []Chunk
+	buffered int
+	dropped  int64
+}
+
+// Send writes data to the peer and records it in the transcript
+func (s *Session) Send(data []byte) error {
+	if !s.Active() {
+		return fmt.Errorf("session #%d is closed", s.ID)
+	}
+	n, err := s.conn.Write(data)
+	if n > 0 {
+		s.record(DirOut, data[:n])
+	}
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+// Close closes the connection
+func (s *Session) Close() {
+	s.conn.Close()
+	s.finish(nil)
+}
+
+// Active reports whether the connection is still open
+func (s *Session) Active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ended.IsZero()
+}
+
+// Stats returns the bytes received and sent and how long the session lasted
+func (s *Session) Stats() (in, out int64, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := s.ended
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return s.bytesIn, s.bytesOut, end.Sub(s.Started)
+}
+
+// Err returns the error that ended the session, if any
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Chunks returns the recorded transcript and the number of leading bytes
+// dropped from it
+func (s *Session) Chunks() ([]Chunk, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Chunk(nil), s.chunks...), s.dropped
+}
+
+// Status returns a short description of the session state
+func (s *Session) Status() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.ended.IsZero():
+		return "active"
+	case s.err != nil:
+		return "error"
+	}
+	return "closed"
+}
+
+func (s *Session) record(dir Direction, data []byte) {
+	s.mu.Lock()
+	chunk := Chunk{Time: time.Now(), Dir: dir, Data: append([]byte(nil), data...)}
+	if dir == DirIn {
+		chunk.Offset = s.bytesIn
+		s.bytesIn += int64(len(data))
+	} else {
+		chunk.Offset = s.bytesOut
+		s.bytesOut += int64(len(data))
+	}
+	s.chunks = append(s.chunks, chunk)
+	s.buffered += len(data)
+	for s.buffered > maxTranscriptBytes && len(s.chunks) > 1 {
+		s.buffered -= len(s.chunks[0].Data)
+		s.dropped += int64(len(s.chunks[0].Data))
+		s.chunks = s.chunks[1:]
+	}
+	s.mu.Unlock()
+
+	s.manager.notify()
+}
+
+func (s *Session) finish(err error) {
+	s.mu.Lock()
+	if !s.ended.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = time.Now()
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		s.err = err
+	}
+	s.mu.Unlock()
+
+	if s.err != nil {
+		s.manager.logf("ERROR", "Session #%d with %s failed: %v", s.ID, s.RemoteAddr, s.err)
+	} else {
+		s.manager.logf("INFO", "Session #%d with %s closed", s.ID, s.RemoteAddr)
+	}
+}
+
+// readLoop copies everything the peer sends into the transcript
+func (s *Session) readLoop(conn net.Conn) {
+	buf := make([]byte, sessionReadSize)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			s.record(DirIn, buf[:n])
+		}
+		if err != nil {
+			s.finish(err)
+			return
+		}
+	}
+}
+
+// Listener is a listening socket whose connections become sessions
+type Listener struct {
+	Network string
+	Addr    string
+	Started time.Time
+
+	closer io.Closer
+}
+
+// SessionManager owns the listeners and sessions started from the TUI.
+// Network goroutines record into sessions directly and signal the UI to
+// redraw through a coalescing event channel.
+type SessionManager struct {
+	mu        sync.Mutex
+	nextID    int
+	sessions  []*Session
+	listeners []*Listener
+	logs      []LogMessage
+	events    chan struct{}
+}
+
+// sessionEventMsg tells the model that session state changed
+type sessionEventMsg struct{}
+
+// sessionDialedMsg delivers the result of an outbound connection attempt
+type sessionDialedMsg struct {
+	session *Session
+	target  string
+	err     error
+}
+
+// NewSessionManager creates an empty session manager
+func NewSessionManager() *SessionManager {
+	return &SessionManager{events: make(chan struct{}, 1)}
+}
+
+// wait returns a command that blocks until session state changes
+func (sm *SessionManager) wait() tea.Cmd {
+	return func() tea.Msg {
+		<-sm.events
+		return sessionEventMsg{}
+	}
+}
+
+func (sm *SessionManager) notify() {
+	select {
+	case sm.events <- struct{}{}:
+	default:
+	}
+}
+
+func (sm *SessionManager) logf(level, format string, args ...interface{}) {
+	sm.mu.Lock()
+	sm.logs = append(sm.logs, LogMessage{Timestamp: time.Now(), Level: level, Message: fmt.Sprintf(format, args...)})
+	if len(sm.logs) > maxSessionLogs {
+		sm.logs = sm.logs[len(sm.logs)-maxSessionLogs:]
+	}
+	sm.mu.Unlock()
+	sm.notify()
+}
+
+// Logs returns the session and listener log
+func (sm *SessionManager) Logs() []LogMessage {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return append([]LogMessage(nil), sm.logs...)
+}
+
+// ClearLogs empties the session and listener log
+func (sm *SessionManager) ClearLogs() {
+	sm.mu.Lock()
+	sm.logs = nil
+	sm.mu.Unlock()
+}
+
+// Sessions returns all sessions, oldest first
+func (sm *SessionManager) Sessions() []*Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return append([]*Session(nil), sm.sessions...)
+}
+
+// Session returns the session with the given ID, or nil
+func (sm *SessionManager) Session(id int) *Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, s := range sm.sessions {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Listeners returns the open listeners
+func (sm *SessionManager) Listeners() []*Listener {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return append([]*Listener(nil), sm.listeners...)
+}
+
+// Listener returns the open listener for network and address, or nil
+func (sm *SessionManager) Listener(network, addr string) *Listener {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, l := range sm.listeners {
+		if l.Network == network && l.Addr == addr {
+			return l
+		}
+	}
+	return nil
+}
+
+// Prune removes closed sessions and returns how many were removed
+func (sm *SessionManager) Prune() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	kept := sm.sessions[:0]
+	for _, s := range sm.sessions {
+		if s.Active() {
+			kept = append(kept, s)
+		}
+	}
+	removed := len(sm.sessions) - len(kept)
+	clear(sm.sessions[len(kept):])
+	sm.sessions = kept
+	return removed
+}
+
+// Close stops all listeners and closes all sessions
+func (sm *SessionManager) Close() {
+	for _, l := range sm.Listeners() {
+		sm.StopListener(l)
+	}
+	for _, s := range sm.Sessions() {
+		s.Close()
+	}
+}
+
+func (sm *SessionManager) newSession(kind, network string, conn io.WriteCloser, local, remote net.Addr) *Session {
+	sm.mu.Lock()
+	sm.nextID++
+	s := &Session{
+		ID:         sm.nextID,
+		Kind:       kind,
+		Network:    network,
+		LocalAddr:  local.String(),
+		RemoteAddr: remote.String(),
+		Started:    time.Now(),
+		manager:    sm,
+		conn:       conn,
+	}
+	sm.sessions = append(sm.sessions, s)
+	sm.mu.Unlock()
+	return s
+}
+
+// Dial opens an outbound connection as a new session
+func (sm *SessionManager) Dial(ctx context.Context, network, addr string) (*Session, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := sm.newSession("connect", network, conn, conn.LocalAddr(), conn.RemoteAddr())
+	sm.logf("INFO", "Session #%d connected to %s (%s)", s.ID, s.RemoteAddr, strings.ToUpper(network))
+	go s.readLoop(conn)
+	return s, nil
+}
+
+// dialSession returns a command that connects to addr in the background
+func (sm *SessionManager) dialSession(network, addr string) tea.Cmd {
+	return func() tea.Msg {
+		s, err := sm.Dial(context.Background(), network, addr)
+		return sessionDialedMsg{session: s, target: addr, err: err}
+	}
+}
+
+// Listen opens a listener whose connections (TCP) or peers (UDP) become
+// sessions until it is stopped
+func (sm *SessionManager) Listen(network, addr string) (*Listener, error) {
+	l := &Listener{Network: network, Addr: addr, Started: time.Now()}
+
+	if strings.HasPrefix(network, "udp") {
+		pc, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		l.closer = pc
+		go sm.servePacket(l, pc)
+	} else {
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		l.closer = ln
+		go sm.serveStream(l, ln)
+	}
+
+	sm.mu.Lock()
+	sm.listeners = append(sm.listeners, l)
+	sm.mu.Unlock()
+	sm.logf("INFO", "Listening on %s (%s)", addr, strings.ToUpper(network))
+	return l, nil
+}
+
+// StopListener closes a listener. Accepted TCP sessions stay open; UDP peer
+// sessions end with the socket.
+func (sm *SessionManager) StopListener(l *Listener) {
+	sm.mu.Lock()
+	for i, other := range sm.listeners {
+		if other == l {
+			sm.listeners = append(sm.listeners[:i], sm.listeners[i+1:]...)
+			break
+		}
+	}
+	sm.mu.Unlock()
+
+	l.closer.Close()
+	sm.logf("INFO", "Stopped listening on %s (%s)", l.Addr, strings.ToUpper(l.Network))
+}
+
+func (sm *SessionManager) serveStream(l *Listener, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				sm.logf("ERROR", "Accept on %s failed: %v", l.Addr, err)
+			}
+			return
+		}
+
+		s := sm.newSession("listen", l.Network, conn, conn.LocalAddr(), conn.RemoteAddr())
+		sm.logf("INFO", "Session #%d accepted from %s", s.ID, s.RemoteAddr)
+		go s.readLoop(conn)
+	}
+}
+
+// udpPeer adapts one remote address of a shared packet socket to a session
+type udpPeer struct {
+	pc   net.PacketConn
+	addr net.Addr
+}
+
+func (p *udpPeer) Write(b []byte) (int, error) {
+	return p.pc.WriteTo(b, p.addr)
+}
+
+func (p *udpPeer) Close() error {
+	return nil
+}
+
+func (sm *SessionManager) servePacket(l *Listener, pc net.PacketConn) {
+	peers := make(map[string]*Session)
+	defer func() {
+		for _, s := range peers {
+			s.finish(nil)
+		}
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				sm.logf("ERROR", "Read on %s failed: %v", l.Addr, err)
+			}
+			return
+		}
+
+		s := peers[addr.String()]
+		if s == nil || !s.Active() {
+			s = sm.newSession("listen", l.Network, &udpPeer{pc: pc, addr: addr}, pc.LocalAddr(), addr)
+			peers[addr.String()] = s
+			sm.logf("INFO", "Session #%d started by %s", s.ID, s.RemoteAddr)
+		}
+		s.record(DirIn, buf[:n])
+	}
+}
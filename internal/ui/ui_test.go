@@ -1,6 +1,17 @@
 package ui
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ibrahmsql/gocat/internal/admin"
+	"github.com/ibrahmsql/gocat/internal/scanner"
+)
 
 func TestHelloWorld(t *testing.T) {
 	// Basit test - sadece geçmesi için
@@ -33,3 +44,360 @@ func TestAppStructExists(t *testing.T) {
 		t.Error("App struct should be creatable")
 	}
 }
+
+func TestScanResultsMsg(t *testing.T) {
+	m := NewModel()
+	s := m.scanState
+	s.scanID = 2
+	s.scanning = true
+	s.counts = make(map[scanner.Status]int)
+
+	// Results of a previous scan are dropped
+	model, _ := m.handleScanResults(scanResultsMsg{id: 1, results: []ScanResult{{Port: 1}}})
+	m = model.(Model)
+	if len(s.results) != 0 {
+		t.Fatalf("stale results recorded: %v", s.results)
+	}
+
+	model, _ = m.handleScanResults(scanResultsMsg{id: 2, results: []ScanResult{
+		{Host: "10.0.0.10", Port: 80, Status: scanner.StatusOpen},
+		{Host: "10.0.0.9", Port: 22, Status: scanner.StatusOpen},
+		{Host: "10.0.0.9", Port: 23, Status: scanner.StatusClosed},
+	}, done: true})
+	m = model.(Model)
+
+	if s.scanning || s.progress != 3 || s.counts[scanner.StatusOpen] != 2 {
+		t.Errorf("scanning=%v progress=%d counts=%v", s.scanning, s.progress, s.counts)
+	}
+
+	view := s.visibleResults()
+	if len(view) != 2 || view[0].Host != "10.0.0.9" || view[1].Host != "10.0.0.10" {
+		t.Errorf("visible results not sorted by host: %v", view)
+	}
+
+	s.showClosed = true
+	s.sortBy = scanSortPort
+	s.sortDesc = true
+	s.viewDirty = true
+	if view := s.visibleResults(); len(view) != 3 || view[0].Port != 80 || view[2].Port != 22 {
+		t.Errorf("visible results not sorted by port descending: %v", view)
+	}
+}
+
+func TestScanResultsAfterStop(t *testing.T) {
+	m := NewModel()
+	s := m.scanState
+	s.scanID = 1
+	s.scanning = true
+	s.cancel = func() {}
+	s.counts = make(map[scanner.Status]int)
+	s.focused = scanFieldResults
+
+	m.stopScan()
+	model, _ := m.updateScan(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	m = model.(Model)
+
+	// A batch queued before the stop arrives late
+	model, cmd := m.handleScanResults(scanResultsMsg{id: 1, results: []ScanResult{{Port: 22, Status: scanner.StatusOpen}}})
+	m = model.(Model)
+	if len(s.results) != 0 || s.progress != 0 || cmd != nil {
+		t.Errorf("stale batch recorded: results=%v progress=%d", s.results, s.progress)
+	}
+}
+
+func TestSessionManager(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.Close()
+
+	l, err := sm.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.closer.(net.Listener).Addr().String()
+
+	client, err := sm.Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the accepted side to appear
+	var server *Session
+	for deadline := time.Now().Add(5 * time.Second); server == nil; {
+		for _, s := range sm.Sessions() {
+			if s.Kind == "listen" {
+				server = s
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("accepted session not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := client.Send([]byte("hello\nwor")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send([]byte("ld\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Send([]byte("bin\x00\xff\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		in, _, _ := server.Stats()
+		clientIn, _, _ := client.Stats()
+		if in == 12 && clientIn == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server received %d bytes, client %d", in, clientIn)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	chunks, _ := client.Chunks()
+	lines := transcriptLines(chunks)
+	want := []transcriptLine{
+		{Dir: DirOut, Text: "hello"},
+		{Dir: DirOut, Text: "world"},
+		{Dir: DirIn, Text: `bin\x00\xff`},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("transcript lines = %+v", lines)
+	}
+	for i := range want {
+		if lines[i].Dir != want[i].Dir || lines[i].Text != want[i].Text {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+	if chunks[1].Offset != 9 {
+		t.Errorf("second chunk offset = %d, want 9", chunks[1].Offset)
+	}
+
+	client.Close()
+	for deadline := time.Now().Add(5 * time.Second); server.Active(); {
+		if time.Now().After(deadline) {
+			t.Fatal("server session still active after peer closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if removed := sm.Prune(); removed != 2 {
+		t.Errorf("Prune removed %d sessions, want 2", removed)
+	}
+}
+
+func TestInspectorSearch(t *testing.T) {
+	chunks := []Chunk{
+		{Dir: DirOut, Offset: 0, Data: []byte("GET / HTTP/1.1\r\n")},
+		{Dir: DirIn, Offset: 0, Data: []byte("\xde\xad\xbe\xef\xde\xad")},
+		{Dir: DirIn, Offset: 6, Data: []byte("HTTP/1.1 200 OK")},
+	}
+
+	pattern, err := parsePattern("hex:de ad")
+	if err != nil || string(pattern) != "\xde\xad" {
+		t.Fatalf("parsePattern(hex) = %x, %v", pattern, err)
+	}
+	if _, err := parsePattern("0xzz"); err == nil {
+		t.Error("invalid hex pattern accepted")
+	}
+
+	matches := findMatches(chunks, pattern)
+	want := []searchMatch{{chunk: chunkKey{DirIn, 0}, pos: 0}, {chunk: chunkKey{DirIn, 0}, pos: 4}}
+	if len(matches) != len(want) || matches[0] != want[0] || matches[1] != want[1] {
+		t.Errorf("findMatches(hex) = %v, want %v", matches, want)
+	}
+
+	pattern, _ = parsePattern("HTTP")
+	if matches := findMatches(chunks, pattern); len(matches) != 2 {
+		t.Errorf("findMatches(text) found %d matches, want 2", len(matches))
+	}
+
+	in := newInspectorState()
+	in.filter = filterIn
+	if filtered := in.filterChunks(chunks); len(filtered) != 2 || filtered[0].Dir != DirIn {
+		t.Errorf("filterChunks(in) = %v", filtered)
+	}
+}
+
+func TestInspectorSelection(t *testing.T) {
+	chunks := []Chunk{
+		{Dir: DirOut, Offset: 0, Data: []byte("a")},
+		{Dir: DirIn, Offset: 0, Data: []byte("b")},
+		{Dir: DirOut, Offset: 1, Data: []byte("c")},
+	}
+	in := newInspectorState()
+
+	in.cursor = 1
+	if sel := in.selection(chunks); len(sel) != 1 || string(sel[0].Data) != "b" {
+		t.Errorf("cursor selection = %v", sel)
+	}
+
+	in.bookmarks[keyOf(chunks[0])] = true
+	in.bookmarks[keyOf(chunks[2])] = true
+	if sel := in.selection(chunks); len(sel) != 2 || string(sel[1].Data) != "c" {
+		t.Errorf("bookmark selection = %v", sel)
+	}
+
+	in.anchor = 2
+	in.cursor = 1
+	if sel := in.selection(chunks); len(sel) != 2 || string(sel[0].Data) != "b" {
+		t.Errorf("range selection = %v", sel)
+	}
+}
+
+func TestHexDumpLines(t *testing.T) {
+	lines := hexDumpLines([]byte("Hello, World!\x00\x01\x02ABC"), 16)
+	want := []string{
+		"00000010  48 65 6c 6c 6f 2c 20 57 6f 72 6c 64 21 00 01 02  |Hello, World!...|",
+		"00000020  41 42 43                                         |ABC|",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("hexDumpLines = %q", lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestBrokerHub(t *testing.T) {
+	b, err := hostBroker("127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := &HubState{}
+	hub.start(b)
+	defer hub.stop()
+
+	addr := b.server.Addr().String()
+	sender, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	receiver, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	for deadline := time.Now().Add(5 * time.Second); len(hub.snapshot.Clients) < 2; hub.poll() {
+		if time.Now().After(deadline) {
+			t.Fatal("clients not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := sender.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	senderID := hub.snapshot.Clients[0].ID
+	sawRate := false
+	for deadline := time.Now().Add(5 * time.Second); hub.snapshot.Stats.BytesOut < 4; hub.poll() {
+		if time.Now().After(deadline) {
+			t.Fatal("data not relayed")
+		}
+		time.Sleep(10 * time.Millisecond)
+		sawRate = sawRate || hub.rates[senderID].in > 0
+	}
+	if !sawRate && hub.rates[senderID].in <= 0 {
+		t.Error("no inbound throughput measured for the sender")
+	}
+
+	m := NewModel()
+	hub.cursor = 1
+	if !m.updateHubClients(hub, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")}) {
+		t.Fatal("kick key not handled")
+	}
+	if len(hub.snapshot.Clients) != 1 || m.errorMsg != "" {
+		t.Errorf("after kick: clients = %d, error = %q", len(hub.snapshot.Clients), m.errorMsg)
+	}
+}
+
+func TestRemoteHub(t *testing.T) {
+	b, err := hostBroker("127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// A stand-in for gocat broker --admin-socket
+	srv := admin.NewServer("broker")
+	srv.Handle("sessions.list", "", func(json.RawMessage) (interface{}, error) { return b.server.Clients(), nil })
+	srv.Handle("stats", "", func(json.RawMessage) (interface{}, error) { return b.server.Stats(), nil })
+	srv.Handle("events", "", func(json.RawMessage) (interface{}, error) { return b.server.Events(), nil })
+	srv.Handle("sessions.kill", "", func(params json.RawMessage) (interface{}, error) {
+		var p struct{ ID string }
+		json.Unmarshal(params, &p)
+		return nil, b.server.Kick(p.ID)
+	})
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	if err := srv.Listen(path); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	defer srv.Close()
+
+	if _, err := attachHub(path, "chat"); err == nil {
+		t.Error("attaching to a broker as a chat server should fail")
+	}
+	remote, err := attachHub(path, "broker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := &HubState{}
+	hub.start(remote)
+
+	conn, err := net.Dial("tcp", b.server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); len(hub.snapshot.Clients) < 1; hub.poll() {
+		if time.Now().After(deadline) {
+			t.Fatalf("client not seen over the admin socket (err %v)", hub.err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(hub.snapshot.Events) == 0 || hub.snapshot.Stats.Total != 1 {
+		t.Errorf("snapshot = %+v", hub.snapshot)
+	}
+
+	m := NewModel()
+	m.updateHubClients(hub, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")})
+	if len(hub.snapshot.Clients) != 0 || m.errorMsg != "" {
+		t.Errorf("after remote kick: clients = %d, error = %q", len(hub.snapshot.Clients), m.errorMsg)
+	}
+
+	// Detaching leaves the broker running
+	hub.stop()
+	again, err := net.Dial("tcp", b.server.Addr().String())
+	if err != nil {
+		t.Fatalf("broker stopped on detach: %v", err)
+	}
+	defer again.Close()
+	for deadline := time.Now().Add(5 * time.Second); len(b.server.Clients()) < 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("broker stopped accepting clients on detach")
+		}
+	}
+}
+
+func TestParseChatLine(t *testing.T) {
+	tests := []struct {
+		line, sender, message string
+	}{
+		{"[15:04] alice: hello there", "alice", "hello there"},
+		{"[15:04] alice: a: b", "alice", "a: b"},
+		{"*** bob joined the chat ***", "", "*** bob joined the chat ***"},
+		{"Current time: 2024-01-02 15:04:05", "", "Current time: 2024-01-02 15:04:05"},
+	}
+	for _, tt := range tests {
+		got := parseChatLine(tt.line)
+		if got.Sender != tt.sender || got.Message != tt.message {
+			t.Errorf("parseChatLine(%q) = %q, %q; want %q, %q", tt.line, got.Sender, got.Message, tt.sender, tt.message)
+		}
+	}
+}
@@ -23,6 +23,10 @@ type WorkerPool struct {
 	taskQueue     chan Task       // Queue for incoming tasks
 	ctx           context.Context // Pool context
 	cancel        context.CancelFunc
+	closed        int32         // Set once shutdown starts, rejects new tasks
+	closing       chan struct{} // Closed once shutdown starts, wakes blocked submitters
+	closeOnce     sync.Once
+	submitMu      sync.RWMutex // Held by submitters, lets shutdown wait them out
 
 	// Synchronization
 	mu sync.RWMutex   // Protects workers map
@@ -139,6 +143,7 @@ func NewWorkerPool(config *PoolConfig) *WorkerPool {
 		taskQueue:   make(chan Task, config.QueueSize),
 		ctx:         ctx,
 		cancel:      cancel,
+		closing:     make(chan struct{}),
 		stats: &PoolStats{
 			LastScaleEvent: time.Now(),
 		},
@@ -157,18 +162,89 @@ func NewWorkerPool(config *PoolConfig) *WorkerPool {
 
 // Submit submits a task to the worker pool
 func (wp *WorkerPool) Submit(task Task) error {
+	wp.submitMu.RLock()
+	defer wp.submitMu.RUnlock()
+	if wp.isClosed() {
+		return fmt.Errorf("worker pool is shutting down")
+	}
+
 	select {
 	case wp.taskQueue <- task:
-		atomic.AddInt64(&wp.stats.TotalTasks, 1)
-		atomic.AddInt64(&wp.stats.QueuedTasks, 1)
+		wp.queued()
 		return nil
-	case <-wp.ctx.Done():
+	case <-wp.closing:
 		return fmt.Errorf("worker pool is shutting down")
 	default:
 		return fmt.Errorf("task queue is full")
 	}
 }
 
+// SubmitWait submits a task, blocking while the queue is full until ctx is done
+func (wp *WorkerPool) SubmitWait(ctx context.Context, task Task) error {
+	wp.submitMu.RLock()
+	defer wp.submitMu.RUnlock()
+	if wp.isClosed() {
+		return fmt.Errorf("worker pool is shutting down")
+	}
+
+	select {
+	case wp.taskQueue <- task:
+		wp.queued()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wp.closing:
+		return fmt.Errorf("worker pool is shutting down")
+	}
+}
+
+// isClosed reports whether the pool stopped accepting tasks
+func (wp *WorkerPool) isClosed() bool {
+	return atomic.LoadInt32(&wp.closed) == 1 || wp.ctx.Err() != nil
+}
+
+// close stops accepting tasks and waits for submitters in progress, so no
+// task enters the queue afterwards
+func (wp *WorkerPool) close() {
+	atomic.StoreInt32(&wp.closed, 1)
+	wp.closeOnce.Do(func() { close(wp.closing) })
+	wp.submitMu.Lock()
+	wp.submitMu.Unlock()
+}
+
+// rejectQueued runs the tasks left in the queue once the pool context is
+// cancelled. Every accepted task runs, so callers waiting on one are
+// released; tasks should return early when their context is done.
+func (wp *WorkerPool) rejectQueued() {
+	for {
+		select {
+		case task := <-wp.taskQueue:
+			atomic.AddInt64(&wp.stats.QueuedTasks, -1)
+			atomic.AddInt64(&wp.stats.FailedTasks, 1)
+			task.Execute(wp.ctx)
+		default:
+			return
+		}
+	}
+}
+
+// queued updates the statistics for a task that entered the queue and
+// starts a worker right away if no idle worker is left to pick it up, so
+// long-running tasks don't wait for the scaling monitor
+func (wp *WorkerPool) queued() {
+	atomic.AddInt64(&wp.stats.TotalTasks, 1)
+	queued := atomic.AddInt64(&wp.stats.QueuedTasks, 1)
+	if queued <= atomic.LoadInt64(&wp.stats.IdleWorkers) {
+		return
+	}
+
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	if len(wp.workers) < wp.maxWorkers && wp.ctx.Err() == nil {
+		wp.createWorkerLocked()
+	}
+}
+
 // SubmitFunc submits a function as a task
 func (wp *WorkerPool) SubmitFunc(id string, fn func(ctx context.Context) error) error {
 	task := &TaskFunc{
@@ -184,7 +260,11 @@ func (wp *WorkerPool) SubmitFunc(id string, fn func(ctx context.Context) error)
 func (wp *WorkerPool) createWorker() *Worker {
 	wp.mu.Lock()
 	defer wp.mu.Unlock()
+	return wp.createWorkerLocked()
+}
 
+// createWorkerLocked creates a new worker and starts it (caller must hold lock)
+func (wp *WorkerPool) createWorkerLocked() *Worker {
 	workerID := int(atomic.AddInt64(&wp.workerCounter, 1))
 	ctx, cancel := context.WithCancel(wp.ctx)
 
@@ -343,17 +423,19 @@ func (wp *WorkerPool) scaleWorkers() {
 	idleWorkers := atomic.LoadInt64(&wp.stats.IdleWorkers)
 
 	// Scale up if queue is building up and we have capacity
-	if queuedTasks > activeWorkers && int(activeWorkers) < wp.maxWorkers {
-		needed := queuedTasks - activeWorkers
+	if queuedTasks > idleWorkers && int(activeWorkers) < wp.maxWorkers {
+		needed := queuedTasks - idleWorkers
 		maxToCreate := int64(wp.maxWorkers) - activeWorkers
 
 		if needed > maxToCreate {
 			needed = maxToCreate
 		}
 
-		for i := int64(0); i < needed; i++ {
-			wp.createWorker()
+		wp.mu.Lock()
+		for i := int64(0); i < needed && len(wp.workers) < wp.maxWorkers; i++ {
+			wp.createWorkerLocked()
 		}
+		wp.mu.Unlock()
 
 		wp.stats.LastScaleEvent = time.Now()
 	}
@@ -422,8 +504,14 @@ func (wp *WorkerPool) GetStats() PoolStats {
 
 // Shutdown gracefully shuts down the worker pool
 func (wp *WorkerPool) Shutdown(timeout time.Duration) error {
-	// Stop accepting new tasks
+	// Stop accepting new tasks and let the workers drain the queue
+	wp.close()
+	deadline := time.Now().Add(timeout)
+	for len(wp.taskQueue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
 	wp.cancel()
+	wp.rejectQueued()
 
 	// Wait for all workers to finish with timeout
 	done := make(chan struct{})
@@ -435,13 +523,14 @@ func (wp *WorkerPool) Shutdown(timeout time.Duration) error {
 	select {
 	case <-done:
 		return nil
-	case <-time.After(timeout):
+	case <-time.After(time.Until(deadline)):
 		return fmt.Errorf("shutdown timeout exceeded")
 	}
 }
 
 // ForceShutdown forcefully shuts down the worker pool
 func (wp *WorkerPool) ForceShutdown() {
+	wp.close()
 	wp.cancel()
 
 	// Terminate all workers
@@ -454,6 +543,7 @@ func (wp *WorkerPool) ForceShutdown() {
 		wp.terminateWorkerLocked(id)
 	}
 	wp.mu.Unlock()
+	wp.rejectQueued()
 }
 
 // QueueSize returns the current queue size
@@ -5,6 +5,7 @@
 -- Feature: SSL/TLS support for secure services
 -- Feature: Customizable timeout and retry mechanisms
 -- Usage: Configure target host and run to identify services
+-- Capability: net
 
 -- Configuration
 local CONFIG = {
@@ -1,5 +1,6 @@
 -- Advanced Chat Bot Example
 -- Demonstrates a more sophisticated chat bot with multiple features
+-- Capability: net:*:8888
 
 local config = {
     port = 8888,
@@ -1,5 +1,6 @@
 -- DNS Resolver Script for GoCat
 -- Performs DNS queries and analysis
+-- Capability: net:*:53
 
 local dns_record_types = {
     A = 1,      -- IPv4 address
@@ -1,126 +1,67 @@
--- Docker Ports Test - Testing known open Docker ports
--- From ss output: 9090 and 3000 are open via docker-proxy
+-- Scanner Accuracy Test
+-- Computes true/false positives and negatives for a set of known open and
+-- closed ports. The open ports are mock services standing in for the
+-- docker-proxy ports (9090, 3000) and DNS (53) the test was written against.
+-- Capability: net:127.0.0.1
+-- Usage: gocat script test scripts/docker_ports_test.lua
 
-local CONFIG = {
-    host = "127.0.0.1",
-    known_open_ports = {9090, 3000, 53}, -- From ss command output
-    known_closed_ports = {80, 443, 22},   -- Should be closed on localhost
-    delay = 0.01
-}
+local HOST = "127.0.0.1"
 
-function test_known_open_ports()
-    log("info", "🐳 DOCKER PORTS TRUE POSITIVE TEST")
-    log("info", "📊 Testing ports that ss shows as OPEN (docker-proxy + dnscrypt-proxy)")
-    log("info", "🎯 Expected: 9090, 3000, 53 should be OPEN")
-    
-    local true_positives = {}
-    local false_negatives = {}
-    
-    for i, port in ipairs(CONFIG.known_open_ports) do
-        log("info", "🔍 Testing known OPEN port " .. port)
-        
-        local conn, err = connect(CONFIG.host, port, "tcp")
-        if conn then
-            log("info", "✅ TRUE POSITIVE: Port " .. port .. " correctly detected as OPEN")
-            table.insert(true_positives, port)
-            close(conn)
-        else
-            log("error", "❌ FALSE NEGATIVE: Port " .. port .. " should be OPEN but connection failed: " .. (err or "unknown"))
-            table.insert(false_negatives, port)
-        end
-        
-        sleep(CONFIG.delay)
-    end
-    
-    log("info", "")
-    log("info", "📊 KNOWN OPEN PORTS RESULTS:")
-    log("info", "✅ True positives: " .. #true_positives .. "/" .. #CONFIG.known_open_ports)
-    log("info", "❌ False negatives: " .. #false_negatives .. "/" .. #CONFIG.known_open_ports)
-    
-    if #false_negatives == 0 then
-        log("info", "🎉 PERFECT: All known open ports correctly detected!")
-    else
-        log("warn", "⚠️  Some open ports were missed (false negatives)")
-        for _, port in ipairs(false_negatives) do
-            log("warn", "  - Port " .. port .. " was missed")
-        end
+local function is_open(port)
+    local conn = connect(HOST, port, "tcp")
+    if conn then
+        close(conn)
+        return true
     end
-    
-    return true_positives, false_negatives
+    return false
 end
 
-function test_known_closed_ports()
-    log("info", "")
-    log("info", "🔒 KNOWN CLOSED PORTS TEST")
-    log("info", "📊 Testing ports that should be CLOSED")
-    log("info", "🎯 Expected: 80, 443, 22 should be CLOSED")
-    
-    local true_negatives = {}
-    local false_positives = {}
-    
-    for i, port in ipairs(CONFIG.known_closed_ports) do
-        log("info", "🔍 Testing known CLOSED port " .. port)
-        
-        local conn, err = connect(CONFIG.host, port, "tcp")
-        if conn then
-            log("error", "🚨 FALSE POSITIVE: Port " .. port .. " reported as OPEN but should be CLOSED!")
-            table.insert(false_positives, port)
-            close(conn)
+-- Returns the accuracy in percent and the confusion counts
+local function calculate_accuracy(open_ports, closed_ports)
+    local counts = {tp = 0, fn = 0, tn = 0, fp = 0}
+
+    for _, port in ipairs(open_ports) do
+        if is_open(port) then
+            counts.tp = counts.tp + 1
         else
-            log("info", "✅ TRUE NEGATIVE: Port " .. port .. " correctly detected as CLOSED")
-            table.insert(true_negatives, port)
+            counts.fn = counts.fn + 1
         end
-        
-        sleep(CONFIG.delay)
     end
-    
-    log("info", "")
-    log("info", "📊 KNOWN CLOSED PORTS RESULTS:")
-    log("info", "✅ True negatives: " .. #true_negatives .. "/" .. #CONFIG.known_closed_ports)
-    log("info", "🚨 False positives: " .. #false_positives .. "/" .. #CONFIG.known_closed_ports)
-    
-    return true_negatives, false_positives
+    for _, port in ipairs(closed_ports) do
+        if is_open(port) then
+            counts.fp = counts.fp + 1
+        else
+            counts.tn = counts.tn + 1
+        end
+    end
+
+    local total = #open_ports + #closed_ports
+    return (counts.tp + counts.tn) / total * 100, counts
 end
 
-function calculate_overall_accuracy()
-    log("info", "")
-    log("info", "🎯 COMPREHENSIVE ACCURACY TEST")
-    
-    local tp, fn = test_known_open_ports()
-    local tn, fp = test_known_closed_ports()
-    
-    local total_tests = #CONFIG.known_open_ports + #CONFIG.known_closed_ports
-    local correct_predictions = #tp + #tn
-    local accuracy = (correct_predictions / total_tests) * 100
-    
-    log("info", "")
-    log("info", "🏁 FINAL ACCURACY METRICS:")
-    log("info", "📈 True Positives (TP): " .. #tp .. " - Correctly identified OPEN ports")
-    log("info", "📈 True Negatives (TN): " .. #tn .. " - Correctly identified CLOSED ports")
-    log("info", "📉 False Positives (FP): " .. #fp .. " - Incorrectly identified as OPEN")
-    log("info", "📉 False Negatives (FN): " .. #fn .. " - Missed OPEN ports")
-    log("info", "")
-    log("info", "🔢 Total tests: " .. total_tests)
-    log("info", "✅ Correct predictions: " .. correct_predictions)
-    log("info", "🎯 Overall accuracy: " .. string.format("%.1f%%", accuracy))
-    
-    if accuracy >= 95 then
-        log("info", "🏆 EXCELLENT: GoCat scanner is highly accurate!")
-    elseif accuracy >= 80 then
-        log("info", "✅ GOOD: GoCat scanner accuracy is acceptable")
-    else
-        log("warn", "⚠️  Scanner accuracy needs improvement")
+test("known open and closed ports are classified correctly", function()
+    local services = {
+        mock.tcp{banner = "HTTP/1.1 200 OK\r\n\r\n"}, -- prometheus (9090)
+        mock.tcp{banner = "HTTP/1.1 302 Found\r\n\r\n"}, -- grafana (3000)
+        mock.tcp{}, -- dnscrypt-proxy (53)
+    }
+    local open_ports = {}
+    for i, service in ipairs(services) do
+        open_ports[i] = service.port
     end
-    
-    return accuracy
-end
+    local closed_ports = {mock.free_port(), mock.free_port(), mock.free_port()}
+
+    local accuracy, counts = calculate_accuracy(open_ports, closed_ports)
+    expect(counts):to_equal({tp = 3, fn = 0, tn = 3, fp = 0})
+    expect(accuracy):to_be(100)
+end)
 
--- Run comprehensive tests
-log("info", "🚀 Starting GoCat Comprehensive Accuracy Test...")
-log("info", "📊 Testing against real system port status (from ss output)")
+test("service banner is read from open port", function()
+    local service = mock.tcp{banner = "HTTP/1.1 200 OK\r\nServer: prometheus\r\n\r\n"}
 
-local final_accuracy = calculate_overall_accuracy()
+    local conn = assert(connect(HOST, service.port, "tcp"))
+    local banner = receive(conn, 1024)
+    close(conn)
 
-log("info", "")
-log("info", "🎊 GOCAT SCANNER VALIDATION COMPLETE!")
-log("info", "📊 Final accuracy score: " .. string.format("%.1f%%", final_accuracy))
\ No newline at end of file
+    assert.matches(banner, "Server: prometheus")
+end)
@@ -1,5 +1,6 @@
 -- Banner Grabber Example
 -- Connects to services and grabs their banners
+-- Capability: net:localhost
 
 local targets = {
     {host = "localhost", port = 22, name = "SSH"},
@@ -0,0 +1,26 @@
+-- Connection Handler Example
+-- Used with --lua-exec; every connection runs in its own Lua state
+--
+--   gocat listen 8888 --lua-exec scripts/examples/conn_handler.lua
+--   gocat connect example.com 80 --lua-exec scripts/examples/conn_handler.lua
+
+local lines = 0
+
+function on_connect(conn)
+    local host, port = conn:peer()
+    log("info", "Client connected: " .. host .. ":" .. port)
+    conn:write("Welcome! Type 'quit' to disconnect.\n")
+end
+
+function on_data(conn, data)
+    lines = lines + 1
+    if data:match("^quit") then
+        conn:write("Bye!\n")
+        return false
+    end
+    conn:write("[" .. lines .. "] " .. data)
+end
+
+function on_close(conn, reason)
+    log("info", "Connection closed (" .. reason .. ") after " .. lines .. " messages")
+end
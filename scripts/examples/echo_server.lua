@@ -1,5 +1,6 @@
 -- Echo Server Example
 -- Listens on a port and echoes back received data
+-- Capability: net:*:8888
 
 local port = 8888
 
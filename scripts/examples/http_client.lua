@@ -1,5 +1,6 @@
 -- HTTP Client Example
 -- Makes a simple HTTP GET request
+-- Capability: net:example.com:80
 
 local host = "example.com"
 local port = 80
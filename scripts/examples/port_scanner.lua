@@ -1,5 +1,6 @@
 -- Port Scanner Example
 -- Scans a range of ports on a target host
+-- Capability: net:localhost
 
 local target_host = "localhost"
 local start_port = 1
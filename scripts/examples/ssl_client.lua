@@ -1,5 +1,6 @@
 -- SSL/TLS Client Example
 -- Connects to an HTTPS server
+-- Capability: net:www.google.com:443
 
 local host = "www.google.com"
 local port = 443
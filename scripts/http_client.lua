@@ -1,5 +1,6 @@
 -- HTTP Client Script for GoCat
 -- Simple HTTP client implementation
+-- Capability: net
 
 function http_request(host, port, method, path, headers, body)
     method = method or "GET"
@@ -1,5 +1,6 @@
 -- Network Monitor Script for GoCat
 -- Monitors network connectivity and logs status
+-- Capability: net
 
 local monitor_config = {
     targets = {
@@ -4,16 +4,21 @@
 -- Feature: Configurable scan ranges and timing
 -- Feature: Progress reporting and result summary
 -- Feature: Rate limiting to prevent network flooding
--- Usage: Modify the configuration section below and run the script
+-- Usage: gocat script run port_scanner --target 10.0.0.5 --ports 1-1024 --allow-cap net
+-- Version: 2.1.0
+-- Arg: target:host=127.0.0.1 Target host to scan
+-- Arg: ports:ports=20-100 Ports to scan, e.g. 22,80,8000-8100
+-- Arg: delay:duration=0.1 Delay between scans
+-- Arg: progress:int=10 Progress report interval
+-- Capability: net
 
--- Configuration
+-- Configuration, taken from the script arguments
 local CONFIG = {
-    host = "127.0.0.1",        -- Target host to scan
-    start_port = 20,           -- Starting port number
-    end_port = 100,            -- Ending port number
-    delay = 0.1,               -- Delay between scans (seconds)
-    timeout = 3,               -- Connection timeout (seconds)
-    progress_interval = 10     -- Progress report interval
+    host = args.target,                   -- Target host to scan
+    ports = args.ports,                   -- Ports to scan
+    delay = args.delay,                   -- Delay between scans (seconds)
+    timeout = 3,                          -- Connection timeout (seconds)
+    progress_interval = args.progress     -- Progress report interval
 }
 
 -- Scan a single port on the target host
@@ -38,16 +43,6 @@ end
 
 -- Scan a range of ports
 function scan_range(host, start_port, end_port, options)
-    options = options or {}
-    local delay = options.delay or CONFIG.delay
-    local progress_interval = options.progress_interval or CONFIG.progress_interval
-    
-    -- Validation
-    if not host or host == "" then
-        log("error", "Host cannot be empty")
-        return {}
-    end
-    
     if start_port <= 0 or end_port <= 0 or start_port > end_port then
         log("error", "Invalid port range: " .. start_port .. "-" .. end_port)
         return {}
@@ -58,14 +53,33 @@ function scan_range(host, start_port, end_port, options)
         end_port = 65535
     end
     
-    log("info", "🎯 Starting port scan on " .. host .. " (" .. start_port .. "-" .. end_port .. ")")
+    local ports = {}
+    for port = start_port, end_port do
+        table.insert(ports, port)
+    end
+    return scan_ports(host, ports, options)
+end
+
+-- Scan a list of ports
+function scan_ports(host, ports, options)
+    options = options or {}
+    local delay = options.delay or CONFIG.delay
+    local progress_interval = options.progress_interval or CONFIG.progress_interval
+    
+    -- Validation
+    if not host or host == "" then
+        log("error", "Host cannot be empty")
+        return {}
+    end
+    
+    log("info", "🎯 Starting port scan on " .. host .. " (" .. #ports .. " ports)")
     
     local open_ports = {}
-    local total_ports = end_port - start_port + 1
+    local total_ports = #ports
     local scanned = 0
     local start_time = os.time()
     
-    for port = start_port, end_port do
+    for _, port in ipairs(ports) do
         if scan_port(host, port) then
             table.insert(open_ports, port)
         end
@@ -115,14 +129,14 @@ function scan_common_ports(host)
 end
 
 -- Main execution
-if CONFIG.host and CONFIG.start_port and CONFIG.end_port then
-    log("info", "🚀 GoCat Port Scanner v2.0 starting...")
+if CONFIG.host and CONFIG.ports then
+    log("info", "🚀 GoCat Port Scanner v2.1 starting...")
     log("info", "🎯 Target: " .. CONFIG.host)
-    log("info", "📡 Range: " .. CONFIG.start_port .. "-" .. CONFIG.end_port)
+    log("info", "📡 Ports: " .. #CONFIG.ports)
     
-    local results = scan_range(CONFIG.host, CONFIG.start_port, CONFIG.end_port, CONFIG)
+    local results = scan_ports(CONFIG.host, CONFIG.ports, CONFIG)
     
     log("info", "🏁 Port scan finished. Total open ports: " .. #results)
 else
-    log("error", "Invalid configuration. Please check CONFIG section.")
+    log("error", "Invalid configuration. Pass --target and --ports.")
 end
@@ -1,5 +1,6 @@
 -- Test Port Scanner Script for GoCat
 -- Purpose: Quick port scanning test with limited range
+-- Capability: net:127.0.0.1
 
 -- Configuration
 local CONFIG = {